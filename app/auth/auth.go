@@ -0,0 +1,227 @@
+package auth
+
+//go:generate go run github.com/xtls/xray-core/common/errors/errorgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/core"
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+)
+
+type cacheEntry struct {
+	response *AuthenticateResponse
+	expire   time.Time
+}
+
+// Authenticator validates credentials of inbound users against an external endpoint, so that users
+// don't need to be in the config. Answers are cached, rejections and failures included, and the requests
+// to the endpoint are rate limited.
+type Authenticator struct {
+	config      *Config
+	timeout     time.Duration
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	conn    *grpc.ClientConn
+	client  AuthServiceClient
+	limiter *rate.Limiter
+
+	access  sync.RWMutex
+	cache   map[string]*cacheEntry
+	group   singleflight.Group
+	cleanup *task.Periodic
+}
+
+// New creates a new Authenticator.
+func New(ctx context.Context, config *Config) (*Authenticator, error) {
+	if (config.Url == "") == (config.GrpcAddress == "") {
+		return nil, newError("exactly one of url and grpc address must be set")
+	}
+	a := &Authenticator{
+		config:      config,
+		timeout:     3 * time.Second,
+		ttl:         300 * time.Second,
+		negativeTTL: 30 * time.Second,
+		cache:       make(map[string]*cacheEntry),
+	}
+	if config.Timeout > 0 {
+		a.timeout = time.Duration(config.Timeout) * time.Millisecond
+	}
+	if config.CacheTtl > 0 {
+		a.ttl = time.Duration(config.CacheTtl) * time.Second
+	}
+	if config.NegativeCacheTtl > 0 {
+		a.negativeTTL = time.Duration(config.NegativeCacheTtl) * time.Second
+	}
+	limit := 100
+	if config.RateLimit > 0 {
+		limit = int(config.RateLimit)
+	}
+	a.limiter = rate.NewLimiter(rate.Limit(limit), limit)
+	a.cleanup = &task.Periodic{
+		Interval: time.Minute,
+		Execute:  a.removeExpired,
+	}
+	return a, nil
+}
+
+// FromInstance returns the Authenticator of the instance, or nil if none is configured.
+func FromInstance(v *core.Instance) *Authenticator {
+	if v == nil {
+		return nil
+	}
+	a, _ := v.GetFeature(Type()).(*Authenticator)
+	return a
+}
+
+// Type implements common.HasType.
+func (*Authenticator) Type() interface{} {
+	return Type()
+}
+
+// Type returns the feature type of Authenticator.
+func Type() interface{} {
+	return (*Authenticator)(nil)
+}
+
+// Start implements common.Runnable.
+func (a *Authenticator) Start() error {
+	if a.config.GrpcAddress != "" {
+		conn, err := grpc.Dial(a.config.GrpcAddress, grpc.WithInsecure())
+		if err != nil {
+			return newError("failed to dial auth service ", a.config.GrpcAddress).Base(err)
+		}
+		a.conn = conn
+		a.client = NewAuthServiceClient(conn)
+	}
+	return a.cleanup.Start()
+}
+
+// Close implements common.Closable.
+func (a *Authenticator) Close() error {
+	if a.conn != nil {
+		a.conn.Close()
+	}
+	return a.cleanup.Close()
+}
+
+func (a *Authenticator) removeExpired() error {
+	now := time.Now()
+	a.access.Lock()
+	defer a.access.Unlock()
+
+	for key, entry := range a.cache {
+		if entry.expire.Before(now) {
+			delete(a.cache, key)
+		}
+	}
+	return nil
+}
+
+// Authenticate returns the user of the credential, or nil if it is rejected. When the endpoint fails,
+// an expired answer is used if there is one, and otherwise the fail open setting decides. Credentials
+// over the rate limit are rejected unless there is an expired answer.
+func (a *Authenticator) Authenticate(protocol string, credential string) *AuthenticateResponse {
+	key := protocol + ":" + credential
+
+	a.access.RLock()
+	entry := a.cache[key]
+	a.access.RUnlock()
+	if entry != nil && time.Now().Before(entry.expire) {
+		return allowed(entry.response)
+	}
+
+	if !a.limiter.Allow() {
+		newError("rate limit of auth endpoint exceeded, rejecting ", protocol, " user").AtDebug().WriteToLog()
+		if entry != nil {
+			return allowed(entry.response)
+		}
+		return nil
+	}
+
+	v, err, _ := a.group.Do(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+		defer cancel()
+		return a.request(ctx, &AuthenticateRequest{
+			Protocol:   protocol,
+			Credential: credential,
+		})
+	})
+	var response *AuthenticateResponse
+	ttl := a.ttl
+	switch {
+	case err == nil:
+		response = v.(*AuthenticateResponse)
+		if !response.Allowed {
+			ttl = a.negativeTTL
+		}
+	case entry != nil:
+		newError("failed to authenticate ", protocol, " user").Base(err).AtWarning().WriteToLog()
+		return allowed(entry.response)
+	default:
+		// the endpoint isn't asked again for the credential until the decision expires
+		newError("failed to authenticate ", protocol, " user").Base(err).AtWarning().WriteToLog()
+		response = &AuthenticateResponse{Allowed: a.config.FailOpen}
+		ttl = a.negativeTTL
+	}
+	a.access.Lock()
+	a.cache[key] = &cacheEntry{
+		response: response,
+		expire:   time.Now().Add(ttl),
+	}
+	a.access.Unlock()
+	return allowed(response)
+}
+
+func allowed(response *AuthenticateResponse) *AuthenticateResponse {
+	if !response.Allowed {
+		return nil
+	}
+	return response
+}
+
+func (a *Authenticator) request(ctx context.Context, request *AuthenticateRequest) (*AuthenticateResponse, error) {
+	if a.client != nil {
+		return a.client.Authenticate(ctx, request)
+	}
+
+	body, _ := json.Marshal(request)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.config.Url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound:
+		return &AuthenticateResponse{}, nil
+	case resp.StatusCode >= 300:
+		return nil, newError("auth url returned status ", resp.StatusCode)
+	}
+	response := new(AuthenticateResponse)
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return nil, newError("invalid response from auth url").Base(err)
+	}
+	return response, nil
+}
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		return New(ctx, config.(*Config))
+	}))
+}
@@ -0,0 +1,98 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/xtls/xray-core/app/auth"
+	"github.com/xtls/xray-core/common"
+)
+
+func TestHTTPAuthenticate(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		var request AuthenticateRequest
+		common.Must(json.NewDecoder(r.Body).Decode(&request))
+		if request.Protocol != "vless" || request.Credential != "good" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(&AuthenticateResponse{Allowed: true, Email: "love@example.com", Level: 1})
+	}))
+	defer server.Close()
+
+	a, err := New(context.Background(), &Config{Url: server.URL})
+	common.Must(err)
+	common.Must(a.Start())
+	defer a.Close()
+
+	for i := 0; i < 2; i++ {
+		if r := a.Authenticate("vless", "good"); r == nil || r.Email != "love@example.com" || r.Level != 1 {
+			t.Error("unexpected response: ", r)
+		}
+		if r := a.Authenticate("vless", "bad"); r != nil {
+			t.Error("expected rejection, but got ", r)
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Error("expected 2 requests with cached answers, but got ", n)
+	}
+}
+
+func TestFailurePolicy(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	closed, err := New(context.Background(), &Config{Url: server.URL})
+	common.Must(err)
+	for i := 0; i < 2; i++ {
+		if r := closed.Authenticate("trojan", "any"); r != nil {
+			t.Error("expected fail closed, but got ", r)
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Error("expected the failure to be cached, but got ", n, " requests")
+	}
+
+	open, err := New(context.Background(), &Config{Url: server.URL, FailOpen: true})
+	common.Must(err)
+	if r := open.Authenticate("trojan", "any"); r == nil || r.Email != "" || r.Level != 0 {
+		t.Error("expected anonymous user with fail open, but got ", r)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		json.NewEncoder(w).Encode(&AuthenticateResponse{Allowed: true})
+	}))
+	defer server.Close()
+
+	a, err := New(context.Background(), &Config{Url: server.URL, RateLimit: 1, FailOpen: true})
+	common.Must(err)
+	if r := a.Authenticate("vless", "first"); r == nil {
+		t.Error("expected the first credential to be allowed")
+	}
+	// probes of random credentials are rejected without asking the endpoint, even with fail open
+	for _, credential := range []string{"second", "third"} {
+		if r := a.Authenticate("vless", credential); r != nil {
+			t.Error("expected ", credential, " to be rejected, but got ", r)
+		}
+	}
+	if r := a.Authenticate("vless", "first"); r == nil {
+		t.Error("expected the cached credential to be allowed")
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Error("expected 1 request within the rate limit, but got ", n)
+	}
+}
@@ -0,0 +1,143 @@
+package commander
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/log"
+	"github.com/xtls/xray-core/common/serial"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// maximum length of the request summary in an audit record
+const auditRequestSummaryLen = 256
+
+// AuditMessage is a log.Message recording one API call.
+type AuditMessage struct {
+	Method  string
+	Caller  string
+	Request string
+	Result  string
+}
+
+func (m *AuditMessage) String() string {
+	builder := strings.Builder{}
+	builder.WriteString("[API] ")
+	builder.WriteString(m.Method)
+	builder.WriteString(" caller: ")
+	builder.WriteString(m.Caller)
+	builder.WriteString(" request: {")
+	builder.WriteString(m.Request)
+	builder.WriteString("} result: ")
+	builder.WriteString(m.Result)
+	return builder.String()
+}
+
+// auditor records API calls to a dedicated log handler.
+type auditor struct {
+	handler log.Handler
+}
+
+func newAuditor(path string) (*auditor, error) {
+	creator, err := log.CreateFileLogWriter(path)
+	if err != nil {
+		return nil, newError("failed to create audit log").Base(err)
+	}
+	return &auditor{
+		handler: log.NewLogger(creator),
+	}, nil
+}
+
+// callerFromContext identifies the caller of an API call by its token name and address.
+func callerFromContext(ctx context.Context, tokenName string) string {
+	addr := "unknown"
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr = p.Addr.String()
+	}
+	if tokenName != "" {
+		return tokenName + "@" + addr
+	}
+	return addr
+}
+
+// summarizeRequest describes a request by the tags and emails it targets only, as the rest of it may carry
+// secrets such as user IDs, passwords or private keys.
+func summarizeRequest(req interface{}) string {
+	m, ok := req.(proto.Message)
+	if !ok || m == nil {
+		return ""
+	}
+	var targets []string
+	collectTargets(m.ProtoReflect(), &targets, 0)
+	s := strings.Join(targets, " ")
+	if len(s) > auditRequestSummaryLen {
+		cut := auditRequestSummaryLen
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		s = s[:cut] + "..."
+	}
+	return s
+}
+
+// collectTargets appends the tag and email fields of the message and of the messages in it, including the ones
+// in typed messages.
+func collectTargets(m protoreflect.Message, targets *[]string, depth int) {
+	if depth > 4 {
+		return
+	}
+	if tm, ok := m.Interface().(*serial.TypedMessage); ok {
+		if instance, err := tm.GetInstance(); err == nil {
+			if instance, ok := instance.(proto.Message); ok {
+				collectTargets(instance.ProtoReflect(), targets, depth+1)
+			}
+		}
+		return
+	}
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !m.Has(fd) {
+			continue
+		}
+		v := m.Get(fd)
+		switch {
+		case fd.Kind() == protoreflect.StringKind && !fd.IsList() && !fd.IsMap():
+			if name := fd.Name(); name == "tag" || name == "email" {
+				*targets = append(*targets, fmt.Sprintf("%s: %q", name, v.String()))
+			}
+		case fd.Kind() == protoreflect.MessageKind && fd.IsList():
+			list := v.List()
+			for j := 0; j < list.Len(); j++ {
+				collectTargets(list.Get(j).Message(), targets, depth+1)
+			}
+		case fd.Kind() == protoreflect.MessageKind && !fd.IsMap():
+			collectTargets(v.Message(), targets, depth+1)
+		}
+	}
+}
+
+func (a *auditor) record(ctx context.Context, tokenName string, method string, req interface{}, err error) {
+	result := "OK"
+	if err != nil {
+		s := status.Convert(err)
+		result = s.Code().String() + ": " + s.Message()
+	}
+	a.handler.Handle(&AuditMessage{
+		Method:  method,
+		Caller:  callerFromContext(ctx, tokenName),
+		Request: summarizeRequest(req),
+		Result:  result,
+	})
+}
+
+// Close implements common.Closable.
+func (a *auditor) Close() error {
+	return common.Close(a.handler)
+}
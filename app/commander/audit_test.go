@@ -0,0 +1,50 @@
+package commander
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/xtls/xray-core/app/proxyman/command"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/proxy/vless"
+)
+
+func TestSummarizeRequest(t *testing.T) {
+	const secret = "27848739-7e62-4138-9fd3-098a63964b6b"
+	addUser := &command.AlterInboundRequest{
+		Tag: "in",
+		Operation: serial.ToTypedMessage(&command.AddUserOperation{
+			User: &protocol.User{
+				Email:   "love@example.com",
+				Account: serial.ToTypedMessage(&vless.Account{Id: secret}),
+			},
+		}),
+	}
+	if s := summarizeRequest(addUser); s != `tag: "in" email: "love@example.com"` {
+		t.Error("unexpected summary: ", s)
+	}
+
+	addInbound := &command.AddInboundRequest{
+		Inbound: &core.InboundHandlerConfig{
+			Tag: "new",
+			ProxySettings: serial.ToTypedMessage(&vless.Account{
+				Id: secret,
+			}),
+		},
+	}
+	if s := summarizeRequest(addInbound); strings.Contains(s, secret) || s != `tag: "new"` {
+		t.Error("unexpected summary: ", s)
+	}
+
+	long := &command.RemoveInboundRequest{Tag: strings.Repeat("标签", auditRequestSummaryLen)}
+	if s := summarizeRequest(long); !utf8.ValidString(s) || len(s) > auditRequestSummaryLen+len("...") {
+		t.Error("unexpected truncation: ", s)
+	}
+
+	if s := summarizeRequest("not a message"); s != "" {
+		t.Error("unexpected summary: ", s)
+	}
+}
@@ -0,0 +1,118 @@
+package commander
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"github.com/xtls/xray-core/common/serial"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// methods allowed by Token_STATS_READ, matched by prefix.
+var statsReadMethods = []string{
+	"/xray.app.stats.command.StatsService/",
+	"/xray.core.app.observatory.command.ObservatoryService/",
+	"/xray.app.router.command.RoutingService/TestRoute",
+	"/xray.app.router.command.RoutingService/SubscribeRoutingStats",
+	"/xray.app.router.command.RoutingService/ListRule",
+	"/grpc.reflection.",
+}
+
+// methods allowed by Token_USER_MANAGEMENT in addition to statsReadMethods, and not by Token_STATS_READ
+// although they match statsReadMethods.
+var userManagementMethods = []string{
+	"/xray.app.proxyman.command.HandlerService/AlterInbound",
+	// the addresses of the users
+	"/xray.app.stats.command.StatsService/GetUserLogins",
+}
+
+// operations of AlterInbound allowed by Token_USER_MANAGEMENT, by their message type. Others, such
+// as setting a maintenance fallback, redirect the clients of the inbound and need Token_FULL_ADMIN.
+var userManagementOperations = []string{
+	"xray.app.proxyman.command.AddUserOperation",
+	"xray.app.proxyman.command.RemoveUserOperation",
+}
+
+func hasMethodPrefix(method string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows returns true if the scope permits calling the given gRPC method.
+func (s Token_Scope) Allows(method string) bool {
+	switch s {
+	case Token_FULL_ADMIN:
+		return true
+	case Token_USER_MANAGEMENT:
+		return hasMethodPrefix(method, statsReadMethods) || hasMethodPrefix(method, userManagementMethods)
+	case Token_STATS_READ:
+		return hasMethodPrefix(method, statsReadMethods) && !hasMethodPrefix(method, userManagementMethods)
+	default:
+		return false
+	}
+}
+
+// allowsRequest returns true if the scope permits the content of a request to a method it allows.
+func (s Token_Scope) allowsRequest(req interface{}) bool {
+	if s == Token_FULL_ADMIN {
+		return true
+	}
+	// resetting counters changes what other API clients read
+	if r, ok := req.(interface{ GetReset_() bool }); ok && r.GetReset_() {
+		return false
+	}
+	if r, ok := req.(interface{ GetOperation() *serial.TypedMessage }); ok && r.GetOperation() != nil {
+		for _, t := range userManagementOperations {
+			if r.GetOperation().Type == t {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// authenticator checks API calls against the configured tokens.
+type authenticator struct {
+	tokens []*Token
+}
+
+func secretFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(values[0], "Bearer ")
+}
+
+// authorize returns the name of the token used in ctx, or an error if the call is not permitted.
+func (a *authenticator) authorize(ctx context.Context, method string, req interface{}) (string, error) {
+	secret := secretFromContext(ctx)
+	if secret == "" {
+		return "", status.Error(codes.Unauthenticated, "missing API token")
+	}
+	for _, token := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(token.Secret), []byte(secret)) != 1 {
+			continue
+		}
+		if !token.Scope.Allows(method) {
+			return token.Name, status.Error(codes.PermissionDenied, "token scope "+token.Scope.String()+" does not permit "+method)
+		}
+		if !token.Scope.allowsRequest(req) {
+			return token.Name, status.Error(codes.PermissionDenied, "token scope "+token.Scope.String()+" does not permit this request to "+method)
+		}
+		return token.Name, nil
+	}
+	return "", status.Error(codes.Unauthenticated, "invalid API token")
+}
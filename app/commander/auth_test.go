@@ -0,0 +1,88 @@
+package commander
+
+import (
+	"context"
+	"testing"
+
+	"github.com/xtls/xray-core/app/proxyman"
+	"github.com/xtls/xray-core/app/proxyman/command"
+	statscmd "github.com/xtls/xray-core/app/stats/command"
+	"github.com/xtls/xray-core/common/serial"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestUserManagementOperations(t *testing.T) {
+	a := &authenticator{tokens: []*Token{
+		{Name: "panel", Secret: "user", Scope: Token_USER_MANAGEMENT},
+		{Name: "admin", Secret: "admin", Scope: Token_FULL_ADMIN},
+	}}
+	const method = "/xray.app.proxyman.command.HandlerService/AlterInbound"
+	addUser := &command.AlterInboundRequest{
+		Tag:       "in",
+		Operation: serial.ToTypedMessage(&command.RemoveUserOperation{Email: "a@example.com"}),
+	}
+	setMaintenance := &command.AlterInboundRequest{
+		Tag: "in",
+		Operation: serial.ToTypedMessage(&command.SetMaintenanceOperation{
+			Maintenance: &proxyman.MaintenanceConfig{Enabled: true, Fallback: "127.0.0.1:80"},
+		}),
+	}
+
+	for _, test := range []struct {
+		secret string
+		req    interface{}
+		allow  bool
+	}{
+		{"user", addUser, true},
+		{"user", setMaintenance, false},
+		{"admin", setMaintenance, true},
+	} {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+test.secret))
+		if _, err := a.authorize(ctx, method, test.req); (err == nil) != test.allow {
+			t.Error("unexpected result for ", test.secret, ": ", err)
+		}
+	}
+}
+
+func TestStatsReset(t *testing.T) {
+	a := &authenticator{tokens: []*Token{
+		{Name: "monitor", Secret: "read", Scope: Token_STATS_READ},
+		{Name: "panel", Secret: "user", Scope: Token_USER_MANAGEMENT},
+		{Name: "admin", Secret: "admin", Scope: Token_FULL_ADMIN},
+	}}
+
+	for _, test := range []struct {
+		secret string
+		method string
+		req    interface{}
+		allow  bool
+	}{
+		{"read", "/xray.app.stats.command.StatsService/QueryStats", &statscmd.QueryStatsRequest{Pattern: "user"}, true},
+		{"read", "/xray.app.stats.command.StatsService/QueryStats", &statscmd.QueryStatsRequest{Reset_: true}, false},
+		{"read", "/xray.app.stats.command.StatsService/GetStats", &statscmd.GetStatsRequest{Name: "a", Reset_: true}, false},
+		{"user", "/xray.app.stats.command.StatsService/GetStats", &statscmd.GetStatsRequest{Name: "a", Reset_: true}, false},
+		{"admin", "/xray.app.stats.command.StatsService/QueryStats", &statscmd.QueryStatsRequest{Reset_: true}, true},
+	} {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+test.secret))
+		if _, err := a.authorize(ctx, test.method, test.req); (err == nil) != test.allow {
+			t.Error("unexpected result for ", test.secret, " ", test.req, ": ", err)
+		}
+	}
+}
+
+func TestTokenScopeAllows(t *testing.T) {
+	for _, test := range []struct {
+		scope  Token_Scope
+		method string
+		allow  bool
+	}{
+		{Token_STATS_READ, "/xray.app.stats.command.StatsService/QueryStats", true},
+		{Token_STATS_READ, "/xray.app.stats.command.StatsService/GetUserLogins", false},
+		{Token_USER_MANAGEMENT, "/xray.app.stats.command.StatsService/GetUserLogins", true},
+		{Token_USER_MANAGEMENT, "/xray.app.proxyman.command.HandlerService/AddInbound", false},
+	} {
+		if test.scope.Allows(test.method) != test.allow {
+			t.Error("unexpected result for ", test.scope, " calling ", test.method)
+		}
+	}
+}
@@ -21,6 +21,8 @@ type Commander struct {
 	services []Service
 	ohm      outbound.Manager
 	tag      string
+	auth     *authenticator
+	auditor  *auditor
 }
 
 // NewCommander creates a new Commander based on the given config.
@@ -49,6 +51,20 @@ func NewCommander(ctx context.Context, config *Config) (*Commander, error) {
 		c.services = append(c.services, service)
 	}
 
+	if len(config.Token) > 0 {
+		c.auth = &authenticator{
+			tokens: config.Token,
+		}
+	}
+
+	if config.AuditLog != "" {
+		a, err := newAuditor(config.AuditLog)
+		if err != nil {
+			return nil, err
+		}
+		c.auditor = a
+	}
+
 	return c, nil
 }
 
@@ -60,7 +76,11 @@ func (c *Commander) Type() interface{} {
 // Start implements common.Runnable.
 func (c *Commander) Start() error {
 	c.Lock()
-	c.server = grpc.NewServer()
+	var opts []grpc.ServerOption
+	if c.auth != nil || c.auditor != nil {
+		opts = append(opts, grpc.UnaryInterceptor(c.unaryInterceptor), grpc.StreamInterceptor(c.streamInterceptor))
+	}
+	c.server = grpc.NewServer(opts...)
 	for _, service := range c.services {
 		service.Register(c.server)
 	}
@@ -87,6 +107,36 @@ func (c *Commander) Start() error {
 	})
 }
 
+// intercept authorizes and audits an API call.
+func (c *Commander) intercept(ctx context.Context, method string, req interface{}, call func() error) error {
+	var tokenName string
+	var err error
+	if c.auth != nil {
+		tokenName, err = c.auth.authorize(ctx, method, req)
+	}
+	if err == nil {
+		err = call()
+	}
+	if c.auditor != nil {
+		c.auditor.record(ctx, tokenName, method, req, err)
+	}
+	return err
+}
+
+func (c *Commander) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	err = c.intercept(ctx, info.FullMethod, req, func() error {
+		resp, err = handler(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (c *Commander) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return c.intercept(ss.Context(), info.FullMethod, nil, func() error {
+		return handler(srv, ss)
+	})
+}
+
 // Close implements common.Closable.
 func (c *Commander) Close() error {
 	c.Lock()
@@ -97,6 +147,10 @@ func (c *Commander) Close() error {
 		c.server = nil
 	}
 
+	if c.auditor != nil {
+		return c.auditor.Close()
+	}
+
 	return nil
 }
 
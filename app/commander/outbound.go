@@ -7,6 +7,7 @@ import (
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/net/cnc"
+	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/common/signal/done"
 	"github.com/xtls/xray-core/transport"
 )
@@ -80,7 +81,14 @@ func (co *Outbound) Dispatch(ctx context.Context, link *transport.Link) {
 	}
 
 	closeSignal := done.New()
-	c := cnc.NewConnection(cnc.ConnectionInputMulti(link.Writer), cnc.ConnectionOutputMulti(link.Reader), cnc.ConnectionOnClose(closeSignal))
+	opts := []cnc.ConnectionOption{cnc.ConnectionInputMulti(link.Writer), cnc.ConnectionOutputMulti(link.Reader), cnc.ConnectionOnClose(closeSignal)}
+	if inbound := session.InboundFromContext(ctx); inbound != nil && inbound.Source.Address != nil && inbound.Source.Address.Family().IsIP() {
+		opts = append(opts, cnc.ConnectionRemoteAddr(&net.TCPAddr{
+			IP:   inbound.Source.Address.IP(),
+			Port: int(inbound.Source.Port),
+		}))
+	}
+	c := cnc.NewConnection(opts...)
 	co.listener.add(c)
 	co.access.RUnlock()
 	<-closeSignal.Wait()
@@ -11,6 +11,7 @@ import (
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/log"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
@@ -243,6 +244,35 @@ func (d *DefaultDispatcher) getLink(ctx context.Context, network net.Network, sn
 				}
 			}
 		}
+		if p.Stats.UserLogin {
+			if r, ok := d.stats.(stats.LoginRecorder); ok {
+				r.RecordLogin(user.Email, stats.Login{
+					Time:       time.Now(),
+					Source:     sessionInbound.Source.Address,
+					InboundTag: sessionInbound.Tag,
+				})
+			}
+		}
+	}
+
+	if sessionInbound != nil && sessionInbound.Timer != nil {
+		var level uint32
+		if user != nil {
+			level = user.Level
+		}
+		t := d.policy.ForLevel(level).Timeouts
+		if t.UplinkIdle > 0 || t.DownlinkIdle > 0 || t.MaxLifetime > 0 {
+			timer := sessionInbound.Timer
+			timer.SetLimits(t.UplinkIdle, t.DownlinkIdle, t.MaxLifetime)
+			inboundLink.Writer = &ActivityWriter{
+				Update: timer.UpdateUplink,
+				Writer: inboundLink.Writer,
+			}
+			outboundLink.Writer = &ActivityWriter{
+				Update: timer.UpdateDownlink,
+				Writer: outboundLink.Writer,
+			}
+		}
 	}
 
 	return inboundLink, outboundLink
@@ -253,8 +283,9 @@ func (d *DefaultDispatcher) shouldOverride(ctx context.Context, result SniffResu
 	if domain == "" {
 		return false
 	}
+	domain = strings.ToLower(net.NormalizeDomain(domain))
 	for _, d := range request.ExcludeForDomain {
-		if strings.ToLower(domain) == d {
+		if domain == d {
 			return false
 		}
 	}
@@ -296,6 +327,11 @@ func (d *DefaultDispatcher) Dispatch(ctx context.Context, destination net.Destin
 		ctx = session.ContextWithContent(ctx, content)
 	}
 
+	if err := checkRestriction(content, destination); err != nil {
+		recordRejected(ctx, err)
+		return nil, err
+	}
+
 	sniffingRequest := content.SniffingRequest
 	inbound, outbound := d.getLink(ctx, destination.Network, sniffingRequest)
 	if !sniffingRequest.Enabled {
@@ -309,10 +345,21 @@ func (d *DefaultDispatcher) Dispatch(ctx context.Context, destination net.Destin
 			result, err := sniffer(ctx, cReader, sniffingRequest.MetadataOnly, destination.Network)
 			if err == nil {
 				content.Protocol = result.Protocol()
+				if r, ok := result.(SnifferResultAttributes); ok {
+					for name, value := range r.Attributes() {
+						content.SetAttribute(name, value)
+					}
+				}
+				if err := checkRestriction(content, destination); err != nil {
+					recordRejected(ctx, err)
+					common.Close(outbound.Writer)
+					common.Interrupt(outbound.Reader)
+					return
+				}
 			}
 			if err == nil && d.shouldOverride(ctx, result, sniffingRequest, destination) {
-				domain := result.Domain()
-				newError("sniffed domain: ", domain).WriteToLog(session.ExportIDToError(ctx))
+				domain := net.NormalizeDomain(result.Domain())
+				newError("sniffed domain: ", net.DisplayDomain(domain)).WriteToLog(session.ExportIDToError(ctx))
 				destination.Address = net.ParseAddress(domain)
 				if sniffingRequest.RouteOnly && result.Protocol() != "fakedns" {
 					ob.RouteTarget = destination
@@ -340,6 +387,10 @@ func (d *DefaultDispatcher) DispatchLink(ctx context.Context, destination net.De
 		content = new(session.Content)
 		ctx = session.ContextWithContent(ctx, content)
 	}
+	if err := checkRestriction(content, destination); err != nil {
+		recordRejected(ctx, err)
+		return err
+	}
 	sniffingRequest := content.SniffingRequest
 	if !sniffingRequest.Enabled {
 		go d.routedDispatch(ctx, outbound, destination)
@@ -352,10 +403,21 @@ func (d *DefaultDispatcher) DispatchLink(ctx context.Context, destination net.De
 			result, err := sniffer(ctx, cReader, sniffingRequest.MetadataOnly, destination.Network)
 			if err == nil {
 				content.Protocol = result.Protocol()
+				if r, ok := result.(SnifferResultAttributes); ok {
+					for name, value := range r.Attributes() {
+						content.SetAttribute(name, value)
+					}
+				}
+				if err := checkRestriction(content, destination); err != nil {
+					recordRejected(ctx, err)
+					common.Close(outbound.Writer)
+					common.Interrupt(outbound.Reader)
+					return
+				}
 			}
 			if err == nil && d.shouldOverride(ctx, result, sniffingRequest, destination) {
-				domain := result.Domain()
-				newError("sniffed domain: ", domain).WriteToLog(session.ExportIDToError(ctx))
+				domain := net.NormalizeDomain(result.Domain())
+				newError("sniffed domain: ", net.DisplayDomain(domain)).WriteToLog(session.ExportIDToError(ctx))
 				destination.Address = net.ParseAddress(domain)
 				if sniffingRequest.RouteOnly && result.Protocol() != "fakedns" {
 					ob.RouteTarget = destination
@@ -370,6 +432,37 @@ func (d *DefaultDispatcher) DispatchLink(ctx context.Context, destination net.De
 	return nil
 }
 
+// checkRestriction returns an error if the inbound refuses the destination or the sniffed protocol.
+func checkRestriction(content *session.Content, destination net.Destination) error {
+	r := content.Restriction
+	if r == nil {
+		return nil
+	}
+	if r.BlockedPorts.Contains(destination.Port) {
+		return newError("destination port ", destination.Port, " is rejected by inbound policy")
+	}
+	if destination.Address.Family().IsIP() && r.BlocksIP(destination.Address.IP()) {
+		return newError("private destination ", destination.Address, " is rejected by inbound policy")
+	}
+	if content.Protocol != "" {
+		for _, p := range r.BlockedProtocols {
+			if strings.HasPrefix(content.Protocol, p) {
+				return newError("protocol ", content.Protocol, " is rejected by inbound policy")
+			}
+		}
+	}
+	return nil
+}
+
+func recordRejected(ctx context.Context, err error) {
+	newError("connection to ", session.OutboundFromContext(ctx).Target, " rejected").Base(err).AtInfo().WriteToLog(session.ExportIDToError(ctx))
+	if accessMessage := log.AccessMessageFromContext(ctx); accessMessage != nil {
+		accessMessage.Status = log.AccessRejected
+		accessMessage.Reason = err
+		log.Record(accessMessage)
+	}
+}
+
 func sniffer(ctx context.Context, cReader *cachedReader, metadataOnly bool, network net.Network) (SniffResult, error) {
 	payload := buf.New()
 	defer payload.Release()
@@ -431,7 +524,7 @@ func (d *DefaultDispatcher) routedDispatch(ctx context.Context, link *transport.
 		}
 	}
 
-	var handler outbound.Handler
+	var handler, standby outbound.Handler
 
 	routingLink := routing_session.AsRoutingContext(ctx)
 	inTag := routingLink.GetInboundTag()
@@ -458,6 +551,16 @@ func (d *DefaultDispatcher) routedDispatch(ctx context.Context, link *transport.
 			} else {
 				newError("non existing outTag: ", outTag).AtWarning().WriteToLog(session.ExportIDToError(ctx))
 			}
+			if sr, ok := route.(routing.SockoptRoute); ok && sr.GetSockoptOverride() != nil {
+				ctx = session.ContextWithSockoptOverride(ctx, sr.GetSockoptOverride())
+			}
+			if sr, ok := route.(routing.StandbyRoute); ok && handler != nil && destination.Network == net.Network_TCP {
+				if standbyTag := sr.GetStandbyTag(); standbyTag != "" && standbyTag != outTag {
+					if standby = d.ohm.GetHandler(standbyTag); standby == nil {
+						newError("non existing standbyTag: ", standbyTag).AtWarning().WriteToLog(session.ExportIDToError(ctx))
+					}
+				}
+			}
 		} else {
 			newError("default route for ", destination).WriteToLog(session.ExportIDToError(ctx))
 		}
@@ -474,7 +577,18 @@ func (d *DefaultDispatcher) routedDispatch(ctx context.Context, link *transport.
 		return
 	}
 
+	if !d.checkDestinationACL(ctx, routingLink, destination, handler.Tag()) {
+		common.Close(link.Writer)
+		common.Interrupt(link.Reader)
+		return
+	}
+
 	if accessMessage := log.AccessMessageFromContext(ctx); accessMessage != nil {
+		if to, ok := accessMessage.To.(net.Destination); ok && to.Address != nil && to.Address.Family().IsDomain() {
+			// internationalized domains are logged in Unicode
+			to.Address = net.DomainAddress(net.DisplayDomain(to.Address.Domain()))
+			accessMessage.To = to
+		}
 		if tag := handler.Tag(); tag != "" {
 			if inTag == "" {
 				accessMessage.Detour = tag
@@ -489,5 +603,75 @@ func (d *DefaultDispatcher) routedDispatch(ctx context.Context, link *transport.
 		log.Record(accessMessage)
 	}
 
+	d.countProtocol(ctx, link)
+
+	if standby != nil {
+		newError("racing [", handler.Tag(), "] against standby [", standby.Tag(), "] for [", destination, "]").WriteToLog(session.ExportIDToError(ctx))
+		raceDispatch(ctx, link, [2]outbound.Handler{handler, standby})
+		return
+	}
 	handler.Dispatch(ctx, link)
 }
+
+// countProtocol counts the traffic of the link in inbound>>>tag>>>protocol>>>protocol>>>traffic>>>uplink and
+// downlink, by the protocol sniffed from the connection, when the system policy enables it.
+func (d *DefaultDispatcher) countProtocol(ctx context.Context, link *transport.Link) {
+	if !d.policy.ForSystem().Stats.InboundProtocol {
+		return
+	}
+	inbound := session.InboundFromContext(ctx)
+	if inbound == nil || len(inbound.Tag) == 0 {
+		return
+	}
+	protocol := "unknown"
+	if content := session.ContentFromContext(ctx); content != nil && len(content.Protocol) > 0 {
+		protocol = content.Protocol
+	}
+	prefix := "inbound>>>" + inbound.Tag + ">>>protocol>>>" + protocol + ">>>traffic>>>"
+	if c, _ := stats.GetOrRegisterCounter(d.stats, prefix+"uplink"); c != nil {
+		link.Reader = &SizeStatReader{
+			Counter: c,
+			Reader:  link.Reader,
+		}
+	}
+	if c, _ := stats.GetOrRegisterCounter(d.stats, prefix+"downlink"); c != nil {
+		link.Writer = &SizeStatWriter{
+			Counter: c,
+			Writer:  link.Writer,
+		}
+	}
+}
+
+// checkDestinationACL enforces the destination ACL in the policy of the inbound user. Rejections count as
+// policy errors of the outbound the connection was routed to.
+func (d *DefaultDispatcher) checkDestinationACL(ctx context.Context, routingLink routing.Context, destination net.Destination, tag string) bool {
+	inbound := session.InboundFromContext(ctx)
+	if inbound == nil || inbound.User == nil {
+		return true
+	}
+	user := inbound.User
+	acl := d.policy.ForLevel(user.Level).ACL
+	if acl == nil || acl.Allowed(routingLink) {
+		return true
+	}
+
+	newError("destination [", destination, "] is not allowed for user [", user.Email, "]").AtWarning().WriteToLog(session.ExportIDToError(ctx))
+	if len(user.Email) > 0 {
+		name := "user>>>" + user.Email + ">>>acl>>>denied"
+		if c, _ := stats.GetOrRegisterCounter(d.stats, name); c != nil {
+			c.Add(1)
+		}
+	}
+	if system := d.policy.ForSystem().Stats; len(tag) > 0 && (system.OutboundUplink || system.OutboundDownlink) {
+		name := "outbound>>>" + tag + ">>>error>>>" + string(errors.ClassPolicy)
+		if c, _ := stats.GetOrRegisterCounter(d.stats, name); c != nil {
+			c.Add(1)
+		}
+	}
+	if accessMessage := log.AccessMessageFromContext(ctx); accessMessage != nil {
+		accessMessage.Status = log.AccessRejected
+		accessMessage.Reason = "destination not allowed"
+		log.Record(accessMessage)
+	}
+	return false
+}
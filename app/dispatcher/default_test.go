@@ -0,0 +1,95 @@
+package dispatcher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/xtls/xray-core/app/policy"
+	"github.com/xtls/xray-core/app/stats"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/transport/pipe"
+)
+
+func TestGetLinkPlainPipes(t *testing.T) {
+	pm, err := policy.New(context.Background(), &policy.Config{
+		Level: map[uint32]*policy.Policy{
+			0: {Stats: &policy.Policy_Stats{UserUplink: true, UserDownlink: true}},
+		},
+	})
+	common.Must(err)
+	sm, err := stats.NewManager(context.Background(), &stats.Config{})
+	common.Must(err)
+	d := &DefaultDispatcher{policy: pm, stats: sm}
+
+	for _, test := range []struct {
+		name    string
+		inbound *session.Inbound
+	}{
+		{"no inbound", nil},
+		{"no user", &session.Inbound{Tag: "in"}},
+		{"user without email", &session.Inbound{Tag: "in", User: &protocol.MemoryUser{}}},
+	} {
+		ctx := context.Background()
+		if test.inbound != nil {
+			ctx = session.ContextWithInbound(ctx, test.inbound)
+		}
+		inbound, outbound := d.getLink(ctx, net.Network_TCP, session.SniffingRequest{})
+		if _, ok := inbound.Writer.(*pipe.Writer); !ok {
+			t.Errorf("%s: expected the uplink pipe as it is, got %T", test.name, inbound.Writer)
+		}
+		if _, ok := outbound.Writer.(*pipe.Writer); !ok {
+			t.Errorf("%s: expected the downlink pipe as it is, got %T", test.name, outbound.Writer)
+		}
+	}
+
+	ctx := session.ContextWithInbound(context.Background(), &session.Inbound{
+		Tag:  "in",
+		User: &protocol.MemoryUser{Email: "love@example.com"},
+	})
+	inbound, _ := d.getLink(ctx, net.Network_TCP, session.SniffingRequest{})
+	if _, ok := inbound.Writer.(*SizeStatWriter); !ok {
+		t.Fatalf("expected the uplink of a user to be counted, got %T", inbound.Writer)
+	}
+	common.Must(inbound.Writer.WriteMultiBuffer(buf.MergeBytes(nil, []byte("uplink"))))
+	if c := sm.GetCounter("user>>>love@example.com>>>traffic>>>uplink"); c == nil || c.Value() != 6 {
+		t.Error("unexpected uplink counter: ", c)
+	}
+}
+
+func BenchmarkDispatch(b *testing.B) {
+	pm, err := policy.New(context.Background(), &policy.Config{
+		Level: map[uint32]*policy.Policy{
+			0: {Stats: &policy.Policy_Stats{}},
+		},
+	})
+	common.Must(err)
+	sm, err := stats.NewManager(context.Background(), &stats.Config{})
+	common.Must(err)
+	d := &DefaultDispatcher{policy: pm, stats: sm}
+
+	for _, bench := range []struct {
+		name    string
+		inbound *session.Inbound
+	}{
+		{"NoInbound", nil},
+		{"NoUser", &session.Inbound{Tag: "in"}},
+		{"UserWithoutStats", &session.Inbound{Tag: "in", User: &protocol.MemoryUser{Email: "love@example.com"}}},
+	} {
+		ctx := context.Background()
+		if bench.inbound != nil {
+			ctx = session.ContextWithInbound(ctx, bench.inbound)
+		}
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				inbound, outbound := d.getLink(ctx, net.Network_TCP, session.SniffingRequest{})
+				common.Close(inbound.Writer)
+				common.Close(outbound.Writer)
+			}
+		})
+	}
+}
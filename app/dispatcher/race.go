@@ -0,0 +1,142 @@
+package dispatcher
+
+import (
+	"context"
+	"sync"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/features/outbound"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/pipe"
+)
+
+// race sets up a connection through a primary and a standby outbound at the same time, and commits to the one
+// that connects first. Only the connection setup is raced: no data is sent until an outbound is chosen, so the
+// destination never sees a request twice.
+type race struct {
+	sync.Mutex
+	ctx       context.Context
+	link      *transport.Link
+	uplinks   [2]*pipe.Writer
+	downlinks [2]*pipe.Reader
+	cancels   [2]context.CancelFunc
+	// index of the outbound that connected first, or -1
+	winner int
+	ended  [2]bool
+	// closed once there is a winner, or both outbounds ended without connecting
+	decided chan struct{}
+}
+
+func raceDispatch(ctx context.Context, link *transport.Link, handlers [2]outbound.Handler) {
+	r := &race{
+		ctx:     ctx,
+		link:    link,
+		winner:  -1,
+		decided: make(chan struct{}),
+	}
+	opts := pipe.OptionsFromContext(ctx)
+	for i, handler := range handlers {
+		i, handler := i, handler
+		upReader, upWriter := pipe.New(opts...)
+		downReader, downWriter := pipe.New(opts...)
+		r.uplinks[i] = upWriter
+		r.downlinks[i] = downReader
+
+		handlerCtx, cancel := context.WithCancel(ctx)
+		r.cancels[i] = cancel
+		if ob := session.OutboundFromContext(ctx); ob != nil {
+			// outbounds may update the session, so each gets its own copy
+			obCopy := *ob
+			handlerCtx = session.ContextWithOutbound(handlerCtx, &obCopy)
+		}
+		handlerCtx = session.ContextWithConnected(handlerCtx, func() {
+			r.connected(i, handler.Tag())
+		})
+		go func() {
+			handler.Dispatch(handlerCtx, &transport.Link{
+				Reader: upReader,
+				Writer: downWriter,
+			})
+			r.end(i)
+		}()
+	}
+	go r.downlink()
+	r.uplink()
+}
+
+// connected makes the outbound the winner, unless there is one already.
+func (r *race) connected(i int, tag string) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.winner >= 0 || r.ended[i] {
+		return
+	}
+	r.winner = i
+	r.drop(1 - i)
+	close(r.decided)
+	newError("outbound [", tag, "] connected first").WriteToLog(session.ExportIDToError(r.ctx))
+}
+
+// end records that the outbound is done with the connection.
+func (r *race) end(i int) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.winner == i {
+		return
+	}
+	r.drop(i)
+	if r.winner < 0 && r.ended[1-i] {
+		// both outbounds ended without connecting
+		close(r.decided)
+	}
+}
+
+// drop stops the given outbound. Caller must hold the lock.
+func (r *race) drop(i int) {
+	if r.ended[i] {
+		return
+	}
+	r.ended[i] = true
+	r.cancels[i]()
+	common.Interrupt(r.uplinks[i])
+	common.Interrupt(r.downlinks[i])
+}
+
+// chosen waits for the outbound to commit to, and returns it, or -1 if neither connected.
+func (r *race) chosen() int {
+	<-r.decided
+	r.Lock()
+	defer r.Unlock()
+	return r.winner
+}
+
+func (r *race) uplink() {
+	i := r.chosen()
+	if i < 0 {
+		common.Interrupt(r.link.Reader)
+		return
+	}
+	if err := buf.Copy(r.link.Reader, r.uplinks[i]); err != nil {
+		common.Interrupt(r.uplinks[i])
+		return
+	}
+	common.Close(r.uplinks[i])
+}
+
+func (r *race) downlink() {
+	i := r.chosen()
+	if i < 0 {
+		common.Interrupt(r.link.Writer)
+		return
+	}
+	if err := buf.Copy(r.downlinks[i], r.link.Writer); err != nil {
+		common.Interrupt(r.link.Writer)
+		common.Interrupt(r.downlinks[i])
+		return
+	}
+	common.Close(r.link.Writer)
+}
@@ -0,0 +1,131 @@
+package dispatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/features/outbound"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/pipe"
+)
+
+// raceHandler connects after the given delay, or never if it is negative, and then echoes the uplink.
+type raceHandler struct {
+	tag      string
+	delay    time.Duration
+	received chan []byte
+}
+
+func (h *raceHandler) Tag() string {
+	return h.tag
+}
+
+func (h *raceHandler) Start() error {
+	return nil
+}
+
+func (h *raceHandler) Close() error {
+	return nil
+}
+
+func (h *raceHandler) Dispatch(ctx context.Context, link *transport.Link) {
+	if h.delay < 0 {
+		<-ctx.Done()
+		common.Interrupt(link.Reader)
+		common.Interrupt(link.Writer)
+		return
+	}
+	select {
+	case <-time.After(h.delay):
+	case <-ctx.Done():
+		common.Interrupt(link.Reader)
+		common.Interrupt(link.Writer)
+		return
+	}
+	session.NotifyConnected(ctx)
+	mb, err := link.Reader.ReadMultiBuffer()
+	if err != nil {
+		common.Interrupt(link.Writer)
+		return
+	}
+	h.received <- []byte(mb.String())
+	link.Writer.WriteMultiBuffer(mb)
+	common.Close(link.Writer)
+}
+
+func testRace(t *testing.T, primary, standby *raceHandler) string {
+	upReader, upWriter := pipe.New()
+	downReader, downWriter := pipe.New()
+	common.Must(upWriter.WriteMultiBuffer(buf.MergeBytes(nil, []byte("request"))))
+	common.Must(upWriter.Close())
+
+	done := make(chan struct{})
+	go func() {
+		raceDispatch(context.Background(), &transport.Link{Reader: upReader, Writer: downWriter}, [2]outbound.Handler{primary, standby})
+		close(done)
+	}()
+
+	mb, err := downReader.ReadMultiBuffer()
+	if err != nil {
+		t.Fatal("no response: ", err)
+	}
+	<-done
+	return mb.String()
+}
+
+func TestRaceSendsOnlyToWinner(t *testing.T) {
+	primary := &raceHandler{tag: "primary", delay: 200 * time.Millisecond, received: make(chan []byte, 1)}
+	standby := &raceHandler{tag: "standby", received: make(chan []byte, 1)}
+
+	if r := testRace(t, primary, standby); r != "request" {
+		t.Error("unexpected response: ", r)
+	}
+	if r := <-standby.received; string(r) != "request" {
+		t.Error("unexpected request: ", string(r))
+	}
+	time.Sleep(300 * time.Millisecond)
+	select {
+	case r := <-primary.received:
+		t.Error("losing outbound received ", string(r))
+	default:
+	}
+}
+
+func TestRaceFallsBackWhenPrimaryNeverConnects(t *testing.T) {
+	primary := &raceHandler{tag: "primary", delay: -1, received: make(chan []byte, 1)}
+	standby := &raceHandler{tag: "standby", delay: 50 * time.Millisecond, received: make(chan []byte, 1)}
+
+	if r := testRace(t, primary, standby); r != "request" {
+		t.Error("unexpected response: ", r)
+	}
+	if len(primary.received) != 0 {
+		t.Error("primary received data")
+	}
+}
+
+func TestRaceNeitherConnects(t *testing.T) {
+	upReader, _ := pipe.New()
+	downReader, downWriter := pipe.New()
+	handlers := [2]outbound.Handler{
+		&raceHandlerFail{},
+		&raceHandlerFail{},
+	}
+	go raceDispatch(context.Background(), &transport.Link{Reader: upReader, Writer: downWriter}, handlers)
+	if _, err := downReader.ReadMultiBuffer(); err == nil {
+		t.Error("expected the downlink to be interrupted")
+	}
+}
+
+// raceHandlerFail fails before connecting.
+type raceHandlerFail struct {
+	raceHandler
+}
+
+func (h *raceHandlerFail) Dispatch(ctx context.Context, link *transport.Link) {
+	common.Interrupt(link.Reader)
+	common.Interrupt(link.Writer)
+}
@@ -2,6 +2,7 @@ package dispatcher
 
 import (
 	"context"
+	"sync"
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/net"
@@ -18,6 +19,39 @@ type SniffResult interface {
 
 type protocolSniffer func(context.Context, []byte) (SniffResult, error)
 
+// SnifferFunc tells the protocol of the first payload of a connection. It returns common.ErrNoClue if
+// the payload is too short to decide.
+type SnifferFunc func(ctx context.Context, payload []byte) (SniffResult, error)
+
+var (
+	registeredSniffersAccess sync.RWMutex
+	registeredSniffers       []protocolSnifferWithMetadata
+	registeredSnifferNames   = make(map[string]bool)
+)
+
+// RegisterSniffer adds a sniffer for connections of the given network, which runs after the built-in ones.
+// The name, in lower case, is the protocol it detects, and may be used in destOverride. Results that implement
+// SnifferResultAttributes also contribute attributes to routing. It is meant to be called in init().
+func RegisterSniffer(name string, network net.Network, sniffer SnifferFunc) error {
+	registeredSniffersAccess.Lock()
+	defer registeredSniffersAccess.Unlock()
+
+	if registeredSnifferNames[name] {
+		return newError("sniffer ", name, " is already registered")
+	}
+	registeredSnifferNames[name] = true
+	registeredSniffers = append(registeredSniffers, protocolSnifferWithMetadata{protocolSniffer(sniffer), false, network})
+	return nil
+}
+
+// IsSnifferRegistered returns whether a sniffer with the given name is registered.
+func IsSnifferRegistered(name string) bool {
+	registeredSniffersAccess.RLock()
+	defer registeredSniffersAccess.RUnlock()
+
+	return registeredSnifferNames[name]
+}
+
 type protocolSnifferWithMetadata struct {
 	protocolSniffer protocolSniffer
 	// A Metadata sniffer will be invoked on connection establishment only, with nil body,
@@ -41,6 +75,9 @@ func NewSniffer(ctx context.Context) *Sniffer {
 			{func(c context.Context, b []byte) (SniffResult, error) { return bittorrent.SniffUTP(b) }, false, net.Network_UDP},
 		},
 	}
+	registeredSniffersAccess.RLock()
+	ret.sniffer = append(ret.sniffer, registeredSniffers...)
+	registeredSniffersAccess.RUnlock()
 	if sniffer, err := newFakeDNSSniffer(ctx); err == nil {
 		others := ret.sniffer
 		ret.sniffer = append(ret.sniffer, sniffer)
@@ -132,6 +169,12 @@ type SnifferResultComposite interface {
 	ProtocolForDomainResult() string
 }
 
+// SnifferResultAttributes is implemented by results that carry attributes of the connection, such as
+// the software in a banner. They are added to the attributes of the session for routing.
+type SnifferResultAttributes interface {
+	Attributes() map[string]string
+}
+
 type SnifferIsProtoSubsetOf interface {
 	IsProtoSubsetOf(protocolName string) bool
 }
@@ -0,0 +1,73 @@
+package dispatcher_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/xtls/xray-core/app/dispatcher"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/core"
+)
+
+type sshResult struct {
+	software string
+}
+
+func (*sshResult) Protocol() string {
+	return "ssh"
+}
+
+func (*sshResult) Domain() string {
+	return ""
+}
+
+func (r *sshResult) Attributes() map[string]string {
+	return map[string]string{"ssh-software": r.software}
+}
+
+func sniffSSH(ctx context.Context, b []byte) (SniffResult, error) {
+	if len(b) < 4 {
+		return nil, common.ErrNoClue
+	}
+	if !bytes.HasPrefix(b, []byte("SSH-")) {
+		return nil, errors.New("not ssh")
+	}
+	line := b
+	if i := bytes.IndexByte(b, '\r'); i >= 0 {
+		line = b[:i]
+	}
+	parts := bytes.SplitN(line, []byte("-"), 3)
+	if len(parts) < 3 {
+		return nil, common.ErrNoClue
+	}
+	return &sshResult{software: string(parts[2])}, nil
+}
+
+func TestRegisterSniffer(t *testing.T) {
+	common.Must(RegisterSniffer("ssh", net.Network_TCP, sniffSSH))
+	if err := RegisterSniffer("ssh", net.Network_TCP, sniffSSH); err == nil {
+		t.Error("registered ssh twice")
+	}
+	if !IsSnifferRegistered("ssh") {
+		t.Error("ssh is not registered")
+	}
+
+	v, err := core.New(&core.Config{})
+	common.Must(err)
+	ctx := context.WithValue(context.Background(), core.XrayKey(1), v)
+	result, err := NewSniffer(ctx).Sniff(ctx, []byte("SSH-2.0-OpenSSH_9.0\r\n"), net.Network_TCP)
+	common.Must(err)
+	if result.Protocol() != "ssh" {
+		t.Error("protocol: ", result.Protocol())
+	}
+	if v := result.(SnifferResultAttributes).Attributes()["ssh-software"]; v != "OpenSSH_9.0" {
+		t.Error("software: ", v)
+	}
+
+	if _, err := NewSniffer(ctx).Sniff(ctx, []byte("SSH-2.0-OpenSSH_9.0\r\n"), net.Network_UDP); err == nil {
+		t.Error("sniffed ssh over udp")
+	}
+}
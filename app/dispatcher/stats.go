@@ -1,6 +1,8 @@
 package dispatcher
 
 import (
+	"time"
+
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/features/stats"
@@ -23,3 +25,48 @@ func (w *SizeStatWriter) Close() error {
 func (w *SizeStatWriter) Interrupt() {
 	common.Interrupt(w.Writer)
 }
+
+// SizeStatReader counts the size of the data read. It reads with timeouts if the underlying reader does.
+type SizeStatReader struct {
+	Counter stats.Counter
+	Reader  buf.Reader
+}
+
+func (r *SizeStatReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	mb, err := r.Reader.ReadMultiBuffer()
+	r.Counter.Add(int64(mb.Len()))
+	return mb, err
+}
+
+func (r *SizeStatReader) ReadMultiBufferTimeout(timeout time.Duration) (buf.MultiBuffer, error) {
+	tr, ok := r.Reader.(buf.TimeoutReader)
+	if !ok {
+		return r.ReadMultiBuffer()
+	}
+	mb, err := tr.ReadMultiBufferTimeout(timeout)
+	r.Counter.Add(int64(mb.Len()))
+	return mb, err
+}
+
+func (r *SizeStatReader) Interrupt() {
+	common.Interrupt(r.Reader)
+}
+
+// ActivityWriter reports the traffic of one direction to an activity callback.
+type ActivityWriter struct {
+	Update func()
+	Writer buf.Writer
+}
+
+func (w *ActivityWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	w.Update()
+	return w.Writer.WriteMultiBuffer(mb)
+}
+
+func (w *ActivityWriter) Close() error {
+	return common.Close(w.Writer)
+}
+
+func (w *ActivityWriter) Interrupt() {
+	common.Interrupt(w.Writer)
+}
@@ -0,0 +1,36 @@
+package dns
+
+import (
+	"net/http"
+	"time"
+)
+
+// maxClockSkew is the skew of the local clock beyond which a warning is logged, as time-based authentication
+// starts to fail. Shadowsocks 2022 tolerates the least skew of the protocols, 30 seconds, which unlike the
+// tolerance of VMess is fixed by the sing-shadowsocks implementation and can't be configured.
+const maxClockSkew = 30 * time.Second
+
+// clockSkew returns how far the local clock is ahead of the time in the Date header of an HTTP response.
+func clockSkew(date string, now time.Time) (time.Duration, bool) {
+	t, err := http.ParseTime(date)
+	if err != nil {
+		return 0, false
+	}
+	return now.Sub(t), true
+}
+
+// checkClock warns if the local clock is skewed from the Date header of a response of the name server, without
+// relying on NTP. It returns whether it warned.
+func checkClock(server string, date string) bool {
+	skew, ok := clockSkew(date, time.Now())
+	if !ok {
+		return false
+	}
+	// the header has a resolution of seconds
+	if skew.Abs() > maxClockSkew+time.Second {
+		newError("local clock is ", skew.Round(time.Second), " away from the time of ", server, ", VMess and Shadowsocks 2022 may fail to authenticate; check the system time").AtWarning().WriteToLog()
+		return true
+	}
+	newError("local clock is ", skew.Round(time.Second), " away from the time of ", server).AtDebug().WriteToLog()
+	return false
+}
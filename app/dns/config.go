@@ -38,6 +38,9 @@ func toStrMatcher(t DomainMatchingType, domain string) (strmatcher.Matcher, erro
 	if !f {
 		return nil, newError("unknown mapping type", t).AtWarning()
 	}
+	if t != DomainMatchingType_Regex {
+		domain = net.NormalizeDomain(domain)
+	}
 	matcher, err := strMType.New(domain)
 	if err != nil {
 		return nil, newError("failed to create str matcher").Base(err)
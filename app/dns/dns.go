@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/xtls/xray-core/app/router"
 	"github.com/xtls/xray-core/common"
@@ -32,6 +33,8 @@ type DNS struct {
 	ctx                    context.Context
 	domainMatcher          strmatcher.IndexMatcher
 	matcherInfos           []*DomainMatcherInfo
+	specialUseDomains      bool
+	lanClient              *Client
 }
 
 // DomainMatcherInfo contains information attached to index returned by Server.domainMatcher
@@ -87,7 +90,7 @@ func New(ctx context.Context, config *Config) (*DNS, error) {
 	clients := []*Client{}
 	domainRuleCount := 0
 	for _, ns := range config.NameServer {
-		domainRuleCount += len(ns.PrioritizedDomain)
+		domainRuleCount += len(ns.PrioritizedDomain) + len(ns.Geosite)
 	}
 
 	// MatcherInfos is ensured to cover the maximum index domainMatcher could return, where matcher's index starts from 1
@@ -132,6 +135,21 @@ func New(ctx context.Context, config *Config) (*DNS, error) {
 		clients = append(clients, NewLocalDNSClient())
 	}
 
+	var lanClient *Client
+	if config.LanServer != nil {
+		if lanClient, err = NewSimpleClient(ctx, config.LanServer, nil); err != nil {
+			return nil, newError("failed to create LAN client").Base(err)
+		}
+	}
+
+	if config.MaxNegativeTtl > 0 {
+		for _, client := range clients {
+			if c, ok := client.server.(interface{ SetMaxNegativeTTL(time.Duration) }); ok {
+				c.SetMaxNegativeTTL(time.Duration(config.MaxNegativeTtl) * time.Second)
+			}
+		}
+	}
+
 	return &DNS{
 		tag:                    tag,
 		hosts:                  hosts,
@@ -143,6 +161,8 @@ func New(ctx context.Context, config *Config) (*DNS, error) {
 		disableCache:           config.DisableCache,
 		disableFallback:        config.DisableFallback,
 		disableFallbackIfMatch: config.DisableFallbackIfMatch,
+		specialUseDomains:      config.SpecialUseDomains,
+		lanClient:              lanClient,
 	}, nil
 }
 
@@ -161,6 +181,15 @@ func (s *DNS) Close() error {
 	return nil
 }
 
+// ReleaseMemory drops the cached records of all servers. The watchdog calls it under memory pressure.
+func (s *DNS) ReleaseMemory() {
+	for _, client := range s.clients {
+		if c, ok := client.server.(interface{ ClearCache() }); ok {
+			c.ClearCache()
+		}
+	}
+}
+
 // IsOwnLink implements proxy.dns.ownLinkVerifier
 func (s *DNS) IsOwnLink(ctx context.Context) bool {
 	inbound := session.InboundFromContext(ctx)
@@ -184,6 +213,7 @@ func (s *DNS) LookupIP(domain string, option dns.IPOption) ([]net.IP, error) {
 	if strings.HasSuffix(domain, ".") {
 		domain = domain[:len(domain)-1]
 	}
+	domain = net.NormalizeDomain(domain)
 
 	// Static host lookup
 	switch addrs := s.hosts.Lookup(domain, option); {
@@ -202,6 +232,11 @@ func (s *DNS) LookupIP(domain string, option dns.IPOption) ([]net.IP, error) {
 	// Name servers lookup
 	errs := []error{}
 	ctx := session.ContextWithInbound(s.ctx, &session.Inbound{Tag: s.tag})
+	if s.specialUseDomains {
+		if use := specialUseOf(domain); use != specialUseNone {
+			return s.lookupSpecialUse(ctx, domain, use, option)
+		}
+	}
 	for _, client := range s.sortClients(domain) {
 		if !option.FakeEnable && strings.EqualFold(client.Name(), "FakeDNS") {
 			newError("skip DNS resolution for domain ", domain, " at server ", client.Name()).AtDebug().WriteToLog()
@@ -225,7 +260,7 @@ func (s *DNS) LookupIP(domain string, option dns.IPOption) ([]net.IP, error) {
 
 // LookupHosts implements dns.HostsLookup.
 func (s *DNS) LookupHosts(domain string) *net.Address {
-	domain = strings.TrimSuffix(domain, ".")
+	domain = net.NormalizeDomain(strings.TrimSuffix(domain, "."))
 	if domain == "" {
 		return nil
 	}
@@ -255,6 +290,42 @@ func (s *DNS) SetFakeDNSOption(isFakeEnable bool) {
 	s.ipOption.FakeEnable = isFakeEnable
 }
 
+// LookupHTTPS implements dns.HTTPSClient.
+func (s *DNS) LookupHTTPS(domain string) (*dns.HTTPSRecord, error) {
+	if domain == "" {
+		return nil, newError("empty domain name")
+	}
+
+	// Normalize the FQDN form query
+	if strings.HasSuffix(domain, ".") {
+		domain = domain[:len(domain)-1]
+	}
+	domain = net.NormalizeDomain(domain)
+
+	if s.specialUseDomains && specialUseOf(domain) != specialUseNone {
+		return nil, dns.ErrEmptyResponse
+	}
+
+	errs := []error{}
+	ctx := session.ContextWithInbound(s.ctx, &session.Inbound{Tag: s.tag})
+	for _, client := range s.sortClients(domain) {
+		rec, err := client.QueryHTTPS(ctx, domain, s.disableCache)
+		if err == errHTTPSNotSupported {
+			continue
+		}
+		if rec != nil {
+			return rec, nil
+		}
+		newError("failed to lookup HTTPS record for domain ", domain, " at server ", client.Name()).Base(err).WriteToLog()
+		errs = append(errs, err)
+		if err != context.Canceled && err != context.DeadlineExceeded {
+			return nil, err
+		}
+	}
+
+	return nil, newError("returning nil for domain ", domain).Base(errors.Combine(errs...))
+}
+
 func (s *DNS) sortClients(domain string) []*Client {
 	clients := make([]*Client, 0, len(s.clients))
 	clientUsed := make([]bool, len(s.clients))
@@ -1,6 +1,7 @@
 package dns_test
 
 import (
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -23,7 +24,16 @@ import (
 
 type staticHandler struct{}
 
+// queries of lossy.google.com. seen by staticHandler, whose first one is dropped
+var lossyQueries int32
+
 func (*staticHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	for _, q := range r.Question {
+		if q.Name == "lossy.google.com." && atomic.AddInt32(&lossyQueries, 1) == 1 {
+			return
+		}
+	}
+
 	ans := new(dns.Msg)
 	ans.Id = r.Id
 
@@ -58,6 +68,10 @@ func (*staticHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 			rr, _ := dns.NewRR("v2.api.google.com. IN A 8.8.7.8")
 			ans.Answer = append(ans.Answer, rr)
 
+		case q.Name == "lossy.google.com." && q.Qtype == dns.TypeA:
+			rr, _ := dns.NewRR("lossy.google.com. IN A 8.8.6.6")
+			ans.Answer = append(ans.Answer, rr)
+
 		case q.Name == "facebook.com." && q.Qtype == dns.TypeA:
 			rr, _ := dns.NewRR("facebook.com. IN A 9.9.9.9")
 			ans.Answer = append(ans.Answer, rr)
@@ -72,6 +86,11 @@ func (*staticHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 			common.Must(err)
 			ans.Answer = append(ans.Answer, rr)
 
+		case q.Name == "google.com." && q.Qtype == dns.TypeHTTPS:
+			rr, err := dns.NewRR(`google.com. IN HTTPS 1 . alpn="h3,h2" ech="AEX+DQBBZAAgACCwcHm6zaBSPbUSsHW7Ld6WGs1OPM2+oiqJVGzADYy6BQAEAAEAAQASY2xvdWRmbGFyZS1lY2guY29tAAA="`)
+			common.Must(err)
+			ans.Answer = append(ans.Answer, rr)
+
 		case q.Name == "notexist.google.com." && q.Qtype == dns.TypeAAAA:
 			ans.MsgHdr.Rcode = dns.RcodeNameError
 
@@ -240,6 +259,21 @@ func TestUDPServer(t *testing.T) {
 		}
 	}
 
+	{
+		ips, err := client.LookupIP("lossy.google.com", feature_dns.IPOption{
+			IPv4Enable: true,
+			IPv6Enable: false,
+			FakeEnable: false,
+		})
+		if err != nil {
+			t.Fatal("unexpected error: ", err)
+		}
+
+		if r := cmp.Diff(ips, []net.IP{{8, 8, 6, 6}}); r != "" {
+			t.Fatal(r)
+		}
+	}
+
 	{
 		_, err := client.LookupIP("notexist.google.com", feature_dns.IPOption{
 			IPv4Enable: true,
@@ -372,6 +406,94 @@ func TestPrioritizedDomain(t *testing.T) {
 	}
 }
 
+func TestGeositeDomain(t *testing.T) {
+	port := udp.PickPort()
+
+	dnsServer := dns.Server{
+		Addr:    "127.0.0.1:" + port.String(),
+		Net:     "udp",
+		Handler: &staticHandler{},
+		UDPSize: 1200,
+	}
+
+	go dnsServer.ListenAndServe()
+	time.Sleep(time.Second)
+
+	config := &core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&Config{
+				NameServers: []*net.Endpoint{
+					{
+						Network: net.Network_UDP,
+						Address: &net.IPOrDomain{
+							Address: &net.IPOrDomain_Ip{
+								Ip: []byte{127, 0, 0, 1},
+							},
+						},
+						Port: 9999, /* unreachable */
+					},
+				},
+				NameServer: []*NameServer{
+					{
+						Address: &net.Endpoint{
+							Network: net.Network_UDP,
+							Address: &net.IPOrDomain{
+								Address: &net.IPOrDomain_Ip{
+									Ip: []byte{127, 0, 0, 1},
+								},
+							},
+							Port: uint32(port),
+						},
+						Geosite: []*router.GeoSite{
+							{
+								CountryCode: "GOOGLE",
+								Domain:      []*router.Domain{{Type: router.Domain_Domain, Value: "google.com"}},
+								File:        "dns_test.dat",
+								Code:        "GOOGLE",
+							},
+						},
+					},
+				},
+			}),
+			serial.ToTypedMessage(&dispatcher.Config{}),
+			serial.ToTypedMessage(&proxyman.OutboundConfig{}),
+			serial.ToTypedMessage(&policy.Config{}),
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+			},
+		},
+	}
+
+	v, err := core.New(config)
+	common.Must(err)
+
+	client := v.GetFeature(feature_dns.ClientType()).(feature_dns.Client)
+
+	startTime := time.Now()
+
+	{
+		ips, err := client.LookupIP("api.google.com", feature_dns.IPOption{
+			IPv4Enable: true,
+			IPv6Enable: true,
+			FakeEnable: false,
+		})
+		if err != nil {
+			t.Fatal("unexpected error: ", err)
+		}
+
+		if r := cmp.Diff(ips, []net.IP{{8, 8, 7, 7}}); r != "" {
+			t.Fatal(r)
+		}
+	}
+
+	endTime := time.Now()
+	if startTime.After(endTime.Add(time.Second * 2)) {
+		t.Error("DNS query doesn't finish in 2 seconds.")
+	}
+}
+
 func TestUDPServerIPv6(t *testing.T) {
 	port := udp.PickPort()
 
@@ -431,6 +553,157 @@ func TestUDPServerIPv6(t *testing.T) {
 	}
 }
 
+func TestUDPServerHTTPS(t *testing.T) {
+	port := udp.PickPort()
+
+	dnsServer := dns.Server{
+		Addr:    "127.0.0.1:" + port.String(),
+		Net:     "udp",
+		Handler: &staticHandler{},
+		UDPSize: 1200,
+	}
+
+	go dnsServer.ListenAndServe()
+	time.Sleep(time.Second)
+
+	config := &core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&Config{
+				NameServer: []*NameServer{
+					{
+						Address: &net.Endpoint{
+							Network: net.Network_UDP,
+							Address: &net.IPOrDomain{
+								Address: &net.IPOrDomain_Ip{
+									Ip: []byte{127, 0, 0, 1},
+								},
+							},
+							Port: uint32(port),
+						},
+					},
+				},
+			}),
+			serial.ToTypedMessage(&dispatcher.Config{}),
+			serial.ToTypedMessage(&proxyman.OutboundConfig{}),
+			serial.ToTypedMessage(&policy.Config{}),
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+			},
+		},
+	}
+
+	v, err := core.New(config)
+	common.Must(err)
+
+	client := v.GetFeature(feature_dns.ClientType()).(feature_dns.HTTPSClient)
+	{
+		rec, err := client.LookupHTTPS("google.com")
+		if err != nil {
+			t.Fatal("unexpected error: ", err)
+		}
+		if r := cmp.Diff(rec.ALPN, []string{"h3", "h2", "http/1.1"}); r != "" {
+			t.Error(r)
+		}
+	}
+	{
+		_, err := client.LookupHTTPS("facebook.com")
+		if err == nil {
+			t.Error("expected error for domain without HTTPS record")
+		}
+	}
+}
+
+func TestSpecialUseDomains(t *testing.T) {
+	port := udp.PickPort()
+
+	dnsServer := dns.Server{
+		Addr:    "127.0.0.1:" + port.String(),
+		Net:     "udp",
+		Handler: &staticHandler{},
+		UDPSize: 1200,
+	}
+
+	go dnsServer.ListenAndServe()
+	time.Sleep(time.Second)
+
+	server := &net.Endpoint{
+		Network: net.Network_UDP,
+		Address: &net.IPOrDomain{
+			Address: &net.IPOrDomain_Ip{
+				Ip: []byte{127, 0, 0, 1},
+			},
+		},
+		Port: uint32(port),
+	}
+	newClient := func(lanServer *net.Endpoint) feature_dns.Client {
+		config := &core.Config{
+			App: []*serial.TypedMessage{
+				serial.ToTypedMessage(&Config{
+					NameServer:        []*NameServer{{Address: server}},
+					SpecialUseDomains: true,
+					LanServer:         lanServer,
+				}),
+				serial.ToTypedMessage(&dispatcher.Config{}),
+				serial.ToTypedMessage(&proxyman.OutboundConfig{}),
+				serial.ToTypedMessage(&policy.Config{}),
+			},
+			Outbound: []*core.OutboundHandlerConfig{
+				{
+					ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+				},
+			},
+		}
+
+		v, err := core.New(config)
+		common.Must(err)
+		return v.GetFeature(feature_dns.ClientType()).(feature_dns.Client)
+	}
+	option := feature_dns.IPOption{
+		IPv4Enable: true,
+		IPv6Enable: false,
+		FakeEnable: false,
+	}
+
+	client := newClient(nil)
+	{ // Never sent to the server, which answers 127.0.0.2
+		ips, err := client.LookupIP("localhost", option)
+		if err != nil {
+			t.Fatal("unexpected error: ", err)
+		}
+		if r := cmp.Diff(ips, []net.IP{{127, 0, 0, 1}}); r != "" {
+			t.Fatal(r)
+		}
+	}
+	for _, domain := range []string{"hostname.local", "hostname", "facebook.onion"} {
+		_, err := client.LookupIP(domain, option)
+		if feature_dns.RCodeFromError(err) != uint16(dns.RcodeNameError) {
+			t.Error("expected NXDOMAIN for ", domain, ", but got ", err)
+		}
+	}
+	{
+		ips, err := client.LookupIP("facebook.com", option)
+		if err != nil {
+			t.Fatal("unexpected error: ", err)
+		}
+		if r := cmp.Diff(ips, []net.IP{{9, 9, 9, 9}}); r != "" {
+			t.Fatal(r)
+		}
+	}
+
+	client = newClient(server)
+	for _, domain := range []string{"hostname.local", "hostname"} {
+		ips, err := client.LookupIP(domain, option)
+		if err != nil {
+			t.Fatal("unexpected error: ", err)
+		}
+		if r := cmp.Diff(ips, []net.IP{{127, 0, 0, 1}}); r != "" {
+			t.Fatal(r)
+		}
+	}
+}
+
 func TestStaticHostDomain(t *testing.T) {
 	port := udp.PickPort()
 
@@ -16,6 +16,14 @@ import (
 	"golang.org/x/net/dns/dnsmessage"
 )
 
+const (
+	// defaultMaxNegativeTTL is how long a negative answer is cached at most, unless configured otherwise.
+	defaultMaxNegativeTTL = time.Second * 600
+	// minNegativeTTL is how long a negative answer is cached when its SOA record does not tell, and how
+	// long a failure of the server is cached.
+	minNegativeTTL = time.Second * 5
+)
+
 // Fqdn normalizes domain make sure it ends with '.'
 func Fqdn(domain string) string {
 	if len(domain) > 0 && strings.HasSuffix(domain, ".") {
@@ -25,8 +33,9 @@ func Fqdn(domain string) string {
 }
 
 type record struct {
-	A    *IPRecord
-	AAAA *IPRecord
+	A     *IPRecord
+	AAAA  *IPRecord
+	HTTPS *IPRecord
 }
 
 // IPRecord is a cacheable item for a resolved domain
@@ -35,6 +44,7 @@ type IPRecord struct {
 	IP     []net.Address
 	Expire time.Time
 	RCode  dnsmessage.RCode
+	HTTPS  *dns_feature.HTTPSRecord
 }
 
 func (r *IPRecord) getIPs() ([]net.Address, error) {
@@ -166,8 +176,9 @@ func buildReqMsgs(domain string, option dns_feature.IPOption, reqIDGen func() ui
 	return reqs
 }
 
-// parseResponse parses DNS answers from the returned payload
-func parseResponse(payload []byte) (*IPRecord, error) {
+// parseResponse parses DNS answers from the returned payload. Negative answers are cached no longer than
+// maxNegativeTTL.
+func parseResponse(payload []byte, maxNegativeTTL time.Duration) (*IPRecord, error) {
 	var parser dnsmessage.Parser
 	h, err := parser.Start(payload)
 	if err != nil {
@@ -183,6 +194,8 @@ func parseResponse(payload []byte) (*IPRecord, error) {
 		RCode:  h.RCode,
 		Expire: now.Add(time.Second * 600),
 	}
+	answered := false
+	var priority uint16
 
 L:
 	for {
@@ -193,6 +206,7 @@ L:
 			}
 			break
 		}
+		answered = true
 
 		ttl := ah.TTL
 		if ttl == 0 {
@@ -218,6 +232,23 @@ L:
 				break L
 			}
 			ipRecord.IP = append(ipRecord.IP, net.IPAddress(ans.AAAA[:]))
+		case typeHTTPS, typeSVCB:
+			ans, err := parser.UnknownResource()
+			if err != nil {
+				newError("failed to parse ", ah.Type, " record for domain: ", ah.Name).Base(err).WriteToLog()
+				break L
+			}
+			p, rec, err := parseSVCB(ans.Data, ah.Type == typeHTTPS)
+			if err != nil {
+				newError("failed to parse ", ah.Type, " record for domain: ", ah.Name).Base(err).WriteToLog()
+				continue
+			}
+			// records of priority 0 alias another name, of the others the lowest priority is preferred
+			if p == 0 || (priority > 0 && p >= priority) {
+				continue
+			}
+			priority = p
+			ipRecord.HTTPS = rec
 		default:
 			if err := parser.SkipAnswer(); err != nil {
 				newError("failed to skip answer").Base(err).WriteToLog()
@@ -227,9 +258,55 @@ L:
 		}
 	}
 
+	switch {
+	case h.RCode != dnsmessage.RCodeSuccess && h.RCode != dnsmessage.RCodeNameError:
+		// a failure of the server says nothing about the domain, so it is asked again soon
+		ipRecord.Expire = now.Add(minNegativeTTL)
+	case len(ipRecord.IP) == 0 && ipRecord.HTTPS == nil:
+		// NXDOMAIN or NODATA, whose CNAME records, if any, still bound the time
+		expire := now.Add(negativeTTL(&parser, maxNegativeTTL))
+		if !answered || expire.Before(ipRecord.Expire) {
+			ipRecord.Expire = expire
+		}
+	}
+
 	return ipRecord, nil
 }
 
+// negativeTTL returns how long to cache a negative answer, following RFC 2308: the lesser of the TTL and the
+// minimum field of the SOA record in the authority section, up to maxTTL.
+func negativeTTL(parser *dnsmessage.Parser, maxTTL time.Duration) time.Duration {
+	ttl := minNegativeTTL
+	for {
+		ah, err := parser.AuthorityHeader()
+		if err != nil {
+			break
+		}
+		if ah.Type != dnsmessage.TypeSOA {
+			if err := parser.SkipAuthority(); err != nil {
+				break
+			}
+			continue
+		}
+		soa, err := parser.SOAResource()
+		if err != nil {
+			break
+		}
+		ttl = time.Duration(ah.TTL) * time.Second
+		if minTTL := time.Duration(soa.MinTTL) * time.Second; minTTL < ttl {
+			ttl = minTTL
+		}
+		if ttl < minNegativeTTL {
+			ttl = minNegativeTTL
+		}
+		break
+	}
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+	return ttl
+}
+
 // toDnsContext create a new background context with parent inbound, session and dns log
 func toDnsContext(ctx context.Context, addr string) context.Context {
 	dnsCtx := core.ToBackgroundDetachedContext(ctx)
@@ -2,6 +2,7 @@ package dns
 
 import (
 	"math/rand"
+	"net/http"
 	"testing"
 	"time"
 
@@ -51,7 +52,7 @@ func Test_parseResponse(t *testing.T) {
 	}{
 		{
 			"empty",
-			&IPRecord{0, []net.Address(nil), time.Time{}, dnsmessage.RCodeSuccess},
+			&IPRecord{0, []net.Address(nil), time.Time{}, dnsmessage.RCodeSuccess, nil},
 			false,
 		},
 		{
@@ -66,18 +67,19 @@ func Test_parseResponse(t *testing.T) {
 				[]net.Address{net.ParseAddress("8.8.8.8"), net.ParseAddress("8.8.4.4")},
 				time.Time{},
 				dnsmessage.RCodeSuccess,
+				nil,
 			},
 			false,
 		},
 		{
 			"aaaa record",
-			&IPRecord{2, []net.Address{net.ParseAddress("2001::123:8888"), net.ParseAddress("2001::123:8844")}, time.Time{}, dnsmessage.RCodeSuccess},
+			&IPRecord{2, []net.Address{net.ParseAddress("2001::123:8888"), net.ParseAddress("2001::123:8844")}, time.Time{}, dnsmessage.RCodeSuccess, nil},
 			false,
 		},
 	}
 	for i, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseResponse(p[i])
+			got, err := parseResponse(p[i], defaultMaxNegativeTTL)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("handleResponse() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -95,6 +97,64 @@ func Test_parseResponse(t *testing.T) {
 	}
 }
 
+func Test_parseResponseNegativeTTL(t *testing.T) {
+	soa := func(ttl uint32, minTTL uint32) dns.RR {
+		return &dns.SOA{
+			Hdr:     dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: ttl},
+			Ns:      "ns.example.com.",
+			Mbox:    "hostmaster.example.com.",
+			Refresh: 3600,
+			Retry:   600,
+			Expire:  86400,
+			Minttl:  minTTL,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		rcode  int
+		answer []dns.RR
+		ns     []dns.RR
+		max    time.Duration
+		want   time.Duration
+	}{
+		{"nxdomain", dns.RcodeNameError, nil, []dns.RR{soa(3600, 300)}, defaultMaxNegativeTTL, time.Second * 300},
+		{"soa ttl", dns.RcodeNameError, nil, []dns.RR{soa(60, 300)}, defaultMaxNegativeTTL, time.Second * 60},
+		{"capped", dns.RcodeNameError, nil, []dns.RR{soa(86400, 86400)}, time.Second * 120, time.Second * 120},
+		{"no soa", dns.RcodeNameError, nil, nil, defaultMaxNegativeTTL, minNegativeTTL},
+		{"zero", dns.RcodeNameError, nil, []dns.RR{soa(0, 0)}, defaultMaxNegativeTTL, minNegativeTTL},
+		{"nodata", dns.RcodeSuccess, nil, []dns.RR{soa(3600, 30)}, defaultMaxNegativeTTL, time.Second * 30},
+		{
+			"nodata cname",
+			dns.RcodeSuccess,
+			[]dns.RR{common.Must2(dns.NewRR("www.example.com. 20 IN CNAME example.com.")).(dns.RR)},
+			[]dns.RR{soa(3600, 300)},
+			defaultMaxNegativeTTL,
+			time.Second * 20,
+		},
+		{"servfail", dns.RcodeServerFailure, nil, nil, defaultMaxNegativeTTL, minNegativeTTL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ans := new(dns.Msg)
+			ans.SetQuestion("www.example.com.", dns.TypeA)
+			ans.Response = true
+			ans.Rcode = tt.rcode
+			ans.Answer = tt.answer
+			ans.Ns = tt.ns
+
+			before := time.Now()
+			got, err := parseResponse(common.Must2(ans.Pack()).([]byte), tt.max)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ttl := got.Expire.Sub(before); ttl < tt.want || ttl > tt.want+time.Second {
+				t.Errorf("cached for %v, want %v", ttl, tt.want)
+			}
+		})
+	}
+}
+
 func Test_buildReqMsgs(t *testing.T) {
 	stubID := func() uint16 {
 		return uint16(rand.Uint32())
@@ -181,3 +241,34 @@ func TestFqdn(t *testing.T) {
 		})
 	}
 }
+
+func Test_clockSkew(t *testing.T) {
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	if skew, ok := clockSkew("Wed, 01 Mar 2023 11:58:30 GMT", now); !ok || skew != 90*time.Second {
+		t.Error("unexpected skew ", skew, ok)
+	}
+	if _, ok := clockSkew("", now); ok {
+		t.Error("expected no skew without Date")
+	}
+}
+
+func Test_checkClock(t *testing.T) {
+	for _, test := range []struct {
+		skew time.Duration
+		warn bool
+	}{
+		{0, false},
+		{20 * time.Second, false},
+		{-20 * time.Second, false},
+		{time.Minute, true},
+		{-time.Minute, true},
+	} {
+		date := time.Now().Add(-test.skew).UTC().Format(http.TimeFormat)
+		if warned := checkClock("test", date); warned != test.warn {
+			t.Error("skew ", test.skew, ": expected warning ", test.warn, ", but got ", warned)
+		}
+	}
+	if checkClock("test", "not a date") {
+		t.Error("unexpected warning without a valid Date")
+	}
+}
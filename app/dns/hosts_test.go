@@ -48,11 +48,31 @@ func TestStaticHosts(t *testing.T) {
 				{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
 			},
 		},
+		{
+			Type:   DomainMatchingType_Full,
+			Domain: "例子.测试",
+			Ip: [][]byte{
+				{3, 3, 3, 3},
+			},
+		},
 	}
 
 	hosts, err := NewStaticHosts(pb, nil)
 	common.Must(err)
 
+	{
+		ips := hosts.Lookup("xn--fsqu00a.xn--0zwm56d", dns.IPOption{
+			IPv4Enable: true,
+			IPv6Enable: true,
+		})
+		if len(ips) != 1 {
+			t.Error("expect 1 IP, but got ", len(ips))
+		}
+		if diff := cmp.Diff([]byte(ips[0].IP()), []byte{3, 3, 3, 3}); diff != "" {
+			t.Error(diff)
+		}
+	}
+
 	{
 		ips := hosts.Lookup("example.com", dns.IPOption{
 			IPv4Enable: true,
@@ -0,0 +1,172 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/signal/pubsub"
+	dns_feature "github.com/xtls/xray-core/features/dns"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Types of the service binding records of RFC 9460, which dnsmessage does not name.
+const (
+	typeSVCB  = dnsmessage.Type(64)
+	typeHTTPS = dnsmessage.Type(65)
+)
+
+// Keys of the service parameters in SVCB and HTTPS records.
+const (
+	svcParamALPN          = 1
+	svcParamNoDefaultALPN = 2
+)
+
+// parseSVCB parses the RDATA of an SVCB or HTTPS record into its priority and the parameters of the service
+// binding. For an HTTPS record, the ALPN includes http/1.1 unless the record says otherwise.
+func parseSVCB(data []byte, https bool) (uint16, *dns_feature.HTTPSRecord, error) {
+	if len(data) < 3 {
+		return 0, nil, newError("record too short")
+	}
+	priority := binary.BigEndian.Uint16(data)
+
+	// the target name, which is never compressed
+	i := 2
+	for {
+		if i >= len(data) {
+			return 0, nil, newError("invalid target name")
+		}
+		l := int(data[i])
+		i++
+		if l == 0 {
+			break
+		}
+		if l&0xC0 != 0 {
+			return 0, nil, newError("invalid target name")
+		}
+		i += l
+	}
+
+	rec := &dns_feature.HTTPSRecord{}
+	defaultALPN := https
+	for i < len(data) {
+		if i+4 > len(data) {
+			return 0, nil, newError("invalid service parameter")
+		}
+		key := binary.BigEndian.Uint16(data[i:])
+		l := int(binary.BigEndian.Uint16(data[i+2:]))
+		i += 4
+		if i+l > len(data) {
+			return 0, nil, newError("invalid service parameter ", key)
+		}
+		value := data[i : i+l]
+		i += l
+
+		switch key {
+		case svcParamALPN:
+			for len(value) > 0 {
+				n := int(value[0])
+				if n == 0 || n >= len(value) {
+					return 0, nil, newError("invalid alpn")
+				}
+				rec.ALPN = append(rec.ALPN, string(value[1:n+1]))
+				value = value[n+1:]
+			}
+		case svcParamNoDefaultALPN:
+			defaultALPN = false
+		}
+	}
+	if defaultALPN {
+		found := false
+		for _, p := range rec.ALPN {
+			if p == "http/1.1" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			rec.ALPN = append(rec.ALPN, "http/1.1")
+		}
+	}
+	return priority, rec, nil
+}
+
+func (r *IPRecord) getHTTPS() (*dns_feature.HTTPSRecord, error) {
+	if r == nil || r.Expire.Before(time.Now()) {
+		return nil, errRecordNotFound
+	}
+	if r.RCode != dnsmessage.RCodeSuccess {
+		return nil, dns_feature.RCodeError(r.RCode)
+	}
+	if r.HTTPS == nil {
+		return nil, dns_feature.ErrEmptyResponse
+	}
+	return r.HTTPS, nil
+}
+
+func buildHTTPSReqMsg(domain string, reqIDGen func() uint16, reqOpts *dnsmessage.Resource) *dnsRequest {
+	msg := new(dnsmessage.Message)
+	msg.Header.ID = reqIDGen()
+	msg.Header.RecursionDesired = true
+	msg.Questions = []dnsmessage.Question{{
+		Name:  dnsmessage.MustNewName(domain),
+		Type:  typeHTTPS,
+		Class: dnsmessage.ClassINET,
+	}}
+	if reqOpts != nil {
+		msg.Additionals = append(msg.Additionals, *reqOpts)
+	}
+	return &dnsRequest{
+		reqType: typeHTTPS,
+		domain:  domain,
+		start:   time.Now(),
+		msg:     msg,
+	}
+}
+
+// httpsQuerier is a name server that keeps the records it receives, by FQDN, and publishes their arrival.
+type httpsQuerier interface {
+	Name() string
+	findRecord(domain string) (*record, bool)
+	subscribe(name string) *pubsub.Subscriber
+	sendQuery(ctx context.Context, domain string, reqs []*dnsRequest)
+	newReqID() uint16
+}
+
+func findHTTPS(s httpsQuerier, domain string) (*dns_feature.HTTPSRecord, error) {
+	rec, found := s.findRecord(domain)
+	if !found {
+		return nil, errRecordNotFound
+	}
+	return rec.HTTPS.getHTTPS()
+}
+
+// queryHTTPS returns the HTTPS record of the domain from the cache of the server, or queries the server for it.
+func queryHTTPS(ctx context.Context, s httpsQuerier, domain string, clientIP net.IP, disableCache bool) (*dns_feature.HTTPSRecord, error) {
+	fqdn := Fqdn(domain)
+
+	if !disableCache {
+		if rec, err := findHTTPS(s, fqdn); err != errRecordNotFound {
+			newError(s.Name(), " cache HIT HTTPS ", domain).Base(err).AtDebug().WriteToLog()
+			return rec, err
+		}
+	}
+
+	sub := s.subscribe(fqdn + "https")
+	defer sub.Close()
+	s.sendQuery(ctx, fqdn, []*dnsRequest{buildHTTPSReqMsg(fqdn, s.newReqID, genEDNS0Options(clientIP))})
+
+	for {
+		rec, err := findHTTPS(s, fqdn)
+		if err != errRecordNotFound {
+			return rec, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-sub.Wait():
+		}
+	}
+}
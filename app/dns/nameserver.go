@@ -13,6 +13,8 @@ import (
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/dns"
 	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/features/stats"
+	"golang.org/x/net/dns/dnsmessage"
 )
 
 // Server is the interface for Name Server.
@@ -23,6 +25,13 @@ type Server interface {
 	QueryIP(ctx context.Context, domain string, clientIP net.IP, option dns.IPOption, disableCache bool) ([]net.IP, error)
 }
 
+// HTTPSServer is a Server that also queries HTTPS records.
+type HTTPSServer interface {
+	Server
+	// QueryHTTPS sends an HTTPS query to its configured server.
+	QueryHTTPS(ctx context.Context, domain string, clientIP net.IP, disableCache bool) (*dns.HTTPSRecord, error)
+}
+
 // Client is the interface for DNS client.
 type Client struct {
 	server       Server
@@ -30,9 +39,16 @@ type Client struct {
 	skipFallback bool
 	domains      []string
 	expectIPs    []*router.GeoIPMatcher
+	// what to do with answers not matching expectIPs
+	expectIPsAction ExpectIPsAction
+	// counts answers not matching expectIPs
+	rejected stats.Counter
 }
 
-var errExpectedIPNonMatch = errors.New("expectIPs not match")
+var (
+	errExpectedIPNonMatch = errors.New("expectIPs not match")
+	errHTTPSNotSupported  = errors.New("HTTPS records not supported")
+)
 
 // NewServer creates a name server object according to the network destination url.
 func NewServer(dest net.Destination, dispatcher routing.Dispatcher) (Server, error) {
@@ -71,13 +87,32 @@ func NewServer(dest net.Destination, dispatcher routing.Dispatcher) (Server, err
 func NewClient(ctx context.Context, ns *NameServer, clientIP net.IP, container router.GeoIPMatcherContainer, matcherInfos *[]*DomainMatcherInfo, updateDomainRule func(strmatcher.Matcher, int, []*DomainMatcherInfo) error) (*Client, error) {
 	client := &Client{}
 
-	err := core.RequireFeatures(ctx, func(dispatcher routing.Dispatcher) error {
+	err := core.RequireFeatures(ctx, func(dispatcher routing.Dispatcher, sm stats.Manager) error {
 		// Create a new server for each client for now
 		server, err := NewServer(ns.Address.AsDestination(), dispatcher)
 		if err != nil {
 			return newError("failed to create nameserver").Base(err).AtWarning()
 		}
 
+		if len(ns.BootstrapIp) > 0 || ns.Bootstrap != nil {
+			doh, ok := server.(*DoHNameServer)
+			if !ok {
+				return newError("bootstrap is only supported by DoH servers, not ", server.Name()).AtWarning()
+			}
+			doh.bootstrap = &dohBootstrap{}
+			for _, ip := range ns.BootstrapIp {
+				if len(ip) != net.IPv4len && len(ip) != net.IPv6len {
+					return newError("invalid bootstrap IP of ", server.Name()).AtWarning()
+				}
+				doh.bootstrap.ips = append(doh.bootstrap.ips, net.IP(ip))
+			}
+			if ns.Bootstrap != nil {
+				if doh.bootstrap.server, err = NewServer(ns.Bootstrap.AsDestination(), dispatcher); err != nil {
+					return newError("failed to create bootstrap nameserver").Base(err).AtWarning()
+				}
+			}
+		}
+
 		// Priotize local domains with specific TLDs or without any dot to local DNS
 		if _, isLocalDNS := server.(*LocalNameServer); isLocalDNS {
 			ns.PrioritizedDomain = append(ns.PrioritizedDomain, localTLDsAndDotlessDomains...)
@@ -127,6 +162,20 @@ func NewClient(ctx context.Context, ns *NameServer, clientIP net.IP, container r
 			}
 		}
 
+		// Each geosite list is a single rule, matched by the matcher shared with the router
+		for _, site := range ns.Geosite {
+			domainRule, err := router.GetGeoSiteMatcher(site)
+			if err != nil {
+				return newError("failed to create geosite domain rule").Base(err).AtWarning()
+			}
+			originalRuleIdx := len(rules)
+			rules = append(rules, geositeRule(site))
+			err = updateDomainRule(domainRule, originalRuleIdx, *matcherInfos)
+			if err != nil {
+				return newError("failed to create geosite domain rule").Base(err).AtWarning()
+			}
+		}
+
 		// Establish expected IPs
 		var matchers []*router.GeoIPMatcher
 		for _, geoip := range ns.Geoip {
@@ -151,11 +200,23 @@ func NewClient(ctx context.Context, ns *NameServer, clientIP net.IP, container r
 		client.skipFallback = ns.SkipFallback
 		client.domains = rules
 		client.expectIPs = matchers
+		client.expectIPsAction = ns.ExpectIpsAction
+		if len(matchers) > 0 {
+			client.rejected, _ = stats.GetOrRegisterCounter(sm, "dns>>>"+server.Name()+">>>rejected")
+		}
 		return nil
 	})
 	return client, err
 }
 
+// geositeRule returns the domain rule of a geosite list as written in the config.
+func geositeRule(site *router.GeoSite) string {
+	if site.File == "geosite.dat" {
+		return "geosite:" + site.Code
+	}
+	return "ext:" + site.File + ":" + site.Code
+}
+
 // NewSimpleClient creates a DNS client with a simple destination.
 func NewSimpleClient(ctx context.Context, endpoint *net.Endpoint, clientIP net.IP) (*Client, error) {
 	client := &Client{}
@@ -198,7 +259,20 @@ func (c *Client) QueryIP(ctx context.Context, domain string, option dns.IPOption
 	return c.MatchExpectedIPs(domain, ips)
 }
 
-// MatchExpectedIPs matches queried domain IPs with expected IPs and returns matched ones.
+// QueryHTTPS sends an HTTPS query to the name server with the client's IP. It returns errHTTPSNotSupported if
+// the server cannot query HTTPS records.
+func (c *Client) QueryHTTPS(ctx context.Context, domain string, disableCache bool) (*dns.HTTPSRecord, error) {
+	server, ok := c.server.(HTTPSServer)
+	if !ok {
+		return nil, errHTTPSNotSupported
+	}
+	ctx, cancel := context.WithTimeout(ctx, 4*time.Second)
+	defer cancel()
+	return server.QueryHTTPS(ctx, domain, c.clientIP, disableCache)
+}
+
+// MatchExpectedIPs matches queried domain IPs with expected IPs and returns matched ones. If none matches,
+// it acts as the expectIPs action of the client says.
 func (c *Client) MatchExpectedIPs(domain string, ips []net.IP) ([]net.IP, error) {
 	if len(c.expectIPs) == 0 {
 		return ips, nil
@@ -213,7 +287,20 @@ func (c *Client) MatchExpectedIPs(domain string, ips []net.IP) ([]net.IP, error)
 		}
 	}
 	if len(newIps) == 0 {
-		return nil, errExpectedIPNonMatch
+		if c.rejected != nil {
+			c.rejected.Add(1)
+		}
+		switch c.expectIPsAction {
+		case ExpectIPsAction_Return:
+			newError("domain ", domain, " expectIPs not matched at server ", c.Name(), ", returning ", ips).AtInfo().WriteToLog()
+			return ips, nil
+		case ExpectIPsAction_Reject:
+			newError("domain ", domain, " expectIPs not matched at server ", c.Name(), ", rejecting ", ips).AtInfo().WriteToLog()
+			return nil, dns.RCodeError(dnsmessage.RCodeNameError)
+		default:
+			newError("domain ", domain, " expectIPs not matched at server ", c.Name(), ", dropping ", ips).AtDebug().WriteToLog()
+			return nil, errExpectedIPNonMatch
+		}
 	}
 	newError("domain ", domain, " expectIPs ", newIps, " matched at server ", c.Name()).AtDebug().WriteToLog()
 	return newIps, nil
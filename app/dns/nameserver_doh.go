@@ -38,6 +38,25 @@ type DoHNameServer struct {
 	httpClient *http.Client
 	dohURL     string
 	name       string
+	bootstrap  *dohBootstrap
+
+	maxNegativeTTL time.Duration
+	// checks the local clock against the first response of the server
+	clockCheck sync.Once
+}
+
+// dohBootstrap resolves the host of a DoH server apart from the DNS the server serves, to either pinned IPs
+// or the answer of a bootstrap server.
+type dohBootstrap struct {
+	ips    []net.IP
+	server Server
+}
+
+func (b *dohBootstrap) lookup(ctx context.Context, host string) ([]net.IP, error) {
+	if len(b.ips) > 0 {
+		return b.ips, nil
+	}
+	return b.server.QueryIP(ctx, host, nil, dns_feature.IPOption{IPv4Enable: true, IPv6Enable: true}, false)
 }
 
 // NewDoHNameServer creates DOH server object for remote resolving.
@@ -56,29 +75,31 @@ func NewDoHNameServer(url *url.URL, dispatcher routing.Dispatcher) (*DoHNameServ
 			if err != nil {
 				return nil, err
 			}
-			link, err := s.dispatcher.Dispatch(toDnsContext(ctx, s.dohURL), dest)
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			default:
-
-			}
-			if err != nil {
-				return nil, err
-			}
-
-			cc := common.ChainedClosable{}
-			if cw, ok := link.Writer.(common.Closable); ok {
-				cc = append(cc, cw)
-			}
-			if cr, ok := link.Reader.(common.Closable); ok {
-				cc = append(cc, cr)
-			}
-			return cnc.NewConnection(
-				cnc.ConnectionInputMulti(link.Writer),
-				cnc.ConnectionOutputMulti(link.Reader),
-				cnc.ConnectionOnClose(cc),
-			), nil
+			return s.dialBootstrapped(ctx, dest, func(ctx context.Context, dest net.Destination) (net.Conn, error) {
+				link, err := s.dispatcher.Dispatch(toDnsContext(ctx, s.dohURL), dest)
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				default:
+
+				}
+				if err != nil {
+					return nil, err
+				}
+
+				cc := common.ChainedClosable{}
+				if cw, ok := link.Writer.(common.Closable); ok {
+					cc = append(cc, cw)
+				}
+				if cr, ok := link.Reader.(common.Closable); ok {
+					cc = append(cc, cr)
+				}
+				return cnc.NewConnection(
+					cnc.ConnectionInputMulti(link.Writer),
+					cnc.ConnectionOutputMulti(link.Reader),
+					cnc.ConnectionOnClose(cc),
+				), nil
+			})
 		},
 	}
 	s.httpClient = &http.Client{
@@ -101,7 +122,9 @@ func NewDoHLocalNameServer(url *url.URL) *DoHNameServer {
 			if err != nil {
 				return nil, err
 			}
-			conn, err := internet.DialSystem(ctx, dest, nil)
+			conn, err := s.dialBootstrapped(ctx, dest, func(ctx context.Context, dest net.Destination) (net.Conn, error) {
+				return internet.DialSystem(ctx, dest, nil)
+			})
 			log.Record(&log.AccessMessage{
 				From:   "DNS",
 				To:     s.dohURL,
@@ -128,6 +151,8 @@ func baseDOHNameServer(url *url.URL, prefix string) *DoHNameServer {
 		pub:    pubsub.NewService(),
 		name:   prefix + "//" + url.Host,
 		dohURL: url.String(),
+
+		maxNegativeTTL: defaultMaxNegativeTTL,
 	}
 	s.cleanup = &task.Periodic{
 		Interval: time.Minute,
@@ -136,11 +161,51 @@ func baseDOHNameServer(url *url.URL, prefix string) *DoHNameServer {
 	return s
 }
 
+// dialBootstrapped dials the destination with dial, at the IPs of the bootstrap for the host of the server
+// if there is a bootstrap, trying them in turn.
+func (s *DoHNameServer) dialBootstrapped(ctx context.Context, dest net.Destination, dial func(context.Context, net.Destination) (net.Conn, error)) (net.Conn, error) {
+	if s.bootstrap == nil || !dest.Address.Family().IsDomain() {
+		return dial(ctx, dest)
+	}
+	ips, err := s.bootstrap.lookup(ctx, dest.Address.Domain())
+	if err != nil {
+		return nil, newError(s.name, " failed to bootstrap ", dest.Address).Base(err)
+	}
+	if len(ips) == 0 {
+		return nil, newError(s.name, " failed to bootstrap ", dest.Address).Base(dns_feature.ErrEmptyResponse)
+	}
+	for _, ip := range ips {
+		var conn net.Conn
+		conn, err = dial(ctx, net.Destination{
+			Network: dest.Network,
+			Address: net.IPAddress(ip),
+			Port:    dest.Port,
+		})
+		if err == nil {
+			return conn, nil
+		}
+		newError(s.name, " failed to dial ", dest.Address, " at ", ip).Base(err).AtDebug().WriteToLog(session.ExportIDToError(ctx))
+	}
+	return nil, err
+}
+
 // Name implements Server.
 func (s *DoHNameServer) Name() string {
 	return s.name
 }
 
+// ClearCache drops all cached records.
+func (s *DoHNameServer) ClearCache() {
+	s.Lock()
+	s.ips = make(map[string]*record)
+	s.Unlock()
+}
+
+// SetMaxNegativeTTL sets how long negative answers are cached at most.
+func (s *DoHNameServer) SetMaxNegativeTTL(ttl time.Duration) {
+	s.maxNegativeTTL = ttl
+}
+
 // Cleanup clears expired items from cache
 func (s *DoHNameServer) Cleanup() error {
 	now := time.Now()
@@ -158,8 +223,11 @@ func (s *DoHNameServer) Cleanup() error {
 		if record.AAAA != nil && record.AAAA.Expire.Before(now) {
 			record.AAAA = nil
 		}
+		if record.HTTPS != nil && record.HTTPS.Expire.Before(now) {
+			record.HTTPS = nil
+		}
 
-		if record.A == nil && record.AAAA == nil {
+		if record.A == nil && record.AAAA == nil && record.HTTPS == nil {
 			newError(s.name, " cleanup ", domain).AtDebug().WriteToLog()
 			delete(s.ips, domain)
 		} else {
@@ -202,6 +270,11 @@ func (s *DoHNameServer) updateIP(req *dnsRequest, ipRec *IPRecord) {
 			rec.AAAA = ipRec
 			updated = true
 		}
+	case typeHTTPS:
+		if isNewer(rec.HTTPS, ipRec) {
+			rec.HTTPS = ipRec
+			updated = true
+		}
 	}
 	newError(s.name, " got answer: ", req.domain, " ", req.reqType, " -> ", ipRec.IP, " ", elapsed).AtInfo().WriteToLog()
 
@@ -213,6 +286,8 @@ func (s *DoHNameServer) updateIP(req *dnsRequest, ipRec *IPRecord) {
 		s.pub.Publish(req.domain+"4", nil)
 	case dnsmessage.TypeAAAA:
 		s.pub.Publish(req.domain+"6", nil)
+	case typeHTTPS:
+		s.pub.Publish(req.domain+"https", nil)
 	}
 	s.Unlock()
 	common.Must(s.cleanup.Start())
@@ -222,7 +297,7 @@ func (s *DoHNameServer) newReqID() uint16 {
 	return uint16(atomic.AddUint32(&s.reqID, 1))
 }
 
-func (s *DoHNameServer) sendQuery(ctx context.Context, domain string, clientIP net.IP, option dns_feature.IPOption) {
+func (s *DoHNameServer) sendQuery(ctx context.Context, domain string, reqs []*dnsRequest) {
 	newError(s.name, " querying: ", domain).AtInfo().WriteToLog(session.ExportIDToError(ctx))
 
 	if s.name+"." == "DOH//"+domain {
@@ -230,8 +305,6 @@ func (s *DoHNameServer) sendQuery(ctx context.Context, domain string, clientIP n
 		return
 	}
 
-	reqs := buildReqMsgs(domain, option, s.newReqID, genEDNS0Options(clientIP))
-
 	var deadline time.Time
 	if d, ok := ctx.Deadline(); ok {
 		deadline = d
@@ -272,7 +345,7 @@ func (s *DoHNameServer) sendQuery(ctx context.Context, domain string, clientIP n
 				newError("failed to retrieve response for ", domain).Base(err).AtError().WriteToLog()
 				return
 			}
-			rec, err := parseResponse(resp)
+			rec, err := parseResponse(resp, s.maxNegativeTTL)
 			if err != nil {
 				newError("failed to handle DOH response for ", domain).Base(err).AtError().WriteToLog()
 				return
@@ -304,6 +377,9 @@ func (s *DoHNameServer) dohHTTPSContext(ctx context.Context, b []byte) ([]byte,
 		io.Copy(io.Discard, resp.Body) // flush resp.Body so that the conn is reusable
 		return nil, fmt.Errorf("DOH server returned code %d", resp.StatusCode)
 	}
+	s.clockCheck.Do(func() {
+		checkClock(s.name, resp.Header.Get("Date"))
+	})
 
 	return io.ReadAll(resp.Body)
 }
@@ -391,7 +467,7 @@ func (s *DoHNameServer) QueryIP(ctx context.Context, domain string, clientIP net
 		}
 		close(done)
 	}()
-	s.sendQuery(ctx, fqdn, clientIP, option)
+	s.sendQuery(ctx, fqdn, buildReqMsgs(fqdn, option, s.newReqID, genEDNS0Options(clientIP)))
 	start := time.Now()
 
 	for {
@@ -408,3 +484,20 @@ func (s *DoHNameServer) QueryIP(ctx context.Context, domain string, clientIP net
 		}
 	}
 }
+
+func (s *DoHNameServer) findRecord(domain string) (*record, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	rec, found := s.ips[domain]
+	return rec, found
+}
+
+func (s *DoHNameServer) subscribe(name string) *pubsub.Subscriber {
+	return s.pub.Subscribe(name)
+}
+
+// QueryHTTPS implements HTTPSServer.
+func (s *DoHNameServer) QueryHTTPS(ctx context.Context, domain string, clientIP net.IP, disableCache bool) (*dns_feature.HTTPSRecord, error) {
+	return queryHTTPS(ctx, s, domain, clientIP, disableCache)
+}
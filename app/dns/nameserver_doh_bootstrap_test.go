@@ -0,0 +1,73 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	gonet "net"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	dns_feature "github.com/xtls/xray-core/features/dns"
+)
+
+type staticServer struct {
+	ips     []net.IP
+	queried []string
+}
+
+func (s *staticServer) Name() string {
+	return "static"
+}
+
+func (s *staticServer) QueryIP(ctx context.Context, domain string, clientIP net.IP, option dns_feature.IPOption, disableCache bool) ([]net.IP, error) {
+	s.queried = append(s.queried, domain)
+	return s.ips, nil
+}
+
+func TestDoHBootstrap(t *testing.T) {
+	u, err := url.Parse("https://dns.example.com/dns-query")
+	common.Must(err)
+	dest := net.TCPDestination(net.DomainAddress("dns.example.com"), 443)
+
+	bootstrapServer := &staticServer{ips: []net.IP{net.ParseIP("192.0.2.2")}}
+	for _, test := range []struct {
+		bootstrap *dohBootstrap
+		dialed    []string
+	}{
+		{
+			dialed: []string{"tcp:dns.example.com:443"},
+		},
+		{
+			bootstrap: &dohBootstrap{ips: []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")}},
+			dialed:    []string{"tcp:192.0.2.1:443", "tcp:[2001:db8::1]:443"},
+		},
+		{
+			bootstrap: &dohBootstrap{server: bootstrapServer},
+			dialed:    []string{"tcp:192.0.2.2:443"},
+		},
+	} {
+		s := baseDOHNameServer(u, "DOH")
+		s.bootstrap = test.bootstrap
+
+		var dialed []string
+		conn, err := s.dialBootstrapped(context.Background(), dest, func(ctx context.Context, dest net.Destination) (net.Conn, error) {
+			dialed = append(dialed, dest.String())
+			if len(dialed) < len(test.dialed) {
+				return nil, errors.New("unreachable")
+			}
+			c, _ := gonet.Pipe()
+			return c, nil
+		})
+		common.Must(err)
+		conn.Close()
+		if r := cmp.Diff(dialed, test.dialed); r != "" {
+			t.Error(r)
+		}
+	}
+	if r := cmp.Diff(bootstrapServer.queried, []string{"dns.example.com"}); r != "" {
+		t.Error(r)
+	}
+}
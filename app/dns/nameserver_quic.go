@@ -38,6 +38,8 @@ type QUICNameServer struct {
 	name        string
 	destination *net.Destination
 	connection  quic.Connection
+
+	maxNegativeTTL time.Duration
 }
 
 // NewQUICNameServer creates DNS-over-QUIC client object for local resolving
@@ -59,6 +61,8 @@ func NewQUICNameServer(url *url.URL) (*QUICNameServer, error) {
 		pub:         pubsub.NewService(),
 		name:        url.String(),
 		destination: &dest,
+
+		maxNegativeTTL: defaultMaxNegativeTTL,
 	}
 	s.cleanup = &task.Periodic{
 		Interval: time.Minute,
@@ -73,6 +77,18 @@ func (s *QUICNameServer) Name() string {
 	return s.name
 }
 
+// ClearCache drops all cached records.
+func (s *QUICNameServer) ClearCache() {
+	s.Lock()
+	s.ips = make(map[string]*record)
+	s.Unlock()
+}
+
+// SetMaxNegativeTTL sets how long negative answers are cached at most.
+func (s *QUICNameServer) SetMaxNegativeTTL(ttl time.Duration) {
+	s.maxNegativeTTL = ttl
+}
+
 // Cleanup clears expired items from cache
 func (s *QUICNameServer) Cleanup() error {
 	now := time.Now()
@@ -90,8 +106,11 @@ func (s *QUICNameServer) Cleanup() error {
 		if record.AAAA != nil && record.AAAA.Expire.Before(now) {
 			record.AAAA = nil
 		}
+		if record.HTTPS != nil && record.HTTPS.Expire.Before(now) {
+			record.HTTPS = nil
+		}
 
-		if record.A == nil && record.AAAA == nil {
+		if record.A == nil && record.AAAA == nil && record.HTTPS == nil {
 			newError(s.name, " cleanup ", domain).AtDebug().WriteToLog()
 			delete(s.ips, domain)
 		} else {
@@ -134,6 +153,11 @@ func (s *QUICNameServer) updateIP(req *dnsRequest, ipRec *IPRecord) {
 			rec.AAAA = ipRec
 			updated = true
 		}
+	case typeHTTPS:
+		if isNewer(rec.HTTPS, ipRec) {
+			rec.HTTPS = ipRec
+			updated = true
+		}
 	}
 	newError(s.name, " got answer: ", req.domain, " ", req.reqType, " -> ", ipRec.IP, " ", elapsed).AtInfo().WriteToLog()
 
@@ -145,6 +169,8 @@ func (s *QUICNameServer) updateIP(req *dnsRequest, ipRec *IPRecord) {
 		s.pub.Publish(req.domain+"4", nil)
 	case dnsmessage.TypeAAAA:
 		s.pub.Publish(req.domain+"6", nil)
+	case typeHTTPS:
+		s.pub.Publish(req.domain+"https", nil)
 	}
 	s.Unlock()
 	common.Must(s.cleanup.Start())
@@ -154,11 +180,9 @@ func (s *QUICNameServer) newReqID() uint16 {
 	return uint16(atomic.AddUint32(&s.reqID, 1))
 }
 
-func (s *QUICNameServer) sendQuery(ctx context.Context, domain string, clientIP net.IP, option dns_feature.IPOption) {
+func (s *QUICNameServer) sendQuery(ctx context.Context, domain string, reqs []*dnsRequest) {
 	newError(s.name, " querying: ", domain).AtInfo().WriteToLog(session.ExportIDToError(ctx))
 
-	reqs := buildReqMsgs(domain, option, s.newReqID, genEDNS0Options(clientIP))
-
 	var deadline time.Time
 	if d, ok := ctx.Deadline(); ok {
 		deadline = d
@@ -214,7 +238,7 @@ func (s *QUICNameServer) sendQuery(ctx context.Context, domain string, clientIP
 				return
 			}
 
-			rec, err := parseResponse(respBuf.Bytes())
+			rec, err := parseResponse(respBuf.Bytes(), s.maxNegativeTTL)
 			if err != nil {
 				newError("failed to handle response").Base(err).AtError().WriteToLog()
 				return
@@ -307,7 +331,7 @@ func (s *QUICNameServer) QueryIP(ctx context.Context, domain string, clientIP ne
 		}
 		close(done)
 	}()
-	s.sendQuery(ctx, fqdn, clientIP, option)
+	s.sendQuery(ctx, fqdn, buildReqMsgs(fqdn, option, s.newReqID, genEDNS0Options(clientIP)))
 	start := time.Now()
 
 	for {
@@ -397,3 +421,20 @@ func (s *QUICNameServer) openStream(ctx context.Context) (quic.Stream, error) {
 	// open a new stream
 	return conn.OpenStreamSync(ctx)
 }
+
+func (s *QUICNameServer) findRecord(domain string) (*record, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	rec, found := s.ips[domain]
+	return rec, found
+}
+
+func (s *QUICNameServer) subscribe(name string) *pubsub.Subscriber {
+	return s.pub.Subscribe(name)
+}
+
+// QueryHTTPS implements HTTPSServer.
+func (s *QUICNameServer) QueryHTTPS(ctx context.Context, domain string, clientIP net.IP, disableCache bool) (*dns_feature.HTTPSRecord, error) {
+	return queryHTTPS(ctx, s, domain, clientIP, disableCache)
+}
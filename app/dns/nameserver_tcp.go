@@ -34,6 +34,8 @@ type TCPNameServer struct {
 	cleanup     *task.Periodic
 	reqID       uint32
 	dial        func(context.Context) (net.Conn, error)
+
+	maxNegativeTTL time.Duration
 }
 
 // NewTCPNameServer creates DNS over TCP server object for remote resolving.
@@ -88,6 +90,8 @@ func baseTCPNameServer(url *url.URL, prefix string) (*TCPNameServer, error) {
 		ips:         make(map[string]*record),
 		pub:         pubsub.NewService(),
 		name:        prefix + "//" + dest.NetAddr(),
+
+		maxNegativeTTL: defaultMaxNegativeTTL,
 	}
 	s.cleanup = &task.Periodic{
 		Interval: time.Minute,
@@ -102,6 +106,18 @@ func (s *TCPNameServer) Name() string {
 	return s.name
 }
 
+// ClearCache drops all cached records.
+func (s *TCPNameServer) ClearCache() {
+	s.Lock()
+	s.ips = make(map[string]*record)
+	s.Unlock()
+}
+
+// SetMaxNegativeTTL sets how long negative answers are cached at most.
+func (s *TCPNameServer) SetMaxNegativeTTL(ttl time.Duration) {
+	s.maxNegativeTTL = ttl
+}
+
 // Cleanup clears expired items from cache
 func (s *TCPNameServer) Cleanup() error {
 	now := time.Now()
@@ -119,8 +135,11 @@ func (s *TCPNameServer) Cleanup() error {
 		if record.AAAA != nil && record.AAAA.Expire.Before(now) {
 			record.AAAA = nil
 		}
+		if record.HTTPS != nil && record.HTTPS.Expire.Before(now) {
+			record.HTTPS = nil
+		}
 
-		if record.A == nil && record.AAAA == nil {
+		if record.A == nil && record.AAAA == nil && record.HTTPS == nil {
 			newError(s.name, " cleanup ", domain).AtDebug().WriteToLog()
 			delete(s.ips, domain)
 		} else {
@@ -163,6 +182,11 @@ func (s *TCPNameServer) updateIP(req *dnsRequest, ipRec *IPRecord) {
 			rec.AAAA = ipRec
 			updated = true
 		}
+	case typeHTTPS:
+		if isNewer(rec.HTTPS, ipRec) {
+			rec.HTTPS = ipRec
+			updated = true
+		}
 	}
 	newError(s.name, " got answer: ", req.domain, " ", req.reqType, " -> ", ipRec.IP, " ", elapsed).AtInfo().WriteToLog()
 
@@ -174,6 +198,8 @@ func (s *TCPNameServer) updateIP(req *dnsRequest, ipRec *IPRecord) {
 		s.pub.Publish(req.domain+"4", nil)
 	case dnsmessage.TypeAAAA:
 		s.pub.Publish(req.domain+"6", nil)
+	case typeHTTPS:
+		s.pub.Publish(req.domain+"https", nil)
 	}
 	s.Unlock()
 	common.Must(s.cleanup.Start())
@@ -183,11 +209,9 @@ func (s *TCPNameServer) newReqID() uint16 {
 	return uint16(atomic.AddUint32(&s.reqID, 1))
 }
 
-func (s *TCPNameServer) sendQuery(ctx context.Context, domain string, clientIP net.IP, option dns_feature.IPOption) {
+func (s *TCPNameServer) sendQuery(ctx context.Context, domain string, reqs []*dnsRequest) {
 	newError(s.name, " querying DNS for: ", domain).AtDebug().WriteToLog(session.ExportIDToError(ctx))
 
-	reqs := buildReqMsgs(domain, option, s.newReqID, genEDNS0Options(clientIP))
-
 	var deadline time.Time
 	if d, ok := ctx.Deadline(); ok {
 		deadline = d
@@ -256,7 +280,7 @@ func (s *TCPNameServer) sendQuery(ctx context.Context, domain string, clientIP n
 				return
 			}
 
-			rec, err := parseResponse(respBuf.Bytes())
+			rec, err := parseResponse(respBuf.Bytes(), s.maxNegativeTTL)
 			if err != nil {
 				newError("failed to parse DNS over TCP response").Base(err).AtError().WriteToLog()
 				return
@@ -346,7 +370,7 @@ func (s *TCPNameServer) QueryIP(ctx context.Context, domain string, clientIP net
 		}
 		close(done)
 	}()
-	s.sendQuery(ctx, fqdn, clientIP, option)
+	s.sendQuery(ctx, fqdn, buildReqMsgs(fqdn, option, s.newReqID, genEDNS0Options(clientIP)))
 	start := time.Now()
 
 	for {
@@ -363,3 +387,20 @@ func (s *TCPNameServer) QueryIP(ctx context.Context, domain string, clientIP net
 		}
 	}
 }
+
+func (s *TCPNameServer) findRecord(domain string) (*record, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	rec, found := s.ips[domain]
+	return rec, found
+}
+
+func (s *TCPNameServer) subscribe(name string) *pubsub.Subscriber {
+	return s.pub.Subscribe(name)
+}
+
+// QueryHTTPS implements HTTPSServer.
+func (s *TCPNameServer) QueryHTTPS(ctx context.Context, domain string, clientIP net.IP, disableCache bool) (*dns_feature.HTTPSRecord, error) {
+	return queryHTTPS(ctx, s, domain, clientIP, disableCache)
+}
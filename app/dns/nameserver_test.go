@@ -0,0 +1,56 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/xtls/xray-core/app/router"
+	"github.com/xtls/xray-core/app/stats"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/features/dns"
+)
+
+func TestMatchExpectedIPsAction(t *testing.T) {
+	matcher, err := (&router.GeoIPMatcherContainer{}).Add(&router.GeoIP{
+		Cidr: []*router.CIDR{{Ip: []byte{192, 0, 2, 0}, Prefix: 24}},
+	})
+	common.Must(err)
+
+	matched := []net.IP{{192, 0, 2, 1}, {198, 51, 100, 1}}
+	poisoned := []net.IP{{198, 51, 100, 1}}
+	for _, test := range []struct {
+		action ExpectIPsAction
+		ips    []net.IP
+		err    error
+	}{
+		{action: ExpectIPsAction_Next, err: errExpectedIPNonMatch},
+		{action: ExpectIPsAction_Return, ips: poisoned},
+		{action: ExpectIPsAction_Reject, err: dns.RCodeError(3)},
+	} {
+		counter := new(stats.Counter)
+		client := &Client{
+			server:          NewLocalNameServer(),
+			expectIPs:       []*router.GeoIPMatcher{matcher},
+			expectIPsAction: test.action,
+			rejected:        counter,
+		}
+
+		ips, err := client.MatchExpectedIPs("example.com", matched)
+		common.Must(err)
+		if r := cmp.Diff(ips, matched[:1]); r != "" {
+			t.Error(test.action, r)
+		}
+
+		ips, err = client.MatchExpectedIPs("example.com", poisoned)
+		if err != test.err {
+			t.Error(test.action, " error: ", err)
+		}
+		if r := cmp.Diff(ips, test.ips); r != "" {
+			t.Error(test.action, r)
+		}
+		if counter.Value() != 1 {
+			t.Error(test.action, " rejected: ", counter.Value())
+		}
+	}
+}
@@ -21,6 +21,14 @@ import (
 	"golang.org/x/net/dns/dnsmessage"
 )
 
+const (
+	// how long a query waits for its answer before it is sent again
+	retransmitDelay = time.Second
+	// the first retransmission reuses the connection, later ones dispatch anew, so that the
+	// router or a balancer may pick another outbound
+	maxRetransmits = 2
+)
+
 // ClassicNameServer implemented traditional UDP DNS.
 type ClassicNameServer struct {
 	sync.RWMutex
@@ -32,6 +40,8 @@ type ClassicNameServer struct {
 	udpServer *udp.Dispatcher
 	cleanup   *task.Periodic
 	reqID     uint32
+
+	maxNegativeTTL time.Duration
 }
 
 // NewClassicNameServer creates udp server object for remote resolving.
@@ -47,6 +57,8 @@ func NewClassicNameServer(address net.Destination, dispatcher routing.Dispatcher
 		requests: make(map[uint16]*dnsRequest),
 		pub:      pubsub.NewService(),
 		name:     strings.ToUpper(address.String()),
+
+		maxNegativeTTL: defaultMaxNegativeTTL,
 	}
 	s.cleanup = &task.Periodic{
 		Interval: time.Minute,
@@ -62,6 +74,18 @@ func (s *ClassicNameServer) Name() string {
 	return s.name
 }
 
+// ClearCache drops all cached records.
+func (s *ClassicNameServer) ClearCache() {
+	s.Lock()
+	s.ips = make(map[string]*record)
+	s.Unlock()
+}
+
+// SetMaxNegativeTTL sets how long negative answers are cached at most.
+func (s *ClassicNameServer) SetMaxNegativeTTL(ttl time.Duration) {
+	s.maxNegativeTTL = ttl
+}
+
 // Cleanup clears expired items from cache
 func (s *ClassicNameServer) Cleanup() error {
 	now := time.Now()
@@ -79,8 +103,11 @@ func (s *ClassicNameServer) Cleanup() error {
 		if record.AAAA != nil && record.AAAA.Expire.Before(now) {
 			record.AAAA = nil
 		}
+		if record.HTTPS != nil && record.HTTPS.Expire.Before(now) {
+			record.HTTPS = nil
+		}
 
-		if record.A == nil && record.AAAA == nil {
+		if record.A == nil && record.AAAA == nil && record.HTTPS == nil {
 			newError(s.name, " cleanup ", domain).AtDebug().WriteToLog()
 			delete(s.ips, domain)
 		} else {
@@ -107,7 +134,7 @@ func (s *ClassicNameServer) Cleanup() error {
 
 // HandleResponse handles udp response packet from remote DNS server.
 func (s *ClassicNameServer) HandleResponse(ctx context.Context, packet *udp_proto.Packet) {
-	ipRec, err := parseResponse(packet.Payload.Bytes())
+	ipRec, err := parseResponse(packet.Payload.Bytes(), s.maxNegativeTTL)
 	if err != nil {
 		newError(s.name, " fail to parse responded DNS udp").AtError().WriteToLog()
 		return
@@ -122,7 +149,8 @@ func (s *ClassicNameServer) HandleResponse(ctx context.Context, packet *udp_prot
 	}
 	s.Unlock()
 	if !ok {
-		newError(s.name, " cannot find the pending request").AtError().WriteToLog()
+		// answers to retransmitted queries may arrive more than once
+		newError(s.name, " cannot find the pending request").AtDebug().WriteToLog()
 		return
 	}
 
@@ -132,11 +160,13 @@ func (s *ClassicNameServer) HandleResponse(ctx context.Context, packet *udp_prot
 		rec.A = ipRec
 	case dnsmessage.TypeAAAA:
 		rec.AAAA = ipRec
+	case typeHTTPS:
+		rec.HTTPS = ipRec
 	}
 
 	elapsed := time.Since(req.start)
 	newError(s.name, " got answer: ", req.domain, " ", req.reqType, " -> ", ipRec.IP, " ", elapsed).AtInfo().WriteToLog()
-	if len(req.domain) > 0 && (rec.A != nil || rec.AAAA != nil) {
+	if len(req.domain) > 0 && (rec.A != nil || rec.AAAA != nil || rec.HTTPS != nil) {
 		s.updateIP(req.domain, &rec)
 	}
 }
@@ -158,6 +188,10 @@ func (s *ClassicNameServer) updateIP(domain string, newRec *record) {
 		rec.AAAA = newRec.AAAA
 		updated = true
 	}
+	if isNewer(rec.HTTPS, newRec.HTTPS) {
+		rec.HTTPS = newRec.HTTPS
+		updated = true
+	}
 
 	if updated {
 		newError(s.name, " updating IP records for domain:", domain).AtDebug().WriteToLog()
@@ -169,6 +203,9 @@ func (s *ClassicNameServer) updateIP(domain string, newRec *record) {
 	if newRec.AAAA != nil {
 		s.pub.Publish(domain+"6", nil)
 	}
+	if newRec.HTTPS != nil {
+		s.pub.Publish(domain+"https", nil)
+	}
 	s.Unlock()
 	common.Must(s.cleanup.Start())
 }
@@ -186,16 +223,60 @@ func (s *ClassicNameServer) addPendingRequest(req *dnsRequest) {
 	s.requests[id] = req
 }
 
-func (s *ClassicNameServer) sendQuery(ctx context.Context, domain string, clientIP net.IP, option dns_feature.IPOption) {
+func (s *ClassicNameServer) sendQuery(ctx context.Context, domain string, reqs []*dnsRequest) {
 	newError(s.name, " querying DNS for: ", domain).AtDebug().WriteToLog(session.ExportIDToError(ctx))
 
-	reqs := buildReqMsgs(domain, option, s.newReqID, genEDNS0Options(clientIP))
-
 	for _, req := range reqs {
 		s.addPendingRequest(req)
-		b, _ := dns.PackMessage(req.msg)
-		s.udpServer.Dispatch(toDnsContext(ctx, s.address.String()), *s.address, b)
+		s.send(ctx, req)
+	}
+	go s.retransmit(ctx, reqs)
+}
+
+func (s *ClassicNameServer) send(ctx context.Context, req *dnsRequest) {
+	b, _ := dns.PackMessage(req.msg)
+	s.udpServer.Dispatch(toDnsContext(ctx, s.address.String()), *s.address, b)
+}
+
+// retransmit sends the queries again while they are unanswered, until the query is done.
+// Queries keep their IDs, so whichever answer comes first completes them.
+func (s *ClassicNameServer) retransmit(ctx context.Context, reqs []*dnsRequest) {
+	timer := time.NewTimer(retransmitDelay)
+	defer timer.Stop()
+
+	for i := 0; i < maxRetransmits; i++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		reqs = s.unanswered(reqs)
+		if len(reqs) == 0 {
+			return
+		}
+		if i > 0 {
+			s.udpServer.RemoveRay(*s.address)
+		}
+		newError(s.name, " retransmitting query for ", reqs[0].domain).AtDebug().WriteToLog(session.ExportIDToError(ctx))
+		for _, req := range reqs {
+			s.send(ctx, req)
+		}
+		timer.Reset(retransmitDelay)
+	}
+}
+
+func (s *ClassicNameServer) unanswered(reqs []*dnsRequest) []*dnsRequest {
+	s.RLock()
+	defer s.RUnlock()
+
+	pending := reqs[:0]
+	for _, req := range reqs {
+		if s.requests[req.msg.ID] == req {
+			pending = append(pending, req)
+		}
 	}
+	return pending
 }
 
 func (s *ClassicNameServer) findIPsForDomain(domain string, option dns_feature.IPOption) ([]net.IP, error) {
@@ -277,7 +358,7 @@ func (s *ClassicNameServer) QueryIP(ctx context.Context, domain string, clientIP
 		}
 		close(done)
 	}()
-	s.sendQuery(ctx, fqdn, clientIP, option)
+	s.sendQuery(ctx, fqdn, buildReqMsgs(fqdn, option, s.newReqID, genEDNS0Options(clientIP)))
 	start := time.Now()
 
 	for {
@@ -294,3 +375,20 @@ func (s *ClassicNameServer) QueryIP(ctx context.Context, domain string, clientIP
 		}
 	}
 }
+
+func (s *ClassicNameServer) findRecord(domain string) (*record, bool) {
+	s.RLock()
+	defer s.RUnlock()
+
+	rec, found := s.ips[domain]
+	return rec, found
+}
+
+func (s *ClassicNameServer) subscribe(name string) *pubsub.Subscriber {
+	return s.pub.Subscribe(name)
+}
+
+// QueryHTTPS implements HTTPSServer.
+func (s *ClassicNameServer) QueryHTTPS(ctx context.Context, domain string, clientIP net.IP, disableCache bool) (*dns_feature.HTTPSRecord, error) {
+	return queryHTTPS(ctx, s, domain, clientIP, disableCache)
+}
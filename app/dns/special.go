@@ -0,0 +1,66 @@
+package dns
+
+import (
+	"context"
+	"strings"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/features/dns"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// specialUse tells how a special-use domain (RFC 6761 and later) is answered.
+type specialUse int
+
+const (
+	// specialUseNone is a domain of the public DNS.
+	specialUseNone specialUse = iota
+	// specialUseLoopback resolves to the loopback addresses, as localhost does (RFC 6761).
+	specialUseLoopback
+	// specialUseInvalid never resolves: invalid (RFC 6761), onion (RFC 7686) and alt (RFC 9476).
+	specialUseInvalid
+	// specialUseLAN only exists in local networks: local of mDNS (RFC 6762), home.arpa (RFC 8375), test and
+	// example (RFC 6761), the customary lan, localdomain and internal, and dotless names of LLMNR.
+	specialUseLAN
+)
+
+func specialUseOf(domain string) specialUse {
+	domain = strings.ToLower(domain)
+	if domain == "home.arpa" || strings.HasSuffix(domain, ".home.arpa") {
+		return specialUseLAN
+	}
+	tld := domain[strings.LastIndexByte(domain, '.')+1:]
+	switch tld {
+	case "localhost":
+		return specialUseLoopback
+	case "invalid", "onion", "alt":
+		return specialUseInvalid
+	case "local", "test", "example", "lan", "localdomain", "internal":
+		return specialUseLAN
+	}
+	if !strings.Contains(domain, ".") {
+		return specialUseLAN
+	}
+	return specialUseNone
+}
+
+// lookupSpecialUse answers a special-use domain without asking any server but the LAN server.
+func (s *DNS) lookupSpecialUse(ctx context.Context, domain string, use specialUse, option dns.IPOption) ([]net.IP, error) {
+	switch use {
+	case specialUseLoopback:
+		var ips []net.IP
+		if option.IPv4Enable {
+			ips = append(ips, net.LocalHostIP.IP())
+		}
+		if option.IPv6Enable {
+			ips = append(ips, net.LocalHostIPv6.IP())
+		}
+		return ips, nil
+	case specialUseLAN:
+		if s.lanClient != nil {
+			return s.lanClient.QueryIP(ctx, domain, option, s.disableCache)
+		}
+	}
+	newError("special-use domain ", domain, " is not resolved").AtDebug().WriteToLog()
+	return nil, dns.RCodeError(dnsmessage.RCodeNameError)
+}
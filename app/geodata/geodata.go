@@ -0,0 +1,304 @@
+package geodata
+
+//go:generate go run github.com/xtls/xray-core/common/errors/errorgen
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	gonet "net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xtls/xray-core/app/hook"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/platform"
+	"github.com/xtls/xray-core/common/signal/done"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/transport/internet/tagged"
+)
+
+// timeout of a single download, large enough for the biggest geosite files on slow links
+const downloadTimeout = 10 * time.Minute
+
+// Updater downloads geoip and geosite files and keeps them up to date, replacing each file at once
+// so readers never see a partial one. The router loads the rules using an updated file again, and
+// other configs use the new files once built again, such as after a restart.
+type Updater struct {
+	ctx    context.Context
+	config *Config
+	client *http.Client
+	done   *done.Instance
+}
+
+// New creates a new Updater.
+func New(ctx context.Context, config *Config) (*Updater, error) {
+	for _, asset := range config.Asset {
+		if asset.File == "" || asset.Url == "" {
+			return nil, newError("geodata asset requires file and url")
+		}
+		if asset.File != filepath.Base(asset.File) {
+			return nil, newError("geodata file must be a name in the asset directory: ", asset.File)
+		}
+	}
+	u := &Updater{
+		ctx:    ctx,
+		config: config,
+		done:   done.New(),
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	var resolver *gonet.Resolver
+	if config.DnsServer != "" {
+		resolver = &gonet.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network string, _ string) (gonet.Conn, error) {
+				var d gonet.Dialer
+				return d.DialContext(ctx, network, config.DnsServer)
+			},
+		}
+	}
+	if config.OutboundTag != "" || resolver != nil {
+		dial := transport.DialContext
+		if config.OutboundTag != "" {
+			transport.Proxy = nil
+		}
+		transport.DialContext = func(ctx context.Context, network string, addr string) (gonet.Conn, error) {
+			if resolver != nil {
+				resolved, err := resolve(ctx, resolver, addr)
+				if err != nil {
+					return nil, err
+				}
+				addr = resolved
+			}
+			if config.OutboundTag == "" {
+				return dial(ctx, network, addr)
+			}
+			dest, err := net.ParseDestination(network + ":" + addr)
+			if err != nil {
+				return nil, newError("cannot understand address").Base(err)
+			}
+			// MUST use Xray's built in context system
+			return tagged.Dialer(u.ctx, dest, config.OutboundTag)
+		}
+	}
+	u.client = &http.Client{
+		Transport: transport,
+		Timeout:   downloadTimeout,
+	}
+	return u, nil
+}
+
+// resolve replaces the host of addr with its first IP given by the resolver.
+func resolve(ctx context.Context, resolver *gonet.Resolver, addr string) (string, error) {
+	host, port, err := gonet.SplitHostPort(addr)
+	if err != nil {
+		return "", newError("cannot understand address").Base(err)
+	}
+	if gonet.ParseIP(host) != nil {
+		return addr, nil
+	}
+	ips, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return "", newError("failed to resolve ", host).Base(err)
+	}
+	if len(ips) == 0 {
+		return "", newError("no IP for ", host)
+	}
+	return gonet.JoinHostPort(ips[0].IP.String(), port), nil
+}
+
+// AssetReloader is a feature that loads a geoip or geosite file again once it is updated.
+type AssetReloader interface {
+	ReloadAsset(file string) error
+}
+
+// Type implements common.HasType.
+func (*Updater) Type() interface{} {
+	return Type()
+}
+
+// Type returns the feature type of Updater.
+func Type() interface{} {
+	return (*Updater)(nil)
+}
+
+// Start implements common.Runnable.
+func (u *Updater) Start() error {
+	go u.run()
+	return nil
+}
+
+// Close implements common.Closable.
+func (u *Updater) Close() error {
+	return u.done.Close()
+}
+
+func (u *Updater) run() {
+	// files that are missing are needed at once, the others only when they are due
+	u.update(true)
+	if u.config.Interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(u.config.Interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			u.update(false)
+		case <-u.done.Wait():
+			return
+		}
+	}
+}
+
+func (u *Updater) update(missingOnly bool) {
+	instance := core.FromContext(u.ctx)
+	hooks := hook.FromInstance(instance)
+	for _, asset := range u.config.Asset {
+		if u.done.Done() {
+			return
+		}
+		if missingOnly {
+			if _, err := os.Stat(platform.GetAssetLocation(asset.File)); !os.IsNotExist(err) {
+				continue
+			}
+		}
+		updated, err := u.download(asset)
+		if err != nil {
+			newError("failed to update ", asset.File).Base(err).AtWarning().WriteToLog()
+			hooks.Fire(hook.EventGeodataError, map[string]string{
+				"file":  asset.File,
+				"error": err.Error(),
+			})
+			continue
+		}
+		if updated {
+			newError("updated ", asset.File).AtInfo().WriteToLog()
+			reload(instance, asset.File)
+			hooks.Fire(hook.EventGeodataUpdate, map[string]string{
+				"file": asset.File,
+			})
+		}
+	}
+}
+
+// reload makes the router of the instance load the rules using the file again.
+func reload(instance *core.Instance, file string) {
+	if instance == nil {
+		return
+	}
+	if reloader, ok := instance.GetFeature(routing.RouterType()).(AssetReloader); ok {
+		if err := reloader.ReloadAsset(file); err != nil {
+			newError("failed to reload ", file).Base(err).AtWarning().WriteToLog()
+		}
+	}
+}
+
+// download fetches the asset into a temporary file next to the current one, and renames it over
+// the current one once it is complete and verified. It returns false if the file didn't change.
+func (u *Updater) download(asset *Asset) (bool, error) {
+	path := platform.GetAssetLocation(asset.File)
+
+	expected := strings.ToLower(asset.Sha256)
+	if expected == "" && asset.ChecksumUrl != "" {
+		sum, err := u.fetchChecksum(asset.ChecksumUrl)
+		if err != nil {
+			return false, err
+		}
+		expected = sum
+	}
+
+	request, err := http.NewRequestWithContext(u.ctx, http.MethodGet, asset.Url, nil)
+	if err != nil {
+		return false, newError("invalid url: ", asset.Url).Base(err)
+	}
+	if info, err := os.Stat(path); err == nil {
+		request.Header.Set("If-Modified-Since", info.ModTime().UTC().Format(http.TimeFormat))
+	}
+	response, err := u.client.Do(request)
+	if err != nil {
+		return false, newError("failed to download ", asset.Url).Base(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotModified {
+		return false, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return false, newError("unexpected status of ", asset.Url, ": ", response.Status)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return false, newError("failed to create temporary file").Base(err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash), response.Body)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return false, newError("failed to download ", asset.Url).Base(err)
+	}
+	if n == 0 {
+		return false, newError("empty file downloaded from ", asset.Url)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); expected != "" && sum != expected {
+		return false, newError("checksum mismatch of ", asset.Url, ": ", sum, ", expected ", expected)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return false, err
+	}
+	if modified, err := http.ParseTime(response.Header.Get("Last-Modified")); err == nil {
+		os.Chtimes(tmp.Name(), modified, modified)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return false, newError("failed to replace ", path).Base(err)
+	}
+	return true, nil
+}
+
+// fetchChecksum returns the SHA-256 in a file in sha256sum format.
+func (u *Updater) fetchChecksum(url string) (string, error) {
+	request, err := http.NewRequestWithContext(u.ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", newError("invalid url: ", url).Base(err)
+	}
+	response, err := u.client.Do(request)
+	if err != nil {
+		return "", newError("failed to download ", url).Base(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", newError("unexpected status of ", url, ": ", response.Status)
+	}
+	content, err := io.ReadAll(io.LimitReader(response.Body, 4096))
+	if err != nil {
+		return "", newError("failed to download ", url).Base(err)
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 || len(fields[0]) != sha256.Size*2 {
+		return "", newError("invalid checksum file: ", url)
+	}
+	if _, err := hex.DecodeString(fields[0]); err != nil {
+		return "", newError("invalid checksum file: ", url).Base(err)
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		return New(ctx, config.(*Config))
+	}))
+}
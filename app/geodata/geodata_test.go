@@ -0,0 +1,124 @@
+package geodata
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	gonet "net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/xtls/xray-core/common"
+)
+
+func TestDownload(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XRAY_LOCATION_ASSET", dir)
+
+	content := []byte("geoip content")
+	sum := sha256.Sum256(content)
+	modified := time.Now().Add(-time.Hour).Truncate(time.Second)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/geoip.dat":
+			http.ServeContent(w, r, "geoip.dat", modified, bytes.NewReader(content))
+		case "/geoip.dat.sha256sum":
+			w.Write([]byte(hex.EncodeToString(sum[:]) + "  geoip.dat\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	u, err := New(context.Background(), &Config{})
+	common.Must(err)
+
+	asset := &Asset{
+		File:        "geoip.dat",
+		Url:         server.URL + "/geoip.dat",
+		ChecksumUrl: server.URL + "/geoip.dat.sha256sum",
+	}
+	updated, err := u.download(asset)
+	common.Must(err)
+	if !updated {
+		t.Fatal("expect file to be downloaded")
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "geoip.dat"))
+	common.Must(err)
+	if !bytes.Equal(got, content) {
+		t.Error("unexpected content: ", string(got))
+	}
+
+	updated, err = u.download(asset)
+	common.Must(err)
+	if updated {
+		t.Error("expect unchanged file to be skipped")
+	}
+
+	_, err = u.download(&Asset{
+		File:   "geosite.dat",
+		Url:    server.URL + "/geoip.dat",
+		Sha256: hex.EncodeToString(make([]byte, sha256.Size)),
+	})
+	if err == nil {
+		t.Error("expect checksum mismatch")
+	}
+	entries, err := os.ReadDir(dir)
+	common.Must(err)
+	if len(entries) != 1 {
+		t.Error("expect only geoip.dat in asset directory, got ", len(entries), " files")
+	}
+}
+
+func TestDownloadWithDNSServer(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XRAY_LOCATION_ASSET", dir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("geosite content"))
+	}))
+	defer server.Close()
+	_, port, err := gonet.SplitHostPort(server.Listener.Addr().String())
+	common.Must(err)
+
+	queries := make(chan string, 4)
+	conn, err := gonet.ListenPacket("udp", "127.0.0.1:0")
+	common.Must(err)
+	dnsServer := &dns.Server{
+		PacketConn: conn,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			ans := new(dns.Msg)
+			ans.SetReply(r)
+			for _, q := range r.Question {
+				queries <- q.Name
+				if q.Qtype == dns.TypeA {
+					rr, _ := dns.NewRR(q.Name + " IN A 127.0.0.1")
+					ans.Answer = append(ans.Answer, rr)
+				}
+			}
+			w.WriteMsg(ans)
+		}),
+	}
+	go dnsServer.ActivateAndServe()
+	defer dnsServer.Shutdown()
+
+	u, err := New(context.Background(), &Config{DnsServer: conn.LocalAddr().String()})
+	common.Must(err)
+	updated, err := u.download(&Asset{
+		File: "geosite.dat",
+		Url:  "http://geodata.invalid:" + port + "/geosite.dat",
+	})
+	common.Must(err)
+	if !updated {
+		t.Fatal("expect file to be downloaded")
+	}
+	if name := <-queries; name != "geodata.invalid." {
+		t.Error("unexpected query: ", name)
+	}
+}
@@ -0,0 +1,241 @@
+package hook
+
+//go:generate go run github.com/xtls/xray-core/common/errors/errorgen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+// Events fired by the core.
+const (
+	EventHandlerStart = "handler.start"
+	EventHandlerStop  = "handler.stop"
+	EventHandlerFail  = "handler.fail"
+	EventUserAdd      = "user.add"
+	EventUserRemove   = "user.remove"
+	// Outbound marked down or up by the observatory.
+	EventOutboundDown = "outbound.down"
+	EventOutboundUp   = "outbound.up"
+	// Failed connections of an outbound exceeded the error threshold.
+	EventOutboundErrors = "outbound.errors"
+	// A certificate failed to reload or to be issued.
+	EventCertError = "cert.error"
+	// A geodata file was updated, or failed to.
+	EventGeodataUpdate = "geodata.update"
+	EventGeodataError  = "geodata.error"
+)
+
+// window in which outbound errors are counted
+const errorWindow = time.Minute
+
+var (
+	activeAccess sync.RWMutex
+	active       *Hooks
+)
+
+// Hooks runs commands or webhooks when events happen, so external systems don't need to poll the API.
+type Hooks struct {
+	hooks          []*Hook
+	errorThreshold uint32
+
+	errorAccess sync.Mutex
+	errors      map[string]uint32
+	resetTask   *task.Periodic
+}
+
+// New creates a new Hooks.
+func New(ctx context.Context, config *Config) (*Hooks, error) {
+	for _, hook := range config.Hook {
+		if len(hook.Command) == 0 && hook.Url == "" {
+			return nil, newError("hook has neither command nor url")
+		}
+	}
+	h := &Hooks{
+		hooks:          config.Hook,
+		errorThreshold: config.ErrorThreshold,
+		errors:         make(map[string]uint32),
+	}
+	if h.errorThreshold > 0 {
+		h.resetTask = &task.Periodic{
+			Interval: errorWindow,
+			Execute:  h.resetErrors,
+		}
+	}
+	return h, nil
+}
+
+// FromInstance returns the Hooks of the instance, or nil if none is configured.
+func FromInstance(v *core.Instance) *Hooks {
+	if v == nil {
+		return nil
+	}
+	h, _ := v.GetFeature(Type()).(*Hooks)
+	return h
+}
+
+// Type implements common.HasType.
+func (*Hooks) Type() interface{} {
+	return Type()
+}
+
+// Type returns the feature type of Hooks.
+func Type() interface{} {
+	return (*Hooks)(nil)
+}
+
+// Start implements common.Runnable.
+func (h *Hooks) Start() error {
+	activeAccess.Lock()
+	active = h
+	activeAccess.Unlock()
+
+	if h.resetTask != nil {
+		return h.resetTask.Start()
+	}
+	return nil
+}
+
+// Close implements common.Closable.
+func (h *Hooks) Close() error {
+	activeAccess.Lock()
+	if active == h {
+		active = nil
+	}
+	activeAccess.Unlock()
+
+	if h.resetTask != nil {
+		return h.resetTask.Close()
+	}
+	return nil
+}
+
+// Fire runs the hooks of the running instance. It is meant for code that has no access to the instance.
+func Fire(event string, fields map[string]string) {
+	activeAccess.RLock()
+	h := active
+	activeAccess.RUnlock()
+	h.Fire(event, fields)
+}
+
+func (h *Hooks) resetErrors() error {
+	h.errorAccess.Lock()
+	defer h.errorAccess.Unlock()
+
+	for tag := range h.errors {
+		delete(h.errors, tag)
+	}
+	return nil
+}
+
+// RecordError counts a failed connection of the outbound, and fires outbound.errors when the count
+// reaches the threshold within a minute. It is safe to call on a nil Hooks.
+func (h *Hooks) RecordError(tag string) {
+	if h == nil || h.errorThreshold == 0 {
+		return
+	}
+	h.errorAccess.Lock()
+	h.errors[tag]++
+	count := h.errors[tag]
+	h.errorAccess.Unlock()
+
+	if count == h.errorThreshold {
+		h.Fire(EventOutboundErrors, map[string]string{
+			"tag":   tag,
+			"count": strconv.FormatUint(uint64(count), 10),
+		})
+	}
+}
+
+// Fire runs the hooks matching the event in the background. Fields describe the event, such as the tag of a handler.
+// It is safe to call on a nil Hooks.
+func (h *Hooks) Fire(event string, fields map[string]string) {
+	if h == nil {
+		return
+	}
+	for _, hook := range h.hooks {
+		if hook.matches(event) {
+			go hook.run(event, fields)
+		}
+	}
+}
+
+func (h *Hook) matches(event string) bool {
+	if len(h.Event) == 0 {
+		return true
+	}
+	for _, e := range h.Event {
+		if e == event || strings.HasPrefix(event, e+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Hook) run(event string, fields map[string]string) {
+	timeout := time.Duration(h.Timeout) * time.Second
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if len(h.Command) > 0 {
+		cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+		cmd.Env = append(os.Environ(), "XRAY_EVENT="+event)
+		for k, v := range fields {
+			cmd.Env = append(cmd.Env, "XRAY_"+strings.ToUpper(k)+"="+v)
+		}
+		if err := cmd.Run(); err != nil {
+			newError("failed to run hook command for ", event).Base(err).AtWarning().WriteToLog()
+		}
+	}
+
+	if h.Url != "" {
+		payload := map[string]string{
+			"event": event,
+			"time":  time.Now().Format(time.RFC3339),
+		}
+		for k, v := range fields {
+			payload[k] = v
+		}
+		body, _ := json.Marshal(payload)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.Url, bytes.NewReader(body))
+		if err != nil {
+			newError("invalid hook url ", h.Url).Base(err).AtWarning().WriteToLog()
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			newError("failed to post hook for ", event).Base(err).AtWarning().WriteToLog()
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			newError("hook url ", h.Url, " returned status ", resp.StatusCode, " for ", event).AtWarning().WriteToLog()
+		}
+	}
+}
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		return New(ctx, config.(*Config))
+	}))
+	internet.RegisterCertErrorHandler(func(fields map[string]string) {
+		Fire(EventCertError, fields)
+	})
+}
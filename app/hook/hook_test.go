@@ -0,0 +1,147 @@
+package hook_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/xtls/xray-core/app/hook"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+func TestWebhook(t *testing.T) {
+	events := make(chan map[string]string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		common.Must(json.NewDecoder(r.Body).Decode(&payload))
+		events <- payload
+	}))
+	defer server.Close()
+
+	hooks, err := New(context.Background(), &Config{
+		Hook: []*Hook{
+			{
+				Event: []string{"user"},
+				Url:   server.URL,
+			},
+		},
+	})
+	common.Must(err)
+
+	hooks.Fire(EventHandlerStart, map[string]string{"tag": "in"})
+	hooks.Fire(EventUserAdd, map[string]string{"tag": "in", "email": "love@example.com"})
+
+	select {
+	case payload := <-events:
+		if payload["event"] != EventUserAdd || payload["email"] != "love@example.com" || payload["tag"] != "in" {
+			t.Error("unexpected payload: ", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+
+	select {
+	case payload := <-events:
+		t.Error("unexpected event: ", payload)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestCertErrorFromTransport(t *testing.T) {
+	events := make(chan map[string]string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		common.Must(json.NewDecoder(r.Body).Decode(&payload))
+		events <- payload
+	}))
+	defer server.Close()
+
+	hooks, err := New(context.Background(), &Config{
+		Hook: []*Hook{
+			{
+				Event: []string{EventCertError},
+				Url:   server.URL,
+			},
+		},
+	})
+	common.Must(err)
+	common.Must(hooks.Start())
+	defer hooks.Close()
+
+	internet.ReportCertError(map[string]string{"path": "/etc/xray/cert.pem", "error": "expired"})
+
+	select {
+	case payload := <-events:
+		if payload["event"] != EventCertError || payload["path"] != "/etc/xray/cert.pem" {
+			t.Error("unexpected payload: ", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
+
+func TestNilHooks(t *testing.T) {
+	var hooks *Hooks
+	hooks.Fire(EventHandlerStart, nil)
+	if FromInstance(nil) != nil {
+		t.Error("expected nil hooks")
+	}
+}
+
+func TestErrorThreshold(t *testing.T) {
+	events := make(chan map[string]string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		common.Must(json.NewDecoder(r.Body).Decode(&payload))
+		events <- payload
+	}))
+	defer server.Close()
+
+	hooks, err := New(context.Background(), &Config{
+		Hook: []*Hook{
+			{
+				Event: []string{"outbound"},
+				Url:   server.URL,
+			},
+		},
+		ErrorThreshold: 3,
+	})
+	common.Must(err)
+	common.Must(hooks.Start())
+	defer hooks.Close()
+
+	for i := 0; i < 5; i++ {
+		hooks.RecordError("proxy")
+	}
+	hooks.RecordError("direct")
+
+	select {
+	case payload := <-events:
+		if payload["event"] != EventOutboundErrors || payload["tag"] != "proxy" || payload["count"] != "3" {
+			t.Error("unexpected payload: ", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+
+	// fired once per window
+	select {
+	case payload := <-events:
+		t.Error("unexpected event: ", payload)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	Fire(EventOutboundDown, map[string]string{"tag": "direct"})
+	select {
+	case payload := <-events:
+		if payload["event"] != EventOutboundDown || payload["tag"] != "direct" {
+			t.Error("unexpected payload: ", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
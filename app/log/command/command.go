@@ -7,6 +7,7 @@ import (
 
 	"github.com/xtls/xray-core/app/log"
 	"github.com/xtls/xray-core/common"
+	clog "github.com/xtls/xray-core/common/log"
 	"github.com/xtls/xray-core/core"
 	grpc "google.golang.org/grpc"
 )
@@ -30,6 +31,29 @@ func (s *LoggerServer) RestartLogger(ctx context.Context, request *RestartLogger
 	return &RestartLoggerResponse{}, nil
 }
 
+// GetRecentLogs implements LoggerService.
+func (s *LoggerServer) GetRecentLogs(ctx context.Context, request *GetRecentLogsRequest) (*GetRecentLogsResponse, error) {
+	history := clog.History()
+	if history == nil {
+		return nil, newError("log history is not kept, see historySize in log settings")
+	}
+	response := new(GetRecentLogsResponse)
+	for _, entry := range history {
+		if request.Level != clog.Severity_Unknown && (entry.Severity == clog.Severity_Unknown || entry.Severity > request.Level) {
+			continue
+		}
+		response.Entry = append(response.Entry, &LogEntry{
+			Time:     entry.Time.UnixMilli(),
+			Severity: entry.Severity,
+			Message:  entry.Message,
+		})
+	}
+	if limit := int(request.Limit); limit > 0 && len(response.Entry) > limit {
+		response.Entry = response.Entry[len(response.Entry)-limit:]
+	}
+	return response, nil
+}
+
 func (s *LoggerServer) mustEmbedUnimplementedLoggerServiceServer() {}
 
 type service struct {
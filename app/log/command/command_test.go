@@ -11,6 +11,7 @@ import (
 	_ "github.com/xtls/xray-core/app/proxyman/inbound"
 	_ "github.com/xtls/xray-core/app/proxyman/outbound"
 	"github.com/xtls/xray-core/common"
+	clog "github.com/xtls/xray-core/common/log"
 	"github.com/xtls/xray-core/common/serial"
 	"github.com/xtls/xray-core/core"
 )
@@ -32,3 +33,40 @@ func TestLoggerRestart(t *testing.T) {
 	}
 	common.Must2(server.RestartLogger(context.Background(), &RestartLoggerRequest{}))
 }
+
+func TestGetRecentLogs(t *testing.T) {
+	v, err := core.New(&core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&log.Config{HistorySize: 100}),
+			serial.ToTypedMessage(&dispatcher.Config{}),
+			serial.ToTypedMessage(&proxyman.InboundConfig{}),
+			serial.ToTypedMessage(&proxyman.OutboundConfig{}),
+		},
+	})
+	common.Must(err)
+	common.Must(v.Start())
+	defer v.Close()
+
+	clog.Record(&clog.GeneralMessage{Severity: clog.Severity_Warning, Content: "upstream down"})
+	clog.Record(&clog.GeneralMessage{Severity: clog.Severity_Debug, Content: "noise"})
+	clog.Record(&clog.GeneralMessage{Severity: clog.Severity_Error, Content: "upstream failed"})
+
+	server := &LoggerServer{
+		V: v,
+	}
+	resp, err := server.GetRecentLogs(context.Background(), &GetRecentLogsRequest{
+		Limit: 1,
+		Level: clog.Severity_Warning,
+	})
+	common.Must(err)
+	if len(resp.Entry) != 1 || resp.Entry[0].Message != "[Error] upstream failed" || resp.Entry[0].Severity != clog.Severity_Error {
+		t.Error("unexpected entries: ", resp.Entry)
+	}
+
+	resp, err = server.GetRecentLogs(context.Background(), &GetRecentLogsRequest{})
+	common.Must(err)
+	// Debug messages are not kept by default
+	if n := len(resp.Entry); n < 2 || resp.Entry[n-2].Message != "[Warning] upstream down" {
+		t.Error("unexpected entries: ", resp.Entry)
+	}
+}
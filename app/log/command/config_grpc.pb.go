@@ -23,6 +23,7 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type LoggerServiceClient interface {
 	RestartLogger(ctx context.Context, in *RestartLoggerRequest, opts ...grpc.CallOption) (*RestartLoggerResponse, error)
+	GetRecentLogs(ctx context.Context, in *GetRecentLogsRequest, opts ...grpc.CallOption) (*GetRecentLogsResponse, error)
 }
 
 type loggerServiceClient struct {
@@ -42,11 +43,21 @@ func (c *loggerServiceClient) RestartLogger(ctx context.Context, in *RestartLogg
 	return out, nil
 }
 
+func (c *loggerServiceClient) GetRecentLogs(ctx context.Context, in *GetRecentLogsRequest, opts ...grpc.CallOption) (*GetRecentLogsResponse, error) {
+	out := new(GetRecentLogsResponse)
+	err := c.cc.Invoke(ctx, "/xray.app.log.command.LoggerService/GetRecentLogs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // LoggerServiceServer is the server API for LoggerService service.
 // All implementations must embed UnimplementedLoggerServiceServer
 // for forward compatibility
 type LoggerServiceServer interface {
 	RestartLogger(context.Context, *RestartLoggerRequest) (*RestartLoggerResponse, error)
+	GetRecentLogs(context.Context, *GetRecentLogsRequest) (*GetRecentLogsResponse, error)
 	mustEmbedUnimplementedLoggerServiceServer()
 }
 
@@ -57,6 +68,9 @@ type UnimplementedLoggerServiceServer struct {
 func (UnimplementedLoggerServiceServer) RestartLogger(context.Context, *RestartLoggerRequest) (*RestartLoggerResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RestartLogger not implemented")
 }
+func (UnimplementedLoggerServiceServer) GetRecentLogs(context.Context, *GetRecentLogsRequest) (*GetRecentLogsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRecentLogs not implemented")
+}
 func (UnimplementedLoggerServiceServer) mustEmbedUnimplementedLoggerServiceServer() {}
 
 // UnsafeLoggerServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -88,6 +102,24 @@ func _LoggerService_RestartLogger_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _LoggerService_GetRecentLogs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRecentLogsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LoggerServiceServer).GetRecentLogs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/xray.app.log.command.LoggerService/GetRecentLogs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LoggerServiceServer).GetRecentLogs(ctx, req.(*GetRecentLogsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // LoggerService_ServiceDesc is the grpc.ServiceDesc for LoggerService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -99,6 +131,10 @@ var LoggerService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RestartLogger",
 			Handler:    _LoggerService_RestartLogger_Handler,
 		},
+		{
+			MethodName: "GetRecentLogs",
+			Handler:    _LoggerService_GetRecentLogs_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "app/log/command/config.proto",
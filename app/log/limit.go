@@ -0,0 +1,153 @@
+package log
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/log"
+	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/common/task"
+)
+
+type dedupKey struct {
+	severity log.Severity
+	content  string
+}
+
+// limitHandler collapses identical error logs within a window and limits the number of lines per second of
+// each severity, so a flapping upstream cannot flood the log.
+type limitHandler struct {
+	handler log.Handler
+	window  time.Duration
+	limits  map[log.Severity]uint32
+
+	access   sync.Mutex
+	repeated map[dedupKey]uint64
+	second   int64
+	lines    map[log.Severity]uint32
+	dropped  map[log.Severity]uint64
+	flush    *task.Periodic
+}
+
+// newLimitHandler wraps the handler with the deduplication and rate limits of the config, if it has any.
+func newLimitHandler(handler log.Handler, config *Config) log.Handler {
+	limits := make(map[log.Severity]uint32)
+	if r := config.RateLimit; r != nil {
+		for severity, limit := range map[log.Severity]uint32{
+			log.Severity_Error:   r.Error,
+			log.Severity_Warning: r.Warning,
+			log.Severity_Info:    r.Info,
+			log.Severity_Debug:   r.Debug,
+		} {
+			if limit > 0 {
+				limits[severity] = limit
+			}
+		}
+	}
+	if config.DedupWindow == 0 && len(limits) == 0 {
+		return handler
+	}
+	h := &limitHandler{
+		handler:  handler,
+		window:   time.Duration(config.DedupWindow) * time.Second,
+		limits:   limits,
+		repeated: make(map[dedupKey]uint64),
+		lines:    make(map[log.Severity]uint32),
+		dropped:  make(map[log.Severity]uint64),
+	}
+	interval := h.window
+	if interval == 0 {
+		interval = time.Second
+	}
+	h.flush = &task.Periodic{
+		Interval: interval,
+		Execute:  h.flushSummary,
+	}
+	common.Must(h.flush.Start())
+	return h
+}
+
+// trimSessionID removes the session IDs an error is prefixed with, so errors of different sessions collapse.
+func trimSessionID(content string) string {
+	for strings.HasPrefix(content, "[") {
+		i := strings.Index(content, "] ")
+		if i < 0 {
+			break
+		}
+		content = content[i+2:]
+	}
+	return content
+}
+
+// Handle implements log.Handler.
+func (h *limitHandler) Handle(msg log.Message) {
+	m, ok := msg.(*log.GeneralMessage)
+	if !ok {
+		h.handler.Handle(msg)
+		return
+	}
+
+	h.access.Lock()
+	var key dedupKey
+	if h.window > 0 {
+		key = dedupKey{severity: m.Severity, content: trimSessionID(serial.ToString(m.Content))}
+		if n, found := h.repeated[key]; found {
+			h.repeated[key] = n + 1
+			h.access.Unlock()
+			return
+		}
+	}
+	if limit, found := h.limits[m.Severity]; found {
+		if now := time.Now().Unix(); now != h.second {
+			h.second = now
+			h.lines = make(map[log.Severity]uint32)
+		}
+		if h.lines[m.Severity] >= limit {
+			h.dropped[m.Severity]++
+			h.access.Unlock()
+			return
+		}
+		h.lines[m.Severity]++
+	}
+	if h.window > 0 {
+		h.repeated[key] = 0
+	}
+	h.access.Unlock()
+
+	h.handler.Handle(msg)
+}
+
+// flushSummary logs how often the errors of the last window repeated, and how many lines were dropped.
+func (h *limitHandler) flushSummary() error {
+	h.access.Lock()
+	repeated := h.repeated
+	dropped := h.dropped
+	h.repeated = make(map[dedupKey]uint64)
+	h.dropped = make(map[log.Severity]uint64)
+	h.access.Unlock()
+
+	for key, n := range repeated {
+		if n > 0 {
+			h.handler.Handle(&log.GeneralMessage{
+				Severity: key.severity,
+				Content:  serial.Concat(key.content, " (repeated ", n, " more times in ", h.window, ")"),
+			})
+		}
+	}
+	for severity, n := range dropped {
+		h.handler.Handle(&log.GeneralMessage{
+			Severity: log.Severity_Warning,
+			Content:  serial.Concat("dropped ", n, " ", severity, " log lines over the rate limit"),
+		})
+	}
+	return nil
+}
+
+// Close implements common.Closable.
+func (h *limitHandler) Close() error {
+	h.flush.Close()
+	h.flushSummary()
+	return common.Close(h.handler)
+}
@@ -28,6 +28,13 @@ func New(ctx context.Context, config *Config) (*Instance, error) {
 		dns:    config.EnableDnsLog,
 	}
 	log.RegisterHandler(g)
+	if config.HistorySize > 0 {
+		level := config.HistoryLevel
+		if level == log.Severity_Unknown {
+			level = log.Severity_Info
+		}
+		log.KeepHistory(int(config.HistorySize), level)
+	}
 
 	// start logger instantly on inited
 	// other modules would log during init
@@ -57,7 +64,7 @@ func (g *Instance) initErrorLogger() error {
 	if err != nil {
 		return err
 	}
-	g.errorLogger = handler
+	g.errorLogger = newLimitHandler(handler, g.config)
 	return nil
 }
 
@@ -2,9 +2,12 @@ package log_test
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/golang/mock/gomock"
+	"github.com/google/go-cmp/cmp"
 	"github.com/xtls/xray-core/app/log"
 	"github.com/xtls/xray-core/common"
 	clog "github.com/xtls/xray-core/common/log"
@@ -50,3 +53,58 @@ func TestCustomLogHandler(t *testing.T) {
 
 	common.Must(logger.Close())
 }
+
+func TestLogDedupAndRateLimit(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+
+	var access sync.Mutex
+	var loggedValue []string
+
+	mockHandler := mocks.NewLogHandler(mockCtl)
+	mockHandler.EXPECT().Handle(gomock.Any()).AnyTimes().DoAndReturn(func(msg clog.Message) {
+		access.Lock()
+		defer access.Unlock()
+		loggedValue = append(loggedValue, msg.String())
+	})
+
+	log.RegisterHandlerCreator(log.LogType_Console, func(lt log.LogType, options log.HandlerCreatorOptions) (clog.Handler, error) {
+		return mockHandler, nil
+	})
+
+	logger, err := log.New(context.Background(), &log.Config{
+		ErrorLogLevel: clog.Severity_Info,
+		ErrorLogType:  log.LogType_Console,
+		AccessLogType: log.LogType_None,
+		DedupWindow:   60,
+		RateLimit:     &log.RateLimit{Info: 2},
+	})
+	common.Must(err)
+
+	for i := 0; i < 5; i++ {
+		clog.Record(&clog.GeneralMessage{
+			Severity: clog.Severity_Warning,
+			Content:  fmt.Sprint("[", i, "] failed to dial"),
+		})
+	}
+	for i := 0; i < 5; i++ {
+		clog.Record(&clog.GeneralMessage{
+			Severity: clog.Severity_Info,
+			Content:  fmt.Sprint("line ", i),
+		})
+	}
+	common.Must(logger.Close())
+
+	access.Lock()
+	defer access.Unlock()
+	expected := []string{
+		"[Warning] [0] failed to dial",
+		"[Info] line 0",
+		"[Info] line 1",
+		"[Warning] failed to dial (repeated 4 more times in 1m0s)",
+		"[Warning] dropped 3 Info log lines over the rate limit",
+	}
+	if diff := cmp.Diff(expected, loggedValue); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -10,12 +10,15 @@ import (
 	"github.com/xtls/xray-core/app/observatory"
 	"github.com/xtls/xray-core/app/stats"
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/signal/done"
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/extension"
 	"github.com/xtls/xray-core/features/outbound"
 	feature_stats "github.com/xtls/xray-core/features/stats"
+	"github.com/xtls/xray-core/transport/internet/tls"
 )
 
 type MetricsHandler struct {
@@ -58,6 +61,21 @@ func NewMetricsHandler(ctx context.Context, config *Config) (*MetricsHandler, er
 		})
 		return resp
 	}))
+	expvar.Publish("crypto", expvar.Func(func() interface{} {
+		return map[string]interface{}{
+			"aesHardware":    protocol.HasAESGCMHardwareSupport(),
+			"preferChaCha20": protocol.PreferChaCha20(),
+			"vmessAuto":      (*protocol.SecurityConfig)(nil).GetSecurityType().String(),
+			"tlsSuites":      tls.CipherSuiteStats(),
+		}
+	}))
+	expvar.Publish("buf", expvar.Func(func() interface{} {
+		return map[string]interface{}{
+			"size":    buf.Size,
+			"maxRead": buf.MaxReadSize(),
+			"readv":   buf.ReadvEnabled(),
+		}
+	}))
 	expvar.Publish("observatory", expvar.Func(func() interface{} {
 		if c.observatory == nil {
 			common.Must(core.RequireFeatures(ctx, func(observatory extension.Observatory) error {
@@ -24,3 +24,11 @@ func (e *errorCollector) UnderlyingError() error {
 	}
 	return e.errors
 }
+
+// Class returns the class of the errors reported by the outbound, or empty if there is none.
+func (e *errorCollector) Class() errors.Class {
+	if e.errors == nil {
+		return ""
+	}
+	return errors.ClassOf(e.errors)
+}
@@ -10,7 +10,9 @@ import (
 	"time"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/xtls/xray-core/app/hook"
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
 	v2net "github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/common/signal/done"
@@ -21,6 +23,11 @@ import (
 	"github.com/xtls/xray-core/transport/internet/tagged"
 )
 
+const (
+	defaultProbeInterval = 10 * time.Second
+	probeTimeout         = 5 * time.Second
+)
+
 type Observer struct {
 	config *Config
 	ctx    context.Context
@@ -34,7 +41,37 @@ type Observer struct {
 }
 
 func (o *Observer) GetObservation(ctx context.Context) (proto.Message, error) {
-	return &ObservationResult{Status: o.status}, nil
+	o.statusLock.Lock()
+	defer o.statusLock.Unlock()
+	// copied, as the status is updated in place by the probes
+	status := make([]*OutboundStatus, 0, len(o.status))
+	for _, v := range o.status {
+		status = append(status, proto.Clone(v).(*OutboundStatus))
+	}
+	return &ObservationResult{Status: status}, nil
+}
+
+// ProbeCycle returns how long probing every outbound under observation once takes at most. Without
+// concurrency, the outbounds are probed one after another.
+func (o *Observer) ProbeCycle() time.Duration {
+	interval := o.probeInterval()
+	if o.config.EnableConcurrency {
+		return interval + probeTimeout
+	}
+	o.statusLock.Lock()
+	n := len(o.status)
+	o.statusLock.Unlock()
+	if n == 0 {
+		n = 1
+	}
+	return time.Duration(n) * (interval + probeTimeout)
+}
+
+func (o *Observer) probeInterval() time.Duration {
+	if o.config.ProbeInterval != 0 {
+		return time.Duration(o.config.ProbeInterval)
+	}
+	return defaultProbeInterval
 }
 
 func (o *Observer) Type() interface{} {
@@ -68,10 +105,7 @@ func (o *Observer) background() {
 
 		o.updateStatus(outbounds)
 
-		sleepTime := time.Second * 10
-		if o.config.ProbeInterval != 0 {
-			sleepTime = time.Duration(o.config.ProbeInterval)
-		}
+		sleepTime := o.probeInterval()
 
 		if !o.config.EnableConcurrency {
 			sort.Strings(outbounds)
@@ -142,7 +176,7 @@ func (o *Observer) probe(outbound string) ProbeResult {
 			}
 			return connection, nil
 		},
-		TLSHandshakeTimeout: time.Second * 5,
+		TLSHandshakeTimeout: probeTimeout,
 	}
 	httpClient := &http.Client{
 		Transport: &httpTransport,
@@ -150,7 +184,7 @@ func (o *Observer) probe(outbound string) ProbeResult {
 			return http.ErrUseLastResponse
 		},
 		Jar:     nil,
-		Timeout: time.Second * 5,
+		Timeout: probeTimeout,
 	}
 	var GETTime time.Duration
 	err := task.Run(o.ctx, func() error {
@@ -171,13 +205,17 @@ func (o *Observer) probe(outbound string) ProbeResult {
 		return nil
 	})
 	if err != nil {
+		class := errorCollectorForRequest.Class()
+		if class == "" {
+			class = errors.ClassOf(err)
+		}
 		fullerr := newError("underlying connection failed").Base(errorCollectorForRequest.UnderlyingError())
 		fullerr = newError("with outbound handler report").Base(fullerr)
 		fullerr = newError("GET request failed:", err).Base(fullerr)
 		fullerr = newError("the outbound ", outbound, " is dead:").Base(fullerr)
 		fullerr = fullerr.AtInfo()
 		fullerr.WriteToLog()
-		return ProbeResult{Alive: false, LastErrorReason: fullerr.Error()}
+		return ProbeResult{Alive: false, LastErrorReason: fullerr.Error(), LastErrorClass: string(class)}
 	}
 	newError("the outbound ", outbound, " is alive:", GETTime.Seconds()).AtInfo().WriteToLog()
 	return ProbeResult{Alive: true, Delay: GETTime.Milliseconds()}
@@ -187,12 +225,18 @@ func (o *Observer) updateStatusForResult(outbound string, result *ProbeResult) {
 	o.statusLock.Lock()
 	defer o.statusLock.Unlock()
 	var status *OutboundStatus
+	// a new outbound is assumed alive, so that it is reported if the first probe fails
+	wasAlive := true
 	if location := o.findStatusLocationLockHolderOnly(outbound); location != -1 {
 		status = o.status[location]
+		wasAlive = status.Alive
 	} else {
 		status = &OutboundStatus{}
 		o.status = append(o.status, status)
 	}
+	if wasAlive != result.Alive {
+		o.fireStatusChange(outbound, result)
+	}
 
 	status.LastTryTime = time.Now().Unix()
 	status.OutboundTag = outbound
@@ -201,12 +245,29 @@ func (o *Observer) updateStatusForResult(outbound string, result *ProbeResult) {
 		status.Delay = result.Delay
 		status.LastSeenTime = status.LastTryTime
 		status.LastErrorReason = ""
+		status.LastErrorClass = ""
 	} else {
 		status.LastErrorReason = result.LastErrorReason
+		status.LastErrorClass = result.LastErrorClass
 		status.Delay = 99999999
 	}
 }
 
+func (o *Observer) fireStatusChange(outbound string, result *ProbeResult) {
+	hooks := hook.FromInstance(core.FromContext(o.ctx))
+	if result.Alive {
+		hooks.Fire(hook.EventOutboundUp, map[string]string{
+			"tag": outbound,
+		})
+	} else {
+		hooks.Fire(hook.EventOutboundDown, map[string]string{
+			"tag":    outbound,
+			"reason": result.LastErrorReason,
+			"class":  result.LastErrorClass,
+		})
+	}
+}
+
 func (o *Observer) findStatusLocationLockHolderOnly(outbound string) int {
 	for i, v := range o.status {
 		if v.OutboundTag == outbound {
@@ -0,0 +1,71 @@
+package policy
+
+import (
+	"github.com/xtls/xray-core/app/router"
+	"github.com/xtls/xray-core/features/routing"
+)
+
+// destinationACL implements policy.DestinationACL with router matchers.
+type destinationACL struct {
+	allowed []router.Condition
+	denied  []router.Condition
+}
+
+func newConditions(domains []*router.Domain, ips []*router.GeoIP) ([]router.Condition, error) {
+	var conds []router.Condition
+	if len(domains) > 0 {
+		matcher, err := router.NewMphMatcherGroup(domains)
+		if err != nil {
+			return nil, newError("failed to build domain condition").Base(err)
+		}
+		conds = append(conds, matcher)
+	}
+	if len(ips) > 0 {
+		matcher, err := router.NewMultiGeoIPMatcher(ips, false)
+		if err != nil {
+			return nil, newError("failed to build ip condition").Base(err)
+		}
+		conds = append(conds, matcher)
+	}
+	return conds, nil
+}
+
+// buildACL builds the destination ACL. Nil if no restriction is configured.
+func (p *Policy_Destination) buildACL() (*destinationACL, error) {
+	if p == nil {
+		return nil, nil
+	}
+	allowed, err := newConditions(p.AllowedDomain, p.AllowedIp)
+	if err != nil {
+		return nil, err
+	}
+	denied, err := newConditions(p.DeniedDomain, p.DeniedIp)
+	if err != nil {
+		return nil, err
+	}
+	if len(allowed) == 0 && len(denied) == 0 {
+		return nil, nil
+	}
+	return &destinationACL{
+		allowed: allowed,
+		denied:  denied,
+	}, nil
+}
+
+// Allowed implements policy.DestinationACL.
+func (a *destinationACL) Allowed(ctx routing.Context) bool {
+	for _, cond := range a.denied {
+		if cond.Apply(ctx) {
+			return false
+		}
+	}
+	if len(a.allowed) == 0 {
+		return true
+	}
+	for _, cond := range a.allowed {
+		if cond.Apply(ctx) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package command
+
+//go:generate go run github.com/xtls/xray-core/common/errors/errorgen
+
+import (
+	"context"
+
+	"github.com/xtls/xray-core/app/policy"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/core"
+	feature_policy "github.com/xtls/xray-core/features/policy"
+	grpc "google.golang.org/grpc"
+)
+
+// policyServer is an implementation of PolicyService.
+type policyServer struct {
+	manager feature_policy.Manager
+}
+
+func NewPolicyServer(manager feature_policy.Manager) PolicyServiceServer {
+	return &policyServer{
+		manager: manager,
+	}
+}
+
+func (s *policyServer) instance() (*policy.Instance, error) {
+	instance, ok := s.manager.(*policy.Instance)
+	if !ok {
+		return nil, newError("policy is not configured")
+	}
+	return instance, nil
+}
+
+// GetPolicy implements PolicyService.
+func (s *policyServer) GetPolicy(ctx context.Context, request *GetPolicyRequest) (*GetPolicyResponse, error) {
+	instance, err := s.instance()
+	if err != nil {
+		return nil, err
+	}
+	return &GetPolicyResponse{Config: instance.Config()}, nil
+}
+
+// SetLevelPolicy implements PolicyService.
+func (s *policyServer) SetLevelPolicy(ctx context.Context, request *SetLevelPolicyRequest) (*SetLevelPolicyResponse, error) {
+	instance, err := s.instance()
+	if err != nil {
+		return nil, err
+	}
+	if request.Policy == nil {
+		return nil, newError("no policy for level ", request.Level)
+	}
+	if err := instance.SetLevel(request.Level, request.Policy); err != nil {
+		return nil, err
+	}
+	newError("updated policy of level ", request.Level).AtInfo().WriteToLog()
+	return &SetLevelPolicyResponse{}, nil
+}
+
+// SetSystemPolicy implements PolicyService.
+func (s *policyServer) SetSystemPolicy(ctx context.Context, request *SetSystemPolicyRequest) (*SetSystemPolicyResponse, error) {
+	instance, err := s.instance()
+	if err != nil {
+		return nil, err
+	}
+	instance.SetSystem(request.System)
+	newError("updated system policy").AtInfo().WriteToLog()
+	return &SetSystemPolicyResponse{}, nil
+}
+
+func (s *policyServer) mustEmbedUnimplementedPolicyServiceServer() {}
+
+type service struct {
+	policyManager feature_policy.Manager
+}
+
+func (s *service) Register(server *grpc.Server) {
+	RegisterPolicyServiceServer(server, NewPolicyServer(s.policyManager))
+}
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, cfg interface{}) (interface{}, error) {
+		s := new(service)
+
+		core.RequireFeatures(ctx, func(pm feature_policy.Manager) {
+			s.policyManager = pm
+		})
+
+		return s, nil
+	}))
+}
@@ -43,6 +43,15 @@ func (p *Policy_Timeout) overrideWith(another *Policy_Timeout) {
 	if another.DownlinkOnly != nil {
 		p.DownlinkOnly = &Second{Value: another.DownlinkOnly.Value}
 	}
+	if another.UplinkIdle != nil {
+		p.UplinkIdle = &Second{Value: another.UplinkIdle.Value}
+	}
+	if another.DownlinkIdle != nil {
+		p.DownlinkIdle = &Second{Value: another.DownlinkIdle.Value}
+	}
+	if another.MaxLifetime != nil {
+		p.MaxLifetime = &Second{Value: another.MaxLifetime.Value}
+	}
 }
 
 func (p *Policy) overrideWith(another *Policy) {
@@ -58,6 +67,12 @@ func (p *Policy) overrideWith(another *Policy) {
 			Connection: another.Buffer.Connection,
 		}
 	}
+	if another.Destination != nil {
+		p.Destination = another.Destination
+	}
+	if another.KeepAlive != nil {
+		p.KeepAlive = another.KeepAlive
+	}
 }
 
 // ToCorePolicy converts this Policy to policy.Session.
@@ -69,14 +84,21 @@ func (p *Policy) ToCorePolicy() policy.Session {
 		cp.Timeouts.Handshake = p.Timeout.Handshake.Duration()
 		cp.Timeouts.DownlinkOnly = p.Timeout.DownlinkOnly.Duration()
 		cp.Timeouts.UplinkOnly = p.Timeout.UplinkOnly.Duration()
+		cp.Timeouts.UplinkIdle = p.Timeout.UplinkIdle.Duration()
+		cp.Timeouts.DownlinkIdle = p.Timeout.DownlinkIdle.Duration()
+		cp.Timeouts.MaxLifetime = p.Timeout.MaxLifetime.Duration()
 	}
 	if p.Stats != nil {
 		cp.Stats.UserUplink = p.Stats.UserUplink
 		cp.Stats.UserDownlink = p.Stats.UserDownlink
+		cp.Stats.UserLogin = p.Stats.UserLogin
 	}
 	if p.Buffer != nil {
 		cp.Buffer.PerConnection = p.Buffer.Connection
 	}
+	if p.KeepAlive != nil {
+		cp.KeepAlive.Mirror = p.KeepAlive.Mirror
+	}
 	return cp
 }
 
@@ -88,6 +110,7 @@ func (p *SystemPolicy) ToCorePolicy() policy.System {
 			InboundDownlink:  p.Stats.InboundDownlink,
 			OutboundUplink:   p.Stats.OutboundUplink,
 			OutboundDownlink: p.Stats.OutboundDownlink,
+			InboundProtocol:  p.Stats.InboundProtocol,
 		},
 	}
 }
@@ -2,14 +2,19 @@ package policy
 
 import (
 	"context"
+	"sync"
 
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/features/policy"
+	"google.golang.org/protobuf/proto"
 )
 
 // Instance is an instance of Policy manager.
 type Instance struct {
+	access sync.RWMutex
 	levels map[uint32]*Policy
+	acls   map[uint32]policy.DestinationACL
 	system *SystemPolicy
 }
 
@@ -17,19 +22,81 @@ type Instance struct {
 func New(ctx context.Context, config *Config) (*Instance, error) {
 	m := &Instance{
 		levels: make(map[uint32]*Policy),
+		acls:   make(map[uint32]policy.DestinationACL),
 		system: config.System,
 	}
-	if len(config.Level) > 0 {
-		for lv, p := range config.Level {
-			pp := defaultPolicy()
-			pp.overrideWith(p)
-			m.levels[lv] = pp
+	applyPreferredCipher(config.System)
+	for lv, p := range config.Level {
+		if err := m.SetLevel(lv, p); err != nil {
+			return nil, err
 		}
 	}
 
 	return m, nil
 }
 
+// SetLevel replaces the policy of the level, where unset parts take the defaults. Sessions started before keep
+// the policy they got.
+func (m *Instance) SetLevel(level uint32, p *Policy) error {
+	pp := defaultPolicy()
+	pp.overrideWith(p)
+	acl, err := pp.Destination.buildACL()
+	if err != nil {
+		return newError("failed to build destination ACL for level ", level).Base(err)
+	}
+
+	m.access.Lock()
+	defer m.access.Unlock()
+	m.levels[level] = pp
+	if acl != nil {
+		m.acls[level] = acl
+	} else {
+		delete(m.acls, level)
+	}
+	return nil
+}
+
+// SetSystem replaces the system policy. Traffic counters of inbounds and outbounds are decided when the
+// handlers start, so changes to them only apply to handlers added afterwards.
+func (m *Instance) SetSystem(p *SystemPolicy) {
+	if p != nil && p.Stats == nil {
+		p = proto.Clone(p).(*SystemPolicy)
+		p.Stats = &SystemPolicy_Stats{}
+	}
+
+	applyPreferredCipher(p)
+
+	m.access.Lock()
+	defer m.access.Unlock()
+	m.system = p
+}
+
+func applyPreferredCipher(p *SystemPolicy) {
+	switch p.GetPreferredCipher() {
+	case SystemPolicy_CHACHA20_POLY1305:
+		protocol.SetPreferChaCha20(true)
+	case SystemPolicy_AES_GCM:
+		protocol.SetPreferChaCha20(false)
+	default:
+		protocol.ResetPreferChaCha20()
+	}
+}
+
+// Config returns the policies in effect.
+func (m *Instance) Config() *Config {
+	m.access.RLock()
+	defer m.access.RUnlock()
+
+	config := &Config{
+		Level:  make(map[uint32]*Policy, len(m.levels)),
+		System: m.system,
+	}
+	for lv, p := range m.levels {
+		config.Level[lv] = p
+	}
+	return config
+}
+
 // Type implements common.HasType.
 func (*Instance) Type() interface{} {
 	return policy.ManagerType()
@@ -37,14 +104,22 @@ func (*Instance) Type() interface{} {
 
 // ForLevel implements policy.Manager.
 func (m *Instance) ForLevel(level uint32) policy.Session {
+	m.access.RLock()
+	defer m.access.RUnlock()
+
 	if p, ok := m.levels[level]; ok {
-		return p.ToCorePolicy()
+		cp := p.ToCorePolicy()
+		cp.ACL = m.acls[level]
+		return cp
 	}
 	return policy.SessionDefault()
 }
 
 // ForSystem implements policy.Manager.
 func (m *Instance) ForSystem() policy.System {
+	m.access.RLock()
+	defer m.access.RUnlock()
+
 	if m.system == nil {
 		return policy.System{}
 	}
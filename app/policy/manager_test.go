@@ -6,8 +6,13 @@ import (
 	"time"
 
 	. "github.com/xtls/xray-core/app/policy"
+	"github.com/xtls/xray-core/app/router"
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/features/policy"
+	routing_session "github.com/xtls/xray-core/features/routing/session"
 )
 
 func TestPolicy(t *testing.T) {
@@ -43,3 +48,124 @@ func TestPolicy(t *testing.T) {
 		}
 	}
 }
+
+func TestPolicyDestinationACL(t *testing.T) {
+	manager, err := New(context.Background(), &Config{
+		Level: map[uint32]*Policy{
+			1: {
+				Destination: &Policy_Destination{
+					AllowedDomain: []*router.Domain{
+						{Type: router.Domain_Domain, Value: "example.com"},
+					},
+					DeniedDomain: []*router.Domain{
+						{Type: router.Domain_Full, Value: "blocked.example.com"},
+					},
+				},
+			},
+		},
+	})
+	common.Must(err)
+
+	if acl := manager.ForLevel(0).ACL; acl != nil {
+		t.Error("expect no ACL for level 0")
+	}
+
+	acl := manager.ForLevel(1).ACL
+	for _, test := range []struct {
+		domain string
+		allow  bool
+	}{
+		{"www.example.com", true},
+		{"blocked.example.com", false},
+		{"www.example.org", false},
+	} {
+		ctx := session.ContextWithOutbound(context.Background(), &session.Outbound{
+			Target: net.TCPDestination(net.DomainAddress(test.domain), 443),
+		})
+		if r := acl.Allowed(routing_session.AsRoutingContext(ctx)); r != test.allow {
+			t.Error("for ", test.domain, " expect ", test.allow, " but got ", r)
+		}
+	}
+}
+
+func TestPolicyUpdate(t *testing.T) {
+	manager, err := New(context.Background(), &Config{
+		Level: map[uint32]*Policy{
+			0: {
+				Destination: &Policy_Destination{
+					DeniedDomain: []*router.Domain{
+						{Type: router.Domain_Full, Value: "blocked.example.com"},
+					},
+				},
+			},
+		},
+	})
+	common.Must(err)
+	if manager.ForLevel(0).ACL == nil {
+		t.Fatal("expect destination ACL of level 0")
+	}
+
+	common.Must(manager.SetLevel(0, &Policy{
+		Timeout: &Policy_Timeout{
+			ConnectionIdle: &Second{Value: 30},
+		},
+		Buffer: &Policy_Buffer{Connection: 1024},
+	}))
+	p := manager.ForLevel(0)
+	if p.Timeouts.ConnectionIdle != 30*time.Second {
+		t.Error("expect 30 sec idle timeout, but got ", p.Timeouts.ConnectionIdle)
+	}
+	if p.Timeouts.Handshake != policy.SessionDefault().Timeouts.Handshake {
+		t.Error("expect default handshake timeout, but got ", p.Timeouts.Handshake)
+	}
+	if p.Buffer.PerConnection != 1024 {
+		t.Error("expect 1024 bytes buffer, but got ", p.Buffer.PerConnection)
+	}
+	if p.ACL != nil {
+		t.Error("expect destination ACL to be removed")
+	}
+
+	manager.SetSystem(&SystemPolicy{
+		Stats: &SystemPolicy_Stats{InboundUplink: true},
+	})
+	if !manager.ForSystem().Stats.InboundUplink {
+		t.Error("expect inbound uplink stats")
+	}
+	if c := manager.Config(); c.Level[0].Buffer.Connection != 1024 || !c.System.Stats.InboundUplink {
+		t.Error("unexpected config: ", c)
+	}
+}
+
+func TestPolicyPreferredCipher(t *testing.T) {
+	defer protocol.ResetPreferChaCha20()
+
+	manager, err := New(context.Background(), &Config{
+		System: &SystemPolicy{PreferredCipher: SystemPolicy_CHACHA20_POLY1305},
+	})
+	common.Must(err)
+	if !protocol.PreferChaCha20() {
+		t.Error("expected ChaCha20-Poly1305 to be preferred")
+	}
+
+	manager.SetSystem(&SystemPolicy{PreferredCipher: SystemPolicy_AES_GCM})
+	if protocol.PreferChaCha20() {
+		t.Error("expected AES-GCM to be preferred")
+	}
+
+	system := &SystemPolicy{PreferredCipher: SystemPolicy_CHACHA20_POLY1305}
+	manager.SetSystem(system)
+	if !protocol.PreferChaCha20() {
+		t.Error("expected ChaCha20-Poly1305 to be preferred without stats")
+	}
+	if c := manager.Config(); c.System.PreferredCipher != SystemPolicy_CHACHA20_POLY1305 || c.System.Stats == nil {
+		t.Error("unexpected system policy: ", c.System)
+	}
+	if system.Stats != nil {
+		t.Error("expected the given policy to be left unchanged")
+	}
+
+	manager.SetSystem(nil)
+	if protocol.PreferChaCha20() != !protocol.HasAESGCMHardwareSupport() {
+		t.Error("expected the CPU to decide again")
+	}
+}
@@ -3,7 +3,11 @@ package command
 import (
 	"context"
 
+	"github.com/xtls/xray-core/app/hook"
+	"github.com/xtls/xray-core/app/proxyman"
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/inbound"
 	"github.com/xtls/xray-core/features/outbound"
@@ -61,6 +65,70 @@ func (op *RemoveUserOperation) ApplyInbound(ctx context.Context, handler inbound
 	return um.RemoveUser(ctx, op.Email)
 }
 
+// ApplyInbound implements InboundOperation.
+func (op *SetMaintenanceOperation) ApplyInbound(ctx context.Context, handler inbound.Handler) error {
+	h, ok := handler.(interface {
+		SetMaintenance(*proxyman.MaintenanceConfig)
+	})
+	if !ok {
+		return newError("inbound doesn't support maintenance")
+	}
+	h.SetMaintenance(op.Maintenance)
+	return nil
+}
+
+// ApplyOutbound implements OutboundOperation.
+func (op *UpdateServerOperation) ApplyOutbound(ctx context.Context, handler outbound.Handler) error {
+	gp, ok := handler.(proxy.GetOutbound)
+	if !ok {
+		return newError("can't get outbound proxy from handler.")
+	}
+	sl, ok := gp.GetOutbound().(proxy.GetServerList)
+	if !ok {
+		return newError("proxy has no servers")
+	}
+	users := make([]*protocol.MemoryUser, 0, len(op.User))
+	for _, u := range op.User {
+		mUser, err := u.ToMemoryUser()
+		if err != nil {
+			return newError("failed to parse user").Base(err)
+		}
+		users = append(users, mUser)
+	}
+
+	list := sl.GetServerList()
+	if op.ServerAddress == nil && op.ServerPort == 0 && list.Size() > 1 {
+		return newError("outbound has more than one server, which requires the server to update")
+	}
+	n := list.Update(func(server *protocol.ServerSpec) *protocol.ServerSpec {
+		dest := server.Destination()
+		if op.ServerAddress != nil && dest.Address != op.ServerAddress.AsAddress() {
+			return nil
+		}
+		if op.ServerPort != 0 && dest.Port != net.Port(op.ServerPort) {
+			return nil
+		}
+		if op.Address != nil {
+			dest.Address = op.Address.AsAddress()
+		}
+		if op.Port != 0 {
+			dest.Port = net.Port(op.Port)
+		}
+		serverUsers := users
+		if len(serverUsers) == 0 {
+			serverUsers = server.Users()
+		}
+		return protocol.NewServerSpec(dest, protocol.AlwaysValid(), serverUsers...)
+	})
+	if n == 0 {
+		return newError("server not found")
+	}
+	if d, ok := handler.(interface{ DrainConnections() }); ok {
+		d.DrainConnections()
+	}
+	return nil
+}
+
 type handlerServer struct {
 	s   *core.Instance
 	ihm inbound.Manager
@@ -94,7 +162,16 @@ func (s *handlerServer) AlterInbound(ctx context.Context, request *AlterInboundR
 		return nil, newError("failed to get handler: ", request.Tag).Base(err)
 	}
 
-	return &AlterInboundResponse{}, operation.ApplyInbound(ctx, handler)
+	if err := operation.ApplyInbound(ctx, handler); err != nil {
+		return nil, err
+	}
+	switch op := operation.(type) {
+	case *AddUserOperation:
+		hook.FromInstance(s.s).Fire(hook.EventUserAdd, map[string]string{"tag": request.Tag, "email": op.User.GetEmail()})
+	case *RemoveUserOperation:
+		hook.FromInstance(s.s).Fire(hook.EventUserRemove, map[string]string{"tag": request.Tag, "email": op.Email})
+	}
+	return &AlterInboundResponse{}, nil
 }
 
 func (s *handlerServer) AddOutbound(ctx context.Context, request *AddOutboundRequest) (*AddOutboundResponse, error) {
@@ -119,6 +196,9 @@ func (s *handlerServer) AlterOutbound(ctx context.Context, request *AlterOutboun
 	}
 
 	handler := s.ohm.GetHandler(request.Tag)
+	if handler == nil {
+		return nil, newError("failed to get handler: ", request.Tag)
+	}
 	return &AlterOutboundResponse{}, operation.ApplyOutbound(ctx, handler)
 }
 
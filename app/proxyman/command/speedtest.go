@@ -0,0 +1,149 @@
+package command
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/core"
+)
+
+const (
+	defaultSpeedTestSize    = 10
+	maxSpeedTestSize        = 1024
+	defaultSpeedTestTimeout = 60
+)
+
+// outboundError keeps the error the outbound reported for the test connection.
+type outboundError struct {
+	sync.Mutex
+	err error
+}
+
+func (e *outboundError) SubmitError(err error) {
+	e.Lock()
+	e.err = err
+	e.Unlock()
+}
+
+// cause returns the error reported by the outbound if there is one, as it tells more than err.
+func (e *outboundError) cause(err error) error {
+	e.Lock()
+	defer e.Unlock()
+	if e.err != nil {
+		return e.err
+	}
+	return err
+}
+
+func speed(n uint64, d time.Duration) uint64 {
+	if d <= 0 {
+		return 0
+	}
+	return uint64(float64(n) / d.Seconds())
+}
+
+// SpeedTest sends data through the given outbound to an echo server, and measures how fast it comes back.
+func (s *handlerServer) SpeedTest(ctx context.Context, request *SpeedTestRequest) (*SpeedTestResponse, error) {
+	if s.ohm.GetHandler(request.OutboundTag) == nil {
+		return nil, newError("outbound not found: ", request.OutboundTag)
+	}
+	dest, err := net.ParseDestination("tcp:" + request.Destination)
+	if err != nil {
+		return nil, newError("invalid destination ", request.Destination).Base(err)
+	}
+	size := request.Size
+	if size == 0 {
+		size = defaultSpeedTestSize
+	}
+	if size > maxSpeedTestSize {
+		return nil, newError("size too large: ", size, "MB")
+	}
+	timeout := request.Timeout
+	if timeout == 0 {
+		timeout = defaultSpeedTestTimeout
+	}
+	total := uint64(size) << 20
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+	ctx = session.SetForcedOutboundTagToContext(ctx, request.OutboundTag)
+	tracker := &outboundError{}
+	ctx = session.TrackedConnectionError(ctx, tracker)
+
+	conn, err := core.Dial(ctx, s.s, dest)
+	if err != nil {
+		return nil, newError("failed to dial ", dest, " through ", request.OutboundTag).Base(err)
+	}
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	start := time.Now()
+	uploadDone := make(chan error, 1)
+	var uploadTime time.Duration
+	go func() {
+		payload := make([]byte, buf.Size)
+		for sent := uint64(0); sent < total; {
+			n := uint64(len(payload))
+			if total-sent < n {
+				n = total - sent
+			}
+			if _, err := conn.Write(payload[:n]); err != nil {
+				uploadDone <- err
+				return
+			}
+			sent += n
+		}
+		uploadTime = time.Since(start)
+		uploadDone <- nil
+	}()
+
+	response := &SpeedTestResponse{
+		Uploaded: total,
+	}
+	var firstByte time.Time
+	b := make([]byte, buf.Size)
+	for response.Downloaded < total {
+		n, err := conn.Read(b)
+		if n > 0 && firstByte.IsZero() {
+			firstByte = time.Now()
+		}
+		response.Downloaded += uint64(n)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			err = tracker.cause(err)
+			class := errors.ClassOf(err)
+			return nil, newError("speed test through ", request.OutboundTag, " failed after ", response.Downloaded, " bytes (", class, ")").Base(err).WithClass(class)
+		}
+	}
+	end := time.Now()
+	if err := <-uploadDone; err != nil {
+		err = tracker.cause(err)
+		class := errors.ClassOf(err)
+		return nil, newError("speed test through ", request.OutboundTag, " failed to upload (", class, ")").Base(err).WithClass(class)
+	}
+	if response.Downloaded < total {
+		if err := tracker.cause(nil); err != nil {
+			class := errors.ClassOf(err)
+			return nil, newError("speed test through ", request.OutboundTag, " failed after ", response.Downloaded, " bytes (", class, ")").Base(err).WithClass(class)
+		}
+	}
+
+	response.Duration = end.Sub(start).Milliseconds()
+	response.UploadSpeed = speed(total, uploadTime)
+	if !firstByte.IsZero() {
+		response.Latency = firstByte.Sub(start).Milliseconds()
+		response.DownloadSpeed = speed(response.Downloaded, end.Sub(firstByte))
+	}
+	return response, nil
+}
@@ -1,5 +1,12 @@
 package proxyman
 
+import (
+	"strings"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+)
+
 func (s *AllocationStrategy) GetConcurrencyValue() uint32 {
 	if s == nil || s.Concurrency == nil {
 		return 3
@@ -37,3 +44,45 @@ func (c *ReceiverConfig) GetEffectiveSniffingSettings() *SniffingConfig {
 
 	return nil
 }
+
+// UseMux returns whether connections to the destination go through Mux, instead of a connection of their own.
+func (c *MultiplexingConfig) UseMux(dest net.Destination) bool {
+	if len(c.Networks) > 0 && !net.HasNetwork(c.Networks, dest.Network) {
+		return false
+	}
+	if c.BypassQuic && dest.Network == net.Network_UDP && dest.Port == 443 {
+		return false
+	}
+	if c.BypassPorts != nil && net.PortListFromProto(c.BypassPorts).Contains(dest.Port) {
+		return false
+	}
+	if dest.Address != nil && dest.Address.Family().IsDomain() {
+		domain := strings.ToLower(dest.Address.Domain())
+		for _, d := range c.BypassDomains {
+			if domain == d || strings.HasSuffix(domain, "."+d) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// GetRestriction returns the destination restriction of this receiver, or nil if there is none.
+func (c *ReceiverConfig) GetRestriction() *session.Restriction {
+	if c.BlockedPorts == nil && len(c.BlockedProtocols) == 0 && !c.BlockPrivateDestinations {
+		return nil
+	}
+	r := &session.Restriction{
+		BlockedProtocols: c.BlockedProtocols,
+		BlockPrivate:     c.BlockPrivateDestinations,
+	}
+	if c.BlockedPorts != nil {
+		r.BlockedPorts = net.PortListFromProto(c.BlockedPorts)
+	}
+	for _, cidr := range c.AllowedPrivateDestinations {
+		if _, allowed, err := net.ParseCIDR(cidr); err == nil {
+			r.AllowedPrivate = append(r.AllowedPrivate, allowed)
+		}
+	}
+	return r
+}
@@ -0,0 +1,81 @@
+package proxyman_test
+
+import (
+	"testing"
+
+	. "github.com/xtls/xray-core/app/proxyman"
+	"github.com/xtls/xray-core/common/net"
+)
+
+func TestRestrictionBlocksPrivate(t *testing.T) {
+	config := &ReceiverConfig{
+		BlockPrivateDestinations:   true,
+		AllowedPrivateDestinations: []string{"10.1.0.0/16", "fd00::1/128"},
+	}
+	r := config.GetRestriction()
+	if r == nil {
+		t.Fatal("expected restriction")
+	}
+	cases := []struct {
+		ip      string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"0.0.0.0", true},
+		{"169.254.169.254", true},
+		{"192.168.1.1", true},
+		{"172.16.0.1", true},
+		{"10.0.0.1", true},
+		{"10.1.2.3", false},
+		{"100.64.0.1", true},
+		{"100.127.255.254", true},
+		{"100.128.0.1", false},
+		{"fd00::1", false},
+		{"fd00::2", true},
+		{"fe80::1", true},
+		{"8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+	}
+	for _, c := range cases {
+		if blocked := r.BlocksIP(net.ParseIP(c.ip)); blocked != c.blocked {
+			t.Error("expected ", c.ip, " blocked: ", c.blocked, ", but got ", blocked)
+		}
+	}
+
+	if (&ReceiverConfig{}).GetRestriction() != nil {
+		t.Error("expected no restriction")
+	}
+}
+
+func TestMuxBypass(t *testing.T) {
+	config := &MultiplexingConfig{
+		Enabled:       true,
+		BypassPorts:   &net.PortList{Range: []*net.PortRange{net.SinglePortRange(8443)}},
+		BypassDomains: []string{"download.example.com"},
+		BypassQuic:    true,
+	}
+	cases := []struct {
+		dest net.Destination
+		mux  bool
+	}{
+		{net.TCPDestination(net.DomainAddress("www.example.com"), 443), true},
+		{net.TCPDestination(net.DomainAddress("download.example.com"), 443), false},
+		{net.TCPDestination(net.DomainAddress("cdn.download.example.com"), 443), false},
+		{net.TCPDestination(net.DomainAddress("notdownload.example.com"), 443), true},
+		{net.TCPDestination(net.LocalHostIP, 8443), false},
+		{net.UDPDestination(net.LocalHostIP, 443), false},
+		{net.UDPDestination(net.LocalHostIP, 53), true},
+	}
+	for _, c := range cases {
+		if mux := config.UseMux(c.dest); mux != c.mux {
+			t.Error("expected ", c.dest, " to use mux: ", c.mux, ", but got ", mux)
+		}
+	}
+
+	config.Networks = []net.Network{net.Network_TCP}
+	if config.UseMux(net.UDPDestination(net.LocalHostIP, 53)) {
+		t.Error("expected UDP to bypass mux")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/xtls/xray-core/app/proxyman"
+	"github.com/xtls/xray-core/app/watchdog"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/dice"
 	"github.com/xtls/xray-core/common/errors"
@@ -16,6 +17,11 @@ import (
 	"github.com/xtls/xray-core/transport/internet"
 )
 
+func getWatchdog(v *core.Instance) *watchdog.Watchdog {
+	w, _ := v.GetFeature(watchdog.Type()).(*watchdog.Watchdog)
+	return w
+}
+
 func getStatCounter(v *core.Instance, tag string) (stats.Counter, stats.Counter) {
 	var uplinkCounter stats.Counter
 	var downlinkCounter stats.Counter
@@ -41,11 +47,23 @@ func getStatCounter(v *core.Instance, tag string) (stats.Counter, stats.Counter)
 	return uplinkCounter, downlinkCounter
 }
 
+// getTransportEvents returns the recorder of transport events of the inbound, or nil if inbound stats are disabled.
+func getTransportEvents(v *core.Instance, tag string) *internet.EventRecorder {
+	policy := v.GetFeature(policy.ManagerType()).(policy.Manager)
+	if len(tag) == 0 || !(policy.ForSystem().Stats.InboundUplink || policy.ForSystem().Stats.InboundDownlink) {
+		return nil
+	}
+	return internet.NewEventRecorder(v.GetFeature(stats.ManagerType()).(stats.Manager), "inbound>>>"+tag)
+}
+
 type AlwaysOnInboundHandler struct {
 	proxy   proxy.Inbound
 	workers []worker
 	mux     *mux.Server
 	tag     string
+	guard   *crashGuard
+
+	maintenance *maintenance
 }
 
 func NewAlwaysOnInboundHandler(ctx context.Context, tag string, receiverConfig *proxyman.ReceiverConfig, proxyConfig interface{}) (*AlwaysOnInboundHandler, error) {
@@ -62,9 +80,15 @@ func NewAlwaysOnInboundHandler(ctx context.Context, tag string, receiverConfig *
 		proxy: p,
 		mux:   mux.NewServer(ctx),
 		tag:   tag,
+
+		maintenance: newMaintenance(receiverConfig.Maintenance),
 	}
 
+	h.mux.Coalesce = receiverConfig.MuxCoalesce
+	h.mux.Resumable = receiverConfig.MuxResumable
+	h.guard = newCrashGuard(core.MustFromContext(ctx), tag)
 	uplinkCounter, downlinkCounter := getStatCounter(core.MustFromContext(ctx), tag)
+	wd := getWatchdog(core.MustFromContext(ctx))
 
 	nl := p.Network()
 	pl := receiverConfig.PortList
@@ -77,6 +101,7 @@ func NewAlwaysOnInboundHandler(ctx context.Context, tag string, receiverConfig *
 	if err != nil {
 		return nil, newError("failed to parse stream config").Base(err).AtWarning()
 	}
+	mss.Events = getTransportEvents(core.MustFromContext(ctx), tag)
 
 	if receiverConfig.ReceiveOriginalDestination {
 		if mss.SocketSettings == nil {
@@ -98,8 +123,12 @@ func NewAlwaysOnInboundHandler(ctx context.Context, tag string, receiverConfig *
 				tag:             tag,
 				dispatcher:      h.mux,
 				sniffingConfig:  receiverConfig.GetEffectiveSniffingSettings(),
+				restriction:     receiverConfig.GetRestriction(),
+				watchdog:        wd,
+				maintenance:     h.maintenance,
 				uplinkCounter:   uplinkCounter,
 				downlinkCounter: downlinkCounter,
+				guard:           h.guard,
 				ctx:             ctx,
 			}
 			h.workers = append(h.workers, worker)
@@ -120,8 +149,12 @@ func NewAlwaysOnInboundHandler(ctx context.Context, tag string, receiverConfig *
 						tag:             tag,
 						dispatcher:      h.mux,
 						sniffingConfig:  receiverConfig.GetEffectiveSniffingSettings(),
+						restriction:     receiverConfig.GetRestriction(),
+						watchdog:        wd,
+						maintenance:     h.maintenance,
 						uplinkCounter:   uplinkCounter,
 						downlinkCounter: downlinkCounter,
+						guard:           h.guard,
 						ctx:             ctx,
 					}
 					h.workers = append(h.workers, worker)
@@ -135,9 +168,12 @@ func NewAlwaysOnInboundHandler(ctx context.Context, tag string, receiverConfig *
 						port:            net.Port(port),
 						dispatcher:      h.mux,
 						sniffingConfig:  receiverConfig.GetEffectiveSniffingSettings(),
+						restriction:     receiverConfig.GetRestriction(),
+						watchdog:        wd,
 						uplinkCounter:   uplinkCounter,
 						downlinkCounter: downlinkCounter,
 						stream:          mss,
+						guard:           h.guard,
 						ctx:             ctx,
 					}
 					h.workers = append(h.workers, worker)
@@ -0,0 +1,89 @@
+package inbound
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/postmortem"
+	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/stats"
+)
+
+// crashPostmortemInterval is the least time between two postmortem bundles of a handler, so that packets
+// crashing it over and over do not fill the disk.
+const crashPostmortemInterval = 10 * time.Second
+
+// crashGuard keeps a panic in a connection of an inbound handler from taking down the whole process. The
+// panic ends only the connection it happened on, and the handler keeps serving the others.
+type crashGuard struct {
+	tag     string
+	counter stats.Counter
+
+	access         sync.Mutex
+	lastPostmortem time.Time
+}
+
+func newCrashGuard(v *core.Instance, tag string) *crashGuard {
+	g := &crashGuard{
+		tag: tag,
+	}
+	if len(tag) > 0 {
+		if m, ok := v.GetFeature(stats.ManagerType()).(stats.Manager); ok {
+			g.counter, _ = stats.GetOrRegisterCounter(m, "inbound>>>"+tag+">>>crash")
+		}
+	}
+	return g
+}
+
+// recover recovers from a panic of the goroutine, which has to defer it. cleanup, if not nil, releases what
+// the goroutine would have released had it not panicked.
+func (g *crashGuard) recover(ctx context.Context, cleanup func()) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	g.crashed(ctx, r, debug.Stack())
+	if cleanup != nil {
+		cleanup()
+	}
+}
+
+// check reports the panic of a goroutine of a connection, which task.Run returns as the error of the
+// connection.
+func (g *crashGuard) check(ctx context.Context, err error) {
+	var p *task.PanicError
+	if errors.As(err, &p) {
+		g.crashed(ctx, p.Value, p.Stack)
+	}
+}
+
+func (g *crashGuard) crashed(ctx context.Context, value interface{}, stack []byte) {
+	reason := serial.Concat("inbound [", g.tag, "] recovered from panic: ", value, "\n", string(stack))
+	newError(reason).AtError().WriteToLog(session.ExportIDToError(ctx))
+	if g.counter != nil {
+		g.counter.Add(1)
+	}
+	go g.writePostmortem(reason)
+}
+
+// writePostmortem writes a postmortem bundle for the panic, unless it did so lately.
+func (g *crashGuard) writePostmortem(reason string) {
+	g.access.Lock()
+	defer g.access.Unlock()
+
+	if time.Since(g.lastPostmortem) < crashPostmortemInterval {
+		return
+	}
+	g.lastPostmortem = time.Now()
+	if path, err := postmortem.Write(reason); err != nil {
+		newError("failed to write postmortem").Base(err).AtWarning().WriteToLog()
+	} else if path != "" {
+		newError("postmortem written to ", path).AtWarning().WriteToLog()
+	}
+}
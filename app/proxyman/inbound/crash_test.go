@@ -0,0 +1,49 @@
+package inbound
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common/task"
+)
+
+type crashCounter struct {
+	value atomic.Int64
+}
+
+func (c *crashCounter) Value() int64      { return c.value.Load() }
+func (c *crashCounter) Set(v int64) int64 { return c.value.Swap(v) }
+func (c *crashCounter) Add(v int64) int64 { return c.value.Add(v) }
+
+func TestCrashGuard(t *testing.T) {
+	counter := &crashCounter{}
+	g := &crashGuard{
+		tag:     "test",
+		counter: counter,
+	}
+
+	cleaned := make(chan struct{})
+	go func() {
+		defer g.recover(context.Background(), func() {
+			close(cleaned)
+		})
+		panic("malformed packet")
+	}()
+	select {
+	case <-cleaned:
+	case <-time.After(time.Second):
+		t.Fatal("expected cleanup after panic")
+	}
+
+	err := task.Run(context.Background(), func() error {
+		panic("malformed packet")
+	})
+	g.check(context.Background(), newError("connection ends").Base(err))
+	g.check(context.Background(), newError("connection ends"))
+
+	if n := counter.Value(); n != 2 {
+		t.Error("expected 2 crashes, but got ", n)
+	}
+}
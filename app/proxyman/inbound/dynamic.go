@@ -28,6 +28,8 @@ type DynamicInboundHandler struct {
 	lastRefresh    time.Time
 	mux            *mux.Server
 	task           *task.Periodic
+	maintenance    *maintenance
+	guard          *crashGuard
 
 	ctx context.Context
 }
@@ -40,14 +42,18 @@ func NewDynamicInboundHandler(ctx context.Context, tag string, receiverConfig *p
 		receiverConfig: receiverConfig,
 		portsInUse:     make(map[net.Port]bool),
 		mux:            mux.NewServer(ctx),
+		maintenance:    newMaintenance(receiverConfig.Maintenance),
 		v:              v,
 		ctx:            ctx,
 	}
+	h.mux.Coalesce = receiverConfig.MuxCoalesce
+	h.mux.Resumable = receiverConfig.MuxResumable
 
 	mss, err := internet.ToMemoryStreamConfig(receiverConfig.StreamSettings)
 	if err != nil {
 		return nil, newError("failed to parse stream settings").Base(err).AtWarning()
 	}
+	mss.Events = getTransportEvents(v, tag)
 	if receiverConfig.ReceiveOriginalDestination {
 		if mss.SocketSettings == nil {
 			mss.SocketSettings = &internet.SocketConfig{}
@@ -59,6 +65,7 @@ func NewDynamicInboundHandler(ctx context.Context, tag string, receiverConfig *p
 	}
 
 	h.streamSettings = mss
+	h.guard = newCrashGuard(v, tag)
 
 	h.task = &task.Periodic{
 		Interval: time.Minute * time.Duration(h.receiverConfig.AllocationStrategy.GetRefreshValue()),
@@ -105,6 +112,7 @@ func (h *DynamicInboundHandler) closeWorkers(workers []worker) {
 	h.portMutex.Unlock()
 }
 
+// refresh replaces the workers of the handler with new ones on other ports.
 func (h *DynamicInboundHandler) refresh() error {
 	h.lastRefresh = time.Now()
 
@@ -138,8 +146,12 @@ func (h *DynamicInboundHandler) refresh() error {
 				recvOrigDest:    h.receiverConfig.ReceiveOriginalDestination,
 				dispatcher:      h.mux,
 				sniffingConfig:  h.receiverConfig.GetEffectiveSniffingSettings(),
+				restriction:     h.receiverConfig.GetRestriction(),
+				watchdog:        getWatchdog(h.v),
+				maintenance:     h.maintenance,
 				uplinkCounter:   uplinkCounter,
 				downlinkCounter: downlinkCounter,
+				guard:           h.guard,
 				ctx:             h.ctx,
 			}
 			if err := worker.Start(); err != nil {
@@ -157,9 +169,12 @@ func (h *DynamicInboundHandler) refresh() error {
 				port:            port,
 				dispatcher:      h.mux,
 				sniffingConfig:  h.receiverConfig.GetEffectiveSniffingSettings(),
+				restriction:     h.receiverConfig.GetRestriction(),
+				watchdog:        getWatchdog(h.v),
 				uplinkCounter:   uplinkCounter,
 				downlinkCounter: downlinkCounter,
 				stream:          h.streamSettings,
+				guard:           h.guard,
 				ctx:             h.ctx,
 			}
 			if err := worker.Start(); err != nil {
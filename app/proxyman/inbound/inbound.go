@@ -6,8 +6,11 @@ import (
 	"context"
 	"sync"
 
+	"github.com/xtls/xray-core/app/hook"
 	"github.com/xtls/xray-core/app/proxyman"
+	"github.com/xtls/xray-core/app/watchdog"
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/mux"
 	"github.com/xtls/xray-core/common/serial"
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/core"
@@ -20,16 +23,39 @@ type Manager struct {
 	untaggedHandler []inbound.Handler
 	taggedHandlers  map[string]inbound.Handler
 	running         bool
+	instance        *core.Instance
 }
 
 // New returns a new Manager for inbound handlers.
 func New(ctx context.Context, config *proxyman.InboundConfig) (*Manager, error) {
 	m := &Manager{
 		taggedHandlers: make(map[string]inbound.Handler),
+		instance:       core.FromContext(ctx),
 	}
 	return m, nil
 }
 
+// startHandler starts the handler and reports the result to hooks. Caller must hold the lock.
+func (m *Manager) startHandler(handler inbound.Handler) error {
+	if err := handler.Start(); err != nil {
+		m.fire(hook.EventHandlerFail, handler.Tag(), err)
+		return err
+	}
+	m.fire(hook.EventHandlerStart, handler.Tag(), nil)
+	return nil
+}
+
+func (m *Manager) fire(event string, tag string, err error) {
+	fields := map[string]string{
+		"direction": "inbound",
+		"tag":       tag,
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	hook.FromInstance(m.instance).Fire(event, fields)
+}
+
 // Type implements common.HasType.
 func (*Manager) Type() interface{} {
 	return inbound.ManagerType()
@@ -51,7 +77,7 @@ func (m *Manager) AddHandler(ctx context.Context, handler inbound.Handler) error
 	}
 
 	if m.running {
-		return handler.Start()
+		return m.startHandler(handler)
 	}
 
 	return nil
@@ -83,6 +109,7 @@ func (m *Manager) RemoveHandler(ctx context.Context, tag string) error {
 			newError("failed to close handler ", tag).Base(err).AtWarning().WriteToLog(session.ExportIDToError(ctx))
 		}
 		delete(m.taggedHandlers, tag)
+		m.fire(hook.EventHandlerStop, tag, nil)
 		return nil
 	}
 
@@ -97,13 +124,13 @@ func (m *Manager) Start() error {
 	m.running = true
 
 	for _, handler := range m.taggedHandlers {
-		if err := handler.Start(); err != nil {
+		if err := m.startHandler(handler); err != nil {
 			return err
 		}
 	}
 
 	for _, handler := range m.untaggedHandler {
-		if err := handler.Start(); err != nil {
+		if err := m.startHandler(handler); err != nil {
 			return err
 		}
 	}
@@ -178,4 +205,6 @@ func init() {
 	common.Must(common.RegisterConfig((*core.InboundHandlerConfig)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
 		return NewHandler(ctx, config.(*core.InboundHandlerConfig))
 	}))
+	// data kept for resumable Mux sessions is only needed if their connections break
+	watchdog.RegisterReleaseFunc(mux.ReleaseResumable)
 }
@@ -0,0 +1,99 @@
+package inbound
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/xtls/xray-core/app/proxyman"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/signal"
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/transport/internet/stat"
+)
+
+const (
+	// maintenanceWriteTimeout limits how long writing the maintenance response may take.
+	maintenanceWriteTimeout = 5 * time.Second
+	// maintenanceIdleTimeout closes connections forwarded to the fallback once idle for so long.
+	maintenanceIdleTimeout = 5 * time.Minute
+)
+
+// maintenance holds the maintenance settings of an inbound, shared by its workers and changed through the API.
+type maintenance struct {
+	config atomic.Value // *proxyman.MaintenanceConfig
+}
+
+func newMaintenance(config *proxyman.MaintenanceConfig) *maintenance {
+	m := new(maintenance)
+	m.set(config)
+	return m
+}
+
+func (m *maintenance) set(config *proxyman.MaintenanceConfig) {
+	if config == nil {
+		config = &proxyman.MaintenanceConfig{}
+	}
+	m.config.Store(config)
+}
+
+// handle serves a new connection if the inbound is in maintenance, and returns whether it did.
+func (m *maintenance) handle(ctx context.Context, conn stat.Connection) bool {
+	config := m.config.Load().(*proxyman.MaintenanceConfig)
+	if !config.Enabled {
+		return false
+	}
+	defer conn.Close()
+
+	if config.Fallback != "" {
+		if err := forward(ctx, conn, config.Fallback); err != nil {
+			newError("failed to forward connection in maintenance to ", config.Fallback).Base(err).AtInfo().WriteToLog(session.ExportIDToError(ctx))
+		}
+		return true
+	}
+	if len(config.Response) > 0 {
+		conn.SetWriteDeadline(time.Now().Add(maintenanceWriteTimeout))
+		conn.Write(config.Response)
+	}
+	newError("connection turned away in maintenance").AtDebug().WriteToLog(session.ExportIDToError(ctx))
+	return true
+}
+
+func forward(ctx context.Context, conn stat.Connection, fallback string) error {
+	network := "tcp"
+	if strings.HasPrefix(fallback, "/") || strings.HasPrefix(fallback, "@") {
+		network = "unix"
+	}
+	var dialer net.Dialer
+	target, err := dialer.DialContext(ctx, network, fallback)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	timer := signal.CancelAfterInactivity(ctx, cancel, maintenanceIdleTimeout)
+
+	request := func() error {
+		defer stat.CloseWrite(target)
+		return buf.Copy(buf.NewReader(conn), buf.NewWriter(target), buf.UpdateActivity(timer))
+	}
+	response := func() error {
+		defer stat.CloseWrite(conn)
+		return buf.Copy(buf.NewReader(target), buf.NewWriter(conn), buf.UpdateActivity(timer))
+	}
+	return task.Run(ctx, request, response)
+}
+
+// SetMaintenance puts the inbound into maintenance, or takes it out.
+func (h *AlwaysOnInboundHandler) SetMaintenance(config *proxyman.MaintenanceConfig) {
+	h.maintenance.set(config)
+}
+
+// SetMaintenance puts the inbound into maintenance, or takes it out.
+func (h *DynamicInboundHandler) SetMaintenance(config *proxyman.MaintenanceConfig) {
+	h.maintenance.set(config)
+}
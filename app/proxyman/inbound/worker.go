@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/xtls/xray-core/app/proxyman"
+	"github.com/xtls/xray-core/app/watchdog"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/net"
@@ -40,8 +41,12 @@ type tcpWorker struct {
 	tag             string
 	dispatcher      routing.Dispatcher
 	sniffingConfig  *proxyman.SniffingConfig
+	restriction     *session.Restriction
+	watchdog        *watchdog.Watchdog
+	maintenance     *maintenance
 	uplinkCounter   stats.Counter
 	downlinkCounter stats.Counter
+	guard           *crashGuard
 
 	hub internet.Listener
 
@@ -59,6 +64,10 @@ func (w *tcpWorker) callback(conn stat.Connection) {
 	ctx, cancel := context.WithCancel(w.ctx)
 	sid := session.NewID()
 	ctx = session.ContextWithID(ctx, sid)
+	defer w.guard.recover(ctx, func() {
+		cancel()
+		conn.Close()
+	})
 
 	if w.recvOrigDest {
 		var dest net.Destination
@@ -87,12 +96,27 @@ func (w *tcpWorker) callback(conn stat.Connection) {
 			WriteCounter: w.downlinkCounter,
 		}
 	}
-	ctx = session.ContextWithInbound(ctx, &session.Inbound{
+	inbound := &session.Inbound{
 		Source:  net.DestinationFromAddr(conn.RemoteAddr()),
 		Gateway: net.TCPDestination(w.address, w.port),
 		Tag:     w.tag,
 		Conn:    conn,
-	})
+	}
+	ctx = session.ContextWithInbound(ctx, inbound)
+	if w.maintenance != nil && w.maintenance.handle(ctx, conn) {
+		cancel()
+		return
+	}
+	if w.watchdog != nil {
+		release, ok := w.watchdog.Track(inbound, cancel)
+		if !ok {
+			newError("connection from ", inbound.Source, " rejected by watchdog").AtInfo().WriteToLog(session.ExportIDToError(ctx))
+			cancel()
+			conn.Close()
+			return
+		}
+		defer release()
+	}
 
 	content := new(session.Content)
 	if w.sniffingConfig != nil {
@@ -102,10 +126,12 @@ func (w *tcpWorker) callback(conn stat.Connection) {
 		content.SniffingRequest.MetadataOnly = w.sniffingConfig.MetadataOnly
 		content.SniffingRequest.RouteOnly = w.sniffingConfig.RouteOnly
 	}
+	content.Restriction = w.restriction
 	ctx = session.ContextWithContent(ctx, content)
 
 	if err := w.proxy.Process(ctx, net.Network_TCP, conn, w.dispatcher); err != nil {
 		newError("connection ends").Base(err).WriteToLog(session.ExportIDToError(ctx))
+		w.guard.check(ctx, err)
 	}
 	cancel()
 	conn.Close()
@@ -242,8 +268,11 @@ type udpWorker struct {
 	stream          *internet.MemoryStreamConfig
 	dispatcher      routing.Dispatcher
 	sniffingConfig  *proxyman.SniffingConfig
+	restriction     *session.Restriction
+	watchdog        *watchdog.Watchdog
 	uplinkCounter   stats.Counter
 	downlinkCounter stats.Counter
+	guard           *crashGuard
 
 	checker    *task.Periodic
 	activeConn map[connID]*udpConn
@@ -286,6 +315,8 @@ func (w *udpWorker) getConnection(id connID) (*udpConn, bool) {
 }
 
 func (w *udpWorker) callback(b *buf.Buffer, source net.Destination, originalDest net.Destination) {
+	defer w.guard.recover(w.ctx, nil)
+
 	id := connID{
 		src: source,
 	}
@@ -304,20 +335,45 @@ func (w *udpWorker) callback(b *buf.Buffer, source net.Destination, originalDest
 		common.Must(w.checker.Start())
 
 		go func() {
-			ctx := w.ctx
+			ctx, cancel := context.WithCancel(w.ctx)
+			defer cancel()
 			sid := session.NewID()
 			ctx = session.ContextWithID(ctx, sid)
+			defer w.guard.recover(ctx, func() {
+				conn.Close()
+				if !conn.inactive {
+					conn.setInactive()
+					w.removeConn(id)
+				}
+			})
 
 			if originalDest.IsValid() {
 				ctx = session.ContextWithOutbound(ctx, &session.Outbound{
 					Target: originalDest,
 				})
 			}
-			ctx = session.ContextWithInbound(ctx, &session.Inbound{
+			inbound := &session.Inbound{
 				Source:  source,
 				Gateway: net.UDPDestination(w.address, w.port),
 				Tag:     w.tag,
-			})
+			}
+			ctx = session.ContextWithInbound(ctx, inbound)
+			if w.watchdog != nil {
+				release, ok := w.watchdog.Track(inbound, func() {
+					cancel()
+					conn.Close()
+				})
+				if !ok {
+					newError("connection from ", source, " rejected by watchdog").AtInfo().WriteToLog(session.ExportIDToError(ctx))
+					conn.Close()
+					if !conn.inactive {
+						conn.setInactive()
+						w.removeConn(id)
+					}
+					return
+				}
+				defer release()
+			}
 			content := new(session.Content)
 			if w.sniffingConfig != nil {
 				content.SniffingRequest.Enabled = w.sniffingConfig.Enabled
@@ -325,9 +381,11 @@ func (w *udpWorker) callback(b *buf.Buffer, source net.Destination, originalDest
 				content.SniffingRequest.MetadataOnly = w.sniffingConfig.MetadataOnly
 				content.SniffingRequest.RouteOnly = w.sniffingConfig.RouteOnly
 			}
+			content.Restriction = w.restriction
 			ctx = session.ContextWithContent(ctx, content)
 			if err := w.proxy.Process(ctx, net.Network_UDP, conn, w.dispatcher); err != nil {
 				newError("connection ends").Base(err).WriteToLog(session.ExportIDToError(ctx))
+				w.guard.check(ctx, err)
 			}
 			conn.Close()
 			// conn not removed by checker TODO may be lock worker here is better
@@ -441,8 +499,12 @@ type dsWorker struct {
 	tag             string
 	dispatcher      routing.Dispatcher
 	sniffingConfig  *proxyman.SniffingConfig
+	restriction     *session.Restriction
+	watchdog        *watchdog.Watchdog
+	maintenance     *maintenance
 	uplinkCounter   stats.Counter
 	downlinkCounter stats.Counter
+	guard           *crashGuard
 
 	hub internet.Listener
 
@@ -453,6 +515,10 @@ func (w *dsWorker) callback(conn stat.Connection) {
 	ctx, cancel := context.WithCancel(w.ctx)
 	sid := session.NewID()
 	ctx = session.ContextWithID(ctx, sid)
+	defer w.guard.recover(ctx, func() {
+		cancel()
+		conn.Close()
+	})
 
 	if w.uplinkCounter != nil || w.downlinkCounter != nil {
 		conn = &stat.CounterConnection{
@@ -461,12 +527,27 @@ func (w *dsWorker) callback(conn stat.Connection) {
 			WriteCounter: w.downlinkCounter,
 		}
 	}
-	ctx = session.ContextWithInbound(ctx, &session.Inbound{
+	inbound := &session.Inbound{
 		Source:  net.DestinationFromAddr(conn.RemoteAddr()),
 		Gateway: net.UnixDestination(w.address),
 		Tag:     w.tag,
 		Conn:    conn,
-	})
+	}
+	ctx = session.ContextWithInbound(ctx, inbound)
+	if w.maintenance != nil && w.maintenance.handle(ctx, conn) {
+		cancel()
+		return
+	}
+	if w.watchdog != nil {
+		release, ok := w.watchdog.Track(inbound, cancel)
+		if !ok {
+			newError("connection from ", inbound.Source, " rejected by watchdog").AtInfo().WriteToLog(session.ExportIDToError(ctx))
+			cancel()
+			conn.Close()
+			return
+		}
+		defer release()
+	}
 
 	content := new(session.Content)
 	if w.sniffingConfig != nil {
@@ -476,10 +557,12 @@ func (w *dsWorker) callback(conn stat.Connection) {
 		content.SniffingRequest.MetadataOnly = w.sniffingConfig.MetadataOnly
 		content.SniffingRequest.RouteOnly = w.sniffingConfig.RouteOnly
 	}
+	content.Restriction = w.restriction
 	ctx = session.ContextWithContent(ctx, content)
 
 	if err := w.proxy.Process(ctx, net.Network_UNIX, conn, w.dispatcher); err != nil {
 		newError("connection ends").Base(err).WriteToLog(session.ExportIDToError(ctx))
+		w.guard.check(ctx, err)
 	}
 	cancel()
 	if err := conn.Close(); err != nil {
@@ -0,0 +1,89 @@
+package inbound
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/app/watchdog"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/transport/internet/stat"
+)
+
+type blockingInbound struct {
+	processed chan net.Destination
+}
+
+func (*blockingInbound) Network() []net.Network {
+	return []net.Network{net.Network_UDP}
+}
+
+func (p *blockingInbound) Process(ctx context.Context, network net.Network, conn stat.Connection, dispatcher routing.Dispatcher) error {
+	p.processed <- net.DestinationFromAddr(conn.RemoteAddr())
+	<-ctx.Done()
+	return nil
+}
+
+func TestUDPWorkerWatchdog(t *testing.T) {
+	wd, err := watchdog.New(context.Background(), &watchdog.Config{
+		MaxConnections: 1,
+	})
+	common.Must(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := &blockingInbound{
+		processed: make(chan net.Destination, 2),
+	}
+	w := &udpWorker{
+		ctx:        ctx,
+		proxy:      p,
+		address:    net.LocalHostIP,
+		port:       net.Port(1080),
+		tag:        "test",
+		watchdog:   wd,
+		guard:      &crashGuard{tag: "test"},
+		activeConn: make(map[connID]*udpConn, 16),
+		checker: &task.Periodic{
+			Interval: time.Hour,
+			Execute:  func() error { return nil },
+		},
+	}
+	defer w.checker.Close()
+
+	first := net.UDPDestination(net.LocalHostIP, net.Port(10001))
+	second := net.UDPDestination(net.LocalHostIP, net.Port(10002))
+
+	w.callback(buf.New(), first, net.Destination{})
+	select {
+	case src := <-p.processed:
+		if src != first {
+			t.Error("expected session from ", first, ", but got ", src)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the first session to be processed")
+	}
+
+	w.callback(buf.New(), second, net.Destination{})
+	for i := 0; ; i++ {
+		w.Lock()
+		_, found := w.activeConn[connID{src: second}]
+		w.Unlock()
+		if !found {
+			break
+		}
+		if i == 100 {
+			t.Fatal("expected the second session to be removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case src := <-p.processed:
+		t.Error("expected session from ", src, " to be rejected by watchdog")
+	default:
+	}
+}
@@ -0,0 +1,88 @@
+package outbound
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultCircuitCooldown = 30 * time.Second
+
+// circuitBreaker opens after a number of consecutive dial failures, and stays open for the cooldown. After the
+// cooldown, a single dial probes the server while the others still fail fast. The success of the probe closes
+// the circuit, and its failure opens it again at once.
+type circuitBreaker struct {
+	sync.Mutex
+	threshold uint32
+	cooldown  time.Duration
+	failures  uint32
+	openUntil time.Time
+	// probeUntil is when the probe in flight is given up, in case its dial never reports back.
+	probeUntil time.Time
+}
+
+func newCircuitBreaker(failures, cooldown uint32) *circuitBreaker {
+	if failures == 0 {
+		return nil
+	}
+	b := &circuitBreaker{
+		threshold: failures,
+		cooldown:  defaultCircuitCooldown,
+	}
+	if cooldown > 0 {
+		b.cooldown = time.Duration(cooldown) * time.Second
+	}
+	return b
+}
+
+// isOpen returns whether connections are to fail without dialing, which is also the case while a probe is
+// in flight.
+func (b *circuitBreaker) isOpen() bool {
+	if b == nil {
+		return false
+	}
+	b.Lock()
+	defer b.Unlock()
+	now := time.Now()
+	return now.Before(b.openUntil) || now.Before(b.probeUntil)
+}
+
+// allow returns whether a connection may dial. Once the cooldown ends, it lets one connection through as the
+// probe.
+func (b *circuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.Lock()
+	defer b.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	now := time.Now()
+	if now.Before(b.openUntil) || now.Before(b.probeUntil) {
+		return false
+	}
+	b.probeUntil = now.Add(b.cooldown)
+	return true
+}
+
+// record counts the result of a dial, and returns whether the failure opened the circuit.
+func (b *circuitBreaker) record(err error) bool {
+	if b == nil {
+		return false
+	}
+	b.Lock()
+	defer b.Unlock()
+	if err == nil {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		b.probeUntil = time.Time{}
+		return false
+	}
+	b.failures++
+	if b.failures < b.threshold || time.Now().Before(b.openUntil) {
+		return false
+	}
+	b.openUntil = time.Now().Add(b.cooldown)
+	b.probeUntil = time.Time{}
+	return true
+}
@@ -0,0 +1,46 @@
+package outbound
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerProbe(t *testing.T) {
+	b := &circuitBreaker{
+		threshold: 2,
+		cooldown:  100 * time.Millisecond,
+	}
+	failure := errors.New("dial failed")
+
+	for i := 0; i < 2; i++ {
+		if !b.allow() {
+			t.Fatal("circuit opened after ", i, " failures")
+		}
+		b.record(failure)
+	}
+	if b.allow() {
+		t.Fatal("expected the circuit to be open")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("expected a probe after the cooldown")
+	}
+	if b.allow() || !b.isOpen() {
+		t.Fatal("expected a single probe")
+	}
+	b.record(failure)
+	if b.allow() {
+		t.Fatal("expected the failed probe to open the circuit")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("expected a probe after the cooldown")
+	}
+	b.record(nil)
+	if !b.allow() || !b.allow() || b.isOpen() {
+		t.Fatal("expected the successful probe to close the circuit")
+	}
+}
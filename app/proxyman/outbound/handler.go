@@ -2,12 +2,13 @@ package outbound
 
 import (
 	"context"
-	"errors"
 	"io"
 	"os"
 
+	"github.com/xtls/xray-core/app/hook"
 	"github.com/xtls/xray-core/app/proxyman"
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/mux"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/net/cnc"
@@ -22,6 +23,7 @@ import (
 	"github.com/xtls/xray-core/transport/internet/stat"
 	"github.com/xtls/xray-core/transport/internet/tls"
 	"github.com/xtls/xray-core/transport/pipe"
+	"golang.org/x/time/rate"
 )
 
 func getStatCounter(v *core.Instance, tag string) (stats.Counter, stats.Counter) {
@@ -49,6 +51,23 @@ func getStatCounter(v *core.Instance, tag string) (stats.Counter, stats.Counter)
 	return uplinkCounter, downlinkCounter
 }
 
+// getErrorStats returns the stats manager for error counters of the outbound, or nil if outbound stats are disabled.
+func getErrorStats(v *core.Instance, tag string) stats.Manager {
+	policy := v.GetFeature(policy.ManagerType()).(policy.Manager)
+	if len(tag) == 0 || !(policy.ForSystem().Stats.OutboundUplink || policy.ForSystem().Stats.OutboundDownlink) {
+		return nil
+	}
+	return v.GetFeature(stats.ManagerType()).(stats.Manager)
+}
+
+// getTransportEvents returns the recorder of transport events of the outbound, or nil if outbound stats are disabled.
+func getTransportEvents(v *core.Instance, tag string) *internet.EventRecorder {
+	if m := getErrorStats(v, tag); m != nil {
+		return internet.NewEventRecorder(m, "outbound>>>"+tag)
+	}
+	return nil
+}
+
 // Handler is an implements of outbound.Handler.
 type Handler struct {
 	tag             string
@@ -59,6 +78,10 @@ type Handler struct {
 	mux             *mux.ClientManager
 	uplinkCounter   stats.Counter
 	downlinkCounter stats.Counter
+	errorStats      stats.Manager
+	limiter         *rate.Limiter
+	breaker         *circuitBreaker
+	instance        *core.Instance
 }
 
 // NewHandler creates a new Handler based on the given configuration.
@@ -70,6 +93,8 @@ func NewHandler(ctx context.Context, config *core.OutboundHandlerConfig) (outbou
 		outboundManager: v.GetFeature(outbound.ManagerType()).(outbound.Manager),
 		uplinkCounter:   uplinkCounter,
 		downlinkCounter: downlinkCounter,
+		errorStats:      getErrorStats(v, config.Tag),
+		instance:        v,
 	}
 
 	if config.SenderSettings != nil {
@@ -84,7 +109,10 @@ func NewHandler(ctx context.Context, config *core.OutboundHandlerConfig) (outbou
 			if err != nil {
 				return nil, newError("failed to parse stream settings").Base(err).AtWarning()
 			}
+			mss.Events = getTransportEvents(v, config.Tag)
 			h.streamSettings = mss
+			h.limiter = newLimiter(s.BandwidthLimit, s.BandwidthBurst)
+			h.breaker = newCircuitBreaker(s.CircuitBreaker.GetFailures(), s.CircuitBreaker.GetCooldown())
 		default:
 			return nil, newError("settings is not SenderConfig")
 		}
@@ -111,7 +139,8 @@ func NewHandler(ctx context.Context, config *core.OutboundHandlerConfig) (outbou
 			return nil, newError("invalid mux concurrency: ", config.Concurrency).AtWarning()
 		}
 		h.mux = &mux.ClientManager{
-			Enabled: h.senderSettings.MultiplexSettings.Enabled,
+			Enabled:   h.senderSettings.MultiplexSettings.Enabled,
+			Resumable: config.Resumable,
 			Picker: &mux.IncrementalWorkerPicker{
 				Factory: &mux.DialingWorkerFactory{
 					Proxy:  proxyHandler,
@@ -119,6 +148,7 @@ func NewHandler(ctx context.Context, config *core.OutboundHandlerConfig) (outbou
 					Strategy: mux.ClientStrategy{
 						MaxConcurrency: config.Concurrency,
 						MaxConnection:  128,
+						Coalesce:       config.Coalesce,
 					},
 				},
 			},
@@ -136,9 +166,21 @@ func (h *Handler) Tag() string {
 
 // Dispatch implements proxy.Outbound.Dispatch.
 func (h *Handler) Dispatch(ctx context.Context, link *transport.Link) {
-	if h.mux != nil && (h.mux.Enabled || session.MuxPreferedFromContext(ctx)) {
+	if !h.breaker.allow() {
+		err := newError("outbound [", h.tag, "] is failing fast after repeated dial failures").AtInfo()
+		session.SubmitOutboundErrorToOriginator(ctx, err)
+		err.WriteToLog(session.ExportIDToError(ctx))
+		common.Interrupt(link.Writer)
+		common.Interrupt(link.Reader)
+		return
+	}
+	if h.mux != nil && (h.mux.Enabled || session.MuxPreferedFromContext(ctx)) && h.useMux(ctx) {
+		// the connections of mux are set up apart from the ones they carry
+		session.NotifyConnected(ctx)
 		if err := h.mux.Dispatch(ctx, link); err != nil {
-			err := newError("failed to process mux outbound traffic").Base(err)
+			class := errors.ClassOf(err)
+			err := newError("failed to process mux outbound traffic (", class, ")").Base(err).WithClass(class)
+			h.recordError(class)
 			session.SubmitOutboundErrorToOriginator(ctx, err)
 			err.WriteToLog(session.ExportIDToError(ctx))
 			common.Interrupt(link.Writer)
@@ -152,7 +194,9 @@ func (h *Handler) Dispatch(ctx context.Context, link *transport.Link) {
 		}
 		if err != nil {
 			// Ensure outbound ray is properly closed.
-			err := newError("failed to process outbound traffic").Base(err)
+			class := errors.ClassOf(err)
+			err := newError("failed to process outbound traffic (", class, ")").Base(err).WithClass(class)
+			h.recordError(class)
 			session.SubmitOutboundErrorToOriginator(ctx, err)
 			err.WriteToLog(session.ExportIDToError(ctx))
 			common.Interrupt(link.Writer)
@@ -163,6 +207,48 @@ func (h *Handler) Dispatch(ctx context.Context, link *transport.Link) {
 	}
 }
 
+// useMux returns whether the mux settings let the connection of ctx use Mux.
+func (h *Handler) useMux(ctx context.Context) bool {
+	outbound := session.OutboundFromContext(ctx)
+	if outbound == nil {
+		return true
+	}
+	return h.senderSettings.MultiplexSettings.UseMux(outbound.Target)
+}
+
+// recordError counts a failed connection of the given class in outbound>>>tag>>>error>>>class,
+// when outbound stats are enabled.
+func (h *Handler) recordError(class errors.Class) {
+	hook.FromInstance(h.instance).RecordError(h.tag)
+	if h.errorStats == nil {
+		return
+	}
+	name := "outbound>>>" + h.tag + ">>>error>>>" + string(class)
+	c, _ := stats.GetOrRegisterCounter(h.errorStats, name)
+	if c == nil {
+		c = h.errorStats.GetCounter(name)
+	}
+	if c != nil {
+		c.Add(1)
+	}
+}
+
+// IsCircuitOpen implements outbound.CircuitBreaker.
+func (h *Handler) IsCircuitOpen() bool {
+	return h.breaker.isOpen()
+}
+
+// recordDial feeds the result of a dial to the circuit breaker. Dials canceled by the client say nothing about
+// the server.
+func (h *Handler) recordDial(err error) {
+	if err != nil && errors.Is(err, context.Canceled) {
+		return
+	}
+	if h.breaker.record(err) {
+		newError("outbound [", h.tag, "] fails fast for ", h.breaker.cooldown, " after ", h.breaker.threshold, " dial failures").Base(err).AtWarning().WriteToLog()
+	}
+}
+
 // Address implements internet.Dialer.
 func (h *Handler) Address() net.Address {
 	if h.senderSettings == nil || h.senderSettings.Via == nil {
@@ -192,10 +278,20 @@ func (h *Handler) Dial(ctx context.Context, dest net.Destination) (stat.Connecti
 
 				if config := tls.ConfigFromStreamSettings(h.streamSettings); config != nil {
 					tlsConfig := config.GetTLSConfig(tls.WithDestination(dest))
-					conn = tls.Client(conn, tlsConfig)
+					if fingerprint := tls.GetFingerprint(config.Fingerprint); fingerprint != nil {
+						conn = tls.UClient(conn, tlsConfig, fingerprint)
+						if err := conn.(*tls.UConn).Handshake(); err != nil {
+							conn.Close()
+							h.recordDial(err)
+							return nil, err
+						}
+					} else {
+						conn = tls.Client(conn, tlsConfig)
+					}
 				}
 
-				return h.getStatCouterConnection(conn), nil
+				session.NotifyConnected(ctx)
+				return h.getLimitedConnection(h.getScheduledConnection(h.getStatCouterConnection(conn), dest)), nil
 			}
 
 			newError("failed to get outbound handler with tag: ", tag).AtWarning().WriteToLog(session.ExportIDToError(ctx))
@@ -212,11 +308,39 @@ func (h *Handler) Dial(ctx context.Context, dest net.Destination) (stat.Connecti
 	}
 
 	if conn, err := h.getUoTConnection(ctx, dest); err != os.ErrInvalid {
-		return conn, err
+		h.recordDial(err)
+		if err == nil {
+			session.NotifyConnected(ctx)
+		}
+		return h.getLimitedConnection(conn), err
 	}
 
 	conn, err := internet.Dial(ctx, dest, h.streamSettings)
-	return h.getStatCouterConnection(conn), err
+	h.recordDial(err)
+	if err == nil {
+		h.mirrorKeepAlive(ctx, conn)
+		session.NotifyConnected(ctx)
+	}
+	return h.getLimitedConnection(h.getScheduledConnection(h.getStatCouterConnection(conn), dest)), err
+}
+
+// mirrorKeepAlive applies the TCP keep-alive of the inbound connection to conn, if the policy of the user asks so.
+func (h *Handler) mirrorKeepAlive(ctx context.Context, conn stat.Connection) {
+	inbound := session.InboundFromContext(ctx)
+	if inbound == nil || inbound.Conn == nil {
+		return
+	}
+	var level uint32
+	if inbound.User != nil {
+		level = inbound.User.Level
+	}
+	pm := h.instance.GetFeature(policy.ManagerType()).(policy.Manager)
+	if !pm.ForLevel(level).KeepAlive.Mirror {
+		return
+	}
+	if err := internet.MirrorKeepAlive(inbound.Conn, conn); err != nil {
+		newError("failed to mirror TCP keep-alive").Base(err).AtDebug().WriteToLog(session.ExportIDToError(ctx))
+	}
 }
 
 func (h *Handler) getStatCouterConnection(conn stat.Connection) stat.Connection {
@@ -230,6 +354,14 @@ func (h *Handler) getStatCouterConnection(conn stat.Connection) stat.Connection
 	return conn
 }
 
+// DrainConnections lets the current mux connections close once their streams end, and makes new streams use
+// new connections. It is used after the servers of the outbound change.
+func (h *Handler) DrainConnections() {
+	if h.mux != nil {
+		h.mux.Drain()
+	}
+}
+
 // GetOutbound implements proxy.GetOutbound.
 func (h *Handler) GetOutbound() proxy.Outbound {
 	return h.proxy
@@ -243,5 +375,10 @@ func (h *Handler) Start() error {
 // Close implements common.Closable.
 func (h *Handler) Close() error {
 	common.Close(h.mux)
+	if h.streamSettings != nil {
+		// the caches are kept by config, which is not dialed with anymore
+		h.streamSettings.SocketSettings.ReleaseResolveCache()
+		tls.ConfigFromStreamSettings(h.streamSettings).ReleaseSessionCache()
+	}
 	return nil
 }
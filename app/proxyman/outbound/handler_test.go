@@ -2,17 +2,29 @@ package outbound_test
 
 import (
 	"context"
+	"io"
 	"testing"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/xtls/xray-core/app/policy"
+	"github.com/xtls/xray-core/app/proxyman"
 	. "github.com/xtls/xray-core/app/proxyman/outbound"
 	"github.com/xtls/xray-core/app/stats"
+	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/common/session"
 	core "github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/outbound"
 	"github.com/xtls/xray-core/proxy/freedom"
+	"github.com/xtls/xray-core/proxy/socks"
+	"github.com/xtls/xray-core/testing/servers/tcp"
+	"github.com/xtls/xray-core/transport"
 	"github.com/xtls/xray-core/transport/internet/stat"
+	_ "github.com/xtls/xray-core/transport/internet/tcp"
+	"github.com/xtls/xray-core/transport/pipe"
 )
 
 func TestInterfaces(t *testing.T) {
@@ -78,3 +90,165 @@ func TestOutboundWithStatCounter(t *testing.T) {
 		t.Errorf("Expected conn to be CounterConnection")
 	}
 }
+
+func TestOutboundWithBandwidthLimit(t *testing.T) {
+	config := &core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&stats.Config{}),
+			serial.ToTypedMessage(&policy.Config{}),
+		},
+	}
+
+	v, _ := core.New(config)
+	v.AddFeature((outbound.Manager)(new(Manager)))
+	ctx := context.WithValue(context.Background(), xrayKey, v)
+	h, err := NewHandler(ctx, &core.OutboundHandlerConfig{
+		Tag: "tag",
+		SenderSettings: serial.ToTypedMessage(&proxyman.SenderConfig{
+			BandwidthLimit: 10240,
+		}),
+		ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+	})
+	common.Must(err)
+
+	tcpServer := tcp.Server{
+		MsgProcessor: func(b []byte) []byte { return b },
+	}
+	dest, err := tcpServer.Start()
+	common.Must(err)
+	defer tcpServer.Close()
+
+	conn, err := h.(*Handler).Dial(ctx, dest)
+	common.Must(err)
+	defer conn.Close()
+	if _, ok := conn.(*LimitedConnection); !ok {
+		t.Fatal("Expected conn to be LimitedConnection")
+	}
+
+	// The first 10KB go through as a burst, then both directions share 10KB/s.
+	start := time.Now()
+	payload := make([]byte, 15360)
+	common.Must2(conn.Write(payload))
+	common.Must2(io.ReadFull(conn, payload))
+	if d := time.Since(start); d < 1500*time.Millisecond {
+		t.Error("transfer finished too early: ", d)
+	}
+}
+
+func TestOutboundWithHandshakeSchedule(t *testing.T) {
+	config := &core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&stats.Config{}),
+			serial.ToTypedMessage(&policy.Config{}),
+		},
+	}
+
+	v, _ := core.New(config)
+	v.AddFeature((outbound.Manager)(new(Manager)))
+	ctx := context.WithValue(context.Background(), xrayKey, v)
+	h, err := NewHandler(ctx, &core.OutboundHandlerConfig{
+		Tag: "tag",
+		SenderSettings: serial.ToTypedMessage(&proxyman.SenderConfig{
+			HandshakeSchedule: &proxyman.HandshakeSchedule{
+				Packets:  1,
+				MinSize:  100,
+				MaxSize:  100,
+				MinDelay: 10,
+				MaxDelay: 10,
+			},
+		}),
+		ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+	})
+	common.Must(err)
+
+	tcpServer := tcp.Server{
+		MsgProcessor: func(b []byte) []byte { return b },
+	}
+	dest, err := tcpServer.Start()
+	common.Must(err)
+	defer tcpServer.Close()
+
+	conn, err := h.(*Handler).Dial(ctx, dest)
+	common.Must(err)
+	defer conn.Close()
+	if _, ok := conn.(*ScheduledConnection); !ok {
+		t.Fatal("Expected conn to be ScheduledConnection")
+	}
+	// Proxies reach the connection under the schedule for XTLS.
+	if _, ok := stat.Unwrap(conn).(*ScheduledConnection); ok {
+		t.Error("Expected ScheduledConnection to be unwrapped")
+	}
+
+	// The first write goes out in 10 chunks with 9 delays between them.
+	start := time.Now()
+	payload := make([]byte, 1000)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	common.Must2(conn.Write(payload))
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Error("write finished too early: ", d)
+	}
+	response := make([]byte, len(payload))
+	common.Must2(io.ReadFull(conn, response))
+	if r := cmp.Diff(response, payload); r != "" {
+		t.Error(r)
+	}
+
+	// Later writes are not scheduled.
+	start = time.Now()
+	common.Must2(conn.Write(payload))
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Error("write after schedule is delayed: ", d)
+	}
+	common.Must2(io.ReadFull(conn, response))
+}
+
+func TestOutboundWithCircuitBreaker(t *testing.T) {
+	config := &core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&stats.Config{}),
+			serial.ToTypedMessage(&policy.Config{}),
+		},
+	}
+
+	v, _ := core.New(config)
+	v.AddFeature((outbound.Manager)(new(Manager)))
+	ctx := context.WithValue(context.Background(), xrayKey, v)
+
+	tcpServer := tcp.Server{
+		MsgProcessor: func(b []byte) []byte { return b },
+	}
+	dest, err := tcpServer.Start()
+	common.Must(err)
+	tcpServer.Close()
+
+	h, err := NewHandler(ctx, &core.OutboundHandlerConfig{
+		Tag: "tag",
+		SenderSettings: serial.ToTypedMessage(&proxyman.SenderConfig{
+			CircuitBreaker: &proxyman.CircuitBreaker{Failures: 2, Cooldown: 60},
+		}),
+		ProxySettings: serial.ToTypedMessage(&socks.ClientConfig{
+			Server: []*protocol.ServerEndpoint{{
+				Address: net.NewIPOrDomain(dest.Address),
+				Port:    uint32(dest.Port),
+			}},
+		}),
+	})
+	common.Must(err)
+
+	cb := h.(outbound.CircuitBreaker)
+	if cb.IsCircuitOpen() {
+		t.Fatal("circuit opened before any dial")
+	}
+	// the socks client retries the server, which is down
+	dispatchCtx := session.ContextWithOutbound(ctx, &session.Outbound{
+		Target: net.TCPDestination(net.DomainAddress("example.com"), 80),
+	})
+	uplinkReader, _ := pipe.New()
+	_, downlinkWriter := pipe.New()
+	h.Dispatch(dispatchCtx, &transport.Link{Reader: uplinkReader, Writer: downlinkWriter})
+	if !cb.IsCircuitOpen() {
+		t.Fatal("circuit not opened after the dials failed")
+	}
+}
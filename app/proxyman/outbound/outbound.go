@@ -7,6 +7,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/xtls/xray-core/app/hook"
 	"github.com/xtls/xray-core/app/proxyman"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/errors"
@@ -21,16 +22,39 @@ type Manager struct {
 	taggedHandler    map[string]outbound.Handler
 	untaggedHandlers []outbound.Handler
 	running          bool
+	instance         *core.Instance
 }
 
 // New creates a new Manager.
 func New(ctx context.Context, config *proxyman.OutboundConfig) (*Manager, error) {
 	m := &Manager{
 		taggedHandler: make(map[string]outbound.Handler),
+		instance:      core.FromContext(ctx),
 	}
 	return m, nil
 }
 
+// startHandler starts the handler and reports the result to hooks. Caller must hold the lock.
+func (m *Manager) startHandler(handler outbound.Handler) error {
+	if err := handler.Start(); err != nil {
+		m.fire(hook.EventHandlerFail, handler.Tag(), err)
+		return err
+	}
+	m.fire(hook.EventHandlerStart, handler.Tag(), nil)
+	return nil
+}
+
+func (m *Manager) fire(event string, tag string, err error) {
+	fields := map[string]string{
+		"direction": "outbound",
+		"tag":       tag,
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	hook.FromInstance(m.instance).Fire(event, fields)
+}
+
 // Type implements common.HasType.
 func (m *Manager) Type() interface{} {
 	return outbound.ManagerType()
@@ -44,13 +68,13 @@ func (m *Manager) Start() error {
 	m.running = true
 
 	for _, h := range m.taggedHandler {
-		if err := h.Start(); err != nil {
+		if err := m.startHandler(h); err != nil {
 			return err
 		}
 	}
 
 	for _, h := range m.untaggedHandlers {
-		if err := h.Start(); err != nil {
+		if err := m.startHandler(h); err != nil {
 			return err
 		}
 	}
@@ -118,7 +142,7 @@ func (m *Manager) AddHandler(ctx context.Context, handler outbound.Handler) erro
 	}
 
 	if m.running {
-		return handler.Start()
+		return m.startHandler(handler)
 	}
 
 	return nil
@@ -132,7 +156,10 @@ func (m *Manager) RemoveHandler(ctx context.Context, tag string) error {
 	m.access.Lock()
 	defer m.access.Unlock()
 
-	delete(m.taggedHandler, tag)
+	if _, found := m.taggedHandler[tag]; found {
+		delete(m.taggedHandler, tag)
+		m.fire(hook.EventHandlerStop, tag, nil)
+	}
 	if m.defaultHandler != nil && m.defaultHandler.Tag() == tag {
 		m.defaultHandler = nil
 	}
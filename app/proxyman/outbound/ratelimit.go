@@ -0,0 +1,67 @@
+package outbound
+
+import (
+	"context"
+
+	"github.com/xtls/xray-core/transport/internet/stat"
+	"golang.org/x/time/rate"
+)
+
+func newLimiter(limit, burst uint64) *rate.Limiter {
+	if limit == 0 {
+		return nil
+	}
+	if burst == 0 {
+		burst = limit
+	}
+	return rate.NewLimiter(rate.Limit(limit), int(burst))
+}
+
+// LimitedConnection is a connection whose traffic in both directions is taken from a token bucket,
+// which may be shared with other connections.
+type LimitedConnection struct {
+	stat.Connection
+	Limiter *rate.Limiter
+}
+
+// wait blocks until n bytes may pass. Reads and writes are not split, to keep packet boundaries
+// intact, so n may be larger than the burst size.
+func (c *LimitedConnection) wait(n int) {
+	burst := c.Limiter.Burst()
+	for n > 0 {
+		m := n
+		if m > burst {
+			m = burst
+		}
+		if c.Limiter.WaitN(context.Background(), m) != nil {
+			return
+		}
+		n -= m
+	}
+}
+
+func (c *LimitedConnection) Read(b []byte) (int, error) {
+	nBytes, err := c.Connection.Read(b)
+	c.wait(nBytes)
+	return nBytes, err
+}
+
+func (c *LimitedConnection) Write(b []byte) (int, error) {
+	c.wait(len(b))
+	return c.Connection.Write(b)
+}
+
+// CloseWrite implements stat.HalfCloser.
+func (c *LimitedConnection) CloseWrite() error {
+	return stat.CloseWrite(c.Connection)
+}
+
+func (h *Handler) getLimitedConnection(conn stat.Connection) stat.Connection {
+	if h.limiter == nil || conn == nil {
+		return conn
+	}
+	return &LimitedConnection{
+		Connection: conn,
+		Limiter:    h.limiter,
+	}
+}
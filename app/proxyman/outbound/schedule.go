@@ -0,0 +1,80 @@
+package outbound
+
+import (
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/app/proxyman"
+	"github.com/xtls/xray-core/common/dice"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet/stat"
+)
+
+func between(min, max uint32) int {
+	if max <= min {
+		return int(min)
+	}
+	return int(min) + dice.Roll(int(max-min)+1)
+}
+
+// ScheduledConnection splits its first writes into chunks of random sizes, and waits a random delay
+// before each of them, according to the HandshakeSchedule.
+type ScheduledConnection struct {
+	stat.Connection
+	Schedule *proxyman.HandshakeSchedule
+
+	access sync.Mutex
+	writes uint32
+}
+
+func (c *ScheduledConnection) Write(b []byte) (int, error) {
+	c.access.Lock()
+	defer c.access.Unlock()
+
+	if c.writes >= c.Schedule.Packets {
+		return c.Connection.Write(b)
+	}
+	c.writes++
+
+	n := 0
+	for n < len(b) {
+		// the very first chunk goes out at once, as nothing has been sent to time it against
+		if c.writes > 1 || n > 0 {
+			time.Sleep(time.Duration(between(c.Schedule.MinDelay, c.Schedule.MaxDelay)) * time.Millisecond)
+		}
+		size := len(b) - n
+		if s := between(c.Schedule.MinSize, c.Schedule.MaxSize); s > 0 && s < size {
+			size = s
+		}
+		m, err := c.Connection.Write(b[n : n+size])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Unwrap implements stat.Wrapper.
+func (c *ScheduledConnection) Unwrap() stat.Connection {
+	return c.Connection
+}
+
+// CloseWrite implements stat.HalfCloser.
+func (c *ScheduledConnection) CloseWrite() error {
+	return stat.CloseWrite(c.Connection)
+}
+
+func (h *Handler) getScheduledConnection(conn stat.Connection, dest net.Destination) stat.Connection {
+	if conn == nil || dest.Network != net.Network_TCP || h.senderSettings == nil {
+		return conn
+	}
+	schedule := h.senderSettings.HandshakeSchedule
+	if schedule == nil || schedule.Packets == 0 {
+		return conn
+	}
+	return &ScheduledConnection{
+		Connection: conn,
+		Schedule:   schedule,
+	}
+}
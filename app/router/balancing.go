@@ -2,16 +2,24 @@ package router
 
 import (
 	"context"
+	"sync/atomic"
 
 	"github.com/xtls/xray-core/common/dice"
 	"github.com/xtls/xray-core/features/extension"
 	"github.com/xtls/xray-core/features/outbound"
+	"github.com/xtls/xray-core/features/routing"
 )
 
 type BalancingStrategy interface {
 	PickOutbound([]string) string
 }
 
+// ContextualBalancingStrategy is a BalancingStrategy picking the outbound by the connection being routed.
+type ContextualBalancingStrategy interface {
+	BalancingStrategy
+	PickOutboundFor(routing.Context, []string) string
+}
+
 type RandomStrategy struct{}
 
 func (s *RandomStrategy) PickOutbound(tags []string) string {
@@ -24,12 +32,18 @@ func (s *RandomStrategy) PickOutbound(tags []string) string {
 }
 
 type Balancer struct {
+	tag       string
 	selectors []string
 	strategy  BalancingStrategy
 	ohm       outbound.Manager
+	// override is the outbound picked instead of the ones of the strategy, if not empty.
+	override atomic.Value
 }
 
-func (b *Balancer) PickOutbound() (string, error) {
+func (b *Balancer) PickOutbound(ctx routing.Context) (string, error) {
+	if target, _ := b.override.Load().(string); target != "" {
+		return target, nil
+	}
 	hs, ok := b.ohm.(outbound.HandlerSelector)
 	if !ok {
 		return "", newError("outbound.Manager is not a HandlerSelector")
@@ -38,13 +52,34 @@ func (b *Balancer) PickOutbound() (string, error) {
 	if len(tags) == 0 {
 		return "", newError("no available outbounds selected")
 	}
-	tag := b.strategy.PickOutbound(tags)
+	tags = b.skipOpenCircuits(tags)
+	if len(tags) == 0 {
+		return "", newError("all outbounds selected are failing fast")
+	}
+	var tag string
+	if s, ok := b.strategy.(ContextualBalancingStrategy); ok && ctx != nil {
+		tag = s.PickOutboundFor(ctx, tags)
+	} else {
+		tag = b.strategy.PickOutbound(tags)
+	}
 	if tag == "" {
 		return "", newError("balancing strategy returns empty tag")
 	}
 	return tag, nil
 }
 
+// skipOpenCircuits removes the outbounds failing fast after repeated dial failures.
+func (b *Balancer) skipOpenCircuits(tags []string) []string {
+	available := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if cb, ok := b.ohm.GetHandler(tag).(outbound.CircuitBreaker); ok && cb.IsCircuitOpen() {
+			continue
+		}
+		available = append(available, tag)
+	}
+	return available
+}
+
 func (b *Balancer) InjectContext(ctx context.Context) {
 	if contextReceiver, ok := b.strategy.(extension.ContextReceiver); ok {
 		contextReceiver.InjectContext(ctx)
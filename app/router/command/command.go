@@ -72,6 +72,66 @@ func (s *routingServer) SubscribeRoutingStats(request *SubscribeRoutingStatsRequ
 	}
 }
 
+func (s *routingServer) ruleManager() (routing.RuleManager, error) {
+	rm, ok := s.router.(routing.RuleManager)
+	if !ok {
+		return nil, newError("the router does not support changing rules")
+	}
+	return rm, nil
+}
+
+func (s *routingServer) AddRule(ctx context.Context, request *AddRuleRequest) (*AddRuleResponse, error) {
+	rm, err := s.ruleManager()
+	if err != nil {
+		return nil, err
+	}
+	if request.Config == nil {
+		return nil, newError("no router config")
+	}
+	if err := rm.AddRule(request.Config, request.ShouldAppend); err != nil {
+		return nil, err
+	}
+	return &AddRuleResponse{}, nil
+}
+
+func (s *routingServer) RemoveRule(ctx context.Context, request *RemoveRuleRequest) (*RemoveRuleResponse, error) {
+	rm, err := s.ruleManager()
+	if err != nil {
+		return nil, err
+	}
+	if err := rm.RemoveRule(request.RuleTag); err != nil {
+		return nil, err
+	}
+	return &RemoveRuleResponse{}, nil
+}
+
+func (s *routingServer) ListRule(ctx context.Context, request *ListRuleRequest) (*ListRuleResponse, error) {
+	rm, err := s.ruleManager()
+	if err != nil {
+		return nil, err
+	}
+	response := &ListRuleResponse{}
+	for _, rule := range rm.ListRule() {
+		response.Rules = append(response.Rules, &ListRuleItem{
+			RuleTag:     rule.RuleTag,
+			OutboundTag: rule.OutboundTag,
+			BalancerTag: rule.BalancerTag,
+		})
+	}
+	return response, nil
+}
+
+func (s *routingServer) OverrideBalancerTarget(ctx context.Context, request *OverrideBalancerTargetRequest) (*OverrideBalancerTargetResponse, error) {
+	rm, err := s.ruleManager()
+	if err != nil {
+		return nil, err
+	}
+	if err := rm.OverrideBalancer(request.BalancerTag, request.Target); err != nil {
+		return nil, err
+	}
+	return &OverrideBalancerTargetResponse{}, nil
+}
+
 func (s *routingServer) mustEmbedUnimplementedRoutingServiceServer() {}
 
 type service struct {
@@ -13,6 +13,8 @@ import (
 	"github.com/xtls/xray-core/app/stats"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/features/outbound"
 	"github.com/xtls/xray-core/features/routing"
 	"github.com/xtls/xray-core/testing/mocks"
 	"google.golang.org/grpc"
@@ -430,3 +432,93 @@ func TestSerivceTestRoute(t *testing.T) {
 		}
 	}
 }
+
+type fakeOutbound struct {
+	outbound.Handler
+}
+
+func TestServiceRuleManagement(t *testing.T) {
+	r := new(router.Router)
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockOhm := mocks.NewOutboundManager(mockCtl)
+	mockOhm.EXPECT().GetHandler("p2").Return(&fakeOutbound{}).AnyTimes()
+	mockOhm.EXPECT().GetHandler("missing").Return(nil).AnyTimes()
+	common.Must(r.Init(context.TODO(), &router.Config{
+		Rule: []*router.RoutingRule{
+			{
+				RuleTag:   "base",
+				Domain:    []*router.Domain{{Type: router.Domain_Domain, Value: "com"}},
+				TargetTag: &router.RoutingRule_Tag{Tag: "out"},
+			},
+		},
+	}, mocks.NewDNSClient(mockCtl), mockOhm))
+	s := NewRoutingServer(r, nil)
+	ctx := context.Background()
+
+	testRoute := func(rc *RoutingContext, expected string) {
+		t.Helper()
+		route, err := s.TestRoute(ctx, &TestRouteRequest{RoutingContext: rc})
+		common.Must(err)
+		if route.OutboundTag != expected {
+			t.Error("expected outbound ", expected, " but got ", route.OutboundTag)
+		}
+	}
+
+	_, err := s.AddRule(ctx, &AddRuleRequest{Config: serial.ToTypedMessage(&router.Config{
+		Rule: []*router.RoutingRule{
+			{
+				RuleTag:   "block",
+				Domain:    []*router.Domain{{Type: router.Domain_Full, Value: "example.com"}},
+				TargetTag: &router.RoutingRule_Tag{Tag: "blocked"},
+			},
+			{
+				RuleTag:    "balance",
+				InboundTag: []string{"in"},
+				TargetTag:  &router.RoutingRule_BalancingTag{BalancingTag: "b1"},
+			},
+		},
+		BalancingRule: []*router.BalancingRule{
+			{Tag: "b1", OutboundSelector: []string{"p"}},
+		},
+	})})
+	common.Must(err)
+	testRoute(&RoutingContext{TargetDomain: "example.com"}, "blocked")
+
+	list, err := s.ListRule(ctx, &ListRuleRequest{})
+	common.Must(err)
+	if r := cmp.Diff(list.Rules, []*ListRuleItem{
+		{RuleTag: "block", OutboundTag: "blocked"},
+		{RuleTag: "balance", BalancerTag: "b1"},
+		{RuleTag: "base", OutboundTag: "out"},
+	}, cmpopts.IgnoreUnexported(ListRuleItem{})); r != "" {
+		t.Error(r)
+	}
+
+	if _, err := s.AddRule(ctx, &AddRuleRequest{Config: serial.ToTypedMessage(&router.Config{
+		Rule: []*router.RoutingRule{{RuleTag: "block", TargetTag: &router.RoutingRule_Tag{Tag: "out"}}},
+	})}); err == nil {
+		t.Error("expected error adding a rule of an existing rule tag")
+	}
+
+	_, err = s.OverrideBalancerTarget(ctx, &OverrideBalancerTargetRequest{BalancerTag: "b1", Target: "p2"})
+	common.Must(err)
+	testRoute(&RoutingContext{InboundTag: "in"}, "p2")
+	if _, err := s.OverrideBalancerTarget(ctx, &OverrideBalancerTargetRequest{BalancerTag: "b1", Target: "missing"}); err == nil {
+		t.Error("expected error overriding with a missing outbound")
+	}
+
+	_, err = s.RemoveRule(ctx, &RemoveRuleRequest{RuleTag: "block"})
+	common.Must(err)
+	testRoute(&RoutingContext{TargetDomain: "example.com"}, "out")
+	if _, err := s.RemoveRule(ctx, &RemoveRuleRequest{RuleTag: "block"}); err == nil {
+		t.Error("expected error removing a removed rule")
+	}
+
+	// the balancer goes with the last rule using it
+	_, err = s.RemoveRule(ctx, &RemoveRuleRequest{RuleTag: "balance"})
+	common.Must(err)
+	if _, err := s.OverrideBalancerTarget(ctx, &OverrideBalancerTargetRequest{BalancerTag: "b1", Target: "p2"}); err == nil {
+		t.Error("expected balancer b1 to be removed")
+	}
+}
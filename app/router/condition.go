@@ -1,6 +1,7 @@
 package router
 
 import (
+	"path/filepath"
 	"strings"
 
 	"github.com/xtls/xray-core/common/net"
@@ -47,13 +48,22 @@ var matcherTypeMap = map[Domain_Type]strmatcher.Type{
 	Domain_Full:   strmatcher.Full,
 }
 
+// normalizePattern returns the pattern of a domain rule with internationalized domains in A-label form, as
+// they are matched.
+func normalizePattern(domain *Domain) string {
+	if domain.Type == Domain_Regex {
+		return domain.Value
+	}
+	return net.NormalizeDomain(domain.Value)
+}
+
 func domainToMatcher(domain *Domain) (strmatcher.Matcher, error) {
 	matcherType, f := matcherTypeMap[domain.Type]
 	if !f {
 		return nil, newError("unsupported domain type", domain.Type)
 	}
 
-	matcher, err := matcherType.New(domain.Value)
+	matcher, err := matcherType.New(normalizePattern(domain))
 	if err != nil {
 		return nil, newError("failed to create domain matcher").Base(err)
 	}
@@ -63,6 +73,7 @@ func domainToMatcher(domain *Domain) (strmatcher.Matcher, error) {
 
 type DomainMatcher struct {
 	matchers strmatcher.IndexMatcher
+	name     string
 }
 
 func NewMphMatcherGroup(domains []*Domain) (*DomainMatcher, error) {
@@ -72,7 +83,7 @@ func NewMphMatcherGroup(domains []*Domain) (*DomainMatcher, error) {
 		if !f {
 			return nil, newError("unsupported domain type", d.Type)
 		}
-		_, err := g.AddPattern(d.Value, matcherType)
+		_, err := g.AddPattern(normalizePattern(d), matcherType)
 		if err != nil {
 			return nil, err
 		}
@@ -99,7 +110,7 @@ func NewDomainMatcher(domains []*Domain) (*DomainMatcher, error) {
 }
 
 func (m *DomainMatcher) ApplyDomain(domain string) bool {
-	return len(m.matchers.Match(strings.ToLower(domain))) > 0
+	return len(m.matchers.Match(strings.ToLower(net.NormalizeDomain(domain)))) > 0
 }
 
 // Apply implements Condition.
@@ -111,6 +122,34 @@ func (m *DomainMatcher) Apply(ctx routing.Context) bool {
 	return m.ApplyDomain(domain)
 }
 
+// Match implements strmatcher.Matcher, so that DNS matches a geosite list with the matcher the router uses.
+func (m *DomainMatcher) Match(domain string) bool {
+	return m.ApplyDomain(domain)
+}
+
+// String implements strmatcher.Matcher.
+func (m *DomainMatcher) String() string {
+	return m.name
+}
+
+// anyDomainMatcher matches the domains that any of its matchers matches.
+type anyDomainMatcher []*DomainMatcher
+
+// Apply implements Condition.
+func (m anyDomainMatcher) Apply(ctx routing.Context) bool {
+	domain := ctx.GetTargetDomain()
+	if len(domain) == 0 {
+		return false
+	}
+	domain = strings.ToLower(net.NormalizeDomain(domain))
+	for _, matcher := range m {
+		if len(matcher.matchers.Match(domain)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 type MultiGeoIPMatcher struct {
 	matchers []*GeoIPMatcher
 	onSource bool
@@ -119,7 +158,9 @@ type MultiGeoIPMatcher struct {
 func NewMultiGeoIPMatcher(geoips []*GeoIP, onSource bool) (*MultiGeoIPMatcher, error) {
 	var matchers []*GeoIPMatcher
 	for _, geoip := range geoips {
+		globalGeoIPAccess.Lock()
 		matcher, err := globalGeoIPContainer.Add(geoip)
+		globalGeoIPAccess.Unlock()
 		if err != nil {
 			return nil, err
 		}
@@ -332,3 +373,48 @@ func (m *AttributeMatcher) Apply(ctx routing.Context) bool {
 	}
 	return m.Match(attributes)
 }
+
+// ProcessMatcher matches the local process the connection comes from, by its name or path.
+type ProcessMatcher struct {
+	names []string
+	paths []string
+}
+
+func NewProcessMatcher(processes []string) *ProcessMatcher {
+	m := new(ProcessMatcher)
+	for _, p := range processes {
+		switch {
+		case strings.ContainsAny(p, `/\`):
+			m.paths = append(m.paths, p)
+		case len(p) > 0:
+			m.names = append(m.names, p)
+		}
+	}
+	return m
+}
+
+// Apply implements Condition.
+func (m *ProcessMatcher) Apply(ctx routing.Context) bool {
+	ips := ctx.GetSourceIPs()
+	port := ctx.GetSourcePort()
+	if len(ips) == 0 || port == 0 {
+		return false
+	}
+	path, err := lookupProcess(ctx.GetNetwork(), ips[0], port)
+	if err != nil {
+		newError("failed to find the process of ", ips[0], ":", port).Base(err).AtDebug().WriteToLog()
+		return false
+	}
+	name := filepath.Base(path)
+	for _, n := range m.names {
+		if sameProcess(n, name) {
+			return true
+		}
+	}
+	for _, p := range m.paths {
+		if sameProcess(p, path) {
+			return true
+		}
+	}
+	return false
+}
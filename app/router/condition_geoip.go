@@ -3,6 +3,7 @@ package router
 import (
 	"encoding/binary"
 	"sort"
+	"sync"
 
 	"github.com/xtls/xray-core/common/net"
 )
@@ -201,4 +202,22 @@ func (c *GeoIPMatcherContainer) Add(geoip *GeoIP) (*GeoIPMatcher, error) {
 	return m, nil
 }
 
-var globalGeoIPContainer GeoIPMatcherContainer
+// Remove drops the GeoIPMatchers of the given country code, so that the GeoIPs added afterwards with it
+// get new ones.
+func (c *GeoIPMatcherContainer) Remove(countryCode string) {
+	if len(countryCode) == 0 {
+		return
+	}
+	matchers := make([]*GeoIPMatcher, 0, len(c.matchers))
+	for _, m := range c.matchers {
+		if m.countryCode != countryCode {
+			matchers = append(matchers, m)
+		}
+	}
+	c.matchers = matchers
+}
+
+var (
+	globalGeoIPAccess    sync.Mutex
+	globalGeoIPContainer GeoIPMatcherContainer
+)
@@ -0,0 +1,82 @@
+package router
+
+import (
+	"os"
+	"sync"
+
+	"github.com/xtls/xray-core/common/platform"
+	"github.com/xtls/xray-core/common/serial"
+)
+
+type geoSiteMatcherEntry struct {
+	file    string
+	stamp   string
+	matcher *DomainMatcher
+}
+
+// GeoSiteMatcherContainer keeps one compiled matcher for each list loaded from a geosite file, so that the
+// rules and DNS servers using the same list share it.
+type GeoSiteMatcherContainer struct {
+	matchers map[string]*geoSiteMatcherEntry
+}
+
+// Add returns the matcher of the list, compiling it the first time its file and code are added. Lists not
+// loaded from a file get a matcher of their own.
+func (c *GeoSiteMatcherContainer) Add(site *GeoSite) (*DomainMatcher, error) {
+	if site.File == "" {
+		return NewMphMatcherGroup(site.Domain)
+	}
+
+	// the file may have been replaced since, by a config loaded later in the same process
+	index := site.File + ":" + site.Code
+	stamp := assetStamp(site.File)
+	if e, found := c.matchers[index]; found && e.stamp == stamp {
+		return e.matcher, nil
+	}
+
+	m, err := NewMphMatcherGroup(site.Domain)
+	if err != nil {
+		return nil, err
+	}
+	m.name = index
+	if c.matchers == nil {
+		c.matchers = make(map[string]*geoSiteMatcherEntry)
+	}
+	c.matchers[index] = &geoSiteMatcherEntry{
+		file:    site.File,
+		stamp:   stamp,
+		matcher: m,
+	}
+	return m, nil
+}
+
+// Remove drops the matchers of the lists of the given file, so that the lists added afterwards get new ones.
+func (c *GeoSiteMatcherContainer) Remove(file string) {
+	for index, e := range c.matchers {
+		if e.file == file {
+			delete(c.matchers, index)
+		}
+	}
+}
+
+// assetStamp returns the size and modification time of an asset file, or nothing if they are unknown.
+func assetStamp(file string) string {
+	info, err := os.Stat(platform.GetAssetLocation(file))
+	if err != nil {
+		return ""
+	}
+	return serial.Concat(info.Size(), "@", info.ModTime().UnixNano())
+}
+
+var (
+	globalGeoSiteAccess    sync.Mutex
+	globalGeoSiteContainer GeoSiteMatcherContainer
+)
+
+// GetGeoSiteMatcher returns the compiled matcher of a geosite list, shared by the routing rules and the
+// DNS servers that use the list.
+func GetGeoSiteMatcher(site *GeoSite) (*DomainMatcher, error) {
+	globalGeoSiteAccess.Lock()
+	defer globalGeoSiteAccess.Unlock()
+	return globalGeoSiteContainer.Add(site)
+}
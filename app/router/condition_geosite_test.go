@@ -0,0 +1,92 @@
+package router
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/platform"
+	"github.com/xtls/xray-core/common/session"
+	routing_session "github.com/xtls/xray-core/features/routing/session"
+)
+
+func TestGeoSiteMatcherContainer(t *testing.T) {
+	writeGeoSite(t)
+
+	site := func(code string) *GeoSite {
+		return &GeoSite{
+			Domain: []*Domain{{Type: Domain_Domain, Value: "example.cn"}},
+			File:   "geosite.dat",
+			Code:   code,
+		}
+	}
+	container := &GeoSiteMatcherContainer{}
+	m1, err := container.Add(site("CN"))
+	common.Must(err)
+	if !m1.Match("www.example.cn") || m1.Match("example.com") {
+		t.Error("unexpected matches of ", m1)
+	}
+	if m, _ := container.Add(site("CN")); m != m1 {
+		t.Error("expected the same matcher for the same list")
+	}
+	if m, _ := container.Add(site("CN@ads")); m == m1 {
+		t.Error("expected another matcher for another list")
+	}
+	custom := &GeoSite{Domain: []*Domain{{Type: Domain_Domain, Value: "example.cn"}}}
+	if m, _ := container.Add(custom); m == m1 {
+		t.Error("expected a matcher of its own for a list not loaded from a file")
+	}
+
+	// the file is replaced, as by a config loaded later in the same process
+	later := time.Now().Add(time.Hour)
+	common.Must(os.Chtimes(platform.GetAssetLocation("geosite.dat"), later, later))
+	m2, _ := container.Add(site("CN"))
+	if m2 == m1 {
+		t.Error("expected a new matcher once the file changed")
+	}
+
+	container.Remove("geosite.dat")
+	if m, _ := container.Add(site("CN")); m == m2 {
+		t.Error("expected a new matcher after the file is removed")
+	}
+}
+
+func TestBuildConditionSharesGeoSite(t *testing.T) {
+	writeGeoSite(t)
+
+	site := func() *GeoSite {
+		return &GeoSite{
+			CountryCode: "CN",
+			Domain:      []*Domain{{Type: Domain_Domain, Value: "example.cn"}},
+			File:        "geosite.dat",
+			Code:        "CN",
+		}
+	}
+	shared, err := GetGeoSiteMatcher(site())
+	common.Must(err)
+
+	cond, err := (&RoutingRule{Geosite: []*GeoSite{site()}}).BuildCondition()
+	common.Must(err)
+	if c := *cond.(*ConditionChan); len(c) != 1 || c[0] != shared {
+		t.Error("expected the rule to use the shared matcher, but got ", c)
+	}
+
+	cond, err = (&RoutingRule{
+		Domain:  []*Domain{{Type: Domain_Full, Value: "example.com"}},
+		Geosite: []*GeoSite{site()},
+	}).BuildCondition()
+	common.Must(err)
+	for domain, expected := range map[string]bool{
+		"www.example.cn":     true,
+		"example.com":        true,
+		"www.example.com":    false,
+		"www.example.cn.org": false,
+	} {
+		ctx := &routing_session.Context{Outbound: &session.Outbound{Target: net.TCPDestination(net.DomainAddress(domain), 80)}}
+		if cond.Apply(ctx) != expected {
+			t.Error("expected ", domain, " to match ", expected)
+		}
+	}
+}
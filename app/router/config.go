@@ -46,14 +46,23 @@ func (l *CIDRList) Swap(i int, j int) {
 }
 
 type Rule struct {
-	Tag       string
-	Balancer  *Balancer
-	Condition Condition
+	RuleTag    string
+	Tag        string
+	StandbyTag string
+	Sockopt    *routing.SockoptOverride
+	Balancer   *Balancer
+	Condition  Condition
+	// ResolveDomain makes the IP conditions match the IPs of the target domain.
+	ResolveDomain bool
+
+	// config is what the rule is built from, without the content of geoip and geosite files, which
+	// are loaded again when updated. Nil if the rule uses no such file.
+	config *RoutingRule
 }
 
-func (r *Rule) GetTag() (string, error) {
+func (r *Rule) GetTag(ctx routing.Context) (string, error) {
 	if r.Balancer != nil {
-		return r.Balancer.PickOutbound()
+		return r.Balancer.PickOutbound(ctx)
 	}
 	return r.Tag, nil
 }
@@ -66,23 +75,51 @@ func (r *Rule) Apply(ctx routing.Context) bool {
 func (rr *RoutingRule) BuildCondition() (Condition, error) {
 	conds := NewConditionChan()
 
-	if len(rr.Domain) > 0 {
-		switch rr.DomainMatcher {
-		case "linear":
-			matcher, err := NewDomainMatcher(rr.Domain)
+	switch rr.DomainMatcher {
+	case "linear":
+		domains := rr.Domain
+		if len(rr.Geosite) > 0 {
+			domains = append([]*Domain(nil), rr.Domain...)
+			for _, site := range rr.Geosite {
+				domains = append(domains, site.Domain...)
+			}
+		}
+		if len(domains) > 0 {
+			matcher, err := NewDomainMatcher(domains)
 			if err != nil {
 				return nil, newError("failed to build domain condition").Base(err)
 			}
 			conds.Add(matcher)
-		case "mph", "hybrid":
-			fallthrough
-		default:
+		}
+	case "mph", "hybrid":
+		fallthrough
+	default:
+		// geosite lists get the matchers shared with other rules and DNS, the other domains one of the rule
+		var matchers anyDomainMatcher
+		for _, site := range rr.Geosite {
+			if len(site.Domain) == 0 {
+				continue
+			}
+			matcher, err := GetGeoSiteMatcher(site)
+			if err != nil {
+				return nil, newError("failed to build domain condition of geosite ", site.CountryCode).Base(err)
+			}
+			matchers = append(matchers, matcher)
+		}
+		if len(rr.Domain) > 0 {
 			matcher, err := NewMphMatcherGroup(rr.Domain)
 			if err != nil {
 				return nil, newError("failed to build domain condition with MphDomainMatcher").Base(err)
 			}
 			newError("MphDomainMatcher is enabled for ", len(rr.Domain), " domain rule(s)").AtDebug().WriteToLog()
-			conds.Add(matcher)
+			matchers = append(matchers, matcher)
+		}
+		switch len(matchers) {
+		case 0:
+		case 1:
+			conds.Add(matchers[0])
+		default:
+			conds.Add(matchers)
 		}
 	}
 
@@ -150,6 +187,11 @@ func (rr *RoutingRule) BuildCondition() (Condition, error) {
 		conds.Add(cond)
 	}
 
+	// the last one, as finding the process is the slowest
+	if len(rr.Process) > 0 {
+		conds.Add(NewProcessMatcher(rr.Process))
+	}
+
 	if conds.Len() == 0 {
 		return nil, newError("this rule has no effective fields").AtWarning()
 	}
@@ -161,14 +203,30 @@ func (br *BalancingRule) Build(ohm outbound.Manager) (*Balancer, error) {
 	switch br.Strategy {
 	case "leastPing":
 		return &Balancer{
+			tag:       br.Tag,
 			selectors: br.OutboundSelector,
 			strategy:  &LeastPingStrategy{},
 			ohm:       ohm,
 		}, nil
+	case "roundRobin":
+		return &Balancer{
+			tag:       br.Tag,
+			selectors: br.OutboundSelector,
+			strategy:  &RoundRobinStrategy{},
+			ohm:       ohm,
+		}, nil
+	case "consistentHash":
+		return &Balancer{
+			tag:       br.Tag,
+			selectors: br.OutboundSelector,
+			strategy:  &ConsistentHashStrategy{key: br.HashKey},
+			ohm:       ohm,
+		}, nil
 	case "random":
 		fallthrough
 	default:
 		return &Balancer{
+			tag:       br.Tag,
 			selectors: br.OutboundSelector,
 			strategy:  &RandomStrategy{},
 			ohm:       ohm,
@@ -0,0 +1,310 @@
+package router
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/xtls/xray-core/common/platform/filesystem"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// LoadGeoEntry reads the entry of the given code from a geoip or geosite file. Entries are read one
+// by one, and the ones of other codes are skipped, so the whole file is never held in memory.
+func LoadGeoEntry(file, code string) ([]byte, error) {
+	if code == "" {
+		return nil, nil
+	}
+	reader, err := filesystem.OpenAsset(file)
+	if err != nil {
+		return nil, newError("failed to open file: ", file).Base(err)
+	}
+	defer reader.Close()
+
+	r := bufio.NewReader(reader)
+	for {
+		// each entry is field 1 of the list, and starts with its code as field 1
+		if _, err := r.ReadByte(); err != nil {
+			if err == io.EOF {
+				return nil, nil
+			}
+			return nil, err
+		}
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, newError("invalid file: ", file).Base(err)
+		}
+		head, err := r.Peek(2 + len(code))
+		if err == nil && int(head[1]) == len(code) && string(head[2:]) == code {
+			entry := make([]byte, size)
+			if _, err := io.ReadFull(r, entry); err != nil {
+				return nil, newError("invalid file: ", file).Base(err)
+			}
+			return entry, nil
+		}
+		if _, err := r.Discard(int(size)); err != nil {
+			return nil, newError("invalid file: ", file).Base(err)
+		}
+	}
+}
+
+// geoLoader loads the entries of geoip and geosite files, each one once.
+type geoLoader struct {
+	ips   map[string][]*CIDR
+	sites map[string][]*Domain
+}
+
+func (l *geoLoader) loadIP(file, code string) ([]*CIDR, error) {
+	index := file + ":" + code
+	if cidrs, found := l.ips[index]; found {
+		return cidrs, nil
+	}
+	bs, err := LoadGeoEntry(file, code)
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, newError("code not found in ", file, ": ", code)
+	}
+	var geoip GeoIP
+	if err := proto.Unmarshal(bs, &geoip); err != nil {
+		return nil, newError("error unmarshal IP in ", file, ": ", code).Base(err)
+	}
+	if l.ips == nil {
+		l.ips = make(map[string][]*CIDR)
+	}
+	l.ips[index] = geoip.Cidr
+	return geoip.Cidr, nil
+}
+
+// loadSite loads a list of a geosite file. Its code may be followed by attributes, as in CN@ads,
+// to keep only the domains that have all of them.
+func (l *geoLoader) loadSite(file, code string) ([]*Domain, error) {
+	index := file + ":" + code
+	if domains, found := l.sites[index]; found {
+		return domains, nil
+	}
+	parts := strings.Split(code, "@")
+	country := strings.ToUpper(parts[0])
+	bs, err := LoadGeoEntry(file, country)
+	if err != nil {
+		return nil, err
+	}
+	if bs == nil {
+		return nil, newError("list not found in ", file, ": ", country)
+	}
+	var geosite GeoSite
+	if err := proto.Unmarshal(bs, &geosite); err != nil {
+		return nil, newError("error unmarshal Site in ", file, ": ", country).Base(err)
+	}
+	domains := geosite.Domain
+	if len(parts) > 1 {
+		domains = make([]*Domain, 0, len(geosite.Domain))
+		for _, domain := range geosite.Domain {
+			if hasAttributes(domain, parts[1:]) {
+				domains = append(domains, domain)
+			}
+		}
+	}
+	if l.sites == nil {
+		l.sites = make(map[string][]*Domain)
+	}
+	l.sites[index] = domains
+	return domains, nil
+}
+
+func hasAttributes(domain *Domain, attrs []string) bool {
+	for _, attr := range attrs {
+		attr = strings.ToLower(attr)
+		found := false
+		for _, a := range domain.Attribute {
+			if a.Key == attr {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// usesFiles returns whether the rule has entries loaded from geoip or geosite files.
+func (rr *RoutingRule) usesFiles() bool {
+	for _, geoip := range rr.Geoip {
+		if geoip.File != "" {
+			return true
+		}
+	}
+	for _, geoip := range rr.SourceGeoip {
+		if geoip.File != "" {
+			return true
+		}
+	}
+	for _, site := range rr.Geosite {
+		if site.File != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// usesFile returns whether the rule has entries loaded from the given file.
+func (rr *RoutingRule) usesFile(file string) bool {
+	for _, geoip := range rr.Geoip {
+		if geoip.File == file {
+			return true
+		}
+	}
+	for _, geoip := range rr.SourceGeoip {
+		if geoip.File == file {
+			return true
+		}
+	}
+	for _, site := range rr.Geosite {
+		if site.File == file {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutFileContent returns a copy of the rule without what was loaded from files, which is all
+// the router keeps to load it again.
+func (rr *RoutingRule) withoutFileContent() *RoutingRule {
+	// the other fields are shared rather than copied, as rules are not changed once built
+	c := new(RoutingRule)
+	dst := c.ProtoReflect()
+	rr.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch fd.Name() {
+		case "geoip", "source_geoip", "geosite":
+		default:
+			dst.Set(fd, v)
+		}
+		return true
+	})
+
+	c.Geoip = geoIPsWithoutFileContent(rr.Geoip)
+	c.SourceGeoip = geoIPsWithoutFileContent(rr.SourceGeoip)
+	for _, site := range rr.Geosite {
+		s := &GeoSite{
+			CountryCode: site.CountryCode,
+			File:        site.File,
+			Code:        site.Code,
+		}
+		if site.File == "" {
+			s.Domain = site.Domain
+		}
+		c.Geosite = append(c.Geosite, s)
+	}
+	return c
+}
+
+func geoIPsWithoutFileContent(geoips []*GeoIP) []*GeoIP {
+	var c []*GeoIP
+	for _, geoip := range geoips {
+		g := &GeoIP{
+			CountryCode:  geoip.CountryCode,
+			ReverseMatch: geoip.ReverseMatch,
+			File:         geoip.File,
+			Code:         geoip.Code,
+		}
+		if geoip.File == "" {
+			g.Cidr = geoip.Cidr
+		}
+		c = append(c, g)
+	}
+	return c
+}
+
+// loadFiles returns a copy of a rule kept by withoutFileContent, with its entries loaded from the files.
+func (rr *RoutingRule) loadFiles(loader *geoLoader) (*RoutingRule, error) {
+	c := rr.withoutFileContent()
+	for _, geoips := range [][]*GeoIP{c.Geoip, c.SourceGeoip} {
+		for _, geoip := range geoips {
+			if geoip.File == "" {
+				continue
+			}
+			cidrs, err := loader.loadIP(geoip.File, geoip.Code)
+			if err != nil {
+				return nil, err
+			}
+			geoip.Cidr = cidrs
+		}
+	}
+	for _, site := range c.Geosite {
+		if site.File == "" {
+			continue
+		}
+		domains, err := loader.loadSite(site.File, site.Code)
+		if err != nil {
+			return nil, err
+		}
+		site.Domain = domains
+	}
+	return c, nil
+}
+
+// ReloadAsset loads the rules that use the given geoip or geosite file again, after the file got
+// updated, and replaces them all at once. Rules that fail to load keep the old content.
+func (r *Router) ReloadAsset(file string) error {
+	r.access.RLock()
+	rules, balancers := r.rules, r.balancers
+	r.access.RUnlock()
+
+	var configs []*RoutingRule
+	var reloading []*Rule
+	for _, rule := range rules {
+		if rule.config != nil && rule.config.usesFile(file) {
+			configs = append(configs, rule.config)
+			reloading = append(reloading, rule)
+		}
+	}
+	if len(reloading) == 0 {
+		return nil
+	}
+
+	// matchers of the same country code, and of the same geosite list, are shared, and have to be built anew
+	globalGeoIPAccess.Lock()
+	for _, config := range configs {
+		for _, geoip := range append(append([]*GeoIP(nil), config.Geoip...), config.SourceGeoip...) {
+			if geoip.File == file {
+				globalGeoIPContainer.Remove(geoip.CountryCode)
+			}
+		}
+	}
+	globalGeoIPAccess.Unlock()
+	globalGeoSiteAccess.Lock()
+	globalGeoSiteContainer.Remove(file)
+	globalGeoSiteAccess.Unlock()
+
+	loader := new(geoLoader)
+	reloaded := make(map[*Rule]*Rule, len(reloading))
+	for i, rule := range reloading {
+		config, err := configs[i].loadFiles(loader)
+		if err != nil {
+			return newError("failed to reload ", file).Base(err)
+		}
+		rr, err := buildRule(config, balancers)
+		if err != nil {
+			return newError("failed to reload ", file).Base(err)
+		}
+		reloaded[rule] = rr
+	}
+
+	r.access.Lock()
+	defer r.access.Unlock()
+
+	current := make([]*Rule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		if rr, found := reloaded[rule]; found {
+			rule = rr
+		}
+		current = append(current, rule)
+	}
+	r.rules = current
+	return nil
+}
@@ -0,0 +1,76 @@
+package router
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/xtls/xray-core/common"
+)
+
+func writeGeoSite(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XRAY_LOCATION_ASSET", dir)
+
+	list := &GeoSiteList{
+		Entry: []*GeoSite{
+			{
+				CountryCode: "CNX",
+				Domain:      []*Domain{{Type: Domain_Domain, Value: "example.net"}},
+			},
+			{
+				CountryCode: "CN",
+				Domain: []*Domain{
+					{Type: Domain_Domain, Value: "example.cn"},
+					{Type: Domain_Full, Value: "ads.example.cn", Attribute: []*Domain_Attribute{{Key: "ads"}}},
+				},
+			},
+		},
+	}
+	content, err := proto.Marshal(list)
+	common.Must(err)
+	common.Must(os.WriteFile(filepath.Join(dir, "geosite.dat"), content, 0o600))
+}
+
+func TestLoadGeoEntry(t *testing.T) {
+	writeGeoSite(t)
+
+	bs, err := LoadGeoEntry("geosite.dat", "CN")
+	common.Must(err)
+	var site GeoSite
+	common.Must(proto.Unmarshal(bs, &site))
+	if site.CountryCode != "CN" || len(site.Domain) != 2 {
+		t.Error("unexpected entry: ", site.CountryCode, " with ", len(site.Domain), " domains")
+	}
+
+	if bs, err := LoadGeoEntry("geosite.dat", "US"); err != nil || bs != nil {
+		t.Error("expected no entry for a missing code, got ", len(bs), " bytes and ", err)
+	}
+	if _, err := LoadGeoEntry("missing.dat", "CN"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestGeoLoaderSite(t *testing.T) {
+	writeGeoSite(t)
+
+	var l geoLoader
+	domains, err := l.loadSite("geosite.dat", "cn@ads")
+	common.Must(err)
+	if len(domains) != 1 || domains[0].Value != "ads.example.cn" {
+		t.Error("unexpected domains with attribute: ", domains)
+	}
+
+	first, err := l.loadSite("geosite.dat", "cn")
+	common.Must(err)
+	second, err := l.loadSite("geosite.dat", "cn")
+	common.Must(err)
+	if len(first) != 2 || &first[0] != &second[0] {
+		t.Error("expected a list to be loaded once")
+	}
+
+	if _, err := l.loadSite("geosite.dat", "us"); err == nil {
+		t.Error("expected an error for a missing list")
+	}
+}
@@ -0,0 +1,59 @@
+package router
+
+import (
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+// processCacheTTL is how long the process of a local socket is remembered, so that the rules of a connection
+// and the packets of a UDP socket look it up only once.
+const processCacheTTL = 2 * time.Second
+
+type processKey struct {
+	network net.Network
+	ip      string
+	port    net.Port
+}
+
+type processEntry struct {
+	path   string
+	err    error
+	expire time.Time
+}
+
+var processCache = struct {
+	sync.Mutex
+	entries   map[processKey]processEntry
+	nextPrune time.Time
+}{
+	entries: make(map[processKey]processEntry),
+}
+
+// lookupProcess is findProcess through the process cache.
+func lookupProcess(network net.Network, ip net.IP, port net.Port) (string, error) {
+	key := processKey{network: network, ip: string(ip.To16()), port: port}
+	now := time.Now()
+	processCache.Lock()
+	entry, found := processCache.entries[key]
+	processCache.Unlock()
+	if found && now.Before(entry.expire) {
+		return entry.path, entry.err
+	}
+
+	path, err := findProcess(network, ip, port)
+
+	processCache.Lock()
+	defer processCache.Unlock()
+	if now.After(processCache.nextPrune) {
+		for k, e := range processCache.entries {
+			if now.After(e.expire) {
+				delete(processCache.entries, k)
+			}
+		}
+		processCache.nextPrune = now.Add(processCacheTTL)
+	}
+	processCache.entries[key] = processEntry{path: path, err: err, expire: now.Add(processCacheTTL)}
+	return path, err
+}
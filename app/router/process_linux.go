@@ -0,0 +1,145 @@
+package router
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+// byte order of the words of the addresses in /proc/net, which is the one of the host
+var procNetByteOrder binary.ByteOrder = binary.LittleEndian
+
+func init() {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 0 {
+		procNetByteOrder = binary.BigEndian
+	}
+}
+
+// sameProcess returns whether the names or paths of processes are the same.
+func sameProcess(a, b string) bool {
+	return a == b
+}
+
+// findProcess returns the executable path of the local process whose socket has the given address.
+// The socket is looked up in /proc/net for its inode, which is then looked up in the descriptors
+// of the processes.
+func findProcess(network net.Network, ip net.IP, port net.Port) (string, error) {
+	var tables []string
+	switch network {
+	case net.Network_TCP:
+		tables = []string{"/proc/net/tcp", "/proc/net/tcp6"}
+	case net.Network_UDP:
+		tables = []string{"/proc/net/udp", "/proc/net/udp6"}
+	default:
+		return "", newError("unsupported network: ", network)
+	}
+	for _, table := range tables {
+		inode, uid, err := findSocket(table, network, ip, port)
+		if err != nil {
+			return "", err
+		}
+		if inode == "" {
+			continue
+		}
+		pid, err := findSocketOwner(inode, uid)
+		if err != nil {
+			return "", err
+		}
+		return os.Readlink("/proc/" + pid + "/exe")
+	}
+	return "", newError("socket not found")
+}
+
+// findSocket returns the inode and the uid of the socket of the given address in a /proc/net table,
+// or an empty inode if there is none. UDP sockets may be bound to an unspecified address.
+func findSocket(table string, network net.Network, ip net.IP, port net.Port) (string, int, error) {
+	content, err := os.ReadFile(table)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", 0, nil
+		}
+		return "", 0, err
+	}
+	lines := strings.Split(string(content), "\n")
+	for _, line := range lines[1:] {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		fields := strings.Fields(line)
+		if len(fields) < 10 || fields[9] == "0" {
+			continue
+		}
+		address, portHex, found := strings.Cut(fields[1], ":")
+		if !found {
+			continue
+		}
+		if p, err := strconv.ParseUint(portHex, 16, 16); err != nil || net.Port(p) != port {
+			continue
+		}
+		local, err := parseProcNetAddress(address)
+		if err != nil {
+			continue
+		}
+		if !local.Equal(ip) && !(network == net.Network_UDP && local.IsUnspecified()) {
+			continue
+		}
+		uid, err := strconv.Atoi(fields[7])
+		if err != nil {
+			return "", 0, newError("invalid uid in ", table).Base(err)
+		}
+		return fields[9], uid, nil
+	}
+	return "", 0, nil
+}
+
+func parseProcNetAddress(s string) (net.IP, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != net.IPv4len && len(b) != net.IPv6len {
+		return nil, newError("invalid address: ", s)
+	}
+	for i := 0; i < len(b); i += 4 {
+		procNetByteOrder.PutUint32(b[i:], binary.BigEndian.Uint32(b[i:]))
+	}
+	return net.IP(b), nil
+}
+
+// findSocketOwner returns the pid of the process holding the socket of the inode, among the ones of
+// the uid of the socket.
+func findSocketOwner(inode string, uid int) (string, error) {
+	target := "socket:[" + inode + "]"
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return "", err
+	}
+	for _, proc := range procs {
+		if _, err := strconv.Atoi(proc.Name()); err != nil || !proc.IsDir() {
+			continue
+		}
+		info, err := proc.Info()
+		if err != nil {
+			continue
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != uid {
+			continue
+		}
+		dir := "/proc/" + proc.Name() + "/fd/"
+		fds, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			if link, err := os.Readlink(dir + fd.Name()); err == nil && link == target {
+				return proc.Name(), nil
+			}
+		}
+	}
+	return "", newError("owner of socket ", inode, " not found")
+}
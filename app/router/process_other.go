@@ -0,0 +1,16 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package router
+
+import (
+	"github.com/xtls/xray-core/common/net"
+)
+
+func sameProcess(a, b string) bool {
+	return a == b
+}
+
+func findProcess(network net.Network, ip net.IP, port net.Port) (string, error) {
+	return "", newError("process is not supported on this platform")
+}
@@ -0,0 +1,61 @@
+//go:build linux || windows
+// +build linux windows
+
+package router_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	. "github.com/xtls/xray-core/app/router"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	routing_session "github.com/xtls/xray-core/features/routing/session"
+)
+
+func TestProcessMatcher(t *testing.T) {
+	executable, err := os.Executable()
+	common.Must(err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	common.Must(err)
+	defer listener.Close()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	common.Must(err)
+	defer conn.Close()
+
+	udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	common.Must(err)
+	defer udpConn.Close()
+
+	for _, source := range []net.Destination{
+		net.DestinationFromAddr(conn.LocalAddr()),
+		net.DestinationFromAddr(udpConn.LocalAddr()),
+	} {
+		ctx := session.ContextWithInbound(context.Background(), &session.Inbound{Source: source})
+		ctx = session.ContextWithOutbound(ctx, &session.Outbound{
+			Target: net.Destination{Network: source.Network, Address: net.DomainAddress("example.com"), Port: 80},
+		})
+		routingCtx := routing_session.AsRoutingContext(ctx)
+
+		for _, test := range []struct {
+			processes []string
+			output    bool
+		}{
+			{[]string{filepath.Base(executable)}, true},
+			{[]string{executable}, true},
+			// names and paths are case sensitive except on Windows
+			{[]string{strings.ToUpper(filepath.Base(executable))}, runtime.GOOS == "windows"},
+			{[]string{"nonexistent", "/usr/bin/nonexistent"}, false},
+		} {
+			if v := NewProcessMatcher(test.processes).Apply(routingCtx); v != test.output {
+				t.Error("unexpected output ", v, " for ", test.processes, " from ", source)
+			}
+		}
+	}
+}
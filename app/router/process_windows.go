@@ -0,0 +1,144 @@
+package router
+
+import (
+	"encoding/binary"
+	"strings"
+	"unsafe"
+
+	"github.com/xtls/xray-core/common/net"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	iphlpapi                = windows.NewLazySystemDLL("iphlpapi.dll")
+	procGetExtendedTcpTable = iphlpapi.NewProc("GetExtendedTcpTable")
+	procGetExtendedUdpTable = iphlpapi.NewProc("GetExtendedUdpTable")
+)
+
+const (
+	tcpTableOwnerPIDAll = 5
+	udpTableOwnerPID    = 1
+)
+
+// socketTable describes the rows of a table of GetExtendedTcpTable or GetExtendedUdpTable.
+type socketTable struct {
+	proc   *windows.LazyProc
+	family uint32
+	class  uint32
+	// size of a row, and offsets of the fields of a row
+	rowSize, addrOffset, portOffset, pidOffset int
+}
+
+var (
+	// MIB_TCPROW_OWNER_PID and MIB_TCP6ROW_OWNER_PID
+	tcpTables = []socketTable{
+		{procGetExtendedTcpTable, windows.AF_INET, tcpTableOwnerPIDAll, 24, 4, 8, 20},
+		{procGetExtendedTcpTable, windows.AF_INET6, tcpTableOwnerPIDAll, 56, 0, 20, 52},
+	}
+	// MIB_UDPROW_OWNER_PID and MIB_UDP6ROW_OWNER_PID
+	udpTables = []socketTable{
+		{procGetExtendedUdpTable, windows.AF_INET, udpTableOwnerPID, 12, 0, 4, 8},
+		{procGetExtendedUdpTable, windows.AF_INET6, udpTableOwnerPID, 28, 0, 20, 24},
+	}
+)
+
+// sameProcess returns whether the names or paths of processes are the same, which ignores the case on Windows.
+func sameProcess(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
+
+// findProcess returns the executable path of the local process whose socket has the given address.
+func findProcess(network net.Network, ip net.IP, port net.Port) (string, error) {
+	var tables []socketTable
+	switch network {
+	case net.Network_TCP:
+		tables = tcpTables
+	case net.Network_UDP:
+		tables = udpTables
+	default:
+		return "", newError("unsupported network: ", network)
+	}
+	for _, table := range tables {
+		pid, found, err := table.findOwner(network, ip, port)
+		if err != nil {
+			return "", err
+		}
+		if found {
+			return processPath(pid)
+		}
+	}
+	return "", newError("socket not found")
+}
+
+// findOwner returns the pid of the process of the socket of the given address.
+func (t *socketTable) findOwner(network net.Network, ip net.IP, port net.Port) (uint32, bool, error) {
+	b, err := t.get()
+	if err != nil {
+		return 0, false, err
+	}
+	pid, found := t.findRow(b, network, ip, port)
+	return pid, found, nil
+}
+
+// findRow returns the pid of the row of the given address in the table b. UDP sockets may be bound to an
+// unspecified address.
+func (t *socketTable) findRow(b []byte, network net.Network, ip net.IP, port net.Port) (uint32, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+	addrLen := net.IPv4len
+	if t.family == windows.AF_INET6 {
+		addrLen = net.IPv6len
+	}
+	n := int(binary.LittleEndian.Uint32(b))
+	for i := 0; i < n && 4+(i+1)*t.rowSize <= len(b); i++ {
+		row := b[4+i*t.rowSize : 4+(i+1)*t.rowSize]
+		// the port is in network byte order in the low bytes
+		if net.Port(binary.BigEndian.Uint16(row[t.portOffset:])) != port {
+			continue
+		}
+		local := net.IP(row[t.addrOffset : t.addrOffset+addrLen])
+		if !local.Equal(ip) && !(network == net.Network_UDP && local.IsUnspecified()) {
+			continue
+		}
+		return binary.LittleEndian.Uint32(row[t.pidOffset:]), true
+	}
+	return 0, false
+}
+
+func (t *socketTable) get() ([]byte, error) {
+	var size uint32
+	for {
+		var b []byte
+		var p uintptr
+		if size > 0 {
+			b = make([]byte, size)
+			p = uintptr(unsafe.Pointer(&b[0]))
+		}
+		r, _, _ := t.proc.Call(p, uintptr(unsafe.Pointer(&size)), 0, uintptr(t.family), uintptr(t.class), 0)
+		switch errno := windows.Errno(r); errno {
+		case 0:
+			return b[:size], nil
+		case windows.ERROR_INSUFFICIENT_BUFFER:
+			// the table may grow between the calls
+			continue
+		default:
+			return nil, newError("failed to get socket table").Base(errno)
+		}
+	}
+}
+
+func processPath(pid uint32) (string, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		return "", newError("failed to open process ", pid).Base(err)
+	}
+	defer windows.CloseHandle(h)
+
+	path := make([]uint16, windows.MAX_LONG_PATH)
+	size := uint32(len(path))
+	if err := windows.QueryFullProcessImageName(h, 0, &path[0], &size); err != nil {
+		return "", newError("failed to get the path of process ", pid).Base(err)
+	}
+	return windows.UTF16ToString(path[:size]), nil
+}
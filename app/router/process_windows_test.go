@@ -0,0 +1,50 @@
+package router
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+// rowLayout is the layout of a row of a socket table, as documented for MIB_TCPROW_OWNER_PID and the others.
+type rowLayout struct {
+	size, addr, port, pid int
+}
+
+func (l rowLayout) putRow(b []byte, ip net.IP, port net.Port, pid uint32) []byte {
+	row := make([]byte, l.size)
+	copy(row[l.addr:], ip)
+	binary.BigEndian.PutUint16(row[l.port:], uint16(port))
+	binary.LittleEndian.PutUint32(row[l.pid:], pid)
+	binary.LittleEndian.PutUint32(b, binary.LittleEndian.Uint32(b)+1)
+	return append(b, row...)
+}
+
+func TestSocketTable(t *testing.T) {
+	for _, test := range []struct {
+		table   socketTable
+		layout  rowLayout
+		network net.Network
+		ip      net.IP
+	}{
+		// dwState, dwLocalAddr, dwLocalPort, dwRemoteAddr, dwRemotePort, dwOwningPid
+		{tcpTables[0], rowLayout{24, 4, 8, 20}, net.Network_TCP, net.IP{127, 0, 0, 1}},
+		// ucLocalAddr, dwLocalScopeId, dwLocalPort, ucRemoteAddr, dwRemoteScopeId, dwRemotePort, dwState, dwOwningPid
+		{tcpTables[1], rowLayout{56, 0, 20, 52}, net.Network_TCP, net.LocalHostIPv6.IP()},
+		// dwLocalAddr, dwLocalPort, dwOwningPid
+		{udpTables[0], rowLayout{12, 0, 4, 8}, net.Network_UDP, net.IP{127, 0, 0, 1}},
+		// ucLocalAddr, dwLocalScopeId, dwLocalPort, dwOwningPid
+		{udpTables[1], rowLayout{28, 0, 20, 24}, net.Network_UDP, net.LocalHostIPv6.IP()},
+	} {
+		b := make([]byte, 4)
+		b = test.layout.putRow(b, test.ip, 1000, 1)
+		b = test.layout.putRow(b, test.ip, 2000, 2)
+		if pid, found := test.table.findRow(b, test.network, test.ip, 2000); !found || pid != 2 {
+			t.Error("unexpected owner ", pid, " of ", test.network, " ", test.ip)
+		}
+		if _, found := test.table.findRow(b, test.network, test.ip, 3000); found {
+			t.Error("unexpected owner of ", test.network, " port 3000")
+		}
+	}
+}
@@ -4,8 +4,10 @@ package router
 
 import (
 	"context"
+	"sync"
 
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/serial"
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/dns"
 	"github.com/xtls/xray-core/features/outbound"
@@ -15,10 +17,17 @@ import (
 
 // Router is an implementation of routing.Router.
 type Router struct {
+	ctx            context.Context
+	ohm            outbound.Manager
 	domainStrategy Config_DomainStrategy
-	rules          []*Rule
-	balancers      map[string]*Balancer
 	dns            dns.Client
+
+	// access guards the rules and the balancers, which are replaced rather than changed in place.
+	access    sync.RWMutex
+	rules     []*Rule
+	balancers map[string]*Balancer
+	// tags of the balancers added along with rules, which are removed with the last rule using them
+	addedBalancers map[string]bool
 }
 
 // Route is an implementation of routing.Route.
@@ -26,44 +35,220 @@ type Route struct {
 	routing.Context
 	outboundGroupTags []string
 	outboundTag       string
+	standbyTag        string
+	sockopt           *routing.SockoptOverride
 }
 
 // Init initializes the Router.
 func (r *Router) Init(ctx context.Context, config *Config, d dns.Client, ohm outbound.Manager) error {
+	r.ctx = ctx
+	r.ohm = ohm
 	r.domainStrategy = config.DomainStrategy
 	r.dns = d
 
-	r.balancers = make(map[string]*Balancer, len(config.BalancingRule))
-	for _, rule := range config.BalancingRule {
-		balancer, err := rule.Build(ohm)
+	balancers, err := r.buildBalancers(config.BalancingRule, nil)
+	if err != nil {
+		return err
+	}
+	r.balancers = balancers
+
+	r.rules = make([]*Rule, 0, len(config.Rule))
+	for _, rule := range config.Rule {
+		rr, err := buildRule(rule, r.balancers)
 		if err != nil {
 			return err
 		}
-		balancer.InjectContext(ctx)
-		r.balancers[rule.Tag] = balancer
+		r.rules = append(r.rules, rr)
 	}
 
-	r.rules = make([]*Rule, 0, len(config.Rule))
-	for _, rule := range config.Rule {
-		cond, err := rule.BuildCondition()
+	return nil
+}
+
+// buildBalancers returns the balancers of existing, and the ones of rules.
+func (r *Router) buildBalancers(rules []*BalancingRule, existing map[string]*Balancer) (map[string]*Balancer, error) {
+	balancers := make(map[string]*Balancer, len(existing)+len(rules))
+	for tag, balancer := range existing {
+		balancers[tag] = balancer
+	}
+	for _, rule := range rules {
+		balancer, err := rule.Build(r.ohm)
+		if err != nil {
+			return nil, err
+		}
+		balancer.InjectContext(r.ctx)
+		balancers[rule.Tag] = balancer
+	}
+	return balancers, nil
+}
+
+func buildRule(rule *RoutingRule, balancers map[string]*Balancer) (*Rule, error) {
+	cond, err := rule.BuildCondition()
+	if err != nil {
+		return nil, err
+	}
+	rr := &Rule{
+		RuleTag:       rule.RuleTag,
+		Condition:     cond,
+		Tag:           rule.GetTag(),
+		StandbyTag:    rule.StandbyTag,
+		ResolveDomain: rule.ResolveDomain,
+	}
+	if rule.usesFiles() {
+		rr.config = rule.withoutFileContent()
+	}
+	if rule.Mark != 0 || rule.Interface != "" || rule.Dscp != 0 {
+		rr.Sockopt = &routing.SockoptOverride{
+			Mark:      rule.Mark,
+			Interface: rule.Interface,
+			DSCP:      rule.Dscp,
+		}
+	}
+	btag := rule.GetBalancingTag()
+	if len(btag) > 0 {
+		brule, found := balancers[btag]
+		if !found {
+			return nil, newError("balancer ", btag, " not found")
+		}
+		rr.Balancer = brule
+	}
+	return rr, nil
+}
+
+// AddRule implements routing.RuleManager.
+func (r *Router) AddRule(config *serial.TypedMessage, shouldAppend bool) error {
+	instance, err := config.GetInstance()
+	if err != nil {
+		return newError("failed to parse router config").Base(err)
+	}
+	c, ok := instance.(*Config)
+	if !ok {
+		return newError("not a router config: ", config.Type)
+	}
+
+	r.access.Lock()
+	defer r.access.Unlock()
+
+	for _, rule := range c.BalancingRule {
+		if _, found := r.balancers[rule.Tag]; found {
+			return newError("balancer ", rule.Tag, " already exists")
+		}
+	}
+	balancers, err := r.buildBalancers(c.BalancingRule, r.balancers)
+	if err != nil {
+		return err
+	}
+
+	ruleTags := make(map[string]bool, len(r.rules)+len(c.Rule))
+	for _, rule := range r.rules {
+		ruleTags[rule.RuleTag] = true
+	}
+	added := make([]*Rule, 0, len(c.Rule))
+	for _, rule := range c.Rule {
+		if rule.RuleTag == "" {
+			return newError("rules added at runtime must have a rule tag")
+		}
+		if ruleTags[rule.RuleTag] {
+			return newError("rule ", rule.RuleTag, " already exists")
+		}
+		ruleTags[rule.RuleTag] = true
+		rr, err := buildRule(rule, balancers)
 		if err != nil {
 			return err
 		}
-		rr := &Rule{
-			Condition: cond,
-			Tag:       rule.GetTag(),
+		added = append(added, rr)
+	}
+
+	rules := make([]*Rule, 0, len(r.rules)+len(added))
+	if shouldAppend {
+		rules = append(append(rules, r.rules...), added...)
+	} else {
+		rules = append(append(rules, added...), r.rules...)
+	}
+	r.rules = rules
+	r.balancers = balancers
+	for _, rule := range c.BalancingRule {
+		if r.addedBalancers == nil {
+			r.addedBalancers = make(map[string]bool)
 		}
-		btag := rule.GetBalancingTag()
-		if len(btag) > 0 {
-			brule, found := r.balancers[btag]
-			if !found {
-				return newError("balancer ", btag, " not found")
+		r.addedBalancers[rule.Tag] = true
+	}
+	return nil
+}
+
+// RemoveRule implements routing.RuleManager.
+func (r *Router) RemoveRule(tag string) error {
+	if tag == "" {
+		return newError("empty rule tag")
+	}
+
+	r.access.Lock()
+	defer r.access.Unlock()
+
+	for i, rule := range r.rules {
+		if rule.RuleTag == tag {
+			rules := make([]*Rule, 0, len(r.rules)-1)
+			r.rules = append(append(rules, r.rules[:i]...), r.rules[i+1:]...)
+			if rule.Balancer != nil {
+				r.removeUnusedBalancer(rule.Balancer.tag)
 			}
-			rr.Balancer = brule
+			return nil
 		}
-		r.rules = append(r.rules, rr)
 	}
+	return newError("rule ", tag, " not found")
+}
+
+// removeUnusedBalancer removes the balancer if it was added along with rules and no rule uses it anymore.
+func (r *Router) removeUnusedBalancer(tag string) {
+	if !r.addedBalancers[tag] {
+		return
+	}
+	for _, rule := range r.rules {
+		if rule.Balancer != nil && rule.Balancer.tag == tag {
+			return
+		}
+	}
+	balancers := make(map[string]*Balancer, len(r.balancers))
+	for t, b := range r.balancers {
+		if t != tag {
+			balancers[t] = b
+		}
+	}
+	r.balancers = balancers
+	delete(r.addedBalancers, tag)
+}
+
+// ListRule implements routing.RuleManager.
+func (r *Router) ListRule() []routing.RuleInfo {
+	r.access.RLock()
+	defer r.access.RUnlock()
+
+	infos := make([]routing.RuleInfo, 0, len(r.rules))
+	for _, rule := range r.rules {
+		info := routing.RuleInfo{
+			RuleTag:     rule.RuleTag,
+			OutboundTag: rule.Tag,
+		}
+		if rule.Balancer != nil {
+			info.BalancerTag = rule.Balancer.tag
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// OverrideBalancer implements routing.RuleManager.
+func (r *Router) OverrideBalancer(balancer string, target string) error {
+	r.access.RLock()
+	defer r.access.RUnlock()
 
+	b, found := r.balancers[balancer]
+	if !found {
+		return newError("balancer ", balancer, " not found")
+	}
+	if target != "" && r.ohm.GetHandler(target) == nil {
+		return newError("outbound ", target, " not found")
+	}
+	b.override.Store(target)
 	return nil
 }
 
@@ -73,11 +258,11 @@ func (r *Router) PickRoute(ctx routing.Context) (routing.Route, error) {
 	if err != nil {
 		return nil, err
 	}
-	tag, err := rule.GetTag()
+	tag, err := rule.GetTag(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return &Route{Context: ctx, outboundTag: tag}, nil
+	return &Route{Context: ctx, outboundTag: tag, standbyTag: rule.StandbyTag, sockopt: rule.Sockopt}, nil
 }
 
 func (r *Router) pickRouteInternal(ctx routing.Context) (*Rule, routing.Context, error) {
@@ -86,11 +271,30 @@ func (r *Router) pickRouteInternal(ctx routing.Context) (*Rule, routing.Context,
 	// this prevents cycle resolving dead loop
 	skipDNSResolve := ctx.GetSkipDNSResolve()
 
+	// the context resolving the target domain, shared by the rules resolving it so that it is resolved once
+	var resolvable routing.Context
 	if r.domainStrategy == Config_IpOnDemand && !skipDNSResolve {
 		ctx = routing_dns.ContextWithDNSClient(ctx, r.dns)
+		resolvable = ctx
+	}
+	resolve := func() routing.Context {
+		if resolvable == nil {
+			resolvable = routing_dns.ContextWithDNSClient(ctx, r.dns)
+		}
+		return resolvable
 	}
 
-	for _, rule := range r.rules {
+	r.access.RLock()
+	rules := r.rules
+	r.access.RUnlock()
+
+	for _, rule := range rules {
+		if rule.ResolveDomain && !skipDNSResolve && len(ctx.GetTargetDomain()) > 0 {
+			if rctx := resolve(); rule.Apply(rctx) {
+				return rule, rctx, nil
+			}
+			continue
+		}
 		if rule.Apply(ctx) {
 			return rule, ctx, nil
 		}
@@ -100,10 +304,10 @@ func (r *Router) pickRouteInternal(ctx routing.Context) (*Rule, routing.Context,
 		return nil, ctx, common.ErrNoClue
 	}
 
-	ctx = routing_dns.ContextWithDNSClient(ctx, r.dns)
+	ctx = resolve()
 
 	// Try applying rules again if we have IPs.
-	for _, rule := range r.rules {
+	for _, rule := range rules {
 		if rule.Apply(ctx) {
 			return rule, ctx, nil
 		}
@@ -137,6 +341,16 @@ func (r *Route) GetOutboundTag() string {
 	return r.outboundTag
 }
 
+// GetStandbyTag implements routing.StandbyRoute.
+func (r *Route) GetStandbyTag() string {
+	return r.standbyTag
+}
+
+// GetSockoptOverride implements routing.SockoptRoute.
+func (r *Route) GetSockoptOverride() *routing.SockoptOverride {
+	return r.sockopt
+}
+
 func init() {
 	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
 		r := new(Router)
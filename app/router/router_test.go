@@ -2,12 +2,15 @@ package router_test
 
 import (
 	"context"
+	"os"
 	"testing"
 
 	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
 	. "github.com/xtls/xray-core/app/router"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/platform"
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/features/dns"
 	"github.com/xtls/xray-core/features/outbound"
@@ -79,6 +82,7 @@ func TestSimpleBalancer(t *testing.T) {
 	mockHs := mocks.NewOutboundHandlerSelector(mockCtl)
 
 	mockHs.EXPECT().Select(gomock.Eq([]string{"test-"})).Return([]string{"test"})
+	mockOhm.EXPECT().GetHandler(gomock.Eq("test")).Return(nil)
 
 	r := new(Router)
 	common.Must(r.Init(context.TODO(), config, mockDNS, &mockOutboundManager{
@@ -205,3 +209,132 @@ func TestIPIfNonMatchIP(t *testing.T) {
 		t.Error("expect tag 'test', bug actually ", tag)
 	}
 }
+
+func TestResolveDomainRule(t *testing.T) {
+	config := &Config{
+		Rule: []*RoutingRule{
+			{
+				TargetTag:     &RoutingRule_Tag{Tag: "private"},
+				Cidr:          []*CIDR{{Ip: []byte{10, 0, 0, 0}, Prefix: 8}},
+				ResolveDomain: true,
+			},
+			{
+				TargetTag: &RoutingRule_Tag{Tag: "unresolved"},
+				Cidr:      []*CIDR{{Ip: []byte{192, 168, 0, 0}, Prefix: 16}},
+			},
+			{
+				TargetTag:     &RoutingRule_Tag{Tag: "test"},
+				Cidr:          []*CIDR{{Ip: []byte{192, 168, 0, 0}, Prefix: 16}},
+				ResolveDomain: true,
+			},
+		},
+	}
+
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+
+	mockDNS := mocks.NewDNSClient(mockCtl)
+	mockDNS.EXPECT().LookupIP(gomock.Eq("example.com"), dns.IPOption{
+		IPv4Enable: true,
+		IPv6Enable: true,
+		FakeEnable: false,
+	}).Return([]net.IP{{192, 168, 0, 1}}, nil).Times(1)
+
+	r := new(Router)
+	common.Must(r.Init(context.TODO(), config, mockDNS, nil))
+
+	ctx := session.ContextWithOutbound(context.Background(), &session.Outbound{Target: net.TCPDestination(net.DomainAddress("example.com"), 80)})
+	route, err := r.PickRoute(routing_session.AsRoutingContext(ctx))
+	common.Must(err)
+	if tag := route.GetOutboundTag(); tag != "test" {
+		t.Error("expect tag 'test', bug actually ", tag)
+	}
+}
+
+func TestReloadAsset(t *testing.T) {
+	t.Setenv("xray.location.asset", t.TempDir())
+	writeAsset := func(file string, m proto.Message) {
+		content, err := proto.Marshal(m)
+		common.Must(err)
+		common.Must(os.WriteFile(platform.GetAssetLocation(file), content, 0o644))
+	}
+	writeSites := func(domain string) {
+		writeAsset("site.dat", &GeoSiteList{
+			Entry: []*GeoSite{{CountryCode: "TEST", Domain: []*Domain{{Type: Domain_Domain, Value: domain}}}},
+		})
+	}
+	writeIPs := func(ip byte) {
+		writeAsset("ip.dat", &GeoIPList{
+			Entry: []*GeoIP{{CountryCode: "TEST", Cidr: []*CIDR{{Ip: []byte{ip, ip, ip, ip}, Prefix: 32}}}},
+		})
+	}
+	writeSites("old.com")
+	writeIPs(1)
+
+	config := &Config{
+		Rule: []*RoutingRule{
+			{
+				TargetTag: &RoutingRule_Tag{Tag: "site"},
+				Geosite: []*GeoSite{{
+					CountryCode: "SITE.DAT_TEST",
+					Domain:      []*Domain{{Type: Domain_Domain, Value: "old.com"}},
+					File:        "site.dat",
+					Code:        "test",
+				}},
+			},
+			{
+				TargetTag: &RoutingRule_Tag{Tag: "ip"},
+				Geoip: []*GeoIP{{
+					CountryCode: "IP.DAT_TEST",
+					Cidr:        []*CIDR{{Ip: []byte{1, 1, 1, 1}, Prefix: 32}},
+					File:        "ip.dat",
+					Code:        "TEST",
+				}},
+			},
+		},
+	}
+
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+
+	mockDNS := mocks.NewDNSClient(mockCtl)
+	mockOhm := mocks.NewOutboundManager(mockCtl)
+	mockHs := mocks.NewOutboundHandlerSelector(mockCtl)
+
+	r := new(Router)
+	common.Must(r.Init(context.TODO(), config, mockDNS, &mockOutboundManager{
+		Manager:         mockOhm,
+		HandlerSelector: mockHs,
+	}))
+
+	pickTag := func(address net.Address) string {
+		ctx := session.ContextWithOutbound(context.Background(), &session.Outbound{Target: net.TCPDestination(address, 80)})
+		route, err := r.PickRoute(routing_session.AsRoutingContext(ctx))
+		if err != nil {
+			return ""
+		}
+		return route.GetOutboundTag()
+	}
+	if tag := pickTag(net.DomainAddress("old.com")); tag != "site" {
+		t.Error("expect tag 'site', but actually ", tag)
+	}
+	if tag := pickTag(net.ParseAddress("1.1.1.1")); tag != "ip" {
+		t.Error("expect tag 'ip', but actually ", tag)
+	}
+
+	writeSites("new.com")
+	writeIPs(2)
+	common.Must(r.ReloadAsset("site.dat"))
+	common.Must(r.ReloadAsset("ip.dat"))
+
+	for address, expected := range map[net.Address]string{
+		net.DomainAddress("old.com"): "",
+		net.DomainAddress("new.com"): "site",
+		net.ParseAddress("1.1.1.1"):  "",
+		net.ParseAddress("2.2.2.2"):  "ip",
+	} {
+		if tag := pickTag(address); tag != expected {
+			t.Error("expect tag ", expected, " for ", address, ", but actually ", tag)
+		}
+	}
+}
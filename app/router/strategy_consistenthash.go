@@ -0,0 +1,69 @@
+package router
+
+import (
+	"hash/fnv"
+
+	"github.com/xtls/xray-core/features/routing"
+)
+
+// ConsistentHashStrategy picks the same outbound for the connections of the same target or source, so that
+// long-lived sessions stick to it. When the selected outbounds change, only the connections of the outbounds
+// gone or added move, as the outbound is picked by rendezvous hashing.
+type ConsistentHashStrategy struct {
+	key      BalancingRule_HashKey
+	fallback RandomStrategy
+}
+
+func (s *ConsistentHashStrategy) PickOutbound(tags []string) string {
+	return s.fallback.PickOutbound(tags)
+}
+
+func (s *ConsistentHashStrategy) PickOutboundFor(ctx routing.Context, tags []string) string {
+	key := s.hashKey(ctx)
+	if key == "" {
+		return s.fallback.PickOutbound(tags)
+	}
+
+	var picked string
+	var highest uint64
+	for _, tag := range tags {
+		if weight := rendezvousWeight(key, tag); picked == "" || weight > highest {
+			picked = tag
+			highest = weight
+		}
+	}
+	return picked
+}
+
+func (s *ConsistentHashStrategy) hashKey(ctx routing.Context) string {
+	switch s.key {
+	case BalancingRule_Source:
+		if ips := ctx.GetSourceIPs(); len(ips) > 0 {
+			return ips[0].String()
+		}
+	default:
+		if domain := ctx.GetTargetDomain(); domain != "" {
+			return domain
+		}
+		if ips := ctx.GetTargetIPs(); len(ips) > 0 {
+			return ips[0].String()
+		}
+	}
+	return ""
+}
+
+// rendezvousWeight returns the weight of tag for key. The tag of the highest weight is picked.
+func rendezvousWeight(key, tag string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write([]byte(tag))
+	// fnv alone spreads similar keys poorly, so its bits are mixed as in splitmix64
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
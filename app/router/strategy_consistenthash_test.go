@@ -0,0 +1,51 @@
+package router
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/features/routing"
+	routing_session "github.com/xtls/xray-core/features/routing/session"
+)
+
+func TestConsistentHashStrategy(t *testing.T) {
+	routingContext := func(source string, target net.Destination) routing.Context {
+		ctx := session.ContextWithInbound(context.Background(), &session.Inbound{
+			Source: net.TCPDestination(net.ParseAddress(source), 1234),
+		})
+		ctx = session.ContextWithOutbound(ctx, &session.Outbound{Target: target})
+		return routing_session.AsRoutingContext(ctx)
+	}
+	tags := []string{"a", "b", "c", "d"}
+
+	s := &ConsistentHashStrategy{key: BalancingRule_Target}
+	target := net.TCPDestination(net.DomainAddress("example.com"), 443)
+	tag := s.PickOutboundFor(routingContext("10.0.0.1", target), tags)
+	if other := s.PickOutboundFor(routingContext("10.0.0.2", target), tags); other != tag {
+		t.Error("picked ", other, " for the same target, want ", tag)
+	}
+
+	// only the connections of an outbound gone move
+	picked := make(map[string]string)
+	counts := make(map[string]int)
+	s = &ConsistentHashStrategy{key: BalancingRule_Source}
+	for i := 0; i < 1000; i++ {
+		source := "10.0." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256)
+		picked[source] = s.PickOutboundFor(routingContext(source, target), tags)
+		counts[picked[source]]++
+	}
+	for _, tag := range tags {
+		if counts[tag] < 150 {
+			t.Error("unevenly spread ", counts)
+		}
+	}
+	for source, tag := range picked {
+		other := s.PickOutboundFor(routingContext(source, target), []string{"a", "b", "c"})
+		if tag != "d" && other != tag {
+			t.Fatal("picked ", other, " for ", source, " without d, want ", tag)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package router
 
 import (
 	"context"
+	"time"
 
 	"github.com/xtls/xray-core/app/observatory"
 	"github.com/xtls/xray-core/common"
@@ -9,9 +10,24 @@ import (
 	"github.com/xtls/xray-core/features/extension"
 )
 
+// An outbound is considered alive for leastPingStaleCycles probe cycles of the observatory after its last
+// successful probe, or for leastPingStaleAfter if the observatory doesn't tell its cycle. When no selected
+// outbound was seen alive that recently, as when the observatory does not probe them, the outbound is picked at
+// random instead.
+const (
+	leastPingStaleCycles = 2
+	leastPingStaleAfter  = 5 * time.Minute
+)
+
+// probeCycler is an observatory that tells how long probing all its outbounds takes.
+type probeCycler interface {
+	ProbeCycle() time.Duration
+}
+
 type LeastPingStrategy struct {
 	ctx         context.Context
 	observatory extension.Observatory
+	fallback    RandomStrategy
 }
 
 func (l *LeastPingStrategy) InjectContext(ctx context.Context) {
@@ -25,30 +41,46 @@ func (l *LeastPingStrategy) PickOutbound(strings []string) string {
 			return nil
 		}))
 	}
+	if l.observatory == nil {
+		newError("no observatory for leastPing balancing, picking at random").AtDebug().WriteToLog()
+		return l.fallback.PickOutbound(strings)
+	}
 
 	observeReport, err := l.observatory.GetObservation(l.ctx)
 	if err != nil {
-		newError("cannot get observe report").Base(err).WriteToLog()
-		return ""
+		newError("cannot get observe report, picking at random").Base(err).WriteToLog()
+		return l.fallback.PickOutbound(strings)
 	}
 	outboundsList := outboundList(strings)
 	if result, ok := observeReport.(*observatory.ObservationResult); ok {
 		status := result.Status
 		leastPing := int64(99999999)
 		selectedOutboundName := ""
+		staleBefore := time.Now().Add(-l.staleAfter()).Unix()
 		for _, v := range status {
-			if outboundsList.contains(v.OutboundTag) && v.Alive && v.Delay < leastPing {
+			if outboundsList.contains(v.OutboundTag) && v.Alive && v.LastSeenTime >= staleBefore && v.Delay < leastPing {
 				selectedOutboundName = v.OutboundTag
 				leastPing = v.Delay
 			}
 		}
-		return selectedOutboundName
+		if selectedOutboundName != "" {
+			return selectedOutboundName
+		}
+		newError("no recent probe of selected outbounds alive, picking at random").AtDebug().WriteToLog()
+		return l.fallback.PickOutbound(strings)
 	}
 
 	// No way to understand observeReport
 	return ""
 }
 
+func (l *LeastPingStrategy) staleAfter() time.Duration {
+	if c, ok := l.observatory.(probeCycler); ok {
+		return leastPingStaleCycles * c.ProbeCycle()
+	}
+	return leastPingStaleAfter
+}
+
 type outboundList []string
 
 func (o outboundList) contains(name string) bool {
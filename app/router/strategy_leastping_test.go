@@ -0,0 +1,80 @@
+package router
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/xtls/xray-core/app/observatory"
+	"github.com/xtls/xray-core/features/extension"
+)
+
+type testObservatory struct {
+	extension.Observatory
+	status []*observatory.OutboundStatus
+}
+
+func (o *testObservatory) GetObservation(ctx context.Context) (proto.Message, error) {
+	return &observatory.ObservationResult{Status: o.status}, nil
+}
+
+func TestLeastPingStrategy(t *testing.T) {
+	now := time.Now().Unix()
+	stale := time.Now().Add(-2 * leastPingStaleAfter).Unix()
+	o := &testObservatory{status: []*observatory.OutboundStatus{
+		{OutboundTag: "slow", Alive: true, Delay: 300, LastSeenTime: now},
+		{OutboundTag: "fast", Alive: true, Delay: 100, LastSeenTime: now},
+		{OutboundTag: "dead", Alive: false, Delay: 99999999, LastSeenTime: stale},
+		{OutboundTag: "stale", Alive: true, Delay: 10, LastSeenTime: stale},
+	}}
+	s := &LeastPingStrategy{ctx: context.Background(), observatory: o}
+
+	if tag := s.PickOutbound([]string{"slow", "fast", "dead", "stale"}); tag != "fast" {
+		t.Error("expected fast, but got ", tag)
+	}
+	if tag := s.PickOutbound([]string{"slow", "dead"}); tag != "slow" {
+		t.Error("expected slow, but got ", tag)
+	}
+
+	candidates := []string{"dead", "stale", "unknown"}
+	picked := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		picked[s.PickOutbound(candidates)] = true
+	}
+	for _, tag := range candidates {
+		if !picked[tag] {
+			t.Error("expected random pick to include ", tag, ", but got ", picked)
+		}
+	}
+}
+
+type testProbeCycler struct {
+	testObservatory
+	cycle time.Duration
+}
+
+func (o *testProbeCycler) ProbeCycle() time.Duration {
+	return o.cycle
+}
+
+func TestLeastPingStrategyProbeCycle(t *testing.T) {
+	// probed 15 minutes ago, with 10 outbounds probed one a minute
+	seen := time.Now().Add(-15 * time.Minute).Unix()
+	o := &testProbeCycler{
+		testObservatory: testObservatory{status: []*observatory.OutboundStatus{
+			{OutboundTag: "slow", Alive: true, Delay: 300, LastSeenTime: time.Now().Unix()},
+			{OutboundTag: "fast", Alive: true, Delay: 100, LastSeenTime: seen},
+		}},
+		cycle: 10 * time.Minute,
+	}
+	s := &LeastPingStrategy{ctx: context.Background(), observatory: o}
+	if tag := s.PickOutbound([]string{"slow", "fast"}); tag != "fast" {
+		t.Error("expected fast, but got ", tag)
+	}
+
+	o.cycle = time.Minute
+	if tag := s.PickOutbound([]string{"slow", "fast"}); tag != "slow" {
+		t.Error("expected slow, but got ", tag)
+	}
+}
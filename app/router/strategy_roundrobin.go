@@ -0,0 +1,22 @@
+package router
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// RoundRobinStrategy picks the selected outbounds in turn.
+type RoundRobinStrategy struct {
+	next uint32
+}
+
+func (s *RoundRobinStrategy) PickOutbound(tags []string) string {
+	n := len(tags)
+	if n == 0 {
+		panic("0 tags")
+	}
+
+	// the outbounds are selected in no particular order
+	sort.Strings(tags)
+	return tags[int((atomic.AddUint32(&s.next, 1)-1)%uint32(n))]
+}
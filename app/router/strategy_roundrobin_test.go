@@ -0,0 +1,18 @@
+package router
+
+import (
+	"testing"
+)
+
+func TestRoundRobinStrategy(t *testing.T) {
+	s := &RoundRobinStrategy{}
+	var picked []string
+	for i := 0; i < 6; i++ {
+		picked = append(picked, s.PickOutbound([]string{"c", "a", "b"}))
+	}
+	for i, tag := range []string{"a", "b", "c", "a", "b", "c"} {
+		if picked[i] != tag {
+			t.Fatal("unexpected picks ", picked)
+		}
+	}
+}
@@ -5,6 +5,7 @@ package command
 import (
 	"context"
 	"runtime"
+	"sort"
 	"time"
 
 	"github.com/xtls/xray-core/app/stats"
@@ -101,6 +102,43 @@ func (s *statsServer) GetSysStats(ctx context.Context, request *SysStatsRequest)
 	return response, nil
 }
 
+func (s *statsServer) GetUserLogins(ctx context.Context, request *GetUserLoginsRequest) (*GetUserLoginsResponse, error) {
+	matcher, err := strmatcher.Substr.New(request.Pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	manager, ok := s.stats.(*stats.Manager)
+	if !ok {
+		return nil, newError("GetUserLogins only works its own stats.Manager.")
+	}
+
+	response := &GetUserLoginsResponse{}
+	manager.VisitLogins(func(email string, logins []feature_stats.Login) bool {
+		if !matcher.Match(email) || (request.Before > 0 && len(logins) > 0 && logins[0].Time.Unix() >= request.Before) {
+			return true
+		}
+		user := &UserLogins{Email: email}
+		for _, l := range logins {
+			login := &Login{
+				Time:       l.Time.Unix(),
+				InboundTag: l.InboundTag,
+			}
+			if l.Source != nil {
+				login.Source = l.Source.String()
+			}
+			user.Login = append(user.Login, login)
+		}
+		response.User = append(response.User, user)
+		return true
+	})
+	sort.Slice(response.User, func(i, j int) bool {
+		return response.User[i].Email < response.User[j].Email
+	})
+
+	return response, nil
+}
+
 func (s *statsServer) mustEmbedUnimplementedStatsServiceServer() {}
 
 type service struct {
@@ -3,12 +3,15 @@ package command_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/xtls/xray-core/app/stats"
 	. "github.com/xtls/xray-core/app/stats/command"
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	feature_stats "github.com/xtls/xray-core/features/stats"
 )
 
 func TestGetStats(t *testing.T) {
@@ -89,3 +92,49 @@ func TestQueryStats(t *testing.T) {
 		t.Error(r)
 	}
 }
+
+func TestGetUserLogins(t *testing.T) {
+	m, err := stats.NewManager(context.Background(), &stats.Config{})
+	common.Must(err)
+
+	login := func(email string, source string, unix int64) {
+		m.RecordLogin(email, feature_stats.Login{
+			Time:       time.Unix(unix, 0),
+			Source:     net.ParseAddress(source),
+			InboundTag: "in",
+		})
+	}
+	login("stale@example.com", "1.1.1.1", 100)
+	login("shared@example.com", "2.2.2.2", 200)
+	login("shared@example.com", "3.3.3.3", 300)
+	login("shared@example.com", "2.2.2.2", 400)
+	// throttled
+	login("shared@example.com", "2.2.2.2", 410)
+
+	s := NewStatsServer(m)
+	resp, err := s.GetUserLogins(context.Background(), &GetUserLoginsRequest{})
+	common.Must(err)
+	if r := cmp.Diff(resp.User, []*UserLogins{
+		{Email: "shared@example.com", Login: []*Login{
+			{Time: 400, Source: "2.2.2.2", InboundTag: "in"},
+			{Time: 300, Source: "3.3.3.3", InboundTag: "in"},
+		}},
+		{Email: "stale@example.com", Login: []*Login{
+			{Time: 100, Source: "1.1.1.1", InboundTag: "in"},
+		}},
+	}, cmpopts.IgnoreUnexported(UserLogins{}, Login{})); r != "" {
+		t.Error(r)
+	}
+
+	m.AddUser("new@example.com")
+	resp, err = s.GetUserLogins(context.Background(), &GetUserLoginsRequest{Before: 300})
+	common.Must(err)
+	if r := cmp.Diff(resp.User, []*UserLogins{
+		{Email: "new@example.com"},
+		{Email: "stale@example.com", Login: []*Login{
+			{Time: 100, Source: "1.1.1.1", InboundTag: "in"},
+		}},
+	}, cmpopts.IgnoreUnexported(UserLogins{}, Login{})); r != "" {
+		t.Error(r)
+	}
+}
@@ -5,18 +5,35 @@ package stats
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/features/stats"
 )
 
+const (
+	// logins kept per user, from distinct sources
+	maxLoginSources = 8
+	// a login from the source of the last one is recorded again only after this time, as every
+	// connection is a login
+	loginInterval = time.Minute
+)
+
 // Manager is an implementation of stats.Manager.
 type Manager struct {
 	access   sync.RWMutex
 	counters map[string]*Counter
 	channels map[string]*Channel
 	running  bool
+
+	// *userLogins by email
+	logins sync.Map
+}
+
+type userLogins struct {
+	access sync.Mutex
+	logins []stats.Login
 }
 
 // NewManager creates an instance of Statistics Manager.
@@ -83,6 +100,70 @@ func (m *Manager) VisitCounters(visitor func(string, stats.Counter) bool) {
 	}
 }
 
+func (m *Manager) getUserLogins(email string) *userLogins {
+	if u, found := m.logins.Load(email); found {
+		return u.(*userLogins)
+	}
+	u, _ := m.logins.LoadOrStore(email, new(userLogins))
+	return u.(*userLogins)
+}
+
+// RecordLogin implements stats.LoginRecorder.
+func (m *Manager) RecordLogin(email string, login stats.Login) {
+	u := m.getUserLogins(email)
+	u.access.Lock()
+	defer u.access.Unlock()
+
+	if len(u.logins) > 0 && u.logins[0].Source == login.Source && login.Time.Sub(u.logins[0].Time) < loginInterval {
+		return
+	}
+	logins := make([]stats.Login, 0, maxLoginSources)
+	logins = append(logins, login)
+	for _, l := range u.logins {
+		if len(logins) == maxLoginSources {
+			break
+		}
+		if l.Source != login.Source {
+			logins = append(logins, l)
+		}
+	}
+	u.logins = logins
+}
+
+// GetLogins implements stats.LoginRecorder.
+func (m *Manager) GetLogins(email string) []stats.Login {
+	u, found := m.logins.Load(email)
+	if !found {
+		return nil
+	}
+	return u.(*userLogins).get()
+}
+
+func (u *userLogins) get() []stats.Login {
+	u.access.Lock()
+	defer u.access.Unlock()
+
+	return append([]stats.Login(nil), u.logins...)
+}
+
+// AddUser implements stats.LoginRecorder.
+func (m *Manager) AddUser(email string) {
+	m.getUserLogins(email)
+}
+
+// RemoveUser implements stats.LoginRecorder.
+func (m *Manager) RemoveUser(email string) {
+	m.logins.Delete(email)
+}
+
+// VisitLogins calls visitor function on the logins of all known users, which are the last ones from
+// their recent distinct sources, the latest first. Users who never logged in have no logins.
+func (m *Manager) VisitLogins(visitor func(string, []stats.Login) bool) {
+	m.logins.Range(func(email, u interface{}) bool {
+		return visitor(email.(string), u.(*userLogins).get())
+	})
+}
+
 // RegisterChannel implements stats.Manager.
 func (m *Manager) RegisterChannel(name string) (stats.Channel, error) {
 	m.access.Lock()
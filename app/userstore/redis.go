@@ -0,0 +1,289 @@
+package userstore
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+)
+
+// Keys of the Redis store. The set xray:inbounds holds the tags of inbounds, the set xray:users:<tag> the
+// emails of an inbound, and the hash xray:user:<tag>:<email> a user, with the fields level, accountType,
+// account, uplink, downlink and lastLogin.
+const (
+	redisInbounds    = "xray:inbounds"
+	redisUsersKey    = "xray:users:"
+	redisUserKey     = "xray:user:"
+	redisDialTimeout = 5 * time.Second
+	// timeout of a pipeline of commands, so that a stuck server doesn't hold the store forever
+	redisCommandTimeout = 10 * time.Second
+)
+
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// redisStore is a minimal client of the Redis protocol, with just the commands the store needs.
+type redisStore struct {
+	config *Config
+
+	access sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func newRedisStore(config *Config) (Store, error) {
+	if config.Address == "" {
+		return nil, newError("empty Redis address")
+	}
+	return &redisStore{config: config}, nil
+}
+
+func (s *redisStore) connect() error {
+	dialer := net.Dialer{Timeout: redisDialTimeout}
+	conn, err := dialer.Dial("tcp", s.config.Address)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	s.reader = bufio.NewReader(conn)
+
+	var setup [][]string
+	if s.config.Password != "" {
+		setup = append(setup, []string{"AUTH", s.config.Password})
+	}
+	if s.config.Database != 0 {
+		setup = append(setup, []string{"SELECT", strconv.FormatUint(uint64(s.config.Database), 10)})
+	}
+	if len(setup) > 0 {
+		if _, err := s.pipeline(setup); err != nil {
+			s.disconnect()
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *redisStore) disconnect() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// do sends the commands at once and returns their replies. Replies that are errors are returned as redisError.
+func (s *redisStore) do(commands [][]string) ([]interface{}, error) {
+	s.access.Lock()
+	defer s.access.Unlock()
+
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return nil, newError("failed to connect to Redis ", s.config.Address).Base(err)
+		}
+	}
+	replies, err := s.pipeline(commands)
+	if err != nil {
+		s.disconnect()
+	}
+	return replies, err
+}
+
+func (s *redisStore) pipeline(commands [][]string) ([]interface{}, error) {
+	if err := s.conn.SetDeadline(time.Now().Add(redisCommandTimeout)); err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(s.conn)
+	for _, command := range commands {
+		w.WriteString("*" + strconv.Itoa(len(command)) + "\r\n")
+		for _, arg := range command {
+			w.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+
+	replies := make([]interface{}, len(commands))
+	for i := range commands {
+		reply, err := s.readReply()
+		if err != nil {
+			return nil, err
+		}
+		if e, ok := reply.(redisError); ok && (commands[i][0] == "AUTH" || commands[i][0] == "SELECT") {
+			return nil, e
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+func (s *redisStore) readReply() (interface{}, error) {
+	line, err := s.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, newError("invalid Redis reply: ", line)
+	}
+	kind, value := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return value, nil
+	case '-':
+		return redisError(value), nil
+	case ':':
+		return strconv.ParseInt(value, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(s.reader, b); err != nil {
+			return nil, err
+		}
+		return string(b[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		array := make([]interface{}, n)
+		for i := range array {
+			if array[i], err = s.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return array, nil
+	default:
+		return nil, newError("invalid Redis reply: ", line)
+	}
+}
+
+func redisStrings(reply interface{}) ([]string, error) {
+	if err, ok := reply.(redisError); ok {
+		return nil, err
+	}
+	array, _ := reply.([]interface{})
+	strs := make([]string, 0, len(array))
+	for _, v := range array {
+		if s, ok := v.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs, nil
+}
+
+func (s *redisStore) Load(tags []string) ([]*User, error) {
+	if len(tags) == 0 {
+		replies, err := s.do([][]string{{"SMEMBERS", redisInbounds}})
+		if err != nil {
+			return nil, err
+		}
+		if tags, err = redisStrings(replies[0]); err != nil {
+			return nil, err
+		}
+	}
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	commands := make([][]string, len(tags))
+	for i, tag := range tags {
+		commands[i] = []string{"SMEMBERS", redisUsersKey + tag}
+	}
+	replies, err := s.do(commands)
+	if err != nil {
+		return nil, err
+	}
+	var users []*User
+	commands = commands[:0]
+	for i, tag := range tags {
+		emails, err := redisStrings(replies[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, email := range emails {
+			users = append(users, &User{Inbound: tag, Email: email})
+			commands = append(commands, []string{"HGETALL", redisUserKey + tag + ":" + email})
+		}
+	}
+	if len(users) == 0 {
+		return nil, nil
+	}
+
+	if replies, err = s.do(commands); err != nil {
+		return nil, err
+	}
+	for i, u := range users {
+		fields, err := redisStrings(replies[i])
+		if err != nil {
+			return nil, err
+		}
+		for j := 0; j+1 < len(fields); j += 2 {
+			switch v := fields[j+1]; fields[j] {
+			case "level":
+				level, _ := strconv.ParseUint(v, 10, 32)
+				u.Level = uint32(level)
+			case "accountType":
+				u.AccountType = v
+			case "account":
+				u.Account = v
+			case "uplink":
+				u.Uplink, _ = strconv.ParseInt(v, 10, 64)
+			case "downlink":
+				u.Downlink, _ = strconv.ParseInt(v, 10, 64)
+			case "lastLogin":
+				u.LastLogin, _ = strconv.ParseInt(v, 10, 64)
+			}
+		}
+	}
+	return users, nil
+}
+
+func (s *redisStore) AddTraffic(tag string, email string, uplink int64, downlink int64) error {
+	key := redisUserKey + tag + ":" + email
+	replies, err := s.do([][]string{
+		{"HINCRBY", key, "uplink", strconv.FormatInt(uplink, 10)},
+		{"HINCRBY", key, "downlink", strconv.FormatInt(downlink, 10)},
+	})
+	if err != nil {
+		return err
+	}
+	for _, reply := range replies {
+		if err, ok := reply.(redisError); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetLastLogin implements LoginStore.
+func (s *redisStore) SetLastLogin(tag string, email string, unix int64) error {
+	replies, err := s.do([][]string{{"HSET", redisUserKey + tag + ":" + email, "lastLogin", strconv.FormatInt(unix, 10)}})
+	if err != nil {
+		return err
+	}
+	if err, ok := replies[0].(redisError); ok {
+		return err
+	}
+	return nil
+}
+
+func (s *redisStore) Close() error {
+	s.access.Lock()
+	defer s.access.Unlock()
+
+	s.disconnect()
+	return nil
+}
+
+func init() {
+	common.Must(RegisterBackend("redis", newRedisStore))
+}
@@ -0,0 +1,84 @@
+package userstore
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/xtls/xray-core/common"
+	_ "modernc.org/sqlite" // registers the sqlite driver
+)
+
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS users (
+	inbound TEXT NOT NULL,
+	email TEXT NOT NULL,
+	level INTEGER NOT NULL DEFAULT 0,
+	account_type TEXT NOT NULL,
+	account TEXT NOT NULL,
+	uplink INTEGER NOT NULL DEFAULT 0,
+	downlink INTEGER NOT NULL DEFAULT 0,
+	last_login INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (inbound, email)
+)`
+
+// sqliteStore keeps users in the users table of an SQLite database, through the pure Go driver of
+// modernc.org/sqlite.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func newSQLiteStore(config *Config) (Store, error) {
+	db, err := sql.Open("sqlite", config.Address)
+	if err != nil {
+		return nil, newError("failed to open ", config.Address).Base(err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, newError("failed to create users table").Base(err)
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) Load(tags []string) ([]*User, error) {
+	query := "SELECT inbound, email, level, account_type, account, uplink, downlink, last_login FROM users"
+	args := make([]interface{}, len(tags))
+	if len(tags) > 0 {
+		query += " WHERE inbound IN (?" + strings.Repeat(", ?", len(tags)-1) + ")"
+		for i, tag := range tags {
+			args[i] = tag
+		}
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		u := new(User)
+		if err := rows.Scan(&u.Inbound, &u.Email, &u.Level, &u.AccountType, &u.Account, &u.Uplink, &u.Downlink, &u.LastLogin); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *sqliteStore) AddTraffic(tag string, email string, uplink int64, downlink int64) error {
+	_, err := s.db.Exec("UPDATE users SET uplink = uplink + ?, downlink = downlink + ? WHERE inbound = ? AND email = ?", uplink, downlink, tag, email)
+	return err
+}
+
+// SetLastLogin implements LoginStore.
+func (s *sqliteStore) SetLastLogin(tag string, email string, unix int64) error {
+	_, err := s.db.Exec("UPDATE users SET last_login = ? WHERE inbound = ? AND email = ?", unix, tag, email)
+	return err
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+func init() {
+	common.Must(RegisterBackend("sqlite", newSQLiteStore))
+}
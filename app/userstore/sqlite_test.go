@@ -0,0 +1,44 @@
+package userstore
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/xtls/xray-core/common"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	s, err := newSQLiteStore(&Config{Address: filepath.Join(t.TempDir(), "users.db")})
+	common.Must(err)
+	defer s.Close()
+
+	db := s.(*sqliteStore).db
+	common.Must2(db.Exec("INSERT INTO users (inbound, email, account_type, account) VALUES (?, ?, ?, ?), (?, ?, ?, ?)",
+		"a", "a@example.com", "xray.proxy.vless.Account", `{"id": "a"}`,
+		"b", "b@example.com", "xray.proxy.vless.Account", `{"id": "b"}`))
+	common.Must(s.AddTraffic("a", "a@example.com", 10, 20))
+	common.Must(s.AddTraffic("a", "a@example.com", 1, 2))
+	common.Must(s.(LoginStore).SetLastLogin("a", "a@example.com", 300))
+
+	users, err := s.Load([]string{"a"})
+	common.Must(err)
+	want := []*User{{
+		Inbound:     "a",
+		Email:       "a@example.com",
+		AccountType: "xray.proxy.vless.Account",
+		Account:     `{"id": "a"}`,
+		Uplink:      11,
+		Downlink:    22,
+		LastLogin:   300,
+	}}
+	if r := cmp.Diff(users, want); r != "" {
+		t.Error(r)
+	}
+
+	users, err = s.Load(nil)
+	common.Must(err)
+	if len(users) != 2 {
+		t.Error("expected 2 users, but got ", len(users))
+	}
+}
@@ -0,0 +1,95 @@
+package userstore
+
+import (
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/serial"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// User is a user of an inbound as kept in a store.
+type User struct {
+	Inbound string
+	Email   string
+	Level   uint32
+	// AccountType is the proto name of the account, such as xray.proxy.vless.Account.
+	AccountType string
+	// Account is the account in proto JSON, such as {"id": "..."} for VLESS.
+	Account string
+	// Uplink and Downlink are the traffic totals of the user in bytes.
+	Uplink   int64
+	Downlink int64
+	// LastLogin is the Unix time of the last login of the user, in seconds, or 0 if it never logged in.
+	LastLogin int64
+}
+
+// ToMemoryUser parses the account of the user.
+func (u *User) ToMemoryUser() (*protocol.MemoryUser, error) {
+	instance, err := serial.GetInstance(u.AccountType)
+	if err != nil {
+		return nil, err
+	}
+	account := instance.(proto.Message)
+	if err := protojson.Unmarshal([]byte(u.Account), proto.MessageV2(account)); err != nil {
+		return nil, newError("invalid account of ", u.Email).Base(err)
+	}
+	return (&protocol.User{
+		Email:   u.Email,
+		Level:   u.Level,
+		Account: serial.ToTypedMessage(account),
+	}).ToMemoryUser()
+}
+
+// same returns whether the users are the same, regardless of traffic.
+func (u *User) same(another *User) bool {
+	return u.Level == another.Level && u.AccountType == another.AccountType && u.Account == another.Account
+}
+
+// Store loads users and keeps their traffic.
+type Store interface {
+	// Load returns the users of the inbounds, or of all inbounds if tags is empty.
+	Load(tags []string) ([]*User, error)
+	// AddTraffic adds to the traffic totals of a user.
+	AddTraffic(tag string, email string, uplink int64, downlink int64) error
+	Close() error
+}
+
+// LoginStore is a Store that also keeps the last logins of users, which are recorded with the
+// statsUserLogin policy.
+type LoginStore interface {
+	// SetLastLogin sets the Unix time of the last login of a user, in seconds.
+	SetLastLogin(tag string, email string, unix int64) error
+}
+
+// BackendCreator creates a Store from the config.
+type BackendCreator func(config *Config) (Store, error)
+
+var (
+	backendAccess sync.RWMutex
+	backends      = make(map[string]BackendCreator)
+)
+
+// RegisterBackend registers a store backend by name, so that it can be used in the config.
+func RegisterBackend(name string, creator BackendCreator) error {
+	backendAccess.Lock()
+	defer backendAccess.Unlock()
+
+	if _, found := backends[name]; found {
+		return newError("user store backend ", name, " is already registered")
+	}
+	backends[name] = creator
+	return nil
+}
+
+func createStore(config *Config) (Store, error) {
+	backendAccess.RLock()
+	creator, found := backends[config.Backend]
+	backendAccess.RUnlock()
+
+	if !found {
+		return nil, newError("unknown user store backend: ", config.Backend)
+	}
+	return creator(config)
+}
@@ -0,0 +1,318 @@
+package userstore
+
+//go:generate go run github.com/xtls/xray-core/common/errors/errorgen
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/app/hook"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/inbound"
+	"github.com/xtls/xray-core/features/stats"
+	"github.com/xtls/xray-core/proxy"
+)
+
+// UserStore keeps the users of inbounds in sync with a store, and saves their traffic to it.
+type UserStore struct {
+	ctx    context.Context
+	config *Config
+	store  Store
+	ihm    inbound.Manager
+	stats  stats.Manager
+	hooks  *hook.Hooks
+
+	access sync.Mutex
+	// users applied to inbounds, by tag and email
+	users map[string]map[string]*User
+	// values of the traffic counters at the last save, by email
+	saved map[string]trafficOffset
+	// Unix times of the last logins in the store, by email
+	lastLogins map[string]int64
+	sync       *task.Periodic
+}
+
+type trafficOffset struct {
+	uplink, downlink int64
+}
+
+// New creates a new UserStore.
+func New(ctx context.Context, config *Config) (*UserStore, error) {
+	store, err := createStore(config)
+	if err != nil {
+		return nil, err
+	}
+	s := &UserStore{
+		ctx:    ctx,
+		config: config,
+		store:  store,
+		hooks:  hook.FromInstance(core.FromContext(ctx)),
+		users:  make(map[string]map[string]*User),
+		saved:  make(map[string]trafficOffset),
+
+		lastLogins: make(map[string]int64),
+	}
+	interval := time.Minute
+	if config.SyncInterval > 0 {
+		interval = time.Duration(config.SyncInterval) * time.Second
+	}
+	s.sync = &task.Periodic{
+		Interval: interval,
+		Execute: func() error {
+			if err := s.Sync(); err != nil {
+				newError("failed to sync users").Base(err).AtWarning().WriteToLog()
+			}
+			return nil
+		},
+	}
+	if err := core.RequireFeatures(ctx, func(ihm inbound.Manager, sm stats.Manager) {
+		s.ihm = ihm
+		s.stats = sm
+	}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Type implements common.HasType.
+func (*UserStore) Type() interface{} {
+	return (*UserStore)(nil)
+}
+
+// Start implements common.Runnable. Users are loaded before it returns.
+func (s *UserStore) Start() error {
+	if err := s.Sync(); err != nil {
+		return newError("failed to load users").Base(err)
+	}
+	return s.sync.Start()
+}
+
+// Close implements common.Closable.
+func (s *UserStore) Close() error {
+	s.sync.Close()
+
+	s.access.Lock()
+	s.saveTraffic()
+	s.saveLogins()
+	s.access.Unlock()
+
+	return s.store.Close()
+}
+
+// Sync saves the traffic of the users, then loads them from the store and applies the changes to the inbounds.
+func (s *UserStore) Sync() error {
+	s.access.Lock()
+	defer s.access.Unlock()
+
+	s.saveTraffic()
+	s.saveLogins()
+
+	loaded, err := s.store.Load(s.config.InboundTag)
+	if err != nil {
+		return err
+	}
+	users := make(map[string]map[string]*User)
+	for _, u := range loaded {
+		if u.Email == "" {
+			newError("ignoring user without email in ", u.Inbound).AtWarning().WriteToLog()
+			continue
+		}
+		if users[u.Inbound] == nil {
+			users[u.Inbound] = make(map[string]*User)
+		}
+		users[u.Inbound][u.Email] = u
+	}
+
+	for tag := range s.users {
+		if _, found := users[tag]; !found {
+			// all users of the inbound are gone from the store
+			users[tag] = nil
+		}
+	}
+	for tag, wanted := range users {
+		s.apply(tag, wanted)
+	}
+	return nil
+}
+
+// apply adds, updates and removes users of the inbound to match wanted.
+func (s *UserStore) apply(tag string, wanted map[string]*User) {
+	applied := s.users[tag]
+	if applied == nil {
+		applied = make(map[string]*User)
+	}
+	defer func() {
+		if len(applied) == 0 {
+			delete(s.users, tag)
+		} else {
+			s.users[tag] = applied
+		}
+	}()
+
+	handler, err := s.ihm.GetHandler(s.ctx, tag)
+	if err != nil {
+		if len(wanted) > 0 {
+			newError("inbound ", tag, " of stored users not found").AtWarning().WriteToLog()
+		}
+		for email := range applied {
+			delete(applied, email)
+		}
+		return
+	}
+	gi, ok := handler.(proxy.GetInbound)
+	if !ok {
+		newError("can't get inbound proxy of ", tag).AtWarning().WriteToLog()
+		return
+	}
+	um, ok := gi.GetInbound().(proxy.UserManager)
+	if !ok {
+		newError("inbound ", tag, " doesn't support users").AtWarning().WriteToLog()
+		return
+	}
+
+	for email, u := range applied {
+		if w, found := wanted[email]; found && w.same(u) {
+			continue
+		}
+		if err := um.RemoveUser(s.ctx, email); err != nil {
+			newError("failed to remove user ", email, " from ", tag).Base(err).AtWarning().WriteToLog()
+		}
+		delete(applied, email)
+		if _, found := wanted[email]; !found {
+			s.forgetLogins(email)
+			s.hooks.Fire(hook.EventUserRemove, map[string]string{"tag": tag, "email": email})
+		}
+	}
+	for email, w := range wanted {
+		if _, found := applied[email]; found {
+			continue
+		}
+		mu, err := w.ToMemoryUser()
+		if err != nil {
+			newError("invalid user ", email, " of ", tag).Base(err).AtWarning().WriteToLog()
+			continue
+		}
+		if err := um.AddUser(s.ctx, mu); err != nil {
+			newError("failed to add user ", email, " to ", tag).Base(err).AtWarning().WriteToLog()
+			continue
+		}
+		applied[email] = w
+		s.restoreLogins(tag, w)
+		s.hooks.Fire(hook.EventUserAdd, map[string]string{"tag": tag, "email": email})
+	}
+}
+
+// restoreLogins makes the user known to the login records of the stats, with its last login in the store.
+func (s *UserStore) restoreLogins(tag string, u *User) {
+	r, ok := s.stats.(stats.LoginRecorder)
+	if !ok {
+		return
+	}
+	r.AddUser(u.Email)
+	if u.LastLogin > s.lastLogins[u.Email] {
+		s.lastLogins[u.Email] = u.LastLogin
+	}
+	if u.LastLogin > 0 && len(r.GetLogins(u.Email)) == 0 {
+		r.RecordLogin(u.Email, stats.Login{Time: time.Unix(u.LastLogin, 0), InboundTag: tag})
+	}
+}
+
+// forgetLogins drops the login records of the user, unless it is still in another inbound.
+func (s *UserStore) forgetLogins(email string) {
+	for _, users := range s.users {
+		if _, found := users[email]; found {
+			return
+		}
+	}
+	delete(s.lastLogins, email)
+	if r, ok := s.stats.(stats.LoginRecorder); ok {
+		r.RemoveUser(email)
+	}
+}
+
+// saveLogins saves the last logins of the users to the store, if it keeps them.
+func (s *UserStore) saveLogins() {
+	ls, ok := s.store.(LoginStore)
+	if !ok {
+		return
+	}
+	r, ok := s.stats.(stats.LoginRecorder)
+	if !ok {
+		return
+	}
+	for tag, users := range s.users {
+		for email := range users {
+			logins := r.GetLogins(email)
+			if len(logins) == 0 {
+				continue
+			}
+			last := logins[0].Time.Unix()
+			if last <= s.lastLogins[email] {
+				continue
+			}
+			if err := ls.SetLastLogin(tag, email, last); err != nil {
+				newError("failed to save last login of ", email).Base(err).AtWarning().WriteToLog()
+				continue
+			}
+			s.lastLogins[email] = last
+		}
+	}
+}
+
+// saveTraffic adds the traffic counted since the last save to the store. The counters are left alone for
+// the stats API, so the values at the last save are kept instead. Stats are kept per email, so the traffic
+// of an email in several inbounds goes to one of them.
+func (s *UserStore) saveTraffic() {
+	if s.stats == nil {
+		return
+	}
+	seen := make(map[string]bool)
+	for tag, users := range s.users {
+		for email := range users {
+			if seen[email] {
+				continue
+			}
+			seen[email] = true
+
+			last := s.saved[email]
+			current := trafficOffset{
+				uplink:   counterValue(s.stats, "user>>>"+email+">>>traffic>>>uplink"),
+				downlink: counterValue(s.stats, "user>>>"+email+">>>traffic>>>downlink"),
+			}
+			uplink, downlink := current.uplink-last.uplink, current.downlink-last.downlink
+			// the counters were reset through the stats API since the last save
+			if uplink < 0 {
+				uplink = current.uplink
+			}
+			if downlink < 0 {
+				downlink = current.downlink
+			}
+			if uplink == 0 && downlink == 0 {
+				continue
+			}
+			if err := s.store.AddTraffic(tag, email, uplink, downlink); err != nil {
+				// the traffic is saved next time
+				newError("failed to save traffic of ", email).Base(err).AtWarning().WriteToLog()
+				continue
+			}
+			// kept for removed users too, as their counters stay
+			s.saved[email] = current
+		}
+	}
+}
+
+func counterValue(m stats.Manager, name string) int64 {
+	if c := m.GetCounter(name); c != nil {
+		return c.Value()
+	}
+	return 0
+}
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		return New(ctx, config.(*Config))
+	}))
+}
@@ -0,0 +1,202 @@
+package userstore_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/app/dispatcher"
+	"github.com/xtls/xray-core/app/proxyman"
+	_ "github.com/xtls/xray-core/app/proxyman/inbound"
+	_ "github.com/xtls/xray-core/app/proxyman/outbound"
+	"github.com/xtls/xray-core/app/stats"
+	. "github.com/xtls/xray-core/app/userstore"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/common/uuid"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/inbound"
+	feature_stats "github.com/xtls/xray-core/features/stats"
+	"github.com/xtls/xray-core/proxy"
+	"github.com/xtls/xray-core/proxy/vless"
+	vless_inbound "github.com/xtls/xray-core/proxy/vless/inbound"
+	"github.com/xtls/xray-core/testing/servers/tcp"
+)
+
+type memoryStore struct {
+	sync.Mutex
+	users     []*User
+	traffic   map[string]int64
+	lastLogin map[string]int64
+}
+
+func (s *memoryStore) Load(tags []string) ([]*User, error) {
+	s.Lock()
+	defer s.Unlock()
+	return append([]*User(nil), s.users...), nil
+}
+
+func (s *memoryStore) AddTraffic(tag string, email string, uplink int64, downlink int64) error {
+	s.Lock()
+	defer s.Unlock()
+	s.traffic[tag+":"+email] += uplink + downlink
+	return nil
+}
+
+func (s *memoryStore) SetLastLogin(tag string, email string, unix int64) error {
+	s.Lock()
+	defer s.Unlock()
+	s.lastLogin[tag+":"+email] = unix
+	return nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+var store = &memoryStore{traffic: make(map[string]int64), lastLogin: make(map[string]int64)}
+
+func init() {
+	common.Must(RegisterBackend("memory", func(*Config) (Store, error) {
+		return store, nil
+	}))
+}
+
+func vlessUser(email string) *User {
+	id := uuid.New()
+	return &User{
+		Inbound:     "in",
+		Email:       email,
+		AccountType: serial.GetMessageType(&vless.Account{}),
+		Account:     `{"id": "` + id.String() + `"}`,
+	}
+}
+
+func TestSync(t *testing.T) {
+	store.users = []*User{vlessUser("a@example.com")}
+
+	v, err := core.New(&core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&dispatcher.Config{}),
+			serial.ToTypedMessage(&proxyman.InboundConfig{}),
+			serial.ToTypedMessage(&proxyman.OutboundConfig{}),
+			serial.ToTypedMessage(&stats.Config{}),
+			serial.ToTypedMessage(&Config{Backend: "memory"}),
+		},
+		Inbound: []*core.InboundHandlerConfig{
+			{
+				Tag: "in",
+				ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(tcp.PickPort())}},
+					Listen:   net.NewIPOrDomain(net.LocalHostIP),
+				}),
+				ProxySettings: serial.ToTypedMessage(&vless_inbound.Config{Decryption: "none"}),
+			},
+		},
+	})
+	common.Must(err)
+	common.Must(v.Start())
+	defer v.Close()
+
+	ctx := context.Background()
+	handler, err := v.GetFeature(inbound.ManagerType()).(inbound.Manager).GetHandler(ctx, "in")
+	common.Must(err)
+	um := handler.(proxy.GetInbound).GetInbound().(proxy.UserManager)
+	duplicate, err := vlessUser("a@example.com").ToMemoryUser()
+	common.Must(err)
+	if err := um.AddUser(ctx, duplicate); err == nil {
+		t.Error("expected a@example.com to be loaded")
+	}
+
+	sm := v.GetFeature(feature_stats.ManagerType()).(feature_stats.Manager)
+	counter, err := sm.RegisterCounter("user>>>a@example.com>>>traffic>>>uplink")
+	common.Must(err)
+	counter.Add(100)
+
+	store.Lock()
+	store.users = []*User{vlessUser("b@example.com")}
+	store.Unlock()
+	common.Must(v.GetFeature((*UserStore)(nil)).(*UserStore).Sync())
+
+	if err := um.RemoveUser(ctx, "a@example.com"); err == nil {
+		t.Error("expected a@example.com to be removed")
+	}
+	if err := um.RemoveUser(ctx, "b@example.com"); err != nil {
+		t.Error("expected b@example.com to be added: ", err)
+	}
+	if n := store.traffic["in:a@example.com"]; n != 100 {
+		t.Error("expected 100 bytes of traffic saved, but got ", n)
+	}
+	if counter.Value() != 100 {
+		t.Error("expected counter to be left for the stats API")
+	}
+
+	// only the traffic since the last save is added
+	counter, err = sm.RegisterCounter("user>>>b@example.com>>>traffic>>>uplink")
+	common.Must(err)
+	for _, n := range []int64{30, 20} {
+		counter.Add(n)
+		common.Must(v.GetFeature((*UserStore)(nil)).(*UserStore).Sync())
+	}
+	if n := store.traffic["in:b@example.com"]; n != 50 {
+		t.Error("expected 50 bytes of traffic saved, but got ", n)
+	}
+}
+
+func TestLogins(t *testing.T) {
+	loggedIn := vlessUser("old@example.com")
+	loggedIn.LastLogin = 100
+	store.Lock()
+	store.users = []*User{loggedIn, vlessUser("new@example.com")}
+	store.Unlock()
+
+	v, err := core.New(&core.Config{
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&dispatcher.Config{}),
+			serial.ToTypedMessage(&proxyman.InboundConfig{}),
+			serial.ToTypedMessage(&proxyman.OutboundConfig{}),
+			serial.ToTypedMessage(&stats.Config{}),
+			serial.ToTypedMessage(&Config{Backend: "memory"}),
+		},
+		Inbound: []*core.InboundHandlerConfig{
+			{
+				Tag: "in",
+				ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(tcp.PickPort())}},
+					Listen:   net.NewIPOrDomain(net.LocalHostIP),
+				}),
+				ProxySettings: serial.ToTypedMessage(&vless_inbound.Config{Decryption: "none"}),
+			},
+		},
+	})
+	common.Must(err)
+	common.Must(v.Start())
+	defer v.Close()
+
+	// the last logins are restored, and the users who never logged in are known
+	sm := v.GetFeature(feature_stats.ManagerType()).(*stats.Manager)
+	logins := make(map[string][]feature_stats.Login)
+	sm.VisitLogins(func(email string, l []feature_stats.Login) bool {
+		logins[email] = l
+		return true
+	})
+	if len(logins) != 2 || len(logins["new@example.com"]) != 0 {
+		t.Error("unexpected logins: ", logins)
+	}
+	if l := logins["old@example.com"]; len(l) != 1 || l[0].Time.Unix() != 100 {
+		t.Error("unexpected logins of old@example.com: ", l)
+	}
+
+	sm.RecordLogin("new@example.com", feature_stats.Login{Time: time.Unix(200, 0), Source: net.LocalHostIP, InboundTag: "in"})
+	common.Must(v.GetFeature((*UserStore)(nil)).(*UserStore).Sync())
+	store.Lock()
+	defer store.Unlock()
+	if n := store.lastLogin["in:new@example.com"]; n != 200 {
+		t.Error("expected last login 200 saved, but got ", n)
+	}
+	if _, found := store.lastLogin["in:old@example.com"]; found {
+		t.Error("unchanged last login saved")
+	}
+}
@@ -0,0 +1,211 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.1
+// 	protoc        v3.21.12
+// source: app/watchdog/config.proto
+
+package watchdog
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Config struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Heap size in MiB above which new inbound connections are rejected. 0 to
+	// disable the heap check.
+	MaxHeap uint32 `protobuf:"varint,1,opt,name=max_heap,json=maxHeap,proto3" json:"max_heap,omitempty"`
+	// Number of concurrent inbound connections above which new connections are
+	// rejected. 0 for unlimited.
+	MaxConnections uint32 `protobuf:"varint,2,opt,name=max_connections,json=maxConnections,proto3" json:"max_connections,omitempty"`
+	// Interval in seconds between two heap checks. Default 5.
+	CheckInterval uint32 `protobuf:"varint,3,opt,name=check_interval,json=checkInterval,proto3" json:"check_interval,omitempty"`
+	// Whether to close the least recently active connections while the heap
+	// limit is exceeded.
+	CloseIdle bool `protobuf:"varint,4,opt,name=close_idle,json=closeIdle,proto3" json:"close_idle,omitempty"`
+	// Heap size in MiB above which DNS caches, idle buffers and unreferenced
+	// geodata are released. 0 to disable.
+	ReleaseHeap uint32 `protobuf:"varint,5,opt,name=release_heap,json=releaseHeap,proto3" json:"release_heap,omitempty"`
+	// Whether to also release memory when the heap reaches 90% of the limit set
+	// by GOMEMLIMIT.
+	ReleaseNearLimit bool `protobuf:"varint,6,opt,name=release_near_limit,json=releaseNearLimit,proto3" json:"release_near_limit,omitempty"`
+}
+
+func (x *Config) Reset() {
+	*x = Config{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_app_watchdog_config_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Config) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Config) ProtoMessage() {}
+
+func (x *Config) ProtoReflect() protoreflect.Message {
+	mi := &file_app_watchdog_config_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Config.ProtoReflect.Descriptor instead.
+func (*Config) Descriptor() ([]byte, []int) {
+	return file_app_watchdog_config_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Config) GetMaxHeap() uint32 {
+	if x != nil {
+		return x.MaxHeap
+	}
+	return 0
+}
+
+func (x *Config) GetMaxConnections() uint32 {
+	if x != nil {
+		return x.MaxConnections
+	}
+	return 0
+}
+
+func (x *Config) GetCheckInterval() uint32 {
+	if x != nil {
+		return x.CheckInterval
+	}
+	return 0
+}
+
+func (x *Config) GetCloseIdle() bool {
+	if x != nil {
+		return x.CloseIdle
+	}
+	return false
+}
+
+func (x *Config) GetReleaseHeap() uint32 {
+	if x != nil {
+		return x.ReleaseHeap
+	}
+	return 0
+}
+
+func (x *Config) GetReleaseNearLimit() bool {
+	if x != nil {
+		return x.ReleaseNearLimit
+	}
+	return false
+}
+
+var File_app_watchdog_config_proto protoreflect.FileDescriptor
+
+var file_app_watchdog_config_proto_rawDesc = []byte{
+	0x0a, 0x19, 0x61, 0x70, 0x70, 0x2f, 0x77, 0x61, 0x74, 0x63, 0x68, 0x64, 0x6f, 0x67, 0x2f, 0x63,
+	0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x11, 0x78, 0x72, 0x61,
+	0x79, 0x2e, 0x61, 0x70, 0x70, 0x2e, 0x77, 0x61, 0x74, 0x63, 0x68, 0x64, 0x6f, 0x67, 0x22, 0xe3,
+	0x01, 0x0a, 0x06, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x19, 0x0a, 0x08, 0x6d, 0x61, 0x78,
+	0x5f, 0x68, 0x65, 0x61, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x6d, 0x61, 0x78,
+	0x48, 0x65, 0x61, 0x70, 0x12, 0x27, 0x0a, 0x0f, 0x6d, 0x61, 0x78, 0x5f, 0x63, 0x6f, 0x6e, 0x6e,
+	0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0e, 0x6d,
+	0x61, 0x78, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x25, 0x0a,
+	0x0e, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x5f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0d, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x49, 0x6e, 0x74, 0x65,
+	0x72, 0x76, 0x61, 0x6c, 0x12, 0x1d, 0x0a, 0x0a, 0x63, 0x6c, 0x6f, 0x73, 0x65, 0x5f, 0x69, 0x64,
+	0x6c, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x63, 0x6c, 0x6f, 0x73, 0x65, 0x49,
+	0x64, 0x6c, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x72, 0x65, 0x6c, 0x65, 0x61, 0x73, 0x65, 0x5f, 0x68,
+	0x65, 0x61, 0x70, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x72, 0x65, 0x6c, 0x65, 0x61,
+	0x73, 0x65, 0x48, 0x65, 0x61, 0x70, 0x12, 0x2c, 0x0a, 0x12, 0x72, 0x65, 0x6c, 0x65, 0x61, 0x73,
+	0x65, 0x5f, 0x6e, 0x65, 0x61, 0x72, 0x5f, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x10, 0x72, 0x65, 0x6c, 0x65, 0x61, 0x73, 0x65, 0x4e, 0x65, 0x61, 0x72, 0x4c,
+	0x69, 0x6d, 0x69, 0x74, 0x42, 0x55, 0x0a, 0x15, 0x63, 0x6f, 0x6d, 0x2e, 0x78, 0x72, 0x61, 0x79,
+	0x2e, 0x61, 0x70, 0x70, 0x2e, 0x77, 0x61, 0x74, 0x63, 0x68, 0x64, 0x6f, 0x67, 0x50, 0x01, 0x5a,
+	0x26, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x78, 0x74, 0x6c, 0x73,
+	0x2f, 0x78, 0x72, 0x61, 0x79, 0x2d, 0x63, 0x6f, 0x72, 0x65, 0x2f, 0x61, 0x70, 0x70, 0x2f, 0x77,
+	0x61, 0x74, 0x63, 0x68, 0x64, 0x6f, 0x67, 0xaa, 0x02, 0x11, 0x58, 0x72, 0x61, 0x79, 0x2e, 0x41,
+	0x70, 0x70, 0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x64, 0x6f, 0x67, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
+}
+
+var (
+	file_app_watchdog_config_proto_rawDescOnce sync.Once
+	file_app_watchdog_config_proto_rawDescData = file_app_watchdog_config_proto_rawDesc
+)
+
+func file_app_watchdog_config_proto_rawDescGZIP() []byte {
+	file_app_watchdog_config_proto_rawDescOnce.Do(func() {
+		file_app_watchdog_config_proto_rawDescData = protoimpl.X.CompressGZIP(file_app_watchdog_config_proto_rawDescData)
+	})
+	return file_app_watchdog_config_proto_rawDescData
+}
+
+var file_app_watchdog_config_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_app_watchdog_config_proto_goTypes = []interface{}{
+	(*Config)(nil), // 0: xray.app.watchdog.Config
+}
+var file_app_watchdog_config_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_app_watchdog_config_proto_init() }
+func file_app_watchdog_config_proto_init() {
+	if File_app_watchdog_config_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_app_watchdog_config_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Config); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_app_watchdog_config_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_app_watchdog_config_proto_goTypes,
+		DependencyIndexes: file_app_watchdog_config_proto_depIdxs,
+		MessageInfos:      file_app_watchdog_config_proto_msgTypes,
+	}.Build()
+	File_app_watchdog_config_proto = out.File
+	file_app_watchdog_config_proto_rawDesc = nil
+	file_app_watchdog_config_proto_goTypes = nil
+	file_app_watchdog_config_proto_depIdxs = nil
+}
@@ -0,0 +1,240 @@
+package watchdog
+
+//go:generate go run github.com/xtls/xray-core/common/errors/errorgen
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/features/dns"
+)
+
+const (
+	// fraction of tracked connections closed per check while the heap limit is exceeded
+	shedDivisor = 10
+	// minimum time between two releases of memory, as each one costs a full GC
+	releaseInterval = time.Minute
+)
+
+// MemoryReleaser is implemented by features holding memory they can do without, such as caches.
+type MemoryReleaser interface {
+	ReleaseMemory()
+}
+
+var (
+	releaseAccess sync.Mutex
+	releaseFuncs  []func()
+)
+
+// RegisterReleaseFunc adds a function to run under memory pressure, for memory held outside of
+// the features of an instance.
+func RegisterReleaseFunc(f func()) {
+	releaseAccess.Lock()
+	defer releaseAccess.Unlock()
+	releaseFuncs = append(releaseFuncs, f)
+}
+
+type connEntry struct {
+	inbound *session.Inbound
+	since   time.Time
+	cancel  context.CancelFunc
+}
+
+func (e *connEntry) lastActivity() time.Time {
+	if e.inbound.Timer != nil {
+		if t := e.inbound.Timer.LastActivity(); t.After(e.since) {
+			return t
+		}
+	}
+	return e.since
+}
+
+// Watchdog rejects new inbound connections when the process is running out of memory
+// or connections, instead of letting the OOM killer take out the whole process.
+type Watchdog struct {
+	sync.Mutex
+	config     *Config
+	conns      map[*connEntry]struct{}
+	overloaded bool
+	checker    *task.Periodic
+
+	instance    *core.Instance
+	lastRelease time.Time
+}
+
+// New creates a new Watchdog.
+func New(ctx context.Context, config *Config) (*Watchdog, error) {
+	w := &Watchdog{
+		config: config,
+		conns:  make(map[*connEntry]struct{}),
+	}
+	if v := core.FromContext(ctx); v != nil {
+		w.instance = v
+	}
+	interval := time.Duration(config.CheckInterval) * time.Second
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+	if config.MaxHeap > 0 || config.ReleaseHeap > 0 || config.ReleaseNearLimit {
+		w.checker = &task.Periodic{
+			Interval: interval,
+			Execute:  w.check,
+		}
+	}
+	return w, nil
+}
+
+// Type implements common.HasType.
+func (*Watchdog) Type() interface{} {
+	return Type()
+}
+
+// Type returns the feature type of Watchdog.
+func Type() interface{} {
+	return (*Watchdog)(nil)
+}
+
+// Start implements common.Runnable.
+func (w *Watchdog) Start() error {
+	if w.checker != nil {
+		return w.checker.Start()
+	}
+	return nil
+}
+
+// Close implements common.Closable.
+func (w *Watchdog) Close() error {
+	if w.checker != nil {
+		return w.checker.Close()
+	}
+	return nil
+}
+
+func (w *Watchdog) check() error {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	if threshold := w.releaseThreshold(); threshold > 0 && stats.HeapAlloc >= threshold && time.Since(w.lastRelease) >= releaseInterval {
+		w.lastRelease = time.Now()
+		w.release()
+		runtime.ReadMemStats(&stats)
+		newError("released memory under pressure, heap usage now ", stats.HeapAlloc>>20, "MiB").AtWarning().WriteToLog()
+	}
+	if w.config.MaxHeap == 0 {
+		return nil
+	}
+	heap := stats.HeapAlloc >> 20
+
+	w.Lock()
+	defer w.Unlock()
+
+	overloaded := heap >= uint64(w.config.MaxHeap)
+	if overloaded != w.overloaded {
+		if overloaded {
+			newError("heap usage ", heap, "MiB exceeds ", w.config.MaxHeap, "MiB, rejecting new connections").AtWarning().WriteToLog()
+		} else {
+			newError("heap usage ", heap, "MiB back under limit, accepting new connections").AtWarning().WriteToLog()
+		}
+		w.overloaded = overloaded
+	}
+	if overloaded && w.config.CloseIdle {
+		w.shed()
+	}
+	return nil
+}
+
+// releaseThreshold returns the heap size in bytes above which memory is released, or 0.
+func (w *Watchdog) releaseThreshold() uint64 {
+	threshold := uint64(w.config.ReleaseHeap) << 20
+	if w.config.ReleaseNearLimit {
+		if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+			near := uint64(limit) / 10 * 9
+			if threshold == 0 || near < threshold {
+				threshold = near
+			}
+		}
+	}
+	return threshold
+}
+
+// release drops caches that can be rebuilt, and returns idle memory, including pooled buffers, to the OS.
+func (w *Watchdog) release() {
+	if w.instance != nil {
+		if r, ok := w.instance.GetFeature(dns.ClientType()).(MemoryReleaser); ok {
+			r.ReleaseMemory()
+		}
+	}
+	releaseAccess.Lock()
+	for _, f := range releaseFuncs {
+		f()
+	}
+	releaseAccess.Unlock()
+	debug.FreeOSMemory()
+}
+
+// shed closes the least recently active connections. Caller must hold the lock.
+func (w *Watchdog) shed() {
+	if len(w.conns) == 0 {
+		return
+	}
+	entries := make([]*connEntry, 0, len(w.conns))
+	for e := range w.conns {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastActivity().Before(entries[j].lastActivity())
+	})
+	n := len(entries) / shedDivisor
+	if n == 0 {
+		n = 1
+	}
+	for _, e := range entries[:n] {
+		e.cancel()
+		if e.inbound.Conn != nil {
+			e.inbound.Conn.Close()
+		}
+		delete(w.conns, e)
+	}
+	newError("closed ", n, " idle connections to reduce memory usage").AtWarning().WriteToLog()
+}
+
+// Track registers an inbound connection. It returns false if the connection should be
+// rejected. Otherwise the returned function must be called when the connection ends.
+func (w *Watchdog) Track(inbound *session.Inbound, cancel context.CancelFunc) (func(), bool) {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.overloaded {
+		return nil, false
+	}
+	if w.config.MaxConnections > 0 && len(w.conns) >= int(w.config.MaxConnections) {
+		return nil, false
+	}
+
+	e := &connEntry{
+		inbound: inbound,
+		since:   time.Now(),
+		cancel:  cancel,
+	}
+	w.conns[e] = struct{}{}
+	return func() {
+		w.Lock()
+		delete(w.conns, e)
+		w.Unlock()
+	}, true
+}
+
+func init() {
+	common.Must(common.RegisterConfig((*Config)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
+		return New(ctx, config.(*Config))
+	}))
+}
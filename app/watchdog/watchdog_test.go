@@ -0,0 +1,57 @@
+package watchdog_test
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/xtls/xray-core/app/watchdog"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/session"
+)
+
+func TestMaxConnections(t *testing.T) {
+	w, err := New(context.Background(), &Config{
+		MaxConnections: 1,
+	})
+	common.Must(err)
+
+	release, ok := w.Track(&session.Inbound{}, func() {})
+	if !ok {
+		t.Fatal("expect first connection to be accepted")
+	}
+	if _, ok := w.Track(&session.Inbound{}, func() {}); ok {
+		t.Error("expect second connection to be rejected")
+	}
+	release()
+	if _, ok := w.Track(&session.Inbound{}, func() {}); !ok {
+		t.Error("expect connection to be accepted after release")
+	}
+}
+
+func TestReleaseMemory(t *testing.T) {
+	var released int32
+	RegisterReleaseFunc(func() {
+		atomic.StoreInt32(&released, 1)
+	})
+
+	w, err := New(context.Background(), &Config{
+		ReleaseHeap:   1,
+		CheckInterval: 1,
+	})
+	common.Must(err)
+	common.Must(w.Start())
+	defer w.Close()
+
+	// keep the heap above the threshold
+	ballast := make([]byte, 4<<20)
+	for i := 0; i < 30 && atomic.LoadInt32(&released) == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	runtime.KeepAlive(ballast)
+	if atomic.LoadInt32(&released) == 0 {
+		t.Error("memory not released")
+	}
+}
@@ -0,0 +1,227 @@
+package antireplay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var ringFilterMagic = [4]byte{'X', 'R', 'F', '1'}
+
+var errRingFilterMismatch = errors.New("saved filter has different parameters")
+
+// RingFilter is a ring of bloom filters. When the current filter is full, the oldest one is
+// cleared and reused, so the memory used is fixed however many records are added.
+// Unlike BloomRing, its state can be saved and loaded, so that records survive restarts.
+type RingFilter struct {
+	sync.Mutex
+	slots    [][]uint64
+	bits     uint64
+	hashes   uint32
+	capacity uint32
+	position uint32
+	count    uint32
+	dirty    bool
+}
+
+// NewRingFilter creates a RingFilter that remembers about capacity records, forgetting the
+// oldest capacity/slots at a time.
+func NewRingFilter(slots, capacity uint32, falsePositiveRate float64) *RingFilter {
+	if slots == 0 {
+		slots = 1
+	}
+	slotCapacity := capacity / slots
+	if slotCapacity == 0 {
+		slotCapacity = 1
+	}
+	// the false positive rate of a lookup is the sum of all slots
+	rate := falsePositiveRate / float64(slots)
+	bits := uint64(math.Ceil(-float64(slotCapacity) * math.Log(rate) / (math.Ln2 * math.Ln2)))
+	bits = (bits + 63) / 64 * 64
+	hashes := uint32(math.Ceil(float64(bits) / float64(slotCapacity) * math.Ln2))
+	if hashes == 0 {
+		hashes = 1
+	}
+
+	f := &RingFilter{
+		slots:    make([][]uint64, slots),
+		bits:     bits,
+		hashes:   hashes,
+		capacity: slotCapacity,
+	}
+	for i := range f.slots {
+		f.slots[i] = make([]uint64, bits/64)
+	}
+	return f
+}
+
+// mix is the finalizer of splitmix64, which spreads similar FNV sums apart.
+func mix(z uint64) uint64 {
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func doubleFNV(b []byte) (uint64, uint64) {
+	hx := fnv.New64()
+	hx.Write(b)
+	hy := fnv.New64a()
+	hy.Write(b)
+	return mix(hx.Sum64()), mix(hy.Sum64()) | 1
+}
+
+// Interval implements GeneralizedReplayFilter. Records expire by count instead of time.
+func (f *RingFilter) Interval() int64 {
+	return 9999999
+}
+
+// Check implements GeneralizedReplayFilter. It returns false if sum was seen before, and adds it otherwise.
+func (f *RingFilter) Check(sum []byte) bool {
+	x, y := doubleFNV(sum)
+
+	f.Lock()
+	defer f.Unlock()
+
+	for _, slot := range f.slots {
+		if f.test(slot, x, y) {
+			return false
+		}
+	}
+
+	if f.count >= f.capacity {
+		f.position = (f.position + 1) % uint32(len(f.slots))
+		slot := f.slots[f.position]
+		for i := range slot {
+			slot[i] = 0
+		}
+		f.count = 0
+	}
+	slot := f.slots[f.position]
+	for i := uint64(0); i < uint64(f.hashes); i++ {
+		bit := (x + i*y) % f.bits
+		slot[bit/64] |= 1 << (bit % 64)
+	}
+	f.count++
+	f.dirty = true
+	return true
+}
+
+func (f *RingFilter) test(slot []uint64, x, y uint64) bool {
+	for i := uint64(0); i < uint64(f.hashes); i++ {
+		bit := (x + i*y) % f.bits
+		if slot[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *RingFilter) header() []uint64 {
+	return []uint64{uint64(len(f.slots)), f.bits, uint64(f.hashes), uint64(f.capacity), uint64(f.position), uint64(f.count)}
+}
+
+// WriteTo writes the state of the filter.
+func (f *RingFilter) WriteTo(w io.Writer) (int64, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	bw := bufio.NewWriter(w)
+	bw.Write(ringFilterMagic[:])
+	for _, v := range f.header() {
+		binary.Write(bw, binary.BigEndian, v)
+	}
+	for _, slot := range f.slots {
+		binary.Write(bw, binary.BigEndian, slot)
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, err
+	}
+	f.dirty = false
+	return int64(len(ringFilterMagic) + 8*len(f.header()) + len(f.slots)*int(f.bits/8)), nil
+}
+
+// ReadFrom restores the state written by WriteTo. It fails if the filter was created with different parameters.
+func (f *RingFilter) ReadFrom(r io.Reader) (int64, error) {
+	br := bufio.NewReader(r)
+	var magic [4]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil {
+		return 0, err
+	}
+	if magic != ringFilterMagic {
+		return 0, errRingFilterMismatch
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	header := make([]uint64, 6)
+	if err := binary.Read(br, binary.BigEndian, header); err != nil {
+		return 0, err
+	}
+	expected := f.header()
+	for i := 0; i < 4; i++ {
+		if header[i] != expected[i] {
+			return 0, errRingFilterMismatch
+		}
+	}
+	if header[4] >= uint64(len(f.slots)) || header[5] > uint64(f.capacity) {
+		return 0, errRingFilterMismatch
+	}
+	slots := make([][]uint64, len(f.slots))
+	for i := range slots {
+		slots[i] = make([]uint64, f.bits/64)
+		if err := binary.Read(br, binary.BigEndian, slots[i]); err != nil {
+			return 0, err
+		}
+	}
+	f.slots = slots
+	f.position = uint32(header[4])
+	f.count = uint32(header[5])
+	f.dirty = false
+	return int64(len(ringFilterMagic) + 8*len(header) + len(f.slots)*int(f.bits/8)), nil
+}
+
+// Save writes the filter to the file if anything was added since the last save.
+func (f *RingFilter) Save(path string) error {
+	f.Lock()
+	dirty := f.dirty
+	f.Unlock()
+	if !dirty {
+		return nil
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteTo(file); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// Load reads the filter from the file. A missing file is not an error.
+func (f *RingFilter) Load(path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = f.ReadFrom(file)
+	return err
+}
@@ -0,0 +1,65 @@
+package antireplay_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/xtls/xray-core/common"
+	. "github.com/xtls/xray-core/common/antireplay"
+)
+
+func salt(i uint32) []byte {
+	b := make([]byte, 32)
+	binary.BigEndian.PutUint32(b, i)
+	return b
+}
+
+func TestRingFilter(t *testing.T) {
+	filter := NewRingFilter(4, 1000, 1e-6)
+	for i := uint32(0); i < 1000; i++ {
+		if !filter.Check(salt(i)) {
+			t.Fatal("unexpected replay of ", i)
+		}
+	}
+	for i := uint32(0); i < 1000; i++ {
+		if filter.Check(salt(i)) {
+			t.Fatal("replay of ", i, " not detected")
+		}
+	}
+
+	// the oldest slot is forgotten once the ring is full
+	for i := uint32(1000); i < 1250; i++ {
+		filter.Check(salt(i))
+	}
+	if !filter.Check(salt(0)) {
+		t.Error("expect oldest salt to expire")
+	}
+	if filter.Check(salt(999)) {
+		t.Error("recent salt expired")
+	}
+}
+
+func TestRingFilterSaveLoad(t *testing.T) {
+	filter := NewRingFilter(4, 1000, 1e-6)
+	for i := uint32(0); i < 100; i++ {
+		filter.Check(salt(i))
+	}
+
+	var b bytes.Buffer
+	common.Must2(filter.WriteTo(&b))
+
+	restored := NewRingFilter(4, 1000, 1e-6)
+	common.Must2(restored.ReadFrom(bytes.NewReader(b.Bytes())))
+	if restored.Check(salt(42)) {
+		t.Error("replay not detected after restore")
+	}
+	if !restored.Check(salt(100)) {
+		t.Error("unexpected replay after restore")
+	}
+
+	other := NewRingFilter(8, 1000, 1e-6)
+	if _, err := other.ReadFrom(bytes.NewReader(b.Bytes())); err == nil {
+		t.Error("expect error loading filter with different parameters")
+	}
+}
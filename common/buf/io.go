@@ -56,6 +56,11 @@ func isPacketReader(reader io.Reader) bool {
 	return ok
 }
 
+// ReadvEnabled returns whether readers of connections use readv(2), as set by xray.buf.readv.
+func ReadvEnabled() bool {
+	return useReadv
+}
+
 // NewReader creates a new Reader.
 // The Reader instance doesn't take the ownership of reader.
 func NewReader(reader io.Reader) Reader {
@@ -4,9 +4,31 @@ import (
 	"io"
 
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/bytespool"
 	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/platform"
 )
 
+// maxReadSize caps how much a reader reads at once while data keeps coming.
+var maxReadSize int32 = 8 * Size
+
+func init() {
+	// xray.buf.maxread is in KiB, from one Buffer up to the largest pool of bytespool.
+	size := int32(platform.NewEnvFlag("xray.buf.maxread").GetValueAsInt(int(maxReadSize/1024))) * 1024
+	if size < Size {
+		size = Size
+	}
+	if size > 16*Size {
+		size = 16 * Size
+	}
+	maxReadSize = size / Size * Size
+}
+
+// MaxReadSize returns how much a reader reads at once at most.
+func MaxReadSize() int32 {
+	return maxReadSize
+}
+
 func readOneUDP(r io.Reader) (*Buffer, error) {
 	b := New()
 	for i := 0; i < 64; i++ {
@@ -148,15 +170,47 @@ func (r *BufferedReader) Close() error {
 	return common.Close(r.Reader)
 }
 
-// SingleReader is a Reader that read one Buffer every time.
+// SingleReader is a Reader that reads once every time. It reads into one Buffer, and into a larger
+// buffer that is split into Buffers while the reads keep filling it, up to MaxReadSize.
 type SingleReader struct {
 	io.Reader
+	size int32
 }
 
 // ReadMultiBuffer implements Reader.
 func (r *SingleReader) ReadMultiBuffer() (MultiBuffer, error) {
-	b, err := ReadBuffer(r.Reader)
-	return MultiBuffer{b}, err
+	if r.size <= Size {
+		b, err := ReadBuffer(r.Reader)
+		if b != nil && b.IsFull() {
+			r.adjust(Size)
+		}
+		return MultiBuffer{b}, err
+	}
+
+	p := bytespool.Alloc(r.size)
+	n, err := r.Reader.Read(p[:r.size])
+	var mb MultiBuffer
+	if n > 0 {
+		mb = MergeBytes(nil, p[:n])
+	}
+	bytespool.Free(p)
+	r.adjust(int32(n))
+	return mb, err
+}
+
+// adjust doubles the read size after a read that filled it, and shrinks it to what the read needed otherwise.
+func (r *SingleReader) adjust(n int32) {
+	if n >= r.size {
+		r.size *= 2
+		if r.size < 2*Size {
+			r.size = 2 * Size
+		}
+		if r.size > maxReadSize {
+			r.size = maxReadSize
+		}
+		return
+	}
+	r.size = (n + Size - 1) / Size * Size
 }
 
 // PacketReader is a Reader that read one Buffer every time.
@@ -3,6 +3,7 @@ package buf_test
 import (
 	"bytes"
 	"io"
+	"net"
 	"strings"
 	"testing"
 
@@ -90,6 +91,52 @@ func TestReadBuffer(t *testing.T) {
 	}
 }
 
+func TestSingleReaderGrowth(t *testing.T) {
+	payload := make([]byte, 4*MaxReadSize())
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	reader := &SingleReader{Reader: bytes.NewReader(payload)}
+
+	var data []byte
+	var sizes []int32
+	for {
+		mb, err := reader.ReadMultiBuffer()
+		if err != nil {
+			break
+		}
+		sizes = append(sizes, mb.Len())
+		for _, b := range mb {
+			data = append(data, b.Bytes()...)
+		}
+		ReleaseMulti(mb)
+	}
+	if !bytes.Equal(data, payload) {
+		t.Fatal("unexpected data")
+	}
+	if sizes[0] != Size || sizes[1] != 2*Size {
+		t.Error("expect reads to start from one buffer and grow, got ", sizes)
+	}
+	for _, size := range sizes {
+		if size > MaxReadSize() {
+			t.Error("read ", size, " bytes at once, more than ", MaxReadSize())
+		}
+	}
+
+	// a short read shrinks it back to one buffer
+	reader.Reader = strings.NewReader("abcd")
+	mb, err := reader.ReadMultiBuffer()
+	common.Must(err)
+	ReleaseMulti(mb)
+	reader.Reader = bytes.NewReader(payload)
+	mb, err = reader.ReadMultiBuffer()
+	common.Must(err)
+	if l := mb.Len(); l != Size {
+		t.Error("expect a read of one buffer after a short read, got ", l)
+	}
+	ReleaseMulti(mb)
+}
+
 func TestReadAtMost(t *testing.T) {
 	sr := strings.NewReader("abcd")
 	reader := &BufferedReader{
@@ -129,3 +176,32 @@ func TestReaderInterface(t *testing.T) {
 	_ = (io.ByteReader)(new(BufferedReader))
 	_ = (io.WriterTo)(new(BufferedReader))
 }
+
+func BenchmarkSingleReader(b *testing.B) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	common.Must(err)
+	defer listener.Close()
+
+	const size = 4 * 1024 * 1024
+	go func() {
+		payload := make([]byte, 256*1024)
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			for n := 0; n < size; n += len(payload) {
+				conn.Write(payload)
+			}
+			conn.Close()
+		}
+	}()
+
+	b.SetBytes(size)
+	for i := 0; i < b.N; i++ {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		common.Must(err)
+		common.Must(Copy(&SingleReader{Reader: conn}, Discard))
+		conn.Close()
+	}
+}
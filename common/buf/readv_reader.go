@@ -26,8 +26,8 @@ func (s *allocStrategy) Adjust(n uint32) {
 		s.current = n
 	}
 
-	if s.current > 8 {
-		s.current = 8
+	if limit := uint32(maxReadSize / Size); s.current > limit {
+		s.current = limit
 	}
 
 	if s.current == 0 {
@@ -0,0 +1,113 @@
+package errors
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"syscall"
+)
+
+// Class is a coarse category of why a connection failed, used in stats and logs.
+type Class string
+
+const (
+	ClassDNS         Class = "dns"
+	ClassRefused     Class = "refused"
+	ClassUnreachable Class = "unreachable"
+	ClassTimeout     Class = "timeout"
+	ClassAuth        Class = "auth"
+	ClassPolicy      Class = "policy"
+	ClassOther       Class = "other"
+)
+
+// WithClass marks the error with the given class. It takes precedence over the classes of inner errors.
+func (err *Error) WithClass(c Class) *Error {
+	err.class = c
+	return err
+}
+
+// ClassOf returns the class of the error. Classes set by WithClass win, otherwise the class is derived
+// from well-known errors in the chain.
+func ClassOf(err error) Class {
+	if err == nil {
+		return ""
+	}
+	if c := explicitClass(err); c != "" {
+		return c
+	}
+
+	var dnsErr *net.DNSError
+	if As(err, &dnsErr) {
+		return ClassDNS
+	}
+	if Is(err, syscall.ECONNREFUSED) {
+		return ClassRefused
+	}
+	if Is(err, syscall.EHOSTUNREACH) || Is(err, syscall.ENETUNREACH) {
+		return ClassUnreachable
+	}
+	if Is(err, context.DeadlineExceeded) || Is(err, os.ErrDeadlineExceeded) {
+		return ClassTimeout
+	}
+	var netErr net.Error
+	if As(err, &netErr) && netErr.Timeout() {
+		return ClassTimeout
+	}
+	if c := messageClass(err); c != "" {
+		return c
+	}
+	return ClassOther
+}
+
+// ClassOfResponse returns the class of an error decoding the response of a proxy server. A response that can't be
+// decoded means the server rejected the request, but timeouts and connections closed or reset before the
+// response are classified by their cause.
+func ClassOfResponse(err error) Class {
+	if c := ClassOf(err); c != ClassOther {
+		return c
+	}
+	if Is(err, io.EOF) || Is(err, io.ErrUnexpectedEOF) || Is(err, io.ErrClosedPipe) || Is(err, net.ErrClosed) ||
+		Is(err, syscall.ECONNRESET) || Is(err, syscall.EPIPE) {
+		return ClassOther
+	}
+	return ClassAuth
+}
+
+func explicitClass(err error) Class {
+	for err != nil {
+		if e, ok := err.(*Error); ok && e.class != "" {
+			return e.class
+		}
+		err = unwrap(err)
+	}
+	return ""
+}
+
+// messageClass classifies errors carried in messages, such as the attempts collected by common/retry.
+// The last one is the most relevant.
+func messageClass(err error) Class {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			for i := len(e.message) - 1; i >= 0; i-- {
+				switch m := e.message[i].(type) {
+				case error:
+					return ClassOf(m)
+				case []error:
+					if len(m) > 0 {
+						return ClassOf(m[len(m)-1])
+					}
+				}
+			}
+		}
+		err = unwrap(err)
+	}
+	return ""
+}
+
+func unwrap(err error) error {
+	if e, ok := err.(hasInnerError); ok {
+		return e.Unwrap()
+	}
+	return nil
+}
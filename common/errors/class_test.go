@@ -0,0 +1,53 @@
+package errors_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	. "github.com/xtls/xray-core/common/errors"
+)
+
+func TestClassOf(t *testing.T) {
+	cases := []struct {
+		err   error
+		class Class
+	}{
+		{err: New("dial").Base(&net.DNSError{Err: "no such host", Name: "example.com"}), class: ClassDNS},
+		{err: New("dial").Base(&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}), class: ClassRefused},
+		{err: New("read").Base(&net.OpError{Op: "read", Err: syscall.EHOSTUNREACH}), class: ClassUnreachable},
+		{err: New("dial").Base(context.DeadlineExceeded), class: ClassTimeout},
+		{err: New("header").Base(io.EOF).WithClass(ClassAuth), class: ClassAuth},
+		{err: New("outer").Base(New("header").Base(context.DeadlineExceeded).WithClass(ClassAuth)), class: ClassAuth},
+		{err: New([]error{io.EOF, syscall.ECONNREFUSED}).Base(New("all retry attempts failed")), class: ClassRefused},
+		{err: io.EOF, class: ClassOther},
+		{err: nil, class: ""},
+	}
+	for _, c := range cases {
+		if v := ClassOf(c.err); v != c.class {
+			t.Error("class of ", c.err, ": ", v, ", want ", c.class)
+		}
+	}
+}
+
+func TestClassOfResponse(t *testing.T) {
+	cases := []struct {
+		err   error
+		class Class
+	}{
+		{err: New("failed to read response version").Base(io.EOF), class: ClassOther},
+		{err: New("failed to read response version").Base(io.ErrUnexpectedEOF), class: ClassOther},
+		{err: New("read").Base(&net.OpError{Op: "read", Err: syscall.ECONNRESET}), class: ClassOther},
+		{err: New("read").Base(os.ErrDeadlineExceeded), class: ClassTimeout},
+		{err: New("unexpected response version"), class: ClassAuth},
+		{err: New("failed to decrypt length").Base(New("cipher: message authentication failed")), class: ClassAuth},
+	}
+	for _, c := range cases {
+		if v := ClassOfResponse(c.err); v != c.class {
+			t.Error("class of ", c.err, ": ", v, ", want ", c.class)
+		}
+	}
+}
@@ -2,6 +2,7 @@
 package errors // import "github.com/xtls/xray-core/common/errors"
 
 import (
+	"errors"
 	"reflect"
 	"strings"
 
@@ -27,6 +28,7 @@ type Error struct {
 	message  []interface{}
 	inner    error
 	severity log.Severity
+	class    Class
 }
 
 func (err *Error) WithPathObj(obj interface{}) *Error {
@@ -181,6 +183,16 @@ L:
 	return err
 }
 
+// Is reports whether any error in err's chain matches target, like errors.Is in the standard library.
+func Is(err, target error) bool {
+	return errors.Is(err, target)
+}
+
+// As finds the first error in err's chain that matches target, like errors.As in the standard library.
+func As(err error, target interface{}) bool {
+	return errors.As(err, target)
+}
+
 // GetSeverity returns the actual severity of the error, including inner errors.
 func GetSeverity(err error) log.Severity {
 	if s, ok := err.(hasSeverity); ok {
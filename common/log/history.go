@@ -0,0 +1,103 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// maxHistoryMessageSize is the size in bytes beyond which the messages kept in the history are cut, so that a
+// history takes at most its size times this much memory.
+const maxHistoryMessageSize = 1024
+
+// HistoryEntry is a message kept in the history, with the time it was recorded.
+type HistoryEntry struct {
+	Time time.Time
+	// Severity is Unknown for messages without one, such as access logs.
+	Severity Severity
+	Message  string
+}
+
+// history keeps the most recent messages recorded.
+type history struct {
+	// level is the least severe level of the messages kept, which is set before the history is used.
+	level Severity
+
+	sync.Mutex
+	entries []HistoryEntry
+	next    int
+	full    bool
+}
+
+var (
+	historyAccess sync.Mutex
+	recent        atomic.Pointer[history]
+)
+
+// KeepHistory starts keeping the last size messages recorded at level or more severe, whether or not a handler
+// logs them. Messages without a severity are kept at the Debug level only. A history that is kept already only
+// grows, in size and level.
+func KeepHistory(size int, level Severity) {
+	historyAccess.Lock()
+	defer historyAccess.Unlock()
+
+	if h := recent.Load(); h != nil {
+		if len(h.entries) >= size && h.level >= level {
+			return
+		}
+		if len(h.entries) > size {
+			size = len(h.entries)
+		}
+		if h.level > level {
+			level = h.level
+		}
+	}
+	recent.Store(&history{level: level, entries: make([]HistoryEntry, size)})
+}
+
+// History returns the messages kept by KeepHistory, oldest first.
+func History() []HistoryEntry {
+	h := recent.Load()
+	if h == nil {
+		return nil
+	}
+	h.Lock()
+	defer h.Unlock()
+
+	var entries []HistoryEntry
+	if h.full {
+		entries = append(entries, h.entries[h.next:]...)
+	}
+	return append(entries, h.entries[:h.next]...)
+}
+
+func (h *history) add(msg Message) {
+	severity := Severity_Unknown
+	if m, ok := msg.(*GeneralMessage); ok {
+		severity = m.Severity
+	}
+	if severity > h.level || (severity == Severity_Unknown && h.level != Severity_Debug) || len(h.entries) == 0 {
+		return
+	}
+	// formatted before locking
+	s := msg.String()
+	if len(s) > maxHistoryMessageSize {
+		n := maxHistoryMessageSize
+		for n > 0 && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		s = s[:n] + "..."
+	}
+	entry := HistoryEntry{Time: time.Now(), Severity: severity, Message: s}
+
+	h.Lock()
+	defer h.Unlock()
+
+	h.entries[h.next] = entry
+	h.next++
+	if h.next == len(h.entries) {
+		h.next = 0
+		h.full = true
+	}
+}
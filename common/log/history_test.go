@@ -0,0 +1,38 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHistoryLevel(t *testing.T) {
+	h := &history{level: Severity_Warning, entries: make([]HistoryEntry, 10)}
+	h.add(&GeneralMessage{Severity: Severity_Error, Content: "error"})
+	h.add(&GeneralMessage{Severity: Severity_Info, Content: "info"})
+	h.add(&GeneralMessage{Severity: Severity_Warning, Content: "warning"})
+	h.add(&testMessage{"access"})
+
+	var lines []string
+	for _, entry := range h.entries[:h.next] {
+		lines = append(lines, entry.Message)
+	}
+	if diff := cmp.Diff([]string{"[Error] error", "[Warning] warning"}, lines); diff != "" {
+		t.Error(diff)
+	}
+
+	// messages without a severity are kept at the Debug level
+	h = &history{level: Severity_Debug, entries: make([]HistoryEntry, 10)}
+	h.add(&testMessage{"access"})
+	if h.next != 1 || h.entries[0].Severity != Severity_Unknown {
+		t.Error("unexpected entries: ", h.entries[:h.next])
+	}
+}
+
+type testMessage struct {
+	s string
+}
+
+func (m *testMessage) String() string {
+	return m.s
+}
@@ -30,6 +30,9 @@ func (m *GeneralMessage) String() string {
 // Record writes a message into log stream.
 func Record(msg Message) {
 	logHandler.Handle(msg)
+	if h := recent.Load(); h != nil {
+		h.add(msg)
+	}
 }
 
 var logHandler syncHandler
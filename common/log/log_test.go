@@ -1,7 +1,9 @@
 package log_test
 
 import (
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/xtls/xray-core/common/log"
@@ -30,3 +32,50 @@ func TestLogRecord(t *testing.T) {
 		t.Error(diff)
 	}
 }
+
+func TestHistory(t *testing.T) {
+	log.KeepHistory(3, log.Severity_Debug)
+	for _, content := range []string{"a", "b"} {
+		log.Record(&log.GeneralMessage{Severity: log.Severity_Debug, Content: content})
+	}
+	if diff := cmp.Diff([]string{"[Debug] a", "[Debug] b"}, historyLines()); diff != "" {
+		t.Error(diff)
+	}
+	for _, content := range []string{"c", "d"} {
+		log.Record(&log.GeneralMessage{Severity: log.Severity_Info, Content: content})
+	}
+	if diff := cmp.Diff([]string{"[Debug] b", "[Info] c", "[Info] d"}, historyLines()); diff != "" {
+		t.Error(diff)
+	}
+
+	// a smaller history does not replace the one kept
+	log.KeepHistory(1, log.Severity_Error)
+	if diff := cmp.Diff([]string{"[Debug] b", "[Info] c", "[Info] d"}, historyLines()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestHistoryBounds(t *testing.T) {
+	// replaces the history of other tests, as it is not less verbose
+	log.KeepHistory(10, log.Severity_Debug)
+	log.Record(&log.GeneralMessage{Severity: log.Severity_Info, Content: strings.Repeat("é", 1000)})
+	entries := log.History()
+	if len(entries) == 0 {
+		t.Fatal("no history")
+	}
+	last := entries[len(entries)-1]
+	if len(last.Message) > 1024+3 || !utf8.ValidString(last.Message) || !strings.HasSuffix(last.Message, "...") {
+		t.Error("unexpected message of ", len(last.Message), " bytes")
+	}
+	if last.Severity != log.Severity_Info {
+		t.Error("unexpected severity ", last.Severity)
+	}
+}
+
+func historyLines() []string {
+	var lines []string
+	for _, entry := range log.History() {
+		lines = append(lines, entry.Message)
+	}
+	return lines
+}
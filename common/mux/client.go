@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/xtls/xray-core/common"
@@ -14,6 +15,7 @@ import (
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/common/signal/done"
 	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/common/xudp"
 	"github.com/xtls/xray-core/proxy"
 	"github.com/xtls/xray-core/transport"
 	"github.com/xtls/xray-core/transport/internet"
@@ -23,9 +25,14 @@ import (
 type ClientManager struct {
 	Enabled bool // wheather mux is enabled from user config
 	Picker  WorkerPicker
+	// Resumable makes TCP sessions survive their mux connection breaking, by resuming them over another one.
+	Resumable bool
 }
 
 func (m *ClientManager) Dispatch(ctx context.Context, link *transport.Link) error {
+	if m.Resumable && session.OutboundFromContext(ctx).Target.Network == net.Network_TCP {
+		return m.dispatchResumable(ctx, link)
+	}
 	for i := 0; i < 16; i++ {
 		worker, err := m.Picker.PickAvailable()
 		if err != nil {
@@ -39,6 +46,14 @@ func (m *ClientManager) Dispatch(ctx context.Context, link *transport.Link) erro
 	return newError("unable to find an available mux client").AtWarning()
 }
 
+// Drain stops dispatching to the current workers, which close once their sessions end. New sessions go
+// to new workers.
+func (m *ClientManager) Drain() {
+	if d, ok := m.Picker.(interface{ Drain() }); ok {
+		d.Drain()
+	}
+}
+
 type WorkerPicker interface {
 	PickAvailable() (*ClientWorker, error)
 }
@@ -73,6 +88,16 @@ func (p *IncrementalWorkerPicker) cleanup() {
 	p.workers = activeWorkers
 }
 
+// Drain drains all current workers.
+func (p *IncrementalWorkerPicker) Drain() {
+	p.access.Lock()
+	defer p.access.Unlock()
+
+	for _, w := range p.workers {
+		w.Drain()
+	}
+}
+
 func (p *IncrementalWorkerPicker) findAvailable() int {
 	for idx, w := range p.workers {
 		if !w.IsFull() {
@@ -165,6 +190,8 @@ func (f *DialingWorkerFactory) Create() (*ClientWorker, error) {
 type ClientStrategy struct {
 	MaxConcurrency uint32
 	MaxConnection  uint32
+	// Coalesce is whether small frames of the sessions are merged into fewer writes.
+	Coalesce bool
 }
 
 type ClientWorker struct {
@@ -172,6 +199,7 @@ type ClientWorker struct {
 	link           transport.Link
 	done           *done.Instance
 	strategy       ClientStrategy
+	draining       uint32
 }
 
 var (
@@ -181,6 +209,9 @@ var (
 
 // NewClientWorker creates a new mux.Client.
 func NewClientWorker(stream transport.Link, s ClientStrategy) (*ClientWorker, error) {
+	if s.Coalesce {
+		stream.Writer = NewCoalescingWriter(stream.Writer)
+	}
 	c := &ClientWorker{
 		sessionManager: NewSessionManager(),
 		link:           stream,
@@ -248,6 +279,9 @@ func fetchInput(ctx context.Context, s *Session, output buf.Writer) {
 	}
 	s.transferType = transferType
 	writer := NewWriter(s.ID, dest, output, transferType)
+	if transferType == protocol.TransferTypePacket {
+		writer.globalID = xudp.GetGlobalID(ctx)
+	}
 	defer s.Close()
 	defer writer.Close()
 
@@ -267,7 +301,15 @@ func fetchInput(ctx context.Context, s *Session, output buf.Writer) {
 	}
 }
 
+// Drain makes the worker take no new sessions, so that it closes once the current ones end.
+func (m *ClientWorker) Drain() {
+	atomic.StoreUint32(&m.draining, 1)
+}
+
 func (m *ClientWorker) IsClosing() bool {
+	if atomic.LoadUint32(&m.draining) == 1 {
+		return true
+	}
 	sm := m.sessionManager
 	if m.strategy.MaxConnection > 0 && sm.Count() >= int(m.strategy.MaxConnection) {
 		return true
@@ -351,12 +393,16 @@ func (m *ClientWorker) handleStatusKeep(meta *FrameMetadata, reader *buf.Buffere
 
 func (m *ClientWorker) handleStatusEnd(meta *FrameMetadata, reader *buf.BufferedReader) error {
 	if s, found := m.sessionManager.Get(meta.SessionID); found {
-		if meta.Option.Has(OptionError) {
+		if a, ok := s.output.(*resumeAttachment); ok {
+			a.stream.end(a, meta.Option.Has(OptionError))
+		} else {
+			if meta.Option.Has(OptionError) {
+				common.Interrupt(s.input)
+				common.Interrupt(s.output)
+			}
 			common.Interrupt(s.input)
-			common.Interrupt(s.output)
+			s.Close()
 		}
-		common.Interrupt(s.input)
-		s.Close()
 	}
 	if meta.Option.Has(OptionData) {
 		return buf.Copy(NewStreamReader(reader), buf.Discard)
@@ -390,6 +436,8 @@ func (m *ClientWorker) fetchOutput() {
 			err = m.handleStatusNew(&meta, reader)
 		case SessionStatusKeep:
 			err = m.handleStatusKeep(&meta, reader)
+		case SessionStatusAck:
+			err = handleStatusAck(m.sessionManager, &meta, reader)
 		default:
 			status := meta.SessionStatus
 			newError("unknown status: ", status).AtError().WriteToLog()
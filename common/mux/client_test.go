@@ -114,3 +114,20 @@ func TestClientWorkerClose(t *testing.T) {
 
 	common.Must(w2.Close())
 }
+
+func TestClientWorkerDrain(t *testing.T) {
+	r, w := pipe.New(pipe.WithoutSizeLimit())
+	worker, err := mux.NewClientWorker(transport.Link{Reader: r, Writer: w}, mux.ClientStrategy{})
+	common.Must(err)
+
+	if worker.IsFull() {
+		t.Error("expected worker to take sessions")
+	}
+	worker.Drain()
+	if !worker.IsFull() {
+		t.Error("expected drained worker to take no sessions")
+	}
+	if worker.Dispatch(context.Background(), nil) {
+		t.Error("expected failed dispatching to drained worker")
+	}
+}
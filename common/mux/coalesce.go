@@ -0,0 +1,174 @@
+package mux
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+)
+
+const (
+	// how long small frames wait for others to join them
+	coalesceDelay = time.Millisecond
+	// pending frames are written right away once they fill a buffer
+	coalesceSize = buf.Size
+	// writers block while this much is pending behind a slow write
+	coalesceMaxPending = 8 * coalesceSize
+)
+
+// CoalescingWriter merges small frames of all sessions into fewer writes to the underlying
+// connection, so that chatty sessions don't cost a TLS record and a syscall per frame.
+// Like Nagle's algorithm, a write to an idle connection goes out at once; the writes that follow
+// within coalesceDelay are held back and sent together.
+// The lock is not held while writing to the underlying writer. Frames written meanwhile are
+// pending, and the goroutine that is writing writes them after its own, so they stay in order.
+type CoalescingWriter struct {
+	sync.Mutex
+	// signalled when a write to the underlying writer ends
+	written *sync.Cond
+	writer  buf.Writer
+	pending buf.MultiBuffer
+	timer   *time.Timer
+	holding bool
+	writing bool
+	err     error
+}
+
+// NewCoalescingWriter creates a CoalescingWriter on top of the given writer.
+func NewCoalescingWriter(writer buf.Writer) *CoalescingWriter {
+	w := &CoalescingWriter{
+		writer: writer,
+	}
+	w.written = sync.NewCond(&w.Mutex)
+	return w
+}
+
+// write writes mb, and then the pending frames if they fill a buffer. It must be called with the lock
+// held and no other goroutine writing, and releases the lock during the writes.
+func (w *CoalescingWriter) write(mb buf.MultiBuffer) error {
+	w.writing = true
+	defer func() {
+		w.writing = false
+		w.written.Broadcast()
+	}()
+
+	for {
+		w.Unlock()
+		err := w.writer.WriteMultiBuffer(mb)
+		w.Lock()
+		if err != nil {
+			if w.err == nil {
+				w.err = err
+			}
+			w.pending = buf.ReleaseMulti(w.pending)
+			return err
+		}
+		if w.err != nil {
+			return w.err
+		}
+		if w.pending.Len() < coalesceSize {
+			if !w.pending.IsEmpty() && !w.holding {
+				w.hold()
+			}
+			return nil
+		}
+		mb = buf.Compact(w.pending)
+		w.pending = nil
+	}
+}
+
+func (w *CoalescingWriter) hold() {
+	w.holding = true
+	if w.timer == nil {
+		w.timer = time.AfterFunc(coalesceDelay, w.flush)
+	} else {
+		w.timer.Reset(coalesceDelay)
+	}
+}
+
+func (w *CoalescingWriter) flush() {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.err != nil {
+		w.holding = false
+		return
+	}
+	if w.writing {
+		// the pending frames go out after the current write
+		w.hold()
+		return
+	}
+	if w.pending.IsEmpty() {
+		w.holding = false
+		return
+	}
+	mb := buf.Compact(w.pending)
+	w.pending = nil
+	w.hold()
+	w.write(mb)
+}
+
+// WriteMultiBuffer implements buf.Writer.
+func (w *CoalescingWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	w.Lock()
+	defer w.Unlock()
+
+	for w.writing && w.err == nil && w.pending.Len() >= coalesceMaxPending {
+		w.written.Wait()
+	}
+	if w.err != nil {
+		buf.ReleaseMulti(mb)
+		return w.err
+	}
+	if !w.holding && !w.writing {
+		w.hold()
+		return w.write(mb)
+	}
+
+	w.pending = append(w.pending, mb...)
+	if !w.writing && w.pending.Len() >= coalesceSize {
+		mb := buf.Compact(w.pending)
+		w.pending = nil
+		return w.write(mb)
+	}
+	return nil
+}
+
+// Close implements common.Closable. Pending frames are written before the underlying writer is closed.
+func (w *CoalescingWriter) Close() error {
+	w.Lock()
+	for w.writing {
+		w.written.Wait()
+	}
+	if !w.pending.IsEmpty() && w.err == nil {
+		mb := buf.Compact(w.pending)
+		w.pending = nil
+		w.write(mb)
+	}
+	w.pending = buf.ReleaseMulti(w.pending)
+	w.err = io.ErrClosedPipe
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.written.Broadcast()
+	w.Unlock()
+
+	return common.Close(w.writer)
+}
+
+// Interrupt implements common.Interruptible.
+func (w *CoalescingWriter) Interrupt() {
+	w.Lock()
+	w.pending = buf.ReleaseMulti(w.pending)
+	w.err = io.ErrClosedPipe
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.written.Broadcast()
+	w.Unlock()
+
+	common.Interrupt(w.writer)
+}
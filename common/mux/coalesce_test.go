@@ -0,0 +1,105 @@
+package mux_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	. "github.com/xtls/xray-core/common/mux"
+)
+
+type countingWriter struct {
+	sync.Mutex
+	writes int
+	data   []byte
+}
+
+func (w *countingWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	w.Lock()
+	defer w.Unlock()
+	w.writes++
+	b := make([]byte, mb.Len())
+	mb.Copy(b)
+	w.data = append(w.data, b...)
+	buf.ReleaseMulti(mb)
+	return nil
+}
+
+func TestCoalescingWriter(t *testing.T) {
+	cw := &countingWriter{}
+	writer := NewCoalescingWriter(cw)
+
+	for i := 0; i < 100; i++ {
+		common.Must(writer.WriteMultiBuffer(buf.MergeBytes(nil, []byte{byte(i)})))
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	cw.Lock()
+	if cw.writes > 2 {
+		t.Error("writes: ", cw.writes)
+	}
+	if len(cw.data) != 100 {
+		t.Fatal("size: ", len(cw.data))
+	}
+	for i, b := range cw.data {
+		if b != byte(i) {
+			t.Fatal("byte ", i, ": ", b)
+		}
+	}
+	cw.Unlock()
+
+	common.Must(writer.WriteMultiBuffer(buf.MergeBytes(nil, []byte{100})))
+	common.Must(writer.WriteMultiBuffer(buf.MergeBytes(nil, []byte{101})))
+	common.Must(writer.Close())
+	if len(cw.data) != 102 || cw.data[101] != 101 {
+		t.Error("pending data is not written on close")
+	}
+	if writer.WriteMultiBuffer(buf.MergeBytes(nil, []byte{102})) == nil {
+		t.Error("write after close")
+	}
+}
+
+type blockingWriter struct {
+	countingWriter
+	unblock chan struct{}
+}
+
+func (w *blockingWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	<-w.unblock
+	return w.countingWriter.WriteMultiBuffer(mb)
+}
+
+func TestCoalescingWriterSlowWrite(t *testing.T) {
+	bw := &blockingWriter{unblock: make(chan struct{})}
+	writer := NewCoalescingWriter(bw)
+
+	go writer.WriteMultiBuffer(buf.MergeBytes(nil, []byte{0}))
+	time.Sleep(10 * time.Millisecond)
+
+	// frames of other sessions don't wait for the slow write
+	done := make(chan struct{})
+	go func() {
+		for i := 1; i < 10; i++ {
+			common.Must(writer.WriteMultiBuffer(buf.MergeBytes(nil, []byte{byte(i)})))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("writes are blocked by a slow write")
+	}
+
+	close(bw.unblock)
+	common.Must(writer.Close())
+	if len(bw.data) != 10 {
+		t.Fatal("size: ", len(bw.data))
+	}
+	for i, b := range bw.data {
+		if b != byte(i) {
+			t.Fatal("byte ", i, ": ", b)
+		}
+	}
+}
@@ -19,11 +19,14 @@ const (
 	SessionStatusKeep      SessionStatus = 0x02
 	SessionStatusEnd       SessionStatus = 0x03
 	SessionStatusKeepAlive SessionStatus = 0x04
+	SessionStatusAck       SessionStatus = 0x05
 )
 
 const (
 	OptionData  bitmask.Byte = 0x01
 	OptionError bitmask.Byte = 0x02
+	// OptionResumable marks a new TCP session that can be resumed over another mux connection.
+	OptionResumable bitmask.Byte = 0x04
 )
 
 type TargetNetwork byte
@@ -51,6 +54,12 @@ Frame format
 2 bytes - port
 n bytes - address
 
+8 bytes - global id, new UDP sessions only, optional
+
+8 bytes - stream id, new resumable TCP sessions only
+8 bytes - received offset, new resumable TCP sessions and acks only
+8 bytes - resend offset, new resumable TCP sessions only
+
 */
 
 type FrameMetadata struct {
@@ -58,6 +67,15 @@ type FrameMetadata struct {
 	SessionID     uint16
 	Option        bitmask.Byte
 	SessionStatus SessionStatus
+	// GlobalID identifies the client socket of a UDP session across mux connections (XUDP).
+	GlobalID [8]byte
+	// StreamID identifies a resumable TCP session across mux connections.
+	StreamID [8]byte
+	// Received is the number of bytes of a resumable session that the sender of the frame has received,
+	// counting its end as one byte.
+	Received uint64
+	// ResendFrom is the offset in the resumable session of the data that follows the frame.
+	ResendFrom uint64
 }
 
 func (f FrameMetadata) WriteTo(b *buf.Buffer) error {
@@ -81,6 +99,16 @@ func (f FrameMetadata) WriteTo(b *buf.Buffer) error {
 		if err := addrParser.WriteAddressPort(b, f.Target.Address, f.Target.Port); err != nil {
 			return err
 		}
+		if f.Target.Network == net.Network_UDP && f.GlobalID != [8]byte{} {
+			common.Must2(b.Write(f.GlobalID[:]))
+		}
+		if f.Target.Network == net.Network_TCP && f.Option.Has(OptionResumable) {
+			common.Must2(b.Write(f.StreamID[:]))
+			binary.BigEndian.PutUint64(b.Extend(8), f.Received)
+			binary.BigEndian.PutUint64(b.Extend(8), f.ResendFrom)
+		}
+	} else if f.SessionStatus == SessionStatusAck {
+		binary.BigEndian.PutUint64(b.Extend(8), f.Received)
 	} else if b.UDP != nil {
 		b.WriteByte(byte(TargetNetworkUDP))
 		addrParser.WriteAddressPort(b, b.UDP.Address, b.UDP.Port)
@@ -142,6 +170,23 @@ func (f *FrameMetadata) UnmarshalFromBuffer(b *buf.Buffer) error {
 		default:
 			return newError("unknown network type: ", network)
 		}
+
+		if f.SessionStatus == SessionStatusNew && f.Target.Network == net.Network_UDP && b.Len() >= 8 {
+			copy(f.GlobalID[:], b.BytesTo(8))
+		}
+		if f.SessionStatus == SessionStatusNew && f.Target.Network == net.Network_TCP && f.Option.Has(OptionResumable) {
+			if b.Len() < 24 {
+				return newError("insufficient buffer: ", b.Len())
+			}
+			copy(f.StreamID[:], b.BytesTo(8))
+			f.Received = binary.BigEndian.Uint64(b.BytesRange(8, 16))
+			f.ResendFrom = binary.BigEndian.Uint64(b.BytesRange(16, 24))
+		}
+	} else if f.SessionStatus == SessionStatusAck {
+		if b.Len() < 12 {
+			return newError("insufficient buffer: ", b.Len())
+		}
+		f.Received = binary.BigEndian.Uint64(b.BytesRange(4, 12))
 	}
 
 	return nil
@@ -0,0 +1,671 @@
+package mux
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/transport"
+)
+
+const (
+	// resumeTimeout is how long a resumable session waits for a working mux connection after it lost its own.
+	resumeTimeout = time.Minute
+	// resumeRetention is how much sent data is kept for retransmission until the peer acknowledges it.
+	resumeRetention = 2 * 1024 * 1024
+	// resumeAckInterval is how much data is received between two acknowledgements.
+	resumeAckInterval = 128 * 1024
+	// resumeMaxBackoff is the longest wait between two attempts of a client to resume a session.
+	resumeMaxBackoff = 8 * time.Second
+	// resumeMaxStreams is how many resumable streams the server keeps at once. New ones past it are rejected.
+	resumeMaxStreams = 1024
+	// resumeMaxRetained is how much sent data the server keeps for all resumable streams. Streams that
+	// would keep more can't be resumed anymore.
+	resumeMaxRetained = 64 * 1024 * 1024
+)
+
+// resumeKey identifies a resumable stream on the server. Streams are only resumed by the user who
+// started them.
+type resumeKey struct {
+	user string
+	id   [8]byte
+}
+
+var resumeManager = struct {
+	sync.Mutex
+	streams map[resumeKey]*resumableStream
+}{
+	streams: make(map[resumeKey]*resumableStream),
+}
+
+// resumeRetained is the size of the data kept by the resumable streams on the server.
+var resumeRetained int64
+
+// resumableStream is a TCP session that outlives the mux connections it is sent over. Both sides keep
+// the data they sent until the peer acknowledges it, so that when a mux connection breaks, the client
+// attaches the session to another one and both resend what the peer hasn't received yet.
+type resumableStream struct {
+	sync.Mutex
+	cond *sync.Cond
+	id   [8]byte
+	// user is the email of the user who started the stream, set on the server only.
+	user string
+	// counted is whether the retained data counts towards resumeMaxRetained, which is on the server only.
+	counted bool
+	dest    net.Destination
+	link    *transport.Link
+
+	// current is the mux session the stream is attached to, nil while it waits for a new one.
+	current   *resumeAttachment
+	resending bool
+	expire    *time.Timer
+	backoff   time.Duration
+	// reattach moves the stream to another mux connection. It is set on the client only.
+	reattach func(*resumableStream)
+
+	// retained holds the sent data from offset acked to offset sent.
+	retained  buf.MultiBuffer
+	sent      uint64
+	acked     uint64
+	confirmed bool
+	lost      bool
+	finished  bool
+	failed    bool
+	closed    bool
+
+	// recvLock orders the received data of the mux sessions the stream is attached to one after another.
+	recvLock sync.Mutex
+	received uint64
+	unacked  uint64
+	skip     uint64
+}
+
+func newResumableStream(id [8]byte, dest net.Destination, link *transport.Link) *resumableStream {
+	s := &resumableStream{
+		id:   id,
+		dest: dest,
+		link: link,
+	}
+	s.cond = sync.NewCond(&s.Mutex)
+	return s
+}
+
+// resumeAttachment is the output of a mux session attached to a resumable stream. Closing it only
+// detaches the mux session, and leaves the stream waiting for another one.
+type resumeAttachment struct {
+	stream  *resumableStream
+	session *Session
+	output  buf.Writer
+	writer  *Writer
+}
+
+func newResumeAttachment(s *resumableStream, sess *Session, output buf.Writer) *resumeAttachment {
+	a := &resumeAttachment{
+		stream:  s,
+		session: sess,
+		output:  output,
+		writer:  NewResponseWriter(sess.ID, output, protocol.TransferTypeStream),
+	}
+	sess.output = a
+	return a
+}
+
+// WriteMultiBuffer delivers the data received in the mux session, unless the stream has moved on to another one.
+func (a *resumeAttachment) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	s := a.stream
+	s.recvLock.Lock()
+	defer s.recvLock.Unlock()
+
+	if !s.isCurrent(a) {
+		buf.ReleaseMulti(mb)
+		return nil
+	}
+	if s.skip > 0 {
+		var skipped buf.MultiBuffer
+		n := s.skip
+		if l := uint64(mb.Len()); l < n {
+			n = l
+		}
+		mb, skipped = buf.SplitSize(mb, int32(n))
+		buf.ReleaseMulti(skipped)
+		s.skip -= n
+	}
+	if mb.IsEmpty() {
+		return nil
+	}
+
+	n := uint64(mb.Len())
+	s.received += n
+	s.unacked += n
+	// A peer that never confirmed resumption may not know acknowledgements at all.
+	if s.unacked >= resumeAckInterval && s.isConfirmed() {
+		s.unacked = 0
+		a.ack(s.received)
+	}
+	if err := s.link.Writer.WriteMultiBuffer(mb); err != nil {
+		newError("failed to write to downstream. closing resumable session ", a.session.ID).Base(err).WriteToLog()
+		a.end(true)
+		s.close(true)
+	}
+	return nil
+}
+
+func (a *resumeAttachment) Close() error {
+	a.stream.detach(a)
+	return nil
+}
+
+func (a *resumeAttachment) ack(received uint64) error {
+	return writeMeta(a.output, FrameMetadata{
+		SessionID:     a.session.ID,
+		SessionStatus: SessionStatusAck,
+		Received:      received,
+	})
+}
+
+func (a *resumeAttachment) end(failed bool) {
+	a.writer.hasError = failed
+	a.writer.Close()
+}
+
+func writeMeta(writer buf.Writer, meta FrameMetadata) error {
+	frame := buf.New()
+	if err := meta.WriteTo(frame); err != nil {
+		frame.Release()
+		return err
+	}
+	return writer.WriteMultiBuffer(buf.MultiBuffer{frame})
+}
+
+func copyMultiBuffer(mb buf.MultiBuffer) buf.MultiBuffer {
+	var c buf.MultiBuffer
+	for _, b := range mb {
+		c = buf.MergeBytes(c, b.Bytes())
+	}
+	return c
+}
+
+func (s *resumableStream) isCurrent(a *resumeAttachment) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.current == a
+}
+
+func (s *resumableStream) isConfirmed() bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.confirmed
+}
+
+func (s *resumableStream) Closed() bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.closed
+}
+
+// attach sends the stream over the mux session of a, and resends what the peer hasn't received. peer is
+// the new frame that resumed the stream on the server, and nil on the client, which sends one instead.
+func (s *resumableStream) attach(a *resumeAttachment, peer *FrameMetadata) bool {
+	s.recvLock.Lock()
+	defer s.recvLock.Unlock()
+	s.Lock()
+	defer s.Unlock()
+
+	if s.closed || s.lost {
+		return false
+	}
+
+	var first FrameMetadata
+	if peer == nil {
+		first = FrameMetadata{
+			SessionID:     a.session.ID,
+			SessionStatus: SessionStatusNew,
+			Target:        s.dest,
+			StreamID:      s.id,
+			Received:      s.received,
+			ResendFrom:    s.acked,
+		}
+		first.Option.Set(OptionResumable)
+		s.skip = 0
+	} else {
+		if peer.ResendFrom > s.received || peer.Received < s.acked || peer.Received > s.sent {
+			return false
+		}
+		s.skip = s.received - peer.ResendFrom
+		s.dropAcked(peer.Received)
+		first = FrameMetadata{
+			SessionID:     a.session.ID,
+			SessionStatus: SessionStatusAck,
+			Received:      s.received,
+		}
+		s.stopExpire()
+	}
+	s.current = a
+	s.resending = true
+	s.unacked = 0
+	go s.resend(a, first, copyMultiBuffer(s.retained))
+	return true
+}
+
+func (s *resumableStream) resend(a *resumeAttachment, first FrameMetadata, mb buf.MultiBuffer) {
+	err := writeMeta(a.output, first)
+	if err == nil && !mb.IsEmpty() {
+		err = a.writer.WriteMultiBuffer(mb)
+	} else {
+		buf.ReleaseMulti(mb)
+	}
+
+	s.Lock()
+	s.resending = false
+	finished, failed := s.finished, s.failed
+	s.cond.Broadcast()
+	s.Unlock()
+
+	if err != nil {
+		s.detach(a)
+		return
+	}
+	if finished {
+		a.end(failed)
+	}
+}
+
+// detach lets the stream wait for another mux session, unless it got attached to one meanwhile.
+func (s *resumableStream) detach(a *resumeAttachment) {
+	s.Lock()
+	if s.current != a || s.closed {
+		s.Unlock()
+		return
+	}
+	s.current = nil
+	if s.lost || (s.reattach != nil && !s.confirmed) {
+		// The peer doesn't keep the stream, or we didn't keep what it may be missing.
+		s.Unlock()
+		s.close(true)
+		return
+	}
+	if s.expire == nil {
+		s.expire = time.AfterFunc(resumeTimeout, s.timeout)
+	}
+	s.Unlock()
+
+	if s.reattach != nil {
+		time.AfterFunc(s.nextBackoff(), func() { s.reattach(s) })
+	}
+}
+
+// nextBackoff returns how long the client waits before it tries to resume the stream, which grows
+// until the peer acknowledges something again.
+func (s *resumableStream) nextBackoff() time.Duration {
+	s.Lock()
+	defer s.Unlock()
+
+	backoff := s.backoff
+	if s.backoff < resumeMaxBackoff {
+		s.backoff = s.backoff*2 + 250*time.Millisecond
+	}
+	return backoff
+}
+
+func (s *resumableStream) stopExpire() {
+	if s.expire != nil {
+		s.expire.Stop()
+		s.expire = nil
+	}
+}
+
+func (s *resumableStream) timeout() {
+	s.Lock()
+	waiting := s.current == nil
+	s.Unlock()
+	if waiting {
+		newError("resumable session to ", s.dest, " timed out").AtInfo().WriteToLog()
+		s.close(true)
+	}
+}
+
+func (s *resumableStream) dropAcked(offset uint64) {
+	if offset <= s.acked {
+		return
+	}
+	if !s.lost {
+		var dropped buf.MultiBuffer
+		s.retained, dropped = buf.SplitSize(s.retained, int32(offset-s.acked))
+		s.release(dropped)
+	}
+	s.acked = offset
+}
+
+// keep adds sent data to the retained data, unless the server keeps too much already.
+func (s *resumableStream) keep(mb buf.MultiBuffer) {
+	if s.counted {
+		n := int64(mb.Len())
+		if atomic.AddInt64(&resumeRetained, n) > resumeMaxRetained {
+			atomic.AddInt64(&resumeRetained, -n)
+			newError("too much data kept for resumable sessions, session to ", s.dest, " can't be resumed").AtWarning().WriteToLog()
+			s.lose()
+			return
+		}
+	}
+	s.retained = append(s.retained, copyMultiBuffer(mb)...)
+}
+
+// lose stops keeping data for the peer, so that the stream ends with its current mux session.
+func (s *resumableStream) lose() {
+	s.lost = true
+	s.release(s.retained)
+	s.retained = nil
+}
+
+func (s *resumableStream) release(mb buf.MultiBuffer) {
+	if s.counted {
+		atomic.AddInt64(&resumeRetained, -int64(mb.Len()))
+	}
+	buf.ReleaseMulti(mb)
+}
+
+// ack handles an acknowledgement of the peer, which also shows that the current mux connection works.
+func (s *resumableStream) ack(a *resumeAttachment, received uint64) {
+	s.Lock()
+	if s.current != a || s.closed {
+		s.Unlock()
+		return
+	}
+	s.confirmed = true
+	s.backoff = 0
+	s.stopExpire()
+	done := s.finished && received > s.sent
+	if received > s.sent {
+		received = s.sent
+	}
+	s.dropAcked(received)
+	s.cond.Broadcast()
+	s.Unlock()
+
+	if done {
+		s.close(false)
+	}
+}
+
+// WriteMultiBuffer sends data over the current mux session, waiting for one if there is none.
+func (s *resumableStream) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	if mb.IsEmpty() {
+		return nil
+	}
+
+	s.Lock()
+	for !s.closed && (s.current == nil || s.resending || (s.confirmed && !s.lost && s.retained.Len() >= resumeRetention)) {
+		s.cond.Wait()
+	}
+	if s.closed {
+		s.Unlock()
+		buf.ReleaseMulti(mb)
+		return newError("resumable session closed")
+	}
+	a := s.current
+	if !s.lost {
+		if !s.confirmed && s.retained.Len() >= resumeRetention {
+			// The peer may not support resumption, so stop keeping data for it.
+			s.lose()
+		} else {
+			s.keep(mb)
+		}
+	}
+	s.sent += uint64(mb.Len())
+	s.Unlock()
+
+	if err := a.writer.WriteMultiBuffer(mb); err != nil {
+		s.detach(a)
+	}
+	return nil
+}
+
+// pump sends the local input of the stream, and its end once the input ends.
+func (s *resumableStream) pump() {
+	err := buf.Copy(s.link.Reader, s)
+
+	s.Lock()
+	if s.closed || s.finished {
+		s.Unlock()
+		return
+	}
+	s.finished = true
+	s.failed = err != nil
+	a := s.current
+	if s.resending {
+		a = nil
+	}
+	s.Unlock()
+
+	if a != nil {
+		a.end(err != nil)
+	}
+	if err != nil {
+		s.close(true)
+	}
+}
+
+// end handles the end of the stream sent by the peer, which counts as one byte it acknowledges.
+func (s *resumableStream) end(a *resumeAttachment, failed bool) {
+	s.recvLock.Lock()
+	current := s.isCurrent(a)
+	if current {
+		s.received++
+		if s.isConfirmed() {
+			a.ack(s.received)
+		}
+	}
+	s.recvLock.Unlock()
+
+	if current {
+		s.close(failed)
+	} else {
+		a.session.parent.Remove(a.session.ID)
+	}
+}
+
+func (s *resumableStream) close(failed bool) {
+	s.Lock()
+	if s.closed {
+		s.Unlock()
+		return
+	}
+	s.closed = true
+	s.stopExpire()
+	s.release(s.retained)
+	s.retained = nil
+	a := s.current
+	s.current = nil
+	s.cond.Broadcast()
+	s.Unlock()
+
+	resumeManager.Lock()
+	key := resumeKey{user: s.user, id: s.id}
+	if resumeManager.streams[key] == s {
+		delete(resumeManager.streams, key)
+	}
+	resumeManager.Unlock()
+
+	if failed {
+		common.Interrupt(s.link.Writer)
+	} else {
+		common.Close(s.link.Writer)
+	}
+	common.Interrupt(s.link.Reader)
+	if a != nil {
+		a.session.Close()
+	}
+}
+
+func handleStatusAck(sm *SessionManager, meta *FrameMetadata, reader *buf.BufferedReader) error {
+	if s, found := sm.Get(meta.SessionID); found {
+		if a, ok := s.output.(*resumeAttachment); ok {
+			a.stream.ack(a, meta.Received)
+		}
+	}
+	if meta.Option.Has(OptionData) {
+		return buf.Copy(NewStreamReader(reader), buf.Discard)
+	}
+	return nil
+}
+
+// dispatchResumable sends a TCP session over a resumable stream, which moves to another mux connection
+// when its own breaks.
+func (m *ClientManager) dispatchResumable(ctx context.Context, link *transport.Link) error {
+	var id [8]byte
+	common.Must2(rand.Read(id[:]))
+	s := newResumableStream(id, session.OutboundFromContext(ctx).Target, link)
+	s.reattach = m.resume
+
+	for i := 0; i < 16; i++ {
+		worker, err := m.Picker.PickAvailable()
+		if err != nil {
+			return err
+		}
+		if worker.attach(s) {
+			newError("dispatching resumable request to ", s.dest).WriteToLog(session.ExportIDToError(ctx))
+			go s.pump()
+			return nil
+		}
+	}
+
+	return newError("unable to find an available mux client").AtWarning()
+}
+
+func (m *ClientManager) resume(s *resumableStream) {
+	if s.Closed() {
+		return
+	}
+	worker, err := m.Picker.PickAvailable()
+	if err == nil && worker.attach(s) {
+		newError("resuming session to ", s.dest).AtInfo().WriteToLog()
+		return
+	}
+	if err == nil {
+		err = newError("mux client unavailable")
+	}
+	newError("failed to resume session to ", s.dest).Base(err).AtInfo().WriteToLog()
+	time.AfterFunc(s.nextBackoff(), func() { m.resume(s) })
+}
+
+func (m *ClientWorker) attach(s *resumableStream) bool {
+	if m.IsFull() || m.Closed() {
+		return false
+	}
+
+	var a *resumeAttachment
+	sess := m.sessionManager.allocate(func(sess *Session) {
+		sess.transferType = protocol.TransferTypeStream
+		a = newResumeAttachment(s, sess, m.link.Writer)
+	})
+	if sess == nil {
+		return false
+	}
+	if !s.attach(a, nil) {
+		m.sessionManager.Remove(sess.ID)
+		return false
+	}
+	return true
+}
+
+// handleResumableNew attaches a new mux session to the resumable stream of its ID, which is dispatched
+// first if it doesn't exist yet.
+func (w *ServerWorker) handleResumableNew(ctx context.Context, meta *FrameMetadata, reader *buf.BufferedReader) error {
+	key := resumeKey{id: meta.StreamID}
+	if inbound := session.InboundFromContext(ctx); inbound != nil && inbound.User != nil {
+		key.user = inbound.User.Email
+	}
+	resumeManager.Lock()
+	s := resumeManager.streams[key]
+	if s == nil {
+		if meta.Received != 0 || meta.ResendFrom != 0 {
+			resumeManager.Unlock()
+			newError("unable to resume session to ", meta.Target, ", it has ended").AtInfo().WriteToLog(session.ExportIDToError(ctx))
+			return w.rejectResumable(meta, reader)
+		}
+		if len(resumeManager.streams) >= resumeMaxStreams {
+			resumeManager.Unlock()
+			newError("rejected resumable session to ", meta.Target, ", too many resumable sessions").AtWarning().WriteToLog(session.ExportIDToError(ctx))
+			return w.rejectResumable(meta, reader)
+		}
+		link, err := w.dispatcher.Dispatch(detachedContext{ctx}, meta.Target)
+		if err != nil {
+			resumeManager.Unlock()
+			if meta.Option.Has(OptionData) {
+				buf.Copy(NewStreamReader(reader), buf.Discard)
+			}
+			return newError("failed to dispatch request.").Base(err)
+		}
+		s = newResumableStream(meta.StreamID, meta.Target, link)
+		s.user = key.user
+		s.counted = true
+		s.confirmed = true
+		resumeManager.streams[key] = s
+		go s.pump()
+	} else {
+		newError("resuming session to ", meta.Target).WriteToLog(session.ExportIDToError(ctx))
+	}
+	resumeManager.Unlock()
+
+	sess := &Session{
+		parent:       w.sessionManager,
+		ID:           meta.SessionID,
+		transferType: protocol.TransferTypeStream,
+	}
+	a := newResumeAttachment(s, sess, w.link.Writer)
+	w.sessionManager.Add(sess)
+	if !s.attach(a, meta) {
+		newError("unable to resume session to ", meta.Target).AtInfo().WriteToLog(session.ExportIDToError(ctx))
+		a.end(true)
+		w.sessionManager.Remove(sess.ID)
+	}
+	if !meta.Option.Has(OptionData) {
+		return nil
+	}
+
+	rr := sess.NewReader(reader, &meta.Target)
+	return buf.Copy(rr, a)
+}
+
+// rejectResumable ends a session that the server doesn't attach to a resumable stream.
+func (w *ServerWorker) rejectResumable(meta *FrameMetadata, reader *buf.BufferedReader) error {
+	closingWriter := NewResponseWriter(meta.SessionID, w.link.Writer, protocol.TransferTypeStream)
+	closingWriter.hasError = true
+	closingWriter.Close()
+	if meta.Option.Has(OptionData) {
+		return buf.Copy(NewStreamReader(reader), buf.Discard)
+	}
+	return nil
+}
+
+// ReleaseResumable drops the data the server keeps for resumable sessions, so that they end with their
+// current mux connection, and ends the sessions waiting for one. The watchdog calls it under memory pressure.
+func ReleaseResumable() {
+	resumeManager.Lock()
+	streams := make([]*resumableStream, 0, len(resumeManager.streams))
+	for _, s := range resumeManager.streams {
+		streams = append(streams, s)
+	}
+	resumeManager.Unlock()
+
+	for _, s := range streams {
+		s.Lock()
+		s.lose()
+		waiting := s.current == nil
+		s.cond.Broadcast()
+		s.Unlock()
+		if waiting {
+			s.close(true)
+		}
+	}
+}
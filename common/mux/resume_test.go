@@ -0,0 +1,407 @@
+package mux_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	. "github.com/xtls/xray-core/common/mux"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/pipe"
+)
+
+// pipePicker connects its workers to server workers through pipes, which the test can break.
+type pipePicker struct {
+	sync.Mutex
+	dispatcher *testDispatcher
+	worker     *ClientWorker
+	carriers   []*pipe.Reader
+}
+
+func (p *pipePicker) PickAvailable() (*ClientWorker, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.worker != nil && !p.worker.Closed() {
+		return p.worker, nil
+	}
+	uplinkReader, uplinkWriter := pipe.New(pipe.WithoutSizeLimit())
+	downlinkReader, downlinkWriter := pipe.New(pipe.WithoutSizeLimit())
+	common.Must2(NewResumableServerWorker(context.Background(), p.dispatcher, &transport.Link{
+		Reader: uplinkReader,
+		Writer: downlinkWriter,
+	}))
+	worker, err := NewClientWorker(transport.Link{Reader: downlinkReader, Writer: uplinkWriter}, ClientStrategy{})
+	if err != nil {
+		return nil, err
+	}
+	p.worker = worker
+	p.carriers = []*pipe.Reader{uplinkReader, downlinkReader}
+	return worker, nil
+}
+
+// breakCarrier drops the current mux connection along with the data in flight.
+func (p *pipePicker) breakCarrier() {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, r := range p.carriers {
+		r.Interrupt()
+	}
+}
+
+func writePattern(t *testing.T, writer buf.Writer, from, to int) {
+	for i := from; i < to; {
+		b := buf.New()
+		for ; i < to && !b.IsFull(); i++ {
+			common.Must(b.WriteByte(byte(i % 251)))
+		}
+		if err := writer.WriteMultiBuffer(buf.MultiBuffer{b}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readPattern(t *testing.T, reader *buf.BufferedReader, from, to int) {
+	expected := make([]byte, 0, to-from)
+	for i := from; i < to; i++ {
+		expected = append(expected, byte(i%251))
+	}
+	actual := make([]byte, to-from)
+	if _, err := io.ReadFull(reader, actual); err != nil {
+		t.Fatal("failed to read from offset ", from, ": ", err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Fatal("unexpected data from offset ", from)
+	}
+}
+
+func TestResumableSession(t *testing.T) {
+	dispatcher := &testDispatcher{
+		outbounds: make(chan *transport.Link, 1),
+	}
+	picker := &pipePicker{dispatcher: dispatcher}
+	manager := &ClientManager{
+		Enabled:   true,
+		Picker:    picker,
+		Resumable: true,
+	}
+
+	ctx := session.ContextWithOutbound(context.Background(), &session.Outbound{
+		Target: net.TCPDestination(net.DomainAddress("example.com"), 80),
+	})
+	uplinkReader, uplinkWriter := pipe.New(pipe.WithoutSizeLimit())
+	downlinkReader, downlinkWriter := pipe.New(pipe.WithoutSizeLimit())
+	common.Must(manager.Dispatch(ctx, &transport.Link{Reader: uplinkReader, Writer: downlinkWriter}))
+
+	const size = 512 * 1024
+	writePattern(t, uplinkWriter, 0, size)
+	outbound := <-dispatcher.outbounds
+	outboundReader := &buf.BufferedReader{Reader: outbound.Reader}
+	downlink := &buf.BufferedReader{Reader: downlinkReader}
+	readPattern(t, outboundReader, 0, size)
+	writePattern(t, outbound.Writer, 0, size)
+	readPattern(t, downlink, 0, size/2)
+
+	picker.breakCarrier()
+	writePattern(t, uplinkWriter, size, 2*size)
+	writePattern(t, outbound.Writer, size, 2*size)
+	readPattern(t, outboundReader, size, 2*size)
+	readPattern(t, downlink, size/2, 2*size)
+
+	picker.breakCarrier()
+	common.Must(common.Close(outbound.Writer))
+	if _, err := downlinkReader.ReadMultiBuffer(); err == nil {
+		t.Error("expect the session to end")
+	}
+	if c := atomic.LoadInt32(&dispatcher.count); c != 1 {
+		t.Error("expect the session to be dispatched once, got ", c)
+	}
+}
+
+type staticPicker struct {
+	worker *ClientWorker
+}
+
+func (p *staticPicker) PickAvailable() (*ClientWorker, error) {
+	return p.worker, nil
+}
+
+func TestResumableSessionWithoutResumeSupport(t *testing.T) {
+	uplinkReader, uplinkWriter := pipe.New(pipe.WithoutSizeLimit())
+	downlinkReader, downlinkWriter := pipe.New(pipe.WithoutSizeLimit())
+	worker, err := NewClientWorker(transport.Link{Reader: downlinkReader, Writer: uplinkWriter}, ClientStrategy{})
+	common.Must(err)
+	manager := &ClientManager{
+		Enabled:   true,
+		Picker:    &staticPicker{worker: worker},
+		Resumable: true,
+	}
+
+	// the server ignores the resumption of sessions, and doesn't know acknowledgements
+	sessions := make(chan uint16, 1)
+	go func() {
+		reader := &buf.BufferedReader{Reader: uplinkReader}
+		for {
+			var meta FrameMetadata
+			if err := meta.Unmarshal(reader); err != nil {
+				return
+			}
+			switch meta.SessionStatus {
+			case SessionStatusNew:
+				sessions <- meta.SessionID
+			case SessionStatusAck:
+				t.Error("unexpected acknowledgement")
+			}
+			if meta.Option.Has(OptionData) {
+				common.Must(buf.Copy(NewStreamReader(reader), buf.Discard))
+			}
+		}
+	}()
+
+	ctx := session.ContextWithOutbound(context.Background(), &session.Outbound{
+		Target: net.TCPDestination(net.DomainAddress("example.com"), 80),
+	})
+	linkUplinkReader, linkUplinkWriter := pipe.New(pipe.WithoutSizeLimit())
+	linkDownlinkReader, linkDownlinkWriter := pipe.New(pipe.WithoutSizeLimit())
+	common.Must(manager.Dispatch(ctx, &transport.Link{Reader: linkUplinkReader, Writer: linkDownlinkWriter}))
+	writePattern(t, linkUplinkWriter, 0, 1024)
+
+	const size = 512 * 1024
+	responseWriter := NewResponseWriter(<-sessions, downlinkWriter, protocol.TransferTypeStream)
+	writePattern(t, responseWriter, 0, size)
+	common.Must(responseWriter.Close())
+
+	downlink := &buf.BufferedReader{Reader: linkDownlinkReader}
+	readPattern(t, downlink, 0, size)
+	if _, err := downlink.ReadMultiBuffer(); err != io.EOF {
+		t.Error("expect the session to end, got ", err)
+	}
+	common.Interrupt(uplinkWriter)
+	common.Interrupt(downlinkWriter)
+}
+
+func TestResumableSessionOfAnotherUser(t *testing.T) {
+	dispatcher := &testDispatcher{
+		outbounds: make(chan *transport.Link, 2),
+	}
+	meta := FrameMetadata{
+		SessionID:     1,
+		SessionStatus: SessionStatusNew,
+		Target:        net.TCPDestination(net.DomainAddress("example.com"), 80),
+		StreamID:      [8]byte{1, 2, 3, 4, 5, 6, 7, 8},
+	}
+	meta.Option.Set(OptionData)
+	meta.Option.Set(OptionResumable)
+
+	var outbounds []*transport.Link
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		ctx := session.ContextWithInbound(context.Background(), &session.Inbound{
+			User: &protocol.MemoryUser{Email: email},
+		})
+		uplinkReader, uplinkWriter := pipe.New(pipe.WithoutSizeLimit())
+		_, downlinkWriter := pipe.New(pipe.WithoutSizeLimit())
+		common.Must2(NewResumableServerWorker(ctx, dispatcher, &transport.Link{
+			Reader: uplinkReader,
+			Writer: downlinkWriter,
+		}))
+		writeNewFrame(uplinkWriter, meta, email)
+		outbound := <-dispatcher.outbounds
+		mb, err := outbound.Reader.ReadMultiBuffer()
+		common.Must(err)
+		if s := mb.String(); s != email {
+			t.Error("unexpected payload: ", s)
+		}
+		buf.ReleaseMulti(mb)
+		outbounds = append(outbounds, outbound)
+	}
+	if c := atomic.LoadInt32(&dispatcher.count); c != 2 {
+		t.Error("expect the sessions of the users to be dispatched separately, got ", c)
+	}
+	for _, outbound := range outbounds {
+		common.Interrupt(outbound.Writer)
+	}
+}
+
+// readResponses reads the frames a server worker sends, and passes the sessions that end with an
+// error to ended, until the worker stops.
+func readResponses(reader buf.Reader, received *int64, acked, ended chan<- uint16) {
+	r := &buf.BufferedReader{Reader: reader}
+	for {
+		var meta FrameMetadata
+		if err := meta.Unmarshal(r); err != nil {
+			return
+		}
+		switch {
+		case meta.SessionStatus == SessionStatusAck && acked != nil:
+			acked <- meta.SessionID
+		case meta.SessionStatus == SessionStatusEnd && meta.Option.Has(OptionError) && ended != nil:
+			ended <- meta.SessionID
+		}
+		if meta.Option.Has(OptionData) {
+			mb, err := NewStreamReader(r).ReadMultiBuffer()
+			if err != nil {
+				return
+			}
+			atomic.AddInt64(received, int64(mb.Len()))
+			buf.ReleaseMulti(mb)
+		}
+	}
+}
+
+func TestResumableSessionDisabled(t *testing.T) {
+	dispatcher := &testDispatcher{
+		outbounds: make(chan *transport.Link, 1),
+	}
+	uplinkReader, uplinkWriter := pipe.New(pipe.WithoutSizeLimit())
+	downlinkReader, downlinkWriter := pipe.New(pipe.WithoutSizeLimit())
+	common.Must2(NewServerWorker(context.Background(), dispatcher, &transport.Link{
+		Reader: uplinkReader,
+		Writer: downlinkWriter,
+	}))
+	var received int64
+	ended := make(chan uint16, 1)
+	go readResponses(downlinkReader, &received, nil, ended)
+
+	meta := FrameMetadata{
+		SessionID:     1,
+		SessionStatus: SessionStatusNew,
+		Target:        net.TCPDestination(net.DomainAddress("example.com"), 80),
+		StreamID:      [8]byte{1},
+	}
+	meta.Option.Set(OptionData)
+	meta.Option.Set(OptionResumable)
+	writeNewFrame(uplinkWriter, meta, "new")
+	outbound := <-dispatcher.outbounds
+	mb, err := outbound.Reader.ReadMultiBuffer()
+	common.Must(err)
+	if s := mb.String(); s != "new" {
+		t.Error("unexpected payload: ", s)
+	}
+	buf.ReleaseMulti(mb)
+
+	// a session can't be resumed on a server that doesn't keep them
+	meta.SessionID = 2
+	meta.Received = 1024
+	meta.ResendFrom = 1024
+	writeNewFrame(uplinkWriter, meta, "resumed")
+	if id := <-ended; id != 2 {
+		t.Error("expect the resumed session to be rejected, got ", id)
+	}
+	if c := atomic.LoadInt32(&dispatcher.count); c != 1 {
+		t.Error("expect one session to be dispatched, got ", c)
+	}
+	common.Interrupt(uplinkWriter)
+	common.Interrupt(outbound.Writer)
+}
+
+func TestResumableSessionPeerNeverAcks(t *testing.T) {
+	dispatcher := &testDispatcher{
+		outbounds: make(chan *transport.Link, 1),
+	}
+	uplinkReader, uplinkWriter := pipe.New(pipe.WithoutSizeLimit())
+	downlinkReader, downlinkWriter := pipe.New(pipe.WithoutSizeLimit())
+	common.Must2(NewResumableServerWorker(context.Background(), dispatcher, &transport.Link{
+		Reader: uplinkReader,
+		Writer: downlinkWriter,
+	}))
+	var received int64
+	go readResponses(downlinkReader, &received, nil, nil)
+
+	meta := FrameMetadata{
+		SessionID:     1,
+		SessionStatus: SessionStatusNew,
+		Target:        net.TCPDestination(net.DomainAddress("example.com"), 80),
+		StreamID:      [8]byte{2},
+	}
+	meta.Option.Set(OptionData)
+	meta.Option.Set(OptionResumable)
+	writeNewFrame(uplinkWriter, meta, "new")
+	outbound := <-dispatcher.outbounds
+	mb, err := outbound.Reader.ReadMultiBuffer()
+	common.Must(err)
+	buf.ReleaseMulti(mb)
+
+	// the server keeps what it sent until the peer acknowledges it, which this one never does
+	const retention = 2 * 1024 * 1024
+	const size = retention + 1024*1024
+	writePattern(t, outbound.Writer, 0, size)
+	for atomic.LoadInt64(&received) < retention {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt64(&received); n >= size {
+		t.Error("expect the server to stop sending at the retention limit, got ", n)
+	}
+
+	// the watchdog drops the kept data, after which the session can't be resumed
+	ReleaseResumable()
+	for atomic.LoadInt64(&received) < size {
+		time.Sleep(10 * time.Millisecond)
+	}
+	common.Interrupt(uplinkWriter)
+	if _, err := outbound.Reader.ReadMultiBuffer(); err == nil {
+		t.Error("expect the session to end with its mux connection")
+	}
+}
+
+func TestResumableSessionLimit(t *testing.T) {
+	const count = 1100
+	dispatcher := &testDispatcher{
+		outbounds: make(chan *transport.Link, count),
+	}
+	uplinkReader, uplinkWriter := pipe.New(pipe.WithoutSizeLimit())
+	downlinkReader, downlinkWriter := pipe.New(pipe.WithoutSizeLimit())
+	common.Must2(NewResumableServerWorker(context.Background(), dispatcher, &transport.Link{
+		Reader: uplinkReader,
+		Writer: downlinkWriter,
+	}))
+	var received int64
+	acked := make(chan uint16, count)
+	ended := make(chan uint16, count)
+	go readResponses(downlinkReader, &received, acked, ended)
+
+	for i := 1; i <= count; i++ {
+		meta := FrameMetadata{
+			SessionID:     uint16(i),
+			SessionStatus: SessionStatusNew,
+			Target:        net.TCPDestination(net.DomainAddress("example.com"), 80),
+			StreamID:      [8]byte{3, byte(i >> 8), byte(i)},
+		}
+		meta.Option.Set(OptionResumable)
+		b := buf.New()
+		common.Must(meta.WriteTo(b))
+		common.Must(uplinkWriter.WriteMultiBuffer(buf.MultiBuffer{b}))
+	}
+	var accepted, rejected int
+	for accepted+rejected < count {
+		select {
+		case <-acked:
+			accepted++
+		case <-ended:
+			rejected++
+		}
+	}
+	if accepted > 1024 || rejected < count-1024 {
+		t.Error("expect sessions past the limit to be rejected, got ", accepted, " accepted and ", rejected, " rejected")
+	}
+
+	// the sessions give up when the mux connection breaks, as the watchdog dropped their data
+	ReleaseResumable()
+	common.Interrupt(uplinkWriter)
+	for i := 0; i < accepted; i++ {
+		outbound := <-dispatcher.outbounds
+		if _, err := outbound.Reader.ReadMultiBuffer(); err == nil {
+			t.Error("expect the session to end")
+		}
+	}
+}
@@ -19,6 +19,10 @@ import (
 
 type Server struct {
 	dispatcher routing.Dispatcher
+	// Coalesce is whether small frames of the sessions of a Mux connection are merged into fewer writes.
+	Coalesce bool
+	// Resumable is whether clients may start resumable sessions, which outlive their Mux connection.
+	Resumable bool
 }
 
 // NewServer creates a new mux.Server.
@@ -45,10 +49,10 @@ func (s *Server) Dispatch(ctx context.Context, dest net.Destination) (*transport
 	uplinkReader, uplinkWriter := pipe.New(opts...)
 	downlinkReader, downlinkWriter := pipe.New(opts...)
 
-	_, err := NewServerWorker(ctx, s.dispatcher, &transport.Link{
+	_, err := newServerWorker(ctx, s.dispatcher, &transport.Link{
 		Reader: uplinkReader,
 		Writer: downlinkWriter,
-	})
+	}, s.Coalesce, s.Resumable)
 	if err != nil {
 		return nil, err
 	}
@@ -61,7 +65,7 @@ func (s *Server) DispatchLink(ctx context.Context, dest net.Destination, link *t
 	if dest.Address != muxCoolAddress {
 		return s.dispatcher.DispatchLink(ctx, dest, link)
 	}
-	_, err := NewServerWorker(ctx, s.dispatcher, link)
+	_, err := newServerWorker(ctx, s.dispatcher, link, s.Coalesce, s.Resumable)
 	return err
 }
 
@@ -79,13 +83,30 @@ type ServerWorker struct {
 	dispatcher     routing.Dispatcher
 	link           *transport.Link
 	sessionManager *SessionManager
+	resumable      bool
 }
 
 func NewServerWorker(ctx context.Context, d routing.Dispatcher, link *transport.Link) (*ServerWorker, error) {
+	return newServerWorker(ctx, d, link, false, false)
+}
+
+// NewResumableServerWorker creates a ServerWorker that accepts resumable sessions.
+func NewResumableServerWorker(ctx context.Context, d routing.Dispatcher, link *transport.Link) (*ServerWorker, error) {
+	return newServerWorker(ctx, d, link, false, true)
+}
+
+func newServerWorker(ctx context.Context, d routing.Dispatcher, link *transport.Link, coalesce, resumable bool) (*ServerWorker, error) {
+	if coalesce {
+		link = &transport.Link{
+			Reader: link.Reader,
+			Writer: NewCoalescingWriter(link.Writer),
+		}
+	}
 	worker := &ServerWorker{
 		dispatcher:     d,
 		link:           link,
 		sessionManager: NewSessionManager(),
+		resumable:      resumable,
 	}
 	go worker.run(ctx)
 	return worker, nil
@@ -131,6 +152,19 @@ func (w *ServerWorker) handleStatusNew(ctx context.Context, meta *FrameMetadata,
 		}
 		ctx = log.ContextWithAccessMessage(ctx, msg)
 	}
+	if meta.Target.Network == net.Network_UDP && meta.GlobalID != [8]byte{} {
+		return w.handleXUDPNew(ctx, meta, reader)
+	}
+	if meta.Target.Network == net.Network_TCP && meta.Option.Has(OptionResumable) {
+		if w.resumable {
+			return w.handleResumableNew(ctx, meta, reader)
+		}
+		// Without resumption, the session is a plain one, which the client handles, but it can't be resumed.
+		if meta.Received != 0 || meta.ResendFrom != 0 {
+			newError("unable to resume session to ", meta.Target, ", resumable sessions are disabled").AtInfo().WriteToLog(session.ExportIDToError(ctx))
+			return w.rejectResumable(meta, reader)
+		}
+	}
 	link, err := w.dispatcher.Dispatch(ctx, meta.Target)
 	if err != nil {
 		if meta.Option.Has(OptionData) {
@@ -198,12 +232,16 @@ func (w *ServerWorker) handleStatusKeep(meta *FrameMetadata, reader *buf.Buffere
 
 func (w *ServerWorker) handleStatusEnd(meta *FrameMetadata, reader *buf.BufferedReader) error {
 	if s, found := w.sessionManager.Get(meta.SessionID); found {
-		if meta.Option.Has(OptionError) {
+		if a, ok := s.output.(*resumeAttachment); ok {
+			a.stream.end(a, meta.Option.Has(OptionError))
+		} else {
+			if meta.Option.Has(OptionError) {
+				common.Interrupt(s.input)
+				common.Interrupt(s.output)
+			}
 			common.Interrupt(s.input)
-			common.Interrupt(s.output)
+			s.Close()
 		}
-		common.Interrupt(s.input)
-		s.Close()
 	}
 	if meta.Option.Has(OptionData) {
 		return buf.Copy(NewStreamReader(reader), buf.Discard)
@@ -227,6 +265,8 @@ func (w *ServerWorker) handleFrame(ctx context.Context, reader *buf.BufferedRead
 		err = w.handleStatusNew(ctx, &meta, reader)
 	case SessionStatusKeep:
 		err = w.handleStatusKeep(&meta, reader)
+	case SessionStatusAck:
+		err = handleStatusAck(w.sessionManager, &meta, reader)
 	default:
 		status := meta.SessionStatus
 		return newError("unknown status: ", status).AtError()
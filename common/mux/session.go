@@ -45,6 +45,11 @@ func (m *SessionManager) Count() int {
 }
 
 func (m *SessionManager) Allocate() *Session {
+	return m.allocate(nil)
+}
+
+// allocate lets init set up the new session before any other goroutine can get it.
+func (m *SessionManager) allocate(init func(*Session)) *Session {
 	m.Lock()
 	defer m.Unlock()
 
@@ -57,6 +62,9 @@ func (m *SessionManager) Allocate() *Session {
 		ID:     m.count,
 		parent: m,
 	}
+	if init != nil {
+		init(s)
+	}
 	m.sessions[s.ID] = s
 	return s
 }
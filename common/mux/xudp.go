@@ -0,0 +1,191 @@
+package mux
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/transport"
+)
+
+// xudpTimeout is how long the UDP session of a client socket is kept after it left its mux
+// connection, waiting for the client to come back with it on another one.
+const xudpTimeout = time.Minute
+
+var xudpManager = struct {
+	sync.Mutex
+	sessions map[[8]byte]*xudpSession
+}{
+	sessions: make(map[[8]byte]*xudpSession),
+}
+
+// xudpSession is a UDP session identified by the global ID of its client socket (XUDP). It
+// outlives the mux sessions it is attached to, so the full-cone NAT mapping of the socket
+// survives when the client moves it to another mux connection.
+type xudpSession struct {
+	sync.Mutex
+	globalID [8]byte
+	link     *transport.Link
+	session  *Session
+	writer   *Writer
+	expire   *time.Timer
+	closed   bool
+}
+
+// detachedContext keeps the values of its parent but not its cancellation, so that the
+// outbound of a UDP session isn't closed with the mux connection it started on.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+// handleXUDPNew attaches a new mux session to the UDP session of its global ID, which is
+// dispatched first if it doesn't exist yet.
+func (w *ServerWorker) handleXUDPNew(ctx context.Context, meta *FrameMetadata, reader *buf.BufferedReader) error {
+	xudpManager.Lock()
+	x := xudpManager.sessions[meta.GlobalID]
+	if x == nil {
+		link, err := w.dispatcher.Dispatch(detachedContext{ctx}, meta.Target)
+		if err != nil {
+			xudpManager.Unlock()
+			if meta.Option.Has(OptionData) {
+				buf.Copy(NewStreamReader(reader), buf.Discard)
+			}
+			return newError("failed to dispatch request.").Base(err)
+		}
+		x = &xudpSession{
+			globalID: meta.GlobalID,
+			link:     link,
+		}
+		xudpManager.sessions[meta.GlobalID] = x
+		go x.run()
+	} else {
+		newError("reusing UDP session of ", meta.Target).WriteToLog(session.ExportIDToError(ctx))
+	}
+	xudpManager.Unlock()
+
+	s := &Session{
+		parent:       w.sessionManager,
+		ID:           meta.SessionID,
+		transferType: protocol.TransferTypePacket,
+	}
+	writer := NewResponseWriter(s.ID, w.link.Writer, protocol.TransferTypePacket)
+	s.output = &xudpWriter{x: x, writer: writer}
+	w.sessionManager.Add(s)
+	x.attach(s, writer)
+	if !meta.Option.Has(OptionData) {
+		return nil
+	}
+
+	rr := s.NewReader(reader, &meta.Target)
+	if err := buf.Copy(rr, s.output); err != nil {
+		buf.Copy(rr, buf.Discard)
+		return s.Close()
+	}
+	return nil
+}
+
+func (x *xudpSession) attach(s *Session, writer *Writer) {
+	x.Lock()
+	defer x.Unlock()
+
+	if x.expire != nil {
+		x.expire.Stop()
+		x.expire = nil
+	}
+	x.session = s
+	x.writer = writer
+	if x.closed {
+		writer.Close()
+	}
+}
+
+// detach lets the session wait for its client, unless it got attached to another mux session meanwhile.
+func (x *xudpSession) detach(writer *Writer) {
+	x.Lock()
+	defer x.Unlock()
+
+	if x.writer != writer {
+		return
+	}
+	x.session = nil
+	x.writer = nil
+	if !x.closed {
+		x.expire = time.AfterFunc(xudpTimeout, x.timeout)
+	}
+}
+
+func (x *xudpSession) timeout() {
+	x.Lock()
+	waiting := x.writer == nil
+	x.Unlock()
+	if waiting {
+		common.Interrupt(x.link.Reader)
+		common.Interrupt(x.link.Writer)
+	}
+}
+
+// run sends the responses to the mux session the UDP session is currently attached to, if any.
+func (x *xudpSession) run() {
+	for {
+		mb, err := x.link.Reader.ReadMultiBuffer()
+		if err != nil {
+			x.close()
+			return
+		}
+		x.Lock()
+		writer := x.writer
+		x.Unlock()
+		if writer == nil {
+			buf.ReleaseMulti(mb)
+			continue
+		}
+		if err := writer.WriteMultiBuffer(mb); err != nil {
+			x.detach(writer)
+		}
+	}
+}
+
+func (x *xudpSession) close() {
+	xudpManager.Lock()
+	if xudpManager.sessions[x.globalID] == x {
+		delete(xudpManager.sessions, x.globalID)
+	}
+	xudpManager.Unlock()
+
+	common.Interrupt(x.link.Writer)
+
+	x.Lock()
+	x.closed = true
+	s, writer := x.session, x.writer
+	x.Unlock()
+	if writer != nil {
+		writer.Close()
+		s.Close()
+	}
+}
+
+// xudpWriter is the output of a mux session attached to a UDP session. Closing it only
+// detaches the mux session, and leaves the UDP session waiting for its client.
+type xudpWriter struct {
+	x      *xudpSession
+	writer *Writer
+}
+
+func (w *xudpWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	return w.x.link.Writer.WriteMultiBuffer(mb)
+}
+
+func (w *xudpWriter) Close() error {
+	w.x.detach(w.writer)
+	return nil
+}
@@ -0,0 +1,114 @@
+package mux_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	. "github.com/xtls/xray-core/common/mux"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/pipe"
+)
+
+type testDispatcher struct {
+	count     int32
+	outbounds chan *transport.Link
+}
+
+func (*testDispatcher) Type() interface{} { return routing.DispatcherType() }
+
+func (*testDispatcher) Start() error { return nil }
+
+func (*testDispatcher) Close() error { return nil }
+
+func (d *testDispatcher) Dispatch(ctx context.Context, dest net.Destination) (*transport.Link, error) {
+	atomic.AddInt32(&d.count, 1)
+	upReader, upWriter := pipe.New(pipe.WithoutSizeLimit())
+	downReader, downWriter := pipe.New(pipe.WithoutSizeLimit())
+	d.outbounds <- &transport.Link{Reader: upReader, Writer: downWriter}
+	return &transport.Link{Reader: downReader, Writer: upWriter}, nil
+}
+
+func (*testDispatcher) DispatchLink(ctx context.Context, dest net.Destination, link *transport.Link) error {
+	return nil
+}
+
+func writeNewFrame(writer buf.Writer, meta FrameMetadata, payload string) {
+	b := buf.New()
+	common.Must(meta.WriteTo(b))
+	common.Must2(serial.WriteUint16(b, uint16(len(payload))))
+	common.Must2(b.WriteString(payload))
+	common.Must(writer.WriteMultiBuffer(buf.MultiBuffer{b}))
+}
+
+func TestXUDPSessionReuse(t *testing.T) {
+	dispatcher := &testDispatcher{
+		outbounds: make(chan *transport.Link, 2),
+	}
+	meta := FrameMetadata{
+		SessionID:     1,
+		SessionStatus: SessionStatusNew,
+		Target:        net.UDPDestination(net.LocalHostIP, 53),
+		GlobalID:      [8]byte{1, 2, 3, 4, 5, 6, 7, 8},
+	}
+	meta.Option.Set(OptionData)
+
+	uplinkReader, uplinkWriter := pipe.New(pipe.WithoutSizeLimit())
+	_, downlinkWriter := pipe.New(pipe.WithoutSizeLimit())
+	common.Must2(NewServerWorker(context.Background(), dispatcher, &transport.Link{
+		Reader: uplinkReader,
+		Writer: downlinkWriter,
+	}))
+	writeNewFrame(uplinkWriter, meta, "a")
+	outbound := <-dispatcher.outbounds
+	mb, err := outbound.Reader.ReadMultiBuffer()
+	common.Must(err)
+	if s := mb.String(); s != "a" {
+		t.Error("unexpected payload: ", s)
+	}
+	buf.ReleaseMulti(mb)
+
+	// the client moves the socket to another mux connection
+	common.Must(uplinkWriter.Close())
+
+	uplinkReader, uplinkWriter = pipe.New(pipe.WithoutSizeLimit())
+	downlinkReader, downlinkWriter := pipe.New(pipe.WithoutSizeLimit())
+	common.Must2(NewServerWorker(context.Background(), dispatcher, &transport.Link{
+		Reader: uplinkReader,
+		Writer: downlinkWriter,
+	}))
+	writeNewFrame(uplinkWriter, meta, "b")
+	mb, err = outbound.Reader.ReadMultiBuffer()
+	common.Must(err)
+	if s := mb.String(); s != "b" {
+		t.Error("unexpected payload: ", s)
+	}
+	buf.ReleaseMulti(mb)
+	if c := atomic.LoadInt32(&dispatcher.count); c != 1 {
+		t.Fatal("expect the UDP session to be dispatched once, got ", c)
+	}
+
+	b := buf.New()
+	common.Must2(b.WriteString("c"))
+	common.Must(outbound.Writer.WriteMultiBuffer(buf.MultiBuffer{b}))
+
+	reader := &buf.BufferedReader{Reader: downlinkReader}
+	var response FrameMetadata
+	common.Must(response.Unmarshal(reader))
+	if response.SessionID != 1 || response.SessionStatus != SessionStatusKeep {
+		t.Error("unexpected response: ", response)
+	}
+	data, err := readAll(NewStreamReader(reader))
+	common.Must(err)
+	if s := data.String(); s != "c" {
+		t.Error("unexpected response payload: ", s)
+	}
+
+	common.Interrupt(outbound.Reader)
+	common.Interrupt(outbound.Writer)
+}
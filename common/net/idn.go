@@ -0,0 +1,42 @@
+package net
+
+import (
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// idnProfile converts domains as resolvers do, but lets through names such as those with underscores.
+var idnProfile = idna.New(idna.MapForLookup(), idna.StrictDomainName(false))
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizeDomain returns the A-label (punycode) form of an internationalized domain, which is how domains
+// are stored in rules, geosite and DNS. Other domains are returned as they are.
+func NormalizeDomain(domain string) string {
+	if isASCII(domain) {
+		return domain
+	}
+	if ascii, err := idnProfile.ToASCII(domain); err == nil {
+		return ascii
+	}
+	return domain
+}
+
+// DisplayDomain returns the U-label (Unicode) form of a domain for display, such as in logs.
+func DisplayDomain(domain string) string {
+	if !strings.Contains(strings.ToLower(domain), "xn--") {
+		return domain
+	}
+	if unicode, err := idna.Display.ToUnicode(domain); err == nil {
+		return unicode
+	}
+	return domain
+}
@@ -0,0 +1,43 @@
+package net_test
+
+import (
+	"testing"
+
+	. "github.com/xtls/xray-core/common/net"
+)
+
+func TestNormalizeDomain(t *testing.T) {
+	cases := []struct {
+		input  string
+		output string
+	}{
+		{"example.com", "example.com"},
+		{"_dmarc.example.com", "_dmarc.example.com"},
+		{"例子.测试", "xn--fsqu00a.xn--0zwm56d"},
+		{"Bücher.example", "xn--bcher-kva.example"},
+		{"例子。测试", "xn--fsqu00a.xn--0zwm56d"},
+		{"xn--fsqu00a.xn--0zwm56d", "xn--fsqu00a.xn--0zwm56d"},
+	}
+	for _, c := range cases {
+		if r := NormalizeDomain(c.input); r != c.output {
+			t.Error("normalizing ", c.input, ": expected ", c.output, " but got ", r)
+		}
+	}
+}
+
+func TestDisplayDomain(t *testing.T) {
+	cases := []struct {
+		input  string
+		output string
+	}{
+		{"example.com", "example.com"},
+		{"xn--fsqu00a.xn--0zwm56d", "例子.测试"},
+		{"XN--fsqu00a.example.com", "例子.example.com"},
+		{"xn--bcher-kva.example", "bücher.example"},
+	}
+	for _, c := range cases {
+		if r := DisplayDomain(c.input); r != c.output {
+			t.Error("displaying ", c.input, ": expected ", c.output, " but got ", r)
+		}
+	}
+}
@@ -30,6 +30,8 @@ var SplitHostPort = net.SplitHostPort
 
 var CIDRMask = net.CIDRMask
 
+var ParseCIDR = net.ParseCIDR
+
 type (
 	Addr       = net.Addr
 	Conn       = net.Conn
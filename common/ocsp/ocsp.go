@@ -7,11 +7,15 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/xtls/xray-core/common/platform/filesystem"
 	"golang.org/x/crypto/ocsp"
 )
 
+// httpClient fetches OCSP responses and issuer certificates, which never takes long with a working responder.
+var httpClient = &http.Client{Timeout: time.Second * 30}
+
 func GetOCSPForFile(path string) ([]byte, error) {
 	return filesystem.ReadFile(path)
 }
@@ -44,42 +48,51 @@ func GetOCSPStapling(cert [][]byte, path string) ([]byte, error) {
 	return ocspData, nil
 }
 
+// GetOCSPForCert fetches a valid OCSP response for the leaf of the certificate chain.
 func GetOCSPForCert(cert [][]byte) ([]byte, error) {
+	raw, _, err := GetOCSPResponseForCert(cert)
+	return raw, err
+}
+
+// GetOCSPResponseForCert fetches the OCSP response for the leaf of the certificate chain, and returns it both
+// raw and parsed. Only a response signed for the certificate with the good status is returned, as stapling
+// anything else makes clients reject the certificate.
+func GetOCSPResponseForCert(cert [][]byte) ([]byte, *ocsp.Response, error) {
 	bundle := new(bytes.Buffer)
 	for _, derBytes := range cert {
 		err := pem.Encode(bundle, &pem.Block{Type: "CERTIFICATE", Bytes: derBytes})
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 	pemBundle := bundle.Bytes()
 
 	certificates, err := parsePEMBundle(pemBundle)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	issuedCert := certificates[0]
 	if len(issuedCert.OCSPServer) == 0 {
-		return nil, newError("no OCSP server specified in cert")
+		return nil, nil, newError("no OCSP server specified in cert")
 	}
 	if len(certificates) == 1 {
 		if len(issuedCert.IssuingCertificateURL) == 0 {
-			return nil, newError("no issuing certificate URL")
+			return nil, nil, newError("no issuing certificate URL")
 		}
-		resp, errC := http.Get(issuedCert.IssuingCertificateURL[0])
+		resp, errC := httpClient.Get(issuedCert.IssuingCertificateURL[0])
 		if errC != nil {
-			return nil, newError("no issuing certificate URL")
+			return nil, nil, newError("no issuing certificate URL")
 		}
 		defer resp.Body.Close()
 
 		issuerBytes, errC := io.ReadAll(resp.Body)
 		if errC != nil {
-			return nil, newError(errC)
+			return nil, nil, newError(errC)
 		}
 
 		issuerCert, errC := x509.ParseCertificate(issuerBytes)
 		if errC != nil {
-			return nil, newError(errC)
+			return nil, nil, newError(errC)
 		}
 
 		certificates = append(certificates, issuerCert)
@@ -88,19 +101,32 @@ func GetOCSPForCert(cert [][]byte) ([]byte, error) {
 
 	ocspReq, err := ocsp.CreateRequest(issuedCert, issuerCert, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	reader := bytes.NewReader(ocspReq)
-	req, err := http.Post(issuedCert.OCSPServer[0], "application/ocsp-request", reader)
+	req, err := httpClient.Post(issuedCert.OCSPServer[0], "application/ocsp-request", reader)
 	if err != nil {
-		return nil, newError(err)
+		return nil, nil, newError(err)
 	}
 	defer req.Body.Close()
+	if req.StatusCode != http.StatusOK {
+		return nil, nil, newError("unexpected status of OCSP server: ", req.Status)
+	}
 	ocspResBytes, err := io.ReadAll(req.Body)
 	if err != nil {
-		return nil, newError(err)
+		return nil, nil, newError(err)
+	}
+	ocspRes, err := ocsp.ParseResponseForCert(ocspResBytes, issuedCert, issuerCert)
+	if err != nil {
+		return nil, nil, newError("invalid OCSP response").Base(err)
+	}
+	if ocspRes.Status != ocsp.Good {
+		return nil, nil, newError("certificate is not good in OCSP response, status ", ocspRes.Status)
+	}
+	if !ocspRes.NextUpdate.IsZero() && ocspRes.NextUpdate.Before(time.Now()) {
+		return nil, nil, newError("OCSP response expired on ", ocspRes.NextUpdate)
 	}
-	return ocspResBytes, nil
+	return ocspResBytes, ocspRes, nil
 }
 
 // parsePEMBundle parses a certificate bundle from top to bottom and returns
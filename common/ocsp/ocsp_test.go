@@ -0,0 +1,58 @@
+package ocsp_test
+
+import (
+	"crypto"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	. "github.com/xtls/xray-core/common/ocsp"
+	"github.com/xtls/xray-core/common/protocol/tls/cert"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestGetOCSPResponseForCert(t *testing.T) {
+	status := ocsp.Good
+	var caCert *cert.Certificate
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, err := io.ReadAll(r.Body)
+		common.Must(err)
+		req, err := ocsp.ParseRequest(raw)
+		common.Must(err)
+		issuer, err := x509.ParseCertificate(caCert.Certificate)
+		common.Must(err)
+		key, err := x509.ParsePKCS8PrivateKey(caCert.PrivateKey)
+		common.Must(err)
+		res, err := ocsp.CreateResponse(issuer, issuer, ocsp.Response{
+			Status:       status,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Hour),
+			NextUpdate:   time.Now().Add(time.Hour),
+			RevokedAt:    time.Now().Add(-time.Hour),
+		}, key.(crypto.Signer))
+		common.Must(err)
+		w.Write(res)
+	}))
+	defer server.Close()
+
+	caCert = cert.MustGenerate(nil, cert.Authority(true), cert.KeyUsage(x509.KeyUsageCertSign))
+	leafCert := cert.MustGenerate(caCert, cert.CommonName("www.example.com"), func(c *x509.Certificate) {
+		c.OCSPServer = []string{server.URL}
+	})
+	chain := [][]byte{leafCert.Certificate, caCert.Certificate}
+
+	raw, res, err := GetOCSPResponseForCert(chain)
+	common.Must(err)
+	if len(raw) == 0 || res.Status != ocsp.Good {
+		t.Fatal("unexpected OCSP response: ", res.Status)
+	}
+
+	status = ocsp.Revoked
+	if _, _, err := GetOCSPResponseForCert(chain); err == nil {
+		t.Fatal("expected error for revoked certificate")
+	}
+}
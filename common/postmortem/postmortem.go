@@ -0,0 +1,149 @@
+// Package postmortem writes a bundle on fatal errors that helps to debug crashes in the field: the stack
+// traces of all goroutines, the recent log, a fingerprint of the config and runtime stats.
+package postmortem
+
+//go:generate go run github.com/xtls/xray-core/common/errors/errorgen
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/log"
+	"github.com/xtls/xray-core/core"
+	"google.golang.org/protobuf/proto"
+)
+
+// A bundle has the historySize recent log messages of historyLevel or more severe.
+const (
+	historySize  = 1000
+	historyLevel = log.Severity_Info
+)
+
+var (
+	access      sync.Mutex
+	dir         string
+	started     time.Time
+	fingerprint string
+)
+
+// Enable makes Write put bundles into the directory, and starts keeping the recent log for them.
+func Enable(bundleDir string) {
+	access.Lock()
+	defer access.Unlock()
+
+	dir = bundleDir
+	started = time.Now()
+	log.KeepHistory(historySize, historyLevel)
+}
+
+// SetConfig records a fingerprint of the running config, which tells configs apart without revealing them.
+func SetConfig(config proto.Message) {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(config)
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(b)
+
+	access.Lock()
+	defer access.Unlock()
+
+	fingerprint = hex.EncodeToString(sum[:])
+}
+
+// Write writes a bundle for the fatal error and returns its path, or an empty path if bundles are not
+// enabled.
+func Write(reason interface{}) (string, error) {
+	access.Lock()
+	defer access.Unlock()
+
+	if dir == "" {
+		return "", nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", newError("failed to create directory ", dir).Base(err)
+	}
+	now := time.Now()
+	path := filepath.Join(dir, "xray-postmortem-"+now.Format("20060102-150405.000")+".tar.gz")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", newError("failed to create bundle").Base(err)
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	for _, file := range []struct {
+		name    string
+		content []byte
+	}{
+		{"summary.txt", summary(reason, now)},
+		{"stacks.txt", stacks()},
+		{"log.txt", history()},
+	} {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    file.name,
+			Mode:    0o600,
+			Size:    int64(len(file.content)),
+			ModTime: now,
+		}); err != nil {
+			return "", newError("failed to write bundle").Base(err)
+		}
+		if _, err := tw.Write(file.content); err != nil {
+			return "", newError("failed to write bundle").Base(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", newError("failed to write bundle").Base(err)
+	}
+	if err := gw.Close(); err != nil {
+		return "", newError("failed to write bundle").Base(err)
+	}
+	return path, nil
+}
+
+func summary(reason interface{}, now time.Time) []byte {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	b := new(bytes.Buffer)
+	fmt.Fprintf(b, "reason: %v\n", reason)
+	fmt.Fprintf(b, "time: %s\n", now.Format(time.RFC3339))
+	fmt.Fprintf(b, "uptime: %s\n", now.Sub(started).Round(time.Second))
+	fmt.Fprintf(b, "version: %s (%s %s/%s)\n", core.Version(), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(b, "config: %s\n", fingerprint)
+	fmt.Fprintf(b, "cpus: %d\n", runtime.NumCPU())
+	fmt.Fprintf(b, "goroutines: %d\n", runtime.NumGoroutine())
+	fmt.Fprintf(b, "heap: %d bytes in use, %d objects\n", m.HeapInuse, m.HeapObjects)
+	fmt.Fprintf(b, "sys: %d bytes\n", m.Sys)
+	fmt.Fprintf(b, "gc: %d cycles, %s paused\n", m.NumGC, time.Duration(m.PauseTotalNs))
+	return b.Bytes()
+}
+
+func history() []byte {
+	b := new(bytes.Buffer)
+	for _, entry := range log.History() {
+		fmt.Fprintf(b, "%s %s\n", entry.Time.Format("2006/01/02 15:04:05.000000"), entry.Message)
+	}
+	return b.Bytes()
+}
+
+// stacks returns the stack traces of all goroutines.
+func stacks() []byte {
+	b := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(b, true)
+		if n < len(b) {
+			return b[:n]
+		}
+		b = make([]byte, 2*len(b))
+	}
+}
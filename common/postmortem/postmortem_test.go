@@ -0,0 +1,56 @@
+package postmortem_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/log"
+	. "github.com/xtls/xray-core/common/postmortem"
+	"github.com/xtls/xray-core/core"
+)
+
+func TestWrite(t *testing.T) {
+	if path, err := Write("not enabled"); path != "" || err != nil {
+		t.Fatal("expected no bundle before enabling, but got ", path, err)
+	}
+
+	Enable(t.TempDir())
+	SetConfig(&core.Config{})
+	log.Record(&log.GeneralMessage{Severity: log.Severity_Warning, Content: "before the crash"})
+
+	path, err := Write("test crash")
+	common.Must(err)
+
+	f, err := os.Open(path)
+	common.Must(err)
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	common.Must(err)
+	tr := tar.NewReader(gr)
+
+	files := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		common.Must(err)
+		content, err := io.ReadAll(tr)
+		common.Must(err)
+		files[header.Name] = string(content)
+	}
+	if !strings.Contains(files["summary.txt"], "reason: test crash\n") || !strings.Contains(files["summary.txt"], "config: e3b0c442") {
+		t.Error("unexpected summary: ", files["summary.txt"])
+	}
+	if !strings.Contains(files["stacks.txt"], "TestWrite") {
+		t.Error("expected stack of the test in stacks")
+	}
+	if !strings.Contains(files["log.txt"], "[Warning] before the crash") {
+		t.Error("unexpected log: ", files["log.txt"])
+	}
+}
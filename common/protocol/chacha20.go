@@ -0,0 +1,8 @@
+//go:build prefer_chacha20
+// +build prefer_chacha20
+
+package protocol
+
+func init() {
+	preferChaCha20Default = "true"
+}
@@ -2,11 +2,14 @@ package protocol
 
 import (
 	"runtime"
+	"sync"
+	"sync/atomic"
 
 	"golang.org/x/sys/cpu"
 
 	"github.com/xtls/xray-core/common/bitmask"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/platform"
 	"github.com/xtls/xray-core/common/uuid"
 )
 
@@ -93,9 +96,62 @@ var (
 		runtime.GOARCH == "s390x" && hasGCMAsmS390X
 )
 
+// default of the xray.crypto.chacha20 env flag, changed by the prefer_chacha20 build tag
+var preferChaCha20Default = "auto"
+
+var (
+	preferChaCha20Once sync.Once
+	preferChaCha20     bool
+	// set by the config: 0 for none, 1 to prefer ChaCha20-Poly1305, 2 to prefer AES-GCM
+	preferChaCha20Config int32
+)
+
+// HasAESGCMHardwareSupport tells whether the CPU has instructions for AES-GCM.
+func HasAESGCMHardwareSupport() bool {
+	return hasAESGCMHardwareSupport
+}
+
+// PreferChaCha20 tells whether ChaCha20-Poly1305 should be chosen over AES-GCM where there is a choice.
+// By default it is on CPUs without AES-GCM instructions, such as most MIPS and older ARM routers, where
+// AES in software is several times slower. The env flag xray.crypto.chacha20 can set it to true or false,
+// and the config, through SetPreferChaCha20, takes precedence over both.
+func PreferChaCha20() bool {
+	switch atomic.LoadInt32(&preferChaCha20Config) {
+	case 1:
+		return true
+	case 2:
+		return false
+	}
+	preferChaCha20Once.Do(func() {
+		switch platform.NewEnvFlag("xray.crypto.chacha20").GetValue(func() string { return preferChaCha20Default }) {
+		case "true":
+			preferChaCha20 = true
+		case "false":
+			preferChaCha20 = false
+		default:
+			preferChaCha20 = !hasAESGCMHardwareSupport
+		}
+	})
+	return preferChaCha20
+}
+
+// SetPreferChaCha20 makes PreferChaCha20 return prefer, whatever the CPU and the env flag.
+func SetPreferChaCha20(prefer bool) {
+	if prefer {
+		atomic.StoreInt32(&preferChaCha20Config, 1)
+	} else {
+		atomic.StoreInt32(&preferChaCha20Config, 2)
+	}
+}
+
+// ResetPreferChaCha20 undoes SetPreferChaCha20, so that the CPU and the env flag decide again.
+func ResetPreferChaCha20() {
+	atomic.StoreInt32(&preferChaCha20Config, 0)
+}
+
 func (sc *SecurityConfig) GetSecurityType() SecurityType {
 	if sc == nil || sc.Type == SecurityType_AUTO {
-		if hasAESGCMHardwareSupport {
+		if !PreferChaCha20() {
 			return SecurityType_AES128_GCM
 		}
 		return SecurityType_CHACHA20_POLY1305
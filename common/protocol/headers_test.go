@@ -0,0 +1,28 @@
+package protocol_test
+
+import (
+	"testing"
+
+	. "github.com/xtls/xray-core/common/protocol"
+)
+
+func TestPreferChaCha20(t *testing.T) {
+	defer ResetPreferChaCha20()
+
+	SetPreferChaCha20(true)
+	if s := (*SecurityConfig)(nil).GetSecurityType(); s != SecurityType_CHACHA20_POLY1305 {
+		t.Error("expected auto security to pick ChaCha20-Poly1305, got ", s)
+	}
+	SetPreferChaCha20(false)
+	if s := (&SecurityConfig{Type: SecurityType_AUTO}).GetSecurityType(); s != SecurityType_AES128_GCM {
+		t.Error("expected auto security to pick AES-128-GCM, got ", s)
+	}
+	if s := (&SecurityConfig{Type: SecurityType_CHACHA20_POLY1305}).GetSecurityType(); s != SecurityType_CHACHA20_POLY1305 {
+		t.Error("expected explicit security to be kept, got ", s)
+	}
+
+	ResetPreferChaCha20()
+	if PreferChaCha20() != !HasAESGCMHardwareSupport() {
+		t.Error("expected ChaCha20-Poly1305 to be preferred only without AES-GCM instructions")
+	}
+}
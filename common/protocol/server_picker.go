@@ -46,6 +46,22 @@ func (sl *ServerList) GetServer(idx uint32) *ServerSpec {
 	}
 }
 
+// Update replaces the servers for which update returns a new one, while the list is in use, and
+// returns the number of servers replaced. Servers for which update returns nil are kept.
+func (sl *ServerList) Update(update func(*ServerSpec) *ServerSpec) int {
+	sl.Lock()
+	defer sl.Unlock()
+
+	n := 0
+	for idx, server := range sl.servers {
+		if s := update(server); s != nil {
+			sl.servers[idx] = s
+			n++
+		}
+	}
+	return n
+}
+
 func (sl *ServerList) removeServer(idx uint32) {
 	n := len(sl.servers)
 	sl.servers[idx] = sl.servers[n-1]
@@ -69,3 +69,42 @@ func TestServerPicker(t *testing.T) {
 		t.Error("server: ", server.Destination())
 	}
 }
+
+func TestServerListUpdate(t *testing.T) {
+	list := NewServerList()
+	list.AddServer(NewServerSpec(net.TCPDestination(net.LocalHostIP, net.Port(1)), AlwaysValid()))
+	picker := NewRoundRobinServerPicker(list)
+
+	list.Update(func(server *ServerSpec) *ServerSpec {
+		dest := server.Destination()
+		dest.Port = 2
+		return NewServerSpec(dest, AlwaysValid(), server.Users()...)
+	})
+	if server := picker.PickServer(); server.Destination().Port != 2 {
+		t.Error("server: ", server.Destination())
+	}
+}
+
+func TestServerListUpdateOne(t *testing.T) {
+	list := NewServerList()
+	list.AddServer(NewServerSpec(net.TCPDestination(net.LocalHostIP, net.Port(1)), AlwaysValid()))
+	list.AddServer(NewServerSpec(net.TCPDestination(net.LocalHostIP, net.Port(2)), AlwaysValid()))
+
+	n := list.Update(func(server *ServerSpec) *ServerSpec {
+		dest := server.Destination()
+		if dest.Port != 2 {
+			return nil
+		}
+		dest.Port = 3
+		return NewServerSpec(dest, AlwaysValid(), server.Users()...)
+	})
+	if n != 1 {
+		t.Error("updated: ", n)
+	}
+	if port := list.GetServer(0).Destination().Port; port != 1 {
+		t.Error("port of server 0: ", port)
+	}
+	if port := list.GetServer(1).Destination().Port; port != 3 {
+		t.Error("port of server 1: ", port)
+	}
+}
@@ -75,6 +75,14 @@ func (s *ServerSpec) Destination() net.Destination {
 	return s.dest
 }
 
+// Users returns the users of the server.
+func (s *ServerSpec) Users() []*MemoryUser {
+	s.RLock()
+	defer s.RUnlock()
+
+	return append([]*MemoryUser(nil), s.users...)
+}
+
 func (s *ServerSpec) HasUser(user *MemoryUser) bool {
 	s.RLock()
 	defer s.RUnlock()
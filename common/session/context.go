@@ -17,6 +17,8 @@ const (
 	sockoptSessionKey
 	trackedConnectionErrorKey
 	dispatcherKey
+	sockoptOverrideSessionKey
+	connectedSessionKey
 )
 
 // ContextWithID returns a new context with the given ID.
@@ -131,3 +133,29 @@ func DispatcherFromContext(ctx context.Context) routing.Dispatcher {
 	}
 	return nil
 }
+
+// ContextWithSockoptOverride returns a new context with the socket options the route of the connection sets.
+func ContextWithSockoptOverride(ctx context.Context, o *routing.SockoptOverride) context.Context {
+	return context.WithValue(ctx, sockoptOverrideSessionKey, o)
+}
+
+// SockoptOverrideFromContext returns the socket options the route of the connection sets, or nil if it sets none.
+func SockoptOverrideFromContext(ctx context.Context) *routing.SockoptOverride {
+	if o, ok := ctx.Value(sockoptOverrideSessionKey).(*routing.SockoptOverride); ok {
+		return o
+	}
+	return nil
+}
+
+// ContextWithConnected returns a new context with a function the outbound calls once it is connected to the server
+// or the destination, before sending any data.
+func ContextWithConnected(ctx context.Context, connected func()) context.Context {
+	return context.WithValue(ctx, connectedSessionKey, connected)
+}
+
+// NotifyConnected calls the function set by ContextWithConnected, if any.
+func NotifyConnected(ctx context.Context) {
+	if connected, ok := ctx.Value(connectedSessionKey).(func()); ok {
+		connected()
+	}
+}
@@ -68,6 +68,34 @@ type SniffingRequest struct {
 	RouteOnly                      bool
 }
 
+// Restriction is the set of destinations an inbound refuses before routing.
+type Restriction struct {
+	BlockedPorts     net.MemoryPortList
+	BlockedProtocols []string
+	// BlockPrivate refuses loopback, link-local, private and shared addresses, apart from those in AllowedPrivate.
+	BlockPrivate   bool
+	AllowedPrivate []*net.IPNet
+}
+
+// sharedAddressSpace is 100.64.0.0/10 of RFC 6598, used by carrier-grade NATs and cloud internal networks.
+var sharedAddressSpace = &net.IPNet{IP: net.IP{100, 64, 0, 0}, Mask: net.CIDRMask(10, 32)}
+
+// BlocksIP returns whether the restriction refuses the IP address.
+func (r *Restriction) BlocksIP(ip net.IP) bool {
+	if !r.BlockPrivate {
+		return false
+	}
+	if !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsPrivate() && !ip.IsUnspecified() && !sharedAddressSpace.Contains(ip) {
+		return false
+	}
+	for _, allowed := range r.AllowedPrivate {
+		if allowed.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
 // Content is the metadata of the connection content.
 type Content struct {
 	// Protocol of current content.
@@ -75,6 +103,9 @@ type Content struct {
 
 	SniffingRequest SniffingRequest
 
+	// Restriction set by the inbound. May be nil.
+	Restriction *Restriction
+
 	Attributes map[string]string
 
 	SkipDNSResolve bool
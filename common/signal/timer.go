@@ -3,6 +3,7 @@ package signal
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/xtls/xray-core/common"
@@ -14,19 +15,40 @@ type ActivityUpdater interface {
 }
 
 type ActivityTimer struct {
+	// unix nano, accessed atomically. Keep them first for 64-bit alignment.
+	lastUpdate   int64
+	lastUplink   int64
+	lastDownlink int64
 	sync.RWMutex
 	updated   chan struct{}
 	checkTask *task.Periodic
+	limitTask *task.Periodic
 	onTimeout func()
 }
 
 func (t *ActivityTimer) Update() {
+	atomic.StoreInt64(&t.lastUpdate, time.Now().UnixNano())
 	select {
 	case t.updated <- struct{}{}:
 	default:
 	}
 }
 
+// LastActivity returns the time of the most recent activity.
+func (t *ActivityTimer) LastActivity() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&t.lastUpdate))
+}
+
+// UpdateUplink records activity in the uplink direction.
+func (t *ActivityTimer) UpdateUplink() {
+	atomic.StoreInt64(&t.lastUplink, time.Now().UnixNano())
+}
+
+// UpdateDownlink records activity in the downlink direction.
+func (t *ActivityTimer) UpdateDownlink() {
+	atomic.StoreInt64(&t.lastDownlink, time.Now().UnixNano())
+}
+
 func (t *ActivityTimer) check() error {
 	select {
 	case <-t.updated:
@@ -48,6 +70,10 @@ func (t *ActivityTimer) finish() {
 		t.checkTask.Close()
 		t.checkTask = nil
 	}
+	if t.limitTask != nil {
+		t.limitTask.Close()
+		t.limitTask = nil
+	}
 }
 
 func (t *ActivityTimer) SetTimeout(timeout time.Duration) {
@@ -72,6 +98,55 @@ func (t *ActivityTimer) SetTimeout(timeout time.Duration) {
 	common.Must(checkTask.Start())
 }
 
+// SetLimits sets idle timeouts for the uplink and downlink directions, and a maximum
+// lifetime counted from now. The timer fires when any of them is exceeded. Zero disables a limit.
+// Directional activity must be reported through UpdateUplink and UpdateDownlink.
+// Only the first call takes effect, so that sessions multiplexed over one connection share its limits.
+func (t *ActivityTimer) SetLimits(uplinkIdle, downlinkIdle, lifetime time.Duration) {
+	var interval time.Duration
+	for _, d := range []time.Duration{uplinkIdle, downlinkIdle, lifetime} {
+		if d > 0 && (interval == 0 || d < interval) {
+			interval = d
+		}
+	}
+	if interval == 0 {
+		return
+	}
+
+	now := time.Now()
+	var deadline time.Time
+	if lifetime > 0 {
+		deadline = now.Add(lifetime)
+	}
+
+	limitTask := &task.Periodic{
+		// check several times per period, so a limit is not overshot by a whole period
+		Interval: interval / 4,
+		Execute: func() error {
+			now := time.Now()
+			switch {
+			case uplinkIdle > 0 && now.Sub(time.Unix(0, atomic.LoadInt64(&t.lastUplink))) > uplinkIdle,
+				downlinkIdle > 0 && now.Sub(time.Unix(0, atomic.LoadInt64(&t.lastDownlink))) > downlinkIdle,
+				!deadline.IsZero() && now.After(deadline):
+				t.finish()
+			}
+			return nil
+		},
+	}
+
+	t.Lock()
+	if t.onTimeout == nil || t.limitTask != nil {
+		// already timed out or limited
+		t.Unlock()
+		return
+	}
+	atomic.StoreInt64(&t.lastUplink, now.UnixNano())
+	atomic.StoreInt64(&t.lastDownlink, now.UnixNano())
+	t.limitTask = limitTask
+	t.Unlock()
+	common.Must(limitTask.Start())
+}
+
 func CancelAfterInactivity(ctx context.Context, cancel context.CancelFunc, timeout time.Duration) *ActivityTimer {
 	timer := &ActivityTimer{
 		updated:   make(chan struct{}, 1),
@@ -58,3 +58,38 @@ func TestActivityTimerZeroTimeout(t *testing.T) {
 	}
 	runtime.KeepAlive(timer)
 }
+
+func TestActivityTimerDirectionalIdle(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	timer := CancelAfterInactivity(ctx, cancel, time.Second*10)
+	timer.SetLimits(time.Millisecond*400, 0, 0)
+	for i := 0; i < 5; i++ {
+		time.Sleep(time.Millisecond * 200)
+		timer.Update()
+		timer.UpdateUplink()
+	}
+	if ctx.Err() != nil {
+		t.Error("expected nil, but got ", ctx.Err().Error())
+	}
+	for i := 0; i < 5; i++ {
+		time.Sleep(time.Millisecond * 200)
+		timer.Update()
+		timer.UpdateDownlink()
+	}
+	if ctx.Err() == nil {
+		t.Error("expected some error, but got nil")
+	}
+}
+
+func TestActivityTimerLifetime(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	timer := CancelAfterInactivity(ctx, cancel, time.Second*10)
+	timer.SetLimits(0, 0, time.Second)
+	for i := 0; i < 8; i++ {
+		time.Sleep(time.Millisecond * 200)
+		timer.Update()
+	}
+	if ctx.Err() == nil {
+		t.Error("expected some error, but got nil")
+	}
+}
@@ -2,6 +2,8 @@ package task
 
 import (
 	"context"
+	"fmt"
+	"runtime/debug"
 
 	"github.com/xtls/xray-core/common/signal/semaphore"
 )
@@ -16,6 +18,26 @@ func OnSuccess(f func() error, g func() error) func() error {
 	}
 }
 
+// PanicError is the error of a task that panicked.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprint("task panicked: ", e.Value)
+}
+
+// call executes f, and turns its panic into a PanicError, so that it ends only the tasks it is run with.
+func call(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return f()
+}
+
 // Run executes a list of tasks in parallel, returns the first error encountered or nil if all tasks pass.
 func Run(ctx context.Context, tasks ...func() error) error {
 	n := len(tasks)
@@ -25,7 +47,7 @@ func Run(ctx context.Context, tasks ...func() error) error {
 	for _, task := range tasks {
 		<-s.Wait()
 		go func(f func() error) {
-			err := f()
+			err := call(f)
 			if err == nil {
 				s.Signal()
 				return
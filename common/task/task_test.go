@@ -46,6 +46,20 @@ func TestExecuteParallelContextCancel(t *testing.T) {
 	}
 }
 
+func TestExecuteParallelPanic(t *testing.T) {
+	err := Run(context.Background(), func() error {
+		time.Sleep(time.Second)
+		return nil
+	}, func() error {
+		panic("test")
+	})
+
+	var p *PanicError
+	if !errors.As(err, &p) || p.Value != "test" {
+		t.Error("expected the panic of the task, but got ", err)
+	}
+}
+
 func BenchmarkExecuteOne(b *testing.B) {
 	noop := func() error {
 		return nil
@@ -0,0 +1,141 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.28.1
+// 	protoc        v3.21.12
+// source: common/xudp/config.proto
+
+package xudp
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// PacketEncoding is how an outbound carries UDP packets.
+type PacketEncoding int32
+
+const (
+	// Left to the outbound.
+	PacketEncoding_Default PacketEncoding = 0
+	// Native UDP of the protocol.
+	PacketEncoding_None PacketEncoding = 1
+	// Mux.Cool with global IDs, which keeps the full-cone NAT of a client
+	// socket across connections.
+	PacketEncoding_XUDP PacketEncoding = 2
+)
+
+// Enum value maps for PacketEncoding.
+var (
+	PacketEncoding_name = map[int32]string{
+		0: "Default",
+		1: "None",
+		2: "XUDP",
+	}
+	PacketEncoding_value = map[string]int32{
+		"Default": 0,
+		"None":    1,
+		"XUDP":    2,
+	}
+)
+
+func (x PacketEncoding) Enum() *PacketEncoding {
+	p := new(PacketEncoding)
+	*p = x
+	return p
+}
+
+func (x PacketEncoding) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PacketEncoding) Descriptor() protoreflect.EnumDescriptor {
+	return file_common_xudp_config_proto_enumTypes[0].Descriptor()
+}
+
+func (PacketEncoding) Type() protoreflect.EnumType {
+	return &file_common_xudp_config_proto_enumTypes[0]
+}
+
+func (x PacketEncoding) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PacketEncoding.Descriptor instead.
+func (PacketEncoding) EnumDescriptor() ([]byte, []int) {
+	return file_common_xudp_config_proto_rawDescGZIP(), []int{0}
+}
+
+var File_common_xudp_config_proto protoreflect.FileDescriptor
+
+var file_common_xudp_config_proto_rawDesc = []byte{
+	0x0a, 0x18, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2f, 0x78, 0x75, 0x64, 0x70, 0x2f, 0x63, 0x6f,
+	0x6e, 0x66, 0x69, 0x67, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x10, 0x78, 0x72, 0x61, 0x79,
+	0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x78, 0x75, 0x64, 0x70, 0x2a, 0x31, 0x0a, 0x0e,
+	0x50, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x45, 0x6e, 0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67, 0x12, 0x0b,
+	0x0a, 0x07, 0x44, 0x65, 0x66, 0x61, 0x75, 0x6c, 0x74, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x4e,
+	0x6f, 0x6e, 0x65, 0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x58, 0x55, 0x44, 0x50, 0x10, 0x02, 0x42,
+	0x52, 0x0a, 0x14, 0x63, 0x6f, 0x6d, 0x2e, 0x78, 0x72, 0x61, 0x79, 0x2e, 0x63, 0x6f, 0x6d, 0x6d,
+	0x6f, 0x6e, 0x2e, 0x78, 0x75, 0x64, 0x70, 0x50, 0x01, 0x5a, 0x25, 0x67, 0x69, 0x74, 0x68, 0x75,
+	0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x78, 0x74, 0x6c, 0x73, 0x2f, 0x78, 0x72, 0x61, 0x79, 0x2d,
+	0x63, 0x6f, 0x72, 0x65, 0x2f, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2f, 0x78, 0x75, 0x64, 0x70,
+	0xaa, 0x02, 0x10, 0x58, 0x72, 0x61, 0x79, 0x2e, 0x43, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x58,
+	0x75, 0x64, 0x70, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_common_xudp_config_proto_rawDescOnce sync.Once
+	file_common_xudp_config_proto_rawDescData = file_common_xudp_config_proto_rawDesc
+)
+
+func file_common_xudp_config_proto_rawDescGZIP() []byte {
+	file_common_xudp_config_proto_rawDescOnce.Do(func() {
+		file_common_xudp_config_proto_rawDescData = protoimpl.X.CompressGZIP(file_common_xudp_config_proto_rawDescData)
+	})
+	return file_common_xudp_config_proto_rawDescData
+}
+
+var file_common_xudp_config_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_common_xudp_config_proto_goTypes = []interface{}{
+	(PacketEncoding)(0), // 0: xray.common.xudp.PacketEncoding
+}
+var file_common_xudp_config_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_common_xudp_config_proto_init() }
+func file_common_xudp_config_proto_init() {
+	if File_common_xudp_config_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_common_xudp_config_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   0,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_common_xudp_config_proto_goTypes,
+		DependencyIndexes: file_common_xudp_config_proto_depIdxs,
+		EnumInfos:         file_common_xudp_config_proto_enumTypes,
+	}.Build()
+	File_common_xudp_config_proto = out.File
+	file_common_xudp_config_proto_rawDesc = nil
+	file_common_xudp_config_proto_goTypes = nil
+	file_common_xudp_config_proto_depIdxs = nil
+}
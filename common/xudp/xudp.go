@@ -1,11 +1,17 @@
 package xudp
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"io"
 
+	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/session"
 )
 
 var addrParser = protocol.NewAddressParser(
@@ -15,16 +21,40 @@ var addrParser = protocol.NewAddressParser(
 	protocol.PortThenAddress(),
 )
 
-func NewPacketWriter(writer buf.Writer, dest net.Destination) *PacketWriter {
+// baseKey keeps global IDs from revealing the addresses they are derived from.
+var baseKey = make([]byte, 32)
+
+func init() {
+	common.Must2(rand.Read(baseKey))
+}
+
+// GetGlobalID returns the ID of the client socket of a UDP inbound connection, which is the same
+// for all the connections from that socket, so that the server can keep its full-cone NAT mapping
+// across them. It is zero if the source is not a UDP socket.
+func GetGlobalID(ctx context.Context) (globalID [8]byte) {
+	inbound := session.InboundFromContext(ctx)
+	if inbound == nil || inbound.Source.Network != net.Network_UDP {
+		return
+	}
+	h := hmac.New(sha256.New, baseKey)
+	h.Write([]byte(inbound.Tag))
+	h.Write([]byte(inbound.Source.String()))
+	copy(globalID[:], h.Sum(nil))
+	return
+}
+
+func NewPacketWriter(writer buf.Writer, dest net.Destination, globalID [8]byte) *PacketWriter {
 	return &PacketWriter{
-		Writer: writer,
-		Dest:   dest,
+		Writer:   writer,
+		Dest:     dest,
+		GlobalID: globalID,
 	}
 }
 
 type PacketWriter struct {
-	Writer buf.Writer
-	Dest   net.Destination
+	Writer   buf.Writer
+	Dest     net.Destination
+	GlobalID [8]byte
 }
 
 func (w *PacketWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
@@ -43,6 +73,9 @@ func (w *PacketWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
 			eb.WriteByte(1) // Opt
 			eb.WriteByte(2) // UDP
 			addrParser.WriteAddressPort(eb, w.Dest.Address, w.Dest.Port)
+			if w.GlobalID != [8]byte{} {
+				eb.Write(w.GlobalID[:])
+			}
 			w.Dest.Network = net.Network_Unknown
 		} else {
 			eb.WriteByte(2) // Keep
@@ -24,6 +24,22 @@ type Client interface {
 	LookupIP(domain string, option IPOption) ([]net.IP, error)
 }
 
+// HTTPSRecord is the service binding of a domain, from its HTTPS record (RFC 9460).
+type HTTPSRecord struct {
+	// ALPN lists the protocols the service supports.
+	ALPN []string
+}
+
+// HTTPSClient is a Client that also looks up HTTPS records.
+//
+// xray:api:beta
+type HTTPSClient interface {
+	Client
+
+	// LookupHTTPS returns the service binding of the given domain.
+	LookupHTTPS(domain string) (*HTTPSRecord, error)
+}
+
 type HostsLookup interface {
 	LookupHosts(domain string) *net.Address
 }
@@ -21,6 +21,12 @@ type HandlerSelector interface {
 	Select([]string) []string
 }
 
+// CircuitBreaker is a Handler that fails connections fast for a while after its dials failed repeatedly.
+type CircuitBreaker interface {
+	// IsCircuitOpen returns whether the handler is failing connections fast at the moment.
+	IsCircuitOpen() bool
+}
+
 // Manager is a feature that manages outbound.Handlers.
 //
 // xray:api:stable
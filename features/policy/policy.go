@@ -7,6 +7,7 @@ import (
 
 	"github.com/xtls/xray-core/common/platform"
 	"github.com/xtls/xray-core/features"
+	"github.com/xtls/xray-core/features/routing"
 )
 
 // Timeout contains limits for connection timeout.
@@ -19,6 +20,12 @@ type Timeout struct {
 	UplinkOnly time.Duration
 	// Timeout for an downlink only connection, i.e., the uplink of the connection has been closed.
 	DownlinkOnly time.Duration
+	// Timeout for no uplink traffic in a connection, even if there is downlink traffic. 0 for no limit.
+	UplinkIdle time.Duration
+	// Timeout for no downlink traffic in a connection, even if there is uplink traffic. 0 for no limit.
+	DownlinkIdle time.Duration
+	// Maximum lifetime of a connection. 0 for no limit.
+	MaxLifetime time.Duration
 }
 
 // Stats contains settings for stats counters.
@@ -27,6 +34,8 @@ type Stats struct {
 	UserUplink bool
 	// Whether or not to enable stat counter for user downlink traffic.
 	UserDownlink bool
+	// Whether or not to record the logins of users.
+	UserLogin bool
 }
 
 // Buffer contains settings for internal buffer.
@@ -35,6 +44,18 @@ type Buffer struct {
 	PerConnection int32
 }
 
+// KeepAlive contains settings for TCP keep-alive of the connections in a session.
+type KeepAlive struct {
+	// Whether or not to apply the keep-alive of the inbound connection to the outbound connection.
+	Mirror bool
+}
+
+// DestinationACL restricts the destinations a session may reach.
+type DestinationACL interface {
+	// Allowed returns true if the target of the routing context may be reached.
+	Allowed(ctx routing.Context) bool
+}
+
 // SystemStats contains stat policy settings on system level.
 type SystemStats struct {
 	// Whether or not to enable stat counter for uplink traffic in inbound handlers.
@@ -45,6 +66,8 @@ type SystemStats struct {
 	OutboundUplink bool
 	// Whether or not to enable stat counter for downlink traffic in outbound handlers.
 	OutboundDownlink bool
+	// Whether or not to enable stat counters for traffic in inbound handlers by the sniffed protocol.
+	InboundProtocol bool
 }
 
 // System contains policy settings at system level.
@@ -55,9 +78,12 @@ type System struct {
 
 // Session is session based settings for controlling Xray requests. It contains various settings (or limits) that may differ for different users in the context.
 type Session struct {
-	Timeouts Timeout // Timeout settings
-	Stats    Stats
-	Buffer   Buffer
+	Timeouts  Timeout // Timeout settings
+	Stats     Stats
+	Buffer    Buffer
+	KeepAlive KeepAlive
+	// ACL of destinations. Nil allows all destinations.
+	ACL DestinationACL
 }
 
 // Manager is a feature that provides Policy for the given user by its id or level.
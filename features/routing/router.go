@@ -2,6 +2,7 @@ package routing
 
 import (
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/serial"
 	"github.com/xtls/xray-core/features"
 )
 
@@ -15,6 +16,33 @@ type Router interface {
 	PickRoute(ctx Context) (Route, error)
 }
 
+// RuleManager is a Router whose rules and balancers can be changed at runtime.
+type RuleManager interface {
+	Router
+
+	// AddRule adds the rules and balancers of config, a router config. The rules are tried after the existing
+	// ones if shouldAppend, or before them otherwise. Every rule must have a rule tag.
+	AddRule(config *serial.TypedMessage, shouldAppend bool) error
+
+	// RemoveRule removes the rule of the given rule tag, along with its balancer if that was added by AddRule
+	// and no other rule uses it.
+	RemoveRule(tag string) error
+
+	// ListRule returns the rules, in the order they are tried.
+	ListRule() []RuleInfo
+
+	// OverrideBalancer makes the balancer of the given tag pick target, an existing outbound, or pick by its
+	// strategy again if target is empty.
+	OverrideBalancer(balancer string, target string) error
+}
+
+// RuleInfo describes a routing rule.
+type RuleInfo struct {
+	RuleTag     string
+	OutboundTag string
+	BalancerTag string
+}
+
 // Route is the routing result of Router feature.
 //
 // xray:api:stable
@@ -29,6 +57,30 @@ type Route interface {
 	GetOutboundTag() string
 }
 
+// StandbyRoute is a Route with a standby outbound, which is raced against the chosen outbound.
+type StandbyRoute interface {
+	Route
+
+	// GetStandbyTag returns the tag of the standby outbound, or empty if there is none.
+	GetStandbyTag() string
+}
+
+// SockoptOverride is the socket options a route sets for the dials of its connections. Zero values keep
+// the options of the outbound.
+type SockoptOverride struct {
+	Mark      int32
+	Interface string
+	DSCP      int32
+}
+
+// SockoptRoute is a Route that overrides socket options of the outbound for the connection.
+type SockoptRoute interface {
+	Route
+
+	// GetSockoptOverride returns the socket options, or nil if the route sets none.
+	GetSockoptOverride() *SockoptOverride
+}
+
 // RouterType return the type of Router interface. Can be used to implement common.HasType.
 //
 // xray:api:stable
@@ -4,8 +4,10 @@ package stats
 
 import (
 	"context"
+	"time"
 
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/features"
 )
 
@@ -79,6 +81,25 @@ type Manager interface {
 	GetChannel(string) Channel
 }
 
+// Login is a successful authentication of a user.
+type Login struct {
+	Time       time.Time
+	Source     net.Address
+	InboundTag string
+}
+
+// LoginRecorder is a Manager that records the logins of users.
+type LoginRecorder interface {
+	// RecordLogin records a login of the user of the given email.
+	RecordLogin(email string, login Login)
+	// GetLogins returns the last logins of the user from their recent distinct sources, the latest first.
+	GetLogins(email string) []Login
+	// AddUser makes the user known, so that it is listed without logins until it logs in.
+	AddUser(email string)
+	// RemoveUser forgets the user and its logins.
+	RemoveUser(email string)
+}
+
 // GetOrRegisterCounter tries to get the StatCounter first. If not exist, it then tries to create a new counter.
 func GetOrRegisterCounter(m Manager, name string) (Counter, error) {
 	counter := m.GetCounter(name)
@@ -6,14 +6,44 @@ import (
 	"github.com/xtls/xray-core/app/commander"
 	loggerservice "github.com/xtls/xray-core/app/log/command"
 	observatoryservice "github.com/xtls/xray-core/app/observatory/command"
+	policyservice "github.com/xtls/xray-core/app/policy/command"
 	handlerservice "github.com/xtls/xray-core/app/proxyman/command"
 	statsservice "github.com/xtls/xray-core/app/stats/command"
 	"github.com/xtls/xray-core/common/serial"
 )
 
 type APIConfig struct {
-	Tag      string   `json:"tag"`
-	Services []string `json:"services"`
+	Tag      string            `json:"tag"`
+	Services []string          `json:"services"`
+	AuditLog string            `json:"auditLog"`
+	Tokens   []*APITokenConfig `json:"tokens"`
+}
+
+type APITokenConfig struct {
+	Name   string `json:"name"`
+	Secret string `json:"secret"`
+	Scope  string `json:"scope"`
+}
+
+func (c *APITokenConfig) Build() (*commander.Token, error) {
+	if c.Secret == "" {
+		return nil, newError("API token secret can't be empty.")
+	}
+	token := &commander.Token{
+		Name:   c.Name,
+		Secret: c.Secret,
+	}
+	switch strings.ToLower(c.Scope) {
+	case "", "stats":
+		token.Scope = commander.Token_STATS_READ
+	case "user":
+		token.Scope = commander.Token_USER_MANAGEMENT
+	case "admin":
+		token.Scope = commander.Token_FULL_ADMIN
+	default:
+		return nil, newError("unknown API token scope: ", c.Scope)
+	}
+	return token, nil
 }
 
 func (c *APIConfig) Build() (*commander.Config, error) {
@@ -34,11 +64,24 @@ func (c *APIConfig) Build() (*commander.Config, error) {
 			services = append(services, serial.ToTypedMessage(&statsservice.Config{}))
 		case "observatoryservice":
 			services = append(services, serial.ToTypedMessage(&observatoryservice.Config{}))
+		case "policyservice":
+			services = append(services, serial.ToTypedMessage(&policyservice.Config{}))
+		}
+	}
+
+	tokens := make([]*commander.Token, 0, len(c.Tokens))
+	for _, t := range c.Tokens {
+		token, err := t.Build()
+		if err != nil {
+			return nil, err
 		}
+		tokens = append(tokens, token)
 	}
 
 	return &commander.Config{
-		Tag:     c.Tag,
-		Service: services,
+		Tag:      c.Tag,
+		Service:  services,
+		AuditLog: c.AuditLog,
+		Token:    tokens,
 	}, nil
 }
@@ -0,0 +1,31 @@
+package conf
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/xtls/xray-core/app/auth"
+)
+
+type AuthConfig struct {
+	URL              string `json:"url"`
+	GRPCAddress      string `json:"grpcAddress"`
+	Timeout          uint32 `json:"timeout"`
+	CacheTTL         uint32 `json:"cacheTtl"`
+	NegativeCacheTTL uint32 `json:"negativeCacheTtl"`
+	FailOpen         bool   `json:"failOpen"`
+	RateLimit        uint32 `json:"rateLimit"`
+}
+
+func (c *AuthConfig) Build() (proto.Message, error) {
+	if (c.URL == "") == (c.GRPCAddress == "") {
+		return nil, newError("auth requires either url or grpcAddress")
+	}
+	return &auth.Config{
+		Url:              c.URL,
+		GrpcAddress:      c.GRPCAddress,
+		Timeout:          c.Timeout,
+		CacheTtl:         c.CacheTTL,
+		NegativeCacheTtl: c.NegativeCacheTTL,
+		FailOpen:         c.FailOpen,
+		RateLimit:        c.RateLimit,
+	}, nil
+}
@@ -27,6 +27,10 @@ func (d *Duration) UnmarshalJSON(b []byte) error {
 		}
 		*d = Duration(dr)
 		return nil
+	case float64:
+		// seconds
+		*d = Duration(value * float64(time.Second))
+		return nil
 	default:
 		return fmt.Errorf("invalid duration: %v", v)
 	}
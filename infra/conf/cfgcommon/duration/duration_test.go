@@ -31,3 +31,13 @@ func TestDurationJSON(t *testing.T) {
 		t.Errorf("expected: %s, actual: %s", time.Duration(expected.Duration), time.Duration(actual.Duration))
 	}
 }
+
+func TestDurationJSONSeconds(t *testing.T) {
+	actual := &testWithDuration{}
+	if err := json.Unmarshal([]byte(`{"Duration": 90}`), actual); err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(actual.Duration) != 90*time.Second {
+		t.Error("unexpected duration: ", time.Duration(actual.Duration))
+	}
+}
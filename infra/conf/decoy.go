@@ -0,0 +1,50 @@
+package conf
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/proxy/decoy"
+)
+
+// DecoyConfig is the service that connections with an invalid handshake are passed to.
+type DecoyConfig struct {
+	Type string          `json:"type"`
+	Dest json.RawMessage `json:"dest"`
+	Xver uint64          `json:"xver"`
+}
+
+// Build implements Buildable
+func (c *DecoyConfig) Build() (*decoy.Config, error) {
+	config := &decoy.Config{
+		Type: c.Type,
+		Xver: c.Xver,
+	}
+	var port uint16
+	if err := json.Unmarshal(c.Dest, &port); err == nil {
+		config.Dest = "127.0.0.1:" + strconv.Itoa(int(port))
+	} else {
+		_ = json.Unmarshal(c.Dest, &config.Dest)
+	}
+	if config.Dest == "" {
+		return nil, newError(`decoy: "dest" is not set`)
+	}
+	if config.Type == "" {
+		switch config.Dest[0] {
+		case '@', '/':
+			config.Type = "unix"
+		default:
+			if _, _, err := net.SplitHostPort(config.Dest); err == nil {
+				config.Type = "tcp"
+			}
+		}
+	}
+	if config.Type == "" {
+		return nil, newError(`decoy: invalid "dest": `, config.Dest)
+	}
+	if config.Xver > 2 {
+		return nil, newError(`decoy: invalid PROXY protocol version, "xver" only accepts 0, 1, 2`)
+	}
+	return config, nil
+}
@@ -0,0 +1,41 @@
+package conf_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/xtls/xray-core/common"
+	. "github.com/xtls/xray-core/infra/conf"
+	"github.com/xtls/xray-core/proxy/decoy"
+)
+
+func TestDecoyConfig(t *testing.T) {
+	for _, test := range []struct {
+		input  string
+		output *decoy.Config
+	}{
+		{`{"dest": 80}`, &decoy.Config{Type: "tcp", Dest: "127.0.0.1:80"}},
+		{`{"dest": "example.com:443", "xver": 2}`, &decoy.Config{Type: "tcp", Dest: "example.com:443", Xver: 2}},
+		{`{"dest": "/dev/shm/decoy.sock"}`, &decoy.Config{Type: "unix", Dest: "/dev/shm/decoy.sock"}},
+		{`{"dest": "@decoy"}`, &decoy.Config{Type: "unix", Dest: "@decoy"}},
+		{`{}`, nil},
+		{`{"dest": "nowhere"}`, nil},
+		{`{"dest": 80, "xver": 3}`, nil},
+	} {
+		c := new(DecoyConfig)
+		common.Must(json.Unmarshal([]byte(test.input), c))
+		config, err := c.Build()
+		if test.output == nil {
+			if err == nil {
+				t.Error("expected error for ", test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error("failed to build ", test.input, ": ", err)
+		} else if !proto.Equal(config, test.output) {
+			t.Error("unexpected config for ", test.input, ": ", config)
+		}
+	}
+}
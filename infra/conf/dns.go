@@ -11,12 +11,15 @@ import (
 )
 
 type NameServerConfig struct {
-	Address      *Address
-	ClientIP     *Address
-	Port         uint16
-	SkipFallback bool
-	Domains      []string
-	ExpectIPs    StringList
+	Address         *Address          `json:"address"`
+	ClientIP        *Address          `json:"clientIp"`
+	Port            uint16            `json:"port"`
+	SkipFallback    bool              `json:"skipFallback"`
+	Domains         []string          `json:"domains"`
+	ExpectIPs       StringList        `json:"expectIps"`
+	BootstrapIPs    StringList        `json:"bootstrapIps"`
+	Bootstrap       *NameServerConfig `json:"bootstrap"`
+	ExpectIPsAction string            `json:"expectIpsAction"`
 }
 
 func (c *NameServerConfig) UnmarshalJSON(data []byte) error {
@@ -27,12 +30,15 @@ func (c *NameServerConfig) UnmarshalJSON(data []byte) error {
 	}
 
 	var advanced struct {
-		Address      *Address   `json:"address"`
-		ClientIP     *Address   `json:"clientIp"`
-		Port         uint16     `json:"port"`
-		SkipFallback bool       `json:"skipFallback"`
-		Domains      []string   `json:"domains"`
-		ExpectIPs    StringList `json:"expectIps"`
+		Address         *Address          `json:"address"`
+		ClientIP        *Address          `json:"clientIp"`
+		Port            uint16            `json:"port"`
+		SkipFallback    bool              `json:"skipFallback"`
+		Domains         []string          `json:"domains"`
+		ExpectIPs       StringList        `json:"expectIps"`
+		BootstrapIPs    StringList        `json:"bootstrapIps"`
+		Bootstrap       *NameServerConfig `json:"bootstrap"`
+		ExpectIPsAction string            `json:"expectIpsAction"`
 	}
 	if err := json.Unmarshal(data, &advanced); err == nil {
 		c.Address = advanced.Address
@@ -41,6 +47,9 @@ func (c *NameServerConfig) UnmarshalJSON(data []byte) error {
 		c.SkipFallback = advanced.SkipFallback
 		c.Domains = advanced.Domains
 		c.ExpectIPs = advanced.ExpectIPs
+		c.BootstrapIPs = advanced.BootstrapIPs
+		c.Bootstrap = advanced.Bootstrap
+		c.ExpectIPsAction = advanced.ExpectIPsAction
 		return nil
 	}
 
@@ -69,8 +78,18 @@ func (c *NameServerConfig) Build() (*dns.NameServer, error) {
 
 	var domains []*dns.NameServer_PriorityDomain
 	var originalRules []*dns.NameServer_OriginalRule
+	var geosites []*router.GeoSite
 
 	for _, rule := range c.Domains {
+		// geosite lists are matched with the matchers the router compiles for them, rather than domain by domain
+		site, err := parseGeositeRule(rule)
+		if err != nil {
+			return nil, newError("invalid domain rule: ", rule).Base(err)
+		}
+		if site != nil {
+			geosites = append(geosites, site)
+			continue
+		}
 		parsedDomain, err := parseDomainRule(rule)
 		if err != nil {
 			return nil, newError("invalid domain rule: ", rule).Base(err)
@@ -101,6 +120,39 @@ func (c *NameServerConfig) Build() (*dns.NameServer, error) {
 		myClientIP = []byte(c.ClientIP.IP())
 	}
 
+	var bootstrapIPs [][]byte
+	for _, ip := range c.BootstrapIPs {
+		addr := net.ParseAddress(ip)
+		if !addr.Family().IsIP() {
+			return nil, newError("invalid bootstrap IP: ", ip)
+		}
+		bootstrapIPs = append(bootstrapIPs, []byte(addr.IP()))
+	}
+
+	var expectIPsAction dns.ExpectIPsAction
+	switch strings.ToLower(c.ExpectIPsAction) {
+	case "", "next":
+		expectIPsAction = dns.ExpectIPsAction_Next
+	case "return":
+		expectIPsAction = dns.ExpectIPsAction_Return
+	case "nxdomain", "reject":
+		expectIPsAction = dns.ExpectIPsAction_Reject
+	default:
+		return nil, newError("unknown expectIpsAction: ", c.ExpectIPsAction)
+	}
+
+	var bootstrap *net.Endpoint
+	if c.Bootstrap != nil {
+		if c.Bootstrap.Address == nil {
+			return nil, newError("bootstrap address is not specified.")
+		}
+		bootstrap = &net.Endpoint{
+			Network: net.Network_UDP,
+			Address: c.Bootstrap.Address.Build(),
+			Port:    uint32(c.Bootstrap.Port),
+		}
+	}
+
 	return &dns.NameServer{
 		Address: &net.Endpoint{
 			Network: net.Network_UDP,
@@ -112,6 +164,10 @@ func (c *NameServerConfig) Build() (*dns.NameServer, error) {
 		PrioritizedDomain: domains,
 		Geoip:             geoipList,
 		OriginalRules:     originalRules,
+		Geosite:           geosites,
+		BootstrapIp:       bootstrapIPs,
+		Bootstrap:         bootstrap,
+		ExpectIpsAction:   expectIPsAction,
 	}, nil
 }
 
@@ -132,6 +188,9 @@ type DNSConfig struct {
 	DisableCache           bool                `json:"disableCache"`
 	DisableFallback        bool                `json:"disableFallback"`
 	DisableFallbackIfMatch bool                `json:"disableFallbackIfMatch"`
+	MaxNegativeTTL         uint32              `json:"maxNegativeTtl"`
+	SpecialUseDomains      bool                `json:"specialUseDomains"`
+	LANServer              *NameServerConfig   `json:"lanServer"`
 }
 
 type HostAddress struct {
@@ -311,6 +370,19 @@ func (c *DNSConfig) Build() (*dns.Config, error) {
 		DisableCache:           c.DisableCache,
 		DisableFallback:        c.DisableFallback,
 		DisableFallbackIfMatch: c.DisableFallbackIfMatch,
+		MaxNegativeTtl:         c.MaxNegativeTTL,
+		SpecialUseDomains:      c.SpecialUseDomains,
+	}
+
+	if c.LANServer != nil {
+		if c.LANServer.Address == nil {
+			return nil, newError("LAN server address is not specified.")
+		}
+		config.LanServer = &net.Endpoint{
+			Network: net.Network_UDP,
+			Address: c.LANServer.Address.Build(),
+			Port:    uint32(c.LANServer.Port),
+		}
 	}
 
 	if c.ClientIP != nil {
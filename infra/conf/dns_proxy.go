@@ -7,10 +7,11 @@ import (
 )
 
 type DNSOutboundConfig struct {
-	Network   Network  `json:"network"`
-	Address   *Address `json:"address"`
-	Port      uint16   `json:"port"`
-	UserLevel uint32   `json:"userLevel"`
+	Network    Network  `json:"network"`
+	Address    *Address `json:"address"`
+	Port       uint16   `json:"port"`
+	UserLevel  uint32   `json:"userLevel"`
+	StripHTTPS bool     `json:"stripHttpsRecords"`
 }
 
 func (c *DNSOutboundConfig) Build() (proto.Message, error) {
@@ -19,7 +20,8 @@ func (c *DNSOutboundConfig) Build() (proto.Message, error) {
 			Network: c.Network.Build(),
 			Port:    uint32(c.Port),
 		},
-		UserLevel: c.UserLevel,
+		UserLevel:         c.UserLevel,
+		StripHttpsRecords: c.StripHTTPS,
 	}
 	if c.Address != nil {
 		config.Server.Address = c.Address.Build()
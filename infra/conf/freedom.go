@@ -2,6 +2,7 @@ package conf
 
 import (
 	"net"
+	"strconv"
 	"strings"
 
 	"github.com/golang/protobuf/proto"
@@ -11,10 +12,66 @@ import (
 )
 
 type FreedomConfig struct {
-	DomainStrategy string  `json:"domainStrategy"`
-	Timeout        *uint32 `json:"timeout"`
-	Redirect       string  `json:"redirect"`
-	UserLevel      uint32  `json:"userLevel"`
+	DomainStrategy string          `json:"domainStrategy"`
+	Timeout        *uint32         `json:"timeout"`
+	Redirect       string          `json:"redirect"`
+	UserLevel      uint32          `json:"userLevel"`
+	Fragment       *FragmentConfig `json:"fragment"`
+}
+
+type FragmentConfig struct {
+	Packets  string `json:"packets"`
+	Length   string `json:"length"`
+	Interval string `json:"interval"`
+}
+
+// parseRange parses a number or a range of numbers like "10-20".
+func parseRange(s string) (uint64, uint64, error) {
+	from, to, found := strings.Cut(strings.TrimSpace(s), "-")
+	min, err := strconv.ParseUint(strings.TrimSpace(from), 10, 64)
+	if err != nil {
+		return 0, 0, newError("invalid range: ", s).Base(err)
+	}
+	max := min
+	if found {
+		if max, err = strconv.ParseUint(strings.TrimSpace(to), 10, 64); err != nil {
+			return 0, 0, newError("invalid range: ", s).Base(err)
+		}
+	}
+	if max < min {
+		return 0, 0, newError("invalid range: ", s)
+	}
+	return min, max, nil
+}
+
+func (c *FragmentConfig) Build() (*freedom.Fragment, error) {
+	fragment := new(freedom.Fragment)
+	var err error
+	switch strings.ToLower(c.Packets) {
+	case "tlshello":
+		fragment.PacketsFrom, fragment.PacketsTo = 0, 1
+	case "":
+		return nil, newError(`freedom: "packets" of fragment is not set`)
+	default:
+		if fragment.PacketsFrom, fragment.PacketsTo, err = parseRange(c.Packets); err != nil {
+			return nil, newError("freedom: invalid fragment packets").Base(err)
+		}
+		if fragment.PacketsFrom == 0 {
+			return nil, newError("freedom: fragment packets are counted from 1")
+		}
+	}
+	if fragment.LengthMin, fragment.LengthMax, err = parseRange(c.Length); err != nil {
+		return nil, newError("freedom: invalid fragment length").Base(err)
+	}
+	if fragment.LengthMin == 0 {
+		return nil, newError("freedom: fragment length must be positive")
+	}
+	if c.Interval != "" {
+		if fragment.IntervalMin, fragment.IntervalMax, err = parseRange(c.Interval); err != nil {
+			return nil, newError("freedom: invalid fragment interval").Base(err)
+		}
+	}
+	return fragment, nil
 }
 
 // Build implements Buildable
@@ -34,6 +91,13 @@ func (c *FreedomConfig) Build() (proto.Message, error) {
 		config.Timeout = *c.Timeout
 	}
 	config.UserLevel = c.UserLevel
+	if c.Fragment != nil {
+		fragment, err := c.Fragment.Build()
+		if err != nil {
+			return nil, err
+		}
+		config.Fragment = fragment
+	}
 	if len(c.Redirect) > 0 {
 		host, portStr, err := net.SplitHostPort(c.Redirect)
 		if err != nil {
@@ -39,5 +39,25 @@ func TestFreedomConfig(t *testing.T) {
 				UserLevel: 1,
 			},
 		},
+		{
+			Input: `{
+				"fragment": {
+					"packets": "tlshello",
+					"length": "100-200",
+					"interval": "10"
+				}
+			}`,
+			Parser: loadJSON(creator),
+			Output: &freedom.Config{
+				Fragment: &freedom.Fragment{
+					PacketsFrom: 0,
+					PacketsTo:   1,
+					LengthMin:   100,
+					LengthMax:   200,
+					IntervalMin: 10,
+					IntervalMax: 10,
+				},
+			},
+		},
 	})
 }
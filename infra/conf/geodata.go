@@ -0,0 +1,89 @@
+package conf
+
+import (
+	"net"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/xtls/xray-core/app/geodata"
+)
+
+type GeodataAssetConfig struct {
+	File        string `json:"file"`
+	URL         string `json:"url"`
+	SHA256      string `json:"sha256"`
+	ChecksumURL string `json:"checksumUrl"`
+}
+
+func (c *GeodataAssetConfig) Build(file string) (*geodata.Asset, error) {
+	if c.File != "" {
+		file = c.File
+	}
+	if file == "" || c.URL == "" {
+		return nil, newError("geodata: asset requires file and url")
+	}
+	if c.SHA256 != "" && c.ChecksumURL != "" {
+		return nil, newError("geodata: sha256 and checksumUrl of ", file, " are exclusive")
+	}
+	if c.SHA256 != "" && len(c.SHA256) != 64 {
+		return nil, newError("geodata: invalid sha256 of ", file, ": ", c.SHA256)
+	}
+	return &geodata.Asset{
+		File:        file,
+		Url:         c.URL,
+		Sha256:      strings.ToLower(c.SHA256),
+		ChecksumUrl: c.ChecksumURL,
+	}, nil
+}
+
+// GeodataConfig keeps geoip.dat, geosite.dat and other asset files up to date.
+type GeodataConfig struct {
+	GeoIP       *GeodataAssetConfig   `json:"geoip"`
+	GeoSite     *GeodataAssetConfig   `json:"geosite"`
+	Assets      []*GeodataAssetConfig `json:"assets"`
+	OutboundTag string                `json:"outboundTag"`
+	Interval    uint32                `json:"interval"`
+	DNSServer   string                `json:"dnsServer"`
+}
+
+func (c *GeodataConfig) Build() (proto.Message, error) {
+	config := &geodata.Config{
+		OutboundTag: c.OutboundTag,
+		Interval:    c.Interval,
+	}
+	if c.DNSServer != "" {
+		host, port, err := net.SplitHostPort(c.DNSServer)
+		if err != nil {
+			host, port = c.DNSServer, "53"
+		}
+		if net.ParseIP(host) == nil {
+			return nil, newError("geodata: dnsServer must be an IP address: ", c.DNSServer)
+		}
+		config.DnsServer = net.JoinHostPort(host, port)
+	}
+	if c.GeoIP != nil {
+		asset, err := c.GeoIP.Build("geoip.dat")
+		if err != nil {
+			return nil, err
+		}
+		config.Asset = append(config.Asset, asset)
+	}
+	if c.GeoSite != nil {
+		asset, err := c.GeoSite.Build("geosite.dat")
+		if err != nil {
+			return nil, err
+		}
+		config.Asset = append(config.Asset, asset)
+	}
+	for _, a := range c.Assets {
+		asset, err := a.Build("")
+		if err != nil {
+			return nil, err
+		}
+		config.Asset = append(config.Asset, asset)
+	}
+	if len(config.Asset) == 0 {
+		return nil, newError("geodata: no asset to update")
+	}
+	return config, nil
+}
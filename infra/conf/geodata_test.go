@@ -0,0 +1,59 @@
+package conf_test
+
+import (
+	"testing"
+
+	"github.com/xtls/xray-core/app/geodata"
+	. "github.com/xtls/xray-core/infra/conf"
+)
+
+func TestGeodataConfig(t *testing.T) {
+	creator := func() Buildable {
+		return new(GeodataConfig)
+	}
+
+	runMultiTestCase(t, []TestCase{
+		{
+			Input: `{
+				"geoip": {
+					"url": "https://example.com/geoip.dat"
+				},
+				"outboundTag": "control",
+				"dnsServer": "1.1.1.1"
+			}`,
+			Parser: loadJSON(creator),
+			Output: &geodata.Config{
+				Asset: []*geodata.Asset{
+					{
+						File: "geoip.dat",
+						Url:  "https://example.com/geoip.dat",
+					},
+				},
+				OutboundTag: "control",
+				DnsServer:   "1.1.1.1:53",
+			},
+		},
+		{
+			Input: `{
+				"geosite": {
+					"url": "https://example.com/geosite.dat"
+				},
+				"dnsServer": "[2606:4700:4700::1111]:5353"
+			}`,
+			Parser: loadJSON(creator),
+			Output: &geodata.Config{
+				Asset: []*geodata.Asset{
+					{
+						File: "geosite.dat",
+						Url:  "https://example.com/geosite.dat",
+					},
+				},
+				DnsServer: "[2606:4700:4700::1111]:5353",
+			},
+		},
+	})
+
+	if _, err := loadJSON(creator)(`{"geoip": {"url": "https://example.com/geoip.dat"}, "dnsServer": "dns.google"}`); err == nil {
+		t.Error("expected dnsServer as a domain to be rejected")
+	}
+}
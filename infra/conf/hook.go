@@ -0,0 +1,54 @@
+package conf
+
+import (
+	"encoding/json"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/xtls/xray-core/app/hook"
+)
+
+type HookConfig struct {
+	Events  StringList `json:"events"`
+	Command StringList `json:"command"`
+	URL     string     `json:"url"`
+	Timeout uint32     `json:"timeout"`
+}
+
+// HooksConfig is either a list of hooks, or an object with the hooks and health settings.
+type HooksConfig struct {
+	Hooks          []*HookConfig `json:"hooks"`
+	ErrorThreshold uint32        `json:"errorThreshold"`
+}
+
+// UnmarshalJSON implements encoding/json.Unmarshaler.UnmarshalJSON
+func (c *HooksConfig) UnmarshalJSON(data []byte) error {
+	var hooks []*HookConfig
+	if err := json.Unmarshal(data, &hooks); err == nil {
+		c.Hooks = hooks
+		return nil
+	}
+
+	type hooksConfig HooksConfig
+	if err := json.Unmarshal(data, (*hooksConfig)(c)); err != nil {
+		return newError("unknown format of hooks: ", string(data)).Base(err)
+	}
+	return nil
+}
+
+func (c *HooksConfig) Build() (proto.Message, error) {
+	config := &hook.Config{
+		ErrorThreshold: c.ErrorThreshold,
+	}
+	for _, h := range c.Hooks {
+		if len(h.Command) == 0 && h.URL == "" {
+			return nil, newError("hook requires command or url")
+		}
+		config.Hook = append(config.Hook, &hook.Hook{
+			Event:   h.Events,
+			Command: h.Command,
+			Url:     h.URL,
+			Timeout: h.Timeout,
+		})
+	}
+	return config, nil
+}
@@ -20,6 +20,19 @@ type LogConfig struct {
 	ErrorLog  string `json:"error"`
 	LogLevel  string `json:"loglevel"`
 	DNSLog    bool   `json:"dnsLog"`
+
+	DedupWindow  uint32        `json:"dedupWindow"`
+	RateLimit    *LogRateLimit `json:"rateLimit"`
+	HistorySize  uint32        `json:"historySize"`
+	HistoryLevel string        `json:"historyLevel"`
+}
+
+// LogRateLimit is the number of error log lines per second allowed for each level.
+type LogRateLimit struct {
+	Error   uint32 `json:"error"`
+	Warning uint32 `json:"warning"`
+	Info    uint32 `json:"info"`
+	Debug   uint32 `json:"debug"`
 }
 
 func (v *LogConfig) Build() *log.Config {
@@ -30,6 +43,16 @@ func (v *LogConfig) Build() *log.Config {
 		ErrorLogType:  log.LogType_Console,
 		AccessLogType: log.LogType_Console,
 		EnableDnsLog:  v.DNSLog,
+		DedupWindow:   v.DedupWindow,
+		HistorySize:   v.HistorySize,
+	}
+	if r := v.RateLimit; r != nil {
+		config.RateLimit = &log.RateLimit{
+			Error:   r.Error,
+			Warning: r.Warning,
+			Info:    r.Info,
+			Debug:   r.Debug,
+		}
 	}
 
 	if v.AccessLog == "none" {
@@ -45,6 +68,17 @@ func (v *LogConfig) Build() *log.Config {
 		config.ErrorLogType = log.LogType_File
 	}
 
+	switch strings.ToLower(v.HistoryLevel) {
+	case "debug":
+		config.HistoryLevel = clog.Severity_Debug
+	case "warning":
+		config.HistoryLevel = clog.Severity_Warning
+	case "error":
+		config.HistoryLevel = clog.Severity_Error
+	case "info":
+		config.HistoryLevel = clog.Severity_Info
+	}
+
 	level := strings.ToLower(v.LogLevel)
 	switch level {
 	case "debug":
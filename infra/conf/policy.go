@@ -2,16 +2,73 @@ package conf
 
 import (
 	"github.com/xtls/xray-core/app/policy"
+	"github.com/xtls/xray-core/app/router"
 )
 
 type Policy struct {
-	Handshake         *uint32 `json:"handshake"`
-	ConnectionIdle    *uint32 `json:"connIdle"`
-	UplinkOnly        *uint32 `json:"uplinkOnly"`
-	DownlinkOnly      *uint32 `json:"downlinkOnly"`
-	StatsUserUplink   bool    `json:"statsUserUplink"`
-	StatsUserDownlink bool    `json:"statsUserDownlink"`
-	BufferSize        *int32  `json:"bufferSize"`
+	Handshake         *uint32     `json:"handshake"`
+	ConnectionIdle    *uint32     `json:"connIdle"`
+	UplinkOnly        *uint32     `json:"uplinkOnly"`
+	DownlinkOnly      *uint32     `json:"downlinkOnly"`
+	UplinkIdle        *uint32     `json:"uplinkIdle"`
+	DownlinkIdle      *uint32     `json:"downlinkIdle"`
+	MaxLifetime       *uint32     `json:"maxLifetime"`
+	StatsUserUplink   bool        `json:"statsUserUplink"`
+	StatsUserDownlink bool        `json:"statsUserDownlink"`
+	StatsUserLogin    bool        `json:"statsUserLogin"`
+	BufferSize        *int32      `json:"bufferSize"`
+	AllowedDomains    *StringList `json:"allowedDomains"`
+	AllowedIPs        *StringList `json:"allowedIPs"`
+	DeniedDomains     *StringList `json:"deniedDomains"`
+	DeniedIPs         *StringList `json:"deniedIPs"`
+	MirrorKeepAlive   bool        `json:"mirrorKeepAlive"`
+}
+
+func parseDomainList(list *StringList) ([]*router.Domain, error) {
+	if list == nil {
+		return nil, nil
+	}
+	var domains []*router.Domain
+	for _, domain := range *list {
+		rules, err := parseDomainRule(domain)
+		if err != nil {
+			return nil, newError("failed to parse domain rule: ", domain).Base(err)
+		}
+		domains = append(domains, rules...)
+	}
+	return domains, nil
+}
+
+func parseIPList(list *StringList) ([]*router.GeoIP, error) {
+	if list == nil {
+		return nil, nil
+	}
+	geoipList, err := ToCidrList(*list)
+	if err != nil {
+		return nil, newError("failed to parse ip list").Base(err)
+	}
+	return geoipList, nil
+}
+
+func (t *Policy) buildDestination() (*policy.Policy_Destination, error) {
+	if t.AllowedDomains == nil && t.AllowedIPs == nil && t.DeniedDomains == nil && t.DeniedIPs == nil {
+		return nil, nil
+	}
+	var err error
+	d := new(policy.Policy_Destination)
+	if d.AllowedDomain, err = parseDomainList(t.AllowedDomains); err != nil {
+		return nil, err
+	}
+	if d.AllowedIp, err = parseIPList(t.AllowedIPs); err != nil {
+		return nil, err
+	}
+	if d.DeniedDomain, err = parseDomainList(t.DeniedDomains); err != nil {
+		return nil, err
+	}
+	if d.DeniedIp, err = parseIPList(t.DeniedIPs); err != nil {
+		return nil, err
+	}
+	return d, nil
 }
 
 func (t *Policy) Build() (*policy.Policy, error) {
@@ -28,12 +85,22 @@ func (t *Policy) Build() (*policy.Policy, error) {
 	if t.DownlinkOnly != nil {
 		config.DownlinkOnly = &policy.Second{Value: *t.DownlinkOnly}
 	}
+	if t.UplinkIdle != nil {
+		config.UplinkIdle = &policy.Second{Value: *t.UplinkIdle}
+	}
+	if t.DownlinkIdle != nil {
+		config.DownlinkIdle = &policy.Second{Value: *t.DownlinkIdle}
+	}
+	if t.MaxLifetime != nil {
+		config.MaxLifetime = &policy.Second{Value: *t.MaxLifetime}
+	}
 
 	p := &policy.Policy{
 		Timeout: config,
 		Stats: &policy.Policy_Stats{
 			UserUplink:   t.StatsUserUplink,
 			UserDownlink: t.StatsUserDownlink,
+			UserLogin:    t.StatsUserLogin,
 		},
 	}
 
@@ -47,25 +114,48 @@ func (t *Policy) Build() (*policy.Policy, error) {
 		}
 	}
 
+	if t.MirrorKeepAlive {
+		p.KeepAlive = &policy.Policy_KeepAlive{
+			Mirror: true,
+		}
+	}
+
+	destination, err := t.buildDestination()
+	if err != nil {
+		return nil, err
+	}
+	p.Destination = destination
+
 	return p, nil
 }
 
 type SystemPolicy struct {
-	StatsInboundUplink    bool `json:"statsInboundUplink"`
-	StatsInboundDownlink  bool `json:"statsInboundDownlink"`
-	StatsOutboundUplink   bool `json:"statsOutboundUplink"`
-	StatsOutboundDownlink bool `json:"statsOutboundDownlink"`
+	StatsInboundUplink    bool  `json:"statsInboundUplink"`
+	StatsInboundDownlink  bool  `json:"statsInboundDownlink"`
+	StatsOutboundUplink   bool  `json:"statsOutboundUplink"`
+	StatsOutboundDownlink bool  `json:"statsOutboundDownlink"`
+	StatsInboundProtocol  bool  `json:"statsInboundProtocol"`
+	PreferChaCha20        *bool `json:"preferChaCha20"`
 }
 
 func (p *SystemPolicy) Build() (*policy.SystemPolicy, error) {
-	return &policy.SystemPolicy{
+	config := &policy.SystemPolicy{
 		Stats: &policy.SystemPolicy_Stats{
 			InboundUplink:    p.StatsInboundUplink,
 			InboundDownlink:  p.StatsInboundDownlink,
 			OutboundUplink:   p.StatsOutboundUplink,
 			OutboundDownlink: p.StatsOutboundDownlink,
+			InboundProtocol:  p.StatsInboundProtocol,
 		},
-	}, nil
+	}
+	if p.PreferChaCha20 != nil {
+		if *p.PreferChaCha20 {
+			config.PreferredCipher = policy.SystemPolicy_CHACHA20_POLY1305
+		} else {
+			config.PreferredCipher = policy.SystemPolicy_AES_GCM
+		}
+	}
+	return config, nil
 }
 
 type PolicyConfig struct {
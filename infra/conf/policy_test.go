@@ -1,8 +1,10 @@
 package conf_test
 
 import (
+	"encoding/json"
 	"testing"
 
+	"github.com/xtls/xray-core/app/policy"
 	"github.com/xtls/xray-core/common"
 	. "github.com/xtls/xray-core/infra/conf"
 )
@@ -38,3 +40,22 @@ func TestBufferSize(t *testing.T) {
 		}
 	}
 }
+
+func TestSystemPolicyPreferChaCha20(t *testing.T) {
+	for _, c := range []struct {
+		Input  string
+		Output policy.SystemPolicy_Cipher
+	}{
+		{`{}`, policy.SystemPolicy_AUTO},
+		{`{"preferChaCha20": true}`, policy.SystemPolicy_CHACHA20_POLY1305},
+		{`{"preferChaCha20": false}`, policy.SystemPolicy_AES_GCM},
+	} {
+		config := new(SystemPolicy)
+		common.Must(json.Unmarshal([]byte(c.Input), config))
+		p, err := config.Build()
+		common.Must(err)
+		if p.PreferredCipher != c.Output {
+			t.Error("expected ", c.Output, " for ", c.Input, " but got ", p.PreferredCipher)
+		}
+	}
+}
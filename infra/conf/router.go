@@ -2,14 +2,13 @@ package conf
 
 import (
 	"encoding/json"
-	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/xtls/xray-core/app/router"
 	"github.com/xtls/xray-core/common/net"
-	"github.com/xtls/xray-core/common/platform/filesystem"
 )
 
 type RouterRulesConfig struct {
@@ -38,11 +37,30 @@ func (r *BalancingRule) Build() (*router.BalancingRule, error) {
 	}
 
 	var strategy string
+	var hashKey router.BalancingRule_HashKey
 	switch strings.ToLower(r.Strategy.Type) {
 	case strategyRandom, "":
 		strategy = strategyRandom
 	case strategyLeastPing:
 		strategy = "leastPing"
+	case strategyRoundRobin:
+		strategy = "roundRobin"
+	case strategyConsistentHash:
+		strategy = "consistentHash"
+		settings := new(strategyConsistentHashConfig)
+		if r.Strategy.Settings != nil {
+			if err := json.Unmarshal(*r.Strategy.Settings, settings); err != nil {
+				return nil, newError("invalid consistentHash settings").Base(err)
+			}
+		}
+		switch strings.ToLower(settings.HashKey) {
+		case "", "target":
+			hashKey = router.BalancingRule_Target
+		case "source":
+			hashKey = router.BalancingRule_Source
+		default:
+			return nil, newError("unknown hashKey: ", settings.HashKey)
+		}
 	default:
 		return nil, newError("unknown balancing strategy: " + r.Strategy.Type)
 	}
@@ -51,6 +69,7 @@ func (r *BalancingRule) Build() (*router.BalancingRule, error) {
 		Tag:              r.Tag,
 		OutboundSelector: []string(r.Selectors),
 		Strategy:         strategy,
+		HashKey:          hashKey,
 	}, nil
 }
 
@@ -120,10 +139,22 @@ func (c *RouterConfig) Build() (*router.Config, error) {
 
 type RouterRule struct {
 	Type        string `json:"type"`
+	RuleTag     string `json:"ruleTag"`
 	OutboundTag string `json:"outboundTag"`
 	BalancerTag string `json:"balancerTag"`
+	StandbyTag  string `json:"standbyTag"`
+
+	Sockopt *RouterRuleSockopt `json:"sockopt"`
 
 	DomainMatcher string `json:"domainMatcher"`
+	ResolveDomain bool   `json:"resolveDomain"`
+}
+
+// RouterRuleSockopt is the socket options a routing rule sets for the dials of matching connections.
+type RouterRuleSockopt struct {
+	Mark      int32  `json:"mark"`
+	Interface string `json:"interface"`
+	DSCP      int32  `json:"dscp"`
 }
 
 func ParseIP(s string) (*router.CIDR, error) {
@@ -179,36 +210,33 @@ func loadGeoIP(code string) ([]*router.CIDR, error) {
 }
 
 var (
-	FileCache = make(map[string][]byte)
-	IPCache   = make(map[string]*router.GeoIP)
-	SiteCache = make(map[string]*router.GeoSite)
+	// geoCacheAccess guards the caches, as configs may be built while the watchdog resets them.
+	geoCacheAccess sync.Mutex
+	FileCache      = make(map[string][]byte)
+	IPCache        = make(map[string]*router.GeoIP)
+	SiteCache      = make(map[string]*router.GeoSite)
 )
 
-func loadFile(file string) ([]byte, error) {
-	if FileCache[file] == nil {
-		bs, err := filesystem.ReadAsset(file)
-		if err != nil {
-			return nil, newError("failed to open file: ", file).Base(err)
-		}
-		if len(bs) == 0 {
-			return nil, newError("empty file: ", file)
-		}
-		// Do not cache file, may save RAM when there
-		// are many files, but consume CPU each time.
-		return bs, nil
-		FileCache[file] = bs
-	}
-	return FileCache[file], nil
+// ResetGeoCache drops the categories cached while loading a config. The router and DNS share the
+// categories they both use through the cache, and once the config is built, it only holds memory.
+// The matchers compiled from geosite lists are shared by the router itself, see router.GetGeoSiteMatcher.
+func ResetGeoCache() {
+	geoCacheAccess.Lock()
+	defer geoCacheAccess.Unlock()
+	FileCache = make(map[string][]byte)
+	IPCache = make(map[string]*router.GeoIP)
+	SiteCache = make(map[string]*router.GeoSite)
 }
 
 func loadIP(file, code string) ([]*router.CIDR, error) {
+	geoCacheAccess.Lock()
+	defer geoCacheAccess.Unlock()
 	index := file + ":" + code
 	if IPCache[index] == nil {
-		bs, err := loadFile(file)
+		bs, err := router.LoadGeoEntry(file, code)
 		if err != nil {
 			return nil, newError("failed to load file: ", file).Base(err)
 		}
-		bs = find(bs, []byte(code))
 		if bs == nil {
 			return nil, newError("code not found in ", file, ": ", code)
 		}
@@ -216,21 +244,20 @@ func loadIP(file, code string) ([]*router.CIDR, error) {
 		if err := proto.Unmarshal(bs, &geoip); err != nil {
 			return nil, newError("error unmarshal IP in ", file, ": ", code).Base(err)
 		}
-		defer runtime.GC()     // or debug.FreeOSMemory()
-		return geoip.Cidr, nil // do not cache geoip
 		IPCache[index] = &geoip
 	}
 	return IPCache[index].Cidr, nil
 }
 
 func loadSite(file, code string) ([]*router.Domain, error) {
+	geoCacheAccess.Lock()
+	defer geoCacheAccess.Unlock()
 	index := file + ":" + code
 	if SiteCache[index] == nil {
-		bs, err := loadFile(file)
+		bs, err := router.LoadGeoEntry(file, code)
 		if err != nil {
 			return nil, newError("failed to load file: ", file).Base(err)
 		}
-		bs = find(bs, []byte(code))
 		if bs == nil {
 			return nil, newError("list not found in ", file, ": ", code)
 		}
@@ -238,47 +265,11 @@ func loadSite(file, code string) ([]*router.Domain, error) {
 		if err := proto.Unmarshal(bs, &geosite); err != nil {
 			return nil, newError("error unmarshal Site in ", file, ": ", code).Base(err)
 		}
-		defer runtime.GC()         // or debug.FreeOSMemory()
-		return geosite.Domain, nil // do not cache geosite
 		SiteCache[index] = &geosite
 	}
 	return SiteCache[index].Domain, nil
 }
 
-func find(data, code []byte) []byte {
-	codeL := len(code)
-	if codeL == 0 {
-		return nil
-	}
-	for {
-		dataL := len(data)
-		if dataL < 2 {
-			return nil
-		}
-		x, y := proto.DecodeVarint(data[1:])
-		if x == 0 && y == 0 {
-			return nil
-		}
-		headL, bodyL := 1+y, int(x)
-		dataL -= headL
-		if dataL < bodyL {
-			return nil
-		}
-		data = data[headL:]
-		if int(data[1]) == codeL {
-			for i := 0; i < codeL && data[2+i] == code[i]; i++ {
-				if i+1 == codeL {
-					return data[:bodyL]
-				}
-			}
-		}
-		if dataL == bodyL {
-			return nil
-		}
-		data = data[bodyL:]
-	}
-}
-
 type AttributeMatcher interface {
 	Match(*router.Domain) bool
 }
@@ -346,14 +337,21 @@ func loadGeositeWithAttr(file string, siteWithAttr string) ([]*router.Domain, er
 	return filteredDomains, nil
 }
 
-func parseDomainRule(domain string) ([]*router.Domain, error) {
+// parseGeositeRule loads the list of a geosite: or ext: domain rule, remembering the file it comes
+// from so that the router can load it again. It returns nil for other domain rules.
+func parseGeositeRule(domain string) (*router.GeoSite, error) {
 	if strings.HasPrefix(domain, "geosite:") {
 		country := strings.ToUpper(domain[8:])
 		domains, err := loadGeositeWithAttr("geosite.dat", country)
 		if err != nil {
 			return nil, newError("failed to load geosite: ", country).Base(err)
 		}
-		return domains, nil
+		return &router.GeoSite{
+			CountryCode: country,
+			Domain:      domains,
+			File:        "geosite.dat",
+			Code:        country,
+		}, nil
 	}
 	isExtDatFile := 0
 	{
@@ -377,7 +375,23 @@ func parseDomainRule(domain string) ([]*router.Domain, error) {
 		if err != nil {
 			return nil, newError("failed to load external sites: ", country, " from ", filename).Base(err)
 		}
-		return domains, nil
+		return &router.GeoSite{
+			CountryCode: strings.ToUpper(filename + "_" + country),
+			Domain:      domains,
+			File:        filename,
+			Code:        country,
+		}, nil
+	}
+	return nil, nil
+}
+
+func parseDomainRule(domain string) ([]*router.Domain, error) {
+	site, err := parseGeositeRule(domain)
+	if err != nil {
+		return nil, err
+	}
+	if site != nil {
+		return site.Domain, nil
 	}
 
 	domainRule := new(router.Domain)
@@ -440,6 +454,8 @@ func ToCidrList(ips StringList) ([]*router.GeoIP, error) {
 				CountryCode:  strings.ToUpper(country),
 				Cidr:         geoip,
 				ReverseMatch: isReverseMatch,
+				File:         "geoip.dat",
+				Code:         strings.ToUpper(country),
 			})
 			continue
 		}
@@ -480,6 +496,8 @@ func ToCidrList(ips StringList) ([]*router.GeoIP, error) {
 				CountryCode:  strings.ToUpper(filename + "_" + country),
 				Cidr:         geoip,
 				ReverseMatch: isReverseMatch,
+				File:         filename,
+				Code:         strings.ToUpper(country),
 			})
 
 			continue
@@ -501,21 +519,24 @@ func ToCidrList(ips StringList) ([]*router.GeoIP, error) {
 	return geoipList, nil
 }
 
+// RawFieldRule is a routing rule in the config.
+type RawFieldRule struct {
+	RouterRule
+	Domain     *StringList  `json:"domain"`
+	Domains    *StringList  `json:"domains"`
+	IP         *StringList  `json:"ip"`
+	Port       *PortList    `json:"port"`
+	Network    *NetworkList `json:"network"`
+	SourceIP   *StringList  `json:"source"`
+	SourcePort *PortList    `json:"sourcePort"`
+	User       *StringList  `json:"user"`
+	InboundTag *StringList  `json:"inboundTag"`
+	Protocols  *StringList  `json:"protocol"`
+	Attributes string       `json:"attrs"`
+	Process    *StringList  `json:"process"`
+}
+
 func parseFieldRule(msg json.RawMessage) (*router.RoutingRule, error) {
-	type RawFieldRule struct {
-		RouterRule
-		Domain     *StringList  `json:"domain"`
-		Domains    *StringList  `json:"domains"`
-		IP         *StringList  `json:"ip"`
-		Port       *PortList    `json:"port"`
-		Network    *NetworkList `json:"network"`
-		SourceIP   *StringList  `json:"source"`
-		SourcePort *PortList    `json:"sourcePort"`
-		User       *StringList  `json:"user"`
-		InboundTag *StringList  `json:"inboundTag"`
-		Protocols  *StringList  `json:"protocol"`
-		Attributes string       `json:"attrs"`
-	}
 	rawFieldRule := new(RawFieldRule)
 	err := json.Unmarshal(msg, rawFieldRule)
 	if err != nil {
@@ -540,24 +561,39 @@ func parseFieldRule(msg json.RawMessage) (*router.RoutingRule, error) {
 		rule.DomainMatcher = rawFieldRule.DomainMatcher
 	}
 
-	if rawFieldRule.Domain != nil {
-		for _, domain := range *rawFieldRule.Domain {
-			rules, err := parseDomainRule(domain)
-			if err != nil {
-				return nil, newError("failed to parse domain rule: ", domain).Base(err)
-			}
-			rule.Domain = append(rule.Domain, rules...)
+	rule.RuleTag = rawFieldRule.RuleTag
+	rule.StandbyTag = rawFieldRule.StandbyTag
+
+	if s := rawFieldRule.Sockopt; s != nil {
+		if s.DSCP < 0 || s.DSCP > 63 {
+			return nil, newError("dscp must be from 0 to 63")
 		}
+		rule.Mark = s.Mark
+		rule.Interface = s.Interface
+		rule.Dscp = s.DSCP
 	}
 
+	var domains StringList
+	if rawFieldRule.Domain != nil {
+		domains = append(domains, *rawFieldRule.Domain...)
+	}
 	if rawFieldRule.Domains != nil {
-		for _, domain := range *rawFieldRule.Domains {
-			rules, err := parseDomainRule(domain)
-			if err != nil {
-				return nil, newError("failed to parse domain rule: ", domain).Base(err)
-			}
-			rule.Domain = append(rule.Domain, rules...)
+		domains = append(domains, *rawFieldRule.Domains...)
+	}
+	for _, domain := range domains {
+		site, err := parseGeositeRule(domain)
+		if err != nil {
+			return nil, newError("failed to parse domain rule: ", domain).Base(err)
+		}
+		if site != nil {
+			rule.Geosite = append(rule.Geosite, site)
+			continue
 		}
+		rules, err := parseDomainRule(domain)
+		if err != nil {
+			return nil, newError("failed to parse domain rule: ", domain).Base(err)
+		}
+		rule.Domain = append(rule.Domain, rules...)
 	}
 
 	if rawFieldRule.IP != nil {
@@ -568,6 +604,13 @@ func parseFieldRule(msg json.RawMessage) (*router.RoutingRule, error) {
 		rule.Geoip = geoipList
 	}
 
+	if rawFieldRule.ResolveDomain {
+		if rawFieldRule.IP == nil {
+			return nil, newError("resolveDomain of routing rule requires ip")
+		}
+		rule.ResolveDomain = true
+	}
+
 	if rawFieldRule.Port != nil {
 		rule.PortList = rawFieldRule.Port.Build()
 	}
@@ -610,6 +653,10 @@ func parseFieldRule(msg json.RawMessage) (*router.RoutingRule, error) {
 		rule.Attributes = rawFieldRule.Attributes
 	}
 
+	if rawFieldRule.Process != nil {
+		rule.Process = append(rule.Process, *rawFieldRule.Process...)
+	}
+
 	return rule, nil
 }
 
@@ -1,6 +1,12 @@
 package conf
 
 const (
-	strategyRandom    string = "random"
-	strategyLeastPing string = "leastping"
+	strategyRandom         string = "random"
+	strategyLeastPing      string = "leastping"
+	strategyRoundRobin     string = "roundrobin"
+	strategyConsistentHash string = "consistenthash"
 )
+
+type strategyConsistentHashConfig struct {
+	HashKey string `json:"hashKey"`
+}
@@ -298,5 +298,150 @@ func TestRouterConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			Input: `{
+				"balancers": [
+					{
+						"tag": "b1",
+						"selector": ["test"],
+						"strategy": {"type": "roundRobin"}
+					},
+					{
+						"tag": "b2",
+						"selector": ["test"],
+						"strategy": {"type": "consistentHash", "settings": {"hashKey": "source"}}
+					}
+				]
+			}`,
+			Parser: createParser(),
+			Output: &router.Config{
+				DomainStrategy: router.Config_AsIs,
+				BalancingRule: []*router.BalancingRule{
+					{
+						Tag:              "b1",
+						OutboundSelector: []string{"test"},
+						Strategy:         "roundRobin",
+					},
+					{
+						Tag:              "b2",
+						OutboundSelector: []string{"test"},
+						Strategy:         "consistentHash",
+						HashKey:          router.BalancingRule_Source,
+					},
+				},
+			},
+		},
+		{
+			Input: `{
+				"rules": [
+					{
+						"type": "field",
+						"sourcePort": "1000-2000, 3000",
+						"user": ["alice@example.com", "bob@example.com"],
+						"outboundTag": "test"
+					}
+				]
+			}`,
+			Parser: createParser(),
+			Output: &router.Config{
+				DomainStrategy: router.Config_AsIs,
+				Rule: []*router.RoutingRule{
+					{
+						SourcePortList: &net.PortList{
+							Range: []*net.PortRange{
+								{From: 1000, To: 2000},
+								{From: 3000, To: 3000},
+							},
+						},
+						UserEmail: []string{"alice@example.com", "bob@example.com"},
+						TargetTag: &router.RoutingRule_Tag{
+							Tag: "test",
+						},
+					},
+				},
+			},
+		},
+		{
+			Input: `{
+				"rules": [
+					{
+						"type": "field",
+						"process": ["chrome.exe", "/usr/bin/curl"],
+						"outboundTag": "direct"
+					}
+				]
+			}`,
+			Parser: createParser(),
+			Output: &router.Config{
+				DomainStrategy: router.Config_AsIs,
+				Rule: []*router.RoutingRule{
+					{
+						Process: []string{"chrome.exe", "/usr/bin/curl"},
+						TargetTag: &router.RoutingRule_Tag{
+							Tag: "direct",
+						},
+					},
+				},
+			},
+		},
+	})
+}
+
+func TestGeoCacheSharedWithDNS(t *testing.T) {
+	t.Setenv("xray.location.asset", t.TempDir())
+	list, err := proto.Marshal(&router.GeoSiteList{
+		Entry: []*router.GeoSite{
+			{
+				CountryCode: "TEST",
+				Domain:      []*router.Domain{{Type: router.Domain_Full, Value: "example.com"}},
+			},
+		},
 	})
+	common.Must(err)
+	path := platform.GetAssetLocation("geosite.dat")
+	common.Must(os.WriteFile(path, list, 0o600))
+
+	ResetGeoCache()
+	defer ResetGeoCache()
+
+	_, err = (&RouterConfig{RuleList: []json.RawMessage{[]byte(`{"type": "field", "domain": ["geosite:test"], "outboundTag": "direct"}`)}}).Build()
+	common.Must(err)
+
+	// the DNS config gets the list the router loaded, without reading the file again
+	common.Must(os.Remove(path))
+
+	dnsConfig := func() *DNSConfig {
+		c := new(DNSConfig)
+		common.Must(json.Unmarshal([]byte(`{"servers": [{"address": "1.1.1.1", "domains": ["geosite:test"]}]}`), c))
+		return c
+	}
+	config, err := dnsConfig().Build()
+	if err != nil {
+		t.Fatal("expected the cached list to be used: ", err)
+	}
+	// the list is kept whole, so that DNS matches it with the matcher the router compiled
+	ns := config.NameServer[0]
+	if len(ns.PrioritizedDomain) != 0 || len(ns.Geosite) != 1 || ns.Geosite[0].File != "geosite.dat" || ns.Geosite[0].Code != "TEST" {
+		t.Error("unexpected name server: ", ns)
+	}
+
+	ResetGeoCache()
+	if _, err := dnsConfig().Build(); err == nil {
+		t.Error("expected the list to be read again after the cache is reset")
+	}
+}
+
+// TestResetGeoCacheWhileBuilding is for the race detector.
+func TestResetGeoCacheWhileBuilding(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			ResetGeoCache()
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		(&RouterConfig{RuleList: []json.RawMessage{[]byte(`{"type": "field", "domain": ["geosite:test"], "outboundTag": "direct"}`)}}).Build()
+	}
+	<-done
 }
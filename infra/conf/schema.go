@@ -0,0 +1,207 @@
+package conf
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/infra/conf/cfgcommon/duration"
+	"github.com/xtls/xray-core/proxy/vless"
+)
+
+// schema is a JSON Schema, or a part of one.
+type schema map[string]interface{}
+
+var (
+	stringSchema        = schema{"type": "string"}
+	stringOrArraySchema = schema{"type": []string{"string", "array"}, "items": stringSchema}
+	portSchema          = schema{"type": []string{"integer", "string"}}
+	rawMessageType      = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaGenerator generates the JSON Schema of config types from their fields.
+type schemaGenerator struct {
+	definitions schema
+	// special is the schema of the types that parse themselves from JSON, in other shapes than their fields.
+	special map[reflect.Type]func() schema
+	// rawLists is the schema of the items of the json.RawMessage lists that are parsed later, by the struct
+	// and the json name of the list.
+	rawLists map[reflect.Type]map[string]func() schema
+}
+
+// Schema returns the JSON Schema of the config, so that editors can validate and complete config files. It is
+// generated from the types the config is parsed into, so it doesn't lag behind them.
+func Schema() map[string]interface{} {
+	g := &schemaGenerator{
+		definitions: schema{},
+	}
+	g.special = map[reflect.Type]func() schema{
+		reflect.TypeOf(StringList{}):  func() schema { return stringOrArraySchema },
+		reflect.TypeOf(NetworkList{}): func() schema { return stringOrArraySchema },
+		reflect.TypeOf(Address{}):     func() schema { return stringSchema },
+		reflect.TypeOf(PortRange{}):   func() schema { return portSchema },
+		reflect.TypeOf(PortList{}):    func() schema { return portSchema },
+		reflect.TypeOf(duration.Duration(0)): func() schema {
+			return schema{"type": []string{"string", "integer"}}
+		},
+		reflect.TypeOf(HostAddress{}): func() schema { return stringOrArraySchema },
+		reflect.TypeOf(HostsWrapper{}): func() schema {
+			return schema{"type": "object", "additionalProperties": stringOrArraySchema}
+		},
+		reflect.TypeOf(NameServerConfig{}): func() schema {
+			return schema{"anyOf": []schema{stringSchema, g.fields(reflect.TypeOf(NameServerConfig{}))}}
+		},
+		reflect.TypeOf(FakeDNSConfig{}): func() schema {
+			pool := g.of(reflect.TypeOf(FakeDNSPoolElementConfig{}))
+			return schema{"anyOf": []schema{pool, {"type": "array", "items": pool}}}
+		},
+		reflect.TypeOf(HooksConfig{}): func() schema {
+			return schema{"anyOf": []schema{
+				{"type": "array", "items": g.of(reflect.TypeOf(HookConfig{}))},
+				g.fields(reflect.TypeOf(HooksConfig{})),
+			}}
+		},
+	}
+
+	rule := func() schema { return g.of(reflect.TypeOf(RawFieldRule{})) }
+	g.rawLists = map[reflect.Type]map[string]func() schema{
+		reflect.TypeOf(RouterConfig{}):        {"rules": rule},
+		reflect.TypeOf(RouterRulesConfig{}):   {"rules": rule},
+		reflect.TypeOf(VLessInboundConfig{}):  {"clients": g.users(reflect.TypeOf(vless.Account{}))},
+		reflect.TypeOf(VLessOutboundVnext{}):  {"users": g.users(reflect.TypeOf(vless.Account{}))},
+		reflect.TypeOf(VMessInboundConfig{}):  {"clients": g.users(reflect.TypeOf(VMessAccount{}))},
+		reflect.TypeOf(VMessOutboundTarget{}): {"users": g.users(reflect.TypeOf(VMessAccount{}))},
+		reflect.TypeOf(HTTPRemoteConfig{}):    {"users": g.users(reflect.TypeOf(HTTPAccount{}))},
+		reflect.TypeOf(SocksRemoteConfig{}):   {"users": g.users(reflect.TypeOf(SocksAccount{}))},
+		reflect.TypeOf(MTProtoServerConfig{}): {"users": g.users(reflect.TypeOf(MTProtoAccount{}))},
+	}
+
+	root := g.fields(reflect.TypeOf(Config{}))
+	g.withSettings("InboundDetourConfig", inboundConfigLoader)
+	g.withSettings("OutboundDetourConfig", outboundConfigLoader)
+	root["$schema"] = "http://json-schema.org/draft-07/schema#"
+	root["title"] = "Xray config"
+	root["definitions"] = g.definitions
+	return root
+}
+
+// of returns the schema of t. Structs are defined once, and referred to.
+func (g *schemaGenerator) of(t reflect.Type) schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == rawMessageType {
+		return schema{}
+	}
+	if special, found := g.special[t]; found {
+		return g.define(t, special)
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return schema{"type": "number"}
+	case reflect.String:
+		return stringSchema
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return stringSchema
+		}
+		return schema{"type": "array", "items": g.of(t.Elem())}
+	case reflect.Map:
+		return schema{"type": "object", "additionalProperties": g.of(t.Elem())}
+	case reflect.Struct:
+		return g.define(t, func() schema { return g.fields(t) })
+	default:
+		return schema{}
+	}
+}
+
+// define adds the schema of t to the definitions, unless it is there already, and refers to it.
+func (g *schemaGenerator) define(t reflect.Type, generate func() schema) schema {
+	name := t.Name()
+	if name == "" {
+		return generate()
+	}
+	if t.PkgPath() != reflect.TypeOf(Config{}).PkgPath() {
+		name = t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:] + "." + name
+	}
+	ref := schema{"$ref": "#/definitions/" + name}
+	if _, found := g.definitions[name]; !found {
+		// placeholder for types that contain themselves
+		g.definitions[name] = schema{}
+		g.definitions[name] = generate()
+	}
+	return ref
+}
+
+// fields returns the schema of the fields of struct t, named by their json tags.
+func (g *schemaGenerator) fields(t reflect.Type) schema {
+	properties := schema{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for key, value := range g.fields(field.Type)["properties"].(schema) {
+				properties[key] = value
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if items, found := g.rawLists[t][name]; found {
+			properties[name] = schema{"type": "array", "items": items()}
+			continue
+		}
+		properties[name] = g.of(field.Type)
+	}
+	return schema{"type": "object", "properties": properties}
+}
+
+// users returns the schema of users with accounts of the given type, which are parsed from the same object.
+func (g *schemaGenerator) users(account reflect.Type) func() schema {
+	return func() schema {
+		user := g.fields(reflect.TypeOf(protocol.User{}))
+		properties := user["properties"].(schema)
+		// the account is parsed from the other fields
+		delete(properties, "account")
+		for key, value := range g.fields(account)["properties"].(schema) {
+			properties[key] = value
+		}
+		return user
+	}
+}
+
+// withSettings lets the settings of the handlers of the given definition follow their protocol.
+func (g *schemaGenerator) withSettings(name string, loader *JSONConfigLoader) {
+	definition := g.definitions[name].(schema)
+	protocols := make([]string, 0, len(loader.cache))
+	for protocol := range loader.cache {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+
+	conditions := make([]schema, 0, len(protocols))
+	for _, protocol := range protocols {
+		conditions = append(conditions, schema{
+			"if": schema{"properties": schema{loader.idKey: schema{"const": protocol}}},
+			"then": schema{"properties": schema{
+				loader.configKey: g.of(reflect.TypeOf(loader.cache[protocol]())),
+			}},
+		})
+	}
+	definition["properties"].(schema)[loader.idKey] = schema{"type": "string", "enum": protocols}
+	definition["allOf"] = conditions
+}
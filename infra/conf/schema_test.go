@@ -0,0 +1,86 @@
+package conf_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	. "github.com/xtls/xray-core/infra/conf"
+)
+
+func TestSchema(t *testing.T) {
+	b, err := json.Marshal(Schema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Properties  map[string]json.RawMessage `json:"properties"`
+		Definitions map[string]struct {
+			Type       interface{} `json:"type"`
+			Properties map[string]struct {
+				Ref   string   `json:"$ref"`
+				Enum  []string `json:"enum"`
+				Items struct {
+					Ref        string                     `json:"$ref"`
+					Properties map[string]json.RawMessage `json:"properties"`
+				} `json:"items"`
+			} `json:"properties"`
+			AnyOf []struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"anyOf"`
+		} `json:"definitions"`
+	}
+	if err := json.Unmarshal(b, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"log", "dns", "routing", "inbounds", "outbounds"} {
+		if _, found := schema.Properties[key]; !found {
+			t.Error("missing property ", key)
+		}
+	}
+	if _, found := schema.Definitions["VLessInboundConfig"]; !found {
+		t.Error("missing definition of VLessInboundConfig")
+	}
+	protocols := schema.Definitions["InboundDetourConfig"].Properties["protocol"].Enum
+	hasVLESS := false
+	for _, protocol := range protocols {
+		if protocol == "vless" {
+			hasVLESS = true
+		}
+	}
+	if !hasVLESS {
+		t.Error("vless not in inbound protocols ", protocols)
+	}
+	nameServer := schema.Definitions["NameServerConfig"].AnyOf
+	if len(nameServer) != 2 {
+		t.Fatal("unexpected name server schema ", nameServer)
+	}
+	if _, found := nameServer[1].Properties["clientIp"]; !found {
+		t.Error("missing clientIp of name server")
+	}
+
+	if r := schema.Definitions["RouterConfig"].Properties["rules"].Items.Ref; r != "#/definitions/RawFieldRule" {
+		t.Error("unexpected rule schema ", r)
+	}
+	for _, key := range []string{"outboundTag", "domain", "ip", "process"} {
+		if _, found := schema.Definitions["RawFieldRule"].Properties[key]; !found {
+			t.Error("missing property ", key, " of rules")
+		}
+	}
+	clients := schema.Definitions["VLessInboundConfig"].Properties["clients"].Items.Properties
+	for _, key := range []string{"id", "flow", "email", "level"} {
+		if _, found := clients[key]; !found {
+			t.Error("missing property ", key, " of VLESS clients")
+		}
+	}
+	if _, found := clients["account"]; found {
+		t.Error("unexpected property account of VLESS clients")
+	}
+	if r := schema.Definitions["ObservatoryConfig"].Properties["probeInterval"].Ref; r != "#/definitions/duration.Duration" {
+		t.Error("unexpected schema of probeInterval ", r)
+	}
+	if r := schema.Definitions["duration.Duration"].Type; !reflect.DeepEqual(r, []interface{}{"string", "integer"}) {
+		t.Error("unexpected schema of durations ", r)
+	}
+}
@@ -38,23 +38,65 @@ type ShadowsocksUserConfig struct {
 	Port     uint16   `json:"port"`
 }
 
+type ShadowsocksReplayProtectionConfig struct {
+	Capacity          uint32  `json:"capacity"`
+	FalsePositiveRate float64 `json:"falsePositiveRate"`
+	Slots             uint32  `json:"slots"`
+	Path              string  `json:"path"`
+}
+
+func (c *ShadowsocksReplayProtectionConfig) Build() (*shadowsocks.ReplayProtection, error) {
+	if c.FalsePositiveRate < 0 || c.FalsePositiveRate >= 1 {
+		return nil, newError("invalid falsePositiveRate: ", c.FalsePositiveRate)
+	}
+	if c.Slots > 0 && c.Capacity > 0 && c.Slots > c.Capacity {
+		return nil, newError("slots must not exceed capacity")
+	}
+	return &shadowsocks.ReplayProtection{
+		Capacity:          c.Capacity,
+		FalsePositiveRate: c.FalsePositiveRate,
+		Slots:             c.Slots,
+		Path:              c.Path,
+	}, nil
+}
+
 type ShadowsocksServerConfig struct {
-	Cipher      string                   `json:"method"`
-	Password    string                   `json:"password"`
-	Level       byte                     `json:"level"`
-	Email       string                   `json:"email"`
-	Users       []*ShadowsocksUserConfig `json:"clients"`
-	NetworkList *NetworkList             `json:"network"`
-	IVCheck     bool                     `json:"ivCheck"`
+	Cipher           string                             `json:"method"`
+	Password         string                             `json:"password"`
+	Level            byte                               `json:"level"`
+	Email            string                             `json:"email"`
+	Users            []*ShadowsocksUserConfig           `json:"clients"`
+	NetworkList      *NetworkList                       `json:"network"`
+	IVCheck          bool                               `json:"ivCheck"`
+	ReplayProtection *ShadowsocksReplayProtectionConfig `json:"replayProtection"`
+	UoT              bool                               `json:"uot"`
+	Decoy            *DecoyConfig                       `json:"decoy"`
 }
 
 func (v *ShadowsocksServerConfig) Build() (proto.Message, error) {
 	if C.Contains(shadowaead_2022.List, v.Cipher) {
+		if v.Decoy != nil {
+			return nil, newError("decoy is not supported by Shadowsocks 2022")
+		}
 		return buildShadowsocks2022(v)
 	}
 
 	config := new(shadowsocks.ServerConfig)
 	config.Network = v.NetworkList.Build()
+	config.UdpOverTcp = v.UoT
+	if v.ReplayProtection != nil {
+		rp, err := v.ReplayProtection.Build()
+		if err != nil {
+			return nil, newError("invalid replay protection").Base(err)
+		}
+		config.ReplayProtection = rp
+	}
+	if v.Decoy != nil {
+		var err error
+		if config.Decoy, err = v.Decoy.Build(); err != nil {
+			return nil, err
+		}
+	}
 
 	if v.Users != nil {
 		for _, user := range v.Users {
@@ -221,6 +263,7 @@ func (v *ShadowsocksClientConfig) Build() (proto.Message, error) {
 		}
 
 		account.IvCheck = server.IVCheck
+		account.UdpOverTcp = server.UoT
 
 		ss := &protocol.ServerEndpoint{
 			Address: server.Address.Build(),
@@ -7,6 +7,7 @@ import (
 	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/serial"
 	. "github.com/xtls/xray-core/infra/conf"
+	"github.com/xtls/xray-core/proxy/decoy"
 	"github.com/xtls/xray-core/proxy/shadowsocks"
 )
 
@@ -32,5 +33,64 @@ func TestShadowsocksServerConfigParsing(t *testing.T) {
 				Network: []net.Network{net.Network_TCP},
 			},
 		},
+		{
+			Input: `{
+				"method": "aes-128-gcm",
+				"password": "xray-password",
+				"replayProtection": {
+					"capacity": 100000,
+					"falsePositiveRate": 0.0001,
+					"path": "/var/lib/xray/ss-salts"
+				}
+			}`,
+			Parser: loadJSON(creator),
+			Output: &shadowsocks.ServerConfig{
+				Users: []*protocol.User{{
+					Account: serial.ToTypedMessage(&shadowsocks.Account{
+						CipherType: shadowsocks.CipherType_AES_128_GCM,
+						Password:   "xray-password",
+					}),
+				}},
+				Network: []net.Network{net.Network_TCP},
+				ReplayProtection: &shadowsocks.ReplayProtection{
+					Capacity:          100000,
+					FalsePositiveRate: 0.0001,
+					Path:              "/var/lib/xray/ss-salts",
+				},
+			},
+		},
+		{
+			Input: `{
+				"method": "aes-128-gcm",
+				"password": "xray-password",
+				"decoy": {
+					"dest": 8080,
+					"xver": 1
+				}
+			}`,
+			Parser: loadJSON(creator),
+			Output: &shadowsocks.ServerConfig{
+				Users: []*protocol.User{{
+					Account: serial.ToTypedMessage(&shadowsocks.Account{
+						CipherType: shadowsocks.CipherType_AES_128_GCM,
+						Password:   "xray-password",
+					}),
+				}},
+				Network: []net.Network{net.Network_TCP},
+				Decoy: &decoy.Config{
+					Type: "tcp",
+					Dest: "127.0.0.1:8080",
+					Xver: 1,
+				},
+			},
+		},
 	})
+
+	if _, err := loadJSON(creator)(`{
+		"method": "2022-blake3-aes-128-gcm",
+		"password": "AAAAAAAAAAAAAAAAAAAAAA==",
+		"decoy": {"dest": 8080}
+	}`); err == nil {
+		t.Error("expected decoy to be rejected for Shadowsocks 2022")
+	}
 }
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/serial"
 	"github.com/xtls/xray-core/proxy/socks"
@@ -31,6 +32,7 @@ type SocksServerConfig struct {
 	AuthMethod string          `json:"auth"`
 	Accounts   []*SocksAccount `json:"accounts"`
 	UDP        bool            `json:"udp"`
+	Network    *NetworkList    `json:"network"`
 	Host       *Address        `json:"ip"`
 	Timeout    uint32          `json:"timeout"`
 	UserLevel  uint32          `json:"userLevel"`
@@ -56,6 +58,14 @@ func (v *SocksServerConfig) Build() (proto.Message, error) {
 	}
 
 	config.UdpEnabled = v.UDP
+	if v.Network != nil {
+		// the UDP associate of SOCKS5 is made over TCP, so TCP is always needed
+		networks := v.Network.Build()
+		if !net.HasNetwork(networks, net.Network_TCP) {
+			return nil, newError(`socks "network" must include tcp`)
+		}
+		config.UdpEnabled = config.UdpEnabled || net.HasNetwork(networks, net.Network_UDP)
+	}
 	if v.Host != nil {
 		config.Address = v.Host.Build()
 	}
@@ -46,6 +46,16 @@ func TestSocksInboundConfig(t *testing.T) {
 				UserLevel: 1,
 			},
 		},
+		{
+			Input: `{
+				"network": "tcp,udp"
+			}`,
+			Parser: loadJSON(creator),
+			Output: &socks.ServerConfig{
+				AuthType:   socks.AuthType_NO_AUTH,
+				UdpEnabled: true,
+			},
+		},
 	})
 }
 
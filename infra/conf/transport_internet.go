@@ -21,6 +21,7 @@ import (
 	httpheader "github.com/xtls/xray-core/transport/internet/headers/http"
 	"github.com/xtls/xray-core/transport/internet/http"
 	"github.com/xtls/xray-core/transport/internet/kcp"
+	"github.com/xtls/xray-core/transport/internet/plugin"
 	"github.com/xtls/xray-core/transport/internet/quic"
 	"github.com/xtls/xray-core/transport/internet/reality"
 	"github.com/xtls/xray-core/transport/internet/tcp"
@@ -146,8 +147,12 @@ func (c *TCPConfig) Build() (proto.Message, error) {
 
 type WebSocketConfig struct {
 	Path                string            `json:"path"`
+	Host                string            `json:"host"`
 	Headers             map[string]string `json:"headers"`
+	DialAddress         string            `json:"dialAddress"`
 	AcceptProxyProtocol bool              `json:"acceptProxyProtocol"`
+	MaxEarlyData        uint32            `json:"maxEarlyData"`
+	EarlyDataHeaderName string            `json:"earlyDataHeaderName"`
 }
 
 // Build implements Buildable.
@@ -170,10 +175,16 @@ func (c *WebSocketConfig) Build() (proto.Message, error) {
 			path = u.String()
 		}
 	}
+	if c.MaxEarlyData > 0 {
+		ed = c.MaxEarlyData
+	}
 	config := &websocket.Config{
-		Path:   path,
-		Header: header,
-		Ed:     ed,
+		Path:                path,
+		Header:              header,
+		Ed:                  ed,
+		Host:                c.Host,
+		DialAddress:         c.DialAddress,
+		EarlyDataHeaderName: c.EarlyDataHeaderName,
 	}
 	if c.AcceptProxyProtocol {
 		config.AcceptProxyProtocol = c.AcceptProxyProtocol
@@ -182,26 +193,61 @@ func (c *WebSocketConfig) Build() (proto.Message, error) {
 }
 
 type HTTPConfig struct {
-	Host               *StringList            `json:"host"`
-	Path               string                 `json:"path"`
-	ReadIdleTimeout    int32                  `json:"read_idle_timeout"`
-	HealthCheckTimeout int32                  `json:"health_check_timeout"`
-	Method             string                 `json:"method"`
-	Headers            map[string]*StringList `json:"headers"`
+	Host                *StringList            `json:"host"`
+	Path                string                 `json:"path"`
+	PingInterval        int32                  `json:"pingInterval"`
+	HealthCheckTimeout  int32                  `json:"healthCheckTimeout"`
+	Method              string                 `json:"method"`
+	Headers             map[string]*StringList `json:"headers"`
+	Mode                string                 `json:"mode"`
+	DownlinkStreams     uint32                 `json:"downlinkStreams"`
+	DialAddress         string                 `json:"dialAddress"`
+	MaxUploadSize       uint64                 `json:"maxUploadSize"`
+	AcceptProxyProtocol bool                   `json:"acceptProxyProtocol"`
+	ResponseHeaders     map[string]*StringList `json:"responseHeaders"`
+	ResponseStatus      uint32                 `json:"responseStatus"`
+
+	// Deprecated: use PingInterval and HealthCheckTimeout.
+	ReadIdleTimeout          int32 `json:"read_idle_timeout"`
+	LegacyHealthCheckTimeout int32 `json:"health_check_timeout"`
+}
+
+func buildHTTPHeaders(headers map[string]*StringList) ([]*httpheader.Header, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	result := make([]*httpheader.Header, 0, len(headers))
+	for _, key := range sortMapKeys(headers) {
+		value := headers[key]
+		if value == nil {
+			return nil, newError("empty HTTP header value: " + key).AtError()
+		}
+		result = append(result, &httpheader.Header{
+			Name:  key,
+			Value: append([]string(nil), (*value)...),
+		})
+	}
+	return result, nil
 }
 
 // Build implements Buildable.
 func (c *HTTPConfig) Build() (proto.Message, error) {
-	if c.ReadIdleTimeout <= 0 {
-		c.ReadIdleTimeout = 0
+	if c.PingInterval == 0 {
+		c.PingInterval = c.ReadIdleTimeout
+	}
+	if c.HealthCheckTimeout == 0 {
+		c.HealthCheckTimeout = c.LegacyHealthCheckTimeout
 	}
-	if c.HealthCheckTimeout <= 0 {
-		c.HealthCheckTimeout = 0
+	if c.PingInterval < 0 || c.HealthCheckTimeout < 0 {
+		return nil, newError("pingInterval and healthCheckTimeout must not be negative").AtError()
 	}
 	config := &http.Config{
-		Path:               c.Path,
-		IdleTimeout:        c.ReadIdleTimeout,
-		HealthCheckTimeout: c.HealthCheckTimeout,
+		Path:                c.Path,
+		IdleTimeout:         c.PingInterval,
+		HealthCheckTimeout:  c.HealthCheckTimeout,
+		DialAddress:         c.DialAddress,
+		MaxUploadSize:       c.MaxUploadSize,
+		AcceptProxyProtocol: c.AcceptProxyProtocol,
 	}
 	if c.Host != nil {
 		config.Host = []string(*c.Host)
@@ -209,19 +255,35 @@ func (c *HTTPConfig) Build() (proto.Message, error) {
 	if c.Method != "" {
 		config.Method = c.Method
 	}
-	if len(c.Headers) > 0 {
-		config.Header = make([]*httpheader.Header, 0, len(c.Headers))
-		headerNames := sortMapKeys(c.Headers)
-		for _, key := range headerNames {
-			value := c.Headers[key]
-			if value == nil {
-				return nil, newError("empty HTTP header value: " + key).AtError()
-			}
-			config.Header = append(config.Header, &httpheader.Header{
-				Name:  key,
-				Value: append([]string(nil), (*value)...),
-			})
+	switch strings.ToLower(c.Mode) {
+	case "", "h2":
+		config.Mode = http.Config_H2
+	case "auto":
+		config.Mode = http.Config_AUTO
+	case "http/1.1", "http1":
+		config.Mode = http.Config_HTTP1
+	case "h3", "http/3":
+		config.Mode = http.Config_H3
+	default:
+		return nil, newError("unknown http mode: ", c.Mode).AtError()
+	}
+	if c.DownlinkStreams > 16 {
+		return nil, newError("downlinkStreams must not exceed 16").AtError()
+	}
+	config.DownlinkStreams = c.DownlinkStreams
+	var err error
+	if config.Header, err = buildHTTPHeaders(c.Headers); err != nil {
+		return nil, err
+	}
+	if config.ResponseHeader, err = buildHTTPHeaders(c.ResponseHeaders); err != nil {
+		return nil, err
+	}
+	if c.ResponseStatus != 0 {
+		// the status has to allow a body, which carries the stream
+		if c.ResponseStatus < 200 || c.ResponseStatus > 299 || c.ResponseStatus == 204 || c.ResponseStatus == 205 {
+			return nil, newError("responseStatus must be a 2xx status allowing a body: ", c.ResponseStatus).AtError()
 		}
+		config.ResponseStatus = c.ResponseStatus
 	}
 	return config, nil
 }
@@ -300,6 +362,7 @@ type TLSCertConfig struct {
 	Usage          string   `json:"usage"`
 	OcspStapling   uint64   `json:"ocspStapling"`
 	OneTimeLoading bool     `json:"oneTimeLoading"`
+	ReloadInterval uint32   `json:"reloadInterval"`
 }
 
 // Build implements Buildable.
@@ -338,6 +401,7 @@ func (c *TLSCertConfig) Build() (*tls.Certificate, error) {
 		certificate.OneTimeLoading = c.OneTimeLoading
 	}
 	certificate.OcspStapling = c.OcspStapling
+	certificate.ReloadInterval = c.ReloadInterval
 
 	return certificate, nil
 }
@@ -356,6 +420,13 @@ type TLSConfig struct {
 	Fingerprint                      string           `json:"fingerprint"`
 	RejectUnknownSNI                 bool             `json:"rejectUnknownSni"`
 	PinnedPeerCertificateChainSha256 *[]string        `json:"pinnedPeerCertificateChainSha256"`
+	SessionTicketKeys                []string         `json:"sessionTicketKeys"`
+	SessionTicketKeyFile             string           `json:"sessionTicketKeyFile"`
+	SessionTicketKeyRotation         uint64           `json:"sessionTicketKeyRotation"`
+	SessionCacheSize                 uint32           `json:"sessionCacheSize"`
+	SessionCacheTTL                  uint32           `json:"sessionCacheTtl"`
+	SessionTicketsDisabled           *bool            `json:"sessionTicketsDisabled"`
+	UseHTTPSRecord                   bool             `json:"useHttpsRecord"`
 }
 
 // Build implements Buildable.
@@ -378,6 +449,13 @@ func (c *TLSConfig) Build() (proto.Message, error) {
 		config.NextProtocol = []string(*c.ALPN)
 	}
 	config.EnableSessionResumption = c.EnableSessionResumption
+	if c.SessionTicketsDisabled != nil {
+		// sessionTicketsDisabled is about the server side only, the client session cache stays with enableSessionResumption
+		if *c.SessionTicketsDisabled && c.EnableSessionResumption {
+			return nil, newError("sessionTicketsDisabled conflicts with enableSessionResumption")
+		}
+		config.EnableSessionTickets = !*c.SessionTicketsDisabled
+	}
 	config.DisableSystemRoot = c.DisableSystemRoot
 	config.MinVersion = c.MinVersion
 	config.MaxVersion = c.MaxVersion
@@ -400,6 +478,28 @@ func (c *TLSConfig) Build() (proto.Message, error) {
 		}
 	}
 
+	for _, v := range c.SessionTicketKeys {
+		key, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, newError("invalid session ticket key: ", v).Base(err)
+		}
+		if _, err := tls.ParseSessionTicketKeys(key); err != nil {
+			return nil, err
+		}
+		config.SessionTicketKey = append(config.SessionTicketKey, key)
+	}
+	config.SessionTicketKeyPath = c.SessionTicketKeyFile
+	config.SessionTicketKeyRotation = c.SessionTicketKeyRotation
+	config.SessionCacheSize = c.SessionCacheSize
+	config.SessionCacheTtl = c.SessionCacheTTL
+	if !config.EnableSessionResumption && !config.EnableSessionTickets && (len(config.SessionTicketKey) > 0 || config.SessionTicketKeyPath != "" || config.SessionTicketKeyRotation > 0) {
+		newError("session ticket keys take no effect while session tickets are disabled").AtWarning().WriteToLog()
+	}
+	if !config.EnableSessionResumption && (config.SessionCacheSize > 0 || config.SessionCacheTtl > 0) {
+		newError("session cache settings take no effect without enableSessionResumption").AtWarning().WriteToLog()
+	}
+	config.UseHttpsRecord = c.UseHTTPSRecord
+
 	return config, nil
 }
 
@@ -628,7 +728,7 @@ func (c *REALITYConfig) Build() (proto.Message, error) {
 		if config.Fingerprint = strings.ToLower(c.Fingerprint); tls.GetFingerprint(config.Fingerprint) == nil {
 			return nil, newError(`unknown "fingerprint": `, config.Fingerprint)
 		}
-		if config.Fingerprint == "hellogolang" {
+		if config.Fingerprint == "hellogolang" || strings.HasSuffix(config.Fingerprint, tls.BehaviorSuffix) {
 			return nil, newError(`invalid "fingerprint": `, config.Fingerprint)
 		}
 		if c.PublicKey == "" {
@@ -677,7 +777,22 @@ func (c *REALITYConfig) Build() (proto.Message, error) {
 
 type TransportProtocol string
 
+type PluginConfig struct {
+	Address  string `json:"address"`
+	Settings string `json:"settings"`
+}
+
 // Build implements Buildable.
+func (c *PluginConfig) Build() (proto.Message, error) {
+	if c.Address == "" {
+		return nil, newError("plugin address is not set")
+	}
+	return &plugin.Config{
+		Address:  c.Address,
+		Settings: c.Settings,
+	}, nil
+}
+
 func (p TransportProtocol) Build() (string, error) {
 	switch strings.ToLower(string(p)) {
 	case "tcp":
@@ -694,6 +809,8 @@ func (p TransportProtocol) Build() (string, error) {
 		return "quic", nil
 	case "grpc", "gun":
 		return "grpc", nil
+	case "plugin":
+		return "plugin", nil
 	default:
 		return "", newError("Config: unknown transport protocol: ", p)
 	}
@@ -710,6 +827,13 @@ type SocketConfig struct {
 	TCPKeepAliveIdle     int32       `json:"tcpKeepAliveIdle"`
 	TCPCongestion        string      `json:"tcpCongestion"`
 	Interface            string      `json:"interface"`
+	TCPMaxSeg            int32       `json:"tcpMaxSeg"`
+	UDPMaxPayload        int32       `json:"udpMaxPayload"`
+	UDPSourcePort        string      `json:"udpSourcePort"`
+	DSCP                 int32       `json:"dscp"`
+	IPv6TrafficClass     int32       `json:"ipv6TrafficClass"`
+	IPv6FlowLabel        uint32      `json:"ipv6FlowLabel"`
+	ResolveCacheTTL      uint32      `json:"resolveCacheTtl"`
 }
 
 // Build implements Buildable.
@@ -749,6 +873,32 @@ func (c *SocketConfig) Build() (*internet.SocketConfig, error) {
 		dStrategy = internet.DomainStrategy_USE_IP6
 	}
 
+	if c.TCPMaxSeg < 0 || c.UDPMaxPayload < 0 {
+		return nil, newError("tcpMaxSeg and udpMaxPayload must not be negative")
+	}
+
+	if c.DSCP < 0 || c.DSCP > 63 {
+		return nil, newError("dscp must be from 0 to 63")
+	}
+
+	if c.IPv6TrafficClass < 0 || c.IPv6TrafficClass > 255 {
+		return nil, newError("ipv6TrafficClass must be from 0 to 255")
+	}
+
+	if c.IPv6FlowLabel > 0xFFFFF {
+		return nil, newError("ipv6FlowLabel must be from 0 to 0xFFFFF")
+	}
+
+	var udpSourcePort internet.SocketConfig_UdpSourcePort
+	switch strings.ToLower(c.UDPSourcePort) {
+	case "", "shared":
+		udpSourcePort = internet.SocketConfig_Shared
+	case "perdestination", "per_destination":
+		udpSourcePort = internet.SocketConfig_PerDestination
+	default:
+		return nil, newError("unknown udpSourcePort: ", c.UDPSourcePort)
+	}
+
 	return &internet.SocketConfig{
 		Mark:                 c.Mark,
 		Tfo:                  tfo,
@@ -760,6 +910,13 @@ func (c *SocketConfig) Build() (*internet.SocketConfig, error) {
 		TcpKeepAliveIdle:     c.TCPKeepAliveIdle,
 		TcpCongestion:        c.TCPCongestion,
 		Interface:            c.Interface,
+		TcpMaxSeg:            c.TCPMaxSeg,
+		UdpMaxPayload:        c.UDPMaxPayload,
+		UdpSourcePort:        udpSourcePort,
+		Dscp:                 c.DSCP,
+		Ipv6TrafficClass:     c.IPv6TrafficClass,
+		Ipv6FlowLabel:        c.IPv6FlowLabel,
+		ResolveCacheTtl:      c.ResolveCacheTTL,
 	}, nil
 }
 
@@ -778,6 +935,7 @@ type StreamConfig struct {
 	SocketSettings  *SocketConfig       `json:"sockopt"`
 	GRPCConfig      *GRPCConfig         `json:"grpcSettings"`
 	GUNConfig       *GRPCConfig         `json:"gunSettings"`
+	PluginSettings  *PluginConfig       `json:"pluginSettings"`
 }
 
 // Build implements Buildable.
@@ -915,6 +1073,16 @@ func (c *StreamConfig) Build() (*internet.StreamConfig, error) {
 			Settings:     serial.ToTypedMessage(gs),
 		})
 	}
+	if c.PluginSettings != nil {
+		ps, err := c.PluginSettings.Build()
+		if err != nil {
+			return nil, newError("Failed to build plugin config.").Base(err)
+		}
+		config.TransportSettings = append(config.TransportSettings, &internet.TransportConfig{
+			ProtocolName: "plugin",
+			Settings:     serial.ToTypedMessage(ps),
+		})
+	}
 	if c.SocketSettings != nil {
 		ss, err := c.SocketSettings.Build()
 		if err != nil {
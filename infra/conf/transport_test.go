@@ -17,6 +17,7 @@ import (
 	"github.com/xtls/xray-core/transport/internet/kcp"
 	"github.com/xtls/xray-core/transport/internet/quic"
 	"github.com/xtls/xray-core/transport/internet/tcp"
+	v2tls "github.com/xtls/xray-core/transport/internet/tls"
 	"github.com/xtls/xray-core/transport/internet/websocket"
 )
 
@@ -312,3 +313,55 @@ func TestTransportConfig(t *testing.T) {
 		},
 	})
 }
+
+func TestTLSSessionTicketsDisabled(t *testing.T) {
+	createParser := func() func(string) (proto.Message, error) {
+		return func(s string) (proto.Message, error) {
+			config := new(TLSConfig)
+			if err := json.Unmarshal([]byte(s), config); err != nil {
+				return nil, err
+			}
+			return config.Build()
+		}
+	}
+
+	runMultiTestCase(t, []TestCase{
+		{
+			Input: `{
+				"sessionTicketsDisabled": false,
+				"sessionTicketKeyRotation": 3600
+			}`,
+			Parser: createParser(),
+			Output: &v2tls.Config{
+				Certificate:              []*v2tls.Certificate{},
+				EnableSessionTickets:     true,
+				SessionTicketKeyRotation: 3600,
+			},
+		},
+		{
+			Input: `{
+				"enableSessionResumption": true,
+				"sessionTicketsDisabled": false,
+				"sessionCacheSize": 64
+			}`,
+			Parser: createParser(),
+			Output: &v2tls.Config{
+				Certificate:             []*v2tls.Certificate{},
+				EnableSessionResumption: true,
+				EnableSessionTickets:    true,
+				SessionCacheSize:        64,
+			},
+		},
+		{
+			Input:  `{"sessionTicketsDisabled": true}`,
+			Parser: createParser(),
+			Output: &v2tls.Config{
+				Certificate: []*v2tls.Certificate{},
+			},
+		},
+	})
+
+	if _, err := createParser()(`{"enableSessionResumption": true, "sessionTicketsDisabled": true}`); err == nil {
+		t.Error("expected conflicting options to fail")
+	}
+}
@@ -25,7 +25,8 @@ type TrojanServerTarget struct {
 
 // TrojanClientConfig is configuration of trojan servers
 type TrojanClientConfig struct {
-	Servers []*TrojanServerTarget `json:"servers"`
+	Servers        []*TrojanServerTarget `json:"servers"`
+	PacketEncoding string                `json:"packetEncoding"`
 }
 
 // Build implements Buildable
@@ -35,6 +36,11 @@ func (c *TrojanClientConfig) Build() (proto.Message, error) {
 	if len(c.Servers) == 0 {
 		return nil, newError("0 Trojan server configured.")
 	}
+	packetEncoding, err := parsePacketEncoding(c.PacketEncoding)
+	if err != nil {
+		return nil, newError("Trojan settings: ").Base(err)
+	}
+	config.PacketEncoding = packetEncoding
 
 	serverSpecs := make([]*protocol.ServerEndpoint, len(c.Servers))
 	for idx, rec := range c.Servers {
@@ -102,14 +108,16 @@ type TrojanUserConfig struct {
 
 // TrojanServerConfig is Inbound configuration
 type TrojanServerConfig struct {
-	Clients   []*TrojanUserConfig      `json:"clients"`
-	Fallback  *TrojanInboundFallback   `json:"fallback"`
-	Fallbacks []*TrojanInboundFallback `json:"fallbacks"`
+	Clients      []*TrojanUserConfig      `json:"clients"`
+	Fallback     *TrojanInboundFallback   `json:"fallback"`
+	Fallbacks    []*TrojanInboundFallback `json:"fallbacks"`
+	ExternalAuth bool                     `json:"externalAuth"`
 }
 
 // Build implements Buildable
 func (c *TrojanServerConfig) Build() (proto.Message, error) {
 	config := new(trojan.ServerConfig)
+	config.ExternalAuth = c.ExternalAuth
 	config.Users = make([]*protocol.User, len(c.Clients))
 	for idx, rawUser := range c.Clients {
 		user := new(protocol.User)
@@ -0,0 +1,29 @@
+package conf
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/xtls/xray-core/app/userstore"
+)
+
+type UserStoreConfig struct {
+	Backend      string     `json:"backend"`
+	Address      string     `json:"address"`
+	Password     string     `json:"password"`
+	Database     uint32     `json:"database"`
+	InboundTags  StringList `json:"inboundTags"`
+	SyncInterval uint32     `json:"syncInterval"`
+}
+
+func (c *UserStoreConfig) Build() (proto.Message, error) {
+	if c.Backend == "" {
+		return nil, newError("user store requires a backend")
+	}
+	return &userstore.Config{
+		Backend:      c.Backend,
+		Address:      c.Address,
+		Password:     c.Password,
+		Database:     c.Database,
+		InboundTag:   c.InboundTags,
+		SyncInterval: c.SyncInterval,
+	}, nil
+}
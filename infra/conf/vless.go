@@ -12,11 +12,26 @@ import (
 	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/serial"
 	"github.com/xtls/xray-core/common/uuid"
+	"github.com/xtls/xray-core/common/xudp"
 	"github.com/xtls/xray-core/proxy/vless"
+	"github.com/xtls/xray-core/proxy/vless/encoding"
 	"github.com/xtls/xray-core/proxy/vless/inbound"
 	"github.com/xtls/xray-core/proxy/vless/outbound"
 )
 
+func checkVLessTransform(account *vless.Account) error {
+	if account.Transform == "" {
+		return nil
+	}
+	if _, found := encoding.GetTransform(account.Transform); !found {
+		return newError(`unknown "transform" "` + account.Transform + `"`)
+	}
+	if account.Flow != "" {
+		return newError(`"transform" can't be used with "flow"`)
+	}
+	return nil
+}
+
 type VLessInboundFallback struct {
 	Name string          `json:"name"`
 	Alpn string          `json:"alpn"`
@@ -27,15 +42,17 @@ type VLessInboundFallback struct {
 }
 
 type VLessInboundConfig struct {
-	Clients    []json.RawMessage       `json:"clients"`
-	Decryption string                  `json:"decryption"`
-	Fallback   *VLessInboundFallback   `json:"fallback"`
-	Fallbacks  []*VLessInboundFallback `json:"fallbacks"`
+	Clients      []json.RawMessage       `json:"clients"`
+	Decryption   string                  `json:"decryption"`
+	Fallback     *VLessInboundFallback   `json:"fallback"`
+	Fallbacks    []*VLessInboundFallback `json:"fallbacks"`
+	ExternalAuth bool                    `json:"externalAuth"`
 }
 
 // Build implements Buildable
 func (c *VLessInboundConfig) Build() (proto.Message, error) {
 	config := new(inbound.Config)
+	config.ExternalAuth = c.ExternalAuth
 	config.Clients = make([]*protocol.User, len(c.Clients))
 	for idx, rawUser := range c.Clients {
 		user := new(protocol.User)
@@ -73,6 +90,10 @@ func (c *VLessInboundConfig) Build() (proto.Message, error) {
 			return nil, newError(`VLESS clients: "encryption" should not in inbound settings`)
 		}
 
+		if err := checkVLessTransform(account); err != nil {
+			return nil, newError(`VLESS clients: `).Base(err)
+		}
+
 		user.Account = serial.ToTypedMessage(account)
 		config.Clients[idx] = user
 	}
@@ -151,7 +172,22 @@ type VLessOutboundVnext struct {
 }
 
 type VLessOutboundConfig struct {
-	Vnext []*VLessOutboundVnext `json:"vnext"`
+	Vnext          []*VLessOutboundVnext `json:"vnext"`
+	PacketEncoding string                `json:"packetEncoding"`
+}
+
+// parsePacketEncoding parses the "packetEncoding" of an outbound.
+func parsePacketEncoding(s string) (xudp.PacketEncoding, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return xudp.PacketEncoding_Default, nil
+	case "none":
+		return xudp.PacketEncoding_None, nil
+	case "xudp":
+		return xudp.PacketEncoding_XUDP, nil
+	default:
+		return xudp.PacketEncoding_Default, newError(`unknown "packetEncoding": `, s)
+	}
 }
 
 // Build implements Buildable
@@ -161,6 +197,11 @@ func (c *VLessOutboundConfig) Build() (proto.Message, error) {
 	if len(c.Vnext) == 0 {
 		return nil, newError(`VLESS settings: "vnext" is empty`)
 	}
+	packetEncoding, err := parsePacketEncoding(c.PacketEncoding)
+	if err != nil {
+		return nil, newError(`VLESS settings: `).Base(err)
+	}
+	config.PacketEncoding = packetEncoding
 	config.Vnext = make([]*protocol.ServerEndpoint, len(c.Vnext))
 	for idx, rec := range c.Vnext {
 		if rec.Address == nil {
@@ -204,6 +245,10 @@ func (c *VLessOutboundConfig) Build() (proto.Message, error) {
 				return nil, newError(`VLESS users: please add/set "encryption":"none" for every user`)
 			}
 
+			if err := checkVLessTransform(account); err != nil {
+				return nil, newError(`VLESS users: `).Base(err)
+			}
+
 			user.Account = serial.ToTypedMessage(account)
 			spec.User[idx] = user
 		}
@@ -6,6 +6,7 @@ import (
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/common/xudp"
 	. "github.com/xtls/xray-core/infra/conf"
 	"github.com/xtls/xray-core/proxy/vless"
 	"github.com/xtls/xray-core/proxy/vless/inbound"
@@ -31,10 +32,12 @@ func TestVLessOutbound(t *testing.T) {
 							"level": 0
 						}
 					]
-				}]
+				}],
+				"packetEncoding": "xudp"
 			}`,
 			Parser: loadJSON(creator),
 			Output: &outbound.Config{
+				PacketEncoding: xudp.PacketEncoding_XUDP,
 				Vnext: []*protocol.ServerEndpoint{
 					{
 						Address: &net.IPOrDomain{
@@ -130,5 +133,18 @@ func TestVLessInbound(t *testing.T) {
 				},
 			},
 		},
+		{
+			Input: `{
+				"clients": [],
+				"decryption": "none",
+				"externalAuth": true
+			}`,
+			Parser: loadJSON(creator),
+			Output: &inbound.Config{
+				Clients:      []*protocol.User{},
+				Decryption:   "none",
+				ExternalAuth: true,
+			},
+		},
 	})
 }
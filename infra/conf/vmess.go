@@ -81,18 +81,28 @@ type VMessInboundConfig struct {
 	Defaults     *VMessDefaultConfig `json:"default"`
 	DetourConfig *VMessDetourConfig  `json:"detour"`
 	SecureOnly   bool                `json:"disableInsecureEncryption"`
+	Tolerance    uint32              `json:"timestampTolerance"`
+	Decoy        *DecoyConfig        `json:"decoy"`
 }
 
 // Build implements Buildable
 func (c *VMessInboundConfig) Build() (proto.Message, error) {
 	config := &inbound.Config{
 		SecureEncryptionOnly: c.SecureOnly,
+		TimestampTolerance:   c.Tolerance,
 	}
 
 	if c.Defaults != nil {
 		config.Default = c.Defaults.Build()
 	}
 
+	if c.Decoy != nil {
+		var err error
+		if config.Decoy, err = c.Decoy.Build(); err != nil {
+			return nil, err
+		}
+	}
+
 	if c.DetourConfig != nil {
 		config.Detour = c.DetourConfig.Build()
 	} else if c.Features != nil && c.Features.Detour != nil {
@@ -0,0 +1,34 @@
+package conf
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/xtls/xray-core/app/watchdog"
+)
+
+type WatchdogConfig struct {
+	MaxHeap          uint32 `json:"maxHeap"`
+	MaxConnections   uint32 `json:"maxConnections"`
+	CheckInterval    uint32 `json:"checkInterval"`
+	CloseIdle        bool   `json:"closeIdle"`
+	ReleaseHeap      uint32 `json:"releaseHeap"`
+	ReleaseNearLimit bool   `json:"releaseNearLimit"`
+}
+
+func (c *WatchdogConfig) Build() (proto.Message, error) {
+	if c.CloseIdle && c.MaxHeap == 0 {
+		return nil, newError("watchdog: closeIdle requires maxHeap")
+	}
+	return &watchdog.Config{
+		MaxHeap:          c.MaxHeap,
+		MaxConnections:   c.MaxConnections,
+		CheckInterval:    c.CheckInterval,
+		CloseIdle:        c.CloseIdle,
+		ReleaseHeap:      c.ReleaseHeap,
+		ReleaseNearLimit: c.ReleaseNearLimit,
+	}, nil
+}
+
+func init() {
+	// geodata cached while loading configs through the API is not needed once they are built
+	watchdog.RegisterReleaseFunc(ResetGeoCache)
+}
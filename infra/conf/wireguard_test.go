@@ -24,7 +24,8 @@ func TestWireGuardOutbound(t *testing.T) {
 					}
 				],
 				"mtu": 1300,
-				"workers": 2
+				"workers": 2,
+				"reserved": [1, 2, 3]
 			}`,
 			Parser: loadJSON(creator),
 			Output: &wireguard.DeviceConfig{
@@ -43,6 +44,7 @@ func TestWireGuardOutbound(t *testing.T) {
 				},
 				Mtu:        1300,
 				NumWorkers: 2,
+				Reserved:   []byte{1, 2, 3},
 			},
 		},
 	})
@@ -5,13 +5,18 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/xtls/xray-core/app/dispatcher"
 	"github.com/xtls/xray-core/app/proxyman"
 	"github.com/xtls/xray-core/app/stats"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
 	"github.com/xtls/xray-core/common/serial"
 	core "github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/proxy/trojan"
+	vlessoutbound "github.com/xtls/xray-core/proxy/vless/outbound"
 	"github.com/xtls/xray-core/transport/internet"
 	"github.com/xtls/xray-core/transport/internet/xtls"
 )
@@ -86,7 +91,10 @@ func (c *SniffingConfig) Build() (*proxyman.SniffingConfig, error) {
 			case "fakedns+others":
 				p = append(p, "fakedns+others")
 			default:
-				return nil, newError("unknown protocol: ", protocol)
+				if !dispatcher.IsSnifferRegistered(strings.ToLower(protocol)) {
+					return nil, newError("unknown protocol: ", protocol)
+				}
+				p = append(p, strings.ToLower(protocol))
 			}
 		}
 	}
@@ -94,7 +102,7 @@ func (c *SniffingConfig) Build() (*proxyman.SniffingConfig, error) {
 	var d []string
 	if c.DomainsExcluded != nil {
 		for _, domain := range *c.DomainsExcluded {
-			d = append(d, strings.ToLower(domain))
+			d = append(d, strings.ToLower(net.NormalizeDomain(domain)))
 		}
 	}
 
@@ -108,8 +116,14 @@ func (c *SniffingConfig) Build() (*proxyman.SniffingConfig, error) {
 }
 
 type MuxConfig struct {
-	Enabled     bool  `json:"enabled"`
-	Concurrency int16 `json:"concurrency"`
+	Enabled       bool         `json:"enabled"`
+	Concurrency   int16        `json:"concurrency"`
+	Network       *NetworkList `json:"network"`
+	BypassPorts   *PortList    `json:"bypassPorts"`
+	BypassDomains StringList   `json:"bypassDomains"`
+	BypassQUIC    bool         `json:"bypassQuic"`
+	Resumable     bool         `json:"resumable"`
+	Coalesce      bool         `json:"coalesce"`
 }
 
 // Build creates MultiplexingConfig, Concurrency < 0 completely disables mux.
@@ -123,10 +137,23 @@ func (m *MuxConfig) Build() *proxyman.MultiplexingConfig {
 		con = uint32(m.Concurrency)
 	}
 
-	return &proxyman.MultiplexingConfig{
+	config := &proxyman.MultiplexingConfig{
 		Enabled:     m.Enabled,
 		Concurrency: con,
+		BypassQuic:  m.BypassQUIC,
+		Resumable:   m.Resumable,
+		Coalesce:    m.Coalesce,
+	}
+	if m.Network != nil {
+		config.Networks = m.Network.Build()
+	}
+	if m.BypassPorts != nil {
+		config.BypassPorts = m.BypassPorts.Build()
 	}
+	for _, domain := range m.BypassDomains {
+		config.BypassDomains = append(config.BypassDomains, strings.ToLower(strings.TrimPrefix(domain, "domain:")))
+	}
+	return config
 }
 
 type InboundDetourAllocationConfig struct {
@@ -173,6 +200,28 @@ type InboundDetourConfig struct {
 	StreamSetting  *StreamConfig                  `json:"streamSettings"`
 	DomainOverride *StringList                    `json:"domainOverride"`
 	SniffingConfig *SniffingConfig                `json:"sniffing"`
+	BlockedPorts   *PortList                      `json:"blockedPorts"`
+	BlockedProtos  *StringList                    `json:"blockedProtocols"`
+	BlockPrivate   bool                           `json:"blockPrivateDestinations"`
+	AllowedPrivate *StringList                    `json:"allowedPrivateDestinations"`
+	Maintenance    *MaintenanceConfig             `json:"maintenance"`
+	MuxCoalesce    bool                           `json:"muxCoalesce"`
+	MuxResumable   bool                           `json:"muxResumable"`
+}
+
+type MaintenanceConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Response string `json:"response"`
+	Fallback string `json:"fallback"`
+}
+
+// Build implements Buildable.
+func (c *MaintenanceConfig) Build() *proxyman.MaintenanceConfig {
+	return &proxyman.MaintenanceConfig{
+		Enabled:  c.Enabled,
+		Response: []byte(c.Response),
+		Fallback: c.Fallback,
+	}
 }
 
 // Build implements Buildable.
@@ -255,6 +304,34 @@ func (c *InboundDetourConfig) Build() (*core.InboundHandlerConfig, error) {
 		}
 		receiverSettings.DomainOverride = kp
 	}
+	if c.BlockedPorts != nil {
+		receiverSettings.BlockedPorts = c.BlockedPorts.Build()
+	}
+	if c.BlockedProtos != nil {
+		for _, p := range *c.BlockedProtos {
+			receiverSettings.BlockedProtocols = append(receiverSettings.BlockedProtocols, strings.ToLower(p))
+		}
+		if receiverSettings.SniffingSettings == nil || !receiverSettings.SniffingSettings.Enabled {
+			newError("blockedProtocols of inbound [", c.Tag, "] has no effect unless sniffing is enabled").AtWarning().WriteToLog()
+		}
+	}
+
+	receiverSettings.BlockPrivateDestinations = c.BlockPrivate
+	receiverSettings.MuxCoalesce = c.MuxCoalesce
+	receiverSettings.MuxResumable = c.MuxResumable
+	if c.AllowedPrivate != nil {
+		for _, s := range *c.AllowedPrivate {
+			cidr, err := ParseIP(s)
+			if err != nil {
+				return nil, newError("invalid allowed private destination: ", s).Base(err)
+			}
+			receiverSettings.AllowedPrivateDestinations = append(receiverSettings.AllowedPrivateDestinations, net.IP(cidr.Ip).String()+"/"+strconv.Itoa(int(cidr.Prefix)))
+		}
+	}
+
+	if c.Maintenance != nil {
+		receiverSettings.Maintenance = c.Maintenance.Build()
+	}
 
 	settings := []byte("{}")
 	if c.Settings != nil {
@@ -287,6 +364,55 @@ type OutboundDetourConfig struct {
 	StreamSetting *StreamConfig    `json:"streamSettings"`
 	ProxySettings *ProxyConfig     `json:"proxySettings"`
 	MuxSettings   *MuxConfig       `json:"mux"`
+	// in KB/s and KB
+	BandwidthLimit    uint64                   `json:"bandwidthLimit"`
+	BandwidthBurst    uint64                   `json:"bandwidthBurst"`
+	HandshakeSchedule *HandshakeScheduleConfig `json:"handshakeSchedule"`
+	CircuitBreaker    *CircuitBreakerConfig    `json:"circuitBreaker"`
+}
+
+type HandshakeScheduleConfig struct {
+	Packets  uint32 `json:"packets"`
+	MinSize  uint32 `json:"minSize"`
+	MaxSize  uint32 `json:"maxSize"`
+	MinDelay uint32 `json:"minDelay"`
+	MaxDelay uint32 `json:"maxDelay"`
+}
+
+// Build implements Buildable.
+func (c *HandshakeScheduleConfig) Build() (*proxyman.HandshakeSchedule, error) {
+	if c.Packets == 0 {
+		return nil, newError("handshakeSchedule requires packets")
+	}
+	if c.MaxSize < c.MinSize {
+		return nil, newError("handshakeSchedule: maxSize is smaller than minSize")
+	}
+	if c.MaxDelay < c.MinDelay {
+		return nil, newError("handshakeSchedule: maxDelay is smaller than minDelay")
+	}
+	return &proxyman.HandshakeSchedule{
+		Packets:  c.Packets,
+		MinSize:  c.MinSize,
+		MaxSize:  c.MaxSize,
+		MinDelay: c.MinDelay,
+		MaxDelay: c.MaxDelay,
+	}, nil
+}
+
+type CircuitBreakerConfig struct {
+	Failures uint32 `json:"failures"`
+	Cooldown uint32 `json:"cooldown"`
+}
+
+// Build implements Buildable.
+func (c *CircuitBreakerConfig) Build() (*proxyman.CircuitBreaker, error) {
+	if c.Failures == 0 {
+		return nil, newError("circuitBreaker requires failures")
+	}
+	return &proxyman.CircuitBreaker{
+		Failures: c.Failures,
+		Cooldown: c.Cooldown,
+	}, nil
 }
 
 func (c *OutboundDetourConfig) checkChainProxyConfig() error {
@@ -357,6 +483,34 @@ func (c *OutboundDetourConfig) Build() (*core.OutboundHandlerConfig, error) {
 		senderSettings.MultiplexSettings = ms
 	}
 
+	if c.BandwidthLimit > 0 {
+		senderSettings.BandwidthLimit = c.BandwidthLimit * 1024
+		senderSettings.BandwidthBurst = c.BandwidthBurst * 1024
+	} else if c.BandwidthBurst > 0 {
+		return nil, newError("bandwidthBurst requires bandwidthLimit")
+	}
+
+	if c.HandshakeSchedule != nil {
+		hs, err := c.HandshakeSchedule.Build()
+		if err != nil {
+			return nil, err
+		}
+		senderSettings.HandshakeSchedule = hs
+	}
+
+	if c.CircuitBreaker != nil {
+		switch strings.ToLower(c.Protocol) {
+		case "freedom", "blackhole", "loopback":
+			// their failures are the ones of the destinations, not of a server
+			return nil, newError("circuitBreaker is not supported by ", c.Protocol, " outbounds")
+		}
+		cb, err := c.CircuitBreaker.Build()
+		if err != nil {
+			return nil, err
+		}
+		senderSettings.CircuitBreaker = cb
+	}
+
 	settings := []byte("{}")
 	if c.Settings != nil {
 		settings = ([]byte)(*c.Settings)
@@ -369,6 +523,12 @@ func (c *OutboundDetourConfig) Build() (*core.OutboundHandlerConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	if senderSettings.BandwidthLimit > 0 {
+		// XTLS needs the connection of the transport, which the limiter would hide
+		if flow := outboundFlow(ts); flow != "" {
+			return nil, newError("bandwidthLimit can't be used with flow ", flow)
+		}
+	}
 
 	return &core.OutboundHandlerConfig{
 		SenderSettings: serial.ToTypedMessage(senderSettings),
@@ -377,6 +537,29 @@ func (c *OutboundDetourConfig) Build() (*core.OutboundHandlerConfig, error) {
 	}, nil
 }
 
+// outboundFlow returns the first flow of the users of a VLESS or Trojan outbound, if any.
+func outboundFlow(config interface{}) string {
+	var servers []*protocol.ServerEndpoint
+	switch config := config.(type) {
+	case *vlessoutbound.Config:
+		servers = config.Vnext
+	case *trojan.ClientConfig:
+		servers = config.Server
+	}
+	for _, server := range servers {
+		for _, user := range server.User {
+			account, err := user.GetAccount().GetInstance()
+			if err != nil {
+				continue
+			}
+			if a, ok := account.(interface{ GetFlow() string }); ok && a.GetFlow() != "" {
+				return a.GetFlow()
+			}
+		}
+	}
+	return ""
+}
+
 type StatsConfig struct{}
 
 // Build implements Buildable.
@@ -419,6 +602,11 @@ type Config struct {
 	Reverse         *ReverseConfig         `json:"reverse"`
 	FakeDNS         *FakeDNSConfig         `json:"fakeDns"`
 	Observatory     *ObservatoryConfig     `json:"observatory"`
+	Watchdog        *WatchdogConfig        `json:"watchdog"`
+	Geodata         *GeodataConfig         `json:"geodata"`
+	Hooks           *HooksConfig           `json:"hooks"`
+	Auth            *AuthConfig            `json:"auth"`
+	UserStore       *UserStoreConfig       `json:"userStore"`
 }
 
 func (c *Config) findInboundTag(tag string) int {
@@ -483,6 +671,26 @@ func (c *Config) Override(o *Config, fn string) {
 		c.Observatory = o.Observatory
 	}
 
+	if o.Watchdog != nil {
+		c.Watchdog = o.Watchdog
+	}
+
+	if o.Geodata != nil {
+		c.Geodata = o.Geodata
+	}
+
+	if o.Hooks != nil {
+		c.Hooks = o.Hooks
+	}
+
+	if o.Auth != nil {
+		c.Auth = o.Auth
+	}
+
+	if o.UserStore != nil {
+		c.UserStore = o.UserStore
+	}
+
 	// deprecated attrs... keep them for now
 	if o.InboundConfig != nil {
 		c.InboundConfig = o.InboundConfig
@@ -646,6 +854,46 @@ func (c *Config) Build() (*core.Config, error) {
 		config.App = append(config.App, serial.ToTypedMessage(r))
 	}
 
+	if c.Watchdog != nil {
+		r, err := c.Watchdog.Build()
+		if err != nil {
+			return nil, err
+		}
+		config.App = append(config.App, serial.ToTypedMessage(r))
+	}
+
+	if c.Geodata != nil {
+		r, err := c.Geodata.Build()
+		if err != nil {
+			return nil, err
+		}
+		config.App = append(config.App, serial.ToTypedMessage(r))
+	}
+
+	if c.Hooks != nil && len(c.Hooks.Hooks) > 0 {
+		r, err := c.Hooks.Build()
+		if err != nil {
+			return nil, err
+		}
+		config.App = append(config.App, serial.ToTypedMessage(r))
+	}
+
+	if c.Auth != nil {
+		r, err := c.Auth.Build()
+		if err != nil {
+			return nil, err
+		}
+		config.App = append(config.App, serial.ToTypedMessage(r))
+	}
+
+	if c.UserStore != nil {
+		r, err := c.UserStore.Build()
+		if err != nil {
+			return nil, err
+		}
+		config.App = append(config.App, serial.ToTypedMessage(r))
+	}
+
 	var inbounds []InboundDetourConfig
 
 	if c.InboundConfig != nil {
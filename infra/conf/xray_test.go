@@ -352,6 +352,18 @@ func TestMuxConfig_Build(t *testing.T) {
 			Concurrency: 4,
 		}},
 		{"forbidden", `{"enabled": false, "concurrency": -1}`, nil},
+		{"bypass", `{"enabled": true, "network": "tcp", "bypassDomains": ["domain:Download.example.com"], "bypassQuic": true}`, &proxyman.MultiplexingConfig{
+			Enabled:       true,
+			Concurrency:   8,
+			Networks:      []net.Network{net.Network_TCP},
+			BypassDomains: []string{"download.example.com"},
+			BypassQuic:    true,
+		}},
+		{"coalesce", `{"enabled": true, "coalesce": true}`, &proxyman.MultiplexingConfig{
+			Enabled:     true,
+			Concurrency: 8,
+			Coalesce:    true,
+		}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -364,6 +376,86 @@ func TestMuxConfig_Build(t *testing.T) {
 	}
 }
 
+func TestOutboundCircuitBreaker(t *testing.T) {
+	for _, tt := range []struct {
+		protocol string
+		valid    bool
+	}{
+		{"socks", true},
+		{"freedom", false},
+		{"blackhole", false},
+	} {
+		config := &OutboundDetourConfig{}
+		common.Must(json.Unmarshal([]byte(`{
+			"protocol": "`+tt.protocol+`",
+			"circuitBreaker": {"failures": 3, "cooldown": 10}
+		}`), config))
+		built, err := config.Build()
+		if !tt.valid {
+			if err == nil {
+				t.Error("expected circuitBreaker to be rejected for ", tt.protocol)
+			}
+			continue
+		}
+		common.Must(err)
+		sender, err := built.SenderSettings.GetInstance()
+		common.Must(err)
+		if r := cmp.Diff(sender.(*proxyman.SenderConfig).CircuitBreaker, &proxyman.CircuitBreaker{Failures: 3, Cooldown: 10}, cmp.Comparer(proto.Equal)); r != "" {
+			t.Error(r)
+		}
+	}
+}
+
+func TestOutboundBandwidthLimitWithFlow(t *testing.T) {
+	for _, tt := range []struct {
+		flow  string
+		valid bool
+	}{
+		{"", true},
+		{"xtls-rprx-vision", false},
+	} {
+		config := &OutboundDetourConfig{}
+		common.Must(json.Unmarshal([]byte(`{
+			"protocol": "vless",
+			"bandwidthLimit": 1024,
+			"settings": {"vnext": [{
+				"address": "example.com",
+				"port": 443,
+				"users": [{"id": "27848739-7e62-4138-9fd3-098a63964b6b", "encryption": "none", "flow": "`+tt.flow+`"}]
+			}]}
+		}`), config))
+		_, err := config.Build()
+		if tt.valid {
+			common.Must(err)
+		} else if err == nil {
+			t.Error("expected bandwidthLimit to be rejected with flow ", tt.flow)
+		}
+	}
+}
+
+func TestInboundRestriction(t *testing.T) {
+	config := &InboundDetourConfig{}
+	common.Must(json.Unmarshal([]byte(`{
+		"protocol": "dokodemo-door",
+		"port": 1080,
+		"settings": {"address": "127.0.0.1", "port": 80},
+		"sniffing": {"enabled": true},
+		"blockedPorts": "25,465-587",
+		"blockedProtocols": ["BitTorrent"]
+	}`), config))
+	built, err := config.Build()
+	common.Must(err)
+	receiver, err := built.ReceiverSettings.GetInstance()
+	common.Must(err)
+	r := receiver.(*proxyman.ReceiverConfig)
+	if r := cmp.Diff(r.BlockedPorts, &net.PortList{Range: []*net.PortRange{{From: 25, To: 25}, {From: 465, To: 587}}}, cmp.Comparer(proto.Equal)); r != "" {
+		t.Error(r)
+	}
+	if r := cmp.Diff(r.BlockedProtocols, []string{"bittorrent"}); r != "" {
+		t.Error(r)
+	}
+}
+
 func TestConfig_Override(t *testing.T) {
 	tests := []struct {
 		name string
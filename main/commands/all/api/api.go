@@ -12,12 +12,19 @@ var CmdAPI = &base.Command{
 `,
 	Commands: []*base.Command{
 		cmdRestartLogger,
+		cmdRecentLogs,
 		cmdGetStats,
 		cmdQueryStats,
 		cmdSysStats,
+		cmdUserLogins,
 		cmdAddInbounds,
 		cmdAddOutbounds,
 		cmdRemoveInbounds,
 		cmdRemoveOutbounds,
+		cmdAddRules,
+		cmdRemoveRules,
+		cmdListRules,
+		cmdOverrideBalancer,
+		cmdSpeedTest,
 	},
 }
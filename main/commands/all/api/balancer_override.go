@@ -0,0 +1,53 @@
+package api
+
+import (
+	routerService "github.com/xtls/xray-core/app/router/command"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdOverrideBalancer = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api bo [--server=127.0.0.1:8080] -b <balancerTag> [outboundTag]",
+	Short:       "Override the outbound of a balancer",
+	Long: `
+Make a balancer pick the given outbound, instead of the ones of its strategy.
+Without outbound, the balancer picks by its strategy again.
+Arguments:
+	-s, -server 
+		The API server address. Default 127.0.0.1:8080
+	-t, -timeout
+		Timeout seconds to call API. Default 3
+	-token
+		The API token, required if the server enables API tokens
+	-b, -balancer
+		The tag of the balancer
+Example:
+    {{.Exec}} {{.LongName}} --server=127.0.0.1:8080 -b balancer proxy1
+`,
+	Run: executeOverrideBalancer,
+}
+
+func executeOverrideBalancer(cmd *base.Command, args []string) {
+	setSharedFlags(cmd)
+	var balancer string
+	cmd.Flag.StringVar(&balancer, "b", "", "")
+	cmd.Flag.StringVar(&balancer, "balancer", "", "")
+	cmd.Flag.Parse(args)
+	if balancer == "" {
+		base.Fatalf("no balancer specified")
+	}
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := routerService.NewRoutingServiceClient(conn)
+	r := &routerService.OverrideBalancerTargetRequest{
+		BalancerTag: balancer,
+		Target:      cmd.Flag.Arg(0),
+	}
+	resp, err := client.OverrideBalancerTarget(ctx, r)
+	if err != nil {
+		base.Fatalf("failed to override balancer: %s", err)
+	}
+	showJSONResponse(resp)
+}
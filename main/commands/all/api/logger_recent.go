@@ -0,0 +1,67 @@
+package api
+
+import (
+	"strings"
+
+	logService "github.com/xtls/xray-core/app/log/command"
+	clog "github.com/xtls/xray-core/common/log"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdRecentLogs = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api recentlogs [--server=127.0.0.1:8080] [-n 100] [-level warning]",
+	Short:       "Get recent logs",
+	Long: `
+Get the recent logs Xray keeps in memory, see historySize in log settings.
+Arguments:
+	-s, -server 
+		The API server address. Default 127.0.0.1:8080
+	-t, -timeout
+		Timeout seconds to call API. Default 3
+	-token
+		The API token, required if the server enables API tokens
+	-n
+		Maximum number of entries, the most recent ones. Default all
+	-level
+		Only get messages of this level or above: error, warning, info or debug
+Example:
+	{{.Exec}} {{.LongName}} --server=127.0.0.1:8080 -n 20 -level warning
+`,
+	Run: executeRecentLogs,
+}
+
+func executeRecentLogs(cmd *base.Command, args []string) {
+	setSharedFlags(cmd)
+	limit := cmd.Flag.Uint("n", 0, "")
+	level := cmd.Flag.String("level", "", "")
+	cmd.Flag.Parse(args)
+
+	r := &logService.GetRecentLogsRequest{
+		Limit: uint32(*limit),
+	}
+	if *level != "" {
+		switch strings.ToLower(*level) {
+		case "error":
+			r.Level = clog.Severity_Error
+		case "warning":
+			r.Level = clog.Severity_Warning
+		case "info":
+			r.Level = clog.Severity_Info
+		case "debug":
+			r.Level = clog.Severity_Debug
+		default:
+			base.Fatalf("invalid level: %s", *level)
+		}
+	}
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := logService.NewLoggerServiceClient(conn)
+	resp, err := client.GetRecentLogs(ctx, r)
+	if err != nil {
+		base.Fatalf("failed to get recent logs: %s", err)
+	}
+	showJSONResponse(resp)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"fmt"
+
+	routerService "github.com/xtls/xray-core/app/router/command"
+	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/infra/conf"
+	cserial "github.com/xtls/xray-core/infra/conf/serial"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdAddRules = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api adrules [--server=127.0.0.1:8080] [-append] <c1.json> [c2.json]...",
+	Short:       "Add routing rules",
+	Long: `
+Add the routing rules and balancers of the "routing" objects of config files
+to Xray. Every rule must have a "ruleTag", by which it can be removed.
+Arguments:
+	-s, -server 
+		The API server address. Default 127.0.0.1:8080
+	-t, -timeout
+		Timeout seconds to call API. Default 3
+	-token
+		The API token, required if the server enables API tokens
+	-append
+		Try the rules after the existing ones. By default they are tried
+		before them.
+Example:
+    {{.Exec}} {{.LongName}} --server=127.0.0.1:8080 -append c1.json c2.json
+`,
+	Run: executeAddRules,
+}
+
+func executeAddRules(cmd *base.Command, args []string) {
+	setSharedFlags(cmd)
+	shouldAppend := cmd.Flag.Bool("append", false, "")
+	cmd.Flag.Parse(args)
+	unnamedArgs := cmd.Flag.Args()
+	if len(unnamedArgs) == 0 {
+		fmt.Println("Reading from STDIN")
+		unnamedArgs = []string{"stdin:"}
+	}
+
+	rcs := make([]*conf.RouterConfig, 0)
+	for _, arg := range unnamedArgs {
+		r, err := loadArg(arg)
+		if err != nil {
+			base.Fatalf("failed to load %s: %s", arg, err)
+		}
+		conf, err := cserial.DecodeJSONConfig(r)
+		if err != nil {
+			base.Fatalf("failed to decode %s: %s", arg, err)
+		}
+		if conf.RouterConfig == nil {
+			base.Fatalf("no routing config in %s", arg)
+		}
+		rcs = append(rcs, conf.RouterConfig)
+	}
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := routerService.NewRoutingServiceClient(conn)
+	for _, rc := range rcs {
+		config, err := rc.Build()
+		if err != nil {
+			base.Fatalf("failed to build conf: %s", err)
+		}
+		for _, rule := range config.Rule {
+			fmt.Println("adding:", rule.RuleTag)
+		}
+		r := &routerService.AddRuleRequest{
+			Config:       serial.ToTypedMessage(config),
+			ShouldAppend: *shouldAppend,
+		}
+		resp, err := client.AddRule(ctx, r)
+		if err != nil {
+			base.Fatalf("failed to add rules: %s", err)
+		}
+		showJSONResponse(resp)
+	}
+}
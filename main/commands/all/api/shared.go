@@ -15,6 +15,7 @@ import (
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/main/commands/base"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 )
@@ -24,6 +25,7 @@ type serviceHandler func(ctx context.Context, conn *grpc.ClientConn, cmd *base.C
 var (
 	apiServerAddrPtr string
 	apiTimeout       int
+	apiToken         string
 )
 
 func setSharedFlags(cmd *base.Command) {
@@ -31,6 +33,7 @@ func setSharedFlags(cmd *base.Command) {
 	cmd.Flag.StringVar(&apiServerAddrPtr, "server", "127.0.0.1:8080", "")
 	cmd.Flag.IntVar(&apiTimeout, "t", 3, "")
 	cmd.Flag.IntVar(&apiTimeout, "timeout", 3, "")
+	cmd.Flag.StringVar(&apiToken, "token", "", "")
 }
 
 func dialAPIServer() (conn *grpc.ClientConn, ctx context.Context, close func()) {
@@ -39,6 +42,9 @@ func dialAPIServer() (conn *grpc.ClientConn, ctx context.Context, close func())
 	if err != nil {
 		base.Fatalf("failed to dial %s", apiServerAddrPtr)
 	}
+	if apiToken != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+apiToken)
+	}
 	close = func() {
 		cancel()
 		conn.Close()
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"flag"
+
+	handlerService "github.com/xtls/xray-core/app/proxyman/command"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdSpeedTest = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api speedtest [--server=127.0.0.1:8080] -outbound <tag> -dest <host:port> [-size 10]",
+	Short:       "Test throughput of an outbound",
+	Long: `
+Send data through an outbound to a TCP echo server, and report the throughput.
+Arguments:
+	-s, -server 
+		The API server address. Default 127.0.0.1:8080
+	-t, -timeout
+		Timeout seconds of the test. Default 60
+	-token
+		The API token, required if the server enables API tokens
+	-outbound
+		Tag of the outbound to test
+	-dest
+		Address of the echo server, in the form of host:port
+	-size
+		Megabytes to send. Default 10
+Example:
+    {{.Exec}} {{.LongName}} -outbound proxy -dest echo.example.com:7
+`,
+	Run: executeSpeedTest,
+}
+
+func executeSpeedTest(cmd *base.Command, args []string) {
+	setSharedFlags(cmd)
+	outboundTag := cmd.Flag.String("outbound", "", "")
+	dest := cmd.Flag.String("dest", "", "")
+	size := cmd.Flag.Uint("size", 10, "")
+	cmd.Flag.Parse(args)
+
+	if *outboundTag == "" || *dest == "" {
+		base.Fatalf("both -outbound and -dest are required")
+	}
+	timeoutSet := false
+	cmd.Flag.Visit(func(f *flag.Flag) {
+		if f.Name == "t" || f.Name == "timeout" {
+			timeoutSet = true
+		}
+	})
+	if !timeoutSet {
+		apiTimeout = 60
+	}
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := handlerService.NewHandlerServiceClient(conn)
+	r := &handlerService.SpeedTestRequest{
+		OutboundTag: *outboundTag,
+		Destination: *dest,
+		Size:        uint32(*size),
+		Timeout:     uint32(apiTimeout),
+	}
+	resp, err := client.SpeedTest(ctx, r)
+	if err != nil {
+		base.Fatalf("failed to run speed test: %s", err)
+	}
+	showJSONResponse(resp)
+}
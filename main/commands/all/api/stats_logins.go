@@ -0,0 +1,57 @@
+package api
+
+import (
+	"time"
+
+	statsService "github.com/xtls/xray-core/app/stats/command"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdUserLogins = &base.Command{
+	CustomFlags: true,
+	UsageLine:   "{{.Exec}} api statslogins [--server=127.0.0.1:8080] [-pattern ''] [-idle 0]",
+	Short:       "Get the logins of users",
+	Long: `
+Get the last logins of users from their recent distinct sources, which
+requires the statsUserLogin policy. Users of the user store who never
+logged in are listed without logins. As the logins have the addresses of
+the users, they need an API token of the user or admin scope.
+Arguments:
+	-s, -server 
+		The API server address. Default 127.0.0.1:8080
+	-t, -timeout
+		Timeout seconds to call API. Default 3
+	-token
+		The API token, required if the server enables API tokens
+	-pattern
+		Substring of the emails of the users.
+	-idle
+		Only the users who haven't logged in for so many days, or never.
+Example:
+	{{.Exec}} {{.LongName}} --server=127.0.0.1:8080 -idle 30
+`,
+	Run: executeUserLogins,
+}
+
+func executeUserLogins(cmd *base.Command, args []string) {
+	setSharedFlags(cmd)
+	pattern := cmd.Flag.String("pattern", "", "")
+	idle := cmd.Flag.Uint("idle", 0, "")
+	cmd.Flag.Parse(args)
+
+	conn, ctx, close := dialAPIServer()
+	defer close()
+
+	client := statsService.NewStatsServiceClient(conn)
+	r := &statsService.GetUserLoginsRequest{
+		Pattern: *pattern,
+	}
+	if *idle > 0 {
+		r.Before = time.Now().Add(-time.Duration(*idle) * 24 * time.Hour).Unix()
+	}
+	resp, err := client.GetUserLogins(ctx, r)
+	if err != nil {
+		base.Fatalf("failed to get user logins: %s", err)
+	}
+	showJSONResponse(resp)
+}
@@ -16,6 +16,8 @@ Arguments:
 		The API server address. Default 127.0.0.1:8080
 	-t, -timeout
 		Timeout seconds to call API. Default 3
+	-token
+		The API token, required if the server enables API tokens
 	-pattern
 		Pattern of the query.
 	-reset
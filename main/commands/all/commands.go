@@ -14,6 +14,8 @@ func init() {
 		api.CmdAPI,
 		// cmdConvert,
 		tls.CmdTLS,
+		cmdProbeTest,
+		cmdSchema,
 		cmdUUID,
 		cmdX25519,
 	)
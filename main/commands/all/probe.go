@@ -0,0 +1,337 @@
+package all
+
+import (
+	gotls "crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/xtls/xray-core/common/uuid"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdProbeTest = &base.Command{
+	UsageLine: "{{.Exec}} probe-test [-sni <name>] [-notls] [-timeout <seconds>] <address>:<port>",
+	Short:     "Probe your own server as malformed and legacy clients",
+	Long: `
+Connect to your own server as clients that are not yours, the way active probes
+do, and report how the server responds to each of them. A server with a good
+fallback answers them the way the website it camouflages as would, instead of
+closing the connection in a way only a proxy would.
+
+The probes are:
+
+	plain HTTP            an HTTP request without TLS
+	TLS without SNI       a TLS handshake without server name
+	legacy TLS            a TLS 1.0 handshake
+	HTTP over TLS         an HTTP request after a TLS handshake
+	wrong VLESS UUID      a VLESS request of an unknown user
+	replayed handshake    the ClientHello of a TLS handshake sent again
+
+Arguments:
+
+	-sni
+		The server name of TLS handshakes and HTTP requests. Defaults to the address.
+
+	-notls
+		The server does not use TLS. The TLS probes and the replay are skipped,
+		and the others are sent without TLS.
+
+	-timeout
+		Seconds to wait for the response of each probe. Default 5.
+
+Example:
+
+	{{.Exec}} {{.LongName}} -sni example.com 203.0.113.1:443
+`,
+}
+
+func init() {
+	cmdProbeTest.Run = executeProbeTest // break init loop
+}
+
+var (
+	probeSNI     = cmdProbeTest.Flag.String("sni", "", "")
+	probeNoTLS   = cmdProbeTest.Flag.Bool("notls", false, "")
+	probeTimeout = cmdProbeTest.Flag.Int("timeout", 5, "")
+)
+
+// probeClient is how a probe connects to the server.
+type probeClient int
+
+const (
+	probePlain probeClient = iota
+	probeTLS
+	probeTLSNoSNI
+	probeTLSLegacy
+)
+
+type probeTester struct {
+	address string
+	port    uint16
+	sni     string
+	timeout time.Duration
+}
+
+// probeResult is how the server responded to a probe.
+type probeResult struct {
+	handshake string
+	response  string
+	// after is when the server closed or reset the connection, if it did.
+	after time.Duration
+}
+
+func (r probeResult) String() string {
+	s := r.response
+	if r.after > 0 {
+		s += " after " + r.after.String()
+	}
+	if r.handshake != "" {
+		s = r.handshake + "\n" + s
+	}
+	return s
+}
+
+func executeProbeTest(cmd *base.Command, args []string) {
+	if cmdProbeTest.Flag.NArg() < 1 {
+		base.Fatalf("address not specified")
+	}
+	address := cmdProbeTest.Flag.Arg(0)
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		base.Fatalf("invalid address %s: %s", address, err)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		base.Fatalf("invalid port %s: %s", portStr, err)
+	}
+	t := &probeTester{
+		address: address,
+		port:    uint16(port),
+		sni:     *probeSNI,
+		timeout: time.Duration(*probeTimeout) * time.Second,
+	}
+	if t.sni == "" {
+		t.sni = host
+	}
+
+	client := func(c probeClient) probeClient {
+		if *probeNoTLS {
+			return probePlain
+		}
+		return c
+	}
+	httpRequest := []byte("GET / HTTP/1.1\r\nHost: " + t.sni + "\r\nUser-Agent: Mozilla/5.0\r\nAccept: */*\r\n\r\n")
+
+	fmt.Println("Probing", address, "as", t.sni)
+	t.report("plain HTTP", t.probe(probePlain, httpRequest))
+	if !*probeNoTLS {
+		t.report("TLS without SNI", t.probe(probeTLSNoSNI, nil))
+		t.report("legacy TLS", t.probe(probeTLSLegacy, nil))
+		t.report("HTTP over TLS", t.probe(probeTLS, httpRequest))
+	}
+	t.report("wrong VLESS UUID", t.probe(client(probeTLS), append(vlessRequest(t.sni, t.port), httpRequest...)))
+
+	if !*probeNoTLS {
+		first, replay := t.replayHandshake()
+		t.report("replayed handshake", probeResult{
+			response: "first: " + first.String() + "\nreplay: " + replay.String(),
+		})
+		if first.handshake != "" && replay.response != tlsHandshakeResponse {
+			fmt.Println("  ! the server doesn't answer a replayed handshake like a new one")
+		}
+	}
+	fmt.Println("-------------------")
+	fmt.Println("Probe test finished")
+}
+
+func (t *probeTester) report(name string, result probeResult) {
+	fmt.Println("-------------------")
+	fmt.Println(name)
+	for _, line := range strings.Split(result.String(), "\n") {
+		fmt.Println("  " + line)
+	}
+}
+
+// recordingConn keeps what is written to it.
+type recordingConn struct {
+	net.Conn
+	written []byte
+}
+
+func (c *recordingConn) Write(b []byte) (int, error) {
+	c.written = append(c.written, b...)
+	return c.Conn.Write(b)
+}
+
+// replayHandshake makes a TLS handshake, then sends its ClientHello again on a new connection, the way probes
+// replaying captured handshakes do. A server that isn't a proxy answers both with a ServerHello.
+func (t *probeTester) replayHandshake() (first probeResult, replay probeResult) {
+	var clientHello []byte
+	first = t.probeWith(probeTLS, nil, func(conn net.Conn) net.Conn {
+		return &recordingConn{Conn: conn}
+	}, func(conn net.Conn) {
+		clientHello = conn.(*recordingConn).written
+	})
+	if first.handshake == "" {
+		return first, probeResult{response: "skipped, as the handshake failed"}
+	}
+	// the ClientHello is the first record
+	if len(clientHello) >= 5 {
+		if n := 5 + int(binary.BigEndian.Uint16(clientHello[3:])); n < len(clientHello) {
+			clientHello = clientHello[:n]
+		}
+	}
+	return first, t.probe(probePlain, clientHello)
+}
+
+// probe connects to the server as the given client, sends payload, and waits for the response.
+func (t *probeTester) probe(client probeClient, payload []byte) probeResult {
+	return t.probeWith(client, payload, nil, nil)
+}
+
+// probeWith is probe with the TCP connection wrapped by wrap, if not nil, and handshaken called with it after
+// a TLS handshake.
+func (t *probeTester) probeWith(client probeClient, payload []byte, wrap func(net.Conn) net.Conn, handshaken func(net.Conn)) probeResult {
+	var result probeResult
+	conn, err := net.DialTimeout("tcp", t.address, t.timeout)
+	if err != nil {
+		result.response = "failed to connect: " + err.Error()
+		return result
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(t.timeout))
+	if wrap != nil {
+		conn = wrap(conn)
+	}
+
+	if client != probePlain {
+		config := &gotls.Config{
+			ServerName:         t.sni,
+			InsecureSkipVerify: true,
+			NextProtos:         []string{"h2", "http/1.1"},
+		}
+		switch client {
+		case probeTLSNoSNI:
+			config.ServerName = ""
+		case probeTLSLegacy:
+			config.MinVersion = gotls.VersionTLS10
+			config.MaxVersion = gotls.VersionTLS10
+		case probeTLS:
+			config.NextProtos = []string{"http/1.1"}
+		}
+		tlsConn := gotls.Client(conn, config)
+		if err := tlsConn.Handshake(); err != nil {
+			result.response = "TLS handshake failed: " + err.Error()
+			return result
+		}
+		result.handshake = describeTLS(tlsConn.ConnectionState())
+		if handshaken != nil {
+			handshaken(conn)
+		}
+		conn = tlsConn
+	}
+
+	start := time.Now()
+	if len(payload) > 0 {
+		if _, err := conn.Write(payload); err != nil {
+			result.response = "failed to send: " + err.Error()
+			return result
+		}
+	}
+	var closed bool
+	result.response, closed = describeResponse(conn, t.timeout)
+	if closed {
+		result.after = time.Since(start).Round(100 * time.Millisecond)
+	}
+	return result
+}
+
+func describeTLS(state gotls.ConnectionState) string {
+	versions := map[uint16]string{
+		gotls.VersionTLS10: "1.0",
+		gotls.VersionTLS11: "1.1",
+		gotls.VersionTLS12: "1.2",
+		gotls.VersionTLS13: "1.3",
+	}
+	s := "TLS " + versions[state.Version]
+	if state.NegotiatedProtocol != "" {
+		s += ", ALPN " + state.NegotiatedProtocol
+	}
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		s += ", certificate of " + strings.Join(append([]string{cert.Subject.CommonName}, cert.DNSNames...), " ")
+	}
+	return s
+}
+
+const (
+	tlsHandshakeResponse = "responded with a TLS handshake"
+	tlsAlertResponse     = "responded with a TLS alert"
+)
+
+// describeResponse tells how the server responded on conn: with data, by closing or resetting it, or not at
+// all. closed is whether the server ended the connection.
+func describeResponse(conn net.Conn, timeout time.Duration) (response string, closed bool) {
+	b := make([]byte, 4096)
+	n, err := conn.Read(b)
+	// a TLS record, to a probe without TLS
+	if n >= 3 && b[1] == 3 {
+		switch b[0] {
+		case 22:
+			return tlsHandshakeResponse, false
+		case 21:
+			return tlsAlertResponse, false
+		}
+	}
+	if n > 0 {
+		line := string(b[:n])
+		if i := strings.IndexAny(line, "\r\n"); i >= 0 {
+			line = line[:i]
+		}
+		if isPrintable(line) && len(line) > 0 {
+			return "responded: " + line, false
+		}
+		if len(line) > 32 {
+			line = line[:32]
+		}
+		return "responded with " + strconv.Itoa(n) + " bytes: " + strconv.QuoteToASCII(line) + "...", false
+	}
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "no response within " + timeout.String() + ", connection kept open", false
+	case errors.Is(err, syscall.ECONNRESET):
+		return "connection reset", true
+	case errors.Is(err, io.EOF):
+		return "connection closed", true
+	default:
+		return "connection failed: " + err.Error(), true
+	}
+}
+
+func isPrintable(s string) bool {
+	for _, c := range s {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// vlessRequest returns the header of a VLESS request to host:port, of a random user.
+func vlessRequest(host string, port uint16) []byte {
+	id := uuid.New()
+	b := []byte{0} // version
+	b = append(b, id.Bytes()...)
+	b = append(b, 0, 1) // no addons, TCP
+	b = binary.BigEndian.AppendUint16(b, port)
+	b = append(b, 2, byte(len(host)))
+	return append(b, host...)
+}
@@ -0,0 +1,89 @@
+package all
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+)
+
+var httpRequest = []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+
+func newProbeTester(t *testing.T, address string) *probeTester {
+	_, portStr, err := net.SplitHostPort(address)
+	common.Must(err)
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	common.Must(err)
+	return &probeTester{
+		address: address,
+		port:    uint16(port),
+		sni:     "example.com",
+		timeout: time.Second,
+	}
+}
+
+func TestProbeFallback(t *testing.T) {
+	// a fallback to a web server
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	}))
+	defer server.Close()
+	tester := newProbeTester(t, server.Listener.Addr().String())
+
+	r := tester.probe(probeTLS, httpRequest)
+	if !strings.HasPrefix(r.handshake, "TLS 1.3") || r.response != "responded: HTTP/1.1 200 OK" {
+		t.Error("unexpected result: ", r.handshake, " ", r)
+	}
+
+	r = tester.probe(probeTLS, append(vlessRequest(tester.sni, tester.port), httpRequest...))
+	if r.response != "responded: HTTP/1.1 400 Bad Request" {
+		t.Error("unexpected result: ", r)
+	}
+
+	first, replay := tester.replayHandshake()
+	if first.handshake == "" {
+		t.Error("unexpected first result: ", first)
+	}
+	if replay.response != tlsHandshakeResponse {
+		t.Error("unexpected replay result: ", replay)
+	}
+}
+
+func TestProbeNoResponse(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	common.Must(err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			// closes the connections of the wrong requests, and holds the others
+			go func() {
+				b := make([]byte, 1024)
+				n, _ := conn.Read(b)
+				if strings.HasPrefix(string(b[:n]), "GET") {
+					conn.Close()
+				}
+			}()
+		}
+	}()
+	tester := newProbeTester(t, listener.Addr().String())
+
+	if r := tester.probe(probePlain, httpRequest); r.response != "connection closed" {
+		t.Error("unexpected result: ", r)
+	}
+	if r := tester.probe(probePlain, vlessRequest(tester.sni, tester.port)); !strings.HasPrefix(r.response, "no response") {
+		t.Error("unexpected result: ", r)
+	}
+	if first, replay := tester.replayHandshake(); first.handshake != "" || replay.response != "skipped, as the handshake failed" {
+		t.Error("unexpected result: ", first, " ", replay)
+	}
+}
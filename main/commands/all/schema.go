@@ -0,0 +1,37 @@
+package all
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/xtls/xray-core/infra/conf"
+	"github.com/xtls/xray-core/main/commands/base"
+)
+
+var cmdSchema = &base.Command{
+	UsageLine: "{{.Exec}} schema",
+	Short:     "Print the JSON Schema of the config",
+	Long: `
+Print the JSON Schema of the JSON config, so that editors and panels can
+validate and complete config files.
+
+The schema is generated from the config this build accepts, so it includes
+every protocol and field of it.
+
+Example:
+
+	{{.Exec}} {{.LongName}} > xray.schema.json
+`,
+}
+
+func init() {
+	cmdSchema.Run = executeSchema // break init loop
+}
+
+func executeSchema(cmd *base.Command, args []string) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(conf.Schema()); err != nil {
+		base.Fatalf("failed to encode schema: %s", err)
+	}
+}
@@ -15,12 +15,14 @@ import (
 
 	"github.com/xtls/xray-core/common/cmdarg"
 	"github.com/xtls/xray-core/common/platform"
+	"github.com/xtls/xray-core/common/postmortem"
 	"github.com/xtls/xray-core/core"
+	"github.com/xtls/xray-core/infra/conf"
 	"github.com/xtls/xray-core/main/commands/base"
 )
 
 var cmdRun = &base.Command{
-	UsageLine: "{{.Exec}} run [-c config.json] [-confdir dir]",
+	UsageLine: "{{.Exec}} run [-c config.json] [-confdir dir] [-crashdump dir]",
 	Short:     "Run Xray with config, the default command",
 	Long: `
 Run Xray with config, the default command.
@@ -35,6 +37,13 @@ Default "auto".
 
 The -test flag tells Xray to test config files only, 
 without launching the server
+
+The -crashdump=dir flag makes Xray write a postmortem bundle
+(stack traces, recent log, config fingerprint and runtime
+stats) to the dir when it fails to start, and on the panics
+recovered by inbounds while handling connections. Other
+panics of goroutines can't be recovered, and crash Xray
+without a bundle, with the stack traces of the Go runtime
 	`,
 }
 
@@ -47,6 +56,7 @@ var (
 	configDir   string
 	test        = cmdRun.Flag.Bool("test", false, "Test config file only, without launching Xray server.")
 	format      = cmdRun.Flag.String("format", "auto", "Format of input file.")
+	crashDump   = cmdRun.Flag.String("crashdump", "", "A dir to write postmortem bundles to on start failures and recovered panics.")
 
 	/* We have to do this here because Golang's Test will also need to parse flag, before
 	 * main func in this file is run.
@@ -62,9 +72,20 @@ var (
 
 func executeRun(cmd *base.Command, args []string) {
 	printVersion()
+	if *crashDump != "" && !*test {
+		postmortem.Enable(*crashDump)
+		// only covers this goroutine, which starts Xray
+		defer func() {
+			if r := recover(); r != nil {
+				writePostmortem(fmt.Sprint("panic: ", r))
+				panic(r)
+			}
+		}()
+	}
 	server, err := startXray()
 	if err != nil {
 		fmt.Println("Failed to start:", err)
+		writePostmortem(err)
 		// Configuration error. Exit with a special value to prevent systemd from restarting.
 		os.Exit(23)
 	}
@@ -76,15 +97,12 @@ func executeRun(cmd *base.Command, args []string) {
 
 	if err := server.Start(); err != nil {
 		fmt.Println("Failed to start:", err)
+		writePostmortem(err)
 		os.Exit(-1)
 	}
 	defer server.Close()
 
-	/*
-		conf.FileCache = nil
-		conf.IPCache = nil
-		conf.SiteCache = nil
-	*/
+	conf.ResetGeoCache()
 
 	// Explicitly triggering GC to remove garbage from config loading.
 	runtime.GC()
@@ -97,6 +115,15 @@ func executeRun(cmd *base.Command, args []string) {
 	}
 }
 
+func writePostmortem(reason interface{}) {
+	path, err := postmortem.Write(reason)
+	if err != nil {
+		fmt.Println("Failed to write postmortem:", err)
+	} else if path != "" {
+		fmt.Println("Postmortem written to", path)
+	}
+}
+
 func fileExists(file string) bool {
 	info, err := os.Stat(file)
 	return err == nil && !info.IsDir()
@@ -186,6 +213,7 @@ func startXray() (core.Server, error) {
 	if err != nil {
 		return nil, newError("failed to load config files: [", configFiles.String(), "]").Base(err)
 	}
+	postmortem.SetConfig(c)
 
 	server, err := core.New(c)
 	if err != nil {
@@ -0,0 +1,110 @@
+package decoy
+
+//go:generate go run github.com/xtls/xray-core/common/errors/errorgen
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/pires/go-proxyproto"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/signal"
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/transport/internet/stat"
+)
+
+// Recorder keeps what is read from a connection until Stop, so that a connection failing the handshake can be
+// passed to the decoy from its first byte.
+type Recorder struct {
+	Reader io.Reader
+
+	recorded buf.MultiBuffer
+	stopped  bool
+}
+
+func (r *Recorder) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 && !r.stopped {
+		r.recorded = buf.MergeBytes(r.recorded, p[:n])
+	}
+	return n, err
+}
+
+// Stop stops recording, once the handshake succeeds.
+func (r *Recorder) Stop() {
+	r.stopped = true
+	r.recorded = buf.ReleaseMulti(r.recorded)
+}
+
+// Unwrap stops recording and makes reader, which reads through the recorder, read from the connection directly
+// for the rest of the session, so that it gets vectorized reads back.
+func (r *Recorder) Unwrap(reader *buf.BufferedReader) {
+	r.Stop()
+	reader.Reader = buf.NewReader(r.Reader)
+}
+
+// Serve passes the connection to the decoy, starting with what the recorder kept, so that a port scanner sees
+// the decoy service rather than a connection closed on an invalid handshake.
+func Serve(ctx context.Context, config *Config, conn stat.Connection, recorder *Recorder, sessionPolicy policy.Session) error {
+	recorded := recorder.recorded
+	recorder.recorded = nil
+	recorder.Stop()
+
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		newError("unable to set back read deadline").Base(err).AtWarning().WriteToLog()
+	}
+
+	var dialer net.Dialer
+	decoyConn, err := dialer.DialContext(ctx, config.Type, config.Dest)
+	if err != nil {
+		buf.ReleaseMulti(recorded)
+		return newError("failed to dial to ", config.Dest).Base(err).AtWarning()
+	}
+	defer decoyConn.Close()
+
+	serverReader := buf.NewReader(decoyConn)
+	serverWriter := buf.NewWriter(decoyConn)
+
+	if config.Xver != 0 {
+		header := proxyproto.HeaderProxyFromAddrs(byte(config.Xver), conn.RemoteAddr(), conn.LocalAddr())
+		if _, err := header.WriteTo(decoyConn); err != nil {
+			buf.ReleaseMulti(recorded)
+			return newError("failed to set PROXY protocol v", config.Xver).Base(err).AtWarning()
+		}
+	}
+	if err := serverWriter.WriteMultiBuffer(recorded); err != nil {
+		return newError("failed to pass the handshake to the decoy").Base(err).AtInfo()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	timer := signal.CancelAfterInactivity(ctx, cancel, sessionPolicy.Timeouts.ConnectionIdle)
+
+	postRequest := func() error {
+		defer timer.SetTimeout(sessionPolicy.Timeouts.DownlinkOnly)
+		if err := buf.Copy(buf.NewReader(conn), serverWriter, buf.UpdateActivity(timer)); err != nil {
+			return newError("failed to pass request payload to the decoy").Base(err).AtInfo()
+		}
+		return nil
+	}
+
+	writer := buf.NewWriter(conn)
+
+	getResponse := func() error {
+		defer timer.SetTimeout(sessionPolicy.Timeouts.UplinkOnly)
+		if err := buf.Copy(serverReader, writer, buf.UpdateActivity(timer)); err != nil {
+			return newError("failed to deliver response payload of the decoy").Base(err).AtInfo()
+		}
+		return nil
+	}
+
+	if err := task.Run(ctx, task.OnSuccess(postRequest, task.Close(serverWriter)), task.OnSuccess(getResponse, task.Close(writer))); err != nil {
+		common.Interrupt(serverReader)
+		common.Interrupt(serverWriter)
+		return newError("decoy ends").Base(err).AtInfo()
+	}
+	return nil
+}
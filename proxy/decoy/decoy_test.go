@@ -0,0 +1,77 @@
+package decoy_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/features/policy"
+	. "github.com/xtls/xray-core/proxy/decoy"
+)
+
+func TestRecorderUnwrap(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	recorder := &Recorder{Reader: server}
+	reader := &buf.BufferedReader{Reader: buf.NewReader(recorder)}
+	go client.Write([]byte("handshake"))
+	b := make([]byte, 9)
+	common.Must2(io.ReadFull(reader, b))
+
+	recorder.Unwrap(reader)
+	if reader.Reader.(*buf.SingleReader).Reader != server {
+		t.Error("expected reader to read from the connection after unwrap")
+	}
+	go client.Write([]byte("payload"))
+	b = make([]byte, 7)
+	common.Must2(io.ReadFull(reader, b))
+	if string(b) != "payload" {
+		t.Error("unexpected payload: ", string(b))
+	}
+}
+
+func TestServe(t *testing.T) {
+	decoyListener, err := net.Listen("tcp", "127.0.0.1:0")
+	common.Must(err)
+	defer decoyListener.Close()
+	go func() {
+		conn, err := decoyListener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+
+	client, server := net.Pipe()
+	defer client.Close()
+
+	recorder := &Recorder{Reader: server}
+	go client.Write([]byte("GET / HTTP/1.1\r\n"))
+	b := make([]byte, 4)
+	common.Must2(io.ReadFull(recorder, b))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(context.Background(), &Config{Type: "tcp", Dest: decoyListener.Addr().String()}, server, recorder, policy.SessionDefault())
+	}()
+
+	// the decoy gets what the recorder kept, then the rest of the connection
+	response := make([]byte, 16)
+	common.Must2(io.ReadFull(client, response))
+	if string(response) != "GET / HTTP/1.1\r\n" {
+		t.Error("unexpected response: ", string(response))
+	}
+	client.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("decoy did not end with the connection")
+	}
+}
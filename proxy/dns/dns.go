@@ -43,11 +43,13 @@ type Handler struct {
 	ownLinkVerifier ownLinkVerifier
 	server          net.Destination
 	timeout         time.Duration
+	stripHTTPS      bool
 }
 
 func (h *Handler) Init(config *Config, dnsClient dns.Client, policyManager policy.Manager) error {
 	h.client = dnsClient
 	h.timeout = policyManager.ForLevel(config.UserLevel).Timeouts.ConnectionIdle
+	h.stripHTTPS = config.StripHttpsRecords
 
 	if v, ok := dnsClient.(ownLinkVerifier); ok {
 		h.ownLinkVerifier = v
@@ -78,15 +80,14 @@ func parseIPQuery(b []byte) (r bool, domain string, id uint16, qType dnsmessage.
 		return
 	}
 	qType = q.Type
-	if qType != dnsmessage.TypeA && qType != dnsmessage.TypeAAAA {
-		return
-	}
-
 	domain = q.Name.String()
-	r = true
+	r = qType == dnsmessage.TypeA || qType == dnsmessage.TypeAAAA
 	return
 }
 
+// typeHTTPS is the type of HTTPS records (RFC 9460), which dnsmessage does not name.
+const typeHTTPS = dnsmessage.Type(65)
+
 // Process implements proxy.Outbound.
 func (h *Handler) Process(ctx context.Context, link *transport.Link, d internet.Dialer) error {
 	outbound := session.OutboundFromContext(ctx)
@@ -168,7 +169,7 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, d internet.
 
 			if !h.isOwnLink(ctx) {
 				isIPQuery, domain, id, qType := parseIPQuery(b.Bytes())
-				if isIPQuery {
+				if isIPQuery || (h.stripHTTPS && qType == typeHTTPS) {
 					go h.handleIPQuery(id, qType, domain, writer)
 					continue
 				}
@@ -225,6 +226,8 @@ func (h *Handler) handleIPQuery(id uint16, qType dnsmessage.Type, domain string,
 			IPv6Enable: true,
 			FakeEnable: true,
 		})
+	case typeHTTPS:
+		err = dns.ErrEmptyResponse
 	}
 
 	rcode := dns.RCodeFromError(err)
@@ -66,6 +66,11 @@ func (*staticHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 			common.Must(err)
 			ans.Answer = append(ans.Answer, rr)
 
+		case q.Name == "google.com." && q.Qtype == dns.TypeHTTPS:
+			rr, err := dns.NewRR(`google.com. IN HTTPS 1 . alpn="h3,h2"`)
+			common.Must(err)
+			ans.Answer = append(ans.Answer, rr)
+
 		case q.Name == "notexist.google.com." && q.Qtype == dns.TypeAAAA:
 			ans.MsgHdr.Rcode = dns.RcodeNameError
 		}
@@ -123,7 +128,7 @@ func TestUDPDNSTunnel(t *testing.T) {
 		},
 		Outbound: []*core.OutboundHandlerConfig{
 			{
-				ProxySettings: serial.ToTypedMessage(&dns_proxy.Config{}),
+				ProxySettings: serial.ToTypedMessage(&dns_proxy.Config{StripHttpsRecords: true}),
 			},
 		},
 	}
@@ -174,6 +179,22 @@ func TestUDPDNSTunnel(t *testing.T) {
 		}
 	}
 
+	{
+		m1 := new(dns.Msg)
+		m1.Id = dns.Id()
+		m1.RecursionDesired = true
+		m1.Question = make([]dns.Question, 1)
+		m1.Question[0] = dns.Question{Name: "google.com.", Qtype: dns.TypeHTTPS, Qclass: dns.ClassINET}
+
+		c := new(dns.Client)
+		in, _, err := c.Exchange(m1, "127.0.0.1:"+strconv.Itoa(int(serverPort)))
+		common.Must(err)
+
+		if in.Rcode != dns.RcodeSuccess || len(in.Answer) != 0 {
+			t.Error("expected empty answer, but got ", in.Rcode, " ", in.Answer)
+		}
+	}
+
 	{
 		m1 := new(dns.Msg)
 		m1.Id = dns.Id()
@@ -226,6 +226,11 @@ func (d *DokodemoDoor) Process(ctx context.Context, network net.Network, conn st
 		if err := buf.Copy(link.Reader, writer, buf.UpdateActivity(timer)); err != nil {
 			return newError("failed to transport response").Base(err)
 		}
+		if network == net.Network_TCP {
+			if err := stat.CloseWrite(conn); err != nil {
+				newError("failed to half-close connection").Base(err).AtDebug().WriteToLog(session.ExportIDToError(ctx))
+			}
+		}
 		return nil
 	}
 
@@ -0,0 +1,109 @@
+package freedom
+
+import (
+	"io"
+	"time"
+
+	"github.com/xtls/xray-core/common/dice"
+)
+
+const (
+	tlsRecordHeaderLen = 5
+	tlsHandshake       = 22
+)
+
+// FragmentWriter splits the writes selected by its Fragment into pieces, and waits between them.
+type FragmentWriter struct {
+	fragment *Fragment
+	writer   io.Writer
+	count    uint64
+}
+
+// NewFragmentWriter creates a new FragmentWriter.
+func NewFragmentWriter(writer io.Writer, fragment *Fragment) *FragmentWriter {
+	return &FragmentWriter{
+		fragment: fragment,
+		writer:   writer,
+	}
+}
+
+func (f *FragmentWriter) Write(b []byte) (int, error) {
+	f.count++
+
+	if f.fragment.PacketsFrom == 0 && f.fragment.PacketsTo == 1 {
+		if f.count != 1 || len(b) <= tlsRecordHeaderLen || b[0] != tlsHandshake {
+			return f.writer.Write(b)
+		}
+		return f.writeClientHello(b)
+	}
+
+	if f.count < f.fragment.PacketsFrom || f.count > f.fragment.PacketsTo {
+		return f.writer.Write(b)
+	}
+	for from := 0; from < len(b); {
+		to := from + f.length()
+		if to > len(b) {
+			to = len(b)
+		}
+		n, err := f.writer.Write(b[from:to])
+		from += n
+		if err != nil {
+			return from, err
+		}
+		if from < len(b) {
+			f.wait()
+		}
+	}
+	return len(b), nil
+}
+
+// writeClientHello sends the handshake record in b as several records, each with a part of its
+// payload. Servers reassemble them, but filters only see the first one.
+func (f *FragmentWriter) writeClientHello(b []byte) (int, error) {
+	recordLen := tlsRecordHeaderLen + (int(b[3])<<8 | int(b[4]))
+	if len(b) < recordLen {
+		// the record doesn't end in this write
+		return f.writer.Write(b)
+	}
+	data := b[tlsRecordHeaderLen:recordLen]
+	record := make([]byte, tlsRecordHeaderLen+f.fragment.LengthMax)
+	for from := 0; from < len(data); {
+		to := from + f.length()
+		if to > len(data) {
+			to = len(data)
+		}
+		copy(record, b[:3])
+		record[3] = byte((to - from) >> 8)
+		record[4] = byte(to - from)
+		l := copy(record[tlsRecordHeaderLen:], data[from:to])
+		if _, err := f.writer.Write(record[:tlsRecordHeaderLen+l]); err != nil {
+			return 0, err
+		}
+		from = to
+		if from < len(data) {
+			f.wait()
+		}
+	}
+	if len(b) > recordLen {
+		n, err := f.writer.Write(b[recordLen:])
+		return recordLen + n, err
+	}
+	return len(b), nil
+}
+
+func (f *FragmentWriter) length() int {
+	return int(randBetween(f.fragment.LengthMin, f.fragment.LengthMax))
+}
+
+func (f *FragmentWriter) wait() {
+	if d := randBetween(f.fragment.IntervalMin, f.fragment.IntervalMax); d > 0 {
+		time.Sleep(time.Duration(d) * time.Millisecond)
+	}
+}
+
+func randBetween(left, right uint64) uint64 {
+	if right <= left {
+		return left
+	}
+	return left + uint64(dice.Roll(int(right-left+1)))
+}
@@ -0,0 +1,58 @@
+package freedom_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/xtls/xray-core/common"
+	. "github.com/xtls/xray-core/proxy/freedom"
+)
+
+type recordingWriter struct {
+	writes [][]byte
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.writes = append(w.writes, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func TestFragmentClientHello(t *testing.T) {
+	payload := bytes.Repeat([]byte{'x'}, 250)
+	hello := append([]byte{22, 3, 1, byte(len(payload) >> 8), byte(len(payload))}, payload...)
+
+	w := &recordingWriter{}
+	writer := NewFragmentWriter(w, &Fragment{
+		PacketsFrom: 0,
+		PacketsTo:   1,
+		LengthMin:   100,
+		LengthMax:   100,
+	})
+	n, err := writer.Write(hello)
+	common.Must(err)
+	if n != len(hello) {
+		t.Error("unexpected written length: ", n)
+	}
+	if len(w.writes) != 3 {
+		t.Fatal("expect 3 records, got ", len(w.writes))
+	}
+	var reassembled []byte
+	for _, record := range w.writes {
+		if record[0] != 22 || record[1] != 3 || record[2] != 1 {
+			t.Error("unexpected record header: ", record[:3])
+		}
+		if l := int(record[3])<<8 | int(record[4]); l != len(record)-5 {
+			t.Error("unexpected record length: ", l)
+		}
+		reassembled = append(reassembled, record[5:]...)
+	}
+	if !bytes.Equal(reassembled, payload) {
+		t.Error("payload is not preserved")
+	}
+
+	// later writes are left alone
+	common.Must2(writer.Write(hello))
+	if len(w.writes) != 4 {
+		t.Error("expect the second write to be passed through")
+	}
+}
@@ -44,6 +44,9 @@ type Handler struct {
 
 // Init initializes the Handler with necessary parameters.
 func (h *Handler) Init(config *Config, pm policy.Manager, d dns.Client) error {
+	if f := config.Fragment; f != nil && (f.LengthMin == 0 || f.LengthMax < f.LengthMin) {
+		return newError("invalid fragment length ", f.LengthMin, "-", f.LengthMax)
+	}
 	h.config = config
 	h.policyManager = pm
 	h.dns = d
@@ -59,6 +62,25 @@ func (h *Handler) policy() policy.Session {
 	return p
 }
 
+// blocksPrivate returns whether the inbound of the connection refuses private destinations.
+func blocksPrivate(ctx context.Context) bool {
+	content := session.ContentFromContext(ctx)
+	return content != nil && content.Restriction != nil && content.Restriction.BlockPrivate
+}
+
+// checkPrivate returns an error if the inbound refuses the private address a connection is about to reach,
+// as the destination may be a domain that resolves to one.
+func checkPrivate(ctx context.Context, ip net.IP) error {
+	content := session.ContentFromContext(ctx)
+	if content == nil || content.Restriction == nil {
+		return nil
+	}
+	if ip != nil && content.Restriction.BlocksIP(ip) {
+		return newError("private destination ", ip, " is rejected by inbound policy")
+	}
+	return nil
+}
+
 func (h *Handler) resolveIP(ctx context.Context, domain string, localAddr net.Address) net.Address {
 	var option dns.IPOption = dns.IPOption{
 		IPv4Enable: true,
@@ -122,9 +144,25 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 	input := link.Reader
 	output := link.Writer
 
+	// The address is checked before dialing, and is the one dialed, so that a domain can't resolve to
+	// another address in between.
+	target := destination
+	if blocksPrivate(ctx) {
+		if target.Address.Family().IsDomain() {
+			ip := h.resolveIP(ctx, target.Address.Domain(), dialer.Address())
+			if ip == nil {
+				return newError("failed to resolve ", target.Address, " to check it against inbound policy")
+			}
+			target.Address = ip
+		}
+		if err := checkPrivate(ctx, target.Address.IP()); err != nil {
+			return newError("failed to open connection to ", destination).Base(err)
+		}
+	}
+
 	var conn stat.Connection
 	err := retry.ExponentialBackoff(5, 100).On(func() error {
-		dialDest := destination
+		dialDest := target
 		if h.config.useIP() && dialDest.Address.Family().IsDomain() {
 			ip := h.resolveIP(ctx, dialDest.Address.Domain(), dialer.Address())
 			if ip != nil {
@@ -149,6 +187,16 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 	}
 	defer conn.Close()
 
+	var unreachable *unreachableCheck
+	if destination.Network == net.Network_UDP {
+		if c := packetConnOf(conn); c != nil && c.EnableICMPErrors() {
+			unreachable = &unreachableCheck{
+				conn:     c,
+				fullCone: UDPOverride.Address == nil && UDPOverride.Port == 0,
+			}
+		}
+	}
+
 	plcy := h.policy()
 	ctx, cancel := context.WithCancel(ctx)
 	timer := signal.CancelAfterInactivity(ctx, cancel, plcy.Timeouts.ConnectionIdle)
@@ -158,15 +206,29 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 
 		var writer buf.Writer
 		if destination.Network == net.Network_TCP {
-			writer = buf.NewWriter(conn)
+			if h.config.Fragment != nil {
+				writer = buf.NewWriter(NewFragmentWriter(conn, h.config.Fragment))
+			} else {
+				writer = buf.NewWriter(conn)
+			}
 		} else {
 			writer = NewPacketWriter(conn, h, ctx, UDPOverride)
+			if w, ok := writer.(*PacketWriter); ok {
+				w.unreachable = unreachable
+			}
 		}
 
 		if err := buf.Copy(input, writer, buf.UpdateActivity(timer)); err != nil {
 			return newError("failed to process request").Base(err)
 		}
 
+		if destination.Network == net.Network_TCP {
+			// Pass the FIN on to the target, which may still be sending its response.
+			if err := stat.CloseWrite(conn); err != nil {
+				newError("failed to half-close connection").Base(err).AtDebug().WriteToLog(session.ExportIDToError(ctx))
+			}
+		}
+
 		return nil
 	}
 
@@ -178,6 +240,12 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 			reader = buf.NewReader(conn)
 		} else {
 			reader = NewPacketReader(conn, UDPOverride)
+			if unreachable != nil {
+				reader = &unreachableReader{
+					Reader:           reader,
+					unreachableCheck: unreachable,
+				}
+			}
 		}
 		if err := buf.Copy(reader, output, buf.UpdateActivity(timer)); err != nil {
 			return newError("failed to process response").Base(err)
@@ -193,6 +261,65 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 	return nil
 }
 
+func packetConnOf(conn net.Conn) *internet.PacketConnWrapper {
+	if statConn, ok := conn.(*stat.CounterConnection); ok {
+		conn = statConn.Connection
+	}
+	c, _ := conn.(*internet.PacketConnWrapper)
+	return c
+}
+
+// unreachableCheck decides whether an ICMP unreachable error ends a UDP session, instead of letting
+// the client send into a black hole until the session times out. In full-cone sessions, errors
+// about other destinations than the target are ignored.
+type unreachableCheck struct {
+	conn     *internet.PacketConnWrapper
+	fullCone bool
+}
+
+// check returns the error that ends the session, or nil if err is to be ignored.
+func (c *unreachableCheck) check(err error) error {
+	if !internet.IsUnreachable(err) {
+		return err
+	}
+	addrs := c.conn.ICMPErrors()
+	if !c.fullCone || len(addrs) == 0 || c.isTarget(addrs) {
+		return newError("destination ", c.conn.Dest, " is unreachable").Base(err)
+	}
+	newError("ignored unreachable destinations ", addrs).AtDebug().WriteToLog()
+	return nil
+}
+
+func (c *unreachableCheck) isTarget(addrs []net.Addr) bool {
+	target, ok := c.conn.Dest.(*net.UDPAddr)
+	if !ok {
+		return true
+	}
+	for _, addr := range addrs {
+		if a, ok := addr.(*net.UDPAddr); ok && a.Port == target.Port && a.IP.Equal(target.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+type unreachableReader struct {
+	buf.Reader
+	*unreachableCheck
+}
+
+func (r *unreachableReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	for {
+		mb, err := r.Reader.ReadMultiBuffer()
+		if err == nil {
+			return mb, nil
+		}
+		if err := r.check(err); err != nil {
+			return nil, err
+		}
+	}
+}
+
 func NewPacketReader(conn net.Conn, UDPOverride net.Destination) buf.Reader {
 	iConn := conn
 	statConn, ok := iConn.(*stat.CounterConnection)
@@ -265,6 +392,8 @@ type PacketWriter struct {
 	*Handler
 	context.Context
 	UDPOverride net.Destination
+	// a pending ICMP error fails the next write, which then has to be checked and retried
+	unreachable *unreachableCheck
 }
 
 func (w *PacketWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
@@ -294,7 +423,17 @@ func (w *PacketWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
 				b.Release()
 				continue
 			}
+			if err := checkPrivate(w.Context, destAddr.IP); err != nil {
+				newError("dropping packet to ", destAddr).Base(err).WriteToLog(session.ExportIDToError(w.Context))
+				b.Release()
+				continue
+			}
 			n, err = w.PacketConnWrapper.WriteTo(b.Bytes(), destAddr)
+			if err != nil && w.unreachable != nil {
+				if err = w.unreachable.check(err); err == nil {
+					n, err = w.PacketConnWrapper.WriteTo(b.Bytes(), destAddr)
+				}
+			}
 		} else {
 			n, err = w.PacketConnWrapper.Write(b.Bytes())
 		}
@@ -0,0 +1,73 @@
+package freedom_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	. "github.com/xtls/xray-core/proxy/freedom"
+	"github.com/xtls/xray-core/testing/mocks"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/internet/stat"
+	"github.com/xtls/xray-core/transport/pipe"
+)
+
+type recordingDialer struct {
+	dialed []net.Destination
+}
+
+func (d *recordingDialer) Dial(ctx context.Context, dest net.Destination) (stat.Connection, error) {
+	d.dialed = append(d.dialed, dest)
+	return nil, errors.New("unreachable")
+}
+
+func (*recordingDialer) Address() net.Address {
+	return nil
+}
+
+func TestBlockPrivateBeforeDial(t *testing.T) {
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+
+	mockDNS := mocks.NewDNSClient(mockCtl)
+	mockDNS.EXPECT().LookupIP(gomock.Eq("private.example.com"), gomock.Any()).Return([]net.IP{{10, 0, 0, 1}}, nil).AnyTimes()
+	mockDNS.EXPECT().LookupIP(gomock.Eq("public.example.com"), gomock.Any()).Return([]net.IP{{8, 8, 8, 8}}, nil).AnyTimes()
+
+	h := new(Handler)
+	common.Must(h.Init(&Config{}, nil, mockDNS))
+
+	for _, test := range []struct {
+		target net.Address
+		dialed net.Address
+	}{
+		{net.LocalHostIP, nil},
+		{net.ParseAddress("100.64.0.1"), nil},
+		{net.DomainAddress("private.example.com"), nil},
+		{net.DomainAddress("public.example.com"), net.ParseAddress("8.8.8.8")},
+	} {
+		ctx := session.ContextWithContent(context.Background(), &session.Content{
+			Restriction: &session.Restriction{BlockPrivate: true},
+		})
+		ctx = session.ContextWithOutbound(ctx, &session.Outbound{
+			Target: net.TCPDestination(test.target, 80),
+		})
+		reader, writer := pipe.New()
+		dialer := &recordingDialer{}
+		if err := h.Process(ctx, &transport.Link{Reader: reader, Writer: writer}, dialer); err == nil {
+			t.Error("expected the connection to ", test.target, " to fail")
+		}
+		if test.dialed == nil {
+			if len(dialer.dialed) > 0 {
+				t.Error("expected ", test.target, " not to be dialed, but dialed ", dialer.dialed)
+			}
+			continue
+		}
+		if len(dialer.dialed) == 0 || dialer.dialed[0].Address != test.dialed {
+			t.Error("expected ", test.target, " to be dialed at ", test.dialed, ", but dialed ", dialer.dialed)
+		}
+	}
+}
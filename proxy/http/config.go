@@ -26,3 +26,12 @@ func (sc *ServerConfig) HasAccount(username, password string) bool {
 	}
 	return p == password
 }
+
+// Authenticate returns the Proxy-Authenticate header challenging clients for credentials.
+func (sc *ServerConfig) Authenticate() string {
+	realm := sc.Realm
+	if realm == "" {
+		realm = "proxy"
+	}
+	return "Basic realm=\"" + realm + "\""
+}
@@ -0,0 +1,172 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/log"
+	"github.com/xtls/xray-core/common/net"
+	http_proto "github.com/xtls/xray-core/common/protocol/http"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/signal"
+	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/features/policy"
+	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/transport/internet/stat"
+	"github.com/xtls/xray-core/transport/internet/tls"
+	"golang.org/x/net/http2"
+)
+
+// negotiatedProtocol returns the protocol negotiated by ALPN if conn is a TLS connection.
+// The handshake is done by peeking at reader.
+func negotiatedProtocol(conn stat.Connection, reader *bufio.Reader) string {
+	iConn := conn
+	if statConn, ok := iConn.(*stat.CounterConnection); ok {
+		iConn = statConn.Connection
+	}
+	tlsConn, ok := iConn.(*tls.Conn)
+	if !ok {
+		return ""
+	}
+	if _, err := reader.Peek(1); err != nil {
+		return ""
+	}
+	return tlsConn.ConnectionState().NegotiatedProtocol
+}
+
+// bufferedConn is a connection whose first bytes were already read into reader.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// flushWriter flushes every write, so that tunneled data isn't held back in HTTP/2 buffers.
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (w flushWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.flush()
+	return n, err
+}
+
+func (w flushWriter) flush() {
+	if f, ok := w.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// serveH2 serves CONNECT requests over HTTP/2, each stream of conn being a tunnel.
+func (s *Server) serveH2(ctx context.Context, conn stat.Connection, reader *bufio.Reader, dispatcher routing.Dispatcher, inbound *session.Inbound) error {
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		newError("failed to clear read deadline").Base(err).WriteToLog(session.ExportIDToError(ctx))
+	}
+
+	var authFailures atomic.Int32
+	server := &http2.Server{
+		IdleTimeout: s.policy().Timeouts.ConnectionIdle,
+	}
+	server.ServeConn(&bufferedConn{Conn: conn, reader: reader}, &http2.ServeConnOpts{
+		Context: ctx,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+			ctx := session.ContextWithID(ctx, session.NewID())
+			// streams run concurrently, and each has its own user
+			var streamInbound *session.Inbound
+			if inbound != nil {
+				streamInbound = new(session.Inbound)
+				*streamInbound = *inbound
+				if inbound.User != nil {
+					user := *inbound.User
+					streamInbound.User = &user
+				}
+				ctx = session.ContextWithInbound(ctx, streamInbound)
+			}
+			if err := s.handleH2Connect(ctx, w, request, conn, dispatcher, streamInbound, &authFailures); err != nil {
+				newError("failed to process HTTP/2 request").Base(err).WriteToLog(session.ExportIDToError(ctx))
+			}
+		}),
+	})
+	return nil
+}
+
+func (s *Server) handleH2Connect(ctx context.Context, w http.ResponseWriter, request *http.Request, conn stat.Connection, dispatcher routing.Dispatcher, inbound *session.Inbound, authFailures *atomic.Int32) error {
+	if len(s.config.Accounts) > 0 {
+		user, pass, ok := parseBasicAuth(request.Header.Get("Proxy-Authorization"))
+		if !ok || !s.config.HasAccount(user, pass) {
+			w.Header().Set("Proxy-Authenticate", s.config.Authenticate())
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			if authFailures.Add(1) >= maxAuthFailures {
+				flushWriter{w: w}.flush()
+				conn.Close()
+				return newError("too many authentication failures").AtWarning()
+			}
+			return nil
+		}
+		if inbound != nil {
+			inbound.User.Email = user
+		}
+	}
+
+	newError("request to Method [", request.Method, "] Host [", request.Host, "] over HTTP/2").WriteToLog(session.ExportIDToError(ctx))
+	if request.Method != http.MethodConnect {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return newError("unsupported method: ", request.Method).AtWarning()
+	}
+	dest, err := http_proto.ParseHost(request.Host, net.Port(443))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return newError("malformed proxy host: ", request.Host).AtWarning().Base(err)
+	}
+	ctx = log.ContextWithAccessMessage(ctx, &log.AccessMessage{
+		From:   conn.RemoteAddr(),
+		To:     request.URL,
+		Status: log.AccessAccepted,
+		Reason: "",
+	})
+
+	plcy := s.policy()
+	ctx, cancel := context.WithCancel(ctx)
+	timer := signal.CancelAfterInactivity(ctx, cancel, plcy.Timeouts.ConnectionIdle)
+
+	ctx = policy.ContextWithBufferPolicy(ctx, plcy.Buffer)
+	link, err := dispatcher.Dispatch(ctx, dest)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		return err
+	}
+
+	writer := flushWriter{w: w}
+	w.WriteHeader(http.StatusOK)
+	writer.flush()
+
+	requestDone := func() error {
+		defer timer.SetTimeout(plcy.Timeouts.DownlinkOnly)
+
+		return buf.Copy(buf.NewReader(readerOnly{request.Body}), link.Writer, buf.UpdateActivity(timer))
+	}
+
+	responseDone := func() error {
+		defer timer.SetTimeout(plcy.Timeouts.UplinkOnly)
+
+		return buf.Copy(link.Reader, buf.NewWriter(writer), buf.UpdateActivity(timer))
+	}
+
+	closeWriter := task.OnSuccess(requestDone, task.Close(link.Writer))
+	if err := task.Run(ctx, closeWriter, responseDone); err != nil {
+		common.Interrupt(link.Reader)
+		common.Interrupt(link.Writer)
+		return newError("connection ends").Base(err)
+	}
+
+	return nil
+}
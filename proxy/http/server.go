@@ -61,6 +61,9 @@ func isTimeout(err error) bool {
 	return ok && nerr.Timeout()
 }
 
+// maxAuthFailures is how many requests with wrong credentials a connection may send before it is closed.
+const maxAuthFailures = 3
+
 func parseBasicAuth(auth string) (username, password string, ok bool) {
 	const prefix = "Basic "
 	if !strings.HasPrefix(auth, prefix) {
@@ -92,6 +95,14 @@ func (s *Server) Process(ctx context.Context, network net.Network, conn stat.Con
 
 	reader := bufio.NewReaderSize(readerOnly{conn}, buf.Size)
 
+	if err := conn.SetReadDeadline(time.Now().Add(s.policy().Timeouts.Handshake)); err != nil {
+		newError("failed to set read deadline").Base(err).WriteToLog(session.ExportIDToError(ctx))
+	}
+	if negotiatedProtocol(conn, reader) == "h2" {
+		return s.serveH2(ctx, conn, reader, dispatcher, inbound)
+	}
+
+	authFailures := 0
 Start:
 	if err := conn.SetReadDeadline(time.Now().Add(s.policy().Timeouts.Handshake)); err != nil {
 		newError("failed to set read deadline").Base(err).WriteToLog(session.ExportIDToError(ctx))
@@ -109,7 +120,29 @@ Start:
 	if len(s.config.Accounts) > 0 {
 		user, pass, ok := parseBasicAuth(request.Header.Get("Proxy-Authorization"))
 		if !ok || !s.config.HasAccount(user, pass) {
-			return common.Error2(conn.Write([]byte("HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: Basic realm=\"proxy\"\r\n\r\n")))
+			// Browsers retry with credentials on the same connection, so keep it open if the request has no body to skip,
+			// but not for guessing them over and over.
+			authFailures++
+			keepAlive := !request.Close && request.ContentLength == 0 && authFailures < maxAuthFailures
+			response := &http.Response{
+				Status:        "Proxy Authentication Required",
+				StatusCode:    407,
+				Proto:         "HTTP/1.1",
+				ProtoMajor:    1,
+				ProtoMinor:    1,
+				Header:        http.Header(make(map[string][]string)),
+				Body:          nil,
+				ContentLength: 0,
+				Close:         !keepAlive,
+			}
+			response.Header.Set("Proxy-Authenticate", s.config.Authenticate())
+			if err := response.Write(conn); err != nil {
+				return newError("failed to write back 407 response").Base(err)
+			}
+			if keepAlive {
+				goto Start
+			}
+			return nil
 		}
 		if inbound != nil {
 			inbound.User.Email = user
@@ -201,6 +234,9 @@ func (s *Server) handleConnect(ctx context.Context, _ *http.Request, reader *buf
 		if err := buf.Copy(link.Reader, v2writer, buf.UpdateActivity(timer)); err != nil {
 			return err
 		}
+		if err := stat.CloseWrite(conn); err != nil {
+			newError("failed to half-close connection").Base(err).AtDebug().WriteToLog(session.ExportIDToError(ctx))
+		}
 
 		return nil
 	}
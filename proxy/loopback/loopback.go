@@ -42,8 +42,11 @@ func (l *Loopback) Process(ctx context.Context, link *transport.Link, _ internet
 
 		ctx = session.ContextWithContent(ctx, content)
 
-		inbound := session.InboundFromContext(ctx)
-
+		// the connection enters routing again as one of inboundTag, without changing the inbound of its first pass
+		inbound := new(session.Inbound)
+		if original := session.InboundFromContext(ctx); original != nil {
+			*inbound = *original
+		}
 		inbound.Tag = l.config.InboundTag
 
 		ctx = session.ContextWithInbound(ctx, inbound)
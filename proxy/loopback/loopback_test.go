@@ -0,0 +1,58 @@
+package loopback
+
+import (
+	"context"
+	"testing"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/transport"
+	"github.com/xtls/xray-core/transport/pipe"
+)
+
+type testDispatcher struct {
+	inbound *session.Inbound
+}
+
+func (*testDispatcher) Type() interface{} { return routing.DispatcherType() }
+
+func (*testDispatcher) Start() error { return nil }
+
+func (*testDispatcher) Close() error { return nil }
+
+func (d *testDispatcher) Dispatch(ctx context.Context, dest net.Destination) (*transport.Link, error) {
+	d.inbound = session.InboundFromContext(ctx)
+	reader, writer := pipe.New(pipe.WithoutSizeLimit())
+	common.Must(writer.Close())
+	return &transport.Link{Reader: reader, Writer: writer}, nil
+}
+
+func (*testDispatcher) DispatchLink(ctx context.Context, dest net.Destination, link *transport.Link) error {
+	return nil
+}
+
+func TestLoopbackInboundTag(t *testing.T) {
+	dispatcher := new(testDispatcher)
+	l := new(Loopback)
+	common.Must(l.init(&Config{InboundTag: "second-pass"}, dispatcher))
+
+	original := &session.Inbound{Tag: "first-pass"}
+	ctx := session.ContextWithInbound(context.Background(), original)
+	ctx = session.ContextWithOutbound(ctx, &session.Outbound{
+		Target: net.TCPDestination(net.DomainAddress("example.com"), 443),
+	})
+
+	reader, writer := pipe.New(pipe.WithoutSizeLimit())
+	common.Must(writer.Close())
+	_, output := pipe.New(pipe.WithoutSizeLimit())
+	common.Must(l.Process(ctx, &transport.Link{Reader: reader, Writer: output}, nil))
+
+	if dispatcher.inbound == nil || dispatcher.inbound.Tag != "second-pass" {
+		t.Error("expect connection to be dispatched as inbound second-pass, got ", dispatcher.inbound)
+	}
+	if original.Tag != "first-pass" {
+		t.Error("inbound of the first pass changed to ", original.Tag)
+	}
+}
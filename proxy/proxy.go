@@ -47,3 +47,8 @@ type GetInbound interface {
 type GetOutbound interface {
 	GetOutbound() Outbound
 }
+
+// GetServerList is the interface for Outbounds that connect to a list of servers, which may be changed while they run.
+type GetServerList interface {
+	GetServerList() *protocol.ServerList
+}
@@ -21,6 +21,7 @@ import (
 
 // Client is a inbound handler for Shadowsocks protocol
 type Client struct {
+	serverList    *protocol.ServerList
 	serverPicker  protocol.ServerPicker
 	policyManager policy.Manager
 }
@@ -41,12 +42,18 @@ func NewClient(ctx context.Context, config *ClientConfig) (*Client, error) {
 
 	v := core.MustFromContext(ctx)
 	client := &Client{
+		serverList:    serverList,
 		serverPicker:  protocol.NewRoundRobinServerPicker(serverList),
 		policyManager: v.GetFeature(policy.ManagerType()).(policy.Manager),
 	}
 	return client, nil
 }
 
+// GetServerList implements proxy.GetServerList.
+func (c *Client) GetServerList() *protocol.ServerList {
+	return c.serverList
+}
+
 // Process implements OutboundHandler.Process().
 func (c *Client) Process(ctx context.Context, link *transport.Link, dialer internet.Dialer) error {
 	outbound := session.OutboundFromContext(ctx)
@@ -57,12 +64,20 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 	network := destination.Network
 
 	var server *protocol.ServerSpec
+	var user *protocol.MemoryUser
 	var conn stat.Connection
+	var uot bool
 
 	err := retry.ExponentialBackoff(5, 100).On(func() error {
 		server = c.serverPicker.PickServer()
+		user = server.PickUser()
 		dest := server.Destination()
 		dest.Network = network
+		account, ok := user.Account.(*MemoryAccount)
+		uot = ok && network == net.Network_UDP && account.UDPOverTCP
+		if uot {
+			dest.Network = net.Network_TCP
+		}
 		rawConn, err := dialer.Dial(ctx, dest)
 		if err != nil {
 			return err
@@ -74,7 +89,11 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 	if err != nil {
 		return newError("failed to find an available destination").AtWarning().Base(err)
 	}
-	newError("tunneling request to ", destination, " via ", network, ":", server.Destination().NetAddr()).WriteToLog(session.ExportIDToError(ctx))
+	if uot {
+		newError("tunneling request to ", destination, " via UoT:", server.Destination().NetAddr()).WriteToLog(session.ExportIDToError(ctx))
+	} else {
+		newError("tunneling request to ", destination, " via ", network, ":", server.Destination().NetAddr()).WriteToLog(session.ExportIDToError(ctx))
+	}
 
 	defer conn.Close()
 
@@ -83,13 +102,16 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 		Address: destination.Address,
 		Port:    destination.Port,
 	}
-	if destination.Network == net.Network_TCP {
+	if destination.Network == net.Network_TCP || uot {
 		request.Command = protocol.RequestCommandTCP
 	} else {
 		request.Command = protocol.RequestCommandUDP
 	}
+	if uot {
+		request.Address = net.DomainAddress(uotMagicAddress)
+		request.Port = 0
+	}
 
-	user := server.PickUser()
 	_, ok := user.Account.(*MemoryAccount)
 	if !ok {
 		return newError("user account is not valid")
@@ -108,6 +130,12 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 			if err != nil {
 				return newError("failed to write request").Base(err)
 			}
+			if uot {
+				bodyWriter = &UoTWriter{
+					Writer: bodyWriter,
+					Dest:   destination,
+				}
+			}
 
 			if err = buf.CopyOnceTimeout(link.Reader, bodyWriter, time.Millisecond*100); err != nil && err != buf.ErrNotTimeoutReader && err != buf.ErrReadTimeout {
 				return newError("failed to write A request payload").Base(err).AtWarning()
@@ -127,6 +155,11 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 			if err != nil {
 				return err
 			}
+			if uot {
+				responseReader = &UoTReader{
+					Reader: &buf.BufferedReader{Reader: responseReader},
+				}
+			}
 
 			return buf.Copy(responseReader, link.Writer, buf.UpdateActivity(timer))
 		}
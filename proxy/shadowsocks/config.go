@@ -21,6 +21,8 @@ import (
 type MemoryAccount struct {
 	Cipher Cipher
 	Key    []byte
+	// UDPOverTCP tells clients to send UDP packets over TCP.
+	UDPOverTCP bool
 
 	replayFilter antireplay.GeneralizedReplayFilter
 }
@@ -105,8 +107,9 @@ func (a *Account) AsAccount() (protocol.Account, error) {
 		return nil, newError("failed to get cipher").Base(err)
 	}
 	return &MemoryAccount{
-		Cipher: Cipher,
-		Key:    passwordToCipherKey([]byte(a.Password), Cipher.KeySize()),
+		Cipher:     Cipher,
+		Key:        passwordToCipherKey([]byte(a.Password), Cipher.KeySize()),
+		UDPOverTCP: a.UdpOverTcp,
 		replayFilter: func() antireplay.GeneralizedReplayFilter {
 			if a.IvCheck {
 				return antireplay.NewBloomRing()
@@ -116,6 +119,22 @@ func (a *Account) AsAccount() (protocol.Account, error) {
 	}, nil
 }
 
+func (c *ReplayProtection) createFilter() *antireplay.RingFilter {
+	capacity := c.Capacity
+	if capacity == 0 {
+		capacity = 1e6
+	}
+	rate := c.FalsePositiveRate
+	if rate <= 0 || rate >= 1 {
+		rate = 1e-6
+	}
+	slots := c.Slots
+	if slots == 0 {
+		slots = 10
+	}
+	return antireplay.NewRingFilter(slots, capacity, rate)
+}
+
 // Cipher is an interface for all Shadowsocks ciphers.
 type Cipher interface {
 	KeySize() int32
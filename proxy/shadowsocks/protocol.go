@@ -4,9 +4,11 @@ import (
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/binary"
 	"hash/crc32"
 	"io"
 
+	"github.com/sagernet/sing/common/uot"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/crypto"
@@ -28,6 +30,16 @@ var addrParser = protocol.NewAddressParser(
 	}),
 )
 
+// uotMagicAddress is the destination of a TCP connection carrying UDP packets, as in UDP over TCP of sing-box.
+const uotMagicAddress = uot.UOTMagicAddress
+
+// uotAddrParser parses the addresses of the packets in UDP over TCP.
+var uotAddrParser = protocol.NewAddressParser(
+	protocol.AddressFamilyByte(0x00, net.AddressFamilyIPv4),
+	protocol.AddressFamilyByte(0x01, net.AddressFamilyIPv6),
+	protocol.AddressFamilyByte(0x02, net.AddressFamilyDomain),
+)
+
 type FullReader struct {
 	reader io.Reader
 	buffer []byte
@@ -60,6 +72,10 @@ func ReadTCPSession(validator *Validator, reader io.Reader) (*protocol.RequestHe
 		return nil, nil, newError("failed to initialize drainer").Base(errDrain)
 	}
 
+	return readTCPSession(validator, reader, drainer)
+}
+
+func readTCPSession(validator *Validator, reader io.Reader, drainer drain.Drainer) (*protocol.RequestHeader, buf.Reader, error) {
 	var r buf.Reader
 	buffer := buf.New()
 	defer buffer.Release()
@@ -342,3 +358,62 @@ func (w *UDPWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
 	}
 	return nil
 }
+
+// UoTReader reads the UDP packets in a stream of UDP over TCP, each with its address.
+type UoTReader struct {
+	Reader io.Reader
+}
+
+func (r *UoTReader) ReadMultiBuffer() (buf.MultiBuffer, error) {
+	b := buf.New()
+	addr, port, err := uotAddrParser.ReadAddressPort(b, r.Reader)
+	if err != nil {
+		b.Release()
+		return nil, newError("failed to read UoT address").Base(err)
+	}
+	b.Clear()
+	if _, err := b.ReadFullFrom(r.Reader, 2); err != nil {
+		b.Release()
+		return nil, err
+	}
+	length := int32(binary.BigEndian.Uint16(b.Bytes()))
+	b.Clear()
+	if length > buf.Size {
+		b.Release()
+		return nil, newError("UoT packet too large: ", length)
+	}
+	if _, err := b.ReadFullFrom(r.Reader, length); err != nil {
+		b.Release()
+		return nil, err
+	}
+	dest := net.UDPDestination(addr, port)
+	b.UDP = &dest
+	return buf.MultiBuffer{b}, nil
+}
+
+// UoTWriter writes UDP packets into a stream of UDP over TCP. A packet without its own address goes to Dest.
+type UoTWriter struct {
+	Writer buf.Writer
+	Dest   net.Destination
+}
+
+func (w *UoTWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	framed := make(buf.MultiBuffer, 0, len(mb)*2)
+	for i, b := range mb {
+		dest := w.Dest
+		if b.UDP != nil {
+			dest = *b.UDP
+		}
+		header := buf.New()
+		if err := uotAddrParser.WriteAddressPort(header, dest.Address, dest.Port); err != nil {
+			header.Release()
+			buf.ReleaseMulti(framed)
+			buf.ReleaseMulti(mb[i:])
+			return newError("failed to write UoT address").Base(err)
+		}
+		binary.BigEndian.PutUint16(header.Extend(2), uint16(b.Len()))
+		b.UDP = nil
+		framed = append(framed, header, b)
+	}
+	return w.Writer.WriteMultiBuffer(framed)
+}
@@ -193,3 +193,42 @@ func TestUDPReaderWriter(t *testing.T) {
 		}
 	}
 }
+
+func TestUoTReaderWriter(t *testing.T) {
+	cache := buf.New()
+	defer cache.Release()
+
+	writer := &UoTWriter{
+		Writer: buf.NewWriter(cache),
+		Dest:   net.UDPDestination(net.DomainAddress("example.com"), 53),
+	}
+	reader := &UoTReader{
+		Reader: cache,
+	}
+
+	b1 := buf.New()
+	common.Must2(b1.WriteString("test payload"))
+	b2 := buf.New()
+	common.Must2(b2.WriteString("test payload 2"))
+	dest2 := net.UDPDestination(net.IPAddress([]byte{1, 2, 3, 4}), 443)
+	b2.UDP = &dest2
+	common.Must(writer.WriteMultiBuffer(buf.MultiBuffer{b1, b2}))
+
+	for _, expected := range []struct {
+		payload string
+		dest    net.Destination
+	}{
+		{"test payload", writer.Dest},
+		{"test payload 2", dest2},
+	} {
+		mb, err := reader.ReadMultiBuffer()
+		common.Must(err)
+		if mb[0].String() != expected.payload {
+			t.Error("unexpected output: ", mb[0].String())
+		}
+		if *mb[0].UDP != expected.dest {
+			t.Error("unexpected destination: ", mb[0].UDP)
+		}
+		buf.ReleaseMulti(mb)
+	}
+}
@@ -2,10 +2,15 @@ package shadowsocks
 
 import (
 	"context"
+	"io"
+	"sync"
 	"time"
 
 	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/antireplay"
 	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/drain"
+	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/log"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol"
@@ -16,6 +21,7 @@ import (
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/policy"
 	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/proxy/decoy"
 	"github.com/xtls/xray-core/transport/internet/stat"
 	"github.com/xtls/xray-core/transport/internet/udp"
 )
@@ -25,11 +31,23 @@ type Server struct {
 	validator     *Validator
 	policyManager policy.Manager
 	cone          bool
+	replayFilter  *antireplay.RingFilter
+	replaySaver   *task.Periodic
 }
 
 // NewServer create a new Shadowsocks server.
 func NewServer(ctx context.Context, config *ServerConfig) (*Server, error) {
 	validator := new(Validator)
+	var replayFilter *antireplay.RingFilter
+	if config.ReplayProtection != nil {
+		replayFilter = config.ReplayProtection.createFilter()
+		if path := config.ReplayProtection.Path; path != "" {
+			if err := replayFilter.Load(path); err != nil {
+				newError("failed to load replay filter from ", path).Base(err).AtWarning().WriteToLog()
+			}
+		}
+		validator.replayFilter = replayFilter
+	}
 	for _, user := range config.Users {
 		u, err := user.ToMemoryUser()
 		if err != nil {
@@ -47,11 +65,38 @@ func NewServer(ctx context.Context, config *ServerConfig) (*Server, error) {
 		validator:     validator,
 		policyManager: v.GetFeature(policy.ManagerType()).(policy.Manager),
 		cone:          ctx.Value("cone").(bool),
+		replayFilter:  replayFilter,
+	}
+	if replayFilter != nil && config.ReplayProtection.Path != "" {
+		s.replaySaver = &task.Periodic{
+			Interval: time.Minute,
+			Execute:  s.saveReplayFilter,
+		}
+		if err := s.replaySaver.Start(); err != nil {
+			return nil, err
+		}
 	}
 
 	return s, nil
 }
 
+func (s *Server) saveReplayFilter() error {
+	path := s.config.ReplayProtection.Path
+	if err := s.replayFilter.Save(path); err != nil {
+		newError("failed to save replay filter to ", path).Base(err).AtWarning().WriteToLog()
+	}
+	return nil
+}
+
+// Close implements common.Closable.
+func (s *Server) Close() error {
+	if s.replaySaver == nil {
+		return nil
+	}
+	s.replaySaver.Close()
+	return s.saveReplayFilter()
+}
+
 // AddUser implements proxy.UserManager.AddUser().
 func (s *Server) AddUser(ctx context.Context, u *protocol.MemoryUser) error {
 	return s.validator.Add(u)
@@ -187,8 +232,29 @@ func (s *Server) handleConnection(ctx context.Context, conn stat.Connection, dis
 		return newError("unable to set read deadline").Base(err).AtWarning()
 	}
 
-	bufferedReader := buf.BufferedReader{Reader: buf.NewReader(conn)}
-	request, bodyReader, err := ReadTCPSession(s.validator, &bufferedReader)
+	var request *protocol.RequestHeader
+	var bodyReader buf.Reader
+	var err error
+	if s.config.Decoy != nil {
+		// the decoy answers instead of draining
+		recorder := &decoy.Recorder{Reader: conn}
+		bufferedReader := buf.BufferedReader{Reader: buf.NewReader(recorder)}
+		request, bodyReader, err = readTCPSession(s.validator, &bufferedReader, drain.NewNopDrainer())
+		if err != nil && errors.Cause(err) != io.EOF {
+			log.Record(&log.AccessMessage{
+				From:   conn.RemoteAddr(),
+				To:     "",
+				Status: log.AccessRejected,
+				Reason: err,
+			})
+			newError("passing to the decoy").Base(err).AtInfo().WriteToLog(session.ExportIDToError(ctx))
+			return decoy.Serve(ctx, s.config.Decoy, conn, recorder, sessionPolicy)
+		}
+		recorder.Unwrap(&bufferedReader)
+	} else {
+		bufferedReader := buf.BufferedReader{Reader: buf.NewReader(conn)}
+		request, bodyReader, err = ReadTCPSession(s.validator, &bufferedReader)
+	}
 	if err != nil {
 		log.Record(&log.AccessMessage{
 			From:   conn.RemoteAddr(),
@@ -217,8 +283,12 @@ func (s *Server) handleConnection(ctx context.Context, conn stat.Connection, dis
 	newError("tunnelling request to ", dest).WriteToLog(session.ExportIDToError(ctx))
 
 	sessionPolicy = s.policyManager.ForLevel(request.User.Level)
+	if s.config.UdpOverTcp && dest.Address.Family().IsDomain() && dest.Address.Domain() == uotMagicAddress {
+		return s.handleUoT(ctx, conn, request, bodyReader, sessionPolicy, dispatcher)
+	}
 	ctx, cancel := context.WithCancel(ctx)
 	timer := signal.CancelAfterInactivity(ctx, cancel, sessionPolicy.Timeouts.ConnectionIdle)
+	inbound.Timer = timer
 
 	ctx = policy.ContextWithBufferPolicy(ctx, sessionPolicy.Buffer)
 	link, err := dispatcher.Dispatch(ctx, dest)
@@ -276,6 +346,60 @@ func (s *Server) handleConnection(ctx context.Context, conn stat.Connection, dis
 	return nil
 }
 
+// handleUoT relays the UDP packets of a UDP over TCP connection, each to its own destination.
+func (s *Server) handleUoT(ctx context.Context, conn stat.Connection, request *protocol.RequestHeader, bodyReader buf.Reader, sessionPolicy policy.Session, dispatcher routing.Dispatcher) error {
+	inbound := session.InboundFromContext(ctx)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	timer := signal.CancelAfterInactivity(ctx, cancel, sessionPolicy.Timeouts.ConnectionIdle)
+	inbound.Timer = timer
+
+	bufferedWriter := buf.NewBufferedWriter(buf.NewWriter(conn))
+	responseWriter, err := WriteTCPResponse(request, bufferedWriter)
+	if err != nil {
+		return newError("failed to write response").Base(err)
+	}
+	writer := &UoTWriter{Writer: responseWriter}
+	var writeAccess sync.Mutex
+
+	udpServer := udp.NewDispatcher(dispatcher, func(ctx context.Context, packet *udp_proto.Packet) {
+		writeAccess.Lock()
+		defer writeAccess.Unlock()
+
+		writer.Dest = packet.Source
+		if err := writer.WriteMultiBuffer(buf.MultiBuffer{packet.Payload}); err != nil {
+			newError("failed to write UoT response").Base(err).WriteToLog(session.ExportIDToError(ctx))
+			cancel()
+			return
+		}
+		if err := bufferedWriter.Flush(); err != nil {
+			cancel()
+			return
+		}
+		timer.Update()
+	})
+
+	reader := &UoTReader{Reader: &buf.BufferedReader{Reader: bodyReader}}
+	var dest *net.Destination
+	for {
+		mb, err := reader.ReadMultiBuffer()
+		if err != nil {
+			if errors.Cause(err) != io.EOF {
+				return newError("failed to read UoT request").Base(err)
+			}
+			return nil
+		}
+		timer.Update()
+		for _, payload := range mb {
+			destination := *payload.UDP
+			if !s.cone || dest == nil {
+				dest = &destination
+			}
+			udpServer.Dispatch(ctx, *dest, payload)
+		}
+	}
+}
+
 func init() {
 	common.Must(common.RegisterConfig((*ServerConfig)(nil), func(ctx context.Context, config interface{}) (interface{}, error) {
 		return NewServer(ctx, config.(*ServerConfig))
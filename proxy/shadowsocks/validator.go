@@ -8,6 +8,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/xtls/xray-core/common/antireplay"
 	"github.com/xtls/xray-core/common/dice"
 	"github.com/xtls/xray-core/common/protocol"
 )
@@ -19,6 +20,9 @@ type Validator struct {
 
 	behaviorSeed  uint64
 	behaviorFused bool
+
+	// replay filter shared by all users, overriding their own
+	replayFilter antireplay.GeneralizedReplayFilter
 }
 
 var ErrNotFound = newError("Not Found")
@@ -32,6 +36,9 @@ func (v *Validator) Add(u *protocol.MemoryUser) error {
 	if !account.Cipher.IsAEAD() && len(v.users) > 0 {
 		return newError("The cipher is not support Single-port Multi-user")
 	}
+	if v.replayFilter != nil {
+		account.replayFilter = v.replayFilter
+	}
 	v.users = append(v.users, u)
 
 	if !v.behaviorFused {
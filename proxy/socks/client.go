@@ -22,6 +22,7 @@ import (
 
 // Client is a Socks5 client.
 type Client struct {
+	serverList    *protocol.ServerList
 	serverPicker  protocol.ServerPicker
 	policyManager policy.Manager
 	version       Version
@@ -44,6 +45,7 @@ func NewClient(ctx context.Context, config *ClientConfig) (*Client, error) {
 
 	v := core.MustFromContext(ctx)
 	c := &Client{
+		serverList:    serverList,
 		serverPicker:  protocol.NewRoundRobinServerPicker(serverList),
 		policyManager: v.GetFeature(policy.ManagerType()).(policy.Manager),
 		version:       config.Version,
@@ -55,6 +57,11 @@ func NewClient(ctx context.Context, config *ClientConfig) (*Client, error) {
 	return c, nil
 }
 
+// GetServerList implements proxy.GetServerList.
+func (c *Client) GetServerList() *protocol.ServerList {
+	return c.serverList
+}
+
 // Process implements proxy.Outbound.Process.
 func (c *Client) Process(ctx context.Context, link *transport.Link, dialer internet.Dialer) error {
 	outbound := session.OutboundFromContext(ctx)
@@ -177,6 +177,11 @@ func (s *Server) transport(ctx context.Context, reader io.Reader, writer io.Writ
 		if err := buf.Copy(link.Reader, v2writer, buf.UpdateActivity(timer)); err != nil {
 			return newError("failed to transport all TCP response").Base(err)
 		}
+		if conn, ok := writer.(net.Conn); ok {
+			if err := stat.CloseWrite(conn); err != nil {
+				newError("failed to half-close connection").Base(err).AtDebug().WriteToLog(session.ExportIDToError(ctx))
+			}
+		}
 
 		return nil
 	}
@@ -15,6 +15,7 @@ import (
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/common/signal"
 	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/common/xudp"
 	core "github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/policy"
 	"github.com/xtls/xray-core/features/stats"
@@ -26,8 +27,10 @@ import (
 
 // Client is a inbound handler for trojan protocol
 type Client struct {
+	serverList    *protocol.ServerList
 	serverPicker  protocol.ServerPicker
 	policyManager policy.Manager
+	encoding      xudp.PacketEncoding
 }
 
 // NewClient create a new trojan client.
@@ -46,12 +49,19 @@ func NewClient(ctx context.Context, config *ClientConfig) (*Client, error) {
 
 	v := core.MustFromContext(ctx)
 	client := &Client{
+		serverList:    serverList,
 		serverPicker:  protocol.NewRoundRobinServerPicker(serverList),
 		policyManager: v.GetFeature(policy.ManagerType()).(policy.Manager),
+		encoding:      config.PacketEncoding,
 	}
 	return client, nil
 }
 
+// GetServerList implements proxy.GetServerList.
+func (c *Client) GetServerList() *protocol.ServerList {
+	return c.serverList
+}
+
 // Process implements OutboundHandler.Process().
 func (c *Client) Process(ctx context.Context, link *transport.Link, dialer internet.Dialer) error {
 	outbound := session.OutboundFromContext(ctx)
@@ -81,7 +91,7 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 
 	defer conn.Close()
 
-	iConn := conn
+	iConn := stat.Unwrap(conn)
 	statConn, ok := iConn.(*stat.CounterConnection)
 	if ok {
 		iConn = statConn.Connection
@@ -140,6 +150,13 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 		}
 	}
 
+	// XUDP carries UDP in Mux.Cool over a TCP request, which the server hands to its mux worker
+	target := destination
+	if destination.Network == net.Network_UDP && c.encoding == xudp.PacketEncoding_XUDP {
+		destination = net.TCPDestination(net.DomainAddress(muxCoolAddress), net.Port(666))
+		network = net.Network_TCP
+	}
+
 	sessionPolicy := c.policyManager.ForLevel(user.Level)
 	ctx, cancel := context.WithCancel(ctx)
 	timer := signal.CancelAfterInactivity(ctx, cancel, sessionPolicy.Timeouts.ConnectionIdle)
@@ -154,7 +171,9 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 		connWriter.Account = account
 
 		var bodyWriter buf.Writer
-		if destination.Network == net.Network_UDP {
+		if target.Network == net.Network_UDP && destination.Network == net.Network_TCP {
+			bodyWriter = xudp.NewPacketWriter(connWriter, target, xudp.GetGlobalID(ctx))
+		} else if destination.Network == net.Network_UDP {
 			bodyWriter = &PacketWriter{Writer: connWriter, Target: destination}
 		} else {
 			bodyWriter = connWriter
@@ -179,6 +198,12 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 			return newError("failed to transfer request payload").Base(err).AtInfo()
 		}
 
+		if target.Network == net.Network_TCP && connWriter.Flow == "" {
+			if err := stat.CloseWrite(conn); err != nil {
+				newError("failed to half-close connection").Base(err).AtDebug().WriteToLog(session.ExportIDToError(ctx))
+			}
+		}
+
 		return nil
 	}
 
@@ -186,7 +211,9 @@ func (c *Client) Process(ctx context.Context, link *transport.Link, dialer inter
 		defer timer.SetTimeout(sessionPolicy.Timeouts.UplinkOnly)
 
 		var reader buf.Reader
-		if network == net.Network_UDP {
+		if target.Network == net.Network_UDP && network == net.Network_TCP {
+			reader = xudp.NewPacketReader(conn)
+		} else if network == net.Network_UDP {
 			reader = &PacketReader{
 				Reader: conn,
 			}
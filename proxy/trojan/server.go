@@ -2,12 +2,14 @@ package trojan
 
 import (
 	"context"
+	"encoding/hex"
 	"io"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/xtls/xray-core/app/auth"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/errors"
@@ -73,6 +75,32 @@ func NewServer(ctx context.Context, config *ServerConfig) (*Server, error) {
 		cone:          ctx.Value("cone").(bool),
 	}
 
+	if config.ExternalAuth {
+		a := auth.FromInstance(v)
+		if a == nil {
+			return nil, newError("externalAuth requires the auth app").AtError()
+		}
+		validator.External = func(hash string) *protocol.MemoryUser {
+			// users are stored by the hex of their key, which is itself the hex SHA-224 of the password
+			key, err := hex.DecodeString(hash)
+			if err != nil {
+				return nil
+			}
+			r := a.Authenticate("trojan", string(key))
+			if r == nil {
+				return nil
+			}
+			return &protocol.MemoryUser{
+				Email: r.Email,
+				Level: r.Level,
+				Account: &MemoryAccount{
+					Key:  key,
+					Flow: r.Flow,
+				},
+			}
+		}
+	}
+
 	if config.Fallbacks != nil {
 		server.fallbacks = make(map[string]map[string]map[string]*Fallback)
 		for _, fb := range config.Fallbacks {
@@ -169,6 +197,11 @@ func (s *Server) Process(ctx context.Context, network net.Network, conn stat.Con
 		Buffer: buf.MultiBuffer{first},
 	}
 
+	inbound := session.InboundFromContext(ctx)
+	if inbound == nil {
+		panic("no inbound metadata")
+	}
+
 	var user *protocol.MemoryUser
 
 	napfb := s.fallbacks
@@ -199,6 +232,10 @@ func (s *Server) Process(ctx context.Context, network net.Network, conn stat.Con
 			})
 
 			shouldFallback = true
+		} else {
+			// attribute the connection to the user as soon as it is known
+			inbound.User = user
+			sessionPolicy = s.policyManager.ForLevel(user.Level)
 		}
 	}
 
@@ -215,6 +252,7 @@ func (s *Server) Process(ctx context.Context, network net.Network, conn stat.Con
 			To:     "",
 			Status: log.AccessRejected,
 			Reason: err,
+			Email:  user.Email,
 		})
 		return newError("failed to create request from: ", conn.RemoteAddr()).Base(err)
 	}
@@ -224,13 +262,6 @@ func (s *Server) Process(ctx context.Context, network net.Network, conn stat.Con
 		return newError("unable to set read deadline").Base(err).AtWarning()
 	}
 
-	inbound := session.InboundFromContext(ctx)
-	if inbound == nil {
-		panic("no inbound metadata")
-	}
-	inbound.User = user
-	sessionPolicy = s.policyManager.ForLevel(user.Level)
-
 	if destination.Network == net.Network_UDP { // handle udp request
 		return s.handleUDPPayload(ctx, &PacketReader{Reader: clientReader}, &PacketWriter{Writer: conn}, dispatcher)
 	}
@@ -347,6 +378,9 @@ func (s *Server) handleConnection(ctx context.Context, sessionPolicy policy.Sess
 ) error {
 	ctx, cancel := context.WithCancel(ctx)
 	timer := signal.CancelAfterInactivity(ctx, cancel, sessionPolicy.Timeouts.ConnectionIdle)
+	if inbound := session.InboundFromContext(ctx); inbound != nil {
+		inbound.Timer = timer
+	}
 	ctx = policy.ContextWithBufferPolicy(ctx, sessionPolicy.Buffer)
 
 	link, err := dispatcher.Dispatch(ctx, destination)
@@ -379,6 +413,11 @@ func (s *Server) handleConnection(ctx context.Context, sessionPolicy policy.Sess
 		if err := buf.Copy(link.Reader, clientWriter, buf.UpdateActivity(timer)); err != nil {
 			return newError("failed to write response").Base(err)
 		}
+		if _, ok := iConn.(*xtls.Conn); !ok {
+			if err := stat.CloseWrite(iConn); err != nil {
+				newError("failed to half-close connection").Base(err).AtDebug().WriteToLog(session.ExportIDToError(ctx))
+			}
+		}
 		return nil
 	}
 
@@ -12,9 +12,12 @@ type Validator struct {
 	// Considering email's usage here, map + sync.Mutex/RWMutex may have better performance.
 	email sync.Map
 	users sync.Map
+
+	// External looks up users that are not stored, such as with an external authenticator. May be nil.
+	External func(hash string) *protocol.MemoryUser
 }
 
-// Add a trojan user, Email must be empty or unique.
+// Add a trojan user, Email must be empty or unique, and so must the password.
 func (v *Validator) Add(u *protocol.MemoryUser) error {
 	if u.Email != "" {
 		_, loaded := v.email.LoadOrStore(strings.ToLower(u.Email), u)
@@ -22,7 +25,14 @@ func (v *Validator) Add(u *protocol.MemoryUser) error {
 			return newError("User ", u.Email, " already exists.")
 		}
 	}
-	v.users.Store(hexString(u.Account.(*MemoryAccount).Key), u)
+	// Users are told apart by the hash of their password only, so a duplicate would take over the
+	// traffic and the stats of the existing user.
+	if _, loaded := v.users.LoadOrStore(hexString(u.Account.(*MemoryAccount).Key), u); loaded {
+		if u.Email != "" {
+			v.email.Delete(strings.ToLower(u.Email))
+		}
+		return newError("User ", u.Email, " has the same password as an existing user.")
+	}
 	return nil
 }
 
@@ -47,5 +57,8 @@ func (v *Validator) Get(hash string) *protocol.MemoryUser {
 	if u != nil {
 		return u.(*protocol.MemoryUser)
 	}
+	if v.External != nil {
+		return v.External(hash)
+	}
 	return nil
 }
@@ -0,0 +1,29 @@
+package trojan_test
+
+import (
+	"testing"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/protocol"
+	. "github.com/xtls/xray-core/proxy/trojan"
+)
+
+func TestValidatorDuplicatePassword(t *testing.T) {
+	newUser := func(email, password string) *protocol.MemoryUser {
+		return &protocol.MemoryUser{
+			Email:   email,
+			Account: toAccount(&Account{Password: password}),
+		}
+	}
+
+	v := new(Validator)
+	common.Must(v.Add(newUser("a@example.com", "password")))
+	if err := v.Add(newUser("b@example.com", "password")); err == nil {
+		t.Fatal("expect error adding user with a duplicate password")
+	}
+	// the email of the rejected user is not taken
+	common.Must(v.Add(newUser("b@example.com", "another password")))
+
+	common.Must(v.Del("a@example.com"))
+	common.Must(v.Add(newUser("c@example.com", "password")))
+}
@@ -15,6 +15,7 @@ func (a *Account) AsAccount() (protocol.Account, error) {
 		ID:         protocol.NewID(id),
 		Flow:       a.Flow,       // needs parser here?
 		Encryption: a.Encryption, // needs parser here?
+		Transform:  a.Transform,
 	}, nil
 }
 
@@ -26,6 +27,8 @@ type MemoryAccount struct {
 	Flow string
 	// Encryption of the account. Used for client connections, and only accepts "none" for now.
 	Encryption string
+	// Transform is the payload transform of the account, or empty for none.
+	Transform string
 }
 
 // Equals implements protocol.Account.Equals().
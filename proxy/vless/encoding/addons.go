@@ -10,8 +10,8 @@ import (
 )
 
 func EncodeHeaderAddons(buffer *buf.Buffer, addons *Addons) error {
-	switch addons.Flow {
-	case vless.XRO, vless.XRD, vless.XRV:
+	switch {
+	case addons.Flow == vless.XRO, addons.Flow == vless.XRD, addons.Flow == vless.XRV, addons.Transform != "":
 		bytes, err := proto.Marshal(addons)
 		if err != nil {
 			return newError("failed to marshal addons protobuf value").Base(err)
@@ -52,6 +52,9 @@ func DecodeHeaderAddons(buffer *buf.Buffer, reader io.Reader) (*Addons, error) {
 		switch addons.Flow {
 		default:
 		}
+		if err := verifyTransform(addons); err != nil {
+			return nil, err
+		}
 	}
 
 	return addons, nil
@@ -59,6 +62,7 @@ func DecodeHeaderAddons(buffer *buf.Buffer, reader io.Reader) (*Addons, error) {
 
 // EncodeBodyAddons returns a Writer that auto-encrypt content written by caller.
 func EncodeBodyAddons(writer io.Writer, request *protocol.RequestHeader, addons *Addons) buf.Writer {
+	writer = transformWriter(writer, request, addons)
 	switch addons.Flow {
 	default:
 		if request.Command == protocol.RequestCommandUDP {
@@ -70,6 +74,7 @@ func EncodeBodyAddons(writer io.Writer, request *protocol.RequestHeader, addons
 
 // DecodeBodyAddons returns a Reader from which caller can fetch decrypted body.
 func DecodeBodyAddons(reader io.Reader, request *protocol.RequestHeader, addons *Addons) buf.Reader {
+	reader = TransformReader(reader, request, addons)
 	switch addons.Flow {
 	default:
 		if request.Command == protocol.RequestCommandUDP {
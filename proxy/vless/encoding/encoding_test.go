@@ -1,6 +1,9 @@
 package encoding_test
 
 import (
+	"bytes"
+	"crypto/rand"
+	"io"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -131,3 +134,89 @@ func TestMuxRequest(t *testing.T) {
 		t.Error(r)
 	}
 }
+
+func TestTransform(t *testing.T) {
+	user := &protocol.MemoryUser{
+		Level: 0,
+		Email: "test@example.com",
+	}
+	id := uuid.New()
+	account := &vless.Account{
+		Id:        id.String(),
+		Transform: TransformXorPad,
+	}
+	user.Account = toAccount(account)
+
+	expectedRequest := &protocol.RequestHeader{
+		Version: Version,
+		User:    user,
+		Command: protocol.RequestCommandTCP,
+		Address: net.DomainAddress("www.example.com"),
+		Port:    net.Port(443),
+	}
+	expectedAddons := &Addons{
+		Transform: TransformXorPad,
+		Seed:      NewSeed(),
+	}
+
+	buffer := buf.New()
+	defer buffer.Release()
+	common.Must(EncodeRequestHeader(buffer, expectedRequest, expectedAddons))
+
+	payload := make([]byte, 4096)
+	common.Must2(rand.Read(payload))
+	writer := EncodeBodyAddons(buffer, expectedRequest, expectedAddons)
+	common.Must(writer.WriteMultiBuffer(buf.MergeBytes(nil, payload)))
+	if bytes.Contains(buffer.Bytes(), payload[:64]) {
+		t.Error("payload is not transformed")
+	}
+
+	Validator := new(vless.Validator)
+	Validator.Add(user)
+
+	actualRequest, actualAddons, _, err := DecodeRequestHeader(false, nil, buffer, Validator)
+	common.Must(err)
+	if actualAddons.Transform != TransformXorPad {
+		t.Error("transform: ", actualAddons.Transform)
+	}
+
+	reader := DecodeBodyAddons(buffer, actualRequest, actualAddons)
+	received := make([]byte, len(payload))
+	common.Must2(io.ReadFull(&buf.BufferedReader{Reader: reader}, received))
+	if r := cmp.Diff(received, payload); r != "" {
+		t.Error(r)
+	}
+}
+
+func TestUnknownTransform(t *testing.T) {
+	user := &protocol.MemoryUser{
+		Level: 0,
+		Email: "test@example.com",
+	}
+	id := uuid.New()
+	account := &vless.Account{
+		Id: id.String(),
+	}
+	user.Account = toAccount(account)
+
+	request := &protocol.RequestHeader{
+		Version: Version,
+		User:    user,
+		Command: protocol.RequestCommandTCP,
+		Address: net.DomainAddress("www.example.com"),
+		Port:    net.Port(443),
+	}
+
+	buffer := buf.StackNew()
+	common.Must(EncodeRequestHeader(&buffer, request, &Addons{
+		Transform: "unknown",
+		Seed:      NewSeed(),
+	}))
+
+	Validator := new(vless.Validator)
+	Validator.Add(user)
+
+	if _, _, _, err := DecodeRequestHeader(false, nil, &buffer, Validator); err == nil {
+		t.Error("nil error")
+	}
+}
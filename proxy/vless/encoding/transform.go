@@ -0,0 +1,207 @@
+package encoding
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"sync"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/dice"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/proxy/vless"
+	"golang.org/x/crypto/chacha20"
+)
+
+// A Transform changes the look of the body on the wire. The client asks for one by name in the
+// request addons, along with a random seed, and the server answers with a seed of its own for the
+// response. New transforms can be registered without changing the header format, so that a
+// transform caught by DPI can be replaced quickly.
+type Transform interface {
+	NewWriter(key []byte, writer io.Writer) TransformWriter
+	NewReader(key []byte, reader io.Reader) io.Reader
+}
+
+// TransformWriter is the writer of a Transform.
+type TransformWriter interface {
+	io.Writer
+	buf.Writer
+}
+
+const (
+	// TransformXorPad XORs the body with a keystream and adds random padding to every write.
+	TransformXorPad = "xorpad"
+
+	seedSize = 16
+)
+
+var (
+	transformAccess sync.RWMutex
+	transforms      = make(map[string]Transform)
+)
+
+// RegisterTransform registers a Transform under the given name.
+func RegisterTransform(name string, t Transform) {
+	transformAccess.Lock()
+	defer transformAccess.Unlock()
+	transforms[name] = t
+}
+
+// GetTransform returns the Transform of the given name.
+func GetTransform(name string) (Transform, bool) {
+	transformAccess.RLock()
+	defer transformAccess.RUnlock()
+	t, found := transforms[name]
+	return t, found
+}
+
+// NewSeed returns a random seed for the addons.
+func NewSeed() []byte {
+	seed := make([]byte, seedSize)
+	common.Must2(rand.Read(seed))
+	return seed
+}
+
+func transformKey(request *protocol.RequestHeader, addons *Addons) []byte {
+	h := sha256.New()
+	h.Write(addons.Seed)
+	h.Write(request.User.Account.(*vless.MemoryAccount).ID.Bytes())
+	return h.Sum(nil)
+}
+
+func verifyTransform(addons *Addons) error {
+	if addons.Transform == "" {
+		return nil
+	}
+	if _, found := GetTransform(addons.Transform); !found {
+		return newError("unknown transform: ", addons.Transform)
+	}
+	if len(addons.Seed) < seedSize {
+		return newError("seed too short for transform ", addons.Transform)
+	}
+	return nil
+}
+
+// TransformReader returns a Reader of the body with the transform in addons undone.
+func TransformReader(reader io.Reader, request *protocol.RequestHeader, addons *Addons) io.Reader {
+	if t, found := GetTransform(addons.Transform); found {
+		return t.NewReader(transformKey(request, addons), reader)
+	}
+	return reader
+}
+
+func transformWriter(writer io.Writer, request *protocol.RequestHeader, addons *Addons) io.Writer {
+	if t, found := GetTransform(addons.Transform); found {
+		return t.NewWriter(transformKey(request, addons), writer)
+	}
+	return writer
+}
+
+const (
+	xorPadHeaderSize = 3
+	xorPadMaxPayload = 65535
+)
+
+type xorPad struct{}
+
+func newKeyStream(key []byte) cipher.Stream {
+	// every key is used once, so the nonce can be fixed
+	stream, err := chacha20.NewUnauthenticatedCipher(key, make([]byte, chacha20.NonceSize))
+	common.Must(err)
+	return stream
+}
+
+func (xorPad) NewWriter(key []byte, writer io.Writer) TransformWriter {
+	return &xorPadWriter{
+		writer: writer,
+		stream: newKeyStream(key),
+	}
+}
+
+func (xorPad) NewReader(key []byte, reader io.Reader) io.Reader {
+	return &xorPadReader{
+		reader: reader,
+		stream: newKeyStream(key),
+	}
+}
+
+// xorPadWriter writes frames of [length uint16][padding length uint8][payload][padding].
+type xorPadWriter struct {
+	writer io.Writer
+	stream cipher.Stream
+}
+
+func (w *xorPadWriter) Write(b []byte) (int, error) {
+	n := 0
+	for len(b) > 0 {
+		size := len(b)
+		if size > xorPadMaxPayload {
+			size = xorPadMaxPayload
+		}
+		padding := dice.Roll(256)
+		frame := make([]byte, xorPadHeaderSize+size+padding)
+		frame[0] = byte(size >> 8)
+		frame[1] = byte(size)
+		frame[2] = byte(padding)
+		copy(frame[xorPadHeaderSize:], b[:size])
+		w.stream.XORKeyStream(frame, frame)
+		if _, err := w.writer.Write(frame); err != nil {
+			return n, err
+		}
+		n += size
+		b = b[size:]
+	}
+	return n, nil
+}
+
+// WriteMultiBuffer implements buf.Writer.
+func (w *xorPadWriter) WriteMultiBuffer(mb buf.MultiBuffer) error {
+	defer buf.ReleaseMulti(mb)
+
+	for _, b := range mb {
+		if _, err := w.Write(b.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type xorPadReader struct {
+	reader  io.Reader
+	stream  cipher.Stream
+	header  [xorPadHeaderSize]byte
+	frame   []byte
+	pending []byte
+}
+
+func (r *xorPadReader) Read(b []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if _, err := io.ReadFull(r.reader, r.header[:]); err != nil {
+			return 0, err
+		}
+		r.stream.XORKeyStream(r.header[:], r.header[:])
+		size := int(r.header[0])<<8 | int(r.header[1])
+		total := size + int(r.header[2])
+		if cap(r.frame) < total {
+			r.frame = make([]byte, total)
+		}
+		frame := r.frame[:total]
+		if _, err := io.ReadFull(r.reader, frame); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		r.stream.XORKeyStream(frame, frame)
+		r.pending = frame[:size]
+	}
+	n := copy(b, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func init() {
+	RegisterTransform(TransformXorPad, xorPad{})
+}
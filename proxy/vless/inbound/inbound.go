@@ -15,6 +15,7 @@ import (
 	"unsafe"
 
 	"github.com/pires/go-proxyproto"
+	"github.com/xtls/xray-core/app/auth"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/errors"
@@ -26,6 +27,7 @@ import (
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/common/signal"
 	"github.com/xtls/xray-core/common/task"
+	"github.com/xtls/xray-core/common/uuid"
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/features/dns"
 	feature_inbound "github.com/xtls/xray-core/features/inbound"
@@ -82,6 +84,27 @@ func New(ctx context.Context, config *Config, dc dns.Client) (*Handler, error) {
 		dns:                   dc,
 	}
 
+	if config.ExternalAuth {
+		a := auth.FromInstance(v)
+		if a == nil {
+			return nil, newError("externalAuth requires the auth app").AtError()
+		}
+		handler.validator.External = func(id uuid.UUID) *protocol.MemoryUser {
+			r := a.Authenticate("vless", id.String())
+			if r == nil {
+				return nil
+			}
+			return &protocol.MemoryUser{
+				Email: r.Email,
+				Level: r.Level,
+				Account: &vless.MemoryAccount{
+					ID:   protocol.NewID(id),
+					Flow: r.Flow,
+				},
+			}
+		}
+	}
+
 	for _, user := range config.Clients {
 		u, err := user.ToMemoryUser()
 		if err != nil {
@@ -462,6 +485,16 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 	responseAddons := &encoding.Addons{
 		// Flow: requestAddons.Flow,
 	}
+	if requestAddons.Transform != account.Transform {
+		return newError(account.ID.String() + " is not able to use transform [" + requestAddons.Transform + "]").AtWarning()
+	}
+	if requestAddons.Transform != "" {
+		if requestAddons.Flow != "" {
+			return newError("transform " + requestAddons.Transform + " can't be used with flow " + requestAddons.Flow).AtWarning()
+		}
+		responseAddons.Transform = requestAddons.Transform
+		responseAddons.Seed = encoding.NewSeed()
+	}
 
 	var netConn net.Conn
 	var rawConn syscall.RawConn
@@ -558,6 +591,7 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 	sessionPolicy = h.policyManager.ForLevel(request.User.Level)
 	ctx, cancel := context.WithCancel(ctx)
 	timer := signal.CancelAfterInactivity(ctx, cancel, sessionPolicy.Timeouts.ConnectionIdle)
+	inbound.Timer = timer
 	ctx = policy.ContextWithBufferPolicy(ctx, sessionPolicy.Buffer)
 
 	link, err := dispatcher.Dispatch(ctx, request.Destination())
@@ -656,6 +690,12 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 		}
 		// Indicates the end of response payload.
 		switch responseAddons.Flow {
+		case "":
+			if request.Command == protocol.RequestCommandTCP {
+				if err := stat.CloseWrite(connection); err != nil {
+					newError("failed to half-close connection").Base(err).AtDebug().WriteToLog(session.ExportIDToError(ctx))
+				}
+			}
 		default:
 		}
 
@@ -14,6 +14,7 @@ import (
 	utls "github.com/refraction-networking/utls"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/platform"
 	"github.com/xtls/xray-core/common/protocol"
@@ -56,6 +57,7 @@ type Handler struct {
 	serverPicker  protocol.ServerPicker
 	policyManager policy.Manager
 	cone          bool
+	encoding      xudp.PacketEncoding
 }
 
 // New creates a new VLess outbound handler.
@@ -75,11 +77,29 @@ func New(ctx context.Context, config *Config) (*Handler, error) {
 		serverPicker:  protocol.NewRoundRobinServerPicker(serverList),
 		policyManager: v.GetFeature(policy.ManagerType()).(policy.Manager),
 		cone:          ctx.Value("cone").(bool),
+		encoding:      config.PacketEncoding,
 	}
 
 	return handler, nil
 }
 
+// useXUDP returns whether UDP to the port is carried by XUDP.
+func (h *Handler) useXUDP(port net.Port) bool {
+	switch h.encoding {
+	case xudp.PacketEncoding_XUDP:
+		return true
+	case xudp.PacketEncoding_None:
+		return false
+	default:
+		return h.cone && port != 53 && port != 443
+	}
+}
+
+// GetServerList implements proxy.GetServerList.
+func (h *Handler) GetServerList() *protocol.ServerList {
+	return h.serverList
+}
+
 // Process implements proxy.Outbound.Process().
 func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer internet.Dialer) error {
 	var rec *protocol.ServerSpec
@@ -98,7 +118,7 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 	}
 	defer conn.Close()
 
-	iConn := conn
+	iConn := stat.Unwrap(conn)
 	statConn, ok := iConn.(*stat.CounterConnection)
 	if ok {
 		iConn = statConn.Connection
@@ -133,6 +153,13 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 	requestAddons := &encoding.Addons{
 		Flow: account.Flow,
 	}
+	if account.Transform != "" {
+		if account.Flow != "" {
+			return newError("transform ", account.Transform, " can't be used with flow ", account.Flow).AtWarning()
+		}
+		requestAddons.Transform = account.Transform
+		requestAddons.Seed = encoding.NewSeed()
+	}
 
 	var netConn net.Conn
 	var rawConn syscall.RawConn
@@ -217,7 +244,7 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 	var remainingServerHello int32 = -1
 	numberOfPacketToFilter := 8
 
-	if request.Command == protocol.RequestCommandUDP && h.cone && request.Port != 53 && request.Port != 443 {
+	if request.Command == protocol.RequestCommandUDP && h.useXUDP(request.Port) {
 		request.Command = protocol.RequestCommandMux
 		request.Address = net.DomainAddress("v1.mux.cool")
 		request.Port = net.Port(666)
@@ -234,7 +261,7 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 		// default: serverWriter := bufferWriter
 		serverWriter := encoding.EncodeBodyAddons(bufferWriter, request, requestAddons)
 		if request.Command == protocol.RequestCommandMux && request.Port == 666 {
-			serverWriter = xudp.NewPacketWriter(serverWriter, target)
+			serverWriter = xudp.NewPacketWriter(serverWriter, target, xudp.GetGlobalID(ctx))
 		}
 		userUUID := account.ID.Bytes()
 		timeoutReader, ok := clientReader.(buf.TimeoutReader)
@@ -297,6 +324,12 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 
 		// Indicates the end of request payload.
 		switch requestAddons.Flow {
+		case "":
+			if request.Command == protocol.RequestCommandTCP {
+				if err := stat.CloseWrite(conn); err != nil {
+					newError("failed to half-close connection").Base(err).AtDebug().WriteToLog(session.ExportIDToError(ctx))
+				}
+			}
 		default:
 		}
 		return nil
@@ -307,13 +340,16 @@ func (h *Handler) Process(ctx context.Context, link *transport.Link, dialer inte
 
 		responseAddons, err := encoding.DecodeResponseHeader(conn, request)
 		if err != nil {
-			return newError("failed to decode response header").Base(err).AtInfo()
+			return newError("failed to decode response header").Base(err).WithClass(errors.ClassOfResponse(err)).AtInfo()
+		}
+		if responseAddons.Transform != requestAddons.Transform {
+			return newError("server answered with transform [", responseAddons.Transform, "] instead of [", requestAddons.Transform, "]").AtWarning()
 		}
 
 		// default: serverReader := buf.NewReader(conn)
 		serverReader := encoding.DecodeBodyAddons(conn, request, responseAddons)
 		if request.Command == protocol.RequestCommandMux && request.Port == 666 {
-			serverReader = xudp.NewPacketReader(conn)
+			serverReader = xudp.NewPacketReader(encoding.TransformReader(conn, request, responseAddons))
 		}
 
 		if rawConn != nil {
@@ -13,6 +13,9 @@ type Validator struct {
 	// Considering email's usage here, map + sync.Mutex/RWMutex may have better performance.
 	email sync.Map
 	users sync.Map
+
+	// External looks up users that are not stored, such as with an external authenticator. May be nil.
+	External func(id uuid.UUID) *protocol.MemoryUser
 }
 
 // Add a VLESS user, Email must be empty or unique.
@@ -48,5 +51,8 @@ func (v *Validator) Get(id uuid.UUID) *protocol.MemoryUser {
 	if u != nil {
 		return u.(*protocol.MemoryUser)
 	}
+	if v.External != nil {
+		return v.External(id)
+	}
 	return nil
 }
@@ -7,6 +7,7 @@ import (
 	rand3 "crypto/rand"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"hash/crc32"
 	"io"
 	"math"
@@ -17,10 +18,14 @@ import (
 )
 
 var (
-	ErrNotFound = errors.New("user do not exist")
-	ErrReplay   = errors.New("replayed request")
+	ErrNotFound     = errors.New("user do not exist")
+	ErrReplay       = errors.New("replayed request")
+	ErrBadTimestamp = errors.New("timestamp beyond tolerance, check the clocks of both sides")
 )
 
+// DefaultTimestampTolerance is how far the time in an auth ID may be from the local time by default.
+const DefaultTimestampTolerance = 120 * time.Second
+
 func CreateAuthID(cmdKey []byte, time int64) [16]byte {
 	buf := bytes.NewBuffer(nil)
 	common.Must(binary.Write(buf, binary.BigEndian, time))
@@ -66,12 +71,20 @@ func (aidd *AuthIDDecoder) Decode(data [16]byte) (int64, uint32, int32, []byte)
 }
 
 func NewAuthIDDecoderHolder() *AuthIDDecoderHolder {
-	return &AuthIDDecoderHolder{make(map[string]*AuthIDDecoderItem), antireplay.NewReplayFilter(120)}
+	return NewAuthIDDecoderHolderWithTolerance(DefaultTimestampTolerance)
+}
+
+// NewAuthIDDecoderHolderWithTolerance creates an AuthIDDecoderHolder accepting auth IDs whose time is within
+// the tolerance of the local time. Replays are filtered over the same window.
+func NewAuthIDDecoderHolderWithTolerance(tolerance time.Duration) *AuthIDDecoderHolder {
+	seconds := int64(tolerance / time.Second)
+	return &AuthIDDecoderHolder{make(map[string]*AuthIDDecoderItem), antireplay.NewReplayFilter(seconds), seconds}
 }
 
 type AuthIDDecoderHolder struct {
-	decoders map[string]*AuthIDDecoderItem
-	filter   *antireplay.ReplayFilter
+	decoders  map[string]*AuthIDDecoderItem
+	filter    *antireplay.ReplayFilter
+	tolerance int64
 }
 
 type AuthIDDecoderItem struct {
@@ -95,6 +108,8 @@ func (a *AuthIDDecoderHolder) RemoveUser(key [16]byte) {
 }
 
 func (a *AuthIDDecoderHolder) Match(authID [16]byte) (interface{}, error) {
+	// the skew of an auth ID of a user, which is only rejected for its time
+	var skew int64
 	for _, v := range a.decoders {
 		t, z, _, d := v.dec.Decode(authID)
 		if z != crc32.ChecksumIEEE(d[:12]) {
@@ -105,7 +120,8 @@ func (a *AuthIDDecoderHolder) Match(authID [16]byte) (interface{}, error) {
 			continue
 		}
 
-		if math.Abs(math.Abs(float64(t))-float64(time.Now().Unix())) > 120 {
+		if diff := int64(math.Abs(float64(t) - float64(time.Now().Unix()))); diff > a.tolerance {
+			skew = t - time.Now().Unix()
 			continue
 		}
 
@@ -115,5 +131,8 @@ func (a *AuthIDDecoderHolder) Match(authID [16]byte) (interface{}, error) {
 
 		return v.ticket, nil
 	}
+	if skew != 0 {
+		return nil, fmt.Errorf("%w: %ds away from local time, while %ds is tolerated", ErrBadTimestamp, skew, a.tolerance)
+	}
 	return nil, ErrNotFound
 }
@@ -125,3 +125,19 @@ func TestCreateAuthIDAndDecodeSuperMassive(t *testing.T) {
 
 	fmt.Println(after.Sub(before).Seconds())
 }
+
+func TestAuthIDTimestampTolerance(t *testing.T) {
+	key := KDF16([]byte("Demo Key for Auth ID Test"), "Demo Path for Auth ID Test")
+	var keyw [16]byte
+	copy(keyw[:], key)
+
+	AuthDecoder := NewAuthIDDecoderHolderWithTolerance(time.Minute * 10)
+	AuthDecoder.AddUser(keyw, "Demo User")
+	res, err := AuthDecoder.Match(CreateAuthID(key, time.Now().Unix()-300))
+	assert.Equal(t, "Demo User", res)
+	assert.Nil(t, err)
+
+	res, err = AuthDecoder.Match(CreateAuthID(key, time.Now().Unix()+900))
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, ErrBadTimestamp)
+}
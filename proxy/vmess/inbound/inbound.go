@@ -24,7 +24,9 @@ import (
 	feature_inbound "github.com/xtls/xray-core/features/inbound"
 	"github.com/xtls/xray-core/features/policy"
 	"github.com/xtls/xray-core/features/routing"
+	"github.com/xtls/xray-core/proxy/decoy"
 	"github.com/xtls/xray-core/proxy/vmess"
+	"github.com/xtls/xray-core/proxy/vmess/aead"
 	"github.com/xtls/xray-core/proxy/vmess/encoding"
 	"github.com/xtls/xray-core/transport/internet/stat"
 )
@@ -113,19 +115,25 @@ type Handler struct {
 	detours               *DetourConfig
 	sessionHistory        *encoding.SessionHistory
 	secure                bool
+	decoy                 *decoy.Config
 }
 
 // New creates a new VMess inbound handler.
 func New(ctx context.Context, config *Config) (*Handler, error) {
 	v := core.MustFromContext(ctx)
+	tolerance := aead.DefaultTimestampTolerance
+	if config.TimestampTolerance > 0 {
+		tolerance = time.Duration(config.TimestampTolerance) * time.Second
+	}
 	handler := &Handler{
 		policyManager:         v.GetFeature(policy.ManagerType()).(policy.Manager),
 		inboundHandlerManager: v.GetFeature(feature_inbound.ManagerType()).(feature_inbound.Manager),
-		clients:               vmess.NewTimedUserValidator(protocol.DefaultIDHash),
+		clients:               vmess.NewTimedUserValidatorWithTolerance(protocol.DefaultIDHash, tolerance),
 		detours:               config.Detour,
 		usersByEmail:          newUserByEmail(config.GetDefaultValue()),
 		sessionHistory:        encoding.NewSessionHistory(),
 		secure:                config.SecureEncryptionOnly,
+		decoy:                 config.Decoy,
 	}
 
 	for _, user := range config.User {
@@ -239,7 +247,14 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 		_, isDrain = iConn.(*net.UnixConn)
 	}
 
+	var recorder *decoy.Recorder
 	reader := &buf.BufferedReader{Reader: buf.NewReader(connection)}
+	if h.decoy != nil {
+		// the decoy answers instead of draining
+		recorder = &decoy.Recorder{Reader: connection}
+		reader = &buf.BufferedReader{Reader: buf.NewReader(recorder)}
+		isDrain = false
+	}
 	svrSession := encoding.NewServerSession(h.clients, h.sessionHistory)
 	svrSession.SetAEADForced(aeadForced)
 	request, err := svrSession.DecodeRequestHeader(reader, isDrain)
@@ -252,9 +267,16 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 				Reason: err,
 			})
 			err = newError("invalid request from ", connection.RemoteAddr()).Base(err).AtInfo()
+			if recorder != nil {
+				newError("passing to the decoy").Base(err).AtInfo().WriteToLog(session.ExportIDToError(ctx))
+				return decoy.Serve(ctx, h.decoy, connection, recorder, sessionPolicy)
+			}
 		}
 		return err
 	}
+	if recorder != nil {
+		recorder.Unwrap(reader)
+	}
 
 	if h.secure && isInsecureEncryption(request.Security) {
 		log.Record(&log.AccessMessage{
@@ -293,6 +315,7 @@ func (h *Handler) Process(ctx context.Context, network net.Network, connection s
 
 	ctx, cancel := context.WithCancel(ctx)
 	timer := signal.CancelAfterInactivity(ctx, cancel, sessionPolicy.Timeouts.ConnectionIdle)
+	inbound.Timer = timer
 
 	ctx = policy.ContextWithBufferPolicy(ctx, sessionPolicy.Buffer)
 	link, err := dispatcher.Dispatch(ctx, request.Destination())
@@ -53,12 +53,18 @@ type indexTimePair struct {
 
 // NewTimedUserValidator creates a new TimedUserValidator.
 func NewTimedUserValidator(hasher protocol.IDHash) *TimedUserValidator {
+	return NewTimedUserValidatorWithTolerance(hasher, aead.DefaultTimestampTolerance)
+}
+
+// NewTimedUserValidatorWithTolerance creates a new TimedUserValidator, which accepts AEAD requests whose time is
+// within the tolerance of the local time.
+func NewTimedUserValidatorWithTolerance(hasher protocol.IDHash, tolerance time.Duration) *TimedUserValidator {
 	tuv := &TimedUserValidator{
 		users:             make([]*user, 0, 16),
 		userHash:          make(map[[16]byte]indexTimePair, 1024),
 		hasher:            hasher,
 		baseTime:          protocol.Timestamp(time.Now().Unix() - cacheDurationSec*2),
-		aeadDecoderHolder: aead.NewAuthIDDecoderHolder(),
+		aeadDecoderHolder: aead.NewAuthIDDecoderHolderWithTolerance(tolerance),
 	}
 	tuv.task = &task.Periodic{
 		Interval: updateInterval,
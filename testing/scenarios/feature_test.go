@@ -446,6 +446,178 @@ func TestBlackhole(t *testing.T) {
 	}
 }
 
+func TestInboundRestriction(t *testing.T) {
+	tcpServer := tcp.Server{
+		MsgProcessor: xor,
+	}
+	dest, err := tcpServer.Start()
+	common.Must(err)
+	defer tcpServer.Close()
+
+	dokodemoConfig := serial.ToTypedMessage(&dokodemo.Config{
+		Address: net.NewIPOrDomain(dest.Address),
+		Port:    uint32(dest.Port),
+		NetworkList: &net.NetworkList{
+			Network: []net.Network{net.Network_TCP},
+		},
+	})
+	blockedPort := tcp.PickPort()
+	allowedPort := tcp.PickPort()
+	sniffingPort := tcp.PickPort()
+	serverConfig := &core.Config{
+		Inbound: []*core.InboundHandlerConfig{
+			{
+				ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					PortList:     &net.PortList{Range: []*net.PortRange{net.SinglePortRange(blockedPort)}},
+					Listen:       net.NewIPOrDomain(net.LocalHostIP),
+					BlockedPorts: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(dest.Port)}},
+				}),
+				ProxySettings: dokodemoConfig,
+			},
+			{
+				ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					PortList:     &net.PortList{Range: []*net.PortRange{net.SinglePortRange(allowedPort)}},
+					Listen:       net.NewIPOrDomain(net.LocalHostIP),
+					BlockedPorts: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(25)}},
+				}),
+				ProxySettings: dokodemoConfig,
+			},
+			{
+				ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(sniffingPort)}},
+					Listen:   net.NewIPOrDomain(net.LocalHostIP),
+					SniffingSettings: &proxyman.SniffingConfig{
+						Enabled:   true,
+						RouteOnly: true,
+					},
+					BlockedProtocols: []string{"http"},
+				}),
+				ProxySettings: dokodemoConfig,
+			},
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+			},
+		},
+	}
+
+	servers, err := InitializeServerConfigs(serverConfig)
+	common.Must(err)
+	defer CloseAllServers(servers)
+
+	if err := testTCPConn(blockedPort, 1024, time.Second*5)(); err == nil {
+		t.Error("expected the blocked port to be rejected")
+	}
+	if err := testTCPConn(allowedPort, 1024, time.Second*5)(); err != nil {
+		t.Error(err)
+	}
+	// traffic that is not sniffed as HTTP passes
+	if err := testTCPConn(sniffingPort, 1024, time.Second*5)(); err != nil {
+		t.Error(err)
+	}
+
+	conn, err := net.DialTCP("tcp", nil, &net.TCPAddr{IP: []byte{127, 0, 0, 1}, Port: int(sniffingPort)})
+	common.Must(err)
+	defer conn.Close()
+	common.Must2(conn.Write([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")))
+	common.Must(conn.SetReadDeadline(time.Now().Add(5 * time.Second)))
+	if n, err := conn.Read(make([]byte, 64)); err != io.EOF {
+		t.Error("expected the HTTP request to be rejected, but got ", n, " bytes and ", err)
+	}
+}
+
+func TestStandbyOutbound(t *testing.T) {
+	tcpServer := tcp.Server{
+		MsgProcessor: xor,
+	}
+	dest, err := tcpServer.Start()
+	common.Must(err)
+	defer tcpServer.Close()
+
+	tcpServer2 := tcp.Server{
+		MsgProcessor: xor,
+	}
+	dest2, err := tcpServer2.Start()
+	common.Must(err)
+	defer tcpServer2.Close()
+
+	serverPort := tcp.PickPort()
+	serverPort2 := tcp.PickPort()
+	serverConfig := &core.Config{
+		Inbound: []*core.InboundHandlerConfig{
+			{
+				ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(serverPort)}},
+					Listen:   net.NewIPOrDomain(net.LocalHostIP),
+				}),
+				ProxySettings: serial.ToTypedMessage(&dokodemo.Config{
+					Address: net.NewIPOrDomain(dest.Address),
+					Port:    uint32(dest.Port),
+					NetworkList: &net.NetworkList{
+						Network: []net.Network{net.Network_TCP},
+					},
+				}),
+			},
+			{
+				ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(serverPort2)}},
+					Listen:   net.NewIPOrDomain(net.LocalHostIP),
+				}),
+				ProxySettings: serial.ToTypedMessage(&dokodemo.Config{
+					Address: net.NewIPOrDomain(dest2.Address),
+					Port:    uint32(dest2.Port),
+					NetworkList: &net.NetworkList{
+						Network: []net.Network{net.Network_TCP},
+					},
+				}),
+			},
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				Tag:           "direct",
+				ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+			},
+			{
+				Tag:           "blocked",
+				ProxySettings: serial.ToTypedMessage(&blackhole.Config{}),
+			},
+		},
+		App: []*serial.TypedMessage{
+			serial.ToTypedMessage(&router.Config{
+				Rule: []*router.RoutingRule{
+					{
+						TargetTag: &router.RoutingRule_Tag{
+							Tag: "blocked",
+						},
+						StandbyTag: "direct",
+						PortList:   &net.PortList{Range: []*net.PortRange{net.SinglePortRange(dest.Port)}},
+					},
+					{
+						TargetTag: &router.RoutingRule_Tag{
+							Tag: "direct",
+						},
+						StandbyTag: "blocked",
+						PortList:   &net.PortList{Range: []*net.PortRange{net.SinglePortRange(dest2.Port)}},
+					},
+				},
+			}),
+		},
+	}
+
+	servers, err := InitializeServerConfigs(serverConfig)
+	common.Must(err)
+	defer CloseAllServers(servers)
+
+	// the standby takes over from a primary that never answers
+	if err := testTCPConn(serverPort, 10240*1024, time.Second*20)(); err != nil {
+		t.Error(err)
+	}
+	if err := testTCPConn(serverPort2, 10240*1024, time.Second*20)(); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestForward(t *testing.T) {
 	tcpServer := tcp.Server{
 		MsgProcessor: xor,
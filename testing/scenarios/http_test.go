@@ -1,12 +1,15 @@
 package scenarios
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/rand"
+	gotls "crypto/tls"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"testing"
 	"time"
 
@@ -15,12 +18,16 @@ import (
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol/tls/cert"
 	"github.com/xtls/xray-core/common/serial"
 	"github.com/xtls/xray-core/core"
 	"github.com/xtls/xray-core/proxy/freedom"
 	v2http "github.com/xtls/xray-core/proxy/http"
 	v2httptest "github.com/xtls/xray-core/testing/servers/http"
 	"github.com/xtls/xray-core/testing/servers/tcp"
+	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/tls"
+	"golang.org/x/net/http2"
 )
 
 func TestHttpConformance(t *testing.T) {
@@ -367,3 +374,204 @@ func TestHttpBasicAuth(t *testing.T) {
 		}
 	}
 }
+
+func TestHttpBasicAuthKeepAlive(t *testing.T) {
+	httpServerPort := tcp.PickPort()
+	httpServer := &v2httptest.Server{
+		Port:        httpServerPort,
+		PathHandler: make(map[string]http.HandlerFunc),
+	}
+	_, err := httpServer.Start()
+	common.Must(err)
+	defer httpServer.Close()
+
+	serverPort := tcp.PickPort()
+	serverConfig := &core.Config{
+		Inbound: []*core.InboundHandlerConfig{
+			{
+				ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(serverPort)}},
+					Listen:   net.NewIPOrDomain(net.LocalHostIP),
+				}),
+				ProxySettings: serial.ToTypedMessage(&v2http.ServerConfig{
+					Accounts: map[string]string{
+						"a": "b",
+					},
+					Realm: "xray",
+				}),
+			},
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+			},
+		},
+	}
+
+	servers, err := InitializeServerConfigs(serverConfig)
+	common.Must(err)
+	defer CloseAllServers(servers)
+
+	conn, err := net.DialTCP("tcp", nil, &net.TCPAddr{
+		IP:   []byte{127, 0, 0, 1},
+		Port: int(serverPort),
+	})
+	common.Must(err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	req, err := http.NewRequest("GET", "http://127.0.0.1:"+httpServerPort.String(), nil)
+	common.Must(err)
+	common.Must(req.WriteProxy(conn))
+	resp, err := http.ReadResponse(reader, req)
+	common.Must(err)
+	if resp.StatusCode != 407 {
+		t.Fatal("status: ", resp.StatusCode)
+	}
+	if r := cmp.Diff(resp.Header.Get("Proxy-Authenticate"), `Basic realm="xray"`); r != "" {
+		t.Error(r)
+	}
+	common.Must(resp.Body.Close())
+
+	// the credentials are sent on the same connection
+	setProxyBasicAuth(req, "a", "b")
+	common.Must(req.WriteProxy(conn))
+	resp, err = http.ReadResponse(reader, req)
+	common.Must(err)
+	if resp.StatusCode != 200 {
+		t.Fatal("status: ", resp.StatusCode)
+	}
+	content, err := io.ReadAll(resp.Body)
+	common.Must(err)
+	if string(content) != "Home" {
+		t.Fatal("body: ", string(content))
+	}
+}
+
+func TestHttpBasicAuthGuessing(t *testing.T) {
+	serverPort := tcp.PickPort()
+	serverConfig := &core.Config{
+		Inbound: []*core.InboundHandlerConfig{
+			{
+				ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(serverPort)}},
+					Listen:   net.NewIPOrDomain(net.LocalHostIP),
+				}),
+				ProxySettings: serial.ToTypedMessage(&v2http.ServerConfig{
+					Accounts: map[string]string{
+						"a": "b",
+					},
+				}),
+			},
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+			},
+		},
+	}
+
+	servers, err := InitializeServerConfigs(serverConfig)
+	common.Must(err)
+	defer CloseAllServers(servers)
+
+	conn, err := net.DialTCP("tcp", nil, &net.TCPAddr{
+		IP:   []byte{127, 0, 0, 1},
+		Port: int(serverPort),
+	})
+	common.Must(err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	req, err := http.NewRequest("GET", "http://127.0.0.1/", nil)
+	common.Must(err)
+	for i := 0; i < 3; i++ {
+		setProxyBasicAuth(req, "a", "guess"+strconv.Itoa(i))
+		common.Must(req.WriteProxy(conn))
+		resp, err := http.ReadResponse(reader, req)
+		common.Must(err)
+		if resp.StatusCode != 407 {
+			t.Fatal("status: ", resp.StatusCode)
+		}
+		common.Must(resp.Body.Close())
+		if resp.Close != (i == 2) {
+			t.Error("unexpected Connection header of response ", i, ": ", resp.Header.Get("Connection"))
+		}
+	}
+	// the connection is closed after the third failure
+	if _, err := reader.ReadByte(); err != io.EOF {
+		t.Error("expected the connection to be closed, got ", err)
+	}
+}
+
+func TestHTTPConnectOverH2(t *testing.T) {
+	tcpServer := tcp.Server{
+		MsgProcessor: xor,
+	}
+	dest, err := tcpServer.Start()
+	common.Must(err)
+	defer tcpServer.Close()
+
+	serverPort := tcp.PickPort()
+	serverConfig := &core.Config{
+		Inbound: []*core.InboundHandlerConfig{
+			{
+				ReceiverSettings: serial.ToTypedMessage(&proxyman.ReceiverConfig{
+					PortList: &net.PortList{Range: []*net.PortRange{net.SinglePortRange(serverPort)}},
+					Listen:   net.NewIPOrDomain(net.LocalHostIP),
+					StreamSettings: &internet.StreamConfig{
+						SecurityType: serial.GetMessageType(&tls.Config{}),
+						SecuritySettings: []*serial.TypedMessage{
+							serial.ToTypedMessage(&tls.Config{
+								Certificate:  []*tls.Certificate{tls.ParseCertificate(cert.MustGenerate(nil))},
+								NextProtocol: []string{"h2", "http/1.1"},
+							}),
+						},
+					},
+				}),
+				ProxySettings: serial.ToTypedMessage(&v2http.ServerConfig{}),
+			},
+		},
+		Outbound: []*core.OutboundHandlerConfig{
+			{
+				ProxySettings: serial.ToTypedMessage(&freedom.Config{}),
+			},
+		},
+	}
+
+	servers, err := InitializeServerConfigs(serverConfig)
+	common.Must(err)
+	defer CloseAllServers(servers)
+
+	conn, err := gotls.Dial("tcp", "127.0.0.1:"+serverPort.String(), &gotls.Config{
+		NextProtos:         []string{"h2"},
+		InsecureSkipVerify: true,
+	})
+	common.Must(err)
+	defer conn.Close()
+	if p := conn.ConnectionState().NegotiatedProtocol; p != "h2" {
+		t.Fatal("negotiated protocol: ", p)
+	}
+	clientConn, err := (&http2.Transport{}).NewClientConn(conn)
+	common.Must(err)
+
+	pr, pw := io.Pipe()
+	req, err := http.NewRequest("CONNECT", "http://"+dest.NetAddr(), pr)
+	common.Must(err)
+	resp, err := clientConn.RoundTrip(req)
+	common.Must(err)
+	if resp.StatusCode != 200 {
+		t.Fatal("status: ", resp.StatusCode)
+	}
+
+	payload := make([]byte, 1024*64)
+	common.Must2(rand.Read(payload))
+	go pw.Write(payload)
+
+	content := make([]byte, len(payload))
+	common.Must2(io.ReadFull(resp.Body, content))
+	if r := cmp.Diff(content, xor(payload)); r != "" {
+		t.Fatal(r)
+	}
+	common.Must(pw.Close())
+}
@@ -10,9 +10,11 @@ import (
 	"github.com/xtls/xray-core/common/session"
 	"github.com/xtls/xray-core/features/dns"
 	"github.com/xtls/xray-core/features/outbound"
+	"github.com/xtls/xray-core/features/routing"
 	"github.com/xtls/xray-core/transport"
 	"github.com/xtls/xray-core/transport/internet/stat"
 	"github.com/xtls/xray-core/transport/pipe"
+	"google.golang.org/protobuf/proto"
 )
 
 // Dialer is the interface for dialing outbound connections.
@@ -68,6 +70,45 @@ func Dial(ctx context.Context, dest net.Destination, streamSettings *MemoryStrea
 	return nil, newError("unknown network ", dest.Network)
 }
 
+// OverrideDestination returns the destination a transport connects to when it dials address instead of dest,
+// as in domain fronting, where TLS and HTTP still name dest. The port of dest stays if address has none.
+func OverrideDestination(dest net.Destination, address string) (net.Destination, error) {
+	if address == "" {
+		return dest, nil
+	}
+	host, port := address, dest.Port
+	if h, p, err := net.SplitHostPort(address); err == nil {
+		if port, err = net.PortFromString(p); err != nil {
+			return dest, newError("invalid port in dial address ", address).Base(err)
+		}
+		host = h
+	}
+	return net.Destination{
+		Network: dest.Network,
+		Address: net.ParseAddress(host),
+		Port:    port,
+	}, nil
+}
+
+// overrideSockopt returns a copy of the socket options with the ones a route sets.
+func overrideSockopt(sockopt *SocketConfig, o *routing.SockoptOverride) *SocketConfig {
+	if sockopt == nil {
+		sockopt = &SocketConfig{}
+	} else {
+		sockopt = proto.Clone(sockopt).(*SocketConfig)
+	}
+	if o.Mark != 0 {
+		sockopt.Mark = o.Mark
+	}
+	if o.Interface != "" {
+		sockopt.Interface = o.Interface
+	}
+	if o.DSCP != 0 {
+		sockopt.Dscp = o.DSCP
+	}
+	return sockopt
+}
+
 var (
 	dnsClient dns.Client
 	obm       outbound.Manager
@@ -104,6 +145,15 @@ func lookupIP(domain string, strategy DomainStrategy, localAddr net.Address) ([]
 	return dnsClient.LookupIP(domain, option)
 }
 
+// LookupHTTPS returns the HTTPS record of the domain, or nil if the DNS does not look up HTTPS records.
+func LookupHTTPS(domain string) (*dns.HTTPSRecord, error) {
+	c, ok := dnsClient.(dns.HTTPSClient)
+	if !ok {
+		return nil, nil
+	}
+	return c.LookupHTTPS(domain)
+}
+
 func canLookupIP(ctx context.Context, dst net.Destination, sockopt *SocketConfig) bool {
 	if dst.Address.Family().IsIP() || dnsClient == nil {
 		return false
@@ -136,12 +186,17 @@ func DialSystem(ctx context.Context, dest net.Destination, sockopt *SocketConfig
 	if outbound := session.OutboundFromContext(ctx); outbound != nil {
 		src = outbound.Gateway
 	}
+	// taken before overriding, which copies the config
+	cache := sockopt.getResolveCache()
+	if o := session.SockoptOverrideFromContext(ctx); o != nil {
+		sockopt = overrideSockopt(sockopt, o)
+	}
 	if sockopt == nil {
 		return effectiveSystemDialer.Dial(ctx, src, dest, sockopt)
 	}
 
 	if canLookupIP(ctx, dest, sockopt) {
-		ips, err := lookupIP(dest.Address.String(), sockopt.DomainStrategy, src)
+		ips, err := cache.lookupIP(dest.Address.String(), sockopt.DomainStrategy, src)
 		if err == nil && len(ips) > 0 {
 			dest.Address = net.IPAddress(ips[dice.Roll(len(ips))])
 			newError("replace destination with " + dest.String()).AtInfo().WriteToLog()
@@ -4,9 +4,11 @@ import (
 	"context"
 	"testing"
 
+	"github.com/golang/mock/gomock"
 	"github.com/google/go-cmp/cmp"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/testing/mocks"
 	"github.com/xtls/xray-core/testing/servers/tcp"
 	. "github.com/xtls/xray-core/transport/internet"
 )
@@ -24,3 +26,97 @@ func TestDialWithLocalAddr(t *testing.T) {
 	}
 	conn.Close()
 }
+
+func TestOverrideDestination(t *testing.T) {
+	dest := net.TCPDestination(net.DomainAddress("example.com"), 443)
+	cases := []struct {
+		address string
+		want    net.Destination
+	}{
+		{"", dest},
+		{"front.example.org", net.TCPDestination(net.DomainAddress("front.example.org"), 443)},
+		{"1.2.3.4:8443", net.TCPDestination(net.ParseAddress("1.2.3.4"), 8443)},
+		{"2001:db8::1", net.TCPDestination(net.ParseAddress("2001:db8::1"), 443)},
+		{"[2001:db8::1]:80", net.TCPDestination(net.ParseAddress("2001:db8::1"), 80)},
+	}
+	for _, c := range cases {
+		got, err := OverrideDestination(dest, c.address)
+		common.Must(err)
+		if r := cmp.Diff(got.String(), c.want.String()); r != "" {
+			t.Error(c.address, ": ", r)
+		}
+	}
+	if _, err := OverrideDestination(dest, "1.2.3.4:http"); err == nil {
+		t.Error("expected error for invalid port")
+	}
+}
+
+func TestDialUDPSourcePort(t *testing.T) {
+	var servers []*net.UDPConn
+	for i := 0; i < 2; i++ {
+		server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.LocalHostIP.IP()})
+		common.Must(err)
+		defer server.Close()
+		servers = append(servers, server)
+	}
+
+	sourcePorts := func(sockopt *SocketConfig) []int {
+		conn, err := DialSystem(context.Background(), net.UDPDestination(net.LocalHostIP, net.Port(servers[0].LocalAddr().(*net.UDPAddr).Port)), sockopt)
+		common.Must(err)
+		defer conn.Close()
+
+		var ports []int
+		for _, server := range servers {
+			common.Must2(conn.(*PacketConnWrapper).WriteTo([]byte("ping"), server.LocalAddr()))
+			b := make([]byte, 16)
+			_, addr, err := server.ReadFrom(b)
+			common.Must(err)
+			ports = append(ports, addr.(*net.UDPAddr).Port)
+
+			common.Must2(server.WriteTo([]byte("pong"), addr))
+			n, from, err := conn.(*PacketConnWrapper).ReadFrom(b)
+			common.Must(err)
+			if string(b[:n]) != "pong" || from.String() != server.LocalAddr().String() {
+				t.Error("unexpected reply ", string(b[:n]), " from ", from)
+			}
+		}
+		return ports
+	}
+
+	if ports := sourcePorts(nil); ports[0] != ports[1] {
+		t.Error("expected one source port, but got ", ports)
+	}
+	if ports := sourcePorts(&SocketConfig{UdpSourcePort: SocketConfig_PerDestination}); ports[0] == ports[1] {
+		t.Error("expected a source port per destination, but got ", ports)
+	}
+}
+
+func TestDialResolveCache(t *testing.T) {
+	server := &tcp.Server{}
+	dest, err := server.Start()
+	common.Must(err)
+	defer server.Close()
+
+	mockCtl := gomock.NewController(t)
+	defer mockCtl.Finish()
+	mockDNS := mocks.NewDNSClient(mockCtl)
+	mockDNS.EXPECT().LookupIP("example.test", gomock.Any()).Return([]net.IP{net.LocalHostIP.IP()}, nil).Times(2)
+	InitSystemDialer(mockDNS, nil)
+	defer InitSystemDialer(nil, nil)
+
+	sockopt := &SocketConfig{
+		DomainStrategy:  DomainStrategy_USE_IP,
+		ResolveCacheTtl: 60,
+	}
+	for i := 0; i < 3; i++ {
+		conn, err := DialSystem(context.Background(), net.TCPDestination(net.DomainAddress("example.test"), dest.Port), sockopt)
+		common.Must(err)
+		conn.Close()
+	}
+
+	// a released cache looks up again
+	sockopt.ReleaseResolveCache()
+	conn, err := DialSystem(context.Background(), net.TCPDestination(net.DomainAddress("example.test"), dest.Port), sockopt)
+	common.Must(err)
+	conn.Close()
+}
@@ -28,7 +28,16 @@ func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.Me
 	}
 
 	if config := tls.ConfigFromStreamSettings(streamSettings); config != nil {
-		return tls.Client(conn, config.GetTLSConfig(tls.WithDestination(dest))), nil
+		tlsConfig := config.GetTLSConfig(tls.WithDestination(dest))
+		if fingerprint := tls.GetFingerprint(config.Fingerprint); fingerprint != nil {
+			uConn := tls.UClient(conn, tlsConfig, fingerprint).(*tls.UConn)
+			if err := uConn.Handshake(); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return uConn, nil
+		}
+		return tls.Client(conn, tlsConfig), nil
 	} else if config := xtls.ConfigFromStreamSettings(streamSettings); config != nil {
 		return xtls.Client(conn, config.GetXTLSConfig(xtls.WithDestination(dest))), nil
 	} else if config := reality.ConfigFromStreamSettings(streamSettings); config != nil {
@@ -0,0 +1,77 @@
+package internet
+
+import (
+	"sync"
+
+	"github.com/xtls/xray-core/features/stats"
+)
+
+// Transport events, counted per inbound or outbound in <inbound|outbound>>>>tag>>>transport>>>event.
+const (
+	// EventTLSHandshakeFailure is a TLS handshake that did not complete.
+	EventTLSHandshakeFailure = "tls_handshake_failure"
+	// EventH2GoAway is a GOAWAY frame received by an HTTP/2 dialer.
+	EventH2GoAway = "h2_goaway"
+	// EventWebSocketAbnormalClose is a WebSocket closed without a normal close frame.
+	EventWebSocketAbnormalClose = "ws_abnormal_close"
+	// EventQUICRetry is a Retry packet of a QUIC handshake, received by dialers and sent by listeners.
+	EventQUICRetry = "quic_retry"
+	// EventKCPSegment is an mKCP data segment sent for the first time.
+	EventKCPSegment = "kcp_segment"
+	// EventKCPRetransmit is an mKCP data segment sent again, so the retransmission rate is
+	// kcp_retransmit / kcp_segment.
+	EventKCPRetransmit = "kcp_retransmit"
+)
+
+// EventRecorder counts the transport events of an inbound or outbound. A nil EventRecorder counts nothing.
+type EventRecorder struct {
+	manager stats.Manager
+	prefix  string
+}
+
+// NewEventRecorder creates an EventRecorder counting events in the given stats manager, under
+// <prefix>>>>transport>>>event.
+func NewEventRecorder(manager stats.Manager, prefix string) *EventRecorder {
+	return &EventRecorder{
+		manager: manager,
+		prefix:  prefix + ">>>transport>>>",
+	}
+}
+
+// Record adds n to the counter of event.
+func (r *EventRecorder) Record(event string, n int64) {
+	if r == nil || n == 0 {
+		return
+	}
+	name := r.prefix + event
+	c, _ := stats.GetOrRegisterCounter(r.manager, name)
+	if c == nil {
+		c = r.manager.GetCounter(name)
+	}
+	if c != nil {
+		c.Add(n)
+	}
+}
+
+var (
+	certErrorHandlerAccess sync.RWMutex
+	certErrorHandler       func(fields map[string]string)
+)
+
+// RegisterCertErrorHandler sets the function called when a certificate fails to reload or to be issued. It lets
+// app packages watch certificates without the transport layer depending on them.
+func RegisterCertErrorHandler(handler func(fields map[string]string)) {
+	certErrorHandlerAccess.Lock()
+	certErrorHandler = handler
+	certErrorHandlerAccess.Unlock()
+}
+
+// ReportCertError calls the function set by RegisterCertErrorHandler, if any.
+func ReportCertError(fields map[string]string) {
+	certErrorHandlerAccess.RLock()
+	handler := certErrorHandler
+	certErrorHandlerAccess.RUnlock()
+	if handler != nil {
+		handler(fields)
+	}
+}
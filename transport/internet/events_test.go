@@ -0,0 +1,30 @@
+package internet_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/xtls/xray-core/app/stats"
+	"github.com/xtls/xray-core/common"
+	. "github.com/xtls/xray-core/transport/internet"
+)
+
+func TestEventRecorder(t *testing.T) {
+	m, err := stats.NewManager(context.Background(), &stats.Config{})
+	common.Must(err)
+
+	r := NewEventRecorder(m, "outbound>>>proxy")
+	r.Record(EventKCPSegment, 10)
+	r.Record(EventKCPSegment, 5)
+	r.Record(EventKCPRetransmit, 0)
+
+	if c := m.GetCounter("outbound>>>proxy>>>transport>>>kcp_segment"); c == nil || c.Value() != 15 {
+		t.Error("unexpected kcp_segment counter: ", c)
+	}
+	if c := m.GetCounter("outbound>>>proxy>>>transport>>>kcp_retransmit"); c != nil {
+		t.Error("expect no counter for events that did not happen")
+	}
+
+	var none *EventRecorder
+	none.Record(EventH2GoAway, 1)
+}
@@ -0,0 +1,55 @@
+package grpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet"
+	. "github.com/xtls/xray-core/transport/internet/grpc"
+	"github.com/xtls/xray-core/transport/internet/stat"
+)
+
+func TestListenAndDial(t *testing.T) {
+	for i, multiMode := range []bool{false, true} {
+		port := net.Port(13160 + i)
+		streamSettings := &internet.MemoryStreamConfig{
+			ProtocolName: "grpc",
+			ProtocolSettings: &Config{
+				ServiceName: "custom/🍉",
+				MultiMode:   multiMode,
+			},
+		}
+		listen, err := Listen(context.Background(), net.LocalHostIP, port, streamSettings, func(conn stat.Connection) {
+			go func(c stat.Connection) {
+				defer c.Close()
+
+				var b [1024]byte
+				n, err := c.Read(b[:])
+				if err != nil {
+					return
+				}
+
+				common.Must2(c.Write(append([]byte("Response: "), b[:n]...)))
+			}(conn)
+		})
+		common.Must(err)
+
+		for _, request := range []string{"Test connection 1", "Test connection 2"} {
+			conn, err := Dial(context.Background(), net.TCPDestination(net.LocalHostIP, port), streamSettings)
+			common.Must(err)
+			common.Must2(conn.Write([]byte(request)))
+
+			var b [1024]byte
+			n, err := conn.Read(b[:])
+			common.Must(err)
+			if string(b[:n]) != "Response: "+request {
+				t.Error("multiMode ", multiMode, ", response: ", string(b[:n]))
+			}
+			common.Must(conn.Close())
+		}
+
+		common.Must(listen.Close())
+	}
+}
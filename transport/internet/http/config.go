@@ -1,13 +1,21 @@
 package http
 
 import (
+	"time"
+
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/dice"
 	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/headers/http"
 )
 
 const protocolName = "http"
 
+const (
+	defaultPingInterval = 30 * time.Second
+	defaultPingTimeout  = 15 * time.Second
+)
+
 func (c *Config) getHosts() []string {
 	if len(c.Host) == 0 {
 		return []string{"www.example.com"}
@@ -40,6 +48,42 @@ func (c *Config) getNormalizedPath() string {
 	return c.Path
 }
 
+// healthCheck returns how long a connection may be idle before it is pinged, and how long to wait for the
+// answer, or zeros if connections are not checked.
+func (c *Config) healthCheck() (interval, timeout time.Duration) {
+	if c.IdleTimeout <= 0 && c.HealthCheckTimeout <= 0 {
+		return 0, 0
+	}
+	interval, timeout = defaultPingInterval, defaultPingTimeout
+	if c.IdleTimeout > 0 {
+		interval = time.Second * time.Duration(c.IdleTimeout)
+	}
+	if c.HealthCheckTimeout > 0 {
+		timeout = time.Second * time.Duration(c.HealthCheckTimeout)
+	}
+	return
+}
+
+func (c *Config) getResponseHeaders() []*http.Header {
+	if len(c.ResponseHeader) > 0 {
+		return c.ResponseHeader
+	}
+	return c.Header
+}
+
+func (c *Config) getResponseStatus() int {
+	if c.ResponseStatus == 0 {
+		return 200
+	}
+	return int(c.ResponseStatus)
+}
+
+// acceptsHTTP1 returns whether listeners accept HTTP/1.1 streams. It is opt-in, as it changes how listeners
+// respond to HTTP/1.1 requests, and so their fingerprint.
+func (c *Config) acceptsHTTP1() bool {
+	return c.Mode == Config_AUTO || c.Mode == Config_HTTP1
+}
+
 func init() {
 	common.Must(internet.RegisterProtocolConfigCreator(protocolName, func() interface{} {
 		return new(Config)
@@ -21,17 +21,103 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type Config_Mode int32
+
+const (
+	// HTTP/2 only.
+	Config_H2 Config_Mode = 0
+	// HTTP/2 or HTTP/1.1, as negotiated by ALPN.
+	Config_AUTO Config_Mode = 1
+	// HTTP/1.1 chunked streaming, one connection per stream.
+	Config_HTTP1 Config_Mode = 2
+	// HTTP/3 over QUIC, which requires TLS. Listeners in this mode serve
+	// HTTP/3 on UDP instead.
+	Config_H3 Config_Mode = 3
+)
+
+// Enum value maps for Config_Mode.
+var (
+	Config_Mode_name = map[int32]string{
+		0: "H2",
+		1: "AUTO",
+		2: "HTTP1",
+		3: "H3",
+	}
+	Config_Mode_value = map[string]int32{
+		"H2":    0,
+		"AUTO":  1,
+		"HTTP1": 2,
+		"H3":    3,
+	}
+)
+
+func (x Config_Mode) Enum() *Config_Mode {
+	p := new(Config_Mode)
+	*p = x
+	return p
+}
+
+func (x Config_Mode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Config_Mode) Descriptor() protoreflect.EnumDescriptor {
+	return file_transport_internet_http_config_proto_enumTypes[0].Descriptor()
+}
+
+func (Config_Mode) Type() protoreflect.EnumType {
+	return &file_transport_internet_http_config_proto_enumTypes[0]
+}
+
+func (x Config_Mode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Config_Mode.Descriptor instead.
+func (Config_Mode) EnumDescriptor() ([]byte, []int) {
+	return file_transport_internet_http_config_proto_rawDescGZIP(), []int{0, 0}
+}
+
 type Config struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Host               []string       `protobuf:"bytes,1,rep,name=host,proto3" json:"host,omitempty"`
-	Path               string         `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
-	IdleTimeout        int32          `protobuf:"varint,3,opt,name=idle_timeout,json=idleTimeout,proto3" json:"idle_timeout,omitempty"`
+	Host []string `protobuf:"bytes,1,rep,name=host,proto3" json:"host,omitempty"`
+	Path string   `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	// Seconds a connection of the dialer may be idle before it is checked with a
+	// ping, or 30 if only health_check_timeout is set.
+	IdleTimeout int32 `protobuf:"varint,3,opt,name=idle_timeout,json=idleTimeout,proto3" json:"idle_timeout,omitempty"`
+	// Seconds to wait for the answer to a ping before the connection is closed,
+	// along with its streams. 0 means 15.
 	HealthCheckTimeout int32          `protobuf:"varint,4,opt,name=health_check_timeout,json=healthCheckTimeout,proto3" json:"health_check_timeout,omitempty"`
 	Method             string         `protobuf:"bytes,5,opt,name=method,proto3" json:"method,omitempty"`
 	Header             []*http.Header `protobuf:"bytes,6,rep,name=header,proto3" json:"header,omitempty"`
+	// Mode of the dialer. Listeners accept HTTP/1.1 besides HTTP/2 in AUTO and
+	// HTTP1 modes only.
+	Mode Config_Mode `protobuf:"varint,7,opt,name=mode,proto3,enum=xray.transport.internet.http.Config_Mode" json:"mode,omitempty"`
+	// Number of parallel GET streams carrying the downlink, for CDNs that throttle single streams.
+	// 0 or 1 carries the downlink in the response of the uplink request. Only effective on HTTP/2.
+	DownlinkStreams uint32 `protobuf:"varint,8,opt,name=downlink_streams,json=downlinkStreams,proto3" json:"downlink_streams,omitempty"`
+	// Address, with an optional port, the client connects to instead of the
+	// destination, such as an edge of a CDN. TLS still names the destination and
+	// requests name host, so the three may differ for domain fronting.
+	DialAddress string `protobuf:"bytes,9,opt,name=dial_address,json=dialAddress,proto3" json:"dial_address,omitempty"`
+	// Maximum size of a request body in bytes, for CDNs that cap it. Dialers send
+	// theirs to the listener, which advertises its own or the one of the dialer if
+	// smaller, and dialers carry on the uplink in further requests when a body
+	// reaches the advertised size. 0 means no limit. Not effective with
+	// downlink_streams.
+	MaxUploadSize uint64 `protobuf:"varint,10,opt,name=max_upload_size,json=maxUploadSize,proto3" json:"max_upload_size,omitempty"`
+	// Whether the listener expects a PROXY protocol header on every connection,
+	// which then gives the address of the client.
+	AcceptProxyProtocol bool `protobuf:"varint,11,opt,name=accept_proxy_protocol,json=acceptProxyProtocol,proto3" json:"accept_proxy_protocol,omitempty"`
+	// Headers of the responses of the listener. Empty means the headers in header,
+	// which requests carry as well.
+	ResponseHeader []*http.Header `protobuf:"bytes,12,rep,name=response_header,json=responseHeader,proto3" json:"response_header,omitempty"`
+	// Status of the responses carrying streams, which dialers expect as well. 0
+	// means 200.
+	ResponseStatus uint32 `protobuf:"varint,13,opt,name=response_status,json=responseStatus,proto3" json:"response_status,omitempty"`
 }
 
 func (x *Config) Reset() {
@@ -108,6 +194,55 @@ func (x *Config) GetHeader() []*http.Header {
 	return nil
 }
 
+func (x *Config) GetMode() Config_Mode {
+	if x != nil {
+		return x.Mode
+	}
+	return Config_H2
+}
+
+func (x *Config) GetDownlinkStreams() uint32 {
+	if x != nil {
+		return x.DownlinkStreams
+	}
+	return 0
+}
+
+func (x *Config) GetDialAddress() string {
+	if x != nil {
+		return x.DialAddress
+	}
+	return ""
+}
+
+func (x *Config) GetMaxUploadSize() uint64 {
+	if x != nil {
+		return x.MaxUploadSize
+	}
+	return 0
+}
+
+func (x *Config) GetAcceptProxyProtocol() bool {
+	if x != nil {
+		return x.AcceptProxyProtocol
+	}
+	return false
+}
+
+func (x *Config) GetResponseHeader() []*http.Header {
+	if x != nil {
+		return x.ResponseHeader
+	}
+	return nil
+}
+
+func (x *Config) GetResponseStatus() uint32 {
+	if x != nil {
+		return x.ResponseStatus
+	}
+	return 0
+}
+
 var File_transport_internet_http_config_proto protoreflect.FileDescriptor
 
 var file_transport_internet_http_config_proto_rawDesc = []byte{
@@ -118,7 +253,7 @@ var file_transport_internet_http_config_proto_rawDesc = []byte{
 	0x68, 0x74, 0x74, 0x70, 0x1a, 0x2c, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74, 0x2f,
 	0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x65, 0x74, 0x2f, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73,
 	0x2f, 0x68, 0x74, 0x74, 0x70, 0x2f, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x22, 0xe3, 0x01, 0x0a, 0x06, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x12, 0x0a,
+	0x74, 0x6f, 0x22, 0xf9, 0x04, 0x0a, 0x06, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x12, 0x0a,
 	0x04, 0x68, 0x6f, 0x73, 0x74, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x68, 0x6f, 0x73,
 	0x74, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
 	0x04, 0x70, 0x61, 0x74, 0x68, 0x12, 0x21, 0x0a, 0x0c, 0x69, 0x64, 0x6c, 0x65, 0x5f, 0x74, 0x69,
@@ -132,15 +267,40 @@ var file_transport_internet_http_config_proto_rawDesc = []byte{
 	0x28, 0x0b, 0x32, 0x2c, 0x2e, 0x78, 0x72, 0x61, 0x79, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x70,
 	0x6f, 0x72, 0x74, 0x2e, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x65, 0x74, 0x2e, 0x68, 0x65, 0x61,
 	0x64, 0x65, 0x72, 0x73, 0x2e, 0x68, 0x74, 0x74, 0x70, 0x2e, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72,
-	0x52, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x42, 0x76, 0x0a, 0x20, 0x63, 0x6f, 0x6d, 0x2e,
-	0x78, 0x72, 0x61, 0x79, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74, 0x2e, 0x69,
-	0x6e, 0x74, 0x65, 0x72, 0x6e, 0x65, 0x74, 0x2e, 0x68, 0x74, 0x74, 0x70, 0x50, 0x01, 0x5a, 0x31,
-	0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x78, 0x74, 0x6c, 0x73, 0x2f,
-	0x78, 0x72, 0x61, 0x79, 0x2d, 0x63, 0x6f, 0x72, 0x65, 0x2f, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x70,
-	0x6f, 0x72, 0x74, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x65, 0x74, 0x2f, 0x68, 0x74, 0x74,
-	0x70, 0xaa, 0x02, 0x1c, 0x58, 0x72, 0x61, 0x79, 0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f,
-	0x72, 0x74, 0x2e, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x65, 0x74, 0x2e, 0x48, 0x74, 0x74, 0x70,
-	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x52, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x3d, 0x0a, 0x04, 0x6d, 0x6f, 0x64, 0x65,
+	0x18, 0x07, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x29, 0x2e, 0x78, 0x72, 0x61, 0x79, 0x2e, 0x74, 0x72,
+	0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74, 0x2e, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x65, 0x74,
+	0x2e, 0x68, 0x74, 0x74, 0x70, 0x2e, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x4d, 0x6f, 0x64,
+	0x65, 0x52, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x12, 0x29, 0x0a, 0x10, 0x64, 0x6f, 0x77, 0x6e, 0x6c,
+	0x69, 0x6e, 0x6b, 0x5f, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x0f, 0x64, 0x6f, 0x77, 0x6e, 0x6c, 0x69, 0x6e, 0x6b, 0x53, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x64, 0x69, 0x61, 0x6c, 0x5f, 0x61, 0x64, 0x64, 0x72, 0x65,
+	0x73, 0x73, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x69, 0x61, 0x6c, 0x41, 0x64,
+	0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x26, 0x0a, 0x0f, 0x6d, 0x61, 0x78, 0x5f, 0x75, 0x70, 0x6c,
+	0x6f, 0x61, 0x64, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0d,
+	0x6d, 0x61, 0x78, 0x55, 0x70, 0x6c, 0x6f, 0x61, 0x64, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x32, 0x0a,
+	0x15, 0x61, 0x63, 0x63, 0x65, 0x70, 0x74, 0x5f, 0x70, 0x72, 0x6f, 0x78, 0x79, 0x5f, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x08, 0x52, 0x13, 0x61, 0x63,
+	0x63, 0x65, 0x70, 0x74, 0x50, 0x72, 0x6f, 0x78, 0x79, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f,
+	0x6c, 0x12, 0x55, 0x0a, 0x0f, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x5f, 0x68, 0x65,
+	0x61, 0x64, 0x65, 0x72, 0x18, 0x0c, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x2c, 0x2e, 0x78, 0x72, 0x61,
+	0x79, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74, 0x2e, 0x69, 0x6e, 0x74, 0x65,
+	0x72, 0x6e, 0x65, 0x74, 0x2e, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x2e, 0x68, 0x74, 0x74,
+	0x70, 0x2e, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x52, 0x0e, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x27, 0x0a, 0x0f, 0x72, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x0d, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x0e, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x53, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x22, 0x2b, 0x0a, 0x04, 0x4d, 0x6f, 0x64, 0x65, 0x12, 0x06, 0x0a, 0x02, 0x48, 0x32, 0x10,
+	0x00, 0x12, 0x08, 0x0a, 0x04, 0x41, 0x55, 0x54, 0x4f, 0x10, 0x01, 0x12, 0x09, 0x0a, 0x05, 0x48,
+	0x54, 0x54, 0x50, 0x31, 0x10, 0x02, 0x12, 0x06, 0x0a, 0x02, 0x48, 0x33, 0x10, 0x03, 0x42, 0x76,
+	0x0a, 0x20, 0x63, 0x6f, 0x6d, 0x2e, 0x78, 0x72, 0x61, 0x79, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73,
+	0x70, 0x6f, 0x72, 0x74, 0x2e, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x65, 0x74, 0x2e, 0x68, 0x74,
+	0x74, 0x70, 0x50, 0x01, 0x5a, 0x31, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d,
+	0x2f, 0x78, 0x74, 0x6c, 0x73, 0x2f, 0x78, 0x72, 0x61, 0x79, 0x2d, 0x63, 0x6f, 0x72, 0x65, 0x2f,
+	0x74, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e,
+	0x65, 0x74, 0x2f, 0x68, 0x74, 0x74, 0x70, 0xaa, 0x02, 0x1c, 0x58, 0x72, 0x61, 0x79, 0x2e, 0x54,
+	0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x72, 0x74, 0x2e, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x65,
+	0x74, 0x2e, 0x48, 0x74, 0x74, 0x70, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -155,18 +315,22 @@ func file_transport_internet_http_config_proto_rawDescGZIP() []byte {
 	return file_transport_internet_http_config_proto_rawDescData
 }
 
+var file_transport_internet_http_config_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
 var file_transport_internet_http_config_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
 var file_transport_internet_http_config_proto_goTypes = []interface{}{
-	(*Config)(nil),      // 0: xray.transport.internet.http.Config
-	(*http.Header)(nil), // 1: xray.transport.internet.headers.http.Header
+	(Config_Mode)(0),    // 0: xray.transport.internet.http.Config.Mode
+	(*Config)(nil),      // 1: xray.transport.internet.http.Config
+	(*http.Header)(nil), // 2: xray.transport.internet.headers.http.Header
 }
 var file_transport_internet_http_config_proto_depIdxs = []int32{
-	1, // 0: xray.transport.internet.http.Config.header:type_name -> xray.transport.internet.headers.http.Header
-	1, // [1:1] is the sub-list for method output_type
-	1, // [1:1] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	2, // 0: xray.transport.internet.http.Config.header:type_name -> xray.transport.internet.headers.http.Header
+	0, // 1: xray.transport.internet.http.Config.mode:type_name -> xray.transport.internet.http.Config.Mode
+	2, // 2: xray.transport.internet.http.Config.response_header:type_name -> xray.transport.internet.headers.http.Header
+	3, // [3:3] is the sub-list for method output_type
+	3, // [3:3] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_transport_internet_http_config_proto_init() }
@@ -193,13 +357,14 @@ func file_transport_internet_http_config_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_transport_internet_http_config_proto_rawDesc,
-			NumEnums:      0,
+			NumEnums:      1,
 			NumMessages:   1,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
 		GoTypes:           file_transport_internet_http_config_proto_goTypes,
 		DependencyIndexes: file_transport_internet_http_config_proto_depIdxs,
+		EnumInfos:         file_transport_internet_http_config_proto_enumTypes,
 		MessageInfos:      file_transport_internet_http_config_proto_msgTypes,
 	}.Build()
 	File_transport_internet_http_config_proto = out.File
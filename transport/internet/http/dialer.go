@@ -1,10 +1,15 @@
 package http
 
 import (
+	"bufio"
 	"context"
 	gotls "crypto/tls"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httputil"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
@@ -29,8 +34,92 @@ type dialerConf struct {
 var (
 	globalDialerMap    map[dialerConf]*http.Client
 	globalDialerAccess sync.Mutex
+	// servers found to only speak HTTP/1.1 in AUTO mode, until when they are dialed with HTTP/1.1
+	http1Servers sync.Map
 )
 
+// how long a server found to only speak HTTP/1.1 is dialed with HTTP/1.1, before HTTP/2 is tried again
+const http1ServerTTL = 10 * time.Minute
+
+// setHTTP1Server remembers that the server only speaks HTTP/1.1, and forgets the servers that expired.
+func setHTTP1Server(conf dialerConf) {
+	now := time.Now()
+	http1Servers.Range(func(key, value interface{}) bool {
+		if now.After(value.(time.Time)) {
+			http1Servers.Delete(key)
+		}
+		return true
+	})
+	http1Servers.Store(conf, now.Add(http1ServerTTL))
+}
+
+// isHTTP1Server returns whether the server was recently found to only speak HTTP/1.1.
+func isHTTP1Server(conf dialerConf) bool {
+	expire, found := http1Servers.Load(conf)
+	if !found {
+		return false
+	}
+	if time.Now().After(expire.(time.Time)) {
+		http1Servers.Delete(conf)
+		return false
+	}
+	return true
+}
+
+var errHTTP1Negotiated = newError("server negotiated HTTP/1.1")
+
+// dialTLS dials addr and completes the TLS or REALITY handshake.
+func dialTLS(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig, addr string, tlsConfig *gotls.Config) (net.Conn, error) {
+	rawHost, rawPort, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if len(rawPort) == 0 {
+		rawPort = "443"
+	}
+	port, err := net.PortFromString(rawPort)
+	if err != nil {
+		return nil, err
+	}
+	address := net.ParseAddress(rawHost)
+
+	dctx := context.Background()
+	dctx = session.ContextWithID(dctx, session.IDFromContext(ctx))
+	dctx = session.ContextWithOutbound(dctx, session.OutboundFromContext(ctx))
+
+	target, err := internet.OverrideDestination(net.TCPDestination(address, port), streamSettings.ProtocolSettings.(*Config).DialAddress)
+	if err != nil {
+		return nil, err
+	}
+	pconn, err := internet.DialSystem(dctx, target, streamSettings.SocketSettings)
+	if err != nil {
+		newError("failed to dial to " + addr).Base(err).AtError().WriteToLog()
+		return nil, err
+	}
+
+	if realityConfigs := reality.ConfigFromStreamSettings(streamSettings); realityConfigs != nil {
+		return reality.UClient(pconn, realityConfigs, ctx, dest)
+	}
+
+	var cn tls.Interface
+	if fingerprint := tls.GetFingerprint(tls.ConfigFromStreamSettings(streamSettings).Fingerprint); fingerprint != nil {
+		cn = tls.UClient(pconn, tlsConfig, fingerprint).(*tls.UConn)
+	} else {
+		cn = tls.Client(pconn, tlsConfig).(*tls.Conn)
+	}
+	if err := cn.Handshake(); err != nil {
+		newError("failed to dial to " + addr).Base(err).AtError().WriteToLog()
+		return nil, err
+	}
+	if !tlsConfig.InsecureSkipVerify {
+		if err := cn.VerifyHostname(tlsConfig.ServerName); err != nil {
+			newError("failed to dial to " + addr).Base(err).AtError().WriteToLog()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
 func getHTTPClient(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (*http.Client, error) {
 	globalDialerAccess.Lock()
 	defer globalDialerAccess.Unlock()
@@ -45,58 +134,33 @@ func getHTTPClient(ctx context.Context, dest net.Destination, streamSettings *in
 	if tlsConfigs == nil && realityConfigs == nil {
 		return nil, newError("TLS or REALITY must be enabled for http transport.").AtWarning()
 	}
-	sockopt := streamSettings.SocketSettings
 
 	if client, found := globalDialerMap[dialerConf{dest, streamSettings}]; found {
 		return client, nil
 	}
 
+	if httpSettings.Mode == Config_H3 {
+		client, err := newHTTP3Client(ctx, dest, streamSettings)
+		if err != nil {
+			return nil, err
+		}
+		globalDialerMap[dialerConf{dest, streamSettings}] = client
+		return client, nil
+	}
+
 	transport := &http2.Transport{
 		DialTLS: func(network string, addr string, tlsConfig *gotls.Config) (net.Conn, error) {
-			rawHost, rawPort, err := net.SplitHostPort(addr)
-			if err != nil {
-				return nil, err
-			}
-			if len(rawPort) == 0 {
-				rawPort = "443"
-			}
-			port, err := net.PortFromString(rawPort)
-			if err != nil {
-				return nil, err
-			}
-			address := net.ParseAddress(rawHost)
-
-			dctx := context.Background()
-			dctx = session.ContextWithID(dctx, session.IDFromContext(ctx))
-			dctx = session.ContextWithOutbound(dctx, session.OutboundFromContext(ctx))
-
-			pconn, err := internet.DialSystem(dctx, net.TCPDestination(address, port), sockopt)
-			if err != nil {
-				newError("failed to dial to " + addr).Base(err).AtError().WriteToLog()
-				return nil, err
-			}
-
-			if realityConfigs != nil {
-				return reality.UClient(pconn, realityConfigs, ctx, dest)
-			}
-
-			var cn tls.Interface
-			if fingerprint := tls.GetFingerprint(tlsConfigs.Fingerprint); fingerprint != nil {
-				cn = tls.UClient(pconn, tlsConfig, fingerprint).(*tls.UConn)
-			} else {
-				cn = tls.Client(pconn, tlsConfig).(*tls.Conn)
-			}
-			if err := cn.Handshake(); err != nil {
-				newError("failed to dial to " + addr).Base(err).AtError().WriteToLog()
-				return nil, err
-			}
-			if !tlsConfig.InsecureSkipVerify {
-				if err := cn.VerifyHostname(tlsConfig.ServerName); err != nil {
-					newError("failed to dial to " + addr).Base(err).AtError().WriteToLog()
-					return nil, err
-				}
+			conn, err := dialTLS(ctx, dest, streamSettings, addr, tlsConfig)
+			if err != nil || realityConfigs != nil {
+				return conn, err
 			}
+			cn := conn.(tls.Interface)
 			negotiatedProtocol, negotiatedProtocolIsMutual := cn.NegotiatedProtocol()
+			if negotiatedProtocol == "http/1.1" && httpSettings.Mode == Config_AUTO {
+				cn.Close()
+				setHTTP1Server(dialerConf{dest, streamSettings})
+				return nil, errHTTP1Negotiated
+			}
 			if negotiatedProtocol != http2.NextProtoTLS {
 				return nil, newError("http2: unexpected ALPN protocol " + negotiatedProtocol + "; want q" + http2.NextProtoTLS).AtError()
 			}
@@ -105,16 +169,21 @@ func getHTTPClient(ctx context.Context, dest net.Destination, streamSettings *in
 			}
 			return cn, nil
 		},
+		CountError: func(errType string) {
+			if strings.HasPrefix(errType, "recv_goaway_") {
+				streamSettings.Events.Record(internet.EventH2GoAway, 1)
+			}
+		},
 	}
 
 	if tlsConfigs != nil {
 		transport.TLSClientConfig = tlsConfigs.GetTLSConfig(tls.WithDestination(dest))
+		if httpSettings.Mode == Config_AUTO {
+			transport.TLSClientConfig.NextProtos = withHTTP1(transport.TLSClientConfig.NextProtos)
+		}
 	}
 
-	if httpSettings.IdleTimeout > 0 || httpSettings.HealthCheckTimeout > 0 {
-		transport.ReadIdleTimeout = time.Second * time.Duration(httpSettings.IdleTimeout)
-		transport.PingTimeout = time.Second * time.Duration(httpSettings.HealthCheckTimeout)
-	}
+	transport.ReadIdleTimeout, transport.PingTimeout = httpSettings.healthCheck()
 
 	client := &http.Client{
 		Transport: transport,
@@ -124,33 +193,123 @@ func getHTTPClient(ctx context.Context, dest net.Destination, streamSettings *in
 	return client, nil
 }
 
-// Dial dials a new TCP connection to the given destination.
-func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (stat.Connection, error) {
+func withHTTP1(protos []string) []string {
+	if len(protos) == 0 {
+		protos = []string{http2.NextProtoTLS}
+	}
+	for _, p := range protos {
+		if p == "http/1.1" {
+			return protos
+		}
+	}
+	return append(protos, "http/1.1")
+}
+
+func (c *Config) getHeaders() http.Header {
+	headers := make(http.Header)
+	for _, httpHeader := range c.Header {
+		for _, httpHeaderValue := range httpHeader.Value {
+			headers.Set(httpHeader.Name, httpHeaderValue)
+		}
+	}
+	// Disable any compression method from server.
+	headers.Set("Accept-Encoding", "identity")
+	return headers
+}
+
+func (c *Config) getMethod() string {
+	if c.Method != "" {
+		return c.Method
+	}
+	return "PUT"
+}
+
+// pipeBody is a request body reading from a pipe. Transports close the body of a stream that fails, such as
+// on a connection lost to a health check, which interrupts the pipe to unblock their reading.
+type pipeBody struct {
+	*buf.BufferedReader
+	reader *pipe.Reader
+}
+
+func newPipeBody(reader *pipe.Reader) pipeBody {
+	return pipeBody{
+		BufferedReader: &buf.BufferedReader{Reader: reader},
+		reader:         reader,
+	}
+}
+
+// Close implements io.Closer.
+func (b pipeBody) Close() error {
+	b.reader.Interrupt()
+	return nil
+}
+
+// dialHTTP1 streams over a single HTTP/1.1 request with chunked bodies in both directions.
+func dialHTTP1(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (stat.Connection, error) {
 	httpSettings := streamSettings.ProtocolSettings.(*Config)
-	client, err := getHTTPClient(ctx, dest, streamSettings)
+	tlsConfigs := tls.ConfigFromStreamSettings(streamSettings)
+	if tlsConfigs == nil {
+		return nil, newError("TLS must be enabled for HTTP/1.1 mode of http transport.").AtWarning()
+	}
+	tlsConfig := tlsConfigs.GetTLSConfig(tls.WithDestination(dest))
+	tlsConfig.NextProtos = []string{"http/1.1"}
+
+	conn, err := dialTLS(ctx, dest, streamSettings, dest.NetAddr(), tlsConfig)
 	if err != nil {
-		return nil, err
+		return nil, newError("failed to dial to ", dest).Base(err).AtWarning()
 	}
 
-	opts := pipe.OptionsFromContext(ctx)
-	preader, pwriter := pipe.New(opts...)
-	breader := &buf.BufferedReader{Reader: preader}
+	// Only the header is written here. The body follows as the connection is written to.
+	method := httpSettings.getMethod()
+	bwriter := bufio.NewWriter(conn)
+	fmt.Fprintf(bwriter, "%s %s HTTP/1.1\r\nHost: %s\r\n", method, (&url.URL{Path: httpSettings.getNormalizedPath()}).RequestURI(), httpSettings.getRandomHost())
+	httpSettings.getHeaders().Write(bwriter)
+	bwriter.WriteString("Transfer-Encoding: chunked\r\n\r\n")
+	if err := bwriter.Flush(); err != nil {
+		conn.Close()
+		return nil, newError("failed to write request").Base(err)
+	}
 
-	httpMethod := "PUT"
-	if httpSettings.Method != "" {
-		httpMethod = httpSettings.Method
+	response, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: method})
+	if err != nil {
+		conn.Close()
+		return nil, newError("failed to read response from ", dest).Base(err).AtWarning()
+	}
+	if response.StatusCode != httpSettings.getResponseStatus() {
+		conn.Close()
+		return nil, newError("unexpected status", response.StatusCode).AtWarning()
 	}
 
-	httpHeaders := make(http.Header)
+	return cnc.NewConnection(
+		cnc.ConnectionOutput(response.Body),
+		cnc.ConnectionInput(httputil.NewChunkedWriter(conn)),
+		cnc.ConnectionOnClose(conn),
+	), nil
+}
 
-	for _, httpHeader := range httpSettings.Header {
-		for _, httpHeaderValue := range httpHeader.Value {
-			httpHeaders.Set(httpHeader.Name, httpHeaderValue)
+// Dial dials a new TCP connection to the given destination.
+func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (stat.Connection, error) {
+	httpSettings := streamSettings.ProtocolSettings.(*Config)
+	switch httpSettings.Mode {
+	case Config_HTTP1:
+		return dialHTTP1(ctx, dest, streamSettings)
+	case Config_AUTO:
+		if isHTTP1Server(dialerConf{dest, streamSettings}) {
+			return dialHTTP1(ctx, dest, streamSettings)
 		}
 	}
 
+	client, err := getHTTPClient(ctx, dest, streamSettings)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := pipe.OptionsFromContext(ctx)
+	preader, pwriter := pipe.New(opts...)
+	breader := newPipeBody(preader)
+
 	request := &http.Request{
-		Method: httpMethod,
+		Method: httpSettings.getMethod(),
 		Host:   httpSettings.getRandomHost(),
 		Body:   breader,
 		URL: &url.URL{
@@ -161,25 +320,61 @@ func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.Me
 		Proto:      "HTTP/2",
 		ProtoMajor: 2,
 		ProtoMinor: 0,
-		Header:     httpHeaders,
+		Header:     httpSettings.getHeaders(),
 	}
-	// Disable any compression method from server.
-	request.Header.Set("Accept-Encoding", "identity")
 
-	response, err := client.Do(request)
+	bwriter := buf.NewBufferedWriter(pwriter)
+	common.Must(bwriter.SetBuffered(false))
+
+	var conn stat.Connection
+	if httpSettings.DownlinkStreams > 1 {
+		conn, err = dialSplitDownlink(client, request, bwriter, int(httpSettings.DownlinkStreams), httpSettings.getResponseStatus())
+	} else {
+		upload := newUploadWriter(client, request, bwriter, opts, httpSettings.MaxUploadSize)
+		conn, err = dialStream(client, request, upload, httpSettings.getResponseStatus(), func() {
+			newError("downlink to ", dest, " was truncated, dropping its connections").AtWarning().WriteToLog(session.ExportIDToError(ctx))
+			dropHTTPClient(dest, streamSettings, client)
+		})
+	}
 	if err != nil {
+		breader.Close()
+		if errors.Is(err, errHTTP1Negotiated) {
+			newError("falling back to HTTP/1.1 for ", dest).AtInfo().WriteToLog(session.ExportIDToError(ctx))
+			return dialHTTP1(ctx, dest, streamSettings)
+		}
 		return nil, newError("failed to dial to ", dest).Base(err).AtWarning()
 	}
-	if response.StatusCode != 200 {
-		return nil, newError("unexpected status", response.StatusCode).AtWarning()
+	return conn, nil
+}
+
+// dropHTTPClient forgets the client, so the next dial does not reuse connections that truncated a stream.
+func dropHTTPClient(dest net.Destination, streamSettings *internet.MemoryStreamConfig, client *http.Client) {
+	globalDialerAccess.Lock()
+	if globalDialerMap[dialerConf{dest, streamSettings}] == client {
+		delete(globalDialerMap, dialerConf{dest, streamSettings})
 	}
+	globalDialerAccess.Unlock()
+	client.CloseIdleConnections()
+}
+
+// dialStream carries both directions over the given request, whose response has the given status, with the
+// uplink split at the maximum upload size the server advertised. onTruncated is called if the response
+// turns out to be cut short.
+func dialStream(client *http.Client, request *http.Request, upload *uploadWriter, status int, onTruncated func()) (stat.Connection, error) {
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != status {
+		response.Body.Close()
+		return nil, newError("unexpected status", response.StatusCode)
+	}
+	upload.setLimit(response)
 
-	bwriter := buf.NewBufferedWriter(pwriter)
-	common.Must(bwriter.SetBuffered(false))
 	return cnc.NewConnection(
-		cnc.ConnectionOutput(response.Body),
-		cnc.ConnectionInput(bwriter),
-		cnc.ConnectionOnClose(common.ChainedClosable{breader, bwriter, response.Body}),
+		cnc.ConnectionOutput(newTrailerReader(response, onTruncated)),
+		cnc.ConnectionInput(upload),
+		cnc.ConnectionOnClose(common.ChainedClosable{upload, response.Body}),
 	), nil
 }
 
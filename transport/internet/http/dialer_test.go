@@ -0,0 +1,34 @@
+package http
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+func TestHTTP1Servers(t *testing.T) {
+	expired := dialerConf{net.TCPDestination(net.LocalHostIP, 1), &internet.MemoryStreamConfig{}}
+	recent := dialerConf{net.TCPDestination(net.LocalHostIP, 2), &internet.MemoryStreamConfig{}}
+
+	setHTTP1Server(expired)
+	if !isHTTP1Server(expired) {
+		t.Error("expected an HTTP/1.1 server")
+	}
+	http1Servers.Store(expired, time.Now().Add(-time.Second))
+	if isHTTP1Server(expired) {
+		t.Error("expected HTTP/2 to be tried again")
+	}
+
+	// the expired servers are forgotten
+	http1Servers.Store(expired, time.Now().Add(-time.Second))
+	setHTTP1Server(recent)
+	if _, found := http1Servers.Load(expired); found {
+		t.Error("expected the expired server to be evicted")
+	}
+	if !isHTTP1Server(recent) {
+		t.Error("expected an HTTP/1.1 server")
+	}
+	http1Servers.Delete(recent)
+}
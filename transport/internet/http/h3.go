@@ -0,0 +1,126 @@
+package http
+
+import (
+	"context"
+	gotls "crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/tls"
+)
+
+// quicConfig returns the QUIC settings of HTTP/3, which keep connections alive as HTTP/2 pings would.
+func (c *Config) quicConfig(sockopt *internet.SocketConfig) *quic.Config {
+	config := &quic.Config{
+		HandshakeIdleTimeout: time.Second * 8,
+		MaxIdleTimeout:       time.Second * 300,
+		// packets stay at the conservative initial size, instead of probing beyond the limit
+		DisablePathMTUDiscovery: internet.UDPMaxPayload(sockopt) > 0,
+	}
+	if interval, timeout := c.healthCheck(); interval > 0 {
+		config.KeepAlivePeriod = interval
+		config.MaxIdleTimeout = interval + timeout
+	}
+	return config
+}
+
+// newHTTP3Client returns a client sending requests over HTTP/3.
+func newHTTP3Client(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (*http.Client, error) {
+	httpSettings := streamSettings.ProtocolSettings.(*Config)
+	tlsConfigs := tls.ConfigFromStreamSettings(streamSettings)
+	if tlsConfigs == nil {
+		return nil, newError("TLS must be enabled for HTTP/3 mode of http transport.").AtWarning()
+	}
+	return &http.Client{
+		Transport: &http3.RoundTripper{
+			TLSClientConfig: tlsConfigs.GetTLSConfig(tls.WithDestination(dest)),
+			QuicConfig:      httpSettings.quicConfig(streamSettings.SocketSettings),
+			Dial: func(dialCtx context.Context, addr string, tlsConfig *gotls.Config, quicConfig *quic.Config) (quic.EarlyConnection, error) {
+				return dialQUIC(ctx, dialCtx, streamSettings, addr, tlsConfig, quicConfig)
+			},
+		},
+	}, nil
+}
+
+// dialQUIC dials addr for HTTP/3, from a socket of its own.
+func dialQUIC(ctx context.Context, dialCtx context.Context, streamSettings *internet.MemoryStreamConfig, addr string, tlsConfig *gotls.Config, quicConfig *quic.Config) (quic.EarlyConnection, error) {
+	rawHost, rawPort, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := net.PortFromString(rawPort)
+	if err != nil {
+		return nil, err
+	}
+
+	dctx := context.Background()
+	dctx = session.ContextWithID(dctx, session.IDFromContext(ctx))
+	dctx = session.ContextWithOutbound(dctx, session.OutboundFromContext(ctx))
+
+	target, err := internet.OverrideDestination(net.UDPDestination(net.ParseAddress(rawHost), port), streamSettings.ProtocolSettings.(*Config).DialAddress)
+	if err != nil {
+		return nil, err
+	}
+	rawConn, err := internet.DialSystem(dctx, target, streamSettings.SocketSettings)
+	if err != nil {
+		newError("failed to dial to " + addr).Base(err).AtError().WriteToLog()
+		return nil, err
+	}
+	packetConn, ok := rawConn.(*internet.PacketConnWrapper)
+	if !ok {
+		rawConn.Close()
+		return nil, newError("HTTP/3 does not support binding the source address")
+	}
+
+	conn, err := quic.DialEarlyContext(dialCtx, packetConn.Conn, packetConn.Dest, rawHost, tlsConfig, quicConfig)
+	if err != nil {
+		packetConn.Close()
+		newError("failed to dial to " + addr).Base(err).AtError().WriteToLog()
+		return nil, err
+	}
+	// quic-go leaves the socket open when the connection ends
+	go func() {
+		<-conn.Context().Done()
+		packetConn.Close()
+	}()
+	return conn, nil
+}
+
+// listenHTTP3 serves the listener over HTTP/3 on UDP.
+func listenHTTP3(ctx context.Context, address net.Address, port net.Port, streamSettings *internet.MemoryStreamConfig, listener *Listener) (*Listener, error) {
+	config := tls.ConfigFromStreamSettings(streamSettings)
+	if config == nil {
+		return nil, newError("TLS must be enabled for HTTP/3 mode of http transport.").AtWarning()
+	}
+	if port == net.Port(0) {
+		return nil, newError("HTTP/3 mode of http transport cannot listen on unix domain sockets.").AtWarning()
+	}
+
+	conn, err := internet.ListenSystemPacket(ctx, &net.UDPAddr{
+		IP:   address.IP(),
+		Port: int(port),
+	}, streamSettings.SocketSettings)
+	if err != nil {
+		return nil, newError("failed to listen on ", address, ":", port).Base(err)
+	}
+
+	server := &http3.Server{
+		TLSConfig:  config.GetTLSConfig(),
+		QuicConfig: listener.config.quicConfig(streamSettings.SocketSettings),
+		Handler:    listener,
+	}
+	listener.local = conn.LocalAddr()
+	listener.server = common.ChainedClosable{server, conn}
+	go func() {
+		if err := server.Serve(conn); err != nil {
+			newError("stopping serving HTTP/3").Base(err).WriteToLog(session.ExportIDToError(ctx))
+		}
+	}()
+	return listener, nil
+}
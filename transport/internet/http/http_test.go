@@ -1,8 +1,13 @@
 package http_test
 
 import (
+	"bufio"
 	"context"
 	"crypto/rand"
+	gotls "crypto/tls"
+	"io"
+	gonet "net"
+	"net/http"
 	"testing"
 	"time"
 
@@ -12,10 +17,13 @@ import (
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol/tls/cert"
 	"github.com/xtls/xray-core/testing/servers/tcp"
+	"github.com/xtls/xray-core/testing/servers/udp"
 	"github.com/xtls/xray-core/transport/internet"
+	httpheader "github.com/xtls/xray-core/transport/internet/headers/http"
 	. "github.com/xtls/xray-core/transport/internet/http"
 	"github.com/xtls/xray-core/transport/internet/stat"
 	"github.com/xtls/xray-core/transport/internet/tls"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestHTTPConnection(t *testing.T) {
@@ -92,3 +100,601 @@ func TestHTTPConnection(t *testing.T) {
 		t.Error(r)
 	}
 }
+
+func TestHTTP1Connection(t *testing.T) {
+	port := tcp.PickPort()
+
+	listener, err := Listen(context.Background(), net.LocalHostIP, port, &internet.MemoryStreamConfig{
+		ProtocolName:     "http",
+		ProtocolSettings: &Config{Mode: Config_HTTP1},
+		SecurityType:     "tls",
+		SecuritySettings: &tls.Config{
+			Certificate: []*tls.Certificate{tls.ParseCertificate(cert.MustGenerate(nil, cert.CommonName("www.example.com")))},
+		},
+	}, func(conn stat.Connection) {
+		go func() {
+			defer conn.Close()
+
+			b := buf.New()
+			defer b.Release()
+
+			for {
+				b.Clear()
+				if _, err := b.ReadFrom(conn); err != nil {
+					return
+				}
+				_, err := conn.Write(b.Bytes())
+				common.Must(err)
+			}
+		}()
+	})
+	common.Must(err)
+
+	defer listener.Close()
+
+	time.Sleep(time.Second)
+
+	dctx := context.Background()
+	conn, err := Dial(dctx, net.TCPDestination(net.LocalHostIP, port), &internet.MemoryStreamConfig{
+		ProtocolName:     "http",
+		ProtocolSettings: &Config{Mode: Config_HTTP1},
+		SecurityType:     "tls",
+		SecuritySettings: &tls.Config{
+			ServerName:    "www.example.com",
+			AllowInsecure: true,
+		},
+	})
+	common.Must(err)
+	defer conn.Close()
+
+	const N = 1024
+	b1 := make([]byte, N)
+	common.Must2(rand.Read(b1))
+	b2 := buf.New()
+
+	for i := 0; i < 2; i++ {
+		nBytes, err := conn.Write(b1)
+		common.Must(err)
+		if nBytes != N {
+			t.Error("write: ", nBytes)
+		}
+
+		b2.Clear()
+		common.Must2(b2.ReadFullFrom(conn, N))
+		if r := cmp.Diff(b2.Bytes(), b1); r != "" {
+			t.Error(r)
+		}
+	}
+}
+
+func TestHTTP3Connection(t *testing.T) {
+	port := udp.PickPort()
+
+	listener, err := Listen(context.Background(), net.LocalHostIP, port, &internet.MemoryStreamConfig{
+		ProtocolName:     "http",
+		ProtocolSettings: &Config{Mode: Config_H3},
+		SecurityType:     "tls",
+		SecuritySettings: &tls.Config{
+			Certificate: []*tls.Certificate{tls.ParseCertificate(cert.MustGenerate(nil, cert.CommonName("www.example.com")))},
+		},
+	}, func(conn stat.Connection) {
+		go func() {
+			defer conn.Close()
+
+			b := buf.New()
+			defer b.Release()
+
+			for {
+				b.Clear()
+				if _, err := b.ReadFrom(conn); err != nil {
+					return
+				}
+				_, err := conn.Write(b.Bytes())
+				common.Must(err)
+			}
+		}()
+	})
+	common.Must(err)
+
+	defer listener.Close()
+
+	dctx := context.Background()
+	conn, err := Dial(dctx, net.TCPDestination(net.LocalHostIP, port), &internet.MemoryStreamConfig{
+		ProtocolName:     "http",
+		ProtocolSettings: &Config{Mode: Config_H3},
+		SecurityType:     "tls",
+		SecuritySettings: &tls.Config{
+			ServerName:    "www.example.com",
+			AllowInsecure: true,
+		},
+	})
+	common.Must(err)
+	defer conn.Close()
+
+	const N = 1024
+	b1 := make([]byte, N)
+	common.Must2(rand.Read(b1))
+	b2 := buf.New()
+
+	for i := 0; i < 2; i++ {
+		nBytes, err := conn.Write(b1)
+		common.Must(err)
+		if nBytes != N {
+			t.Error("write: ", nBytes)
+		}
+
+		b2.Clear()
+		common.Must2(b2.ReadFullFrom(conn, N))
+		if r := cmp.Diff(b2.Bytes(), b1); r != "" {
+			t.Error(r)
+		}
+	}
+}
+
+func TestHTTPSplitDownlink(t *testing.T) {
+	port := tcp.PickPort()
+
+	listener, err := Listen(context.Background(), net.LocalHostIP, port, &internet.MemoryStreamConfig{
+		ProtocolName:     "http",
+		ProtocolSettings: &Config{},
+		SecurityType:     "tls",
+		SecuritySettings: &tls.Config{
+			Certificate: []*tls.Certificate{tls.ParseCertificate(cert.MustGenerate(nil, cert.CommonName("www.example.com")))},
+		},
+	}, func(conn stat.Connection) {
+		go func() {
+			defer conn.Close()
+
+			b := buf.New()
+			defer b.Release()
+
+			for {
+				b.Clear()
+				if _, err := b.ReadFrom(conn); err != nil {
+					return
+				}
+				_, err := conn.Write(b.Bytes())
+				common.Must(err)
+			}
+		}()
+	})
+	common.Must(err)
+
+	defer listener.Close()
+
+	time.Sleep(time.Second)
+
+	dctx := context.Background()
+	conn, err := Dial(dctx, net.TCPDestination(net.LocalHostIP, port), &internet.MemoryStreamConfig{
+		ProtocolName:     "http",
+		ProtocolSettings: &Config{DownlinkStreams: 4},
+		SecurityType:     "tls",
+		SecuritySettings: &tls.Config{
+			ServerName:    "www.example.com",
+			AllowInsecure: true,
+		},
+	})
+	common.Must(err)
+	defer conn.Close()
+
+	const N = 1024 * 1024
+	b1 := make([]byte, N)
+	common.Must2(rand.Read(b1))
+	go func() {
+		common.Must2(conn.Write(b1))
+	}()
+
+	b2 := make([]byte, N)
+	common.Must2(io.ReadFull(conn, b2))
+	if r := cmp.Diff(b2, b1); r != "" {
+		t.Error(r)
+	}
+}
+
+func TestHTTPSplitDownlinkDuplicateStream(t *testing.T) {
+	port := tcp.PickPort()
+
+	listener, err := Listen(context.Background(), net.LocalHostIP, port, &internet.MemoryStreamConfig{
+		ProtocolName:     "http",
+		ProtocolSettings: &Config{Mode: Config_AUTO},
+	}, func(conn stat.Connection) {
+		conn.Close()
+	})
+	common.Must(err)
+	defer listener.Close()
+
+	time.Sleep(100 * time.Millisecond)
+
+	get := func() (*http.Response, error) {
+		request, err := http.NewRequest("GET", "http://"+net.TCPDestination(net.LocalHostIP, port).NetAddr()+"/?s=0123456789abcdef&n=2&i=0", nil)
+		common.Must(err)
+		request.Host = "www.example.com"
+		return http.DefaultClient.Do(request)
+	}
+	first := make(chan struct{})
+	go func() {
+		defer close(first)
+		if response, err := get(); err == nil {
+			response.Body.Close()
+		}
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	response, err := get()
+	common.Must(err)
+	response.Body.Close()
+	if response.StatusCode != http.StatusBadRequest {
+		t.Error("expected a second stream with the same index to be rejected, but got status ", response.StatusCode)
+	}
+	select {
+	case <-first:
+		t.Error("expected the first stream to keep waiting for the session")
+	default:
+	}
+}
+
+func TestHTTPTrailers(t *testing.T) {
+	port := tcp.PickPort()
+
+	listener, err := Listen(context.Background(), net.LocalHostIP, port, &internet.MemoryStreamConfig{
+		ProtocolName:     "http",
+		ProtocolSettings: &Config{},
+		SecurityType:     "tls",
+		SecuritySettings: &tls.Config{
+			Certificate: []*tls.Certificate{tls.ParseCertificate(cert.MustGenerate(nil, cert.CommonName("www.example.com")))},
+		},
+	}, func(conn stat.Connection) {
+		go func() {
+			defer conn.Close()
+			common.Must2(conn.Write([]byte("Response")))
+		}()
+	})
+	common.Must(err)
+	defer listener.Close()
+
+	time.Sleep(time.Second)
+
+	conn, err := Dial(context.Background(), net.TCPDestination(net.LocalHostIP, port), &internet.MemoryStreamConfig{
+		ProtocolName:     "http",
+		ProtocolSettings: &Config{},
+		SecurityType:     "tls",
+		SecuritySettings: &tls.Config{
+			ServerName:    "www.example.com",
+			AllowInsecure: true,
+		},
+	})
+	common.Must(err)
+	defer conn.Close()
+
+	b, err := io.ReadAll(conn)
+	if err != nil {
+		t.Error("expected graceful end, but got ", err)
+	}
+	if string(b) != "Response" {
+		t.Error("response: ", string(b))
+	}
+}
+
+func TestHTTPTruncated(t *testing.T) {
+	port := tcp.PickPort()
+
+	securitySettings := &tls.Config{
+		Certificate: []*tls.Certificate{tls.ParseCertificate(cert.MustGenerate(nil, cert.CommonName("www.example.com")))},
+	}
+	// a server that announces trailers but never sends them, as when a stream is cut short on the way
+	server := &http.Server{
+		TLSConfig: securitySettings.GetTLSConfig(tls.WithNextProto("h2")),
+		Handler: http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.Header().Set("Trailer", "X-Stream-Bytes, X-Stream-Close")
+			writer.WriteHeader(200)
+			writer.Write([]byte("Response"))
+		}),
+	}
+	l, err := gonet.Listen("tcp", net.TCPDestination(net.LocalHostIP, port).NetAddr())
+	common.Must(err)
+	go server.ServeTLS(l, "", "")
+	defer server.Close()
+
+	conn, err := Dial(context.Background(), net.TCPDestination(net.LocalHostIP, port), &internet.MemoryStreamConfig{
+		ProtocolName:     "http",
+		ProtocolSettings: &Config{},
+		SecurityType:     "tls",
+		SecuritySettings: &tls.Config{
+			ServerName:    "www.example.com",
+			AllowInsecure: true,
+		},
+	})
+	common.Must(err)
+	defer conn.Close()
+
+	b, err := io.ReadAll(conn)
+	if err == nil {
+		t.Error("expected truncation error")
+	}
+	if string(b) != "Response" {
+		t.Error("response: ", string(b))
+	}
+}
+
+func TestHTTPMaxUploadSize(t *testing.T) {
+	// the limit of the dialer is either larger than the one of the listener, or lowers it
+	for _, limit := range []uint64{0, 5000, 300} {
+		testHTTPMaxUploadSize(t, limit)
+	}
+}
+
+func testHTTPMaxUploadSize(t *testing.T, limit uint64) {
+	port := tcp.PickPort()
+
+	listener, err := Listen(context.Background(), net.LocalHostIP, port, &internet.MemoryStreamConfig{
+		ProtocolName:     "http",
+		ProtocolSettings: &Config{MaxUploadSize: 1000},
+		SecurityType:     "tls",
+		SecuritySettings: &tls.Config{
+			Certificate: []*tls.Certificate{tls.ParseCertificate(cert.MustGenerate(nil, cert.CommonName("www.example.com")))},
+		},
+	}, func(conn stat.Connection) {
+		go func() {
+			defer conn.Close()
+			io.Copy(conn, conn)
+		}()
+	})
+	common.Must(err)
+	defer listener.Close()
+
+	time.Sleep(time.Second)
+
+	conn, err := Dial(context.Background(), net.TCPDestination(net.LocalHostIP, port), &internet.MemoryStreamConfig{
+		ProtocolName:     "http",
+		ProtocolSettings: &Config{MaxUploadSize: limit},
+		SecurityType:     "tls",
+		SecuritySettings: &tls.Config{
+			ServerName:    "www.example.com",
+			AllowInsecure: true,
+		},
+	})
+	common.Must(err)
+	defer conn.Close()
+
+	// spans several requests, the last of them full
+	const N = 4000
+	b1 := make([]byte, N)
+	common.Must2(rand.Read(b1))
+	common.Must2(conn.Write(b1))
+
+	b2 := make([]byte, N)
+	common.Must2(io.ReadFull(conn, b2))
+	if r := cmp.Diff(b2, b1); r != "" {
+		t.Error(r)
+	}
+
+	common.Must2(conn.Write(b1[:10]))
+	common.Must2(io.ReadFull(conn, b2[:10]))
+	if r := cmp.Diff(b2[:10], b1[:10]); r != "" {
+		t.Error(r)
+	}
+}
+
+func TestHTTPProxyProtocol(t *testing.T) {
+	port := tcp.PickPort()
+
+	remote := make(chan net.Addr, 1)
+	streamSettings := &internet.MemoryStreamConfig{
+		ProtocolName:     "http",
+		ProtocolSettings: &Config{AcceptProxyProtocol: true, Mode: Config_AUTO},
+	}
+	listener, err := Listen(context.Background(), net.LocalHostIP, port, streamSettings, func(conn stat.Connection) {
+		remote <- conn.RemoteAddr()
+		conn.Close()
+	})
+	common.Must(err)
+	defer listener.Close()
+	if streamSettings.SocketSettings != nil {
+		t.Error("expected the stream settings to be left unchanged")
+	}
+
+	time.Sleep(time.Second)
+
+	conn, err := gonet.Dial("tcp", net.TCPDestination(net.LocalHostIP, port).NetAddr())
+	common.Must(err)
+	defer conn.Close()
+	common.Must2(conn.Write([]byte("PROXY TCP4 192.0.2.1 127.0.0.1 4321 " + port.String() + "\r\n" +
+		"POST / HTTP/1.1\r\nHost: www.example.com\r\nTransfer-Encoding: chunked\r\n\r\n")))
+
+	select {
+	case addr := <-remote:
+		if addr.String() != "192.0.2.1:4321" {
+			t.Error("expected the address in the PROXY header, but got ", addr)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout")
+	}
+}
+
+func TestHTTPResponseHeaders(t *testing.T) {
+	port := tcp.PickPort()
+
+	config := &Config{
+		Method: "POST",
+		Header: []*httpheader.Header{{Name: "X-Request", Value: []string{"request"}}},
+		ResponseHeader: []*httpheader.Header{
+			{Name: "Server", Value: []string{"nginx"}},
+			{Name: "Content-Type", Value: []string{"application/grpc"}},
+		},
+		ResponseStatus: 201,
+		Mode:           Config_AUTO,
+	}
+	listener, err := Listen(context.Background(), net.LocalHostIP, port, &internet.MemoryStreamConfig{
+		ProtocolName:     "http",
+		ProtocolSettings: config,
+		SecurityType:     "tls",
+		SecuritySettings: &tls.Config{
+			Certificate: []*tls.Certificate{tls.ParseCertificate(cert.MustGenerate(nil, cert.CommonName("www.example.com")))},
+		},
+	}, func(conn stat.Connection) {
+		go func() {
+			defer conn.Close()
+			io.Copy(conn, conn)
+		}()
+	})
+	common.Must(err)
+	defer listener.Close()
+
+	time.Sleep(time.Second)
+
+	for _, mode := range []Config_Mode{Config_H2, Config_HTTP1} {
+		dialConfig := proto.Clone(config).(*Config)
+		dialConfig.Mode = mode
+		conn, err := Dial(context.Background(), net.TCPDestination(net.LocalHostIP, port), &internet.MemoryStreamConfig{
+			ProtocolName:     "http",
+			ProtocolSettings: dialConfig,
+			SecurityType:     "tls",
+			SecuritySettings: &tls.Config{
+				ServerName:    "www.example.com",
+				AllowInsecure: true,
+			},
+		})
+		common.Must(err)
+		common.Must2(conn.Write([]byte("Request")))
+		b := make([]byte, 7)
+		common.Must2(io.ReadFull(conn, b))
+		if string(b) != "Request" {
+			t.Error("mode ", mode, ", response: ", string(b))
+		}
+		conn.Close()
+	}
+
+	conn, err := gotls.Dial("tcp", net.TCPDestination(net.LocalHostIP, port).NetAddr(), &gotls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{"http/1.1"},
+	})
+	common.Must(err)
+	defer conn.Close()
+	common.Must2(conn.Write([]byte("POST / HTTP/1.1\r\nHost: www.example.com\r\nTransfer-Encoding: chunked\r\n\r\n")))
+	response, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	common.Must(err)
+	if response.StatusCode != 201 {
+		t.Error("status: ", response.StatusCode)
+	}
+	if response.Header.Get("Server") != "nginx" || response.Header.Get("Content-Type") != "application/grpc" || response.Header.Get("X-Request") != "" {
+		t.Error("headers: ", response.Header)
+	}
+}
+
+func TestHTTPListenerHTTP1(t *testing.T) {
+	for _, mode := range []Config_Mode{Config_H2, Config_AUTO} {
+		port := tcp.PickPort()
+		listener, err := Listen(context.Background(), net.LocalHostIP, port, &internet.MemoryStreamConfig{
+			ProtocolName:     "http",
+			ProtocolSettings: &Config{Mode: mode},
+			SecurityType:     "tls",
+			SecuritySettings: &tls.Config{
+				Certificate: []*tls.Certificate{tls.ParseCertificate(cert.MustGenerate(nil, cert.CommonName("www.example.com")))},
+			},
+		}, func(conn stat.Connection) {
+			conn.Close()
+		})
+		common.Must(err)
+		time.Sleep(100 * time.Millisecond)
+
+		conn, err := gotls.Dial("tcp", net.TCPDestination(net.LocalHostIP, port).NetAddr(), &gotls.Config{
+			InsecureSkipVerify: true,
+			NextProtos:         []string{"http/1.1"},
+		})
+		common.Must(err)
+		// a request that isn't an HTTP/1.1 stream, which is rejected only when HTTP/1.1 streams are accepted
+		common.Must2(conn.Write([]byte("GET / HTTP/1.1\r\nHost: www.example.com\r\n\r\n")))
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		response, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		common.Must(err)
+		if (response.StatusCode == http.StatusBadRequest) != (mode == Config_AUTO) {
+			t.Error("mode ", mode, ", status ", response.StatusCode)
+		}
+		conn.Close()
+		listener.Close()
+	}
+}
+
+func TestHTTPHealthCheck(t *testing.T) {
+	port := tcp.PickPort()
+
+	listener, err := Listen(context.Background(), net.LocalHostIP, port, &internet.MemoryStreamConfig{
+		ProtocolName:     "http",
+		ProtocolSettings: &Config{},
+		SecurityType:     "tls",
+		SecuritySettings: &tls.Config{
+			Certificate: []*tls.Certificate{tls.ParseCertificate(cert.MustGenerate(nil, cert.CommonName("www.example.com")))},
+		},
+	}, func(conn stat.Connection) {
+		go func() {
+			defer conn.Close()
+			io.Copy(conn, conn)
+		}()
+	})
+	common.Must(err)
+	defer listener.Close()
+
+	// the relay stops forwarding once frozen, but keeps its connections open, as a dead NAT mapping would
+	relayPort := tcp.PickPort()
+	relay, err := gonet.Listen("tcp", net.TCPDestination(net.LocalHostIP, relayPort).NetAddr())
+	common.Must(err)
+	defer relay.Close()
+	frozen := make(chan struct{})
+	go func() {
+		for {
+			conn, err := relay.Accept()
+			if err != nil {
+				return
+			}
+			upstream, err := gonet.Dial("tcp", net.TCPDestination(net.LocalHostIP, port).NetAddr())
+			common.Must(err)
+			forward := func(dst, src gonet.Conn) {
+				b := make([]byte, 32*1024)
+				for {
+					n, err := src.Read(b)
+					if err != nil {
+						return
+					}
+					select {
+					case <-frozen:
+						<-time.After(time.Minute)
+						return
+					default:
+					}
+					if _, err := dst.Write(b[:n]); err != nil {
+						return
+					}
+				}
+			}
+			go forward(upstream, conn)
+			go forward(conn, upstream)
+		}
+	}()
+
+	time.Sleep(time.Second)
+
+	conn, err := Dial(context.Background(), net.TCPDestination(net.LocalHostIP, relayPort), &internet.MemoryStreamConfig{
+		ProtocolName:     "http",
+		ProtocolSettings: &Config{IdleTimeout: 1, HealthCheckTimeout: 1},
+		SecurityType:     "tls",
+		SecuritySettings: &tls.Config{
+			ServerName:    "www.example.com",
+			AllowInsecure: true,
+		},
+	})
+	common.Must(err)
+	defer conn.Close()
+
+	common.Must2(conn.Write([]byte("Request")))
+	b := make([]byte, 7)
+	common.Must2(io.ReadFull(conn, b))
+
+	close(frozen)
+	start := time.Now()
+	if _, err := conn.Read(b); err == nil {
+		t.Error("expected the dead connection to be closed")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Error("dead connection closed after ", elapsed)
+	}
+}
@@ -2,11 +2,15 @@ package http
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/httputil"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/pires/go-proxyproto"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/net/cnc"
@@ -21,11 +25,17 @@ import (
 )
 
 type Listener struct {
-	server  *http.Server
+	server  common.Closable
 	handler internet.ConnHandler
 	local   net.Addr
 	config  *Config
 	locker  *internet.FileLocker // for unix domain socket
+
+	splitAccess   sync.Mutex
+	splitSessions map[string]*splitSession
+
+	uploadAccess   sync.Mutex
+	uploadSessions map[string]*uploadSession
 }
 
 func (l *Listener) Addr() net.Addr {
@@ -42,6 +52,7 @@ func (l *Listener) Close() error {
 type flushWriter struct {
 	w io.Writer
 	d *done.Instance
+	t *streamTrailer
 }
 
 func (fw flushWriter) Write(p []byte) (n int, err error) {
@@ -50,6 +61,12 @@ func (fw flushWriter) Write(p []byte) (n int, err error) {
 	}
 
 	n, err = fw.w.Write(p)
+	if fw.t != nil {
+		fw.t.written.Add(int64(n))
+		if err != nil {
+			fw.t.failed.Store(true)
+		}
+	}
 	if f, ok := fw.w.(http.Flusher); ok && err == nil {
 		f.Flush()
 	}
@@ -70,17 +87,12 @@ func (l *Listener) ServeHTTP(writer http.ResponseWriter, request *http.Request)
 
 	writer.Header().Set("Cache-Control", "no-store")
 
-	for _, httpHeader := range l.config.Header {
+	for _, httpHeader := range l.config.getResponseHeaders() {
 		for _, httpHeaderValue := range httpHeader.Value {
 			writer.Header().Set(httpHeader.Name, httpHeaderValue)
 		}
 	}
 
-	writer.WriteHeader(200)
-	if f, ok := writer.(http.Flusher); ok {
-		f.Flush()
-	}
-
 	remoteAddr := l.Addr()
 	dest, err := net.ParseDestination(request.RemoteAddr)
 	if err != nil {
@@ -100,11 +112,36 @@ func (l *Listener) ServeHTTP(writer http.ResponseWriter, request *http.Request)
 		}
 	}
 
+	if query := request.URL.Query(); query.Get("s") != "" {
+		l.serveSplit(writer, request, remoteAddr, query)
+		return
+	} else if query.Get("u") != "" {
+		l.serveUpload(writer, request, query)
+		return
+	}
+
+	if request.ProtoMajor == 1 && l.config.acceptsHTTP1() {
+		l.serveHTTP1(writer, request, remoteAddr)
+		return
+	}
+
+	upload := l.newUpload(writer, request)
+	// quic-go does not send trailers
+	if request.ProtoMajor != 3 {
+		announceTrailers(writer.Header())
+	}
+	writer.WriteHeader(l.config.getResponseStatus())
+	if f, ok := writer.(http.Flusher); ok {
+		f.Flush()
+	}
+
 	done := done.New()
+	trailer := new(streamTrailer)
+	defer trailer.write(writer.Header())
 	conn := cnc.NewConnection(
-		cnc.ConnectionOutput(request.Body),
-		cnc.ConnectionInput(flushWriter{w: writer, d: done}),
-		cnc.ConnectionOnClose(common.ChainedClosable{done, request.Body}),
+		cnc.ConnectionOutput(upload),
+		cnc.ConnectionInput(flushWriter{w: writer, d: done, t: trailer}),
+		cnc.ConnectionOnClose(common.ChainedClosable{done, upload}),
 		cnc.ConnectionLocalAddr(l.Addr()),
 		cnc.ConnectionRemoteAddr(remoteAddr),
 	)
@@ -112,6 +149,43 @@ func (l *Listener) ServeHTTP(writer http.ResponseWriter, request *http.Request)
 	<-done.Wait()
 }
 
+// serveHTTP1 takes over an HTTP/1.1 connection to stream chunked bodies in both directions,
+// which net/http does not support on HTTP/1.1.
+func (l *Listener) serveHTTP1(writer http.ResponseWriter, request *http.Request, remoteAddr net.Addr) {
+	if len(request.TransferEncoding) == 0 || request.TransferEncoding[0] != "chunked" {
+		writer.WriteHeader(400)
+		return
+	}
+	hijacker, ok := writer.(http.Hijacker)
+	if !ok {
+		writer.WriteHeader(500)
+		return
+	}
+	header := writer.Header().Clone()
+	rawConn, brw, err := hijacker.Hijack()
+	if err != nil {
+		newError("failed to hijack HTTP/1.1 connection").Base(err).WriteToLog()
+		return
+	}
+
+	status := l.config.getResponseStatus()
+	fmt.Fprintf(brw, "HTTP/1.1 %d %s\r\n", status, http.StatusText(status))
+	header.Write(brw)
+	brw.WriteString("Transfer-Encoding: chunked\r\n\r\n")
+	if err := brw.Flush(); err != nil {
+		rawConn.Close()
+		return
+	}
+
+	l.handler(cnc.NewConnection(
+		cnc.ConnectionOutput(httputil.NewChunkedReader(brw.Reader)),
+		cnc.ConnectionInput(httputil.NewChunkedWriter(rawConn)),
+		cnc.ConnectionOnClose(rawConn),
+		cnc.ConnectionLocalAddr(l.Addr()),
+		cnc.ConnectionRemoteAddr(remoteAddr),
+	))
+}
+
 func Listen(ctx context.Context, address net.Address, port net.Port, streamSettings *internet.MemoryStreamConfig, handler internet.ConnHandler) (internet.Listener, error) {
 	httpSettings := streamSettings.ProtocolSettings.(*Config)
 	var listener *Listener
@@ -135,6 +209,10 @@ func Listen(ctx context.Context, address net.Address, port net.Port, streamSetti
 		}
 	}
 
+	if httpSettings.Mode == Config_H3 {
+		return listenHTTP3(ctx, address, port, streamSettings, listener)
+	}
+
 	var server *http.Server
 	config := tls.ConfigFromStreamSettings(streamSettings)
 	if config == nil {
@@ -148,13 +226,16 @@ func Listen(ctx context.Context, address net.Address, port net.Port, streamSetti
 	} else {
 		server = &http.Server{
 			Addr:              serial.Concat(address, ":", port),
+			// ServeTLS offers http/1.1 after h2 by ALPN as well, whether or not HTTP/1.1 streams are accepted
 			TLSConfig:         config.GetTLSConfig(tls.WithNextProto("h2")),
 			Handler:           listener,
 			ReadHeaderTimeout: time.Second * 4,
 		}
 	}
 
-	if streamSettings.SocketSettings != nil && streamSettings.SocketSettings.AcceptProxyProtocol {
+	// the socket settings wrap the listener themselves when they accept PROXY protocol
+	socketProxyProtocol := streamSettings.SocketSettings != nil && streamSettings.SocketSettings.AcceptProxyProtocol
+	if httpSettings.AcceptProxyProtocol || socketProxyProtocol {
 		newError("accepting PROXY protocol").AtWarning().WriteToLog(session.ExportIDToError(ctx))
 	}
 
@@ -186,6 +267,11 @@ func Listen(ctx context.Context, address net.Address, port net.Port, streamSetti
 			}
 		}
 
+		if httpSettings.AcceptProxyProtocol && !socketProxyProtocol {
+			policyFunc := func(upstream net.Addr) (proxyproto.Policy, error) { return proxyproto.REQUIRE, nil }
+			streamListener = &proxyproto.Listener{Listener: streamListener, Policy: policyFunc}
+		}
+
 		if config == nil {
 			err = server.Serve(streamListener)
 			if err != nil {
@@ -0,0 +1,404 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/net/cnc"
+	"github.com/xtls/xray-core/common/signal/done"
+	"github.com/xtls/xray-core/transport/internet/stat"
+)
+
+// A split downlink carries the downlink of one connection over several GET streams. The uplink request
+// and the GET streams are tied together by a session id in the query string. The server writes frames
+// of [seq uint32][length uint16][payload] to whichever stream is ready, and the client puts them back in order.
+
+const (
+	maxDownlinkStreams = 16
+	// frames a stream may run ahead of the next expected frame on the client
+	maxPendingFrames = 256
+	// time for all streams of a session to arrive on the server
+	splitSessionTimeout = 10 * time.Second
+	frameHeaderSize     = 6
+)
+
+func splitQuery(id string, streams int, index int) string {
+	q := url.Values{}
+	q.Set("s", id)
+	q.Set("n", strconv.Itoa(streams))
+	if index >= 0 {
+		q.Set("i", strconv.Itoa(index))
+	}
+	return q.Encode()
+}
+
+// frameReader reassembles frames read from the downlink streams.
+type frameReader struct {
+	sync.Mutex
+	cond    *sync.Cond
+	frames  map[uint32][]byte
+	next    uint32
+	ended   int
+	err     error
+	bodies  []io.ReadCloser
+	pending []byte
+}
+
+func newFrameReader(bodies []io.ReadCloser) *frameReader {
+	r := &frameReader{
+		frames: make(map[uint32][]byte),
+		bodies: bodies,
+	}
+	r.cond = sync.NewCond(&r.Mutex)
+	for _, body := range bodies {
+		go r.readStream(body)
+	}
+	return r
+}
+
+func (r *frameReader) readStream(body io.Reader) {
+	var header [frameHeaderSize]byte
+	for {
+		if _, err := io.ReadFull(body, header[:]); err != nil {
+			r.endStream(err)
+			return
+		}
+		seq := binary.BigEndian.Uint32(header[:4])
+		payload := make([]byte, binary.BigEndian.Uint16(header[4:]))
+		if _, err := io.ReadFull(body, payload); err != nil {
+			r.endStream(err)
+			return
+		}
+
+		r.Lock()
+		for seq-r.next >= maxPendingFrames && r.err == nil {
+			r.cond.Wait()
+		}
+		r.frames[seq] = payload
+		r.cond.Broadcast()
+		r.Unlock()
+	}
+}
+
+func (r *frameReader) endStream(err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if err == io.EOF {
+		// The downlink ends when all of its streams do.
+		r.ended++
+		if r.ended < len(r.bodies) {
+			return
+		}
+	}
+	if r.err == nil {
+		r.err = err
+	}
+	r.cond.Broadcast()
+}
+
+func (r *frameReader) Read(b []byte) (int, error) {
+	if len(r.pending) == 0 {
+		r.Lock()
+		for {
+			if payload, found := r.frames[r.next]; found {
+				delete(r.frames, r.next)
+				r.next++
+				r.cond.Broadcast()
+				r.pending = payload
+				break
+			}
+			if r.err != nil {
+				err := r.err
+				r.Unlock()
+				return 0, err
+			}
+			r.cond.Wait()
+		}
+		r.Unlock()
+	}
+	n := copy(b, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *frameReader) Close() error {
+	r.Lock()
+	if r.err == nil {
+		r.err = io.ErrClosedPipe
+	}
+	r.cond.Broadcast()
+	r.Unlock()
+	for _, body := range r.bodies {
+		body.Close()
+	}
+	return nil
+}
+
+// dialSplitDownlink sends the uplink over the given request and receives the downlink over parallel GET streams,
+// whose responses have the given status.
+func dialSplitDownlink(client *http.Client, request *http.Request, bwriter *buf.BufferedWriter, streams int, status int) (stat.Connection, error) {
+	var rawID [16]byte
+	common.Must2(io.ReadFull(rand.Reader, rawID[:]))
+	id := hex.EncodeToString(rawID[:])
+
+	requests := make([]*http.Request, 0, streams+1)
+	request.URL.RawQuery = splitQuery(id, streams, -1)
+	requests = append(requests, request)
+	for i := 0; i < streams; i++ {
+		u := *request.URL
+		u.RawQuery = splitQuery(id, streams, i)
+		requests = append(requests, &http.Request{
+			Method:     "GET",
+			Host:       request.Host,
+			URL:        &u,
+			Proto:      request.Proto,
+			ProtoMajor: request.ProtoMajor,
+			ProtoMinor: request.ProtoMinor,
+			Header:     request.Header.Clone(),
+		})
+	}
+
+	responses := make([]*http.Response, len(requests))
+	errs := make([]error, len(requests))
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i], errs[i] = client.Do(requests[i])
+			if errs[i] == nil && responses[i].StatusCode != status {
+				errs[i] = newError("unexpected status", responses[i].StatusCode)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var err error
+	for i := range requests {
+		if errs[i] != nil && err == nil {
+			err = errs[i]
+		}
+	}
+	if err != nil {
+		for _, response := range responses {
+			if response != nil {
+				response.Body.Close()
+			}
+		}
+		return nil, err
+	}
+
+	bodies := make([]io.ReadCloser, 0, streams)
+	for _, response := range responses[1:] {
+		bodies = append(bodies, response.Body)
+	}
+	reader := newFrameReader(bodies)
+	return cnc.NewConnection(
+		cnc.ConnectionOutput(reader),
+		cnc.ConnectionInput(bwriter),
+		cnc.ConnectionOnClose(common.ChainedClosable{bwriter, responses[0].Body, reader}),
+	), nil
+}
+
+// splitSession collects the requests of a split downlink on the server.
+type splitSession struct {
+	sync.Mutex
+	id        string
+	streams   int
+	uplink    io.ReadCloser
+	downlinks uint16 // bit i is set once stream i is attached
+	attached  int
+	seq       uint32
+	frames    chan []byte
+	ready     *done.Instance
+	closed    *done.Instance
+	closeOnce sync.Once
+}
+
+// Write implements io.Writer. It queues frames for the downlink streams.
+func (s *splitSession) Write(b []byte) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	n := 0
+	for len(b) > 0 {
+		size := len(b)
+		if size > buf.Size {
+			size = buf.Size
+		}
+		frame := make([]byte, frameHeaderSize+size)
+		binary.BigEndian.PutUint32(frame, s.seq)
+		binary.BigEndian.PutUint16(frame[4:], uint16(size))
+		copy(frame[frameHeaderSize:], b[:size])
+		select {
+		case s.frames <- frame:
+		case <-s.closed.Wait():
+			return n, io.ErrClosedPipe
+		}
+		s.seq++
+		n += size
+		b = b[size:]
+	}
+	return n, nil
+}
+
+func (s *splitSession) Close() error {
+	s.closeOnce.Do(func() {
+		s.closed.Close()
+		if s.uplink != nil {
+			s.uplink.Close()
+		}
+	})
+	return nil
+}
+
+func (l *Listener) getSplitSession(id string, streams int) *splitSession {
+	l.splitAccess.Lock()
+	defer l.splitAccess.Unlock()
+
+	if l.splitSessions == nil {
+		l.splitSessions = make(map[string]*splitSession)
+	}
+	if s, found := l.splitSessions[id]; found {
+		if s.streams != streams {
+			return nil
+		}
+		return s
+	}
+	s := &splitSession{
+		id:      id,
+		streams: streams,
+		frames:  make(chan []byte, streams),
+		ready:   done.New(),
+		closed:  done.New(),
+	}
+	l.splitSessions[id] = s
+	time.AfterFunc(splitSessionTimeout, func() {
+		l.removeSplitSession(s)
+		if !s.ready.Done() {
+			s.Close()
+		}
+	})
+	return s
+}
+
+func (l *Listener) removeSplitSession(s *splitSession) {
+	l.splitAccess.Lock()
+	defer l.splitAccess.Unlock()
+
+	if l.splitSessions[s.id] == s {
+		delete(l.splitSessions, s.id)
+	}
+}
+
+// serveSplit handles one request of a split downlink session.
+func (l *Listener) serveSplit(writer http.ResponseWriter, request *http.Request, remoteAddr net.Addr, query url.Values) {
+	streams, err := strconv.Atoi(query.Get("n"))
+	if err != nil || streams < 2 || streams > maxDownlinkStreams {
+		writer.WriteHeader(400)
+		return
+	}
+	index := -1
+	if i := query.Get("i"); i != "" {
+		if index, err = strconv.Atoi(i); err != nil || index < 0 || index >= streams {
+			writer.WriteHeader(400)
+			return
+		}
+	}
+	s := l.getSplitSession(query.Get("s"), streams)
+	if s == nil {
+		writer.WriteHeader(400)
+		return
+	}
+
+	s.Lock()
+	if index < 0 {
+		if s.uplink != nil {
+			s.Unlock()
+			writer.WriteHeader(400)
+			return
+		}
+		s.uplink = request.Body
+	} else {
+		if s.downlinks&(1<<index) != 0 {
+			s.Unlock()
+			writer.WriteHeader(400)
+			return
+		}
+		s.downlinks |= 1 << index
+	}
+	s.attached++
+	complete := s.attached == streams+1
+	s.Unlock()
+
+	if complete {
+		l.removeSplitSession(s)
+		s.ready.Close()
+		l.handler(cnc.NewConnection(
+			cnc.ConnectionOutput(s.uplink),
+			cnc.ConnectionInput(s),
+			cnc.ConnectionOnClose(s),
+			cnc.ConnectionLocalAddr(l.Addr()),
+			cnc.ConnectionRemoteAddr(remoteAddr),
+		))
+	}
+
+	select {
+	case <-s.ready.Wait():
+	case <-s.closed.Wait():
+		writer.WriteHeader(504)
+		return
+	}
+
+	writer.WriteHeader(l.config.getResponseStatus())
+	flusher, _ := writer.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	if index < 0 {
+		<-s.closed.Wait()
+		return
+	}
+
+	write := func(frame []byte) bool {
+		if _, err := writer.Write(frame); err != nil {
+			s.Close()
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	}
+	for {
+		select {
+		case frame := <-s.frames:
+			if !write(frame) {
+				return
+			}
+		case <-s.closed.Wait():
+			// send out what was queued before the connection was closed
+			for {
+				select {
+				case frame := <-s.frames:
+					if !write(frame) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
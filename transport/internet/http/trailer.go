@@ -0,0 +1,89 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+// The HTTP/2 hub ends a response with trailers telling how many bytes it wrote and why the stream ended, so
+// the dialer can tell a graceful end from a stream cut short on the way, such as by a CDN. Servers that
+// announce no trailers are trusted on EOF, as before.
+
+const (
+	trailerBytes = "X-Stream-Bytes"
+	trailerClose = "X-Stream-Close"
+
+	closeEOF   = "eof"
+	closeError = "error"
+)
+
+var errStreamTruncated = newError("stream truncated")
+
+// streamTrailer collects what the hub reports in the trailers of a response.
+type streamTrailer struct {
+	written atomic.Int64
+	failed  atomic.Bool
+}
+
+func announceTrailers(header http.Header) {
+	header.Set("Trailer", trailerBytes+", "+trailerClose)
+}
+
+func (t *streamTrailer) write(header http.Header) {
+	reason := closeEOF
+	if t.failed.Load() {
+		reason = closeError
+	}
+	header.Set(trailerBytes, strconv.FormatInt(t.written.Load(), 10))
+	header.Set(trailerClose, reason)
+}
+
+// trailerReader reads a response body and checks its trailers on EOF.
+type trailerReader struct {
+	io.ReadCloser
+	response  *http.Response
+	announced bool
+	read      int64
+	// onTruncated is called once when the stream turns out to be cut short.
+	onTruncated func()
+}
+
+func newTrailerReader(response *http.Response, onTruncated func()) *trailerReader {
+	_, announced := response.Trailer[trailerClose]
+	return &trailerReader{
+		ReadCloser:  response.Body,
+		response:    response,
+		announced:   announced,
+		onTruncated: onTruncated,
+	}
+}
+
+func (r *trailerReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.read += int64(n)
+	if err == io.EOF && r.announced {
+		if err = r.check(); errors.Is(err, errStreamTruncated) && r.onTruncated != nil {
+			r.onTruncated()
+			r.onTruncated = nil
+		}
+	}
+	return n, err
+}
+
+// check returns io.EOF if the stream ended gracefully.
+func (r *trailerReader) check() error {
+	reason := r.response.Trailer.Get(trailerClose)
+	if reason == "" {
+		return newError("no trailers after ", r.read, " bytes").Base(errStreamTruncated)
+	}
+	if written, err := strconv.ParseInt(r.response.Trailer.Get(trailerBytes), 10, 64); err != nil || written != r.read {
+		return newError("received ", r.read, " of ", r.response.Trailer.Get(trailerBytes), " bytes").Base(errStreamTruncated)
+	}
+	if reason != closeEOF {
+		return newError("stream closed by server: ", reason)
+	}
+	return io.EOF
+}
@@ -0,0 +1,358 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/signal/done"
+	"github.com/xtls/xray-core/transport/pipe"
+)
+
+// An uplink may span several requests for CDNs that cap the size of request bodies. The dialer names its
+// uplink in the upload session header of the first request, along with its own maximum upload size if it
+// has one. A listener with a maximum upload size advertises it in the response, lowered to the one of the
+// dialer if that is smaller, and both sides split the uplink at the advertised size. Once a request body reaches that size, the dialer ends it and carries on
+// in a continuation request, whose query names the session and the position of the request in the uplink.
+// A body shorter than the maximum ends the uplink.
+
+const (
+	uploadSessionHeader = "X-Upload-Session"
+	maxUploadSizeHeader = "X-Max-Upload-Size"
+	// time for the next request of an uplink to arrive on the server
+	uploadTimeout = 10 * time.Second
+)
+
+func uploadQuery(id string, seq int) string {
+	q := url.Values{}
+	q.Set("u", id)
+	q.Set("q", strconv.Itoa(seq))
+	return q.Encode()
+}
+
+// uploadWriter writes the uplink, moving on to a continuation request whenever a request body is full.
+type uploadWriter struct {
+	client  *http.Client
+	request *http.Request
+	opts    []pipe.Option
+	id      string
+	max     int64
+
+	access  sync.Mutex
+	writer  *buf.BufferedWriter
+	written int64
+	seq     int
+	err     error
+}
+
+func newUploadWriter(client *http.Client, request *http.Request, writer *buf.BufferedWriter, opts []pipe.Option, limit uint64) *uploadWriter {
+	var rawID [16]byte
+	common.Must2(io.ReadFull(rand.Reader, rawID[:]))
+	w := &uploadWriter{
+		client:  client,
+		request: request,
+		opts:    opts,
+		id:      hex.EncodeToString(rawID[:]),
+		writer:  writer,
+	}
+	request.Header.Set(uploadSessionHeader, w.id)
+	if limit > 0 {
+		request.Header.Set(maxUploadSizeHeader, strconv.FormatUint(limit, 10))
+	}
+	return w
+}
+
+// setLimit applies the maximum upload size the server advertised. It is never lowered here, as the server
+// ends the uplink at the first body shorter than the size it advertised.
+func (w *uploadWriter) setLimit(response *http.Response) {
+	advertised, err := strconv.ParseUint(response.Header.Get(maxUploadSizeHeader), 10, 63)
+	if err != nil || advertised == 0 {
+		return
+	}
+	w.max = int64(advertised)
+}
+
+// Write implements io.Writer.
+func (w *uploadWriter) Write(b []byte) (int, error) {
+	w.access.Lock()
+	defer w.access.Unlock()
+
+	if w.max == 0 {
+		return w.writer.Write(b)
+	}
+	n := 0
+	for len(b) > 0 {
+		if w.err != nil {
+			return n, w.err
+		}
+		size := int64(len(b))
+		if room := w.max - w.written; size > room {
+			size = room
+		}
+		if _, err := w.writer.Write(b[:size]); err != nil {
+			return n, err
+		}
+		w.written += size
+		n += int(size)
+		b = b[size:]
+		if w.written == w.max {
+			w.next()
+		}
+	}
+	return n, nil
+}
+
+// next ends the current request body and starts the next request of the uplink.
+func (w *uploadWriter) next() {
+	w.writer.Close()
+
+	preader, pwriter := pipe.New(w.opts...)
+	reader := newPipeBody(preader)
+	w.writer = buf.NewBufferedWriter(pwriter)
+	common.Must(w.writer.SetBuffered(false))
+	w.written = 0
+	w.seq++
+
+	u := *w.request.URL
+	u.RawQuery = uploadQuery(w.id, w.seq)
+	request := &http.Request{
+		Method:     w.request.Method,
+		Host:       w.request.Host,
+		URL:        &u,
+		Proto:      w.request.Proto,
+		ProtoMajor: w.request.ProtoMajor,
+		ProtoMinor: w.request.ProtoMinor,
+		Header:     w.request.Header.Clone(),
+		Body:       reader,
+	}
+	request.Header.Del(uploadSessionHeader)
+	go func() {
+		response, err := w.client.Do(request)
+		if err == nil {
+			if response.StatusCode != 200 {
+				err = newError("unexpected status", response.StatusCode)
+			}
+			response.Body.Close()
+		}
+		if err != nil {
+			// unblock writes to the body first, as they hold the lock
+			reader.Interrupt()
+			w.fail(newError("failed to continue upload").Base(err))
+		}
+	}()
+}
+
+func (w *uploadWriter) fail(err error) {
+	w.access.Lock()
+	defer w.access.Unlock()
+
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+// Close implements io.Closer.
+func (w *uploadWriter) Close() error {
+	w.access.Lock()
+	defer w.access.Unlock()
+
+	return w.writer.Close()
+}
+
+// uploadSession puts the requests of an uplink back together on the server.
+type uploadSession struct {
+	sync.Mutex
+	cond    *sync.Cond
+	id      string
+	max     int64
+	bodies  map[int]io.ReadCloser
+	next    int
+	current io.ReadCloser
+	read    int64
+	// consumed holds what is closed when the body of a continuation request has been read.
+	consumed map[int]*done.Instance
+	closed   bool
+	onClose  func()
+}
+
+func newUploadSession(id string, max int64, body io.ReadCloser, onClose func()) *uploadSession {
+	s := &uploadSession{
+		id:       id,
+		max:      max,
+		bodies:   make(map[int]io.ReadCloser),
+		current:  body,
+		consumed: make(map[int]*done.Instance),
+		onClose:  onClose,
+	}
+	s.cond = sync.NewCond(&s.Mutex)
+	return s
+}
+
+// add hands over a continuation request and returns what is closed when its body is read.
+func (s *uploadSession) add(seq int, body io.ReadCloser) *done.Instance {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.closed || seq < s.next || seq == s.next && s.current != nil {
+		return nil
+	}
+	if _, found := s.bodies[seq]; found {
+		return nil
+	}
+	consumed := done.New()
+	s.bodies[seq] = body
+	s.consumed[seq] = consumed
+	s.cond.Broadcast()
+	return consumed
+}
+
+// Read implements io.Reader.
+func (s *uploadSession) Read(b []byte) (int, error) {
+	for {
+		s.Lock()
+		if s.current == nil {
+			timer := time.AfterFunc(uploadTimeout, func() {
+				s.Lock()
+				s.closed = true
+				s.cond.Broadcast()
+				s.Unlock()
+			})
+			for s.bodies[s.next] == nil && !s.closed {
+				s.cond.Wait()
+			}
+			timer.Stop()
+			if s.closed {
+				s.Unlock()
+				return 0, io.ErrClosedPipe
+			}
+			s.current = s.bodies[s.next]
+			delete(s.bodies, s.next)
+		}
+		current := s.current
+		s.Unlock()
+
+		n, err := current.Read(b)
+		s.read += int64(n)
+		if err != io.EOF {
+			return n, err
+		}
+		if s.read < s.max {
+			// a body short of the maximum ends the uplink
+			return n, io.EOF
+		}
+
+		s.Lock()
+		if consumed := s.consumed[s.next]; consumed != nil {
+			consumed.Close()
+			delete(s.consumed, s.next)
+		}
+		s.current = nil
+		s.read = 0
+		s.next++
+		s.Unlock()
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// Close implements io.Closer.
+func (s *uploadSession) Close() error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.closed && s.onClose == nil {
+		return nil
+	}
+	s.closed = true
+	s.cond.Broadcast()
+	if s.current != nil {
+		s.current.Close()
+	}
+	for _, body := range s.bodies {
+		body.Close()
+	}
+	for _, consumed := range s.consumed {
+		consumed.Close()
+	}
+	if s.onClose != nil {
+		s.onClose()
+		s.onClose = nil
+	}
+	return nil
+}
+
+func (l *Listener) addUploadSession(s *uploadSession) bool {
+	l.uploadAccess.Lock()
+	defer l.uploadAccess.Unlock()
+
+	if l.uploadSessions == nil {
+		l.uploadSessions = make(map[string]*uploadSession)
+	}
+	if _, found := l.uploadSessions[s.id]; found {
+		return false
+	}
+	l.uploadSessions[s.id] = s
+	return true
+}
+
+func (l *Listener) removeUploadSession(s *uploadSession) {
+	l.uploadAccess.Lock()
+	defer l.uploadAccess.Unlock()
+
+	if l.uploadSessions[s.id] == s {
+		delete(l.uploadSessions, s.id)
+	}
+}
+
+// newUpload returns the uplink of a request, spanning continuation requests if the listener has a maximum
+// upload size and the dialer asked for it.
+func (l *Listener) newUpload(writer http.ResponseWriter, request *http.Request) io.ReadCloser {
+	id := request.Header.Get(uploadSessionHeader)
+	if l.config.MaxUploadSize == 0 || id == "" {
+		return request.Body
+	}
+	max := l.config.MaxUploadSize
+	if limit, err := strconv.ParseUint(request.Header.Get(maxUploadSizeHeader), 10, 63); err == nil && limit > 0 && limit < max {
+		max = limit
+	}
+	var s *uploadSession
+	s = newUploadSession(id, int64(max), request.Body, func() {
+		l.removeUploadSession(s)
+	})
+	if !l.addUploadSession(s) {
+		return request.Body
+	}
+	writer.Header().Set(maxUploadSizeHeader, strconv.FormatUint(max, 10))
+	return s
+}
+
+// serveUpload handles a continuation request of an uplink.
+func (l *Listener) serveUpload(writer http.ResponseWriter, request *http.Request, query url.Values) {
+	seq, err := strconv.Atoi(query.Get("q"))
+	if err != nil {
+		writer.WriteHeader(400)
+		return
+	}
+	l.uploadAccess.Lock()
+	s := l.uploadSessions[query.Get("u")]
+	l.uploadAccess.Unlock()
+	if s == nil {
+		writer.WriteHeader(404)
+		return
+	}
+	consumed := s.add(seq, request.Body)
+	if consumed == nil {
+		writer.WriteHeader(400)
+		return
+	}
+	<-consumed.Wait()
+	writer.WriteHeader(200)
+}
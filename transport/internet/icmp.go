@@ -0,0 +1,34 @@
+package internet
+
+import (
+	"errors"
+	"syscall"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+// IsUnreachable returns whether the error is caused by an ICMP port, host or network unreachable message.
+func IsUnreachable(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH)
+}
+
+// EnableICMPErrors asks the system to report ICMP errors of the socket, which are otherwise dropped
+// for sockets that are not connected. A read then fails with an unreachable error once, and
+// ICMPErrors tells which destinations the error came from. It returns false if this is not supported.
+func (c *PacketConnWrapper) EnableICMPErrors() bool {
+	conn, ok := c.Conn.(*net.UDPConn)
+	if !ok {
+		return false
+	}
+	return enableICMPErrors(conn)
+}
+
+// ICMPErrors takes the pending ICMP errors of the socket, and returns the destinations of the packets
+// that caused them.
+func (c *PacketConnWrapper) ICMPErrors() []net.Addr {
+	conn, ok := c.Conn.(*net.UDPConn)
+	if !ok {
+		return nil
+	}
+	return readICMPErrors(conn)
+}
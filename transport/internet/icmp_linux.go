@@ -0,0 +1,54 @@
+//go:build linux
+// +build linux
+
+package internet
+
+import (
+	"syscall"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+func enableICMPErrors(conn *net.UDPConn) bool {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return false
+	}
+	enabled := false
+	rawConn.Control(func(fd uintptr) {
+		// a dual-stack socket needs both, as IPv4 errors are reported by IP_RECVERR
+		if syscall.SetsockoptInt(int(fd), syscall.SOL_IP, syscall.IP_RECVERR, 1) == nil {
+			enabled = true
+		}
+		if syscall.SetsockoptInt(int(fd), syscall.SOL_IPV6, syscall.IPV6_RECVERR, 1) == nil {
+			enabled = true
+		}
+	})
+	return enabled
+}
+
+func readICMPErrors(conn *net.UDPConn) []net.Addr {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return nil
+	}
+	var addrs []net.Addr
+	b := make([]byte, 1)
+	oob := make([]byte, 512)
+	rawConn.Control(func(fd uintptr) {
+		for {
+			// the name of a message in the error queue is the destination of the packet that failed
+			_, _, _, from, err := syscall.Recvmsg(int(fd), b, oob, syscall.MSG_ERRQUEUE|syscall.MSG_DONTWAIT)
+			if err != nil {
+				return
+			}
+			switch sa := from.(type) {
+			case *syscall.SockaddrInet4:
+				addrs = append(addrs, &net.UDPAddr{IP: net.IP(append([]byte(nil), sa.Addr[:]...)), Port: sa.Port})
+			case *syscall.SockaddrInet6:
+				addrs = append(addrs, &net.UDPAddr{IP: net.IP(append([]byte(nil), sa.Addr[:]...)), Port: sa.Port})
+			}
+		}
+	})
+	return addrs
+}
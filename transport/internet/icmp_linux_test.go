@@ -0,0 +1,41 @@
+package internet_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/testing/servers/udp"
+	. "github.com/xtls/xray-core/transport/internet"
+)
+
+func TestICMPErrors(t *testing.T) {
+	// nothing listens on the port, so the packet is answered with ICMP port unreachable
+	dest := net.UDPDestination(net.LocalHostIP, udp.PickPort())
+	dialer := DefaultSystemDialer{}
+	conn, err := dialer.Dial(context.Background(), nil, dest, nil)
+	common.Must(err)
+	defer conn.Close()
+
+	c := conn.(*PacketConnWrapper)
+	if !c.EnableICMPErrors() {
+		t.Fatal("failed to enable ICMP errors")
+	}
+	common.Must2(c.Write([]byte("ping")))
+
+	c.Conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = c.Read(make([]byte, 16))
+	if !IsUnreachable(err) {
+		t.Fatal("expected unreachable error, but got ", err)
+	}
+
+	addrs := c.ICMPErrors()
+	if len(addrs) != 1 {
+		t.Fatal("addrs: ", addrs)
+	}
+	if addr := addrs[0].(*net.UDPAddr); addr.Port != int(dest.Port) || !addr.IP.Equal(dest.Address.IP()) {
+		t.Error("unexpected destination ", addr)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package internet
+
+import (
+	"github.com/xtls/xray-core/common/net"
+)
+
+func enableICMPErrors(conn *net.UDPConn) bool {
+	return false
+}
+
+func readICMPErrors(conn *net.UDPConn) []net.Addr {
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/transport/internet"
+	"google.golang.org/protobuf/proto"
 )
 
 const protocolName = "mkcp"
@@ -17,6 +18,16 @@ func (c *Config) GetMTUValue() uint32 {
 	return c.Mtu.Value
 }
 
+// clampMTU returns the config with its MTU lowered to the given maximum UDP payload, if it's larger.
+func (c *Config) clampMTU(max int32) *Config {
+	if max <= 0 || c.GetMTUValue() <= uint32(max) {
+		return c
+	}
+	config := proto.Clone(c).(*Config)
+	config.Mtu = &MTU{Value: uint32(max)}
+	return config
+}
+
 // GetTTIValue returns the value of TTI settings.
 func (c *Config) GetTTIValue() uint32 {
 	if c == nil || c.Tti == nil {
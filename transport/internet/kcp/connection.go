@@ -12,6 +12,7 @@ import (
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/signal"
 	"github.com/xtls/xray-core/common/signal/semaphore"
+	"github.com/xtls/xray-core/transport/internet"
 )
 
 var (
@@ -171,6 +172,8 @@ type ConnMetadata struct {
 	LocalAddr    net.Addr
 	RemoteAddr   net.Addr
 	Conversation uint16
+	// Events counts the segments sent over the connection, and how many of them were retransmitted.
+	Events *internet.EventRecorder
 }
 
 // Connection is a KCP connection over UDP.
@@ -17,6 +17,8 @@ import (
 
 var globalConv = uint32(dice.RollUint16())
 
+// fetchInput reads one packet at a time. Unlike the listener, whose hub reads in batches, a dialer
+// has a socket of its own to a single server, so it is not batched.
 func fetchInput(_ context.Context, input io.Reader, reader PacketReader, conn *Connection) {
 	cache := make(chan *buf.Buffer, 1024)
 	go func() {
@@ -54,7 +56,7 @@ func DialKCP(ctx context.Context, dest net.Destination, streamSettings *internet
 		return nil, newError("failed to dial to dest: ", err).AtWarning().Base(err)
 	}
 
-	kcpSettings := streamSettings.ProtocolSettings.(*Config)
+	kcpSettings := streamSettings.ProtocolSettings.(*Config).clampMTU(internet.UDPMaxPayload(streamSettings.SocketSettings))
 
 	header, err := kcpSettings.GetPackerHeader()
 	if err != nil {
@@ -79,6 +81,7 @@ func DialKCP(ctx context.Context, dest net.Destination, streamSettings *internet
 		LocalAddr:    rawConn.LocalAddr(),
 		RemoteAddr:   rawConn.RemoteAddr(),
 		Conversation: conv,
+		Events:       streamSettings.Events,
 	}, writer, rawConn, kcpSettings)
 
 	go fetchInput(ctx, rawConn, reader, session)
@@ -86,7 +89,18 @@ func DialKCP(ctx context.Context, dest net.Destination, streamSettings *internet
 	var iConn stat.Connection = session
 
 	if config := tls.ConfigFromStreamSettings(streamSettings); config != nil {
-		iConn = tls.Client(iConn, config.GetTLSConfig(tls.WithDestination(dest)))
+		tlsConfig := config.GetTLSConfig(tls.WithDestination(dest))
+		if fingerprint := tls.GetFingerprint(config.Fingerprint); fingerprint != nil {
+			iConn = tls.UClient(iConn, tlsConfig, fingerprint)
+			if err := iConn.(*tls.UConn).Handshake(); err != nil {
+				streamSettings.Events.Record(internet.EventTLSHandshakeFailure, 1)
+				iConn.Close()
+				return nil, err
+			}
+		} else {
+			iConn = tls.Client(iConn, tlsConfig)
+			iConn.(*tls.Conn).RecordEvents(streamSettings.Events)
+		}
 	} else if config := xtls.ConfigFromStreamSettings(streamSettings); config != nil {
 		iConn = xtls.Client(iConn, config.GetXTLSConfig(xtls.WithDestination(dest)))
 	}
@@ -35,10 +35,11 @@ type Listener struct {
 	header     internet.PacketHeader
 	security   cipher.AEAD
 	addConn    internet.ConnHandler
+	events     *internet.EventRecorder
 }
 
 func NewListener(ctx context.Context, address net.Address, port net.Port, streamSettings *internet.MemoryStreamConfig, addConn internet.ConnHandler) (*Listener, error) {
-	kcpSettings := streamSettings.ProtocolSettings.(*Config)
+	kcpSettings := streamSettings.ProtocolSettings.(*Config).clampMTU(internet.UDPMaxPayload(streamSettings.SocketSettings))
 	header, err := kcpSettings.GetPackerHeader()
 	if err != nil {
 		return nil, newError("failed to create packet header").Base(err).AtError()
@@ -57,6 +58,7 @@ func NewListener(ctx context.Context, address net.Address, port net.Port, stream
 		sessions: make(map[ConnectionID]*Connection),
 		config:   kcpSettings,
 		addConn:  addConn,
+		events:   streamSettings.Events,
 	}
 
 	if config := tls.ConfigFromStreamSettings(streamSettings); config != nil {
@@ -129,6 +131,7 @@ func (l *Listener) OnReceive(payload *buf.Buffer, src net.Destination) {
 			LocalAddr:    localAddr,
 			RemoteAddr:   remoteAddr,
 			Conversation: conv,
+			Events:       l.events,
 		}, &KCPPacketWriter{
 			Header:   l.header,
 			Security: l.security,
@@ -137,6 +140,7 @@ func (l *Listener) OnReceive(payload *buf.Buffer, src net.Destination) {
 		var netConn stat.Connection = conn
 		if l.tlsConfig != nil {
 			netConn = tls.Server(conn, l.tlsConfig)
+			netConn.(*tls.Conn).RecordEvents(l.events)
 		} else if l.xtlsConfig != nil {
 			netConn = xtls.Server(conn, l.xtlsConfig)
 		}
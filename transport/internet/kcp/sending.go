@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/transport/internet"
 )
 
 type SendingWindow struct {
@@ -12,6 +13,10 @@ type SendingWindow struct {
 	totalInFlightSize uint32
 	writer            SegmentWriter
 	onPacketLoss      func(uint32)
+
+	// segments sent for the first time, and sent again, since the last report to the events of the connection
+	sent          int64
+	retransmitted int64
 }
 
 func NewSendingWindow(writer SegmentWriter, onPacketLoss func(uint32)) *SendingWindow {
@@ -110,8 +115,10 @@ func (sw *SendingWindow) Flush(current uint32, rto uint32, maxInFlightSize uint3
 		if segment.transmit == 0 {
 			// First time
 			sw.totalInFlightSize++
+			sw.sent++
 		} else {
 			lost++
+			sw.retransmitted++
 		}
 		segment.timeout = current + rto
 
@@ -327,12 +334,16 @@ func (w *SendingWorker) Flush(current uint32) {
 		w.window.Flush(current, w.conn.roundTrip.Timeout(), cwnd)
 		w.firstUnacknowledgedUpdated = false
 	}
+	sent, retransmitted := w.window.sent, w.window.retransmitted
+	w.window.sent, w.window.retransmitted = 0, 0
 
 	updated := w.firstUnacknowledgedUpdated
 	w.firstUnacknowledgedUpdated = false
 
 	w.Unlock()
 
+	w.conn.meta.Events.Record(internet.EventKCPSegment, sent)
+	w.conn.meta.Events.Record(internet.EventKCPRetransmit, retransmitted)
 	if updated {
 		w.conn.Ping(current, CommandPing)
 	}
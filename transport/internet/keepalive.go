@@ -0,0 +1,54 @@
+package internet
+
+import (
+	"net"
+	"time"
+
+	"github.com/xtls/xray-core/transport/internet/stat"
+)
+
+// keepAlive is the TCP keep-alive of a connection.
+type keepAlive struct {
+	enabled  bool
+	idle     time.Duration
+	interval time.Duration
+	count    int
+}
+
+// MirrorKeepAlive applies the TCP keep-alive of one connection to another, e.g. of the client-facing connection of
+// a session to the server-facing one, so that middleboxes on either side see the same liveness signals. It does
+// nothing if either is not a TCP connection, or on platforms where the keep-alive of a socket can't be read.
+func MirrorKeepAlive(from, to net.Conn) error {
+	src, dst := rawTCPConn(from), rawTCPConn(to)
+	if src == nil || dst == nil {
+		return nil
+	}
+	ka, err := getKeepAlive(src)
+	if err != nil {
+		return newError("failed to get TCP keep-alive").Base(err)
+	}
+	if ka == nil {
+		return nil
+	}
+	if err := setKeepAlive(dst, ka); err != nil {
+		return newError("failed to set TCP keep-alive").Base(err)
+	}
+	return nil
+}
+
+// rawTCPConn returns the TCP connection under the layers of conn, if any.
+func rawTCPConn(conn net.Conn) *net.TCPConn {
+	for conn != nil {
+		switch c := conn.(type) {
+		case *net.TCPConn:
+			return c
+		case *stat.CounterConnection:
+			conn = c.Connection
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package internet
+
+import (
+	"net"
+	"syscall"
+	"time"
+)
+
+func getKeepAlive(conn *net.TCPConn) (*keepAlive, error) {
+	ka := new(keepAlive)
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var sysErr error
+	err = rawConn.Control(func(fd uintptr) {
+		var enabled, idle, interval int
+		if enabled, sysErr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE); sysErr != nil {
+			return
+		}
+		if idle, sysErr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE); sysErr != nil {
+			return
+		}
+		if interval, sysErr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL); sysErr != nil {
+			return
+		}
+		if ka.count, sysErr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT); sysErr != nil {
+			return
+		}
+		ka.enabled = enabled != 0
+		ka.idle = time.Duration(idle) * time.Second
+		ka.interval = time.Duration(interval) * time.Second
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sysErr != nil {
+		return nil, sysErr
+	}
+	return ka, nil
+}
+
+func setKeepAlive(conn *net.TCPConn, ka *keepAlive) error {
+	if !ka.enabled {
+		return conn.SetKeepAlive(false)
+	}
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sysErr error
+	err = rawConn.Control(func(fd uintptr) {
+		if sysErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE, 1); sysErr != nil {
+			return
+		}
+		if sysErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE, int(ka.idle/time.Second)); sysErr != nil {
+			return
+		}
+		if sysErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL, int(ka.interval/time.Second)); sysErr != nil {
+			return
+		}
+		sysErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT, ka.count)
+	})
+	if err != nil {
+		return err
+	}
+	return sysErr
+}
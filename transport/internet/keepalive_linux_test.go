@@ -0,0 +1,45 @@
+package internet_test
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	. "github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/stat"
+)
+
+func TestMirrorKeepAlive(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	common.Must(err)
+	defer listener.Close()
+
+	dial := func() *net.TCPConn {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		common.Must(err)
+		return conn.(*net.TCPConn)
+	}
+	from := dial()
+	defer from.Close()
+	to := dial()
+	defer to.Close()
+
+	common.Must(from.SetKeepAlive(true))
+	common.Must(from.SetKeepAlivePeriod(42 * time.Second))
+	common.Must(to.SetKeepAlive(false))
+
+	common.Must(MirrorKeepAlive(&stat.CounterConnection{Connection: from}, to))
+
+	rawConn, err := to.SyscallConn()
+	common.Must(err)
+	common.Must(rawConn.Control(func(fd uintptr) {
+		if v, err := syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE); err != nil || v == 0 {
+			t.Error("expect keep-alive enabled, but got ", v, err)
+		}
+		if v, err := syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE); err != nil || v != 42 {
+			t.Error("expect keep-alive idle 42, but got ", v, err)
+		}
+	}))
+}
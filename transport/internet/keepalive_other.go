@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package internet
+
+import (
+	"net"
+)
+
+// getKeepAlive returns nil, as the keep-alive of a socket can't be read here.
+func getKeepAlive(conn *net.TCPConn) (*keepAlive, error) {
+	return nil, nil
+}
+
+func setKeepAlive(conn *net.TCPConn, ka *keepAlive) error {
+	return nil
+}
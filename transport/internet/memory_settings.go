@@ -7,6 +7,8 @@ type MemoryStreamConfig struct {
 	SecurityType     string
 	SecuritySettings interface{}
 	SocketSettings   *SocketConfig
+	// Events counts the transport events of the handler using these settings, if its stats are enabled.
+	Events *EventRecorder
 }
 
 // ToMemoryStreamConfig converts a StreamConfig to MemoryStreamConfig. It returns a default non-nil MemoryStreamConfig for nil input.
@@ -0,0 +1,30 @@
+package plugin
+
+//go:generate go run github.com/xtls/xray-core/common/errors/errorgen
+
+import (
+	"strings"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+const protocolName = "plugin"
+
+// network returns the network of the plugin address, for net.Dial and net.Listen.
+func (c *Config) network() (string, error) {
+	switch {
+	case c.Address == "":
+		return "", newError("empty plugin address")
+	case strings.HasPrefix(c.Address, "/") || strings.HasPrefix(c.Address, "@") || strings.HasPrefix(c.Address, "."):
+		return "unix", nil
+	default:
+		return "tcp", nil
+	}
+}
+
+func init() {
+	common.Must(internet.RegisterProtocolConfigCreator(protocolName, func() interface{} {
+		return new(Config)
+	}))
+}
@@ -0,0 +1,75 @@
+package plugin
+
+import (
+	"context"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/stat"
+	"github.com/xtls/xray-core/transport/internet/tls"
+)
+
+func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig) (stat.Connection, error) {
+	settings := streamSettings.ProtocolSettings.(*Config)
+	network, err := settings.network()
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, settings.Address)
+	if err != nil {
+		return nil, newError("failed to dial plugin: ", settings.Address).Base(err).AtWarning()
+	}
+
+	metadata := &Metadata{
+		Destination: dest.String(),
+		Settings:    settings.Settings,
+	}
+	if inbound := session.InboundFromContext(ctx); inbound != nil && inbound.User != nil {
+		metadata.User = inbound.User.Email
+	}
+	if err := handshake(conn, metadata); err != nil {
+		conn.Close()
+		return nil, newError("plugin rejected stream to ", dest).Base(err).AtWarning()
+	}
+
+	if config := tls.ConfigFromStreamSettings(streamSettings); config != nil {
+		tlsConfig := config.GetTLSConfig(tls.WithDestination(dest))
+		if fingerprint := tls.GetFingerprint(config.Fingerprint); fingerprint != nil {
+			uConn := tls.UClient(conn, tlsConfig, fingerprint).(*tls.UConn)
+			if err := uConn.Handshake(); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return uConn, nil
+		}
+		return tls.Client(conn, tlsConfig), nil
+	}
+	return conn, nil
+}
+
+// handshake sends the metadata of an outbound stream, and waits for the plugin to accept it.
+func handshake(conn net.Conn, metadata *Metadata) error {
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	if err := WriteMetadata(conn, metadata); err != nil {
+		return err
+	}
+	answer, err := ReadMetadata(conn)
+	if err != nil {
+		return err
+	}
+	if answer.Error != "" {
+		return newError(answer.Error)
+	}
+	return nil
+}
+
+func init() {
+	common.Must(internet.RegisterTransportDialer(protocolName, Dial))
+}
@@ -0,0 +1,98 @@
+package plugin
+
+import (
+	"context"
+	gotls "crypto/tls"
+	"strings"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet"
+	"github.com/xtls/xray-core/transport/internet/stat"
+	"github.com/xtls/xray-core/transport/internet/tls"
+)
+
+type Listener struct {
+	ln        net.Listener
+	tlsConfig *gotls.Config
+	addConn   internet.ConnHandler
+}
+
+// Listen listens on the plugin address, instead of the address of the inbound.
+func Listen(ctx context.Context, address net.Address, port net.Port, streamSettings *internet.MemoryStreamConfig, handler internet.ConnHandler) (internet.Listener, error) {
+	settings := streamSettings.ProtocolSettings.(*Config)
+	network, err := settings.network()
+	if err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen(network, settings.Address)
+	if err != nil {
+		return nil, newError("failed to listen for plugin on ", settings.Address).Base(err).AtWarning()
+	}
+	newError("listening for plugin on ", settings.Address).WriteToLog()
+
+	listener := &Listener{
+		ln:      ln,
+		addConn: handler,
+	}
+	if config := tls.ConfigFromStreamSettings(streamSettings); config != nil {
+		listener.tlsConfig = config.GetTLSConfig()
+	}
+
+	go listener.run()
+
+	return listener, nil
+}
+
+func (l *Listener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+func (l *Listener) Close() error {
+	return l.ln.Close()
+}
+
+func (l *Listener) run() {
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			if strings.Contains(err.Error(), "closed") {
+				break
+			}
+			newError("failed to accept plugin connection").Base(err).AtWarning().WriteToLog()
+			continue
+		}
+		go l.handle(conn)
+	}
+}
+
+func (l *Listener) handle(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	metadata, err := ReadMetadata(conn)
+	if err == nil {
+		err = WriteMetadata(conn, &Metadata{})
+	}
+	if err != nil {
+		newError("failed to read plugin metadata").Base(err).AtInfo().WriteToLog()
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	if metadata.Source != "" {
+		// anyone who can reach the plugin address could claim any source, so it is not used for routing
+		newError("plugin stream from ", conn.RemoteAddr(), " claims source ", metadata.Source).AtDebug().WriteToLog()
+	}
+
+	stream := conn
+	if l.tlsConfig != nil {
+		stream = tls.Server(stream, l.tlsConfig)
+	}
+	l.addConn(stat.Connection(stream))
+}
+
+func init() {
+	common.Must(internet.RegisterTransportListener(protocolName, Listen))
+}
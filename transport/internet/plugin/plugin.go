@@ -0,0 +1,70 @@
+// Package plugin implements a transport that hands streams to an external process, so that
+// experimental transports can be developed out of tree.
+//
+// Every stream is a connection to the plugin address. The side that opens it first sends a header,
+// which is a 2-byte big-endian length followed by a JSON object of Metadata. The other side answers
+// with a header of the same form, whose error is empty if it accepts the stream. What follows are the
+// bytes of the stream.
+//
+// For outbounds, Xray connects to the plugin with the destination, the user and the settings, and the
+// plugin carries the stream to its counterpart on the server. There, the plugin connects to the
+// inbound, which sees the address of the plugin as the source of the stream.
+package plugin
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// handshakeTimeout limits how long the exchange of headers may take.
+const handshakeTimeout = 10 * time.Second
+
+// Metadata describes a stream.
+type Metadata struct {
+	// Destination of an outbound stream, such as tcp:example.com:443.
+	Destination string `json:"destination,omitempty"`
+	// Source of an inbound stream, the address of the client as host:port. It is only logged: the
+	// inbound takes the address the plugin connected from.
+	Source string `json:"source,omitempty"`
+	// Email of the user of an outbound stream, if known.
+	User string `json:"user,omitempty"`
+	// Settings of the transport, passed as configured.
+	Settings string `json:"settings,omitempty"`
+	// Error in an answer that rejects the stream.
+	Error string `json:"error,omitempty"`
+}
+
+// WriteMetadata writes the header of m to w.
+func WriteMetadata(w io.Writer, m *Metadata) error {
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if len(payload) > 0xffff {
+		return newError("metadata too large: ", len(payload))
+	}
+	b := make([]byte, 2+len(payload))
+	binary.BigEndian.PutUint16(b, uint16(len(payload)))
+	copy(b[2:], payload)
+	_, err = w.Write(b)
+	return err
+}
+
+// ReadMetadata reads a header from r.
+func ReadMetadata(r io.Reader) (*Metadata, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	m := new(Metadata)
+	if err := json.Unmarshal(payload, m); err != nil {
+		return nil, newError("invalid metadata").Base(err)
+	}
+	return m, nil
+}
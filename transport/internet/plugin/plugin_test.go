@@ -0,0 +1,127 @@
+package plugin_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/testing/servers/tcp"
+	"github.com/xtls/xray-core/transport/internet"
+	. "github.com/xtls/xray-core/transport/internet/plugin"
+	"github.com/xtls/xray-core/transport/internet/stat"
+)
+
+// runPlugin accepts streams from Xray and relays them to the inbound at server, as the client and the
+// server halves of a plugin would do together.
+func runPlugin(t *testing.T, ln net.Listener, server string, received chan<- *Metadata) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			metadata, err := ReadMetadata(conn)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			received <- metadata
+			if metadata.Settings == "reject" {
+				common.Must(WriteMetadata(conn, &Metadata{Error: "rejected"}))
+				return
+			}
+			common.Must(WriteMetadata(conn, &Metadata{}))
+
+			upstream, err := net.Dial("tcp", server)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer upstream.Close()
+			common.Must(WriteMetadata(upstream, &Metadata{Source: "192.0.2.1:1234"}))
+			if _, err := ReadMetadata(upstream); err != nil {
+				t.Error(err)
+				return
+			}
+			go io.Copy(upstream, conn)
+			io.Copy(conn, upstream)
+		}()
+	}
+}
+
+func TestPlugin(t *testing.T) {
+	ctx := context.Background()
+	serverPort := tcp.PickPort()
+	serverSettings := &internet.MemoryStreamConfig{
+		ProtocolName: "plugin",
+		ProtocolSettings: &Config{
+			Address: net.LocalHostIP.String() + ":" + serverPort.String(),
+		},
+	}
+	remote := make(chan net.Addr, 1)
+	listener, err := Listen(ctx, nil, net.Port(0), serverSettings, func(conn stat.Connection) {
+		defer conn.Close()
+		remote <- conn.RemoteAddr()
+
+		b := buf.New()
+		defer b.Release()
+		common.Must2(b.ReadFrom(conn))
+		b.WriteString("Response")
+		common.Must2(conn.Write(b.Bytes()))
+	})
+	common.Must(err)
+	defer listener.Close()
+
+	pluginListener, err := net.Listen("tcp", "127.0.0.1:0")
+	common.Must(err)
+	defer pluginListener.Close()
+	received := make(chan *Metadata, 2)
+	go runPlugin(t, pluginListener, listener.Addr().String(), received)
+
+	clientSettings := &internet.MemoryStreamConfig{
+		ProtocolName: "plugin",
+		ProtocolSettings: &Config{
+			Address:  pluginListener.Addr().String(),
+			Settings: "mode=test",
+		},
+	}
+	ctx = session.ContextWithInbound(ctx, &session.Inbound{
+		User: &protocol.MemoryUser{Email: "love@example.com"},
+	})
+	dest := net.TCPDestination(net.DomainAddress("example.com"), 443)
+	conn, err := Dial(ctx, dest, clientSettings)
+	common.Must(err)
+	defer conn.Close()
+
+	metadata := <-received
+	if metadata.Destination != "tcp:example.com:443" || metadata.User != "love@example.com" || metadata.Settings != "mode=test" {
+		t.Error("unexpected metadata: ", metadata)
+	}
+
+	common.Must2(conn.Write([]byte("Request")))
+	common.Must(conn.(interface{ CloseWrite() error }).CloseWrite())
+
+	b := buf.New()
+	defer b.Release()
+	common.Must2(b.ReadFrom(conn))
+	if b.String() != "RequestResponse" {
+		t.Error("expected response as 'RequestResponse' but got ", b.String())
+	}
+	if addr := <-remote; !addr.(*net.TCPAddr).IP.IsLoopback() {
+		t.Error("expected the address of the plugin as remote address, but got ", addr)
+	}
+
+	clientSettings.ProtocolSettings = &Config{
+		Address:  pluginListener.Addr().String(),
+		Settings: "reject",
+	}
+	if _, err := Dial(ctx, dest, clientSettings); err == nil {
+		t.Error("expected rejected stream to fail")
+	}
+}
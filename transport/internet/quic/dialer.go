@@ -2,13 +2,10 @@ package quic
 
 import (
 	"context"
-	"io"
 	"sync"
 	"time"
 
 	"github.com/quic-go/quic-go"
-	"github.com/quic-go/quic-go/logging"
-	"github.com/quic-go/quic-go/qlog"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/task"
@@ -104,7 +101,7 @@ func (s *clientConnections) cleanConnections() error {
 	return nil
 }
 
-func (s *clientConnections) openConnection(ctx context.Context, destAddr net.Addr, config *Config, tlsConfig *tls.Config, sockopt *internet.SocketConfig) (stat.Connection, error) {
+func (s *clientConnections) openConnection(ctx context.Context, destAddr net.Addr, config *Config, tlsConfig *tls.Config, sockopt *internet.SocketConfig, events *internet.EventRecorder) (stat.Connection, error) {
 	s.access.Lock()
 	defer s.access.Unlock()
 
@@ -144,10 +141,10 @@ func (s *clientConnections) openConnection(ctx context.Context, destAddr net.Add
 		KeepAlivePeriod:      0,
 		HandshakeIdleTimeout: time.Second * 8,
 		MaxIdleTimeout:       time.Second * 300,
-		Tracer: qlog.NewTracer(func(_ logging.Perspective, connID []byte) io.WriteCloser {
-			return &QlogWriter{connID: connID}
-		}),
+		Tracer:               newTracer(events),
 	}
+	// packets stay at the conservative initial size, instead of probing beyond the limit
+	quicConfig.DisablePathMTUDiscovery = internet.UDPMaxPayload(sockopt) > 0
 
 	udpConn, _ := rawConn.(*net.UDPConn)
 	if udpConn == nil {
@@ -209,7 +206,7 @@ func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.Me
 
 	config := streamSettings.ProtocolSettings.(*Config)
 
-	return client.openConnection(ctx, destAddr, config, tlsConfig, streamSettings.SocketSettings)
+	return client.openConnection(ctx, destAddr, config, tlsConfig, streamSettings.SocketSettings, streamSettings.Events)
 }
 
 func init() {
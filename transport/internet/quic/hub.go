@@ -2,12 +2,9 @@ package quic
 
 import (
 	"context"
-	"io"
 	"time"
 
 	"github.com/quic-go/quic-go"
-	"github.com/quic-go/quic-go/logging"
-	"github.com/quic-go/quic-go/qlog"
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol/tls/cert"
@@ -110,10 +107,9 @@ func Listen(ctx context.Context, address net.Address, port net.Port, streamSetti
 		MaxIdleTimeout:        time.Second * 300,
 		MaxIncomingStreams:    32,
 		MaxIncomingUniStreams: -1,
-		Tracer: qlog.NewTracer(func(_ logging.Perspective, connID []byte) io.WriteCloser {
-			return &QlogWriter{connID: connID}
-		}),
+		Tracer:                newTracer(streamSettings.Events),
 	}
+	quicConfig.DisablePathMTUDiscovery = internet.UDPMaxPayload(streamSettings.SocketSettings) > 0
 
 	conn, err := wrapSysConn(rawConn.(*net.UDPConn), config)
 	if err != nil {
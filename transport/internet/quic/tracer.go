@@ -0,0 +1,48 @@
+package quic
+
+import (
+	"context"
+	"io"
+
+	"github.com/quic-go/quic-go/logging"
+	"github.com/quic-go/quic-go/qlog"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+// newTracer returns the tracer logging QUIC connections as qlog, which also counts Retry packets
+// in events if they are to be counted.
+func newTracer(events *internet.EventRecorder) logging.Tracer {
+	tracer := qlog.NewTracer(func(_ logging.Perspective, connID []byte) io.WriteCloser {
+		return &QlogWriter{connID: connID}
+	})
+	if events == nil {
+		return tracer
+	}
+	return logging.NewMultiplexedTracer(tracer, retryTracer{events: events})
+}
+
+// retryTracer counts the Retry packets sent by a listener, and received by the connections of a dialer.
+type retryTracer struct {
+	logging.NullTracer
+	events *internet.EventRecorder
+}
+
+func (t retryTracer) TracerForConnection(context.Context, logging.Perspective, logging.ConnectionID) logging.ConnectionTracer {
+	return retryConnectionTracer{events: t.events}
+}
+
+func (t retryTracer) SentPacket(_ net.Addr, hdr *logging.Header, _ logging.ByteCount, _ []logging.Frame) {
+	if logging.PacketTypeFromHeader(hdr) == logging.PacketTypeRetry {
+		t.events.Record(internet.EventQUICRetry, 1)
+	}
+}
+
+type retryConnectionTracer struct {
+	logging.NullConnectionTracer
+	events *internet.EventRecorder
+}
+
+func (t retryConnectionTracer) ReceivedRetry(*logging.Header) {
+	t.events.Record(internet.EventQUICRetry, 1)
+}
@@ -0,0 +1,103 @@
+package internet
+
+import (
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+// dedicated resolve caches, keyed by *SocketConfig
+var resolveCaches sync.Map
+
+// resolveCache keeps the addresses resolved for the dialing of a SocketConfig. Concurrent lookups of
+// the same domain wait for one another, and failed lookups are not kept.
+type resolveCache struct {
+	ttl time.Duration
+
+	access  sync.Mutex
+	entries map[resolveKey]*resolveEntry
+}
+
+type resolveKey struct {
+	domain   string
+	strategy DomainStrategy
+	gateway  net.Address
+}
+
+type resolveEntry struct {
+	// done is closed once the lookup completes, after which the other fields are not changed
+	done   chan struct{}
+	ips    []net.IP
+	err    error
+	expire time.Time
+}
+
+func (e *resolveEntry) expired(now time.Time) bool {
+	select {
+	case <-e.done:
+		return now.After(e.expire)
+	default:
+		return false
+	}
+}
+
+// getResolveCache returns the resolve cache of the config, or nil if it has none.
+func (c *SocketConfig) getResolveCache() *resolveCache {
+	if c == nil || c.ResolveCacheTtl == 0 {
+		return nil
+	}
+	if cache, found := resolveCaches.Load(c); found {
+		return cache.(*resolveCache)
+	}
+	cache, _ := resolveCaches.LoadOrStore(c, &resolveCache{
+		ttl:     time.Duration(c.ResolveCacheTtl) * time.Second,
+		entries: make(map[resolveKey]*resolveEntry),
+	})
+	return cache.(*resolveCache)
+}
+
+// ReleaseResolveCache drops the resolve cache of the config, once the handler dialing with it is closed.
+// Dials afterwards start a new cache.
+func (c *SocketConfig) ReleaseResolveCache() {
+	if c != nil {
+		resolveCaches.Delete(c)
+	}
+}
+
+// lookupIP is lookupIP through the cache. A nil cache looks up every time.
+func (c *resolveCache) lookupIP(domain string, strategy DomainStrategy, localAddr net.Address) ([]net.IP, error) {
+	if c == nil {
+		return lookupIP(domain, strategy, localAddr)
+	}
+
+	key := resolveKey{domain: domain, strategy: strategy, gateway: localAddr}
+	now := time.Now()
+	c.access.Lock()
+	entry := c.entries[key]
+	if entry != nil && !entry.expired(now) {
+		c.access.Unlock()
+		<-entry.done
+		return entry.ips, entry.err
+	}
+	for k, e := range c.entries {
+		if e.expired(now) {
+			delete(c.entries, k)
+		}
+	}
+	entry = &resolveEntry{done: make(chan struct{})}
+	c.entries[key] = entry
+	c.access.Unlock()
+
+	entry.ips, entry.err = lookupIP(domain, strategy, localAddr)
+	entry.expire = time.Now().Add(c.ttl)
+	if entry.err != nil || len(entry.ips) == 0 {
+		c.access.Lock()
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+		}
+		c.access.Unlock()
+	}
+	close(entry.done)
+	return entry.ips, entry.err
+}
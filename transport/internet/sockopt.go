@@ -1,5 +1,60 @@
 package internet
 
+import (
+	"sync"
+
+	"github.com/xtls/xray-core/common/platform"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	globalSocketOnce sync.Once
+	globalSocket     *SocketConfig
+)
+
+// globalSocketConfig returns the socket options that apply to all connections unless set in their own
+// sockopt, or nil if there is none. They are read from the environment once.
+func globalSocketConfig() *SocketConfig {
+	globalSocketOnce.Do(func() {
+		config := &SocketConfig{
+			TcpMaxSeg:     int32(platform.NewEnvFlag("xray.sockopt.tcp.maxseg").GetValueAsInt(0)),
+			UdpMaxPayload: int32(platform.NewEnvFlag("xray.sockopt.udp.maxpayload").GetValueAsInt(0)),
+		}
+		if config.TcpMaxSeg > 0 || config.UdpMaxPayload > 0 {
+			globalSocket = config
+		}
+	})
+	return globalSocket
+}
+
+// effectiveSocketConfig fills the options of config that are not set with the global ones.
+func effectiveSocketConfig(config *SocketConfig) *SocketConfig {
+	global := globalSocketConfig()
+	if global == nil {
+		return config
+	}
+	if config == nil {
+		return global
+	}
+	if (config.TcpMaxSeg > 0 || global.TcpMaxSeg == 0) && (config.UdpMaxPayload > 0 || global.UdpMaxPayload == 0) {
+		return config
+	}
+	config = proto.Clone(config).(*SocketConfig)
+	if config.TcpMaxSeg == 0 {
+		config.TcpMaxSeg = global.TcpMaxSeg
+	}
+	if config.UdpMaxPayload == 0 {
+		config.UdpMaxPayload = global.UdpMaxPayload
+	}
+	return config
+}
+
+// UDPMaxPayload returns the largest UDP payload that transports may send with the given options,
+// or 0 if there is no limit.
+func UDPMaxPayload(config *SocketConfig) int32 {
+	return effectiveSocketConfig(config).GetUdpMaxPayload()
+}
+
 func isTCPSocket(network string) bool {
 	switch network {
 	case "tcp", "tcp4", "tcp6":
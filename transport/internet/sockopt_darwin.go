@@ -96,6 +96,18 @@ func OriginalDst(la, ra net.Addr) (net.IP, int, error) {
 }
 
 func applyOutboundSocketOptions(network string, address string, fd uintptr, config *SocketConfig) error {
+	if config.Dscp != 0 {
+		if err := setDSCP(network, fd, config.Dscp); err != nil {
+			return err
+		}
+	}
+
+	if config.Ipv6TrafficClass != 0 {
+		if err := setTrafficClass(network, fd, config.Ipv6TrafficClass); err != nil {
+			return err
+		}
+	}
+
 	if isTCPSocket(network) {
 		tfo := config.ParseTFOValue()
 		if tfo > 0 {
@@ -132,6 +144,12 @@ func applyOutboundSocketOptions(network string, address string, fd uintptr, conf
 }
 
 func applyInboundSocketOptions(network string, fd uintptr, config *SocketConfig) error {
+	if config.Dscp != 0 {
+		if err := setDSCP(network, fd, config.Dscp); err != nil {
+			return err
+		}
+	}
+
 	if isTCPSocket(network) {
 		tfo := config.ParseTFOValue()
 		if tfo > 0 {
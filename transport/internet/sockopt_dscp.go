@@ -0,0 +1,39 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package internet
+
+import (
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// setDSCP marks the packets of the socket with the DSCP value. Dual-stack sockets get both the IPv4 and the
+// IPv6 marking.
+func setDSCP(network string, fd uintptr, dscp int32) error {
+	tos := int(dscp) << 2
+	err4 := unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, tos)
+	if strings.HasSuffix(network, "4") {
+		if err4 != nil {
+			return newError("failed to set IP_TOS").Base(err4)
+		}
+		return nil
+	}
+	err6 := unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TCLASS, tos)
+	if err4 != nil && err6 != nil {
+		return newError("failed to set IPV6_TCLASS").Base(err6)
+	}
+	return nil
+}
+
+// setTrafficClass sets the traffic class of the packets of IPv6 and dual-stack sockets.
+func setTrafficClass(network string, fd uintptr, tclass int32) error {
+	if strings.HasSuffix(network, "4") {
+		return nil
+	}
+	if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TCLASS, int(tclass)); err != nil {
+		return newError("failed to set IPV6_TCLASS").Base(err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package internet
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const flowLabelSupported = true
+
+// From linux/in6.h.
+const (
+	ipv6FlowLabelMgr      = 32
+	ipv6FlowInfoSend      = 33
+	ipv6FlowLabelGet      = 0
+	ipv6FlowLabelShareAny = 255
+	ipv6FlowLabelCreate   = 1
+)
+
+// in6FlowLabelReq is struct in6_flowlabel_req.
+type in6FlowLabelReq struct {
+	dst     [16]byte
+	label   [4]byte
+	action  uint8
+	share   uint8
+	flags   uint16
+	expires uint16
+	linger  uint16
+	_       uint32
+}
+
+// connectWithFlowLabel connects fd to address with the IPv6 flow label. The label of a TCP connection is the
+// one of the address it is connected to, which the dialer has no way to set, so the connection is started here
+// and left in progress for the dialer. The dialer can't bind the source address after that, so src, if any, is
+// bound here first.
+func connectWithFlowLabel(network string, address string, fd uintptr, label uint32, src net.Addr) error {
+	if src, ok := src.(*net.TCPAddr); ok {
+		ip := src.IP.To16()
+		if network == "tcp4" {
+			ip = src.IP.To4()
+		}
+		if err := bindAddr(fd, ip, uint32(src.Port)); err != nil {
+			return newError("failed to bind source address ", src).Base(err)
+		}
+	}
+	if network != "tcp6" {
+		return nil
+	}
+	dest, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return newError("failed to parse address ", address).Base(err)
+	}
+
+	// the label is leased by every connection using it, and released with the last one
+	req := in6FlowLabelReq{
+		dst:    dest.Addr().As16(),
+		action: ipv6FlowLabelGet,
+		share:  ipv6FlowLabelShareAny,
+		flags:  ipv6FlowLabelCreate,
+	}
+	binary.BigEndian.PutUint32(req.label[:], label)
+	b := (*[unsafe.Sizeof(req)]byte)(unsafe.Pointer(&req))[:]
+	if err := unix.SetsockoptString(int(fd), unix.IPPROTO_IPV6, ipv6FlowLabelMgr, string(b)); err != nil {
+		return newError("failed to lease flow label ", label).Base(err)
+	}
+	if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, ipv6FlowInfoSend, 1); err != nil {
+		return newError("failed to set IPV6_FLOWINFO_SEND").Base(err)
+	}
+
+	sa := unix.RawSockaddrInet6{
+		Family: unix.AF_INET6,
+		Addr:   dest.Addr().As16(),
+	}
+	binary.BigEndian.PutUint16((*[2]byte)(unsafe.Pointer(&sa.Port))[:], dest.Port())
+	binary.BigEndian.PutUint32((*[4]byte)(unsafe.Pointer(&sa.Flowinfo))[:], label)
+	if zone := dest.Addr().Zone(); zone != "" {
+		iface, err := net.InterfaceByName(zone)
+		if err != nil {
+			return newError("failed to find interface ", zone).Base(err)
+		}
+		sa.Scope_id = uint32(iface.Index)
+	}
+	_, _, errno := unix.Syscall(unix.SYS_CONNECT, fd, uintptr(unsafe.Pointer(&sa)), unix.SizeofSockaddrInet6)
+	if errno != 0 && errno != unix.EINPROGRESS {
+		return newError("failed to connect to ", address).Base(errno)
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package internet
+
+import (
+	"net"
+)
+
+const flowLabelSupported = false
+
+func connectWithFlowLabel(network string, address string, fd uintptr, label uint32, src net.Addr) error {
+	return newError("IPv6 flow label is not supported on this platform")
+}
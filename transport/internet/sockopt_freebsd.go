@@ -124,6 +124,18 @@ func OriginalDst(la, ra net.Addr) (net.IP, int, error) {
 }
 
 func applyOutboundSocketOptions(network string, address string, fd uintptr, config *SocketConfig) error {
+	if config.Dscp != 0 {
+		if err := setDSCP(network, fd, config.Dscp); err != nil {
+			return err
+		}
+	}
+
+	if config.Ipv6TrafficClass != 0 {
+		if err := setTrafficClass(network, fd, config.Ipv6TrafficClass); err != nil {
+			return err
+		}
+	}
+
 	if config.Mark != 0 {
 		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_USER_COOKIE, int(config.Mark)); err != nil {
 			return newError("failed to set SO_USER_COOKIE").Base(err)
@@ -177,6 +189,12 @@ func applyOutboundSocketOptions(network string, address string, fd uintptr, conf
 }
 
 func applyInboundSocketOptions(network string, fd uintptr, config *SocketConfig) error {
+	if config.Dscp != 0 {
+		if err := setDSCP(network, fd, config.Dscp); err != nil {
+			return err
+		}
+	}
+
 	if config.Mark != 0 {
 		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_USER_COOKIE, int(config.Mark)); err != nil {
 			return newError("failed to set SO_USER_COOKIE").Base(err)
@@ -41,6 +41,18 @@ func bindAddr(fd uintptr, ip []byte, port uint32) error {
 }
 
 func applyOutboundSocketOptions(network string, address string, fd uintptr, config *SocketConfig) error {
+	if config.Dscp != 0 {
+		if err := setDSCP(network, fd, config.Dscp); err != nil {
+			return err
+		}
+	}
+
+	if config.Ipv6TrafficClass != 0 {
+		if err := setTrafficClass(network, fd, config.Ipv6TrafficClass); err != nil {
+			return err
+		}
+	}
+
 	if config.Mark != 0 {
 		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_MARK, int(config.Mark)); err != nil {
 			return newError("failed to set SO_MARK").Base(err)
@@ -89,6 +101,12 @@ func applyOutboundSocketOptions(network string, address string, fd uintptr, conf
 				return newError("failed to set TCP_CONGESTION", err)
 			}
 		}
+
+		if config.TcpMaxSeg > 0 {
+			if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_MAXSEG, int(config.TcpMaxSeg)); err != nil {
+				return newError("failed to set TCP_MAXSEG", err)
+			}
+		}
 	}
 
 	if config.Tproxy.IsEnabled() {
@@ -101,6 +119,12 @@ func applyOutboundSocketOptions(network string, address string, fd uintptr, conf
 }
 
 func applyInboundSocketOptions(network string, fd uintptr, config *SocketConfig) error {
+	if config.Dscp != 0 {
+		if err := setDSCP(network, fd, config.Dscp); err != nil {
+			return err
+		}
+	}
+
 	if config.Mark != 0 {
 		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_MARK, int(config.Mark)); err != nil {
 			return newError("failed to set SO_MARK").Base(err)
@@ -139,6 +163,12 @@ func applyInboundSocketOptions(network string, fd uintptr, config *SocketConfig)
 				return newError("failed to set TCP_CONGESTION", err)
 			}
 		}
+
+		if config.TcpMaxSeg > 0 {
+			if err := syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_MAXSEG, int(config.TcpMaxSeg)); err != nil {
+				return newError("failed to set TCP_MAXSEG", err)
+			}
+		}
 	}
 
 	if config.Tproxy.IsEnabled() {
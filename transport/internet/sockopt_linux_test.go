@@ -2,11 +2,15 @@ package internet_test
 
 import (
 	"context"
+	"encoding/binary"
 	"syscall"
 	"testing"
+	"unsafe"
 
 	"github.com/xtls/xray-core/common"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/features/routing"
 	"github.com/xtls/xray-core/testing/servers/tcp"
 	. "github.com/xtls/xray-core/transport/internet"
 )
@@ -40,3 +44,136 @@ func TestSockOptMark(t *testing.T) {
 	})
 	common.Must(err)
 }
+
+func TestSockOptTCPMaxSeg(t *testing.T) {
+	tcpServer := tcp.Server{
+		MsgProcessor: func(b []byte) []byte {
+			return b
+		},
+	}
+	dest, err := tcpServer.Start()
+	common.Must(err)
+	defer tcpServer.Close()
+
+	const maxSeg = 1000
+	dialer := DefaultSystemDialer{}
+	conn, err := dialer.Dial(context.Background(), nil, dest, &SocketConfig{TcpMaxSeg: maxSeg})
+	common.Must(err)
+	defer conn.Close()
+
+	rawConn, err := conn.(*net.TCPConn).SyscallConn()
+	common.Must(err)
+	err = rawConn.Control(func(fd uintptr) {
+		m, err := syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_MAXSEG)
+		common.Must(err)
+		if m > maxSeg {
+			t.Fatal("unexpected maximum segment size ", m, " want at most ", maxSeg)
+		}
+	})
+	common.Must(err)
+}
+
+func TestSockOptDSCP(t *testing.T) {
+	tcpServer := tcp.Server{
+		MsgProcessor: func(b []byte) []byte {
+			return b
+		},
+	}
+	dest, err := tcpServer.Start()
+	common.Must(err)
+	defer tcpServer.Close()
+
+	// the DSCP of the routing rule overrides the one of the outbound
+	const dscp = 46
+	ctx := session.ContextWithSockoptOverride(context.Background(), &routing.SockoptOverride{DSCP: dscp})
+	conn, err := DialSystem(ctx, dest, &SocketConfig{Dscp: 8})
+	common.Must(err)
+	defer conn.Close()
+
+	rawConn, err := conn.(*net.TCPConn).SyscallConn()
+	common.Must(err)
+	err = rawConn.Control(func(fd uintptr) {
+		tos, err := syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS)
+		common.Must(err)
+		if tos>>2 != dscp {
+			t.Fatal("unexpected DSCP ", tos>>2, " want ", dscp)
+		}
+	})
+	common.Must(err)
+}
+
+func TestSockOptIPv6FlowLabel(t *testing.T) {
+	tcpServer := tcp.Server{
+		Listen: net.ParseAddress("::1"),
+		MsgProcessor: func(b []byte) []byte {
+			return b
+		},
+	}
+	dest, err := tcpServer.Start()
+	if err != nil {
+		t.Skip("IPv6 is not available: ", err)
+	}
+	defer tcpServer.Close()
+
+	const label = 0x12345
+	const tclass = 0xb8
+	ctx := session.ContextWithOutbound(context.Background(), &session.Outbound{Gateway: net.ParseAddress("::1")})
+	conn, err := DialSystem(ctx, dest, &SocketConfig{Ipv6FlowLabel: label, Ipv6TrafficClass: tclass})
+	common.Must(err)
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("test")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 4)
+	if _, err := conn.Read(b); err != nil || string(b) != "test" {
+		t.Fatal("unexpected echo ", string(b), err)
+	}
+	if ip := conn.LocalAddr().(*net.TCPAddr).IP; !ip.Equal(net.ParseIP("::1")) {
+		t.Error("unexpected source address ", ip)
+	}
+
+	rawConn, err := conn.(*net.TCPConn).SyscallConn()
+	common.Must(err)
+	err = rawConn.Control(func(fd uintptr) {
+		// struct in6_flowlabel_req, with the label of the socket read into bytes 16 to 20
+		req := make([]byte, 32)
+		l := uint32(len(req))
+		_, _, errno := syscall.Syscall6(syscall.SYS_GETSOCKOPT, fd, syscall.IPPROTO_IPV6, 32, uintptr(unsafe.Pointer(&req[0])), uintptr(unsafe.Pointer(&l)), 0)
+		if errno != 0 {
+			t.Fatal("failed to get flow label: ", errno)
+		}
+		if got := binary.BigEndian.Uint32(req[16:20]); got != label {
+			t.Errorf("unexpected flow label %#x want %#x", got, label)
+		}
+
+		tc, err := syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS)
+		common.Must(err)
+		if tc != tclass {
+			t.Error("unexpected traffic class ", tc, " want ", tclass)
+		}
+	})
+	common.Must(err)
+}
+
+func TestSockOptIPv6FlowLabelBindFailure(t *testing.T) {
+	tcpServer := tcp.Server{
+		Listen: net.ParseAddress("::1"),
+		MsgProcessor: func(b []byte) []byte {
+			return b
+		},
+	}
+	dest, err := tcpServer.Start()
+	if err != nil {
+		t.Skip("IPv6 is not available: ", err)
+	}
+	defer tcpServer.Close()
+
+	// an address not on the host, which the dial must not silently replace
+	ctx := session.ContextWithOutbound(context.Background(), &session.Outbound{Gateway: net.ParseAddress("2001:db8::1")})
+	conn, err := DialSystem(ctx, dest, &SocketConfig{Ipv6FlowLabel: 0x12345})
+	if err == nil {
+		conn.Close()
+		t.Fatal("expected the dial to fail, but it is from ", conn.LocalAddr())
+	}
+}
@@ -1,6 +1,7 @@
 package stat
 
 import (
+	"errors"
 	"net"
 
 	"github.com/xtls/xray-core/features/stats"
@@ -10,6 +11,39 @@ type Connection interface {
 	net.Conn
 }
 
+// HalfCloser is a connection that can shut down its writing side only, e.g. TCP and TLS.
+type HalfCloser interface {
+	CloseWrite() error
+}
+
+// ErrHalfCloseUnsupported is returned by CloseWrite if the connection can't be half-closed.
+var ErrHalfCloseUnsupported = errors.New("half-close is not supported")
+
+// CloseWrite sends EOF to the peer of conn while keeping the reading side open.
+func CloseWrite(conn net.Conn) error {
+	if c, ok := conn.(HalfCloser); ok {
+		return c.CloseWrite()
+	}
+	return ErrHalfCloseUnsupported
+}
+
+// Wrapper is a Connection that shapes the writes of another one. Proxies unwrap it to reach the
+// connection of the transport under it, such as for XTLS.
+type Wrapper interface {
+	Unwrap() Connection
+}
+
+// Unwrap returns the connection under the Wrappers around conn, which may be a CounterConnection.
+func Unwrap(conn Connection) Connection {
+	for {
+		w, ok := conn.(Wrapper)
+		if !ok {
+			return conn
+		}
+		conn = w.Unwrap()
+	}
+}
+
 type CounterConnection struct {
 	Connection
 	ReadCounter  stats.Counter
@@ -32,3 +66,7 @@ func (c *CounterConnection) Write(b []byte) (int, error) {
 	}
 	return nBytes, err
 }
+
+func (c *CounterConnection) CloseWrite() error {
+	return CloseWrite(c.Connection)
+}
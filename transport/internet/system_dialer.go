@@ -47,6 +47,7 @@ func hasBindAddr(sockopt *SocketConfig) bool {
 
 func (d *DefaultSystemDialer) Dial(ctx context.Context, src net.Address, dest net.Destination, sockopt *SocketConfig) (net.Conn, error) {
 	newError("dialing to " + dest.String()).AtDebug().WriteToLog()
+	sockopt = effectiveSocketConfig(sockopt)
 
 	if dest.Network == net.Network_UDP && !hasBindAddr(sockopt) {
 		srcAddr := resolveSrcAddr(net.Network_UDP, src)
@@ -56,11 +57,17 @@ func (d *DefaultSystemDialer) Dial(ctx context.Context, src net.Address, dest ne
 				Port: 0,
 			}
 		}
-		packetConn, err := ListenSystemPacket(ctx, srcAddr, sockopt)
+		destAddr, err := net.ResolveUDPAddr("udp", dest.NetAddr())
 		if err != nil {
 			return nil, err
 		}
-		destAddr, err := net.ResolveUDPAddr("udp", dest.NetAddr())
+		if sockopt != nil && sockopt.UdpSourcePort == SocketConfig_PerDestination {
+			return &PacketConnWrapper{
+				Conn: newPerDestinationConn(ctx, srcAddr.(*net.UDPAddr), sockopt),
+				Dest: destAddr,
+			}, nil
+		}
+		packetConn, err := ListenSystemPacket(ctx, srcAddr, sockopt)
 		if err != nil {
 			return nil, err
 		}
@@ -79,9 +86,17 @@ func (d *DefaultSystemDialer) Dial(ctx context.Context, src net.Address, dest ne
 		KeepAlive: goStdKeepAlive,
 	}
 
+	var flowLabelSrc net.Addr
+	withFlowLabel := sockopt != nil && sockopt.Ipv6FlowLabel != 0 && dest.Network == net.Network_TCP && flowLabelSupported
+	if withFlowLabel {
+		// the connection is started by the control function, before the dialer would bind the source address
+		flowLabelSrc, dialer.LocalAddr = dialer.LocalAddr, nil
+	}
+
 	if sockopt != nil || len(d.controllers) > 0 {
 		dialer.Control = func(network, address string, c syscall.RawConn) error {
-			return c.Control(func(fd uintptr) {
+			var controlErr error
+			err := c.Control(func(fd uintptr) {
 				if sockopt != nil {
 					if err := applyOutboundSocketOptions(network, address, fd, sockopt); err != nil {
 						newError("failed to apply socket options").Base(err).WriteToLog(session.ExportIDToError(ctx))
@@ -98,7 +113,18 @@ func (d *DefaultSystemDialer) Dial(ctx context.Context, src net.Address, dest ne
 						newError("failed to apply external controller").Base(err).WriteToLog(session.ExportIDToError(ctx))
 					}
 				}
+
+				// unlike the options above, a failure here would dial from another source address, or not dial at all
+				if withFlowLabel {
+					if err := connectWithFlowLabel(network, address, fd, sockopt.Ipv6FlowLabel, flowLabelSrc); err != nil {
+						controlErr = newError("failed to connect with flow label").Base(err)
+					}
+				}
 			})
+			if err != nil {
+				return err
+			}
+			return controlErr
 		}
 	}
 
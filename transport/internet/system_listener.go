@@ -45,6 +45,7 @@ func getControlFunc(ctx context.Context, sockopt *SocketConfig, controllers []co
 func (dl *DefaultListener) Listen(ctx context.Context, addr net.Addr, sockopt *SocketConfig) (l net.Listener, err error) {
 	var lc net.ListenConfig
 	var network, address string
+	sockopt = effectiveSocketConfig(sockopt)
 
 	switch addr := addr.(type) {
 	case *net.TCPAddr:
@@ -108,7 +109,7 @@ func (dl *DefaultListener) Listen(ctx context.Context, addr net.Addr, sockopt *S
 func (dl *DefaultListener) ListenPacket(ctx context.Context, addr net.Addr, sockopt *SocketConfig) (net.PacketConn, error) {
 	var lc net.ListenConfig
 
-	lc.Control = getControlFunc(ctx, sockopt, dl.controllers)
+	lc.Control = getControlFunc(ctx, effectiveSocketConfig(sockopt), dl.controllers)
 
 	return lc.ListenPacket(ctx, addr.Network(), addr.String())
 }
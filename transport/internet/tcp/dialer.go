@@ -26,16 +26,19 @@ func Dial(ctx context.Context, dest net.Destination, streamSettings *internet.Me
 		if fingerprint := tls.GetFingerprint(config.Fingerprint); fingerprint != nil {
 			conn = tls.UClient(conn, tlsConfig, fingerprint)
 			if err := conn.(*tls.UConn).Handshake(); err != nil {
+				streamSettings.Events.Record(internet.EventTLSHandshakeFailure, 1)
 				return nil, err
 			}
 		} else {
 			conn = tls.Client(conn, tlsConfig)
+			conn.(*tls.Conn).RecordEvents(streamSettings.Events)
 		}
 	} else if config := xtls.ConfigFromStreamSettings(streamSettings); config != nil {
 		xtlsConfig := config.GetXTLSConfig(xtls.WithDestination(dest))
 		conn = xtls.Client(conn, xtlsConfig)
 	} else if config := reality.ConfigFromStreamSettings(streamSettings); config != nil {
 		if conn, err = reality.UClient(conn, config, ctx, dest); err != nil {
+			streamSettings.Events.Record(internet.EventTLSHandshakeFailure, 1)
 			return nil, err
 		}
 	}
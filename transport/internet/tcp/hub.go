@@ -28,6 +28,7 @@ type Listener struct {
 	config        *Config
 	addConn       internet.ConnHandler
 	locker        *internet.FileLocker // for unix domain socket
+	events        *internet.EventRecorder
 }
 
 // ListenTCP creates a new Listener based on configurations.
@@ -74,6 +75,7 @@ func ListenTCP(ctx context.Context, address net.Address, port net.Port, streamSe
 	}
 
 	l.listener = listener
+	l.events = streamSettings.Events
 
 	if config := tls.ConfigFromStreamSettings(streamSettings); config != nil {
 		l.tlsConfig = config.GetTLSConfig()
@@ -118,10 +120,12 @@ func (v *Listener) keepAccepting() {
 		go func() {
 			if v.tlsConfig != nil {
 				conn = tls.Server(conn, v.tlsConfig)
+				conn.(*tls.Conn).RecordEvents(v.events)
 			} else if v.xtlsConfig != nil {
 				conn = xtls.Server(conn, v.xtlsConfig)
 			} else if v.realityConfig != nil {
 				if conn, err = reality.Server(conn, v.realityConfig); err != nil {
+					v.events.Record(internet.EventTLSHandshakeFailure, 1)
 					newError(err).AtInfo().WriteToLog()
 					return
 				}
@@ -0,0 +1,188 @@
+package tls
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	utls "github.com/refraction-networking/utls"
+	"github.com/xtls/xray-core/common/dice"
+)
+
+// BehaviorSuffix selects a fingerprint that mimics how the browser acts around its client hello, such as
+// "chrome+behavior", rather than the hello alone.
+const BehaviorSuffix = "+behavior"
+
+// behavior is how a browser acts around its client hello.
+type behavior struct {
+	// grease is whether the browser sends GREASE values in suites, groups, versions and extensions.
+	grease bool
+	// shuffle is whether the browser permutes its extensions on every connection, as Chromium does
+	// since 106.
+	shuffle bool
+	// sessionLifetime is how long the browser resumes a session.
+	sessionLifetime time.Duration
+
+	cache utls.ClientSessionCache
+}
+
+var (
+	behaviorAccess       sync.Mutex
+	behaviorFingerprints = make(map[*utls.ClientHelloID]*utls.ClientHelloID)
+	behaviors            = make(map[*utls.ClientHelloID]*behavior)
+)
+
+// behaviorFingerprint returns the variant of the fingerprint that also mimics the behavior of its browser.
+func behaviorFingerprint(fingerprint *utls.ClientHelloID) *utls.ClientHelloID {
+	behaviorAccess.Lock()
+	defer behaviorAccess.Unlock()
+
+	if variant := behaviorFingerprints[fingerprint]; variant != nil {
+		return variant
+	}
+	variant := new(utls.ClientHelloID)
+	*variant = *fingerprint
+	behaviorFingerprints[fingerprint] = variant
+	behaviors[variant] = newBehavior(fingerprint)
+	return variant
+}
+
+func getBehavior(fingerprint *utls.ClientHelloID) *behavior {
+	behaviorAccess.Lock()
+	defer behaviorAccess.Unlock()
+
+	return behaviors[fingerprint]
+}
+
+func newBehavior(fingerprint *utls.ClientHelloID) *behavior {
+	b := new(behavior)
+	version, _ := strconv.Atoi(strings.SplitN(fingerprint.Version, ".", 2)[0])
+	switch fingerprint.Client {
+	case "Chrome", "Edge", "360Browser", "QQBrowser":
+		// Chromium keeps sessions for an hour
+		b.grease = true
+		b.shuffle = (fingerprint.Client == "Chrome" || fingerprint.Client == "Edge") && version >= 106
+		b.sessionLifetime = time.Hour
+	case "Safari", "iOS":
+		b.grease = true
+		b.sessionLifetime = time.Hour
+	case "Firefox":
+		// NSS keeps client sessions for a day
+		b.sessionLifetime = 24 * time.Hour
+	case "Android":
+		b.sessionLifetime = time.Hour
+	}
+	if b.sessionLifetime > 0 {
+		b.cache = &lifetimeSessionCache{
+			ClientSessionCache: utls.NewLRUClientSessionCache(128),
+			lifetime:           b.sessionLifetime,
+			created:            make(map[string]time.Time),
+		}
+	}
+	return b
+}
+
+// apply changes the spec to behave like the browser on this connection.
+func (b *behavior) apply(spec *utls.ClientHelloSpec) {
+	if b.grease {
+		addGREASE(spec)
+	}
+	if b.shuffle {
+		shuffleExtensions(spec.Extensions)
+	}
+}
+
+// addGREASE adds GREASE values where BoringSSL sends them, unless the spec has them already.
+func addGREASE(spec *utls.ClientHelloSpec) {
+	if len(spec.CipherSuites) == 0 || spec.CipherSuites[0] != utls.GREASE_PLACEHOLDER {
+		spec.CipherSuites = append([]uint16{utls.GREASE_PLACEHOLDER}, spec.CipherSuites...)
+	}
+	hasGREASE := false
+	for _, ext := range spec.Extensions {
+		switch ext := ext.(type) {
+		case *utls.UtlsGREASEExtension:
+			hasGREASE = true
+		case *utls.SupportedCurvesExtension:
+			if len(ext.Curves) == 0 || ext.Curves[0] != utls.GREASE_PLACEHOLDER {
+				ext.Curves = append([]utls.CurveID{utls.GREASE_PLACEHOLDER}, ext.Curves...)
+			}
+		case *utls.KeyShareExtension:
+			if len(ext.KeyShares) == 0 || ext.KeyShares[0].Group != utls.GREASE_PLACEHOLDER {
+				ext.KeyShares = append([]utls.KeyShare{{Group: utls.GREASE_PLACEHOLDER, Data: []byte{0}}}, ext.KeyShares...)
+			}
+		case *utls.SupportedVersionsExtension:
+			if len(ext.Versions) == 0 || ext.Versions[0] != utls.GREASE_PLACEHOLDER {
+				ext.Versions = append([]uint16{utls.GREASE_PLACEHOLDER}, ext.Versions...)
+			}
+		}
+	}
+	if !hasGREASE {
+		// one GREASE extension comes first, and another one last but before padding
+		last := len(spec.Extensions)
+		if last > 0 {
+			if _, ok := spec.Extensions[last-1].(*utls.UtlsPaddingExtension); ok {
+				last--
+			}
+		}
+		extensions := make([]utls.TLSExtension, 0, len(spec.Extensions)+2)
+		extensions = append(extensions, &utls.UtlsGREASEExtension{})
+		extensions = append(extensions, spec.Extensions[:last]...)
+		extensions = append(extensions, &utls.UtlsGREASEExtension{})
+		extensions = append(extensions, spec.Extensions[last:]...)
+		spec.Extensions = extensions
+	}
+}
+
+// shuffleExtensions permutes the extensions as Chromium does, with GREASE and padding staying in place.
+func shuffleExtensions(extensions []utls.TLSExtension) {
+	var movable []int
+	for i, ext := range extensions {
+		switch ext.(type) {
+		case *utls.UtlsGREASEExtension, *utls.UtlsPaddingExtension:
+		default:
+			movable = append(movable, i)
+		}
+	}
+	for i := len(movable) - 1; i > 0; i-- {
+		j := dice.Roll(i + 1)
+		extensions[movable[i]], extensions[movable[j]] = extensions[movable[j]], extensions[movable[i]]
+	}
+}
+
+// lifetimeSessionCache forgets sessions after their lifetime, as browsers do.
+type lifetimeSessionCache struct {
+	utls.ClientSessionCache
+	lifetime time.Duration
+
+	access  sync.Mutex
+	created map[string]time.Time
+}
+
+func (c *lifetimeSessionCache) Get(sessionKey string) (*utls.ClientSessionState, bool) {
+	c.access.Lock()
+	created, found := c.created[sessionKey]
+	if found && time.Since(created) > c.lifetime {
+		delete(c.created, sessionKey)
+		found = false
+	}
+	c.access.Unlock()
+
+	if !found {
+		return nil, false
+	}
+	return c.ClientSessionCache.Get(sessionKey)
+}
+
+func (c *lifetimeSessionCache) Put(sessionKey string, cs *utls.ClientSessionState) {
+	c.access.Lock()
+	if cs == nil {
+		delete(c.created, sessionKey)
+	} else if _, found := c.created[sessionKey]; !found {
+		// a resumed session keeps the time of the full handshake
+		c.created[sessionKey] = time.Now()
+	}
+	c.access.Unlock()
+
+	c.ClientSessionCache.Put(sessionKey, cs)
+}
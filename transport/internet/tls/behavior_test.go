@@ -0,0 +1,55 @@
+package tls_test
+
+import (
+	gotls "crypto/tls"
+	"net"
+	"testing"
+
+	utls "github.com/refraction-networking/utls"
+	"github.com/xtls/xray-core/common"
+	. "github.com/xtls/xray-core/transport/internet/tls"
+)
+
+func TestBehaviorFingerprint(t *testing.T) {
+	fingerprint := GetFingerprint("helloedge_106" + BehaviorSuffix)
+	if fingerprint == nil {
+		t.Fatal("expected fingerprint for helloedge_106+behavior")
+	}
+	if fingerprint == GetFingerprint("helloedge_106") {
+		t.Error("expected behavior fingerprint to differ from the plain one")
+	}
+	if fingerprint != GetFingerprint("helloedge_106"+BehaviorSuffix) {
+		t.Error("expected the same behavior fingerprint every time")
+	}
+	if GetFingerprint("unknown"+BehaviorSuffix) != nil {
+		t.Error("expected no fingerprint for unknown+behavior")
+	}
+
+	orders := make(map[string]bool)
+	for i := 0; i < 8; i++ {
+		c, s := net.Pipe()
+		conn := UClient(c, &gotls.Config{ServerName: "example.com"}, fingerprint).(*UConn)
+		common.Must(conn.BuildHandshakeState())
+		c.Close()
+		s.Close()
+
+		extensions := conn.Extensions
+		if _, ok := extensions[0].(*utls.UtlsGREASEExtension); !ok {
+			t.Fatal("expected GREASE as first extension")
+		}
+		if _, ok := extensions[len(extensions)-1].(*utls.UtlsPaddingExtension); !ok {
+			t.Fatal("expected padding as last extension")
+		}
+		if conn.HandshakeState.Hello.CipherSuites[0]&0x0f0f != utls.GREASE_PLACEHOLDER {
+			t.Error("expected GREASE as first cipher suite")
+		}
+		order := ""
+		for _, ext := range extensions {
+			order += string(rune(ext.Len())) + ","
+		}
+		orders[order] = true
+	}
+	if len(orders) < 2 {
+		t.Error("expected extensions to be shuffled")
+	}
+}
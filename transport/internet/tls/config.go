@@ -10,13 +10,17 @@ import (
 	"time"
 
 	"github.com/xtls/xray-core/common/net"
-	"github.com/xtls/xray-core/common/ocsp"
-	"github.com/xtls/xray-core/common/platform/filesystem"
 	"github.com/xtls/xray-core/common/protocol/tls/cert"
 	"github.com/xtls/xray-core/transport/internet"
 )
 
-var globalSessionCache = tls.NewLRUClientSessionCache(128)
+const globalSessionCacheSize = 128
+
+var (
+	globalSessionCache = tls.NewLRUClientSessionCache(globalSessionCacheSize)
+	// dedicated client session caches, keyed by *Config
+	sessionCaches sync.Map
+)
 
 // ParseCertificate converts a cert.Certificate to Certificate.
 func ParseCertificate(c *cert.Certificate) *Certificate {
@@ -40,77 +44,39 @@ func (c *Config) loadSelfCertPool() (*x509.CertPool, error) {
 	return root, nil
 }
 
-// BuildCertificates builds a list of TLS certificates from proto definition.
+// BuildCertificates builds a list of TLS certificates from proto definition. The certificates are the ones
+// being served at the moment, which change as their files do.
 func (c *Config) BuildCertificates() []*tls.Certificate {
-	certs := make([]*tls.Certificate, 0, len(c.Certificate))
+	pairs := c.buildKeyPairs()
+	certs := make([]*tls.Certificate, 0, len(pairs))
+	for _, p := range pairs {
+		certs = append(certs, p.current.Load())
+	}
+	return certs
+}
+
+func (c *Config) buildKeyPairs() []*keyPair {
+	pairs := make([]*keyPair, 0, len(c.Certificate))
 	for _, entry := range c.Certificate {
 		if entry.Usage != Certificate_ENCIPHERMENT {
 			continue
 		}
-		keyPair, err := tls.X509KeyPair(entry.Certificate, entry.Key)
-		if err != nil {
-			newError("ignoring invalid X509 key pair").Base(err).AtWarning().WriteToLog()
-			continue
-		}
-		keyPair.Leaf, err = x509.ParseCertificate(keyPair.Certificate[0])
+		p, err := getKeyPair(entry)
 		if err != nil {
-			newError("ignoring invalid certificate").Base(err).AtWarning().WriteToLog()
+			newError("ignoring certificate").Base(err).AtWarning().WriteToLog()
 			continue
 		}
-		certs = append(certs, &keyPair)
-		if !entry.OneTimeLoading {
-			var isOcspstapling bool
-			hotReloadCertInterval := uint64(3600)
-			if entry.OcspStapling != 0 {
-				hotReloadCertInterval = entry.OcspStapling
-				isOcspstapling = true
-			}
-			index := len(certs) - 1
-			go func(entry *Certificate, cert *tls.Certificate, index int) {
-				t := time.NewTicker(time.Duration(hotReloadCertInterval) * time.Second)
-				for {
-					if entry.CertificatePath != "" && entry.KeyPath != "" {
-						newCert, err := filesystem.ReadFile(entry.CertificatePath)
-						if err != nil {
-							newError("failed to parse certificate").Base(err).AtError().WriteToLog()
-							<-t.C
-							continue
-						}
-						newKey, err := filesystem.ReadFile(entry.KeyPath)
-						if err != nil {
-							newError("failed to parse key").Base(err).AtError().WriteToLog()
-							<-t.C
-							continue
-						}
-						if string(newCert) != string(entry.Certificate) && string(newKey) != string(entry.Key) {
-							newKeyPair, err := tls.X509KeyPair(newCert, newKey)
-							if err != nil {
-								newError("ignoring invalid X509 key pair").Base(err).AtError().WriteToLog()
-								<-t.C
-								continue
-							}
-							if newKeyPair.Leaf, err = x509.ParseCertificate(newKeyPair.Certificate[0]); err != nil {
-								newError("ignoring invalid certificate").Base(err).AtError().WriteToLog()
-								<-t.C
-								continue
-							}
-							cert = &newKeyPair
-						}
-					}
-					if isOcspstapling {
-						if newOCSPData, err := ocsp.GetOCSPForCert(cert.Certificate); err != nil {
-							newError("ignoring invalid OCSP").Base(err).AtWarning().WriteToLog()
-						} else if string(newOCSPData) != string(cert.OCSPStaple) {
-							cert.OCSPStaple = newOCSPData
-						}
-					}
-					certs[index] = cert
-					<-t.C
-				}
-			}(entry, certs[index], index)
-		}
+		pairs = append(pairs, p)
 	}
-	return certs
+	return pairs
+}
+
+// reportCertError notifies the registered handler that a certificate file failed to reload.
+func reportCertError(path string, err error) {
+	internet.ReportCertError(map[string]string{
+		"path":  path,
+		"error": err.Error(),
+	})
 }
 
 func isCertificateExpired(c *tls.Certificate) bool {
@@ -191,6 +157,10 @@ func getGetCertificateFunc(c *tls.Config, ca []*Certificate) func(hello *tls.Cli
 				newCert, err := issueCertificate(rawCert, domain)
 				if err != nil {
 					newError("failed to issue new certificate for ", domain).Base(err).WriteToLog()
+					internet.ReportCertError(map[string]string{
+						"domain": domain,
+						"error":  err.Error(),
+					})
 					continue
 				}
 				parsed, err := x509.ParseCertificate(newCert.Certificate[0])
@@ -222,20 +192,21 @@ func getGetCertificateFunc(c *tls.Config, ca []*Certificate) func(hello *tls.Cli
 	}
 }
 
-func getNewGetCertificateFunc(certs []*tls.Certificate, rejectUnknownSNI bool) func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+func getNewGetCertificateFunc(pairs []*keyPair, rejectUnknownSNI bool) func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
 	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
-		if len(certs) == 0 {
+		if len(pairs) == 0 {
 			return nil, errNoCertificates
 		}
 		sni := strings.ToLower(hello.ServerName)
-		if !rejectUnknownSNI && (len(certs) == 1 || sni == "") {
-			return certs[0], nil
+		if !rejectUnknownSNI && (len(pairs) == 1 || sni == "") {
+			return pairs[0].current.Load(), nil
 		}
 		gsni := "*"
 		if index := strings.IndexByte(sni, '.'); index != -1 {
 			gsni += sni[index:]
 		}
-		for _, keyPair := range certs {
+		for _, p := range pairs {
+			keyPair := p.current.Load()
 			if keyPair.Leaf.Subject.CommonName == sni || keyPair.Leaf.Subject.CommonName == gsni {
 				return keyPair, nil
 			}
@@ -248,8 +219,102 @@ func getNewGetCertificateFunc(certs []*tls.Certificate, rejectUnknownSNI bool) f
 		if rejectUnknownSNI {
 			return nil, errNoCertificates
 		}
-		return certs[0], nil
+		return pairs[0].current.Load(), nil
+	}
+}
+
+// sessionTicketsEnabled returns whether the server side issues and accepts session tickets.
+func (c *Config) sessionTicketsEnabled() bool {
+	return c.EnableSessionResumption || c.EnableSessionTickets
+}
+
+func (c *Config) getClientSessionCache() tls.ClientSessionCache {
+	if !c.EnableSessionResumption {
+		return nil
+	}
+	if c.SessionCacheSize == 0 && c.SessionCacheTtl == 0 {
+		return globalSessionCache
+	}
+	if cache, found := sessionCaches.Load(c); found {
+		return cache.(tls.ClientSessionCache)
+	}
+	size := int(c.SessionCacheSize)
+	if size == 0 {
+		size = globalSessionCacheSize
+	}
+	var cache tls.ClientSessionCache
+	if c.SessionCacheTtl == 0 {
+		cache = tls.NewLRUClientSessionCache(size)
+	} else {
+		cache = &expiringSessionCache{
+			capacity: size,
+			ttl:      time.Duration(c.SessionCacheTtl) * time.Second,
+			sessions: make(map[string]expiringSession),
+		}
 	}
+	actual, _ := sessionCaches.LoadOrStore(c, cache)
+	return actual.(tls.ClientSessionCache)
+}
+
+// ReleaseSessionCache drops the dedicated session cache of the config, once the handler dialing with it is
+// closed. Dials afterwards start a new cache.
+func (c *Config) ReleaseSessionCache() {
+	if c != nil {
+		sessionCaches.Delete(c)
+	}
+}
+
+// expiringSessionCache is a tls.ClientSessionCache that stops resuming sessions once they are older
+// than ttl. When full, the oldest session makes room for a new one.
+type expiringSessionCache struct {
+	capacity int
+	ttl      time.Duration
+
+	access   sync.Mutex
+	sessions map[string]expiringSession
+}
+
+type expiringSession struct {
+	state *tls.ClientSessionState
+	added time.Time
+}
+
+// Get implements tls.ClientSessionCache.
+func (c *expiringSessionCache) Get(sessionKey string) (*tls.ClientSessionState, bool) {
+	c.access.Lock()
+	defer c.access.Unlock()
+
+	s, found := c.sessions[sessionKey]
+	if !found {
+		return nil, false
+	}
+	if time.Since(s.added) >= c.ttl {
+		delete(c.sessions, sessionKey)
+		return nil, false
+	}
+	return s.state, true
+}
+
+// Put implements tls.ClientSessionCache.
+func (c *expiringSessionCache) Put(sessionKey string, cs *tls.ClientSessionState) {
+	c.access.Lock()
+	defer c.access.Unlock()
+
+	if cs == nil {
+		delete(c.sessions, sessionKey)
+		return
+	}
+	if _, found := c.sessions[sessionKey]; !found && len(c.sessions) >= c.capacity {
+		var oldest string
+		var oldestAdded time.Time
+		for k, s := range c.sessions {
+			if oldest == "" || s.added.Before(oldestAdded) {
+				oldest, oldestAdded = k, s.added
+			}
+		}
+		delete(c.sessions, oldest)
+	}
+	c.sessions[sessionKey] = expiringSession{state: cs, added: time.Now()}
 }
 
 func (c *Config) parseServerName() string {
@@ -287,12 +352,16 @@ func (c *Config) GetTLSConfig(opts ...Option) *tls.Config {
 	}
 
 	config := &tls.Config{
-		ClientSessionCache:     globalSessionCache,
+		ClientSessionCache:     c.getClientSessionCache(),
 		RootCAs:                root,
 		InsecureSkipVerify:     c.AllowInsecure,
 		NextProtos:             c.NextProtocol,
-		SessionTicketsDisabled: !c.EnableSessionResumption,
+		SessionTicketsDisabled: !c.sessionTicketsEnabled(),
 		VerifyPeerCertificate:  c.verifyPeerCert,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			recordCipherSuite(cs.CipherSuite)
+			return nil
+		},
 	}
 
 	for _, opt := range opts {
@@ -303,13 +372,23 @@ func (c *Config) GetTLSConfig(opts ...Option) *tls.Config {
 	if len(caCerts) > 0 {
 		config.GetCertificate = getGetCertificateFunc(config, caCerts)
 	} else {
-		config.GetCertificate = getNewGetCertificateFunc(c.BuildCertificates(), c.RejectUnknownSni)
+		config.GetCertificate = getNewGetCertificateFunc(c.buildKeyPairs(), c.RejectUnknownSni)
+	}
+
+	if c.sessionTicketsEnabled() {
+		if m := newSessionTicketKeyManager(c); m != nil {
+			config.GetConfigForClient = m.getConfigForClientFunc(config)
+		}
 	}
 
 	if sn := c.parseServerName(); len(sn) > 0 {
 		config.ServerName = sn
 	}
 
+	if c.UseHttpsRecord && len(config.NextProtos) == 0 && config.ServerName != "" {
+		config.NextProtos = httpsRecords.alpn(config.ServerName)
+	}
+
 	if len(config.NextProtos) == 0 {
 		config.NextProtos = []string{"h2", "http/1.1"}
 	}
@@ -3,6 +3,9 @@ package tls_test
 import (
 	gotls "crypto/tls"
 	"crypto/x509"
+	"net"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -63,6 +66,45 @@ func TestExpiredCertificate(t *testing.T) {
 	}
 }
 
+func TestCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	writeCert := func(c *cert.Certificate, mtime time.Time) {
+		certPEM, keyPEM := c.ToPEM()
+		common.Must(os.WriteFile(certPath, certPEM, 0o600))
+		common.Must(os.WriteFile(keyPath, keyPEM, 0o600))
+		common.Must(os.Chtimes(certPath, mtime, mtime))
+		common.Must(os.Chtimes(keyPath, mtime, mtime))
+	}
+
+	oldCert := cert.MustGenerate(nil, cert.CommonName("old.example.com"), cert.DNSNames("www.example.com"))
+	writeCert(oldCert, time.Now().Add(-time.Hour))
+	certificate := ParseCertificate(oldCert)
+	certificate.CertificatePath = certPath
+	certificate.KeyPath = keyPath
+	certificate.ReloadInterval = 1
+
+	tlsConfig := (&Config{Certificate: []*Certificate{certificate}}).GetTLSConfig()
+	getCommonName := func() string {
+		c, err := tlsConfig.GetCertificate(&gotls.ClientHelloInfo{ServerName: "www.example.com"})
+		common.Must(err)
+		return c.Leaf.Subject.CommonName
+	}
+	if name := getCommonName(); name != "old.example.com" {
+		t.Fatal("unexpected certificate: ", name)
+	}
+
+	writeCert(cert.MustGenerate(nil, cert.CommonName("new.example.com"), cert.DNSNames("www.example.com")), time.Now())
+	deadline := time.Now().Add(time.Second * 5)
+	for getCommonName() != "new.example.com" {
+		if time.Now().After(deadline) {
+			t.Fatal("certificate not reloaded")
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+}
+
 func TestInsecureCertificates(t *testing.T) {
 	c := &Config{}
 
@@ -72,6 +114,108 @@ func TestInsecureCertificates(t *testing.T) {
 	}
 }
 
+func TestSharedSessionTicketKey(t *testing.T) {
+	serverCert := ParseCertificate(cert.MustGenerate(nil, cert.CommonName("www.example.com"), cert.DNSNames("www.example.com")))
+	key := make([]byte, 32)
+	newServer := func() *gotls.Config {
+		c := &Config{
+			Certificate:             []*Certificate{serverCert},
+			EnableSessionResumption: true,
+			SessionTicketKey:        [][]byte{key},
+			MaxVersion:              "1.2",
+		}
+		return c.GetTLSConfig()
+	}
+	clientConfig := &gotls.Config{
+		ServerName:         "www.example.com",
+		InsecureSkipVerify: true,
+		ClientSessionCache: gotls.NewLRUClientSessionCache(1),
+		MaxVersion:         gotls.VersionTLS12,
+	}
+
+	handshake := func(serverConfig *gotls.Config) bool {
+		c, s := net.Pipe()
+		defer c.Close()
+		defer s.Close()
+		go gotls.Server(s, serverConfig).Handshake()
+		client := gotls.Client(c, clientConfig)
+		common.Must(client.Handshake())
+		return client.ConnectionState().DidResume
+	}
+
+	if handshake(newServer()) {
+		t.Fatal("unexpected resumption on first handshake")
+	}
+	if !handshake(newServer()) {
+		t.Fatal("session not resumed across servers sharing a ticket key")
+	}
+}
+
+func TestClientSessionCache(t *testing.T) {
+	if cache := (&Config{}).GetTLSConfig().ClientSessionCache; cache != nil {
+		t.Error("expected no session cache when resumption is disabled")
+	}
+
+	tickets := (&Config{EnableSessionTickets: true}).GetTLSConfig()
+	if tickets.ClientSessionCache != nil {
+		t.Error("expected server session tickets to leave the client session cache off")
+	}
+	if tickets.SessionTicketsDisabled {
+		t.Error("expected session tickets to be enabled")
+	}
+
+	c1 := &Config{EnableSessionResumption: true, SessionCacheSize: 16}
+	c2 := &Config{EnableSessionResumption: true, SessionCacheSize: 16}
+	if c1.GetTLSConfig().ClientSessionCache != c1.GetTLSConfig().ClientSessionCache {
+		t.Error("expected session cache to be reused across dials")
+	}
+	if c1.GetTLSConfig().ClientSessionCache == c2.GetTLSConfig().ClientSessionCache {
+		t.Error("expected dedicated session cache per config")
+	}
+
+	cache := c1.GetTLSConfig().ClientSessionCache
+	c1.ReleaseSessionCache()
+	if c1.GetTLSConfig().ClientSessionCache == cache {
+		t.Error("expected released session cache to be dropped")
+	}
+	(*Config)(nil).ReleaseSessionCache()
+}
+
+func TestExpiringSessionCache(t *testing.T) {
+	c := &Config{EnableSessionResumption: true, SessionCacheSize: 2, SessionCacheTtl: 1}
+	cache := c.GetTLSConfig().ClientSessionCache
+	state := new(gotls.ClientSessionState)
+	cache.Put("a", state)
+	cache.Put("b", state)
+	cache.Put("c", state)
+	if _, found := cache.Get("a"); found {
+		t.Error("expected the oldest session to be evicted")
+	}
+	if s, found := cache.Get("c"); !found || s != state {
+		t.Error("expected the session to be cached")
+	}
+	cache.Put("c", nil)
+	if _, found := cache.Get("c"); found {
+		t.Error("expected the session to be removed")
+	}
+
+	time.Sleep(time.Second)
+	if _, found := cache.Get("b"); found {
+		t.Error("expected the session to expire")
+	}
+}
+
+func TestParseSessionTicketKeys(t *testing.T) {
+	keys, err := ParseSessionTicketKeys(make([]byte, 64))
+	common.Must(err)
+	if len(keys) != 2 {
+		t.Error("expected 2 keys, got ", len(keys))
+	}
+	if _, err := ParseSessionTicketKeys(make([]byte, 48)); err == nil {
+		t.Error("expected error for invalid key length")
+	}
+}
+
 func BenchmarkCertificateIssuing(b *testing.B) {
 	certificate := ParseCertificate(cert.MustGenerate(nil, cert.Authority(true), cert.KeyUsage(x509.KeyUsageCertSign)))
 	certificate.Usage = Certificate_AUTHORITY_ISSUE
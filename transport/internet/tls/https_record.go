@@ -0,0 +1,87 @@
+package tls
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/transport/internet"
+)
+
+// how long the ALPN from an HTTPS record, or the lack of one, is used for
+const httpsRecordTTL = 10 * time.Minute
+
+// ALPN from the HTTPS records of server names, for configs with use_https_record
+var httpsRecords = newHTTPSRecordCache(alpnFromHTTPSRecord)
+
+// httpsRecordCache keeps the ALPN from HTTPS records. Dials never wait for a lookup: while the record of a
+// server name is looked up in the background, its dials use the previous ALPN, or the default one.
+type httpsRecordCache struct {
+	lookup func(serverName string) []string
+
+	access  sync.Mutex
+	entries map[string]*httpsRecordEntry
+}
+
+type httpsRecordEntry struct {
+	alpn []string
+	// zero while the record is looked up
+	expire time.Time
+}
+
+func newHTTPSRecordCache(lookup func(string) []string) *httpsRecordCache {
+	return &httpsRecordCache{
+		lookup:  lookup,
+		entries: make(map[string]*httpsRecordEntry),
+	}
+}
+
+// alpn returns the cached ALPN of the server name, and starts a lookup if there is none or it expired.
+func (c *httpsRecordCache) alpn(serverName string) []string {
+	now := time.Now()
+	c.access.Lock()
+	defer c.access.Unlock()
+
+	entry := c.entries[serverName]
+	if entry != nil && (entry.expire.IsZero() || now.Before(entry.expire)) {
+		return entry.alpn
+	}
+	for name, e := range c.entries {
+		if !e.expire.IsZero() && now.After(e.expire) {
+			delete(c.entries, name)
+		}
+	}
+	var alpn []string
+	if entry != nil {
+		alpn = entry.alpn
+	}
+	c.entries[serverName] = &httpsRecordEntry{alpn: alpn}
+	go func() {
+		alpn := c.lookup(serverName)
+		c.access.Lock()
+		c.entries[serverName] = &httpsRecordEntry{alpn: alpn, expire: time.Now().Add(httpsRecordTTL)}
+		c.access.Unlock()
+	}()
+	return alpn
+}
+
+// alpnFromHTTPSRecord returns the protocols of the HTTPS record of the server that run over TLS on TCP.
+func alpnFromHTTPSRecord(serverName string) []string {
+	rec, err := internet.LookupHTTPS(serverName)
+	if err != nil {
+		newError("failed to lookup HTTPS record of ", serverName).Base(err).AtDebug().WriteToLog()
+		return nil
+	}
+	if rec == nil {
+		return nil
+	}
+	var protos []string
+	for _, p := range rec.ALPN {
+		// HTTP/3 runs over QUIC
+		if p == "h3" || strings.HasPrefix(p, "h3-") {
+			continue
+		}
+		protos = append(protos, p)
+	}
+	return protos
+}
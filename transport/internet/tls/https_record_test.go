@@ -0,0 +1,47 @@
+package tls
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHTTPSRecordCache(t *testing.T) {
+	var lookups int32
+	unblock := make(chan struct{})
+	cache := newHTTPSRecordCache(func(serverName string) []string {
+		atomic.AddInt32(&lookups, 1)
+		<-unblock
+		return []string{"h2"}
+	})
+
+	// the dials don't wait for the lookup
+	for i := 0; i < 3; i++ {
+		if alpn := cache.alpn("example.com"); alpn != nil {
+			t.Error("unexpected ALPN: ", alpn)
+		}
+	}
+	close(unblock)
+	time.Sleep(100 * time.Millisecond)
+
+	if r := cmp.Diff(cache.alpn("example.com"), []string{"h2"}); r != "" {
+		t.Error(r)
+	}
+	if n := atomic.LoadInt32(&lookups); n != 1 {
+		t.Error("lookups: ", n)
+	}
+
+	// an expired record is used until it is looked up again
+	cache.access.Lock()
+	cache.entries["example.com"].expire = time.Now().Add(-time.Second)
+	cache.access.Unlock()
+	if r := cmp.Diff(cache.alpn("example.com"), []string{"h2"}); r != "" {
+		t.Error(r)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&lookups); n != 2 {
+		t.Error("lookups: ", n)
+	}
+}
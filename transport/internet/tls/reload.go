@@ -0,0 +1,179 @@
+package tls
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xtls/xray-core/common/ocsp"
+	"github.com/xtls/xray-core/common/platform/filesystem"
+)
+
+// defaultReloadInterval is how often the files of a certificate are checked for changes, unless configured
+// otherwise.
+const defaultReloadInterval = time.Minute
+
+// keyPairs holds the keyPair of each certificate entry, so that building a TLS config again does not start
+// another watcher.
+var keyPairs sync.Map
+
+// keyPair is the key pair of a certificate entry, which follows the certificate and key files of the entry
+// and the OCSP responses for it.
+type keyPair struct {
+	entry   *Certificate
+	current atomic.Pointer[tls.Certificate]
+
+	// the files loaded lastly
+	certPEM, keyPEM   []byte
+	certStat, keyStat os.FileInfo
+
+	// when the OCSP response is due to refresh, and when it expires
+	stapleRefresh, stapleExpiry time.Time
+}
+
+func parseKeyPair(certPEM, keyPEM []byte) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, newError("invalid X509 key pair").Base(err)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil, newError("invalid certificate").Base(err)
+	}
+	return &cert, nil
+}
+
+// getKeyPair returns the key pair of the entry, which is watched for changes unless it is loaded only once.
+func getKeyPair(entry *Certificate) (*keyPair, error) {
+	if p, found := keyPairs.Load(entry); found {
+		return p.(*keyPair), nil
+	}
+	cert, err := parseKeyPair(entry.Certificate, entry.Key)
+	if err != nil {
+		return nil, err
+	}
+	p := &keyPair{
+		entry:   entry,
+		certPEM: entry.Certificate,
+		keyPEM:  entry.Key,
+	}
+	p.current.Store(cert)
+	if actual, loaded := keyPairs.LoadOrStore(entry, p); loaded {
+		return actual.(*keyPair), nil
+	}
+	if !entry.OneTimeLoading && (entry.CertificatePath != "" && entry.KeyPath != "" || entry.OcspStapling != 0) {
+		go p.watch()
+	}
+	return p, nil
+}
+
+func (p *keyPair) watch() {
+	interval := defaultReloadInterval
+	if p.entry.ReloadInterval > 0 {
+		interval = time.Duration(p.entry.ReloadInterval) * time.Second
+	}
+	ocspInterval := time.Duration(p.entry.OcspStapling) * time.Second
+	if ocspInterval > 0 && ocspInterval < interval {
+		interval = ocspInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		reloaded := p.entry.CertificatePath != "" && p.entry.KeyPath != "" && p.reload()
+		if ocspInterval > 0 && (reloaded || !time.Now().Before(p.stapleRefresh)) {
+			p.staple(ocspInterval)
+		}
+		<-t.C
+	}
+}
+
+// reload loads the files of the certificate if they changed, and returns whether the key pair changed.
+func (p *keyPair) reload() bool {
+	certStat, err := os.Stat(p.entry.CertificatePath)
+	if err != nil {
+		newError("failed to reload certificate").Base(err).AtError().WriteToLog()
+		reportCertError(p.entry.CertificatePath, err)
+		return false
+	}
+	keyStat, err := os.Stat(p.entry.KeyPath)
+	if err != nil {
+		newError("failed to reload key").Base(err).AtError().WriteToLog()
+		reportCertError(p.entry.KeyPath, err)
+		return false
+	}
+	if sameFile(certStat, p.certStat) && sameFile(keyStat, p.keyStat) {
+		return false
+	}
+
+	certPEM, err := filesystem.ReadFile(p.entry.CertificatePath)
+	if err != nil {
+		newError("failed to reload certificate").Base(err).AtError().WriteToLog()
+		reportCertError(p.entry.CertificatePath, err)
+		return false
+	}
+	keyPEM, err := filesystem.ReadFile(p.entry.KeyPath)
+	if err != nil {
+		newError("failed to reload key").Base(err).AtError().WriteToLog()
+		reportCertError(p.entry.KeyPath, err)
+		return false
+	}
+	if bytes.Equal(certPEM, p.certPEM) && bytes.Equal(keyPEM, p.keyPEM) {
+		p.certStat, p.keyStat = certStat, keyStat
+		return false
+	}
+	// the files are checked again next time, as a certificate may be written ahead of its key
+	cert, err := parseKeyPair(certPEM, keyPEM)
+	if err != nil {
+		newError("ignoring certificate ", p.entry.CertificatePath).Base(err).AtError().WriteToLog()
+		reportCertError(p.entry.CertificatePath, err)
+		return false
+	}
+	p.certPEM, p.keyPEM = certPEM, keyPEM
+	p.certStat, p.keyStat = certStat, keyStat
+	p.current.Store(cert)
+	newError("reloaded certificate ", p.entry.CertificatePath, " (expire on ", cert.Leaf.NotAfter.Format(time.RFC3339), ")").AtInfo().WriteToLog()
+	return true
+}
+
+// staple updates the OCSP response of the certificate, which is refreshed again after the interval, or halfway
+// through the validity of the response if that comes first. A response past its validity is dropped, as
+// clients reject a stale one.
+func (p *keyPair) staple(interval time.Duration) {
+	now := time.Now()
+	p.stapleRefresh = now.Add(interval)
+	current := p.current.Load()
+	ocspData, ocspRes, err := ocsp.GetOCSPResponseForCert(current.Certificate)
+	if err != nil {
+		newError("ignoring invalid OCSP").Base(err).AtWarning().WriteToLog()
+		if len(current.OCSPStaple) > 0 && !p.stapleExpiry.IsZero() && now.After(p.stapleExpiry) {
+			newError("dropping expired OCSP response of ", current.Leaf.Subject.CommonName).AtWarning().WriteToLog()
+			p.setStaple(current, nil)
+		}
+		return
+	}
+	p.stapleExpiry = ocspRes.NextUpdate
+	if !ocspRes.NextUpdate.IsZero() {
+		if refresh := ocspRes.ThisUpdate.Add(ocspRes.NextUpdate.Sub(ocspRes.ThisUpdate) / 2); refresh.Before(p.stapleRefresh) {
+			p.stapleRefresh = refresh
+		}
+	}
+	if !bytes.Equal(ocspData, current.OCSPStaple) {
+		p.setStaple(current, ocspData)
+		newError("stapled OCSP response of ", current.Leaf.Subject.CommonName, " (next update on ", ocspRes.NextUpdate.Format(time.RFC3339), ")").AtInfo().WriteToLog()
+	}
+}
+
+// setStaple serves a copy of the key pair with the OCSP response, unless the key pair got reloaded meanwhile.
+func (p *keyPair) setStaple(current *tls.Certificate, ocspData []byte) {
+	cert := *current
+	cert.OCSPStaple = ocspData
+	p.current.CompareAndSwap(current, &cert)
+}
+
+func sameFile(a, b os.FileInfo) bool {
+	return a != nil && b != nil && a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
@@ -0,0 +1,112 @@
+package tls
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/platform/filesystem"
+)
+
+const (
+	sessionTicketKeyLen = 32
+	// number of previous random keys still accepted for decryption
+	sessionTicketKeyHistory = 2
+)
+
+// ParseSessionTicketKeys splits raw bytes into 32-byte session ticket keys.
+func ParseSessionTicketKeys(raw []byte) ([][sessionTicketKeyLen]byte, error) {
+	if len(raw) == 0 || len(raw)%sessionTicketKeyLen != 0 {
+		return nil, newError("invalid session ticket key length: ", len(raw), ", must be a multiple of ", sessionTicketKeyLen)
+	}
+	keys := make([][sessionTicketKeyLen]byte, 0, len(raw)/sessionTicketKeyLen)
+	for i := 0; i < len(raw); i += sessionTicketKeyLen {
+		var key [sessionTicketKeyLen]byte
+		copy(key[:], raw[i:])
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// sessionTicketKeyManager keeps the session ticket keys of a server tls.Config up to date.
+// Updates are checked lazily on incoming handshakes, so no goroutine is bound to the listener.
+type sessionTicketKeyManager struct {
+	sync.Mutex
+	config     *Config
+	interval   time.Duration
+	keys       [][sessionTicketKeyLen]byte
+	lastUpdate time.Time
+}
+
+func newSessionTicketKeyManager(c *Config) *sessionTicketKeyManager {
+	if len(c.SessionTicketKey) == 0 && c.SessionTicketKeyPath == "" && c.SessionTicketKeyRotation == 0 {
+		return nil
+	}
+	return &sessionTicketKeyManager{
+		config:   c,
+		interval: time.Duration(c.SessionTicketKeyRotation) * time.Second,
+	}
+}
+
+func (m *sessionTicketKeyManager) load() ([][sessionTicketKeyLen]byte, error) {
+	c := m.config
+	if c.SessionTicketKeyPath != "" {
+		raw, err := filesystem.ReadFile(c.SessionTicketKeyPath)
+		if err != nil {
+			return nil, newError("failed to read session ticket keys").Base(err)
+		}
+		return ParseSessionTicketKeys(raw)
+	}
+	if len(c.SessionTicketKey) > 0 {
+		keys := make([][sessionTicketKeyLen]byte, 0, len(c.SessionTicketKey))
+		for _, raw := range c.SessionTicketKey {
+			k, err := ParseSessionTicketKeys(raw)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, k...)
+		}
+		return keys, nil
+	}
+	var key [sessionTicketKeyLen]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return nil, newError("failed to generate session ticket key").Base(err)
+	}
+	keys := append([][sessionTicketKeyLen]byte{key}, m.keys...)
+	if len(keys) > sessionTicketKeyHistory+1 {
+		keys = keys[:sessionTicketKeyHistory+1]
+	}
+	return keys, nil
+}
+
+// update refreshes the keys of config if they are missing or expired.
+func (m *sessionTicketKeyManager) update(config *tls.Config) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.keys != nil && (m.interval == 0 || time.Since(m.lastUpdate) < m.interval) {
+		return
+	}
+	// Retry failed loads no sooner than the next interval.
+	m.lastUpdate = time.Now()
+	keys, err := m.load()
+	if err != nil {
+		newError("failed to update session ticket keys").Base(err).AtError().WriteToLog()
+		if m.keys == nil {
+			m.keys = [][sessionTicketKeyLen]byte{}
+		}
+		return
+	}
+	m.keys = keys
+	config.SetSessionTicketKeys(keys)
+	newError("session ticket keys updated, ", len(keys), " key(s) in use").AtDebug().WriteToLog()
+}
+
+// getConfigForClientFunc applies the managed session ticket keys before each server handshake.
+func (m *sessionTicketKeyManager) getConfigForClientFunc(config *tls.Config) func(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		m.update(config)
+		return nil, nil
+	}
+}
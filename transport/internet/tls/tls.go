@@ -4,10 +4,16 @@ import (
 	"crypto/rand"
 	"crypto/tls"
 	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	utls "github.com/refraction-networking/utls"
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/transport/internet"
 )
 
 //go:generate go run github.com/xtls/xray-core/common/errors/errorgen
@@ -23,6 +29,47 @@ var _ buf.Writer = (*Conn)(nil)
 
 type Conn struct {
 	*tls.Conn
+	events          *internet.EventRecorder
+	handshakeFailed uint32
+}
+
+// RecordEvents makes the connection count a failed handshake in events.
+func (c *Conn) RecordEvents(events *internet.EventRecorder) {
+	c.events = events
+}
+
+func (c *Conn) Handshake() error {
+	err := c.Conn.Handshake()
+	if err != nil {
+		c.recordHandshakeFailure()
+	}
+	return err
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if err != nil {
+		c.recordHandshakeFailure()
+	}
+	return n, err
+}
+
+func (c *Conn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if err != nil {
+		c.recordHandshakeFailure()
+	}
+	return n, err
+}
+
+// recordHandshakeFailure counts the handshake as failed once, if the error that ended an I/O came before it completed.
+func (c *Conn) recordHandshakeFailure() {
+	if c.events == nil || c.ConnectionState().HandshakeComplete {
+		return
+	}
+	if atomic.CompareAndSwapUint32(&c.handshakeFailed, 0, 1) {
+		c.events.Record(internet.EventTLSHandshakeFailure, 1)
+	}
 }
 
 func (c *Conn) WriteMultiBuffer(mb buf.MultiBuffer) error {
@@ -108,6 +155,30 @@ func (c *UConn) NegotiatedProtocol() (name string, mutual bool) {
 }
 
 func UClient(c net.Conn, config *tls.Config, fingerprint *utls.ClientHelloID) net.Conn {
+	if b := getBehavior(fingerprint); b != nil {
+		if spec, err := utls.UTLSIdToSpec(*fingerprint); err == nil {
+			b.apply(&spec)
+			if protocol.PreferChaCha20() {
+				preferChaCha20(spec.CipherSuites)
+			}
+			uConfig := copyConfig(config)
+			uConfig.ClientSessionCache = b.cache
+			utlsConn := utls.UClient(c, uConfig, utls.HelloCustom)
+			if err := utlsConn.ApplyPreset(&spec); err == nil {
+				return &UConn{UConn: utlsConn}
+			}
+		}
+	}
+	if protocol.PreferChaCha20() {
+		// browsers on CPUs without AES instructions offer ChaCha20 first, and so do we
+		if spec, err := utls.UTLSIdToSpec(*fingerprint); err == nil {
+			preferChaCha20(spec.CipherSuites)
+			utlsConn := utls.UClient(c, copyConfig(config), utls.HelloCustom)
+			if err := utlsConn.ApplyPreset(&spec); err == nil {
+				return &UConn{UConn: utlsConn}
+			}
+		}
+	}
 	utlsConn := utls.UClient(c, copyConfig(config), *fingerprint)
 	return &UConn{UConn: utlsConn}
 }
@@ -118,7 +189,64 @@ func copyConfig(c *tls.Config) *utls.Config {
 		ServerName:            c.ServerName,
 		InsecureSkipVerify:    c.InsecureSkipVerify,
 		VerifyPeerCertificate: c.VerifyPeerCertificate,
+		VerifyConnection: func(cs utls.ConnectionState) error {
+			recordCipherSuite(cs.CipherSuite)
+			return nil
+		},
+	}
+}
+
+func isChaCha20(id uint16) bool {
+	switch id {
+	case utls.TLS_CHACHA20_POLY1305_SHA256, utls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256, utls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256:
+		return true
+	}
+	return false
+}
+
+// preferChaCha20 moves the ChaCha20 suites in front of the others of the same TLS version.
+func preferChaCha20(suites []uint16) {
+	rank := func(id uint16) int {
+		switch {
+		case id == utls.GREASE_PLACEHOLDER:
+			return 0
+		case id>>8 == 0x13 && isChaCha20(id):
+			return 1
+		case id>>8 == 0x13:
+			return 2
+		case isChaCha20(id):
+			return 3
+		default:
+			return 4
+		}
 	}
+	sort.SliceStable(suites, func(i, j int) bool {
+		return rank(suites[i]) < rank(suites[j])
+	})
+}
+
+var (
+	cipherSuiteAccess sync.Mutex
+	cipherSuiteCounts = make(map[string]uint64)
+)
+
+func recordCipherSuite(id uint16) {
+	name := tls.CipherSuiteName(id)
+	cipherSuiteAccess.Lock()
+	cipherSuiteCounts[name]++
+	cipherSuiteAccess.Unlock()
+}
+
+// CipherSuiteStats returns the number of handshakes that negotiated each cipher suite.
+func CipherSuiteStats() map[string]uint64 {
+	cipherSuiteAccess.Lock()
+	defer cipherSuiteAccess.Unlock()
+
+	stats := make(map[string]uint64, len(cipherSuiteCounts))
+	for name, count := range cipherSuiteCounts {
+		stats[name] = count
+	}
+	return stats
 }
 
 func init() {
@@ -145,6 +273,12 @@ func GetFingerprint(name string) (fingerprint *utls.ClientHelloID) {
 	if name == "" {
 		return
 	}
+	if base := strings.TrimSuffix(name, BehaviorSuffix); base != name {
+		if fingerprint = GetFingerprint(base); fingerprint != nil {
+			fingerprint = behaviorFingerprint(fingerprint)
+		}
+		return
+	}
 	if fingerprint = PresetFingerprints[name]; fingerprint != nil {
 		return
 	}
@@ -0,0 +1,69 @@
+package tls_test
+
+import (
+	"context"
+	gotls "crypto/tls"
+	"net"
+	"testing"
+
+	utls "github.com/refraction-networking/utls"
+	"github.com/xtls/xray-core/app/stats"
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/protocol/tls/cert"
+	"github.com/xtls/xray-core/transport/internet"
+	. "github.com/xtls/xray-core/transport/internet/tls"
+)
+
+func TestHandshakeFailureEvent(t *testing.T) {
+	m, err := stats.NewManager(context.Background(), &stats.Config{})
+	common.Must(err)
+	events := internet.NewEventRecorder(m, "inbound>>>tls")
+
+	serverConfig := (&Config{
+		Certificate: []*Certificate{ParseCertificate(cert.MustGenerate(nil))},
+	}).GetTLSConfig()
+
+	c, s := net.Pipe()
+	defer c.Close()
+	go func() {
+		c.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+		c.Close()
+	}()
+	conn := Server(s, serverConfig).(*Conn)
+	conn.RecordEvents(events)
+	b := make([]byte, 1)
+	if _, err := conn.Read(b); err == nil {
+		t.Fatal("expect handshake to fail")
+	}
+	conn.Read(b)
+	conn.Close()
+
+	if c := m.GetCounter("inbound>>>tls>>>transport>>>tls_handshake_failure"); c == nil || c.Value() != 1 {
+		t.Error("expect one handshake failure, got ", c)
+	}
+}
+
+func TestUClientPreferChaCha20(t *testing.T) {
+	defer protocol.ResetPreferChaCha20()
+
+	for _, prefer := range []bool{true, false} {
+		protocol.SetPreferChaCha20(prefer)
+		c, _ := net.Pipe()
+		conn := UClient(c, &gotls.Config{ServerName: "example.com"}, &utls.HelloChrome_102).(*UConn)
+		common.Must(conn.BuildHandshakeState())
+		c.Close()
+
+		var first uint16
+		for _, suite := range conn.HandshakeState.Hello.CipherSuites {
+			if suite>>8 == 0x13 {
+				first = suite
+				break
+			}
+		}
+		isChaCha20 := first == utls.TLS_CHACHA20_POLY1305_SHA256
+		if isChaCha20 != prefer {
+			t.Errorf("prefer ChaCha20 %v: unexpected first TLS 1.3 suite %#04x", prefer, first)
+		}
+	}
+}
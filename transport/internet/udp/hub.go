@@ -2,13 +2,30 @@ package udp
 
 import (
 	"context"
+	"io"
 
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/net"
 	"github.com/xtls/xray-core/common/protocol/udp"
+	"github.com/xtls/xray-core/common/signal/done"
 	"github.com/xtls/xray-core/transport/internet"
+	"golang.org/x/net/ipv4"
 )
 
+const (
+	// maximum number of packets read or written in one syscall
+	batchSize = 64
+	// limits of a write split by the kernel with segmentation offload
+	gsoMaxSegments = 64
+	gsoMaxSize     = 65000
+)
+
+// batchConn reads and writes many packets in one syscall, such as recvmmsg and sendmmsg on Linux.
+type batchConn interface {
+	ReadBatch(ms []ipv4.Message, flags int) (int, error)
+	WriteBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
 type HubOption func(h *Hub)
 
 func HubCapacity(capacity int) HubOption {
@@ -25,7 +42,11 @@ func HubReceiveOriginalDestination(r bool) HubOption {
 
 type Hub struct {
 	conn         *net.UDPConn
+	batch        batchConn
 	cache        chan *udp.Packet
+	writes       chan *udp.Packet
+	gso          bool
+	done         *done.Instance
 	capacity     int
 	recvOrigDest bool
 }
@@ -56,23 +77,224 @@ func ListenUDP(ctx context.Context, address net.Address, port net.Port, streamSe
 	}
 	newError("listening UDP on ", address, ":", port).WriteToLog()
 	hub.conn = udpConn.(*net.UDPConn)
+	hub.batch = newBatchConn(hub.conn)
 	hub.cache = make(chan *udp.Packet, hub.capacity)
+	hub.done = done.New()
 
-	go hub.start()
+	if hub.batch != nil {
+		hub.writes = make(chan *udp.Packet, hub.capacity)
+		hub.gso = gsoSupported(hub.conn)
+		go hub.startBatch()
+		go hub.writeBatches()
+	} else {
+		go hub.start()
+	}
 	return hub, nil
 }
 
 // Close implements net.Listener.
 func (h *Hub) Close() error {
+	h.done.Close()
 	h.conn.Close()
 	return nil
 }
 
 func (h *Hub) WriteTo(payload []byte, dest net.Destination) (int, error) {
-	return h.conn.WriteToUDP(payload, &net.UDPAddr{
-		IP:   dest.Address.IP(),
-		Port: int(dest.Port),
-	})
+	// a payload larger than a buffer is sent right away, as it would be truncated in the batch
+	if h.batch == nil || len(payload) > buf.Size {
+		return h.conn.WriteToUDP(payload, &net.UDPAddr{
+			IP:   dest.Address.IP(),
+			Port: int(dest.Port),
+		})
+	}
+
+	// the packet is sent in the next batch, and the caller may reuse payload right after returning
+	b := buf.New()
+	if _, err := b.Write(payload); err != nil {
+		b.Release()
+		return 0, err
+	}
+	select {
+	case h.writes <- &udp.Packet{Payload: b, Target: dest}:
+		return len(payload), nil
+	case <-h.done.Wait():
+		b.Release()
+		return 0, io.ErrClosedPipe
+	}
+}
+
+// pack fills messages with packets. With segmentation offload, packets of the same size to the
+// same destination are joined into one message, which the kernel splits again. firsts[i] is set
+// to the index of the first packet of message i, and firsts[count] to the number of packets.
+func (h *Hub) pack(packets []*udp.Packet, messages []ipv4.Message, firsts []int) int {
+	count := 0
+	for i := 0; i < len(packets); {
+		p := packets[i]
+		m := &messages[count]
+		firsts[count] = i
+		m.Buffers = append(m.Buffers[:0], p.Payload.Bytes())
+		m.OOB = nil
+		m.Addr = &net.UDPAddr{
+			IP:   p.Target.Address.IP(),
+			Port: int(p.Target.Port),
+		}
+		i++
+
+		if h.gso {
+			size := int(p.Payload.Len())
+			total := size
+			for i < len(packets) && len(m.Buffers) < gsoMaxSegments {
+				next := packets[i]
+				l := int(next.Payload.Len())
+				if next.Target != p.Target || l > size || total+l > gsoMaxSize {
+					break
+				}
+				m.Buffers = append(m.Buffers, next.Payload.Bytes())
+				total += l
+				i++
+				// only the last segment may be shorter
+				if l < size {
+					break
+				}
+			}
+			if len(m.Buffers) > 1 {
+				m.OOB = gsoControl(size)
+			}
+		}
+		count++
+	}
+	firsts[count] = len(packets)
+	return count
+}
+
+// writeBatches sends the packets queued by WriteTo, as many as are ready in one syscall.
+func (h *Hub) writeBatches() {
+	packets := make([]*udp.Packet, 0, batchSize)
+	messages := make([]ipv4.Message, batchSize)
+	firsts := make([]int, batchSize+1)
+	for {
+		select {
+		case p := <-h.writes:
+			packets = append(packets, p)
+		case <-h.done.Wait():
+			return
+		}
+	fill:
+		for len(packets) < batchSize {
+			select {
+			case p := <-h.writes:
+				packets = append(packets, p)
+			default:
+				break fill
+			}
+		}
+
+		for sent := 0; sent < len(packets); {
+			count := h.pack(packets[sent:], messages, firsts)
+			n, err := h.batch.WriteBatch(messages[:count], 0)
+			if n < 0 {
+				n = 0
+			}
+			sent += firsts[n]
+			if err != nil {
+				if h.done.Done() {
+					break
+				}
+				if firsts[n+1]-firsts[n] > 1 {
+					// the device may not support segmentation offload, so send the packets one by one
+					newError("disabling UDP segmentation offload").Base(err).AtWarning().WriteToLog()
+					h.gso = false
+					continue
+				}
+				// skip the packet that failed, like a failed WriteToUDP would
+				newError("failed to write UDP batch").Base(err).WriteToLog()
+				sent++
+			}
+		}
+		for i, p := range packets {
+			p.Payload.Release()
+			packets[i] = nil
+		}
+		for i := range messages {
+			messages[i].Buffers = messages[i].Buffers[:0]
+		}
+		packets = packets[:0]
+	}
+}
+
+func (h *Hub) deliver(payload *udp.Packet, oob []byte) {
+	if h.recvOrigDest && len(oob) > 0 {
+		payload.Target = RetrieveOriginalDest(oob)
+		if payload.Target.IsValid() {
+			newError("UDP original destination: ", payload.Target).AtDebug().WriteToLog()
+		} else {
+			newError("failed to read UDP original destination").WriteToLog()
+		}
+	}
+
+	select {
+	case h.cache <- payload:
+	default:
+		payload.Payload.Release()
+		payload.Payload = nil
+	}
+}
+
+// startBatch is the same as start, but reads up to batchSize packets in one syscall.
+func (h *Hub) startBatch() {
+	defer close(h.cache)
+
+	buffers := make([]*buf.Buffer, batchSize)
+	messages := make([]ipv4.Message, batchSize)
+	for i := range messages {
+		messages[i].OOB = make([]byte, 256)
+	}
+	defer func() {
+		for _, b := range buffers {
+			if b != nil {
+				b.Release()
+			}
+		}
+	}()
+
+	for {
+		for i, b := range buffers {
+			if b == nil {
+				b = buf.New()
+				buffers[i] = b
+			}
+			messages[i].Buffers = [][]byte{b.Extend(buf.Size)}
+		}
+
+		n, err := h.batch.ReadBatch(messages, 0)
+		if err != nil {
+			newError("failed to read UDP msg").Base(err).WriteToLog()
+			break
+		}
+
+		for i := 0; i < n; i++ {
+			m := &messages[i]
+			buffer := buffers[i]
+			buffer.Resize(0, int32(m.N))
+			if buffer.IsEmpty() {
+				continue
+			}
+			addr, ok := m.Addr.(*net.UDPAddr)
+			if !ok {
+				continue
+			}
+			buffers[i] = nil
+			h.deliver(&udp.Packet{
+				Payload: buffer,
+				Source:  net.UDPDestination(net.IPAddress(addr.IP), net.Port(addr.Port)),
+			}, m.OOB[:m.NN])
+		}
+		for _, b := range buffers {
+			if b != nil {
+				b.Clear()
+			}
+		}
+	}
 }
 
 func (h *Hub) start() {
@@ -100,25 +322,10 @@ func (h *Hub) start() {
 			continue
 		}
 
-		payload := &udp.Packet{
+		h.deliver(&udp.Packet{
 			Payload: buffer,
 			Source:  net.UDPDestination(net.IPAddress(addr.IP), net.Port(addr.Port)),
-		}
-		if h.recvOrigDest && noob > 0 {
-			payload.Target = RetrieveOriginalDest(oobBytes[:noob])
-			if payload.Target.IsValid() {
-				newError("UDP original destination: ", payload.Target).AtDebug().WriteToLog()
-			} else {
-				newError("failed to read UDP original destination").WriteToLog()
-			}
-		}
-
-		select {
-		case c <- payload:
-		default:
-			buffer.Release()
-			payload.Payload = nil
-		}
+		}, oobBytes[:noob])
 	}
 }
 
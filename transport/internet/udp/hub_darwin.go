@@ -44,3 +44,15 @@ func ReadUDPMsg(conn *net.UDPConn, payload []byte, oob []byte) (int, int, int, *
 	noob, _ := reader.Read(oob)
 	return nBytes, noob, 0, addr, err
 }
+
+func newBatchConn(conn *net.UDPConn) batchConn {
+	return nil
+}
+
+func gsoSupported(conn *net.UDPConn) bool {
+	return false
+}
+
+func gsoControl(size int) []byte {
+	return nil
+}
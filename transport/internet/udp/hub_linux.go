@@ -5,8 +5,10 @@ package udp
 
 import (
 	"syscall"
+	"unsafe"
 
 	"github.com/xtls/xray-core/common/net"
+	"golang.org/x/net/ipv4"
 	"golang.org/x/sys/unix"
 )
 
@@ -32,3 +34,38 @@ func RetrieveOriginalDest(oob []byte) net.Destination {
 func ReadUDPMsg(conn *net.UDPConn, payload []byte, oob []byte) (int, int, int, *net.UDPAddr, error) {
 	return conn.ReadMsgUDP(payload, oob)
 }
+
+// newBatchConn uses recvmmsg and sendmmsg, which work for sockets of both address families.
+func newBatchConn(conn *net.UDPConn) batchConn {
+	return ipv4.NewPacketConn(conn)
+}
+
+const (
+	solUDP     = 17  // SOL_UDP
+	udpSegment = 103 // UDP_SEGMENT, missing in x/sys
+)
+
+// gsoSupported tells whether the kernel can split a large write into datagrams by itself.
+func gsoSupported(conn *net.UDPConn) bool {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return false
+	}
+	supported := false
+	rawConn.Control(func(fd uintptr) {
+		_, err := unix.GetsockoptInt(int(fd), solUDP, udpSegment)
+		supported = err == nil
+	})
+	return supported
+}
+
+// gsoControl returns the control message that sets the segment size of a write.
+func gsoControl(size int) []byte {
+	b := make([]byte, unix.CmsgSpace(2))
+	h := (*unix.Cmsghdr)(unsafe.Pointer(&b[0]))
+	h.Level = solUDP
+	h.Type = udpSegment
+	h.SetLen(unix.CmsgLen(2))
+	*(*uint16)(unsafe.Pointer(&b[unix.CmsgLen(0)])) = uint16(size)
+	return b
+}
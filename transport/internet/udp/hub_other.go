@@ -15,3 +15,15 @@ func ReadUDPMsg(conn *net.UDPConn, payload []byte, oob []byte) (int, int, int, *
 	nBytes, addr, err := conn.ReadFromUDP(payload)
 	return nBytes, 0, 0, addr, err
 }
+
+func newBatchConn(conn *net.UDPConn) batchConn {
+	return nil
+}
+
+func gsoSupported(conn *net.UDPConn) bool {
+	return false
+}
+
+func gsoControl(size int) []byte {
+	return nil
+}
@@ -0,0 +1,89 @@
+package udp_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common"
+	"github.com/xtls/xray-core/common/net"
+	. "github.com/xtls/xray-core/transport/internet/udp"
+)
+
+func TestHubEcho(t *testing.T) {
+	hub, err := ListenUDP(context.Background(), net.LocalHostIP, 0, nil)
+	common.Must(err)
+	defer hub.Close()
+
+	go func() {
+		for packet := range hub.Receive() {
+			hub.WriteTo(packet.Payload.Bytes(), packet.Source)
+			packet.Payload.Release()
+		}
+	}()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.LocalHostIP.IP()})
+	common.Must(err)
+	defer conn.Close()
+
+	const count = 200
+	for i := 0; i < count; i++ {
+		// sizes vary, so that segmentation offload has to split groups
+		payload := make([]byte, 100+i%3*50)
+		payload[0] = byte(i)
+		common.Must2(conn.WriteTo(payload, hub.Addr()))
+	}
+
+	seen := make(map[byte]bool)
+	b := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for len(seen) < count {
+		n, _, err := conn.ReadFrom(b)
+		if err != nil {
+			t.Fatal("received ", len(seen), " packets: ", err)
+		}
+		if n != 100+int(b[0])%3*50 {
+			t.Fatal("unexpected size ", n, " of packet ", b[0])
+		}
+		seen[b[0]] = true
+	}
+}
+
+func TestHubWriteLargePacket(t *testing.T) {
+	hub, err := ListenUDP(context.Background(), net.LocalHostIP, 0, nil)
+	common.Must(err)
+	defer hub.Close()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.LocalHostIP.IP()})
+	common.Must(err)
+	defer conn.Close()
+
+	// larger than buf.Size
+	payload := make([]byte, 10000)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	dest := net.DestinationFromAddr(conn.LocalAddr())
+	if n, err := hub.WriteTo(payload, dest); err != nil || n != len(payload) {
+		t.Fatal("write ", n, " bytes: ", err)
+	}
+	small := []byte("small")
+	common.Must2(hub.WriteTo(small, dest))
+
+	b := make([]byte, 16384)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for received := 0; received < 2; received++ {
+		n, _, err := conn.ReadFrom(b)
+		common.Must(err)
+		switch n {
+		case len(payload):
+			if !bytes.Equal(b[:n], payload) {
+				t.Error("large packet corrupted")
+			}
+		case len(small):
+		default:
+			t.Error("unexpected size ", n)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package internet
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/dice"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/signal/done"
+)
+
+const (
+	// sockets a flow may hold open, after which the oldest one is closed for a new destination
+	maxPerDestinationSockets = 64
+	// attempts to listen on a random port before leaving the choice to the system
+	randomPortAttempts = 8
+	minRandomPort      = 1024
+)
+
+type packet struct {
+	b    *buf.Buffer
+	addr net.Addr
+}
+
+// perDestinationConn is a PacketConn that sends to every destination from its own socket on a random port.
+type perDestinationConn struct {
+	ctx     context.Context
+	src     *net.UDPAddr
+	sockopt *SocketConfig
+
+	access  sync.Mutex
+	conns   map[string]net.PacketConn
+	order   []string
+	packets chan packet
+	done    *done.Instance
+
+	deadlineAccess sync.Mutex
+	readDeadline   time.Time
+}
+
+func newPerDestinationConn(ctx context.Context, src *net.UDPAddr, sockopt *SocketConfig) *perDestinationConn {
+	return &perDestinationConn{
+		ctx:     ctx,
+		src:     src,
+		sockopt: sockopt,
+		conns:   make(map[string]net.PacketConn),
+		packets: make(chan packet, 64),
+		done:    done.New(),
+	}
+}
+
+// listenRandomPort listens on a port picked at random, rather than by the system, whose choice is
+// sequential on some platforms.
+func (c *perDestinationConn) listenRandomPort() (net.PacketConn, error) {
+	for i := 0; i < randomPortAttempts; i++ {
+		addr := &net.UDPAddr{
+			IP:   c.src.IP,
+			Port: minRandomPort + dice.Roll(65536-minRandomPort),
+		}
+		if conn, err := ListenSystemPacket(c.ctx, addr, c.sockopt); err == nil {
+			return conn, nil
+		}
+	}
+	return ListenSystemPacket(c.ctx, &net.UDPAddr{IP: c.src.IP}, c.sockopt)
+}
+
+func (c *perDestinationConn) getConn(addr net.Addr) (net.PacketConn, error) {
+	c.access.Lock()
+	defer c.access.Unlock()
+
+	if c.done.Done() {
+		return nil, io.ErrClosedPipe
+	}
+	key := addr.String()
+	if conn, found := c.conns[key]; found {
+		return conn, nil
+	}
+	if len(c.order) >= maxPerDestinationSockets {
+		c.conns[c.order[0]].Close()
+		delete(c.conns, c.order[0])
+		c.order = c.order[1:]
+	}
+	conn, err := c.listenRandomPort()
+	if err != nil {
+		return nil, err
+	}
+	c.conns[key] = conn
+	c.order = append(c.order, key)
+	go c.readLoop(conn)
+	return conn, nil
+}
+
+func (c *perDestinationConn) readLoop(conn net.PacketConn) {
+	for {
+		b := buf.New()
+		b.Resize(0, buf.Size)
+		n, addr, err := conn.ReadFrom(b.Bytes())
+		if err != nil {
+			b.Release()
+			if !c.done.Done() {
+				newError("failed to read from socket of ", conn.LocalAddr()).Base(err).AtDebug().WriteToLog(session.ExportIDToError(c.ctx))
+			}
+			return
+		}
+		b.Resize(0, int32(n))
+		select {
+		case c.packets <- packet{b: b, addr: addr}:
+		case <-c.done.Wait():
+			b.Release()
+			return
+		}
+	}
+}
+
+func (c *perDestinationConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	c.deadlineAccess.Lock()
+	deadline := c.readDeadline
+	c.deadlineAccess.Unlock()
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case packet := <-c.packets:
+		n := copy(p, packet.b.Bytes())
+		packet.b.Release()
+		return n, packet.addr, nil
+	case <-c.done.Wait():
+		return 0, nil, io.ErrClosedPipe
+	case <-timeout:
+		return 0, nil, newError("read timeout")
+	}
+}
+
+func (c *perDestinationConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	conn, err := c.getConn(addr)
+	if err != nil {
+		return 0, err
+	}
+	return conn.WriteTo(p, addr)
+}
+
+func (c *perDestinationConn) Close() error {
+	c.access.Lock()
+	defer c.access.Unlock()
+
+	if c.done.Done() {
+		return nil
+	}
+	c.done.Close()
+	for _, conn := range c.conns {
+		conn.Close()
+	}
+	return nil
+}
+
+func (c *perDestinationConn) LocalAddr() net.Addr {
+	return c.src
+}
+
+func (c *perDestinationConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+func (c *perDestinationConn) SetReadDeadline(t time.Time) error {
+	c.deadlineAccess.Lock()
+	defer c.deadlineAccess.Unlock()
+
+	c.readDeadline = t
+	return nil
+}
+
+func (c *perDestinationConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
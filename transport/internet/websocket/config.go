@@ -20,6 +20,14 @@ func (c *Config) GetNormalizedPath() string {
 	return path
 }
 
+// GetNormalizedEarlyDataHeaderName returns the request header that carries early data.
+func (c *Config) GetNormalizedEarlyDataHeaderName() string {
+	if name := c.GetEarlyDataHeaderName(); name != "" {
+		return http.CanonicalHeaderKey(name)
+	}
+	return "Sec-WebSocket-Protocol"
+}
+
 func (c *Config) GetRequestHeader() http.Header {
 	header := http.Header{}
 	for _, h := range c.Header {
@@ -9,6 +9,7 @@ import (
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/errors"
 	"github.com/xtls/xray-core/common/serial"
+	"github.com/xtls/xray-core/transport/internet"
 )
 
 var _ buf.Writer = (*connection)(nil)
@@ -18,13 +19,16 @@ type connection struct {
 	conn       *websocket.Conn
 	reader     io.Reader
 	remoteAddr net.Addr
+	events     *internet.EventRecorder
+	ended      bool
 }
 
-func newConnection(conn *websocket.Conn, remoteAddr net.Addr, extraReader io.Reader) *connection {
+func newConnection(conn *websocket.Conn, remoteAddr net.Addr, extraReader io.Reader, events *internet.EventRecorder) *connection {
 	return &connection{
 		conn:       conn,
 		remoteAddr: remoteAddr,
 		reader:     extraReader,
+		events:     events,
 	}
 }
 
@@ -52,6 +56,10 @@ func (c *connection) getReader() (io.Reader, error) {
 
 	_, reader, err := c.conn.NextReader()
 	if err != nil {
+		if !c.ended && websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+			c.events.Record(internet.EventWebSocketAbnormalClose, 1)
+		}
+		c.ended = true
 		return nil, err
 	}
 	c.reader = reader
@@ -70,10 +70,14 @@ func init() {
 
 func dialWebSocket(ctx context.Context, dest net.Destination, streamSettings *internet.MemoryStreamConfig, ed []byte) (net.Conn, error) {
 	wsSettings := streamSettings.ProtocolSettings.(*Config)
+	dialDest, err := internet.OverrideDestination(dest, wsSettings.DialAddress)
+	if err != nil {
+		return nil, err
+	}
 
 	dialer := &websocket.Dialer{
 		NetDial: func(network, addr string) (net.Conn, error) {
-			return internet.DialSystem(ctx, dest, streamSettings.SocketSettings)
+			return internet.DialSystem(ctx, dialDest, streamSettings.SocketSettings)
 		},
 		ReadBufferSize:   4 * 1024,
 		WriteBufferSize:  4 * 1024,
@@ -89,7 +93,7 @@ func dialWebSocket(ctx context.Context, dest net.Destination, streamSettings *in
 		if fingerprint := tls.GetFingerprint(config.Fingerprint); fingerprint != nil {
 			dialer.NetDialTLSContext = func(_ context.Context, _, addr string) (gonet.Conn, error) {
 				// Like the NetDial in the dialer
-				pconn, err := internet.DialSystem(ctx, dest, streamSettings.SocketSettings)
+				pconn, err := internet.DialSystem(ctx, dialDest, streamSettings.SocketSettings)
 				if err != nil {
 					newError("failed to dial to " + addr).Base(err).AtError().WriteToLog()
 					return nil, err
@@ -138,13 +142,16 @@ func dialWebSocket(ctx context.Context, dest net.Destination, streamSettings *in
 			conn.Close()
 			return nil, newError(s)
 		}
-		return newConnection(conn, conn.RemoteAddr(), nil), nil
+		return newConnection(conn, conn.RemoteAddr(), nil, streamSettings.Events), nil
 	}
 
 	header := wsSettings.GetRequestHeader()
+	if wsSettings.Host != "" {
+		header.Set("Host", wsSettings.Host)
+	}
 	if ed != nil {
 		// RawURLEncoding is support by both V2Ray/V2Fly and XRay.
-		header.Set("Sec-WebSocket-Protocol", base64.RawURLEncoding.EncodeToString(ed))
+		header.Set(wsSettings.GetNormalizedEarlyDataHeaderName(), base64.RawURLEncoding.EncodeToString(ed))
 	}
 
 	conn, resp, err := dialer.Dial(uri, header)
@@ -156,7 +163,7 @@ func dialWebSocket(ctx context.Context, dest net.Destination, streamSettings *in
 		return nil, newError("failed to dial to (", uri, "): ", reason).Base(err)
 	}
 
-	return newConnection(conn, conn.RemoteAddr(), nil), nil
+	return newConnection(conn, conn.RemoteAddr(), nil, streamSettings.Events), nil
 }
 
 type delayDialConn struct {
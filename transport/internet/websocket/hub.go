@@ -44,10 +44,14 @@ func (h *requestHandler) ServeHTTP(writer http.ResponseWriter, request *http.Req
 
 	var extraReader io.Reader
 	responseHeader := http.Header{}
-	if str := request.Header.Get("Sec-WebSocket-Protocol"); str != "" {
+	edHeader := h.ln.config.GetNormalizedEarlyDataHeaderName()
+	if str := request.Header.Get(edHeader); str != "" {
 		if ed, err := base64.RawURLEncoding.DecodeString(replacer.Replace(str)); err == nil && len(ed) > 0 {
 			extraReader = bytes.NewReader(ed)
-			responseHeader.Set("Sec-WebSocket-Protocol", str)
+			if edHeader == "Sec-WebSocket-Protocol" {
+				// the client expects the subprotocol it offered to be accepted
+				responseHeader.Set(edHeader, str)
+			}
 		}
 	}
 
@@ -66,7 +70,7 @@ func (h *requestHandler) ServeHTTP(writer http.ResponseWriter, request *http.Req
 		}
 	}
 
-	h.ln.addConn(newConnection(conn, remoteAddr, extraReader))
+	h.ln.addConn(newConnection(conn, remoteAddr, extraReader, h.ln.events))
 }
 
 type Listener struct {
@@ -76,11 +80,13 @@ type Listener struct {
 	config   *Config
 	addConn  internet.ConnHandler
 	locker   *internet.FileLocker // for unix domain socket
+	events   *internet.EventRecorder
 }
 
 func ListenWS(ctx context.Context, address net.Address, port net.Port, streamSettings *internet.MemoryStreamConfig, addConn internet.ConnHandler) (internet.Listener, error) {
 	l := &Listener{
 		addConn: addConn,
+		events:  streamSettings.Events,
 	}
 	wsSettings := streamSettings.ProtocolSettings.(*Config)
 	l.config = wsSettings
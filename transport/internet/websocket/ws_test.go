@@ -147,3 +147,88 @@ func Test_listenWSAndDial_TLS(t *testing.T) {
 		t.Error("end: ", end, " start: ", start)
 	}
 }
+
+func TestDialFronted(t *testing.T) {
+	streamSettings := &internet.MemoryStreamConfig{
+		ProtocolName: "websocket",
+		ProtocolSettings: &Config{
+			Path:        "ws",
+			Host:        "hidden.example.com",
+			DialAddress: "127.0.0.1:13149",
+		},
+		SecurityType: "tls",
+		SecuritySettings: &tls.Config{
+			AllowInsecure: true,
+			Certificate:   []*tls.Certificate{tls.ParseCertificate(cert.MustGenerate(nil, cert.CommonName("front.invalid")))},
+		},
+	}
+	listen, err := ListenWS(context.Background(), net.LocalHostIP, 13149, streamSettings, func(conn stat.Connection) {
+		go func(c stat.Connection) {
+			defer c.Close()
+
+			var b [1024]byte
+			if _, err := c.Read(b[:]); err != nil {
+				return
+			}
+			common.Must2(c.Write([]byte("Response")))
+		}(conn)
+	})
+	common.Must(err)
+	defer listen.Close()
+
+	// front.invalid never resolves, so the connection can only go through the dial address
+	conn, err := Dial(context.Background(), net.TCPDestination(net.DomainAddress("front.invalid"), 443), streamSettings)
+	common.Must(err)
+	defer conn.Close()
+
+	common.Must2(conn.Write([]byte("Test connection")))
+	var b [1024]byte
+	n, err := conn.Read(b[:])
+	common.Must(err)
+	if string(b[:n]) != "Response" {
+		t.Error("response: ", string(b[:n]))
+	}
+}
+
+func TestDialEarlyData(t *testing.T) {
+	config := &Config{
+		Path:                "ws",
+		Ed:                  2048,
+		EarlyDataHeaderName: "X-Early-Data",
+	}
+	listen, err := ListenWS(context.Background(), net.LocalHostIP, 13149, &internet.MemoryStreamConfig{
+		ProtocolName:     "websocket",
+		ProtocolSettings: config,
+	}, func(conn stat.Connection) {
+		go func(c stat.Connection) {
+			defer c.Close()
+
+			var b [1024]byte
+			n, err := c.Read(b[:])
+			if err != nil {
+				return
+			}
+
+			common.Must2(c.Write(append([]byte("Response: "), b[:n]...)))
+		}(conn)
+	})
+	common.Must(err)
+	defer listen.Close()
+
+	conn, err := Dial(context.Background(), net.TCPDestination(net.DomainAddress("localhost"), 13149), &internet.MemoryStreamConfig{
+		ProtocolName:     "websocket",
+		ProtocolSettings: config,
+	})
+	common.Must(err)
+	defer conn.Close()
+
+	// the early data is only sent in the header, so the response proves the listener read it from there
+	common.Must2(conn.Write([]byte("early data")))
+
+	var b [1024]byte
+	n, err := conn.Read(b[:])
+	common.Must(err)
+	if string(b[:n]) != "Response: early data" {
+		t.Error("response: ", string(b[:n]))
+	}
+}